@@ -0,0 +1,143 @@
+// Package arrowblosc compresses the buffers of Apache Arrow arrays with
+// Blosc, deriving the element size of each buffer from the Arrow data type so
+// that numeric columns are shuffled before compression.
+//
+// The package does not import Arrow: its DataType interface is satisfied by
+// arrow.DataType from github.com/apache/arrow-go, and buffers are plain byte
+// slices, such as those returned by memory.Buffer.Bytes. Compressed buffers
+// use the framing of Arrow IPC body compression: the uncompressed length as a
+// little-endian int64, then the compressed bytes, or -1 then the raw bytes
+// when compression does not pay.
+package arrowblosc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	blosc "github.com/mrjoshuak/go-blosc"
+)
+
+// DataType is the part of arrow.DataType that buffer layouts depend on.
+type DataType interface {
+	Name() string
+}
+
+// fixedWidth is implemented by Arrow's fixed-width types
+type fixedWidth interface {
+	BitWidth() int
+}
+
+// ErrInvalidBuffer indicates a compressed buffer that is too short or whose
+// length prefix does not match its contents.
+var ErrInvalidBuffer = errors.New("arrowblosc: invalid compressed buffer")
+
+// uncompressed is the length prefix of a buffer stored as is
+const uncompressed = -1
+
+// prefixSize is the size of the length prefix
+const prefixSize = 8
+
+// BufferTypeSize returns the element size of buffer i of an array of type dt,
+// following the Arrow columnar layout: validity bitmaps and byte data have
+// size 1, offsets 4 or 8 for the large variants, and fixed-width values their
+// width in bytes.
+func BufferTypeSize(dt DataType, i int) int {
+	if i == 0 {
+		return 1 // Validity bitmap
+	}
+	switch dt.Name() {
+	case "utf8", "binary", "list", "map":
+		if i == 1 {
+			return 4
+		}
+		return 1
+	case "large_utf8", "large_binary", "large_list":
+		if i == 1 {
+			return 8
+		}
+		return 1
+	}
+	if fw, ok := dt.(fixedWidth); ok && fw.BitWidth() >= 8 && fw.BitWidth()%8 == 0 {
+		return fw.BitWidth() / 8
+	}
+	return 1
+}
+
+// Compressor compresses Arrow buffers.
+type Compressor struct {
+	Codec blosc.Codec
+	Level int
+}
+
+// DefaultCompressor favors speed, as Arrow's LZ4 frame compression does.
+var DefaultCompressor = Compressor{Codec: blosc.LZ4, Level: 5}
+
+// options returns the Blosc options for elements of typeSize bytes. Only
+// multi-byte elements benefit from a byte shuffle.
+func (c Compressor) options(typeSize int) blosc.Options {
+	opts := blosc.Options{Codec: c.Codec, Level: c.Level, TypeSize: typeSize}
+	if typeSize > 1 {
+		opts.Shuffle = blosc.Shuffle1
+	}
+	return opts
+}
+
+// CompressBuffer compresses buffer i of an array of type dt. Empty buffers
+// stay empty.
+func (c Compressor) CompressBuffer(dt DataType, i int, buf []byte) ([]byte, error) {
+	if len(buf) == 0 {
+		return nil, nil
+	}
+	chunk, err := blosc.CompressWithOptions(buf, c.options(BufferTypeSize(dt, i)))
+	if err != nil {
+		return nil, err
+	}
+	if len(chunk) >= len(buf) {
+		return appendPrefixed(uncompressed, buf), nil
+	}
+	return appendPrefixed(int64(len(buf)), chunk), nil
+}
+
+// appendPrefixed returns body prefixed with length n
+func appendPrefixed(n int64, body []byte) []byte {
+	out := make([]byte, prefixSize, prefixSize+len(body))
+	binary.LittleEndian.PutUint64(out, uint64(n))
+	return append(out, body...)
+}
+
+// CompressBuffers compresses all buffers of an array of type dt, in layout
+// order.
+func (c Compressor) CompressBuffers(dt DataType, bufs [][]byte) ([][]byte, error) {
+	out := make([][]byte, len(bufs))
+	for i, buf := range bufs {
+		var err error
+		if out[i], err = c.CompressBuffer(dt, i, buf); err != nil {
+			return nil, fmt.Errorf("buffer %d: %w", i, err)
+		}
+	}
+	return out, nil
+}
+
+// DecompressBuffer decompresses a buffer written by CompressBuffer.
+func DecompressBuffer(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	if len(data) < prefixSize {
+		return nil, fmt.Errorf("%w: %d bytes", ErrInvalidBuffer, len(data))
+	}
+	n := int64(binary.LittleEndian.Uint64(data))
+	body := data[prefixSize:]
+	if n == uncompressed {
+		return body, nil
+	}
+	header, err := blosc.GetInfo(body)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 || int64(header.NBytesOrig) != n {
+		return nil, fmt.Errorf("%w: prefix claims %d bytes, chunk holds %d", ErrInvalidBuffer, n, header.NBytesOrig)
+	}
+	return blosc.Decompress(body)
+}
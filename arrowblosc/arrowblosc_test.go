@@ -0,0 +1,122 @@
+package arrowblosc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+
+	blosc "github.com/mrjoshuak/go-blosc"
+)
+
+// Stand-ins for arrow-go data types, which have the same methods
+type fixedType struct {
+	name  string
+	width int
+}
+
+func (t fixedType) Name() string  { return t.name }
+func (t fixedType) BitWidth() int { return t.width }
+
+type varType string
+
+func (t varType) Name() string { return string(t) }
+
+var (
+	float64Type   = fixedType{"float64", 64}
+	int16Type     = fixedType{"int16", 16}
+	boolType      = fixedType{"bool", 1}
+	stringType    = varType("utf8")
+	largeListType = varType("large_list")
+)
+
+func TestBufferTypeSize(t *testing.T) {
+	tests := []struct {
+		dt   DataType
+		i    int
+		want int
+	}{
+		{float64Type, 0, 1},
+		{float64Type, 1, 8},
+		{int16Type, 1, 2},
+		{boolType, 1, 1},
+		{stringType, 1, 4},
+		{stringType, 2, 1},
+		{largeListType, 1, 8},
+		{varType("struct"), 1, 1},
+	}
+	for _, tt := range tests {
+		if got := BufferTypeSize(tt.dt, tt.i); got != tt.want {
+			t.Errorf("BufferTypeSize(%s, %d) = %d, want %d", tt.dt.Name(), tt.i, got, tt.want)
+		}
+	}
+}
+
+func TestCompressBuffers(t *testing.T) {
+	values := make([]byte, 8*10000)
+	for i := 0; i < 10000; i++ {
+		binary.LittleEndian.PutUint64(values[8*i:], math.Float64bits(float64(i)*0.25))
+	}
+	validity := bytes.Repeat([]byte{0xFF}, 10000/8)
+	bufs := [][]byte{validity, values}
+
+	compressed, err := DefaultCompressor.CompressBuffers(float64Type, bufs)
+	if err != nil {
+		t.Fatalf("CompressBuffers failed: %v", err)
+	}
+	for i, buf := range compressed {
+		if len(buf) >= len(bufs[i]) {
+			t.Errorf("buffer %d did not shrink: %d bytes", i, len(buf))
+		}
+		got, err := DecompressBuffer(buf)
+		if err != nil {
+			t.Fatalf("buffer %d: %v", i, err)
+		}
+		if !bytes.Equal(got, bufs[i]) {
+			t.Errorf("buffer %d does not round trip", i)
+		}
+	}
+
+	// The values buffer is shuffled by its element size
+	info, err := blosc.GetInfo(compressed[1][prefixSize:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.TypeSize != 8 || !info.HasShuffle() {
+		t.Errorf("values compressed with typesize %d, shuffle %v", info.TypeSize, info.HasShuffle())
+	}
+}
+
+func TestCompressBufferIncompressible(t *testing.T) {
+	buf := []byte("short")
+	out, err := DefaultCompressor.CompressBuffer(stringType, 2, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(binary.LittleEndian.Uint64(out)) != -1 || !bytes.Equal(out[prefixSize:], buf) {
+		t.Errorf("expected a raw buffer, got % x", out)
+	}
+	got, err := DecompressBuffer(out)
+	if err != nil || !bytes.Equal(got, buf) {
+		t.Errorf("raw buffer: %q, %v", got, err)
+	}
+
+	if out, err := DefaultCompressor.CompressBuffer(stringType, 2, nil); err != nil || out != nil {
+		t.Errorf("empty buffer: %v, %v", out, err)
+	}
+}
+
+func TestDecompressBufferErrors(t *testing.T) {
+	if _, err := DecompressBuffer([]byte{1, 2, 3}); !errors.Is(err, ErrInvalidBuffer) {
+		t.Errorf("expected ErrInvalidBuffer, got %v", err)
+	}
+	out, err := DefaultCompressor.CompressBuffer(int16Type, 1, make([]byte, 4096))
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary.LittleEndian.PutUint64(out, 4095)
+	if _, err := DecompressBuffer(out); !errors.Is(err, ErrInvalidBuffer) {
+		t.Errorf("expected ErrInvalidBuffer for a wrong length, got %v", err)
+	}
+}
@@ -0,0 +1,187 @@
+package blosc
+
+import "sync"
+
+// Result is a chunk compressed by an AsyncCompressor, or the error
+// compressing it, for the buffer submitted under ID.
+type Result struct {
+	ID    int
+	Chunk []byte
+	Err   error
+}
+
+// AsyncCompressor compresses submitted buffers in the background, so that
+// ingestion need not wait for each chunk. Work is pipelined: filter
+// goroutines shuffle a buffer's blocks while codec goroutines compress
+// blocks of earlier buffers. Each chunk is the one CompressWithOptions
+// would produce. Results arrive in completion order, not submission order.
+type AsyncCompressor struct {
+	opts    Options
+	mu      sync.RWMutex // Held by Submit, and by Close to stop them
+	closed  bool
+	jobs    chan *asyncJob // To the filter stage
+	encode  chan *asyncJob // To the codec stage
+	results chan Result
+}
+
+// asyncJob is a buffer on its way through an AsyncCompressor
+type asyncJob struct {
+	id       int
+	data     []byte
+	b        *chunkBuilder // Builder of a chunk to encode, if any
+	filtered []byte        // Filtered blocks for b to encode
+	chunk    []byte
+	err      error
+}
+
+// NewAsyncCompressor starts an AsyncCompressor compressing with opts, using
+// opts.NumThreads codec goroutines, or MaxWorkers if 0, and a filter
+// goroutine for every four of them. Across all AsyncCompressors, at most
+// MaxWorkers of these goroutines compress at once. Call Close when done
+// submitting.
+func NewAsyncCompressor(opts Options) *AsyncCompressor {
+	threads := opts.NumThreads
+	if threads <= 0 {
+		threads = MaxWorkers()
+	}
+	filterers := (threads + 3) / 4
+	a := &AsyncCompressor{
+		opts:    opts,
+		jobs:    make(chan *asyncJob, threads),
+		encode:  make(chan *asyncJob, threads),
+		results: make(chan Result, threads),
+	}
+
+	var filtering, encoding sync.WaitGroup
+	for i := 0; i < filterers; i++ {
+		filtering.Add(1)
+		go func() {
+			defer filtering.Done()
+			for j := range a.jobs {
+				workers.acquire()
+				a.filterJob(j)
+				workers.release()
+				a.encode <- j
+			}
+		}()
+	}
+	for i := 0; i < threads; i++ {
+		encoding.Add(1)
+		go func() {
+			defer encoding.Done()
+			for j := range a.encode {
+				workers.acquire()
+				encodeJob(j)
+				workers.release()
+				a.results <- Result{ID: j.id, Chunk: j.chunk, Err: j.err}
+			}
+		}()
+	}
+	go func() {
+		filtering.Wait()
+		close(a.encode)
+		encoding.Wait()
+		close(a.results)
+	}()
+	return a
+}
+
+// Submit queues data for compression under id, blocking only while the
+// queue is full. data must not be modified until its Result arrives. Submit
+// fails with ErrClosed after Close.
+func (a *AsyncCompressor) Submit(id int, data []byte) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		return ErrClosed
+	}
+	a.jobs <- &asyncJob{id: id, data: data}
+	return nil
+}
+
+// Results returns the channel Results are delivered on. It is closed once
+// Close has been called and every submitted buffer has its Result. Results
+// must be received, on another goroutine than the one submitting, for
+// compression to keep going.
+func (a *AsyncCompressor) Results() <-chan Result {
+	return a.results
+}
+
+// Close stops accepting buffers. Those already submitted are still
+// compressed and delivered.
+func (a *AsyncCompressor) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.closed {
+		a.closed = true
+		close(a.jobs)
+	}
+	return nil
+}
+
+// filterJob runs the filter stage: the prefilter and filter pipeline of
+// every block. Chunks that do not go block by block through a codec, such
+// as special-value, memcpy and legacy chunks, are finished here.
+func (a *AsyncCompressor) filterJob(j *asyncJob) {
+	opts, err := normalizeOptions(a.opts)
+	n := len(j.data)
+	if err != nil || n == 0 || checkBufferSize(int64(n)) != nil {
+		j.chunk, j.err = compressWithEnv(j.data, a.opts, nil)
+		return
+	}
+	if opts.TypeSize == TypeSizeAuto {
+		opts.TypeSize = pickTypeSize(j.data, opts)
+	}
+	if opts.SpecialValues && !opts.LegacyFormat && opts.prefilter() == nil {
+		if chunk, ok := detectSpecial(j.data, opts); ok {
+			logSpecial(&opts, n, chunk)
+			reportProgress(opts.progress(), n, n)
+			j.chunk = chunk
+			return
+		}
+	}
+	if opts.Shuffle == AutoShuffle {
+		opts.Shuffle = pickShuffle(j.data, opts)
+	}
+	b, err := newChunkBuilder(n, opts, nil)
+	if err != nil {
+		j.err = err
+		return
+	}
+	if b.legacy || b.memcpy() {
+		j.chunk, j.err = compressBackend(j.data, opts, nil)
+		return
+	}
+	blockSize := int(b.header.BlockSize)
+	j.filtered = make([]byte, n)
+	for i := 0; i < b.nblocks; i++ {
+		filtered, err := b.filter(i, j.data[i*blockSize:min((i+1)*blockSize, n)])
+		if err != nil {
+			j.err = err
+			return
+		}
+		copy(j.filtered[i*blockSize:], filtered)
+	}
+	j.b = b
+}
+
+// encodeJob runs the codec stage, if the filter stage left any work
+func encodeJob(j *asyncJob) {
+	b := j.b
+	if b == nil {
+		return
+	}
+	blockSize := int(b.header.BlockSize)
+	for i := 0; i < b.nblocks && !b.memcpy(); i++ {
+		if err := b.encode(j.filtered[i*blockSize : min((i+1)*blockSize, len(j.filtered))]); err != nil {
+			j.err = err
+			return
+		}
+	}
+	if b.memcpy() {
+		j.chunk = b.memcpyChunk(j.data)
+	} else {
+		j.chunk = b.finish()
+	}
+	b.logChunk(len(j.data), j.chunk)
+}
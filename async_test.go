@@ -0,0 +1,121 @@
+package blosc
+
+import (
+	"bytes"
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAsyncCompressor(t *testing.T) {
+	inputs := [][]byte{
+		makeFloatData(300000),
+		makeTestData(100),
+		make([]byte, 200000),
+		makeTestData(1 << 20),
+		nil,
+	}
+	for _, opts := range []Options{
+		{Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: 4, NumThreads: 3},
+		{Codec: ZSTD, Level: 3, TypeSize: 4, Filters: [MaxFilters]FilterStage{{Filter: FilterShuffle}, {Filter: FilterByteDelta, Meta: 4}}},
+		{Codec: LZ4, Level: 5, TypeSize: 4, SpecialValues: true, NumThreads: 1},
+		{Codec: Snappy, Level: 5, Shuffle: Shuffle1, TypeSize: 4, LegacyFormat: true},
+	} {
+		a := NewAsyncCompressor(opts)
+		var wg sync.WaitGroup
+		results := make(map[int]Result)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range a.Results() {
+				results[r.ID] = r
+			}
+		}()
+		for round := 0; round < 3; round++ {
+			for i, data := range inputs {
+				if err := a.Submit(round*len(inputs)+i, data); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		a.Close()
+		wg.Wait()
+
+		if len(results) != 3*len(inputs) {
+			t.Fatalf("%+v: %d results", opts, len(results))
+		}
+		for id, r := range results {
+			data := inputs[id%len(inputs)]
+			want, wantErr := CompressWithOptions(data, opts)
+			if !bytes.Equal(r.Chunk, want) || !errors.Is(r.Err, wantErr) {
+				t.Errorf("%+v: result %d differs from CompressWithOptions: %v, %v", opts, id, r.Err, wantErr)
+			}
+		}
+
+		if err := a.Submit(0, inputs[0]); !errors.Is(err, ErrClosed) {
+			t.Errorf("Submit after Close: got %v", err)
+		}
+		if err := a.Close(); err != nil {
+			t.Errorf("second Close: %v", err)
+		}
+	}
+}
+
+func TestSetMaxWorkers(t *testing.T) {
+	prev := SetMaxWorkers(2)
+	t.Cleanup(func() { SetMaxWorkers(prev) })
+	if n := MaxWorkers(); n != 2 {
+		t.Fatalf("MaxWorkers = %d, want 2", n)
+	}
+
+	// Callbacks of the filter and codec stages of two compressors never
+	// overlap beyond the cap
+	var mu sync.Mutex
+	running, peak := 0, 0
+	enter := func() {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+	}
+	opts := Options{Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: 4, BlockSize: 16384, NumThreads: 8,
+		Hooks: &Hooks{
+			Prefilter: func([]byte, int) { enter() },
+			Progress:  func(int64, int64) { enter() },
+		},
+	}
+	data := makeTestData(65536)
+	var wg sync.WaitGroup
+	for c := 0; c < 2; c++ {
+		a := NewAsyncCompressor(opts)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range a.Results() {
+				if r.Err != nil {
+					t.Error(r.Err)
+				}
+			}
+		}()
+		for i := 0; i < 8; i++ {
+			if err := a.Submit(i, data); err != nil {
+				t.Fatal(err)
+			}
+		}
+		a.Close()
+	}
+	wg.Wait()
+	if peak > 2 {
+		t.Errorf("%d workers ran at once, cap is 2", peak)
+	}
+
+	if SetMaxWorkers(0); MaxWorkers() != runtime.GOMAXPROCS(0) {
+		t.Errorf("default MaxWorkers = %d, want GOMAXPROCS", MaxWorkers())
+	}
+}
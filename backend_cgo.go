@@ -0,0 +1,79 @@
+//go:build cgo_blosc && cgo
+
+package blosc
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/mrjoshuak/go-blosc/internal/cblosc"
+)
+
+// CgoBackend reports whether chunks are compressed and decompressed by
+// libblosc where it can. It is set by building with the cgo_blosc tag and
+// cgo enabled.
+const CgoBackend = true
+
+// compressBackend compresses with libblosc when the options ask for nothing
+// beyond the Blosc1 format c-blosc writes, and with the pure Go codecs
+// otherwise or when libblosc fails, for instance for a codec it was built
+// without. CompressFrom always uses the pure Go codecs.
+func compressBackend(data []byte, opts Options, env *filterEnv) ([]byte, error) {
+	if !cgoCompressible(opts) {
+		return compressGo(data, opts, env)
+	}
+	threads := min(max(opts.NumThreads, 1), MaxWorkers())
+	start := time.Now()
+	chunk, err := cblosc.CompressBlocks(data, opts.Codec.String(), opts.Level, int(opts.Shuffle), opts.TypeSize, opts.BlockSize, threads)
+	if err != nil {
+		return compressGo(data, opts, env)
+	}
+	if log := debugLogger(&opts); log != nil {
+		log.LogAttrs(context.Background(), slog.LevelDebug, "blosc: compressed chunk",
+			slog.String("codec", opts.Codec.String()),
+			slog.Int("clevel", opts.Level),
+			slog.String("filters", filterNames(opts)),
+			slog.Int("typesize", opts.TypeSize),
+			slog.Int("nbytes", len(data)),
+			slog.Int("cbytes", len(chunk)),
+			slog.String("layout", "libblosc"),
+			slog.Duration("elapsed", time.Since(start)))
+	}
+	reportProgress(opts.progress(), len(data), len(data))
+	return chunk, nil
+}
+
+// cgoCompressible reports whether libblosc can write the chunk opts describe
+func cgoCompressible(opts Options) bool {
+	if format, ok := codecFormat(opts.Codec); !ok || format == formatUDCodec {
+		return false
+	}
+	return opts.Level >= 1 && opts.Level <= 9 && opts.Shuffle <= BitShuffle &&
+		!opts.LegacyFormat && !opts.SkipIncompressible && !opts.BlockChecksums && !opts.Deterministic && opts.prefilter() == nil &&
+		opts.Filters == [MaxFilters]FilterStage{} && opts.CodecParams == CodecParams{}
+}
+
+// decompressBackend decompresses Blosc1 chunks with libblosc, and any other
+// chunk, including go-blosc 1.0.x chunks, with the pure Go codecs.
+func decompressBackend(dst, data []byte, typeSize, maxBytes int, env *filterEnv) ([]byte, error) {
+	header, _, err := checkChunk(data, typeSize, maxBytes)
+	if err != nil {
+		return dst, err
+	}
+	if header.Version != FormatVersion || header.legacy || header.NBytesOrig == 0 ||
+		(typeSize > 0 && typeSize != int(header.TypeSize)) ||
+		(env != nil && (env.postfilter != nil || env.skippable != nil)) {
+		return decompressGo(dst, data, typeSize, maxBytes, env)
+	}
+
+	start := len(dst)
+	out := slices.Grow(dst, int(header.NBytesOrig))[:start+int(header.NBytesOrig)]
+	if err := cblosc.DecompressInto(out[start:], data[:header.NBytesComp], 1); err != nil {
+		// Report the error the pure Go codecs find
+		return decompressGo(dst, data, typeSize, maxBytes, env)
+	}
+	env.reportProgress(int(header.NBytesOrig), int(header.NBytesOrig))
+	return out, nil
+}
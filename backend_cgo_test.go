@@ -0,0 +1,153 @@
+//go:build cgo && cgo_blosc
+
+package blosc
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/mrjoshuak/go-blosc/internal/cblosc"
+)
+
+// Differential tests of the libblosc backend against the pure Go codecs. Run
+// with: go test -tags cgo_blosc -run CgoBackend .
+
+func TestCgoBackendMatchesGo(t *testing.T) {
+	inputs := map[string][]byte{
+		"payload": InteropPayload(),
+		"floats":  makeFloatData(1 << 16),
+		"small":   makeTestData(100),
+		"noise":   makeTestDataPure(70001),
+	}
+
+	for _, codec := range interopCodecs {
+		for _, shuffle := range []Shuffle{NoShuffle, Shuffle1, BitShuffle} {
+			for _, typeSize := range []int{1, 4, 8, 20} {
+				for _, level := range []int{1, 5, 9} {
+					for inputName, data := range inputs {
+						name := fmt.Sprintf("%s-%s-ts%d-l%d-%s", codec, shuffle, typeSize, level, inputName)
+						t.Run(name, func(t *testing.T) {
+							opts := Options{Codec: codec, Level: level, Shuffle: shuffle, TypeSize: typeSize}
+							checkCgoBackend(t, data, opts)
+						})
+					}
+				}
+			}
+		}
+	}
+}
+
+// checkCgoBackend checks that chunks of data written by libblosc and by the
+// pure Go codecs decode to data with both
+func checkCgoBackend(t *testing.T, data []byte, opts Options) {
+	t.Helper()
+	chunks := map[string][]byte{}
+	var err error
+	if chunks["c"], err = compressBackend(data, opts, nil); err != nil {
+		t.Fatalf("backend compress failed: %v", err)
+	}
+	if chunks["go"], err = compressGo(data, opts, nil); err != nil {
+		t.Fatalf("go compress failed: %v", err)
+	}
+
+	for writer, chunk := range chunks {
+		got, err := decompressGo(nil, chunk, 0, -1, nil)
+		if err != nil || !bytes.Equal(got, data) {
+			t.Errorf("go cannot read the %s chunk: %v", writer, err)
+		}
+		got, err = decompressBackend(nil, chunk, 0, -1, nil)
+		if err != nil || !bytes.Equal(got, data) {
+			t.Errorf("backend cannot read the %s chunk: %v", writer, err)
+		}
+		if h, _ := ParseHeader(chunk); h.IsExtended() {
+			continue
+		}
+		got, err = cblosc.Decompress(chunk)
+		if err != nil || !bytes.Equal(got, data) {
+			t.Errorf("c-blosc cannot read the %s chunk: %v", writer, err)
+		}
+	}
+}
+
+func TestCgoBackendFallsBack(t *testing.T) {
+	data := makeFloatData(1 << 16)
+	tests := []struct {
+		name string
+		set  func(o *Options)
+	}{
+		{"filters", func(o *Options) {
+			o.Filters[0] = FilterStage{Filter: FilterByteDelta}
+			o.Filters[1] = FilterStage{Filter: FilterShuffle}
+		}},
+		{"store", func(o *Options) {
+			o.Level = 0
+			o.Filters[0] = FilterStage{Filter: FilterBitShuffle}
+		}},
+		{"legacy", func(o *Options) { o.LegacyFormat = true }},
+		{"codec params", func(o *Options) { o.CodecParams.ZSTD.Level = 19 }},
+		{"skip incompressible", func(o *Options) { o.SkipIncompressible = true }},
+		{"block checksums", func(o *Options) { o.BlockChecksums = true }},
+	}
+	for _, codec := range interopCodecs {
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("%s-%s", codec, tt.name), func(t *testing.T) {
+				opts := Options{Codec: codec, Level: 5, Shuffle: Shuffle1, TypeSize: 4}
+				tt.set(&opts)
+				if cgoCompressible(opts) {
+					t.Fatal("libblosc cannot write these options")
+				}
+				got, err := compressBackend(data, opts, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want, err := compressGo(data, opts, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Error("backend differs from the pure Go codecs")
+				}
+				checkCgoBackend(t, data, opts)
+			})
+		}
+	}
+
+	// A type size override and a postfilter are applied by the Go decoder
+	chunk, err := Compress(data, LZ4, 5, Shuffle1, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := decompressGo(nil, chunk, 2, -1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := decompressBackend(nil, chunk, 2, -1, nil); err != nil || !bytes.Equal(got, want) {
+		t.Errorf("type size override: %v", err)
+	}
+	calls := 0
+	env := &filterEnv{postfilter: func([]byte, int) { calls++ }}
+	if _, err := decompressBackend(nil, chunk, 0, -1, env); err != nil || calls == 0 {
+		t.Errorf("postfilter: %d calls, %v", calls, err)
+	}
+}
+
+func TestCgoBackendErrors(t *testing.T) {
+	chunk, err := Compress(makeFloatData(1<<14), ZSTD, 5, Shuffle1, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt := bytes.Clone(chunk)
+	for i := HeaderSize + 16; i < len(corrupt); i++ {
+		corrupt[i] ^= 0x5a
+	}
+	_, want := decompressGo(nil, corrupt, 0, -1, nil)
+	dst := []byte("kept")
+	got, err := decompressBackend(dst, corrupt, 0, -1, nil)
+	if want == nil || err == nil || err.Error() != want.Error() {
+		t.Errorf("expected %v, got %v", want, err)
+	}
+	if string(got) != "kept" {
+		t.Errorf("dst changed on error: %q", got)
+	}
+}
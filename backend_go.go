@@ -0,0 +1,16 @@
+//go:build !cgo_blosc || !cgo
+
+package blosc
+
+// CgoBackend reports whether chunks are compressed and decompressed by
+// libblosc where it can. It is set by building with the cgo_blosc tag and
+// cgo enabled.
+const CgoBackend = false
+
+func compressBackend(data []byte, opts Options, env *filterEnv) ([]byte, error) {
+	return compressGo(data, opts, env)
+}
+
+func decompressBackend(dst, data []byte, typeSize, maxBytes int, env *filterEnv) ([]byte, error) {
+	return decompressGo(dst, data, typeSize, maxBytes, env)
+}
@@ -27,6 +27,8 @@
 //   - Shuffle: Byte shuffle - groups bytes by position within elements
 //   - BitShuffle: Bit-level shuffle for maximum compression of typed data
 //
+// AutoShuffle tries all three on a sample of each chunk and keeps the best.
+//
 // # Supported Codecs
 //
 //   - LZ4: Very fast compression/decompression (default)
@@ -40,22 +42,33 @@
 package blosc
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"math"
+	"slices"
+	"time"
 )
 
 // Version constants
 const (
 	Version       = "1.0.0"
 	FormatVersion = 2 // Blosc format version
+
+	// Blosc2FormatVersion is the version written to chunks with an extended
+	// header, which Options.Filters requires. It matches c-blosc2's stable format.
+	Blosc2FormatVersion = 5
 )
 
 // Codec identifies the compression algorithm
 type Codec uint8
 
 const (
-	BloscLZ Codec = iota // BloscLZ (internal, not implemented)
+	BloscLZ Codec = iota // BloscLZ, the c-blosc default
 	LZ4                  // LZ4 compression
 	LZ4HC                // LZ4 High Compression
 	Snappy               // Snappy compression
@@ -90,8 +103,19 @@ const (
 	NoShuffle  Shuffle = 0x0 // No shuffle
 	Shuffle1   Shuffle = 0x1 // Byte shuffle
 	BitShuffle Shuffle = 0x2 // Bit shuffle
+
+	// AutoShuffle picks one of the modes above for each chunk, whichever
+	// compresses the start of the input best. The chunk header records the
+	// mode picked, so chunks read as any other.
+	AutoShuffle Shuffle = 0x3
 )
 
+// TypeSizeAuto, as Options.TypeSize, picks the element size of each chunk
+// from 1, 2, 4 and 8, whichever compresses the start of the input best when
+// shuffled, for data whose element size is not known. The chunk header
+// records the size picked.
+const TypeSizeAuto = -1
+
 // String returns the shuffle mode name
 func (s Shuffle) String() string {
 	switch s {
@@ -101,6 +125,8 @@ func (s Shuffle) String() string {
 		return "shuffle"
 	case BitShuffle:
 		return "bitshuffle"
+	case AutoShuffle:
+		return "auto"
 	default:
 		return fmt.Sprintf("unknown(%d)", s)
 	}
@@ -108,16 +134,109 @@ func (s Shuffle) String() string {
 
 // Flag bits in the Blosc header
 const (
-	flagShuffle    = 0x1 // Byte shuffle enabled
-	flagMemcpy     = 0x2 // Data stored uncompressed (memcpy)
-	flagBitShuffle = 0x4 // Bit shuffle enabled
-	flagSplit      = 0x8 // Split blocks (not commonly used)
+	flagShuffle    = 0x1  // Byte shuffle enabled
+	flagMemcpy     = 0x2  // Data stored uncompressed (memcpy)
+	flagBitShuffle = 0x4  // Bit shuffle enabled
+	flagReserved   = 0x8  // Reserved by the Blosc spec, must be zero
+	flagDontSplit  = 0x10 // Blocks stored as one stream instead of one per byte position
+	flagCodecShift = 5    // Compressor format code occupies the top 3 bits
+
+	// Both shuffle bits set mark a Blosc2 extended header
+	flagExtended = flagShuffle | flagBitShuffle
+)
+
+// Bits of the Blosc2 flags byte of an extended header
+const (
+	blosc2FlagDict      = 0x1 // Chunk uses a codec dictionary
+	blosc2FlagBigEndian = 0x2 // Written on a big-endian host; the data is bytes either way
+	blosc2FlagSums      = 0x4 // Blocks carry CRC32C checksums (go-blosc extension)
+	blosc2FlagReserved  = 0x8 // Unassigned
+	blosc2SpecialShift  = 4   // Special value kind occupies bits 4-6
+	blosc2SpecialMask   = 0x7
+	blosc2FlagInstr     = 0x80 // Streams hold codec instrumentation, not data
+)
+
+// Compressor format codes stored in the top 3 flag bits, as defined by the
+// Blosc header spec. LZ4 and LZ4HC share a format.
+const (
+	formatBloscLZ = 0
+	formatLZ4     = 1
+	formatSnappy  = 2
+	formatZlib    = 3
+	formatZstd    = 4
+
+	// formatUDCodec marks a codec outside the built-in ones, whose ID the
+	// extended header holds, as c-blosc2's BLOSC_UDCODEC_FORMAT
+	formatUDCodec = 6
+)
+
+// codecFormatVersion is the compressor format version c-blosc writes to
+// VersionLZ. It is 1 for every codec.
+const codecFormatVersion = 1
+
+// Block layout constants shared with c-blosc.
+const (
+	maxSplits     = 16        // Largest typeSize whose blocks are split into streams
+	minBufferSize = 128       // Smallest stream worth splitting out
+	l1CacheSize   = 32 * 1024 // Base block size for automatic sizing
 )
 
+// unknownCodec is returned by Header.Codec for compressor codes with no Codec.
+const unknownCodec Codec = 0xFF
+
+// codecFormat returns the Blosc compressor format code for c.
+func codecFormat(c Codec) (uint8, bool) {
+	switch c {
+	case BloscLZ:
+		return formatBloscLZ, true
+	case LZ4, LZ4HC:
+		return formatLZ4, true
+	case Snappy:
+		return formatSnappy, true
+	case ZLIB:
+		return formatZlib, true
+	case ZSTD:
+		return formatZstd, true
+	default:
+		if c >= CodecRegisteredStart && c != unknownCodec {
+			return formatUDCodec, true
+		}
+		return 0, false
+	}
+}
+
+// formatCodec returns the codec that decodes compressor format code f.
+func formatCodec(f uint8) (Codec, bool) {
+	switch f {
+	case formatBloscLZ:
+		return BloscLZ, true
+	case formatLZ4:
+		return LZ4, true
+	case formatSnappy:
+		return Snappy, true
+	case formatZlib:
+		return ZLIB, true
+	case formatZstd:
+		return ZSTD, true
+	default:
+		return unknownCodec, false
+	}
+}
+
 // Header size constants
 const (
 	HeaderSize    = 16 // Blosc header size in bytes
 	MinHeaderSize = 16
+
+	// ExtendedHeaderSize is the size of a Blosc2 extended header, which adds
+	// the filter pipeline to the 16 bytes of a Blosc1 header.
+	ExtendedHeaderSize = 32
+
+	// MaxBufferSize is the most input one chunk holds, as c-blosc2's
+	// BLOSC2_MAX_BUFFERSIZE: sizes in chunk headers are 32 bits, and C
+	// readers take them as signed. Larger data must be split into chunks,
+	// as SChunk.AppendFrom does.
+	MaxBufferSize = math.MaxInt32 - ExtendedHeaderSize
 )
 
 // Predefined errors for common failure conditions.
@@ -138,30 +257,188 @@ var (
 	// ErrSizeMismatch indicates the decompressed size does not match the expected size.
 	ErrSizeMismatch = errors.New("blosc: decompressed size mismatch")
 
+	// ErrTypeSizeMismatch indicates a type size override that differs from
+	// the chunk header, or does not divide the data into whole elements.
+	ErrTypeSizeMismatch = errors.New("blosc: type size mismatch")
+
 	// ErrDataTooLarge indicates the input data exceeds the maximum supported size.
 	ErrDataTooLarge = errors.New("blosc: data too large")
 
 	// ErrCompressionFailed indicates the compression operation failed.
 	ErrCompressionFailed = errors.New("blosc: compression failed")
 
+	// ErrIncompressible is returned by a codec's Compress when its output
+	// would be no smaller than the input. Chunks then store the data raw,
+	// as uncompressed streams or a memcpy chunk, without calling Decompress.
+	ErrIncompressible = errors.New("blosc: data is incompressible")
+
 	// ErrDecompressionFailed indicates the decompression operation failed.
 	ErrDecompressionFailed = errors.New("blosc: decompression failed")
+
+	// ErrInvalidShuffle indicates the shuffle mode is not recognized.
+	ErrInvalidShuffle = errors.New("blosc: invalid shuffle mode")
+
+	// ErrBufferTooSmall indicates a caller-provided destination buffer is too small.
+	ErrBufferTooSmall = errors.New("blosc: destination buffer too small")
+
+	// ErrOutputTooLarge indicates a chunk decompresses to more bytes than the caller allows.
+	ErrOutputTooLarge = errors.New("blosc: decompressed size exceeds limit")
+
+	// ErrChunkIndex indicates a chunk index outside an SChunk.
+	ErrChunkIndex = errors.New("blosc: chunk index out of range")
+
+	// ErrInvalidFilter indicates a filter that is unknown or cannot be applied.
+	ErrInvalidFilter = errors.New("blosc: unsupported filter")
+
+	// ErrInvalidShape indicates an NDArray shape, region or dtype that does not fit.
+	ErrInvalidShape = errors.New("blosc: invalid array shape")
+
+	// ErrInvalidFrame indicates a frame that is truncated or malformed.
+	ErrInvalidFrame = errors.New("blosc: invalid frame")
+
+	// ErrInvalidKey indicates an encryption key that is missing or not a valid AES key.
+	ErrInvalidKey = errors.New("blosc: invalid encryption key")
+
+	// ErrAuthFailed indicates an encrypted chunk that fails authentication, because
+	// it was modified, moved to another index or frame, or the key is wrong.
+	ErrAuthFailed = errors.New("blosc: chunk authentication failed")
+
+	// ErrInvalidNpy indicates a NumPy .npy file that is malformed or holds an
+	// unsupported dtype.
+	ErrInvalidNpy = errors.New("blosc: invalid npy data")
+
+	// ErrClosed indicates work submitted to an AsyncCompressor after Close.
+	ErrClosed = errors.New("blosc: compressor closed")
+
+	// ErrChecksum indicates stored data whose checksum does not match, such
+	// as a truncated or bit-flipped frame header or trailer. It is reported
+	// along with the error for the kind of data, such as ErrInvalidFrame.
+	ErrChecksum = errors.New("blosc: checksum mismatch")
+
+	// ErrInvalidProfile indicates an unknown option preset name.
+	ErrInvalidProfile = errors.New("blosc: unknown profile")
+
+	// ErrInvalidOption indicates an out-of-range value given to NewConfig.
+	ErrInvalidOption = errors.New("blosc: invalid option")
+
+	// ErrRoundTrip indicates a chunk that decompresses without error to
+	// something other than the data it was compressed from.
+	ErrRoundTrip = errors.New("blosc: round trip mismatch")
+
+	// ErrUnsupportedFeature indicates a chunk header flag this package
+	// cannot decode chunks with, such as a reserved bit or a dictionary for
+	// a codec other than ZSTD. HeaderFlags lists the flags and which are
+	// supported.
+	ErrUnsupportedFeature = errors.New("blosc: unsupported feature")
+
+	// ErrMissingDict indicates a chunk compressed with a ZSTDDict that
+	// decompression was not given, through DecompressOptions.Dict or the
+	// frame or SChunk holding the chunk.
+	ErrMissingDict = errors.New("blosc: chunk needs a dictionary")
+)
+
+// Stage identifies the part of the Blosc pipeline where an operation failed.
+type Stage uint8
+
+const (
+	StageHeader Stage = iota // Chunk header or block offset table
+	StageFilter              // Shuffle or bit shuffle
+	StageCodec               // Compressing or decompressing a stream
+)
+
+// String returns the stage name.
+func (s Stage) String() string {
+	switch s {
+	case StageHeader:
+		return "header"
+	case StageFilter:
+		return "filter"
+	case StageCodec:
+		return "codec"
+	default:
+		return fmt.Sprintf("Stage(%d)", s)
+	}
+}
+
+// BloscError reports where in a chunk compression or decompression failed.
+// Decompression always returns errors of this type; use errors.As to get at
+// the location and errors.Is to match the wrapped sentinel error.
+type BloscError struct {
+	Stage  Stage // Pipeline stage that failed
+	Block  int   // Block index, or -1 if the failure is not tied to a block
+	Offset int   // Byte offset within the chunk, or -1 if unknown
+	Err    error // Underlying error, wrapping one of the Err* values
+}
+
+func (e *BloscError) Error() string {
+	msg := fmt.Sprintf("%v (%s stage", e.Err, e.Stage)
+	if e.Block >= 0 {
+		msg += fmt.Sprintf(", block %d", e.Block)
+	}
+	if e.Offset >= 0 {
+		msg += fmt.Sprintf(", offset %d", e.Offset)
+	}
+	return msg + ")"
+}
+
+func (e *BloscError) Unwrap() error {
+	return e.Err
+}
+
+// Header field offsets, used to locate header errors
+const (
+	offsetVersionLZ   = 1
+	offsetFlags       = 2
+	offsetTypeSize    = 3
+	offsetNBytesOrig  = 4
+	offsetBlockSize   = 8
+	offsetNBytesComp  = 12
+	offsetFilters     = 16
+	offsetUDCodec     = 22
+	offsetBlosc2Flags = 31
 )
 
+func headerError(offset int, err error) error {
+	return &BloscError{Stage: StageHeader, Block: -1, Offset: offset, Err: err}
+}
+
+func blockError(stage Stage, block, offset int, err error) error {
+	return &BloscError{Stage: stage, Block: block, Offset: offset, Err: err}
+}
+
 // Header represents the 16-byte Blosc frame header that prefixes all compressed data.
 // It contains metadata needed to decompress the data, including the codec used,
 // shuffle mode, and original/compressed sizes.
+//
+// Chunks follow the Blosc header spec: the compressor is encoded in the top 3
+// bits of Flags and VersionLZ holds the compressor format version. Chunks
+// written by go-blosc 1.0.x (or with Options.LegacyFormat) instead store the
+// Codec in VersionLZ; ParseHeader detects these and IsLegacy reports them.
+//
+// Blosc2 chunks may carry a 32-byte extended header, marked by both shuffle
+// bits in Flags, which replaces the shuffle flags with a filter pipeline.
 type Header struct {
 	Version    uint8  // Blosc format version (2 for current format)
-	VersionLZ  uint8  // Codec identifier (LZ4, ZSTD, etc.)
-	Flags      uint8  // Shuffle and compression flags
+	VersionLZ  uint8  // Compressor format version (Codec ID in legacy chunks)
+	Flags      uint8  // Shuffle, split and compressor flags
 	TypeSize   uint8  // Element size for shuffle (1, 2, 4, 8, etc.)
 	NBytesOrig uint32 // Original (uncompressed) data size
 	BlockSize  uint32 // Block size used for compression
 	NBytesComp uint32 // Total compressed size (including this header)
+
+	// Extended header fields, zero unless IsExtended
+	Filters     [MaxFilters]FilterStage // Filter pipeline, applied in order
+	UDCodec     uint8                   // Codec ID when the compressor format is 6, for codecs registered with RegisterCodec
+	CodecMeta   uint8                   // Codec metadata, as c-blosc2's compcode_meta
+	Blosc2Flags uint8                   // Dictionary, checksum and special value flags
+
+	legacy bool // Chunk uses the go-blosc 1.0.x layout
 }
 
-// ParseHeader parses a Blosc header from bytes
+// ParseHeader parses a Blosc header from bytes.
+//
+// When data holds more than the header, it is also used to tell spec chunks
+// from legacy go-blosc chunks where the header alone is ambiguous.
 func ParseHeader(data []byte) (*Header, error) {
 	if len(data) < HeaderSize {
 		return nil, ErrInvalidHeader
@@ -177,16 +454,68 @@ func ParseHeader(data []byte) (*Header, error) {
 		NBytesComp: binary.LittleEndian.Uint32(data[12:16]),
 	}
 
-	if h.Version != FormatVersion {
-		return nil, fmt.Errorf("%w: got %d, expected %d", ErrInvalidVersion, h.Version, FormatVersion)
+	if h.Version < FormatVersion || h.Version > Blosc2FormatVersion {
+		return nil, fmt.Errorf("%w: got %d, expected %d to %d", ErrInvalidVersion, h.Version, FormatVersion, Blosc2FormatVersion)
+	}
+	if h.IsExtended() {
+		if len(data) < ExtendedHeaderSize {
+			return nil, ErrInvalidHeader
+		}
+		for i := range h.Filters {
+			h.Filters[i] = FilterStage{Filter: Filter(data[16+i]), Meta: data[24+i]}
+		}
+		h.UDCodec, h.CodecMeta = data[22], data[23]
+		h.Blosc2Flags = data[31]
+	}
+	if h.Version == FormatVersion {
+		h.legacy = isLegacyLayout(h, data)
 	}
 
 	return h, nil
 }
 
+// isLegacyLayout reports whether a chunk uses the go-blosc 1.0.x layout: the
+// Codec ID in VersionLZ and the codec output directly after the header.
+func isLegacyLayout(h *Header, data []byte) bool {
+	if h.Flags&^(flagShuffle|flagMemcpy|flagBitShuffle) != 0 {
+		return false // Legacy writers never set the split or compressor bits
+	}
+	if h.VersionLZ != codecFormatVersion {
+		return true
+	}
+	// VersionLZ 1 is both the legacy LZ4 ID and the spec format version of
+	// BloscLZ. Memcpy chunks decode the same either way, so only the reported
+	// codec is at stake, and a well-formed memcpy chunk is read as a spec
+	// chunk whatever its block size: a single spec block is as likely as a
+	// legacy one. Otherwise a spec chunk starts with its block offset table.
+	if h.IsMemcpy() {
+		if uint64(h.NBytesComp) == uint64(h.NBytesOrig)+HeaderSize {
+			return false
+		}
+		return h.BlockSize == h.NBytesOrig
+	}
+	nblocks, ok := h.numBlocks()
+	if !ok {
+		return true
+	}
+	start := HeaderSize + 4*nblocks
+	if len(data) < start+4 {
+		return true
+	}
+	return binary.LittleEndian.Uint32(data[HeaderSize:]) != uint32(start)
+}
+
+// numBlocks returns the number of blocks a spec chunk is divided into.
+func (h *Header) numBlocks() (int, bool) {
+	if h.BlockSize == 0 {
+		return 0, false
+	}
+	return int((uint64(h.NBytesOrig) + uint64(h.BlockSize) - 1) / uint64(h.BlockSize)), true
+}
+
 // Bytes serializes the header to bytes
 func (h *Header) Bytes() []byte {
-	buf := make([]byte, HeaderSize)
+	buf := make([]byte, h.Size())
 	buf[0] = h.Version
 	buf[1] = h.VersionLZ
 	buf[2] = h.Flags
@@ -194,24 +523,100 @@ func (h *Header) Bytes() []byte {
 	binary.LittleEndian.PutUint32(buf[4:8], h.NBytesOrig)
 	binary.LittleEndian.PutUint32(buf[8:12], h.BlockSize)
 	binary.LittleEndian.PutUint32(buf[12:16], h.NBytesComp)
+	if h.IsExtended() {
+		for i, f := range h.Filters {
+			buf[16+i] = byte(f.Filter)
+			buf[24+i] = f.Meta
+		}
+		buf[22], buf[23] = h.UDCodec, h.CodecMeta
+		buf[31] = h.Blosc2Flags
+	}
 	return buf
 }
 
+// IsExtended returns true if the chunk has a Blosc2 extended header
+func (h *Header) IsExtended() bool {
+	return h.Version > FormatVersion && h.Flags&flagExtended == flagExtended
+}
+
+// Size returns the length of the header in bytes: HeaderSize, or
+// ExtendedHeaderSize for extended headers
+func (h *Header) Size() int {
+	if h.IsExtended() {
+		return ExtendedHeaderSize
+	}
+	return HeaderSize
+}
+
 // HasShuffle returns true if byte shuffle is enabled
 func (h *Header) HasShuffle() bool {
+	if h.IsExtended() {
+		return h.hasFilter(FilterShuffle)
+	}
 	return h.Flags&flagShuffle != 0
 }
 
 // HasBitShuffle returns true if bit shuffle is enabled
 func (h *Header) HasBitShuffle() bool {
+	if h.IsExtended() {
+		return h.hasFilter(FilterBitShuffle)
+	}
 	return h.Flags&flagBitShuffle != 0
 }
 
+func (h *Header) hasFilter(f Filter) bool {
+	for _, s := range h.Filters {
+		if s.Filter == f {
+			return true
+		}
+	}
+	return false
+}
+
+// HasDict reports whether the chunk was compressed with a dictionary, as
+// ZSTDParams.Dict does, which decompression needs.
+func (h *Header) HasDict() bool {
+	return h.IsExtended() && h.Blosc2Flags&blosc2FlagDict != 0
+}
+
+// HasBlockChecksums reports whether the chunk ends with a CRC32C checksum of
+// each block, as Options.BlockChecksums writes.
+func (h *Header) HasBlockChecksums() bool {
+	return h.IsExtended() && h.Blosc2Flags&blosc2FlagSums != 0
+}
+
 // IsMemcpy returns true if data is stored uncompressed
 func (h *Header) IsMemcpy() bool {
 	return h.Flags&flagMemcpy != 0
 }
 
+// IsLegacy returns true if the chunk uses the go-blosc 1.0.x layout. Memcpy
+// chunks, which decode the same in both layouts, are reported as spec chunks.
+func (h *Header) IsLegacy() bool {
+	return h.legacy
+}
+
+// Codec returns the codec the chunk was compressed with. LZ4HC chunks report
+// LZ4, since the two share a format, and chunks of codecs registered with
+// RegisterCodec report the ID in UDCodec.
+func (h *Header) Codec() Codec {
+	if h.legacy {
+		return Codec(h.VersionLZ)
+	}
+	format := h.Flags >> flagCodecShift
+	if format == formatUDCodec && h.IsExtended() {
+		return Codec(h.UDCodec)
+	}
+	c, _ := formatCodec(format)
+	return c
+}
+
+// splitStreams returns true if full blocks are stored as one stream per byte
+// position of an element
+func (h *Header) splitStreams() bool {
+	return h.Flags&flagDontSplit == 0
+}
+
 // ShuffleMode returns the shuffle mode from flags
 func (h *Header) ShuffleMode() Shuffle {
 	if h.HasBitShuffle() {
@@ -226,11 +631,248 @@ func (h *Header) ShuffleMode() Shuffle {
 // Options configures Blosc compression behavior.
 type Options struct {
 	Codec      Codec   // Compression codec (LZ4, ZSTD, ZLIB, Snappy)
-	Level      int     // Compression level (1-9, higher = better compression)
-	Shuffle    Shuffle // Shuffle mode (NoShuffle, Shuffle1, BitShuffle)
-	TypeSize   int     // Element size in bytes for shuffle (1, 2, 4, 8)
+	Level      int     // Compression level (1-9, higher = better compression; 0 = store without a codec)
+	Shuffle    Shuffle // Shuffle mode (NoShuffle, Shuffle1, BitShuffle, AutoShuffle)
+	TypeSize   int     // Element size in bytes for shuffle (1 to 255; larger sizes compress as 1), or TypeSizeAuto
 	BlockSize  int     // Block size in bytes (0 = automatic)
-	NumThreads int     // Reserved for future use (not used in pure Go implementation)
+	NumThreads int     // Codec goroutines of an AsyncCompressor (0 = MaxWorkers); one-shot calls use one
+
+	// LegacyFormat writes the single-block layout of go-blosc 1.0.x, with the
+	// Codec ID in VersionLZ. Use it only when the output must be readable by
+	// those releases; c-blosc cannot read it.
+	LegacyFormat bool
+
+	// CodecParams tunes individual codecs beyond Level
+	CodecParams CodecParams
+
+	// Filters sets a Blosc2 filter pipeline, applied to each block in slot
+	// order. When any slot is set, chunks are written with an extended
+	// header that c-blosc2 can read, and Shuffle is ignored: add
+	// FilterShuffle or FilterBitShuffle to the pipeline instead.
+	Filters [MaxFilters]FilterStage
+
+	// ErrorBound is the precision FilterFloatQuantize may give up in each
+	// value, and must be set when Filters holds that filter.
+	ErrorBound ErrorBound
+
+	// SpecialValues stores input whose elements are all equal, such as all
+	// zeros or all NaN, as a Blosc2 special-value chunk of a header and at
+	// most one element. Such chunks need a Blosc2 reader. It is ignored with
+	// LegacyFormat.
+	SpecialValues bool
+
+	// Hooks, if set, holds the callbacks compression runs. It is a
+	// pointer so that Options stays comparable with ==; options copied
+	// from one another share their Hooks.
+	Hooks *Hooks
+
+	// SkipIncompressible samples a few KB of each stream before compressing
+	// it and stores the stream as it is when the sample looks random, with
+	// near 8 bits of entropy per byte and no repeats, so already compressed
+	// media or encrypted data skip the codec instead of paying for it
+	// before falling back to a copy. Streams of data that would compress a
+	// little may then be stored uncompressed.
+	SkipIncompressible bool
+
+	// BlockChecksums stores a CRC32C checksum of the compressed streams of
+	// each block at the end of the chunk, so that decompression verifies
+	// every block it decodes and GetItems only the blocks it reads. Such
+	// chunks have an extended header and are never stored as memcpy
+	// chunks; c-blosc2 reads them and ignores the checksums. Special-value
+	// chunks carry none. It needs the Blosc2 format, so not LegacyFormat.
+	BlockChecksums bool
+
+	// Strict makes compression fail with the error Validate reports instead
+	// of adjusting options it would otherwise clamp or round, such as a
+	// Level of 12 or a TypeSize of 0.
+	Strict bool
+
+	// Deterministic guarantees that the same input and options compress to
+	// the same bytes in every run, whatever GOMAXPROCS, NumThreads or the
+	// build tags, as content-addressed stores that hash chunks need. Block
+	// partitioning and the codecs already depend on nothing else; this
+	// also leaves out the settings that can change between processes:
+	// chunks are built by the pure Go codecs rather than libblosc, and ZSTD
+	// ignores ConfigureZSTD. Output may still differ between go-blosc
+	// releases, dependency versions and platforms, whose codec assembly
+	// can find other matches.
+	Deterministic bool
+}
+
+// Hooks are the callbacks Options.Hooks sets for compression.
+type Hooks struct {
+	// Prefilter, if set, is called on a copy of each block before the
+	// filter pipeline and codec run, with the offset of the block in the
+	// input, and may rewrite the block in place, as blosc2_set_prefilter
+	// callbacks do. It can convert or scale data while it is in cache
+	// instead of in a separate pass. It may be called more than once for a
+	// block and must give the same result each time. The input itself is
+	// never modified. SpecialValues is ignored when a Prefilter is set.
+	Prefilter func(block []byte, offset int)
+
+	// Logger, if set, receives a Debug record for each chunk compressed,
+	// with the decisions taken for it: the layout and block size, whether
+	// the chunk fell back to a plain copy and why, the SIMD kernels
+	// shuffling used and the time spent in the codec. Nothing is gathered
+	// or timed unless the logger is enabled for slog.LevelDebug.
+	Logger *slog.Logger
+
+	// Progress, if set, is called after each block of a chunk is
+	// compressed, with the input bytes done so far and the size of the
+	// input, so that long compressions can show a progress bar. Calls for
+	// a chunk come in order, the last with done equal to total; chunks not
+	// compressed block by block, such as special-value chunks, report once
+	// when done. SChunk.AppendFrom counts across all the chunks it appends.
+	// An AsyncCompressor calls it from its goroutines, for several chunks
+	// at once.
+	Progress func(done, total int64)
+}
+
+// prefilter returns the Prefilter of o.Hooks, or nil
+func (o *Options) prefilter() func(block []byte, offset int) {
+	if o.Hooks == nil {
+		return nil
+	}
+	return o.Hooks.Prefilter
+}
+
+// progress returns the Progress of o.Hooks, or nil
+func (o *Options) progress() func(done, total int64) {
+	if o.Hooks == nil {
+		return nil
+	}
+	return o.Hooks.Progress
+}
+
+// ownHooks replaces o.Hooks with a copy, or with new Hooks if nil, and
+// returns it, so that a callback can be set without changing the Hooks of
+// the options o was copied from
+func (o *Options) ownHooks() *Hooks {
+	h := new(Hooks)
+	if o.Hooks != nil {
+		*h = *o.Hooks
+	}
+	o.Hooks = h
+	return h
+}
+
+// DecompressOptions configures DecompressWithOptions.
+type DecompressOptions struct {
+	// Postfilter, if set, is called on each block after the codec and
+	// filter pipeline have decoded it, with the offset of the block in the
+	// output, and may rewrite the block in place, as c-blosc2 postfilters
+	// do. Use it to undo a Prefilter.
+	Postfilter func(block []byte, offset int)
+
+	// Progress, if set, is called after each block is decoded, with the
+	// output bytes done so far and the size of the output. Chunks not
+	// decoded block by block report once when done.
+	Progress func(done, total int64)
+
+	// TypeSize, if positive, overrides the element size in the chunk header
+	// that shuffled data is unshuffled with. It must match the header, or
+	// with ForceTypeSize divide the data into whole elements, or decoding
+	// fails with ErrTypeSizeMismatch.
+	TypeSize      int
+	ForceTypeSize bool
+
+	// Dict is the dictionary of chunks compressed with ZSTDParams.Dict.
+	// Frames and SChunks keep their own, so their chunks need none.
+	Dict *ZSTDDict
+
+	// SkippableFrames, if set, is called with the content of each zstd
+	// skippable frame in the streams of a ZSTD chunk, which some producers
+	// embed metadata in and decompression otherwise skips. It is given the
+	// block holding the frame and the low 4 bits of its magic number, which
+	// tell kinds of frame apart. content is part of the chunk data.
+	SkippableFrames func(block, id int, content []byte)
+}
+
+// CodecParams holds codec-specific tuning knobs. Zero values keep the
+// behavior derived from Options.Level, and parameters for codecs other than
+// Options.Codec are ignored.
+type CodecParams struct {
+	LZ4  LZ4Params
+	ZSTD ZSTDParams
+	ZLIB ZLIBParams
+}
+
+// LZ4Params tunes the LZ4 codec (not LZ4HC).
+type LZ4Params struct {
+	// Acceleration trades ratio for speed as in LZ4_compress_fast: values
+	// above 1 skip ahead faster through data that does not match, and 1
+	// selects the default compressor. 0 derives it from Options.Level: 8, 4
+	// and 2 for levels 1 to 3, and 1 for levels 4 to 9.
+	Acceleration int
+}
+
+// ZSTDParams tunes the ZSTD codec. Long-distance matching is not available
+// in the pure Go zstd encoder.
+type ZSTDParams struct {
+	// WindowLog sets the match window to 1<<WindowLog bytes, clamped to the
+	// range the encoder supports (10 to 29 on 64-bit platforms). 0 keeps the
+	// window of ZSTDConfig.WindowLog, or else the one chosen by the level. Windows larger than the block size have no
+	// effect, since each block is compressed on its own.
+	WindowLog int
+
+	// Level, if nonzero, sets the zstd level on zstd's own scale in place of
+	// Options.Level, which is clamped to 1-9. The pure Go encoder has four
+	// strategies, so the range maps onto them as follows:
+	//
+	//	-131072 to -1  fastest, without entropy coding (zstd's fast levels)
+	//	1 to 2         fastest (SpeedFastest)
+	//	3 to 5         default (SpeedDefault)
+	//	6 to 9         better (SpeedBetterCompression)
+	//	10 to 22       best (SpeedBestCompression)
+	//
+	// Levels above 22 count as 22. Options.Level 0 still stores without a
+	// codec.
+	Level int
+
+	// Dict, if set, primes each stream with a dictionary, which pays off
+	// for small blocks of data like what it was built from. Chunks record
+	// that they need one in the dict flag of an extended header, and
+	// decompress only when given it, through DecompressOptions.Dict or the
+	// frame an SChunk writes it to.
+	Dict *ZSTDDict
+}
+
+// ZLIBParams tunes the ZLIB codec.
+type ZLIBParams struct {
+	// HuffmanOnly disables match searching and only entropy codes the data,
+	// like zlib's Z_HUFFMAN_ONLY strategy. It is very fast and suits shuffled
+	// data whose redundancy is mostly in the byte distribution.
+	HuffmanOnly bool
+}
+
+// ZSTDConfig sets up the encoders and decoder that all ZSTD compression and
+// decompression share. Zero fields keep the zstd library's defaults.
+type ZSTDConfig struct {
+	// EncoderConcurrency caps the calls each shared encoder runs at once;
+	// further calls wait. Each running call holds its own match-finder
+	// state, so servers compressing on many goroutines can lower it to
+	// bound memory. 0 allows GOMAXPROCS calls.
+	EncoderConcurrency int
+
+	// WindowLog sets the match window of the shared encoders to
+	// 1<<WindowLog bytes, clamped as ZSTDParams.WindowLog is, which
+	// overrides it. 0 keeps the window chosen by the level.
+	WindowLog int
+
+	// DecoderConcurrency caps the calls the shared decoder runs at once, as
+	// EncoderConcurrency does for encoders. 0 allows 4 or GOMAXPROCS calls,
+	// whichever is fewer.
+	DecoderConcurrency int
+
+	// LowMemory trades speed for lower memory use in the decoder and the
+	// encoders.
+	LowMemory bool
+
+	// MaxDecodedSize fails the decoding of streams that would decode to
+	// more bytes, to bound the memory hostile input can claim. 0 means 64
+	// GiB. Streams never decode past the size their chunk header claims in
+	// any case.
+	MaxDecodedSize uint64
 }
 
 // DefaultOptions returns default compression options
@@ -249,9 +891,9 @@ func DefaultOptions() Options {
 // Parameters:
 //   - data: Input data to compress
 //   - codec: Compression codec (LZ4, ZSTD, ZLIB, Snappy)
-//   - level: Compression level (1-9)
+//   - level: Compression level (1-9, or 0 to store without a codec)
 //   - shuffle: Shuffle mode (NoShuffle, Shuffle1, BitShuffle)
-//   - typeSize: Element size for shuffle preprocessing (1, 2, 4, 8 bytes)
+//   - typeSize: Element size for shuffle preprocessing (1 to 255 bytes)
 //
 // Returns compressed data with Blosc header, or error
 func Compress(data []byte, codec Codec, level int, shuffle Shuffle, typeSize int) ([]byte, error) {
@@ -266,169 +908,1371 @@ func Compress(data []byte, codec Codec, level int, shuffle Shuffle, typeSize int
 
 // CompressWithOptions compresses data using specified options.
 func CompressWithOptions(data []byte, opts Options) ([]byte, error) {
-	if len(data) == 0 {
-		return nil, ErrInvalidData
-	}
+	return compressWithEnv(data, opts, nil)
+}
 
-	// Validate options
-	if opts.TypeSize <= 0 {
-		opts.TypeSize = 1
+// MaxCompressedSize returns the largest chunk compressing n bytes with opts
+// can produce, for sizing destination buffers ahead of time. Block offset
+// tables and codec expansion never count against it: a chunk that would
+// grow past its input is stored uncompressed instead, so the bound is the
+// header size plus n. The exception is Level 0 with a shuffle, which
+// AutoShuffle may pick, or filters, whose blocks of raw streams add 4 bytes
+// per block and per stream, and BlockChecksums, whose chunks are never
+// stored uncompressed and add another 4 bytes per block for the checksum.
+func MaxCompressedSize(n int, opts Options) int {
+	n = max(n, 0)
+	hsize := HeaderSize
+	if opts.Filters != ([MaxFilters]FilterStage{}) || opts.BlockChecksums {
+		hsize = ExtendedHeaderSize
 	}
-	if opts.Level < 1 {
-		opts.Level = 1
+	opts, _ = normalizeOptions(opts)
+	if opts.TypeSize == TypeSizeAuto {
+		bound := 0
+		for _, typeSize := range autoTypeSizes {
+			opts.TypeSize = typeSize
+			bound = max(bound, MaxCompressedSize(n, opts))
+		}
+		return bound
 	}
-	if opts.Level > 9 {
-		opts.Level = 9
+	if opts.Shuffle == AutoShuffle {
+		bound := 0
+		for _, shuffle := range []Shuffle{NoShuffle, Shuffle1, BitShuffle} {
+			opts.Shuffle = shuffle
+			bound = max(bound, MaxCompressedSize(n, opts))
+		}
+		return bound
 	}
-
-	// Call backend implementation (pure Go or CGO depending on build tags)
-	return compressBackend(data, opts)
-}
-
-// Decompress decompresses Blosc-compressed data
-//
-// The typeSize parameter is optional - if 0, it uses the typeSize from the header
-func Decompress(data []byte) ([]byte, error) {
-	return DecompressWithSize(data, 0)
+	filtered := opts.Filters != ([MaxFilters]FilterStage{}) || len(shufflePipeline(opts.Shuffle, opts.TypeSize)) > 0
+	sums := opts.BlockChecksums && !opts.LegacyFormat
+	if !sums && (opts.Level != 0 || !filtered || opts.LegacyFormat || n < minBufferSize) || n == 0 {
+		return hsize + n
+	}
+	blockSize := computeBlockSize(opts, n)
+	nblocks := (n + blockSize - 1) / blockSize
+	nstreams := nblocks
+	if splitBlock(opts.TypeSize, blockSize) {
+		nstreams *= opts.TypeSize
+	}
+	if sums {
+		nstreams += nblocks
+	}
+	return hsize + 4*nblocks + 4*nstreams + n
 }
 
-// DecompressWithSize decompresses with explicit type size override.
-func DecompressWithSize(data []byte, typeSize int) ([]byte, error) {
-	if len(data) < HeaderSize {
-		return nil, ErrInvalidHeader
+// compressWithEnv implements CompressWithOptions for filters that need env
+func compressWithEnv(data []byte, opts Options, env *filterEnv) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, ErrInvalidData
+	}
+	if err := checkBufferSize(int64(len(data))); err != nil {
+		return nil, err
+	}
+	opts, err := normalizeOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.TypeSize == TypeSizeAuto {
+		opts.TypeSize = pickTypeSize(data, opts)
+	}
+	if opts.SpecialValues && !opts.LegacyFormat && opts.prefilter() == nil {
+		if chunk, ok := detectSpecial(data, opts); ok {
+			logSpecial(&opts, len(data), chunk)
+			reportProgress(opts.progress(), len(data), len(data))
+			return chunk, nil
+		}
+	}
+	if opts.Shuffle == AutoShuffle {
+		opts.Shuffle = pickShuffle(data, opts)
 	}
 
 	// Call backend implementation (pure Go or CGO depending on build tags)
-	return decompressBackend(data, typeSize)
+	return compressBackend(data, opts, env)
 }
 
-// GetInfo returns information about compressed data without decompressing
-func GetInfo(data []byte) (*Header, error) {
-	return ParseHeader(data)
+// reportProgress calls progress, if set, with done of total bytes
+func reportProgress(progress func(done, total int64), done, total int) {
+	if progress != nil {
+		progress(int64(done), int64(total))
+	}
 }
 
-// GetDecompressedSize returns the original size of compressed data
-func GetDecompressedSize(data []byte) (int, error) {
-	header, err := ParseHeader(data)
-	if err != nil {
-		return 0, err
+// checkBufferSize checks that n bytes fit in one chunk
+func checkBufferSize(n int64) error {
+	if n > MaxBufferSize {
+		return fmt.Errorf("%w: %d bytes, a chunk holds at most %d", ErrDataTooLarge, n, MaxBufferSize)
 	}
-	return int(header.NBytesOrig), nil
+	return nil
 }
 
-// compressBackend implements compression using pure Go codecs
-func compressBackend(data []byte, opts Options) ([]byte, error) {
-	// Get codec compressor
-	compressor, ok := codecs[opts.Codec]
-	if !ok {
-		return nil, fmt.Errorf("%w: %s", ErrInvalidCodec, opts.Codec)
+// normalizeOptions clamps options to the ranges compression accepts. With
+// Strict set, it also reports the error Validate gives for them.
+func normalizeOptions(opts Options) (Options, error) {
+	var err error
+	if opts.Strict {
+		err = opts.Validate()
 	}
-
-	// Apply shuffle preprocessing
-	shuffled := data
-	if opts.Shuffle == Shuffle1 && opts.TypeSize > 1 {
-		shuffled = shuffleBytes(data, opts.TypeSize)
-	} else if opts.Shuffle == BitShuffle && opts.TypeSize > 1 {
-		shuffled = bitShuffle(data, opts.TypeSize)
+	if opts.TypeSize != TypeSizeAuto && (opts.TypeSize <= 0 || opts.TypeSize > math.MaxUint8) {
+		// The header holds the type size in a byte; as c-blosc does, wider
+		// elements are compressed as bytes
+		opts.TypeSize = 1
 	}
-
-	// Compress the data
-	compressed, err := compressor.Compress(shuffled, opts.Level)
-	if err != nil {
-		return nil, fmt.Errorf("%w: %v", ErrCompressionFailed, err)
+	if opts.Level < 0 {
+		opts.Level = 1
 	}
-
-	// Check if compression was beneficial
-	useMemcpy := len(compressed) >= len(data)
-	if useMemcpy {
-		compressed = data // Store uncompressed
+	if opts.Level > 9 {
+		opts.Level = 9
 	}
+	return opts, err
+}
 
-	// Build header
-	flags := uint8(0)
-	if opts.Shuffle == Shuffle1 {
-		flags |= flagShuffle
-	} else if opts.Shuffle == BitShuffle {
-		flags |= flagBitShuffle
-	}
-	if useMemcpy {
-		flags |= flagMemcpy
+// CompressFrom compresses the next n bytes of r into a chunk, reading and
+// compressing one block at a time, so that only the output is held in
+// memory. The chunk is the same CompressWithOptions would produce. If r ends
+// before n bytes, CompressFrom fails with io.ErrUnexpectedEOF.
+func CompressFrom(r io.Reader, n int64, opts Options) ([]byte, error) {
+	if n <= 0 {
+		return nil, ErrInvalidData
 	}
-
-	header := Header{
-		Version:    FormatVersion,
-		VersionLZ:  uint8(opts.Codec),
-		Flags:      flags,
-		TypeSize:   uint8(opts.TypeSize),
-		NBytesOrig: uint32(len(data)),
-		BlockSize:  uint32(len(data)), // Single block for simplicity
-		NBytesComp: uint32(HeaderSize + len(compressed)),
+	if err := checkBufferSize(n); err != nil {
+		return nil, err
 	}
-
-	// Build output
-	result := make([]byte, HeaderSize+len(compressed))
-	copy(result[:HeaderSize], header.Bytes())
-	copy(result[HeaderSize:], compressed)
-
-	return result, nil
-}
-
-// decompressBackend implements decompression using pure Go codecs
-func decompressBackend(data []byte, typeSize int) ([]byte, error) {
-	// Parse header
-	header, err := ParseHeader(data)
+	opts, err := normalizeOptions(opts)
 	if err != nil {
 		return nil, err
 	}
-
-	// Validate sizes
-	if int(header.NBytesComp) > len(data) {
-		return nil, ErrInvalidData
+	if opts.Shuffle == AutoShuffle || opts.TypeSize == TypeSizeAuto {
+		// Pick from the same sample CompressWithOptions would
+		sample := make([]byte, min(int(n), autoSampleSize))
+		if _, err := readFull(r, sample); err != nil {
+			return nil, err
+		}
+		if opts.TypeSize == TypeSizeAuto {
+			opts.TypeSize = pickTypeSize(sample, opts)
+		}
+		if opts.Shuffle == AutoShuffle {
+			opts.Shuffle = pickShuffle(sample, opts)
+		}
+		r = io.MultiReader(bytes.NewReader(sample), r)
 	}
-	if header.NBytesComp < HeaderSize {
-		return nil, ErrInvalidData
+	b, err := newChunkBuilder(int(n), opts, nil)
+	if err != nil {
+		return nil, err
+	}
+	if b.legacy || b.memcpy() || opts.SpecialValues && int(b.header.BlockSize) < opts.TypeSize {
+		data := make([]byte, n)
+		if _, err := readFull(r, data); err != nil {
+			return nil, err
+		}
+		return compressWithEnv(data, opts, nil)
 	}
 
-	// Get compressed payload
-	payload := data[HeaderSize:header.NBytesComp]
-
-	var decompressed []byte
+	blockSize := int(b.header.BlockSize)
+	buf := make([]byte, blockSize)
 
-	// Handle memcpy (uncompressed) data
-	if header.IsMemcpy() {
-		decompressed = make([]byte, len(payload))
-		copy(decompressed, payload)
-	} else {
-		// Get codec decompressor
-		codec := Codec(header.VersionLZ)
-		decompressor, ok := codecs[codec]
-		if !ok {
-			return nil, fmt.Errorf("%w: %s", ErrInvalidCodec, codec)
+	// With SpecialValues, track whether the input repeats its first element
+	var pattern []byte
+	repeated := opts.SpecialValues && opts.prefilter() == nil && opts.TypeSize <= math.MaxUint8 && n%int64(opts.TypeSize) == 0
+	for i := 0; i < b.nblocks; i++ {
+		block := buf[:min(blockSize, int(n)-i*blockSize)]
+		if _, err := readFull(r, block); err != nil {
+			return nil, err
+		}
+		if repeated {
+			if pattern == nil {
+				pattern = make([]byte, blockSize+opts.TypeSize)
+				fillPattern(pattern, block[:opts.TypeSize])
+			}
+			phase := i * blockSize % opts.TypeSize
+			repeated = bytes.Equal(block, pattern[phase:phase+len(block)])
+		}
+		if err := b.add(block); err != nil {
+			return nil, err
+		}
+		if !b.memcpy() {
+			continue
 		}
 
-		// Decompress
-		decompressed, err = decompressor.Decompress(payload, int(header.NBytesOrig))
+		// Compression does not pay: recover the input read so far and copy
+		// the rest as it is
+		prefix, err := b.decodeAdded()
 		if err != nil {
-			return nil, fmt.Errorf("%w: %v", ErrDecompressionFailed, err)
+			return nil, err
+		}
+		hsize := b.header.Size()
+		chunk := make([]byte, hsize, hsize+int(n))
+		chunk = append(chunk, prefix...)
+		chunk = append(chunk, block...)
+		if _, err := readFull(r, chunk[len(chunk):cap(chunk)]); err != nil {
+			return nil, err
 		}
+		chunk = chunk[:cap(chunk)]
+		if opts.prefilter() != nil {
+			// The blocks added so far were decoded already filtered
+			done := i * blockSize
+			eachBlock(chunk[hsize+done:], done, blockSize, opts.prefilter())
+		}
+		reportProgress(opts.progress(), int(n), int(n))
+		if repeated {
+			if special, ok := detectSpecial(chunk[hsize:], opts); ok {
+				logSpecial(&opts, int(n), special)
+				return special, nil
+			}
+		}
+		b.header.NBytesComp = uint32(len(chunk))
+		copy(chunk, b.header.Bytes())
+		b.logChunk(int(n), chunk)
+		return chunk, nil
 	}
-
-	// Use header typeSize if not overridden
+	if repeated {
+		if special, ok := specialChunkFor(int(n), pattern[:opts.TypeSize]); ok {
+			logSpecial(&opts, int(n), special)
+			return special, nil
+		}
+	}
+	chunk := b.finish()
+	b.logChunk(int(n), chunk)
+	return chunk, nil
+}
+
+// CompressVec compresses the buffers in bufs as one input, giving the chunk
+// their concatenation would compress to, without concatenating them first:
+// blocks are gathered from the buffers one at a time, as CompressFrom reads
+// them, so data arriving as row groups or network segments needs no copy of
+// the whole. Empty buffers are skipped.
+func CompressVec(bufs [][]byte, opts Options) ([]byte, error) {
+	var n int64
+	readers := make([]io.Reader, 0, len(bufs))
+	for _, buf := range bufs {
+		if len(buf) > 0 {
+			n += int64(len(buf))
+			readers = append(readers, bytes.NewReader(buf))
+		}
+	}
+	if len(readers) == 0 {
+		return nil, ErrInvalidData
+	}
+	return CompressFrom(io.MultiReader(readers...), n, opts)
+}
+
+// readFull is io.ReadFull, reporting a short read as io.ErrUnexpectedEOF
+// even when nothing was read
+func readFull(r io.Reader, p []byte) (int, error) {
+	n, err := io.ReadFull(r, p)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// Decompress decompresses Blosc-compressed data
+//
+// The typeSize parameter is optional - if 0, it uses the typeSize from the header
+func Decompress(data []byte) ([]byte, error) {
+	return DecompressWithSize(data, 0)
+}
+
+// DecompressWithSize decompresses with explicit type size override. An
+// override other than 0 must match the TypeSize in the chunk header, as
+// unshuffling with another element size garbles the output, or it fails with
+// ErrTypeSizeMismatch. DecompressOptions.ForceTypeSize lifts the check.
+func DecompressWithSize(data []byte, typeSize int) ([]byte, error) {
+	if len(data) < HeaderSize {
+		return nil, headerError(0, ErrInvalidHeader)
+	}
+	if err := checkTypeSize(data, typeSize, false); err != nil {
+		return nil, err
+	}
+
+	// Call backend implementation (pure Go or CGO depending on build tags)
+	return decompressBackend(nil, data, typeSize, -1, nil)
+}
+
+// DecompressLimited decompresses data like Decompress, but fails with
+// ErrOutputTooLarge if the chunk would decompress to more than maxBytes.
+//
+// A 16-byte header can claim up to 4 GB of output, and Decompress allocates
+// whatever the header claims. Use DecompressLimited for chunks from untrusted
+// sources: the limit is checked before any output is allocated.
+func DecompressLimited(data []byte, maxBytes int) ([]byte, error) {
+	if len(data) < HeaderSize {
+		return nil, headerError(0, ErrInvalidHeader)
+	}
+	return decompressBackend(nil, data, 0, max(maxBytes, 0), nil)
+}
+
+// DecompressAppend decompresses data and appends the result to dst, growing
+// it only if its capacity is too small, and returns the extended slice. Like
+// zstd's DecodeAll, it lets callers decode many chunks into one buffer
+// without intermediate allocations. On error, dst is returned unchanged.
+func DecompressAppend(dst, data []byte) ([]byte, error) {
+	if len(data) < HeaderSize {
+		return dst, headerError(0, ErrInvalidHeader)
+	}
+	return decompressBackend(dst, data, 0, -1, nil)
+}
+
+// DecompressWithOptions decompresses data like Decompress, running
+// opts.Postfilter on each block as it is decoded, reporting to opts.Progress,
+// unshuffling with opts.TypeSize and decoding with opts.Dict.
+func DecompressWithOptions(data []byte, opts DecompressOptions) ([]byte, error) {
+	if len(data) < HeaderSize {
+		return nil, headerError(0, ErrInvalidHeader)
+	}
+	if err := checkTypeSize(data, opts.TypeSize, opts.ForceTypeSize); err != nil {
+		return nil, err
+	}
+	var env *filterEnv
+	if opts.Postfilter != nil || opts.Progress != nil || opts.Dict != nil || opts.SkippableFrames != nil {
+		env = &filterEnv{postfilter: opts.Postfilter, progress: opts.Progress, dict: opts.Dict, skippable: opts.SkippableFrames}
+	}
+	return decompressBackend(nil, data, opts.TypeSize, -1, env)
+}
+
+// checkTypeSize rejects a type size override that differs from the header of
+// chunk data, unless force is set, or that does not divide its data
+func checkTypeSize(data []byte, typeSize int, force bool) error {
 	if typeSize <= 0 {
-		typeSize = int(header.TypeSize)
+		return nil
+	}
+	header, err := ParseHeader(data)
+	if err != nil {
+		return headerError(0, err)
+	}
+	if typeSize == int(header.TypeSize) {
+		return nil
+	}
+	if !force {
+		return headerError(offsetTypeSize, fmt.Errorf("%w: override %d, header has %d", ErrTypeSizeMismatch, typeSize, header.TypeSize))
+	}
+	if uint64(header.NBytesOrig)%uint64(typeSize) != 0 {
+		return headerError(offsetTypeSize, fmt.Errorf("%w: override %d does not divide %d bytes", ErrTypeSizeMismatch, typeSize, header.NBytesOrig))
+	}
+	return nil
+}
+
+// DecompressTo decompresses data to w one block at a time, so the output is
+// never held in memory as a whole, and returns the number of bytes written.
+// Errors from w are returned as they are; if one occurs, w has received only
+// part of the output.
+func DecompressTo(w io.Writer, data []byte) (int64, error) {
+	if len(data) < HeaderSize {
+		return 0, headerError(0, ErrInvalidHeader)
+	}
+	header, typeSize, err := checkChunk(data, 0, -1)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int64
+	write := func(p []byte) error {
+		m, err := w.Write(p)
+		n += int64(m)
+		return err
+	}
+	switch {
+	case header.IsMemcpy():
+		payload := data[header.Size():header.NBytesComp]
+		if len(payload) != int(header.NBytesOrig) {
+			return 0, blockError(StageCodec, -1, header.Size(), fmt.Errorf("%w: got %d, expected %d", ErrSizeMismatch, len(payload), header.NBytesOrig))
+		}
+		return n, write(payload)
+	case header.legacy:
+		// Legacy chunks are a single block
+		decompressed := make([]byte, header.NBytesOrig)
+		if err := decompressLegacyInto(decompressed, header, data[HeaderSize:header.NBytesComp], typeSize); err != nil {
+			return 0, err
+		}
+		return n, write(decompressed)
+	default:
+		return n, decodeBlocks(header, data[:header.NBytesComp], typeSize, nil, nil, write)
+	}
+}
+
+// Info describes a chunk for display and planning, as GetInfo reports it.
+// Its Header holds the raw fields, as ParseHeader returns them.
+type Info struct {
+	Header
+
+	CodecName   string  // Codec name, as ParseCodec accepts it
+	ShuffleName string  // Shuffle mode: noshuffle, shuffle or bitshuffle
+	Ratio       float64 // NBytesOrig / NBytesComp
+	Blocks      int     // Blocks the data is divided into; 1 in go-blosc 1.0.x chunks
+	Checksums   bool    // Whether blocks carry checksums (Options.BlockChecksums)
+
+	// DecodeMemory estimates the bytes decompressing the chunk allocates:
+	// the output and the working buffers, not counting codec state.
+	DecodeMemory int64
+}
+
+// GetInfo returns information about compressed data without decompressing.
+// It fails where ParseHeader does.
+func GetInfo(data []byte) (*Info, error) {
+	header, err := ParseHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	info := &Info{
+		Header:      *header,
+		ShuffleName: header.ShuffleMode().String(),
+		Checksums:   header.HasBlockChecksums(),
+	}
+	info.CodecName = codecName(header.Codec())
+	if header.NBytesComp > 0 {
+		info.Ratio = float64(header.NBytesOrig) / float64(header.NBytesComp)
+	}
+
+	// The output, plus what the decoding path allocates besides
+	nbytes := int64(header.NBytesOrig)
+	info.DecodeMemory = nbytes
+	if header.legacy {
+		info.Blocks = 1
+		if !header.IsMemcpy() && !header.HasBitShuffle() && header.HasShuffle() {
+			info.DecodeMemory += nbytes // Byte shuffled data is decoded aside
+		}
+		return info, nil
+	}
+	info.Blocks, _ = header.numBlocks()
+	if !header.IsMemcpy() && header.Special() == SpecialNone {
+		// The two block buffers of the block decoder
+		bufSize := int64(header.BlockSize)
+		if bufSize > nbytes {
+			bufSize = nbytes
+		}
+		info.DecodeMemory += 2 * bufSize
+	}
+	return info, nil
+}
+
+// GetDecompressedSize returns the original size of compressed data
+func GetDecompressedSize(data []byte) (int, error) {
+	header, err := ParseHeader(data)
+	if err != nil {
+		return 0, err
 	}
+	return int(header.NBytesOrig), nil
+}
 
-	// Apply unshuffle
-	if header.HasBitShuffle() && typeSize > 1 {
-		decompressed = bitUnshuffle(decompressed, typeSize)
-	} else if header.HasShuffle() && typeSize > 1 {
-		decompressed = unshuffleBytes(decompressed, typeSize)
+// compressGo implements compression using pure Go codecs
+func compressGo(data []byte, opts Options, env *filterEnv) ([]byte, error) {
+	b, err := newChunkBuilder(len(data), opts, env)
+	if err != nil {
+		return nil, err
+	}
+	if b.legacy {
+		if opts.prefilter() != nil {
+			// Legacy chunks are a single block
+			data = bytes.Clone(data)
+			opts.prefilter()(data, 0)
+		}
+		chunk, err := compressLegacy(data, opts, b.compressor)
+		if err == nil {
+			b.logChunk(len(data), chunk)
+			reportProgress(opts.progress(), len(data), len(data))
+		}
+		return chunk, err
+	}
+	blockSize := int(b.header.BlockSize)
+	for i := 0; i < b.nblocks && !b.memcpy(); i++ {
+		if err := b.add(data[i*blockSize : min((i+1)*blockSize, len(data))]); err != nil {
+			return nil, err
+		}
+	}
+	var chunk []byte
+	if b.memcpy() {
+		chunk = b.memcpyChunk(data)
+	} else {
+		chunk = b.finish()
+	}
+	b.logChunk(len(data), chunk)
+	return chunk, nil
+}
+
+// chunkBuilder compresses the blocks of a spec chunk one at a time, so that
+// the input need not be in memory all at once
+type chunkBuilder struct {
+	opts       Options
+	env        *filterEnv
+	compressor CodecInterface
+	codec      *codecScratch // Codec state to reuse, or nil
+	legacy     bool          // Built from the whole input in the go-blosc 1.0.x layout
+	store      bool          // Level 0 with filters: streams are stored filtered but raw
+	sums       []byte        // Block checksums so far, if the chunk carries them
+	header     Header
+	filters    pipeline
+	split      bool
+	nblocks    int
+	added      int // Blocks added so far
+	limit      int // Size of the memcpy chunk, which the chunk must stay under
+	result     []byte
+	tmp        []byte
+	pre        []byte // Copy of the block for Options.Prefilter to rewrite
+
+	// What Hooks.Logger is told, gathered only when it logs
+	log       *slog.Logger
+	start     time.Time
+	codecTime time.Duration // Spent in the codec
+	skipped   int           // Streams SkipIncompressible stored as they are
+	reason    string        // Why the chunk is a memcpy chunk
+}
+
+// newChunkBuilder prepares to compress nbytes of input. The chunk may turn
+// out to be a memcpy or legacy chunk before any block is added.
+func newChunkBuilder(nbytes int, opts Options, env *filterEnv) (*chunkBuilder, error) {
+	// Get codec compressor
+	compressor, ok := codecs[opts.Codec]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCodec, opts.Codec)
+	}
+	b := &chunkBuilder{opts: opts, env: env, compressor: compressor, log: debugLogger(&opts)}
+	if b.log != nil {
+		b.start = time.Now()
+	}
+
+	filtered := opts.Filters != [MaxFilters]FilterStage{}
+	dict := opts.Codec == ZSTD && opts.CodecParams.ZSTD.Dict != nil
+	format, ok := codecFormat(opts.Codec)
+	// Only the extended header holds the ID of a registered codec
+	extended := filtered || opts.BlockChecksums || dict || (ok && format == formatUDCodec)
+	if filtered && (opts.LegacyFormat || !ok) {
+		return nil, fmt.Errorf("%w: filters need the Blosc2 format, which %s cannot be written in", ErrInvalidFilter, opts.Codec)
+	}
+	if opts.BlockChecksums && (opts.LegacyFormat || !ok) {
+		return nil, fmt.Errorf("%w: block checksums need the Blosc2 format, which %s cannot be written in", ErrInvalidOption, opts.Codec)
+	}
+	if dict && opts.LegacyFormat {
+		return nil, fmt.Errorf("%w: dictionaries need the Blosc2 format", ErrInvalidOption)
+	}
+	if opts.LegacyFormat || !ok {
+		b.legacy = true
+		return b, nil
+	}
+
+	blockSize := computeBlockSize(opts, nbytes)
+	b.split = splitBlock(opts.TypeSize, blockSize)
+
+	b.header = Header{
+		Version:    FormatVersion,
+		VersionLZ:  codecFormatVersion,
+		Flags:      shuffleFlags(opts.Shuffle) | format<<flagCodecShift,
+		TypeSize:   uint8(opts.TypeSize),
+		NBytesOrig: uint32(nbytes),
+		BlockSize:  uint32(blockSize),
+	}
+	b.filters = shufflePipeline(opts.Shuffle, opts.TypeSize)
+	if extended {
+		slots := opts.Filters
+		if !filtered {
+			slots = shuffleSlots(opts.Shuffle, opts.TypeSize)
+		}
+		var err error
+		if b.filters, err = newPipeline(slots, true); err != nil {
+			return nil, err
+		}
+		b.header.Version = Blosc2FormatVersion
+		b.header.Flags = flagExtended | format<<flagCodecShift
+		b.header.Filters = slots
+		if format == formatUDCodec {
+			b.header.UDCodec = uint8(opts.Codec)
+		}
+		if quantizes(slots) {
+			if opts.ErrorBound.IsZero() {
+				return nil, fmt.Errorf("%w: %s needs Options.ErrorBound", ErrInvalidOption, FilterFloatQuantize)
+			}
+			// Each chunk gathers its own largest error
+			quant := filterEnv{}
+			if env != nil {
+				quant = *env
+			}
+			quant.quantize = &quantizer{bound: opts.ErrorBound}
+			b.env = &quant
+		}
+		if opts.BlockChecksums {
+			b.header.Blosc2Flags |= blosc2FlagSums
+		}
+		if dict {
+			b.header.Blosc2Flags |= blosc2FlagDict
+		}
+	}
+	if !b.split {
+		b.header.Flags |= flagDontSplit
+	}
+	hsize := b.header.Size()
+
+	// Layout: header, one uint32 offset per block, then the blocks. Each block
+	// is a sequence of streams, each a uint32 size followed by codec output, or
+	// by the raw stream when the size equals the stream length.
+	b.nblocks = (nbytes + blockSize - 1) / blockSize
+	b.limit = hsize + nbytes
+	if opts.BlockChecksums {
+		// Memcpy chunks have no blocks to check, so data that does not
+		// compress is stored as blocks of raw streams instead, which can
+		// outgrow the input
+		b.sums = make([]byte, 0, 4*b.nblocks)
+		b.limit = MaxCompressedSize(nbytes, opts)
+	} else if nbytes < minBufferSize || hsize+4*b.nblocks >= b.limit {
+		// Too small to gain anything from compression, as in c-blosc
+		b.header.Flags |= flagMemcpy
+		b.reason = memcpyTooSmall
+		return b, nil
+	}
+	if opts.Level == 0 {
+		// Store mode runs no codec. Memcpy chunks skip the filters when read,
+		// so filtered data is stored as blocks of raw streams instead.
+		if len(b.filters) == 0 && b.sums == nil {
+			b.header.Flags |= flagMemcpy
+			b.reason = memcpyLevel0
+			return b, nil
+		}
+		b.store = true
+	}
+	if s := env.buffers(); s != nil {
+		s.result = s.buffer(s.result, b.limit)
+		s.tmp = s.buffer(s.tmp, 2*blockSize)
+		b.result, b.tmp, b.codec = s.result[:hsize+4*b.nblocks], s.tmp, &s.codec
+		if opts.prefilter() != nil {
+			s.pre = s.buffer(s.pre, blockSize)
+			b.pre = s.pre
+		}
+		return b, nil
+	}
+	b.result = make([]byte, hsize+4*b.nblocks, b.limit)
+	b.tmp = make([]byte, 2*blockSize)
+	if opts.prefilter() != nil {
+		b.pre = make([]byte, blockSize)
+	}
+	return b, nil
+}
+
+// memcpy reports whether the chunk must store its input uncompressed
+func (b *chunkBuilder) memcpy() bool {
+	return b.header.Flags&flagMemcpy != 0
+}
+
+// add compresses the next block. Once the chunk can no longer beat a plain
+// copy, it marks the header memcpy and ignores the block.
+func (b *chunkBuilder) add(block []byte) error {
+	filtered, err := b.filter(b.added, block)
+	if err != nil {
+		return err
+	}
+	return b.encode(filtered)
+}
+
+// filter runs the prefilter and filter pipeline over block i. The result
+// may share memory with block or the builder's scratch space.
+func (b *chunkBuilder) filter(i int, block []byte) ([]byte, error) {
+	blockSize := int(b.header.BlockSize)
+	if b.opts.prefilter() != nil {
+		block = b.pre[:copy(b.pre, block)]
+		b.opts.prefilter()(block, i*blockSize)
+	}
+	filtered, err := b.filters.forward(block, b.tmp[:blockSize], b.tmp[blockSize:], b.opts.TypeSize, b.env)
+	if err != nil {
+		return nil, blockError(StageFilter, i, len(b.result), err)
+	}
+	return filtered, nil
+}
+
+// encode compresses the next block, already filtered, like add
+func (b *chunkBuilder) encode(src []byte) error {
+	i := b.added
+	hsize := b.header.Size()
+	blockSize := int(b.header.BlockSize)
+	start := len(b.result)
+	binary.LittleEndian.PutUint32(b.result[hsize+4*i:], uint32(start))
+
+	// The leftover block is never split
+	nstreams := 1
+	if b.split && len(src) == blockSize {
+		nstreams = b.opts.TypeSize
+	}
+	streamSize := len(src) / nstreams
+
+	for j := 0; j < nstreams; j++ {
+		stream := src[j*streamSize : (j+1)*streamSize]
+		compressed := stream
+		if !b.store && b.opts.SkipIncompressible && incompressible(stream) {
+			b.skipped++
+		} else if !b.store {
+			var start time.Time
+			if b.log != nil {
+				start = time.Now()
+			}
+			var err error
+			compressed, err = codecCompressScratch(b.compressor, stream, &b.opts, b.codec)
+			if b.log != nil {
+				b.codecTime += time.Since(start)
+			}
+			if errors.Is(err, ErrIncompressible) {
+				compressed = stream
+			} else if err != nil {
+				return blockError(StageCodec, i, len(b.result), fmt.Errorf("%w: %w", ErrCompressionFailed, err))
+			}
+			// A stream the size of its input is read back raw, so output that
+			// long must not be taken for codec output
+			if len(compressed) == 0 || len(compressed) >= streamSize {
+				compressed = stream
+			}
+		}
+
+		// Store uncompressed once the chunk can no longer beat a plain copy
+		if !b.store && b.sums == nil && len(b.result)+4+len(compressed) >= b.limit {
+			b.header.Flags |= flagMemcpy
+			b.reason = memcpyIncompressible
+			b.result = b.result[:start]
+			return nil
+		}
+		b.result = binary.LittleEndian.AppendUint32(b.result, uint32(len(compressed)))
+		b.result = append(b.result, compressed...)
+	}
+	if b.sums != nil {
+		b.sums = binary.LittleEndian.AppendUint32(b.sums, crc32.Checksum(b.result[start:], castagnoli))
+	}
+	b.added++
+	nbytes := int(b.header.NBytesOrig)
+	reportProgress(b.opts.progress(), min(b.added*blockSize, nbytes), nbytes)
+	return nil
+}
+
+// memcpyChunk returns the chunk storing all of data uncompressed, once
+// memcpy reports it must
+func (b *chunkBuilder) memcpyChunk(data []byte) []byte {
+	b.noteQuantizeError(0)
+	chunk := appendMemcpy(b.header, data)
+	if b.opts.prefilter() != nil {
+		eachBlock(chunk[b.header.Size():], 0, int(b.header.BlockSize), b.opts.prefilter())
+	}
+	reportProgress(b.opts.progress(), len(data), len(data))
+	return chunk
+}
+
+// finish returns the chunk once every block has been added
+func (b *chunkBuilder) finish() []byte {
+	if b.env != nil && b.env.quantize != nil {
+		b.noteQuantizeError(b.env.quantize.maxErr)
+	}
+	b.result = append(b.result, b.sums...)
+	b.header.NBytesComp = uint32(len(b.result))
+	copy(b.result[:b.header.Size()], b.header.Bytes())
+	return b.result
+}
+
+// noteQuantizeError records err, the largest error FilterFloatQuantize
+// introduced, in the header and for the caller that asked for it
+func (b *chunkBuilder) noteQuantizeError(err float64) {
+	if b.env == nil || b.env.quantize == nil {
+		return
+	}
+	for i, s := range b.header.Filters {
+		if s.Filter == FilterFloatQuantize {
+			b.header.Filters[i].Meta = quantizeErrorCode(err)
+		}
+	}
+	if b.env.quantError != nil {
+		*b.env.quantError = err
+	}
+}
+
+// decodeAdded decompresses the blocks added so far, which are all full
+// blocks, to recover input that is no longer at hand
+func (b *chunkBuilder) decodeAdded() ([]byte, error) {
+	if b.added == 0 {
+		return nil, nil
+	}
+	hsize := b.header.Size()
+	blockSize := int(b.header.BlockSize)
+
+	// Rebuild the chunk as if it held only those blocks
+	header := b.header
+	header.Flags &^= flagMemcpy
+	header.NBytesOrig = uint32(b.added * blockSize)
+	tableEnd := hsize + 4*b.nblocks
+	shift := 4 * (b.nblocks - b.added)
+	chunk := header.Bytes()
+	for i := 0; i < b.added; i++ {
+		offset := binary.LittleEndian.Uint32(b.result[hsize+4*i:])
+		chunk = binary.LittleEndian.AppendUint32(chunk, offset-uint32(shift))
+	}
+	chunk = append(chunk, b.result[tableEnd:]...)
+
+	data := make([]byte, header.NBytesOrig)
+	if err := decodeBlocks(&header, chunk, b.opts.TypeSize, b.env, data, nil); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// appendMemcpy builds a memcpy chunk holding data as-is after the header
+func appendMemcpy(header Header, data []byte) []byte {
+	hsize := header.Size()
+	header.NBytesComp = uint32(hsize + len(data))
+	result := make([]byte, hsize+len(data))
+	copy(result[:hsize], header.Bytes())
+	copy(result[hsize:], data)
+	return result
+}
+
+// eachBlock calls f on each blockSize piece of data, with its offset in the
+// whole input given that data starts at offset
+func eachBlock(data []byte, offset, blockSize int, f func(block []byte, offset int)) {
+	for i := 0; i < len(data); i += blockSize {
+		f(data[i:min(i+blockSize, len(data))], offset+i)
+	}
+}
+
+// shuffleFlags returns the header flags for a shuffle mode
+func shuffleFlags(s Shuffle) uint8 {
+	switch s {
+	case Shuffle1:
+		return flagShuffle
+	case BitShuffle:
+		return flagBitShuffle
+	default:
+		return 0
+	}
+}
+
+// isHCR reports whether a codec favours high compression ratio over speed and
+// therefore benefits from larger blocks.
+func isHCR(c Codec) bool {
+	return c == LZ4HC || c == ZLIB || c == ZSTD
+}
+
+// splitBlock reports whether blocks of blockSize bytes are stored as one stream
+// per byte position, using c-blosc's forward-compatible split rule.
+func splitBlock(typeSize, blockSize int) bool {
+	return typeSize <= maxSplits && blockSize/typeSize >= minBufferSize
+}
+
+// computeBlockSize picks the block size for nbytes of data, following c-blosc's
+// heuristics so chunks are laid out as the C library would lay them out.
+// Options.BlockSize, when set, is used as-is apart from clamping, which
+// chunks with a filter pipeline skip for blocks under 128 bytes.
+func computeBlockSize(opts Options, nbytes int) int {
+	typeSize := opts.TypeSize
+	if nbytes < typeSize {
+		return nbytes
+	}
+
+	blockSize := nbytes
+	if opts.BlockSize > 0 {
+		blockSize = opts.BlockSize
+		// Blosc2 chunks keep small blocks, which NDArrays rely on
+		if opts.Filters == ([MaxFilters]FilterStage{}) {
+			blockSize = max(blockSize, minBufferSize)
+		}
+	} else {
+		if nbytes >= l1CacheSize {
+			blockSize = l1CacheSize
+			if isHCR(opts.Codec) {
+				blockSize *= 2
+			}
+			switch opts.Level {
+			case 0, 1:
+				blockSize /= 2
+			case 2:
+			case 3:
+				blockSize *= 2
+			case 4, 5:
+				blockSize *= 4
+			case 6, 7, 8:
+				blockSize *= 8
+			default:
+				blockSize *= 8
+				if isHCR(opts.Codec) {
+					blockSize *= 2
+				}
+			}
+		}
+
+		// Split codecs compress each stream separately, so give them
+		// typeSize times the data, within 64 KB to 1 MB
+		if splitBlock(typeSize, blockSize) {
+			blockSize = min(blockSize, 1<<18) * typeSize
+			blockSize = min(max(blockSize, 1<<16), 1<<20)
+		}
+	}
+
+	blockSize = min(blockSize, nbytes)
+	// Blocks must hold whole elements
+	if blockSize > typeSize {
+		blockSize = blockSize / typeSize * typeSize
+	}
+	return blockSize
+}
+
+// decompressGo implements decompression using pure Go codecs. It decompresses
+// data and appends the result to dst; on error, dst is returned unchanged. A
+// negative maxBytes means no limit.
+func decompressGo(dst, data []byte, typeSize, maxBytes int, env *filterEnv) ([]byte, error) {
+	header, typeSize, err := checkChunk(data, typeSize, maxBytes)
+	if err != nil {
+		return dst, err
+	}
+	if err := checkChunkDelta(header, env); err != nil {
+		return dst, err
+	}
+	hsize := header.Size()
+
+	start := len(dst)
+	out := dst
+	switch {
+	case header.IsMemcpy():
+		// Memcpy chunks hold the original data; filters do not apply
+		out = append(out, data[hsize:header.NBytesComp]...)
+		if env != nil && env.postfilter != nil && header.BlockSize > 0 {
+			eachBlock(out[start:], 0, int(header.BlockSize), env.postfilter)
+		}
+	case header.legacy:
+		out = slices.Grow(out, int(header.NBytesOrig))[:start+int(header.NBytesOrig)]
+		err = decompressLegacyInto(out[start:], header, data[HeaderSize:header.NBytesComp], typeSize)
+		if err == nil && env != nil && env.postfilter != nil {
+			env.postfilter(out[start:], 0)
+		}
+	default:
+		out = slices.Grow(out, int(header.NBytesOrig))[:start+int(header.NBytesOrig)]
+		err = decodeBlocks(header, data[:header.NBytesComp], typeSize, env, out[start:], nil)
+	}
+	if err != nil {
+		return dst, err
 	}
 
 	// Verify size
-	if len(decompressed) != int(header.NBytesOrig) {
-		return nil, fmt.Errorf("%w: got %d, expected %d", ErrSizeMismatch, len(decompressed), header.NBytesOrig)
+	if len(out)-start != int(header.NBytesOrig) {
+		return dst, blockError(StageCodec, -1, hsize, fmt.Errorf("%w: got %d, expected %d", ErrSizeMismatch, len(out)-start, header.NBytesOrig))
+	}
+	if header.IsMemcpy() || header.legacy {
+		// Decoded as a whole
+		env.reportProgress(len(out)-start, len(out)-start)
+	}
+
+	return out, nil
+}
+
+// checkChunk parses and checks the header of a chunk before decompression,
+// and returns the element size to unshuffle with: typeSize if positive, or
+// the one in the header
+func checkChunk(data []byte, typeSize, maxBytes int) (*Header, int, error) {
+	// Parse header
+	header, err := ParseHeader(data)
+	if err != nil {
+		return nil, 0, headerError(0, err)
+	}
+	if maxBytes = decodeLimit(maxBytes); maxBytes >= 0 && uint64(header.NBytesOrig) > uint64(maxBytes) {
+		return nil, 0, headerError(offsetNBytesOrig, fmt.Errorf("%w: header claims %d bytes, limit is %d", ErrOutputTooLarge, header.NBytesOrig, maxBytes))
+	}
+
+	// Validate sizes
+	if int64(header.NBytesComp) > int64(len(data)) {
+		return nil, 0, headerError(offsetNBytesComp, fmt.Errorf("%w: header claims %d bytes, have %d", ErrInvalidData, header.NBytesComp, len(data)))
+	}
+	hsize := header.Size()
+	if int(header.NBytesComp) < hsize {
+		return nil, 0, headerError(offsetNBytesComp, fmt.Errorf("%w: compressed size %d is smaller than the header", ErrInvalidData, header.NBytesComp))
+	}
+
+	// Use header typeSize if not overridden
+	if typeSize <= 0 {
+		typeSize = int(header.TypeSize)
 	}
 
-	return decompressed, nil
+	if err := checkFlags(header); err != nil {
+		return nil, 0, err
+	}
+	switch {
+	case header.IsMemcpy() || header.Special() != SpecialNone:
+	case header.legacy:
+		if err := checkLegacyExpansion(header); err != nil {
+			return nil, 0, err
+		}
+	default:
+		if err := checkPayload(header, data[:header.NBytesComp]); err != nil {
+			return nil, 0, err
+		}
+	}
+	return header, typeSize, nil
+}
+
+// checkLegacyExpansion checks that the single stream of a legacy chunk can
+// decode to the whole output at the codec's largest expansion ratio
+func checkLegacyExpansion(header *Header) error {
+	payload := int64(header.NBytesComp) - int64(header.Size())
+	if c, ok := codecs[header.Codec()].(boundedDecompressor); ok && int64(header.NBytesOrig)/int64(c.maxRatio()) > payload {
+		return headerError(offsetNBytesOrig, fmt.Errorf("%w: %d bytes of %s stream cannot decode to %d", ErrInvalidData, payload, header.Codec(), header.NBytesOrig))
+	}
+	return nil
+}
+
+// checkExpansion checks, from the header alone, that a spec chunk has room
+// for its block offset table and block checksums, and that its payload can
+// decode to NBytesOrig at the codec's largest expansion ratio. It runs
+// before any buffer is sized from NBytesOrig or BlockSize. Extended chunks
+// may hold runs of a repeated byte, which expand without limit, so only
+// their streams are checked, by checkPayload.
+func checkExpansion(header *Header) error {
+	nblocks, ok := header.numBlocks()
+	if !ok {
+		return nil // newBlockDecoder reports it
+	}
+	hsize := int64(header.Size())
+	overhead := 4 * int64(nblocks)
+	if header.HasBlockChecksums() {
+		overhead *= 2
+	}
+	payload := int64(header.NBytesComp) - hsize - overhead
+	if payload < 0 {
+		return headerError(int(hsize), fmt.Errorf("%w: %d block offsets do not fit in %d bytes", ErrInvalidData, nblocks, header.NBytesComp))
+	}
+	c, ok := codecs[header.Codec()].(boundedDecompressor)
+	if ok && !header.IsExtended() && int64(header.NBytesOrig) > int64(c.maxRatio())*payload {
+		return headerError(offsetNBytesOrig, fmt.Errorf("%w: %d bytes of %s streams cannot decode to %d", ErrInvalidData, payload, header.Codec(), header.NBytesOrig))
+	}
+	return nil
+}
+
+// checkPayload runs checkExpansion, then walks the stream sizes of a spec
+// chunk, trimmed to NBytesComp, and rejects any stream too short to decode
+// to its share of its block. Offsets and streams that do not fit the chunk,
+// which may be truncated, are left for the block decoder to report.
+func checkPayload(header *Header, chunk []byte) error {
+	if err := checkExpansion(header); err != nil {
+		return err
+	}
+	c, ok := codecs[header.Codec()].(boundedDecompressor)
+	nblocks, nonzero := header.numBlocks()
+	if !ok || !nonzero {
+		return nil
+	}
+	ratio := uint64(c.maxRatio())
+	hsize := header.Size()
+	tableEnd := hsize + 4*nblocks
+	if header.HasBlockChecksums() {
+		chunk = chunk[:min(len(chunk), int(header.NBytesComp)-4*nblocks)]
+	}
+	blockSize, nbytes := int(header.BlockSize), int(header.NBytesOrig)
+	for i := 0; i < nblocks && hsize+4*i+4 <= len(chunk); i++ {
+		start := int(binary.LittleEndian.Uint32(chunk[hsize+4*i:]))
+		if start < tableEnd || start > len(chunk) {
+			continue
+		}
+		n := min(blockSize, nbytes-i*blockSize)
+		nstreams := header.blockStreams(n)
+		streamSize := n / nstreams
+		src := chunk[start:]
+		for j := 0; j < nstreams && len(src) >= 4; j++ {
+			size := binary.LittleEndian.Uint32(src)
+			src = src[4:]
+			if run := int32(size); header.IsExtended() && run <= 0 && run >= -255 {
+				continue
+			}
+			if uint64(size) > uint64(len(src)) {
+				break
+			}
+			if int(size) != streamSize && uint64(size)*ratio < uint64(streamSize) {
+				return blockError(StageCodec, i, len(chunk)-len(src)-4, fmt.Errorf("%w: %d bytes of %s stream %d cannot decode to %d", ErrInvalidData, size, header.Codec(), j, streamSize))
+			}
+			src = src[size:]
+		}
+	}
+	return nil
+}
+
+// blockStreams returns the number of streams a block of n bytes is stored
+// in: one per byte of an element for split full blocks, one otherwise. The
+// leftover block is never split.
+func (h *Header) blockStreams(n int) int {
+	if typeSize := int(h.TypeSize); h.splitStreams() && n == int(h.BlockSize) && typeSize > 1 && n%typeSize == 0 {
+		return typeSize
+	}
+	return 1
+}
+
+// checkFlags rejects header sizes and flags that no chunk can be decoded with
+func checkFlags(header *Header) error {
+	if uint64(header.NBytesOrig) > math.MaxInt {
+		// Only on 32-bit platforms
+		return headerError(offsetNBytesOrig, fmt.Errorf("%w: header claims %d bytes", ErrDataTooLarge, header.NBytesOrig))
+	}
+	if err := checkFeatures(header); err != nil {
+		return err
+	}
+	if !header.IsMemcpy() && !header.IsExtended() && header.Flags&flagExtended == flagExtended {
+		return &BloscError{Stage: StageFilter, Block: -1, Offset: offsetFlags, Err: fmt.Errorf("%w: byte and bit shuffle both set", ErrInvalidShuffle)}
+	}
+	return nil
+}
+
+// decodeBlocks decodes the blocks of a spec or special-value chunk. chunk is
+// the whole chunk, header included, trimmed to NBytesComp. Blocks are decoded
+// into dst, which holds NBytesOrig bytes, or if dst is nil into a block
+// buffer that is passed to emit after each block. Errors from emit are
+// returned as they are.
+func decodeBlocks(header *Header, chunk []byte, typeSize int, env *filterEnv, dst []byte, emit func([]byte) error) error {
+	if header.Special() != SpecialNone {
+		err := decodeSpecial(header, chunk, dst, emit)
+		if err == nil && dst != nil && env != nil && env.postfilter != nil {
+			eachBlock(dst, 0, max(int(header.BlockSize), 1), env.postfilter)
+		}
+		if err == nil {
+			env.reportProgress(int(header.NBytesOrig), int(header.NBytesOrig))
+		}
+		return err
+	}
+	d, err := newBlockDecoder(header, chunk, typeSize, env)
+	if err != nil {
+		return err
+	}
+	if err := d.checkTable(); err != nil {
+		return err
+	}
+
+	var out []byte
+	if dst == nil {
+		out = make([]byte, d.bufSize)
+	}
+	for i := 0; i < d.nblocks; i++ {
+		var blockDst []byte
+		if dst != nil {
+			blockDst = dst[i*d.blockSize : i*d.blockSize+d.blockLen(i)]
+		} else {
+			blockDst = out[:d.blockLen(i)]
+		}
+		if err := d.decode(i, blockDst); err != nil {
+			return err
+		}
+		env.reportProgress(i*d.blockSize+len(blockDst), int(header.NBytesOrig))
+		if emit != nil {
+			if err := emit(blockDst); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// blockDecoder decodes the blocks of a spec chunk one at a time
+type blockDecoder struct {
+	header       *Header
+	chunk        []byte
+	typeSize     int
+	env          *filterEnv
+	decompressor CodecInterface
+	filters      pipeline
+	sums         []byte // Block checksums, split off the end of chunk
+	hsize        int
+	nblocks      int
+	tableEnd     int // End of the block offset table
+	blockSize    int
+	bufSize      int
+	tmp          []byte
+}
+
+// newBlockDecoder checks the codec, filters and block size of a spec chunk.
+// The block offset table is only read as blocks are decoded.
+func newBlockDecoder(header *Header, chunk []byte, typeSize int, env *filterEnv) (*blockDecoder, error) {
+	codec := header.Codec()
+	decompressor, ok := codecs[codec]
+	if !ok {
+		return nil, headerError(0, fmt.Errorf("%w: %s", ErrInvalidCodec, codec))
+	}
+	if header.HasDict() {
+		if codec != ZSTD {
+			return nil, headerError(offsetBlosc2Flags, fmt.Errorf("%w: dictionary for %s, only ZSTD chunks have one", ErrUnsupportedFeature, codec))
+		}
+		if env == nil || env.dict == nil || env.dict.codec == nil {
+			return nil, headerError(offsetBlosc2Flags, ErrMissingDict)
+		}
+		decompressor = env.dict.decompressor()
+	}
+
+	filters := shufflePipeline(header.ShuffleMode(), typeSize)
+	if header.IsExtended() {
+		var err error
+		if filters, err = newPipeline(header.Filters, false); err != nil {
+			return nil, headerError(offsetFilters, err)
+		}
+	}
+
+	nblocks, ok := header.numBlocks()
+	if !ok {
+		return nil, headerError(offsetBlockSize, fmt.Errorf("%w: zero block size", ErrInvalidHeader))
+	}
+	hsize := header.Size()
+	var sums []byte
+	if header.HasBlockChecksums() {
+		nbytes := int(header.NBytesComp)
+		end := nbytes - 4*nblocks
+		if end < hsize+4*nblocks {
+			return nil, headerError(hsize, fmt.Errorf("%w: %d block checksums do not fit in %d bytes", ErrInvalidData, nblocks, nbytes))
+		}
+		if len(chunk) >= nbytes {
+			chunk, sums = chunk[:end], chunk[end:nbytes]
+		} else {
+			// Only Salvage decodes truncated chunks, whose checksums are lost
+			chunk = chunk[:min(end, len(chunk))]
+		}
+	}
+	return &blockDecoder{
+		header:       header,
+		chunk:        chunk,
+		typeSize:     typeSize,
+		env:          env,
+		decompressor: decompressor,
+		filters:      filters,
+		sums:         sums,
+		hsize:        hsize,
+		nblocks:      nblocks,
+		tableEnd:     hsize + 4*nblocks,
+		blockSize:    int(header.BlockSize),
+		bufSize:      min(int(header.BlockSize), int(header.NBytesOrig)),
+	}, nil
+}
+
+// block returns the scratch block decode unpacks streams into, allocating it
+// on first use, so that chunks are checked before buffers are sized from
+// their headers
+func (d *blockDecoder) block(n int) []byte {
+	if d.tmp == nil {
+		if s := d.env.buffers(); s != nil {
+			s.tmp = s.buffer(s.tmp, 2*d.bufSize)
+			d.tmp = s.tmp[:d.bufSize]
+		} else {
+			d.tmp = make([]byte, d.bufSize, 2*d.bufSize)
+		}
+	}
+	return d.tmp[:n]
+}
+
+// checkTable checks that the block offset table fits the chunk and that the
+// first block follows it
+func (d *blockDecoder) checkTable() error {
+	if d.tableEnd > len(d.chunk) {
+		return headerError(d.hsize, fmt.Errorf("%w: %d block offsets do not fit in %d bytes", ErrInvalidData, d.nblocks, len(d.chunk)))
+	}
+	if first := binary.LittleEndian.Uint32(d.chunk[d.hsize:]); first != uint32(d.tableEnd) {
+		return blockError(StageHeader, 0, d.hsize, fmt.Errorf("%w: first block at %d, expected %d for %d blocks", ErrSizeMismatch, first, d.tableEnd, d.nblocks))
+	}
+	return nil
+}
+
+// blockLen returns the decompressed size of block i
+func (d *blockDecoder) blockLen(i int) int {
+	return min(d.blockSize, int(d.header.NBytesOrig)-i*d.blockSize)
+}
+
+// decode decodes block i into dst, which holds blockLen(i) bytes
+func (d *blockDecoder) decode(i int, dst []byte) error {
+	n := len(dst)
+	streamSize := n / d.header.blockStreams(n)
+	block := d.block(n)
+	start, err := d.decodeStreams(i, n, func(j int) []byte {
+		return block[j*streamSize : (j+1)*streamSize : (j+1)*streamSize]
+	})
+	if err != nil {
+		return err
+	}
+
+	// Undo the filter pipeline
+	if err := d.filters.backward(dst, block, d.tmp[d.bufSize:2*d.bufSize], d.typeSize, d.env); err != nil {
+		return blockError(StageFilter, i, start, err)
+	}
+	if d.env != nil && d.env.postfilter != nil {
+		d.env.postfilter(dst, i*d.blockSize)
+	}
+	return nil
+}
+
+// decodeStreams checks the checksum of block i, of n bytes, if there is one,
+// and decodes each of its streams j into stream(j), which holds the
+// stream's share of the block. It returns the offset of the block.
+func (d *blockDecoder) decodeStreams(i, n int, stream func(j int) []byte) (int, error) {
+	header, chunk := d.header, d.chunk
+	if d.hsize+4*i+4 > len(chunk) {
+		return 0, blockError(StageHeader, i, d.hsize+4*i, fmt.Errorf("%w: block offset truncated", ErrInvalidData))
+	}
+	start := int(binary.LittleEndian.Uint32(chunk[d.hsize+4*i:]))
+	if start < d.tableEnd || start > len(chunk) {
+		return 0, blockError(StageHeader, i, d.hsize+4*i, fmt.Errorf("%w: block offset %d out of range", ErrInvalidData, start))
+	}
+	src := chunk[start:]
+	nstreams := header.blockStreams(n)
+	streamSize := n / nstreams
+	if d.sums != nil {
+		if err := d.verify(i, start, nstreams); err != nil {
+			return 0, err
+		}
+	}
+
+	for j := 0; j < nstreams; j++ {
+		offset := len(chunk) - len(src)
+		if len(src) < 4 {
+			return 0, blockError(StageCodec, i, offset, fmt.Errorf("%w: stream %d size truncated", ErrInvalidData, j))
+		}
+		raw := binary.LittleEndian.Uint32(src)
+		src = src[4:]
+		dst := stream(j)
+		if run := int32(raw); header.IsExtended() && run <= 0 && run >= -255 {
+			// Blosc2 stores a stream of one repeated byte as its negated value
+			fillBytes(dst, byte(-run))
+			continue
+		}
+		// Compared before converting, as sizes of 2 GiB and more are
+		// negative as an int on 32-bit platforms
+		if uint64(raw) > uint64(len(src)) {
+			return 0, blockError(StageCodec, i, offset, fmt.Errorf("%w: stream %d truncated", ErrInvalidData, j))
+		}
+		size := int(raw)
+		if size == streamSize {
+			copy(dst, src[:size])
+		} else {
+			n, err := codecDecompressInto(d.decompressor, dst, src[:size])
+			if err != nil {
+				return 0, blockError(StageCodec, i, offset+4, fmt.Errorf("%w: stream %d: %w", ErrDecompressionFailed, j, err))
+			}
+			if n != streamSize {
+				return 0, blockError(StageCodec, i, offset+4, fmt.Errorf("%w: stream %d got %d, expected %d", ErrSizeMismatch, j, n, streamSize))
+			}
+			if d.env != nil && d.env.skippable != nil && header.Codec() == ZSTD {
+				err := zstdSkippableFrames(src[:size], func(id int, content []byte) { d.env.skippable(i, id, content) })
+				if err != nil {
+					return 0, blockError(StageCodec, i, offset+4, fmt.Errorf("%w: stream %d: %w", ErrDecompressionFailed, j, err))
+				}
+			}
+		}
+		src = src[size:]
+	}
+	return start, nil
+}
+
+// verify checks the nstreams streams of block i, starting at start, against
+// the block checksum before any of them is decoded
+func (d *blockDecoder) verify(i, start, nstreams int) error {
+	end := start
+	for j := 0; j < nstreams; j++ {
+		if end+4 > len(d.chunk) {
+			return blockError(StageCodec, i, end, fmt.Errorf("%w: stream %d size truncated", ErrInvalidData, j))
+		}
+		size := int(binary.LittleEndian.Uint32(d.chunk[end:]))
+		end += 4
+		if run := int32(size); run <= 0 && run >= -255 {
+			continue // Run of a repeated byte, with no payload
+		}
+		if size < 0 || size > len(d.chunk)-end {
+			return blockError(StageCodec, i, end-4, fmt.Errorf("%w: stream %d truncated", ErrInvalidData, j))
+		}
+		end += size
+	}
+	sum := binary.LittleEndian.Uint32(d.sums[4*i:])
+	if crc := crc32.Checksum(d.chunk[start:end], castagnoli); crc != sum {
+		return blockError(StageCodec, i, start, fmt.Errorf("%w: block checksum %08x, stored %08x", ErrChecksum, crc, sum))
+	}
+	return nil
+}
+
+// fillBytes sets every byte of b to v
+func fillBytes(b []byte, v byte) {
+	for i := range b {
+		b[i] = v
+	}
 }
@@ -5,9 +5,12 @@ import (
 	cryptorand "crypto/rand"
 	"encoding/binary"
 	"errors"
+	"io"
 	"math"
 	"math/rand"
+	"runtime"
 	"testing"
+	"testing/iotest"
 )
 
 func TestCompressDecompressLZ4(t *testing.T) {
@@ -222,7 +225,7 @@ func TestEmptyData(t *testing.T) {
 
 func TestInvalidHeader(t *testing.T) {
 	_, err := Decompress([]byte{1, 2, 3})
-	if err != ErrInvalidHeader {
+	if !errors.Is(err, ErrInvalidHeader) {
 		t.Errorf("expected ErrInvalidHeader for short data, got %v", err)
 	}
 }
@@ -311,6 +314,39 @@ func TestVariousTypeSizes(t *testing.T) {
 	}
 }
 
+func TestWideTypeSizes(t *testing.T) {
+	data := makeFloatData(1 << 16)
+	for _, typeSize := range []int{3, 17, 100, 255, 256, 300, 1000} {
+		for _, shuffle := range []Shuffle{NoShuffle, Shuffle1, BitShuffle} {
+			compressed, err := Compress(data, ZSTD, 5, shuffle, typeSize)
+			if err != nil {
+				t.Fatalf("compress typesize=%d shuffle=%s failed: %v", typeSize, shuffle, err)
+			}
+			h, err := ParseHeader(compressed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// As in c-blosc, elements too wide for the header are bytes
+			want := typeSize
+			if typeSize > 255 {
+				want = 1
+			}
+			if int(h.TypeSize) != want {
+				t.Errorf("typesize=%d: header has %d", typeSize, h.TypeSize)
+			}
+			decompressed, err := Decompress(compressed)
+			if err != nil || !bytes.Equal(data, decompressed) {
+				t.Errorf("round trip typesize=%d shuffle=%s failed: %v", typeSize, shuffle, err)
+			}
+		}
+	}
+
+	opts := Options{Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: 256, Strict: true}
+	if _, err := CompressWithOptions(data, opts); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("strict typesize 256: expected ErrInvalidOption, got %v", err)
+	}
+}
+
 func TestCodecStrings(t *testing.T) {
 	tests := []struct {
 		codec Codec
@@ -539,6 +575,54 @@ func TestGetInfo(t *testing.T) {
 	if !info.HasBitShuffle() {
 		t.Error("expected bitshuffle flag to be set")
 	}
+	if info.CodecName != "zstd" || info.ShuffleName != "bitshuffle" || info.Checksums {
+		t.Errorf("got codec %q, shuffle %q, checksums %v", info.CodecName, info.ShuffleName, info.Checksums)
+	}
+	if want := float64(info.NBytesOrig) / float64(len(compressed)); info.Ratio != want {
+		t.Errorf("ratio %v, want %v", info.Ratio, want)
+	}
+}
+
+func TestGetInfoReport(t *testing.T) {
+	data := makeFloatData(1 << 18)
+	for name, opts := range map[string]Options{
+		"blocks":    {Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: 4, BlockSize: 1 << 16},
+		"checksums": {Codec: LZ4, Level: 5, TypeSize: 4, BlockSize: 1 << 16, BlockChecksums: true},
+		"legacy":    {Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: 4, LegacyFormat: true},
+		"memcpy":    {Codec: LZ4, Level: 0, TypeSize: 4, BlockSize: 1 << 16},
+	} {
+		chunk, err := CompressWithOptions(data, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info, err := GetInfo(chunk)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		blocks := 16
+		if opts.LegacyFormat {
+			blocks = 1
+		}
+		if info.Blocks != blocks || info.Checksums != opts.BlockChecksums || info.CodecName != "lz4" {
+			t.Errorf("%s: %d blocks, checksums %v, codec %q", name, info.Blocks, info.Checksums, info.CodecName)
+		}
+
+		// The estimate covers what decoding allocates, except under the race
+		// detector, where pools drop buffers at random
+		if raceEnabled {
+			continue
+		}
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		if _, err := Decompress(chunk); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		runtime.ReadMemStats(&after)
+		n := int64(after.TotalAlloc - before.TotalAlloc)
+		if n > info.DecodeMemory+4096 || info.DecodeMemory > n+n/8 {
+			t.Errorf("%s: decoding allocated %d bytes, estimated %d", name, n, info.DecodeMemory)
+		}
+	}
 }
 
 func TestGetDecompressedSizeError(t *testing.T) {
@@ -716,6 +800,21 @@ func TestDecompressWithTypeSizeOverride(t *testing.T) {
 	if !bytes.Equal(data, decompressed2) {
 		t.Error("data mismatch using header typeSize")
 	}
+
+	// Unshuffling with another element size would garble the output
+	if _, err := DecompressWithSize(compressed, 8); !errors.Is(err, ErrTypeSizeMismatch) {
+		t.Errorf("expected ErrTypeSizeMismatch, got %v", err)
+	}
+	if _, err := DecompressWithOptions(compressed, DecompressOptions{TypeSize: 2}); !errors.Is(err, ErrTypeSizeMismatch) {
+		t.Errorf("expected ErrTypeSizeMismatch, got %v", err)
+	}
+	if _, err := DecompressWithOptions(compressed, DecompressOptions{TypeSize: 3, ForceTypeSize: true}); !errors.Is(err, ErrTypeSizeMismatch) {
+		t.Errorf("expected ErrTypeSizeMismatch for 3-byte elements of %d bytes, got %v", len(data), err)
+	}
+	forced, err := DecompressWithOptions(compressed, DecompressOptions{TypeSize: 8, ForceTypeSize: true})
+	if err != nil || len(forced) != len(data) {
+		t.Errorf("forced override returned %d bytes, %v", len(forced), err)
+	}
 }
 
 // =============================================================================
@@ -836,3 +935,1108 @@ func TestZSTDCompressionLevels(t *testing.T) {
 		})
 	}
 }
+
+// =============================================================================
+// Chunk Layout Tests
+// =============================================================================
+
+func TestCompressSpecCodecFlags(t *testing.T) {
+	data := makeTestData(10000)
+
+	testCases := []struct {
+		codec  Codec
+		format uint8
+		want   Codec
+	}{
+		{LZ4, formatLZ4, LZ4},
+		{LZ4HC, formatLZ4, LZ4},
+		{Snappy, formatSnappy, Snappy},
+		{ZLIB, formatZlib, ZLIB},
+		{ZSTD, formatZstd, ZSTD},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.codec.String(), func(t *testing.T) {
+			compressed, err := Compress(data, tc.codec, 5, Shuffle1, 4)
+			if err != nil {
+				t.Fatalf("compress failed: %v", err)
+			}
+
+			header, err := ParseHeader(compressed)
+			if err != nil {
+				t.Fatalf("parse header failed: %v", err)
+			}
+			if header.IsMemcpy() {
+				t.Fatal("expected compressed chunk")
+			}
+			if header.IsLegacy() {
+				t.Error("expected spec chunk")
+			}
+			if header.VersionLZ != codecFormatVersion {
+				t.Errorf("VersionLZ: got %d, want %d", header.VersionLZ, codecFormatVersion)
+			}
+			if got := header.Flags >> flagCodecShift; got != tc.format {
+				t.Errorf("compressor code: got %d, want %d", got, tc.format)
+			}
+			if got := header.Codec(); got != tc.want {
+				t.Errorf("Codec(): got %s, want %s", got, tc.want)
+			}
+
+			decompressed, err := Decompress(compressed)
+			if err != nil {
+				t.Fatalf("decompress failed: %v", err)
+			}
+			if !bytes.Equal(data, decompressed) {
+				t.Error("data mismatch")
+			}
+		})
+	}
+}
+
+func TestCompressMultipleBlocks(t *testing.T) {
+	data := makeTestData(10000)
+
+	for _, shuffle := range []Shuffle{NoShuffle, Shuffle1, BitShuffle} {
+		t.Run(shuffle.String(), func(t *testing.T) {
+			opts := Options{Codec: LZ4, Level: 5, Shuffle: shuffle, TypeSize: 4, BlockSize: 1024}
+			compressed, err := CompressWithOptions(data, opts)
+			if err != nil {
+				t.Fatalf("compress failed: %v", err)
+			}
+
+			header, _ := ParseHeader(compressed)
+			if header.BlockSize != 1024 {
+				t.Errorf("BlockSize: got %d, want 1024", header.BlockSize)
+			}
+			if !header.splitStreams() {
+				t.Error("expected split streams for 1024-byte blocks of typeSize 4")
+			}
+
+			// The offset table holds one entry per block, the first pointing
+			// just past the table
+			nblocks, _ := header.numBlocks()
+			if nblocks != 10 {
+				t.Fatalf("numBlocks: got %d, want 10", nblocks)
+			}
+			if got := binary.LittleEndian.Uint32(compressed[HeaderSize:]); got != uint32(HeaderSize+4*nblocks) {
+				t.Errorf("first block offset: got %d, want %d", got, HeaderSize+4*nblocks)
+			}
+
+			decompressed, err := Decompress(compressed)
+			if err != nil {
+				t.Fatalf("decompress failed: %v", err)
+			}
+			if !bytes.Equal(data, decompressed) {
+				t.Error("data mismatch")
+			}
+		})
+	}
+}
+
+func TestCompressDontSplitFlag(t *testing.T) {
+	data := makeTestData(10000)
+
+	// Element sizes above 16 bytes are never split into streams
+	compressed, err := Compress(data, LZ4, 5, Shuffle1, 20)
+	if err != nil {
+		t.Fatalf("compress failed: %v", err)
+	}
+	header, _ := ParseHeader(compressed)
+	if header.splitStreams() {
+		t.Error("expected dont-split flag for typeSize 20")
+	}
+
+	decompressed, err := Decompress(compressed)
+	if err != nil {
+		t.Fatalf("decompress failed: %v", err)
+	}
+	if !bytes.Equal(data, decompressed) {
+		t.Error("data mismatch")
+	}
+}
+
+// TestDecompressHandBuiltChunk decodes a chunk assembled by hand following
+// the Blosc header spec: two split blocks, one holding a raw stream, and an
+// unsplit leftover block.
+func TestDecompressHandBuiltChunk(t *testing.T) {
+	const typeSize, blockSize = 2, 512
+	data := makeTestData(1200)
+
+	chunk := make([]byte, HeaderSize+4*3)
+	for i := 0; i < 3; i++ {
+		binary.LittleEndian.PutUint32(chunk[HeaderSize+4*i:], uint32(len(chunk)))
+		block := data[i*blockSize : min((i+1)*blockSize, len(data))]
+		shuffled := shuffleBytes(block, typeSize)
+
+		nstreams := typeSize
+		if len(block) < blockSize {
+			nstreams = 1
+		}
+		streamSize := len(block) / nstreams
+		for j := 0; j < nstreams; j++ {
+			stream := shuffled[j*streamSize : (j+1)*streamSize]
+			payload := stream
+			if i != 1 || j != 0 {
+				var err error
+				payload, err = codecs[LZ4].Compress(stream, 5)
+				if err != nil {
+					t.Fatalf("lz4 compress failed: %v", err)
+				}
+			}
+			chunk = binary.LittleEndian.AppendUint32(chunk, uint32(len(payload)))
+			chunk = append(chunk, payload...)
+		}
+	}
+
+	header := Header{
+		Version:    FormatVersion,
+		VersionLZ:  codecFormatVersion,
+		Flags:      flagShuffle | formatLZ4<<flagCodecShift,
+		TypeSize:   typeSize,
+		NBytesOrig: uint32(len(data)),
+		BlockSize:  blockSize,
+		NBytesComp: uint32(len(chunk)),
+	}
+	copy(chunk, header.Bytes())
+
+	decompressed, err := Decompress(chunk)
+	if err != nil {
+		t.Fatalf("decompress failed: %v", err)
+	}
+	if !bytes.Equal(data, decompressed) {
+		t.Error("data mismatch")
+	}
+}
+
+func TestLegacyFormatRoundTrip(t *testing.T) {
+	data := makeTestData(10000)
+
+	for _, codec := range []Codec{LZ4, LZ4HC, Snappy, ZLIB, ZSTD} {
+		for _, shuffle := range []Shuffle{NoShuffle, Shuffle1, BitShuffle} {
+			opts := Options{Codec: codec, Level: 5, Shuffle: shuffle, TypeSize: 4, LegacyFormat: true}
+			compressed, err := CompressWithOptions(data, opts)
+			if err != nil {
+				t.Fatalf("%s/%s: compress failed: %v", codec, shuffle, err)
+			}
+
+			header, _ := ParseHeader(compressed)
+			if !header.IsLegacy() {
+				t.Errorf("%s/%s: expected legacy chunk", codec, shuffle)
+			}
+			if header.VersionLZ != uint8(codec) || header.Codec() != codec {
+				t.Errorf("%s/%s: codec: VersionLZ=%d Codec()=%s", codec, shuffle, header.VersionLZ, header.Codec())
+			}
+
+			decompressed, err := Decompress(compressed)
+			if err != nil {
+				t.Fatalf("%s/%s: decompress failed: %v", codec, shuffle, err)
+			}
+			if !bytes.Equal(data, decompressed) {
+				t.Errorf("%s/%s: data mismatch", codec, shuffle)
+			}
+		}
+	}
+}
+
+func TestLegacyBitShuffleMemory(t *testing.T) {
+	// Bit shuffled legacy chunks decode in place, into the output alone
+	data := makeFloatData(1 << 20)
+	chunk, err := CompressWithOptions(data, Options{Codec: LZ4, Level: 5, Shuffle: BitShuffle, TypeSize: 4, LegacyFormat: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := make([]byte, 0, len(data))
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	got, err := DecompressAppend(dst, chunk)
+	runtime.ReadMemStats(&after)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("round trip: %v", err)
+	}
+	if n := after.TotalAlloc - before.TotalAlloc; n > uint64(len(data))/8 {
+		t.Errorf("decoding %d bytes allocated %d", len(data), n)
+	}
+}
+
+func TestLegacyMemcpyIgnoresShuffle(t *testing.T) {
+	// go-blosc 1.0.x stored incompressible data unshuffled but kept the
+	// shuffle flag; such chunks must decode to the stored bytes.
+	data := make([]byte, 1000)
+	_, _ = cryptorand.Read(data)
+
+	header := Header{
+		Version:    FormatVersion,
+		VersionLZ:  uint8(ZSTD),
+		Flags:      flagShuffle | flagMemcpy,
+		TypeSize:   4,
+		NBytesOrig: uint32(len(data)),
+		BlockSize:  uint32(len(data)),
+		NBytesComp: uint32(HeaderSize + len(data)),
+	}
+	chunk := append(header.Bytes(), data...)
+
+	decompressed, err := Decompress(chunk)
+	if err != nil {
+		t.Fatalf("decompress failed: %v", err)
+	}
+	if !bytes.Equal(data, decompressed) {
+		t.Error("memcpy chunk was unshuffled")
+	}
+}
+
+func TestSpecMemcpySingleBlock(t *testing.T) {
+	// A spec BloscLZ memcpy chunk of one block has VersionLZ 1, like a
+	// legacy LZ4 chunk, and is read as a spec chunk
+	data := make([]byte, 1000)
+	_, _ = cryptorand.Read(data)
+	for _, flags := range []uint8{flagMemcpy, flagMemcpy | flagShuffle, flagMemcpy | flagBitShuffle} {
+		header := Header{
+			Version:    FormatVersion,
+			VersionLZ:  codecFormatVersion,
+			Flags:      flags,
+			TypeSize:   4,
+			NBytesOrig: uint32(len(data)),
+			BlockSize:  uint32(len(data)),
+			NBytesComp: uint32(HeaderSize + len(data)),
+		}
+		chunk := append(header.Bytes(), data...)
+		h, err := ParseHeader(chunk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.IsLegacy() || h.Codec() != BloscLZ {
+			t.Errorf("flags %#x: legacy %v, codec %s", flags, h.IsLegacy(), h.Codec())
+		}
+		if got, err := Decompress(chunk); err != nil || !bytes.Equal(got, data) {
+			t.Errorf("flags %#x: decompress: %v", flags, err)
+		}
+	}
+}
+
+func TestShuffledIncompressibleRoundTrip(t *testing.T) {
+	data := make([]byte, 10000)
+	_, _ = cryptorand.Read(data)
+
+	for _, legacy := range []bool{false, true} {
+		opts := Options{Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: 4, LegacyFormat: legacy}
+		compressed, err := CompressWithOptions(data, opts)
+		if err != nil {
+			t.Fatalf("compress failed: %v", err)
+		}
+		decompressed, err := Decompress(compressed)
+		if err != nil {
+			t.Fatalf("decompress failed: %v", err)
+		}
+		if !bytes.Equal(data, decompressed) {
+			t.Errorf("legacy=%v: data mismatch", legacy)
+		}
+	}
+}
+
+// =============================================================================
+// Decompression Limit Tests
+// =============================================================================
+
+func TestDecompressLimited(t *testing.T) {
+	data := makeTestData(10000)
+	compressed, err := Compress(data, LZ4, 5, Shuffle1, 4)
+	if err != nil {
+		t.Fatalf("compress failed: %v", err)
+	}
+
+	decompressed, err := DecompressLimited(compressed, len(data))
+	if err != nil {
+		t.Fatalf("decompress at exact limit failed: %v", err)
+	}
+	if !bytes.Equal(data, decompressed) {
+		t.Error("data mismatch")
+	}
+
+	for _, limit := range []int{len(data) - 1, 0, -1} {
+		if _, err := DecompressLimited(compressed, limit); !errors.Is(err, ErrOutputTooLarge) {
+			t.Errorf("limit %d: expected ErrOutputTooLarge, got %v", limit, err)
+		}
+	}
+}
+
+func TestDecompressLimitedRejectsBeforeAllocating(t *testing.T) {
+	// A bare header claiming 4 GB must be refused without allocating the output
+	header := Header{
+		Version:    FormatVersion,
+		VersionLZ:  codecFormatVersion,
+		Flags:      formatLZ4 << flagCodecShift,
+		TypeSize:   1,
+		NBytesOrig: math.MaxUint32,
+		BlockSize:  1 << 20,
+		NBytesComp: HeaderSize + 4096,
+	}
+	chunk := append(header.Bytes(), make([]byte, 4096)...)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := DecompressLimited(chunk, 1<<20)
+	runtime.ReadMemStats(&after)
+
+	if !errors.Is(err, ErrOutputTooLarge) {
+		t.Fatalf("expected ErrOutputTooLarge, got %v", err)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 64<<10 {
+		t.Errorf("allocated %d bytes before rejecting the chunk", allocated)
+	}
+}
+
+func TestDecompressStreamExpansionCapped(t *testing.T) {
+	// A stream that expands far past the size its chunk claims must fail
+	// instead of decoding in full
+	bomb := make([]byte, 64<<20)
+	for _, codec := range []Codec{ZSTD, Snappy} {
+		t.Run(codec.String(), func(t *testing.T) {
+			c, _ := GetCodec(codec)
+			stream, err := c.Compress(bomb, 5)
+			if err != nil {
+				t.Fatalf("compress failed: %v", err)
+			}
+			format, _ := codecFormat(codec)
+
+			const claimed = 4096
+			header := Header{
+				Version:    FormatVersion,
+				VersionLZ:  codecFormatVersion,
+				Flags:      flagDontSplit | format<<flagCodecShift,
+				TypeSize:   1,
+				NBytesOrig: claimed,
+				BlockSize:  claimed,
+			}
+			chunk := binary.LittleEndian.AppendUint32(header.Bytes(), HeaderSize+4)
+			chunk = binary.LittleEndian.AppendUint32(chunk, uint32(len(stream)))
+			chunk = append(chunk, stream...)
+			binary.LittleEndian.PutUint32(chunk[12:16], uint32(len(chunk)))
+
+			var before, after runtime.MemStats
+			runtime.ReadMemStats(&before)
+			_, err = DecompressLimited(chunk, claimed)
+			runtime.ReadMemStats(&after)
+
+			if err == nil {
+				t.Fatal("expected an error for an over-long stream")
+			}
+			if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 4<<20 {
+				t.Errorf("allocated %d bytes decoding a %d-byte chunk", allocated, claimed)
+			}
+		})
+	}
+}
+
+func TestDecompressHeaderBomb(t *testing.T) {
+	// A chunk of a few bytes whose header claims 1 GiB must be rejected
+	// before anything of that size is allocated. The size stays below
+	// MaxInt32 so that 32-bit platforms take the same paths.
+	const claimed = 1 << 30
+	for _, extended := range []bool{false, true} {
+		for _, codec := range []Codec{BloscLZ, LZ4, ZSTD} {
+			format, _ := codecFormat(codec)
+			header := Header{
+				Version:    FormatVersion,
+				VersionLZ:  codecFormatVersion,
+				Flags:      flagDontSplit | format<<flagCodecShift,
+				TypeSize:   1,
+				NBytesOrig: claimed,
+				BlockSize:  claimed,
+			}
+			if extended {
+				header.Version = Blosc2FormatVersion
+				header.Flags |= flagExtended
+			}
+			chunk := binary.LittleEndian.AppendUint32(header.Bytes(), uint32(header.Size()+4))
+			chunk = binary.LittleEndian.AppendUint32(chunk, 4)
+			chunk = append(chunk, 1, 2, 3, 4)
+			binary.LittleEndian.PutUint32(chunk[12:16], uint32(len(chunk)))
+
+			name := codec.String()
+			if extended {
+				name += ", extended"
+			}
+			for call, f := range map[string]func() error{
+				"Decompress":   func() error { _, err := Decompress(chunk); return err },
+				"DecompressTo": func() error { _, err := DecompressTo(io.Discard, chunk); return err },
+				"GetItems":     func() error { _, err := GetItems(chunk, 0, 1); return err },
+				"Validate":     func() error { return Validate(chunk) },
+				"Salvage":      func() error { _, _, err := Salvage(chunk); return err },
+			} {
+				var err error
+				if allocated := allocBytes(func() { err = f() }); allocated > 1<<20 {
+					t.Errorf("%s: %s allocated %d bytes", name, call, allocated)
+				}
+				if !errors.Is(err, ErrInvalidData) {
+					t.Errorf("%s: %s: expected ErrInvalidData, got %v", name, call, err)
+				}
+			}
+		}
+	}
+}
+
+func TestDecompressHugeStreamSize(t *testing.T) {
+	// A stream size of 2 GiB or more is negative as an int on 32-bit
+	// platforms and must still be caught as running past the chunk
+	data := makeTestData(20000)
+	for name, opts := range map[string]Options{
+		"blosc1":   {Codec: LZ4, Level: 5, TypeSize: 1, BlockSize: 4096},
+		"extended": {Codec: LZ4, Level: 5, TypeSize: 1, BlockSize: 4096, Filters: [6]FilterStage{{Filter: FilterByteDelta}}},
+	} {
+		chunk, err := CompressWithOptions(data, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		header, err := ParseHeader(chunk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		start := int(binary.LittleEndian.Uint32(chunk[header.Size():]))
+		binary.LittleEndian.PutUint32(chunk[start:], 0x80000000)
+		if _, err := Decompress(chunk); !errors.Is(err, ErrInvalidData) {
+			t.Errorf("%s: Decompress: expected ErrInvalidData, got %v", name, err)
+		}
+		if err := Validate(chunk); !errors.Is(err, ErrInvalidData) {
+			t.Errorf("%s: Validate: expected ErrInvalidData, got %v", name, err)
+		}
+	}
+}
+
+// =============================================================================
+// Error Diagnostics Tests
+// =============================================================================
+
+func TestBloscErrorLocatesCorruptBlock(t *testing.T) {
+	data := makeTestData(20000)
+	compressed, err := CompressWithOptions(data, Options{Codec: LZ4, Level: 5, Shuffle: NoShuffle, TypeSize: 1, BlockSize: 4096})
+	if err != nil {
+		t.Fatalf("compress failed: %v", err)
+	}
+
+	// Replace the third block's stream with one whose match offset points before the start
+	const block = 2
+	start := int(binary.LittleEndian.Uint32(compressed[HeaderSize+4*block:]))
+	size := int(binary.LittleEndian.Uint32(compressed[start:]))
+	stream := compressed[start+4 : start+4+size]
+	for i := range stream {
+		stream[i] = 0xFF
+	}
+
+	_, err = Decompress(compressed)
+	var berr *BloscError
+	if !errors.As(err, &berr) {
+		t.Fatalf("expected *BloscError, got %T: %v", err, err)
+	}
+	if berr.Stage != StageCodec || berr.Block != block || berr.Offset != start+4 {
+		t.Errorf("got stage %s block %d offset %d, want codec block %d offset %d", berr.Stage, berr.Block, berr.Offset, block, start+4)
+	}
+	if !errors.Is(err, ErrDecompressionFailed) {
+		t.Errorf("expected ErrDecompressionFailed, got %v", err)
+	}
+}
+
+func TestBloscErrorStages(t *testing.T) {
+	data := makeTestData(20000)
+	good, err := CompressWithOptions(data, Options{Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: 4, BlockSize: 4096})
+	if err != nil {
+		t.Fatalf("compress failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		corrupt func(c []byte) []byte
+		stage   Stage
+		block   int
+		offset  int
+		want    error
+	}{
+		{"short", func(c []byte) []byte { return c[:8] }, StageHeader, -1, 0, ErrInvalidHeader},
+		{"truncated", func(c []byte) []byte { return c[:len(c)-10] }, StageHeader, -1, offsetNBytesComp, ErrInvalidData},
+		{"block offset", func(c []byte) []byte {
+			binary.LittleEndian.PutUint32(c[HeaderSize+4:], 1<<30)
+			return c
+		}, StageHeader, 1, HeaderSize + 4, ErrInvalidData},
+		{"both shuffles", func(c []byte) []byte { c[2] |= flagBitShuffle; return c }, StageFilter, -1, offsetFlags, ErrInvalidShuffle},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decompress(tt.corrupt(append([]byte(nil), good...)))
+			var berr *BloscError
+			if !errors.As(err, &berr) {
+				t.Fatalf("expected *BloscError, got %T: %v", err, err)
+			}
+			if berr.Stage != tt.stage || berr.Block != tt.block || berr.Offset != tt.offset {
+				t.Errorf("got stage %s block %d offset %d, want %s block %d offset %d",
+					berr.Stage, berr.Block, berr.Offset, tt.stage, tt.block, tt.offset)
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+// maxWriteRecorder records the largest single write it receives
+type maxWriteRecorder struct {
+	bytes.Buffer
+	largest int
+}
+
+func (r *maxWriteRecorder) Write(p []byte) (int, error) {
+	r.largest = max(r.largest, len(p))
+	return r.Buffer.Write(p)
+}
+
+func TestDecompressTo(t *testing.T) {
+	data := makeFloatData(100000)
+	cases := map[string]struct {
+		data []byte
+		opts Options
+	}{
+		"blocks":  {data, Options{Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: 4, BlockSize: 16384}},
+		"filters": {data, Options{Codec: ZSTD, Level: 5, TypeSize: 4, Filters: [MaxFilters]FilterStage{{Filter: FilterShuffle}, {Filter: FilterByteDelta}}}},
+		"memcpy":  {data[:100], Options{Codec: LZ4, Level: 5, TypeSize: 4}},
+		"legacy":  {data, Options{Codec: ZLIB, Level: 5, Shuffle: Shuffle1, TypeSize: 4, LegacyFormat: true}},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			compressed, err := CompressWithOptions(c.data, c.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var w maxWriteRecorder
+			n, err := DecompressTo(&w, compressed)
+			if err != nil {
+				t.Fatalf("DecompressTo failed: %v", err)
+			}
+			if n != int64(len(c.data)) || !bytes.Equal(w.Bytes(), c.data) {
+				t.Errorf("wrote %d bytes, want %d", n, len(c.data))
+			}
+			if name == "blocks" && w.largest > c.opts.BlockSize {
+				t.Errorf("largest write %d exceeds the block size", w.largest)
+			}
+		})
+	}
+}
+
+func TestDecompressToErrors(t *testing.T) {
+	compressed, err := CompressWithOptions(makeTestData(100000), Options{Codec: LZ4, Level: 5, TypeSize: 1, BlockSize: 8192})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecompressTo(failingWriter{}, compressed); err == nil || err.Error() != "disk full" {
+		t.Errorf("expected the writer's error, got %v", err)
+	}
+
+	corrupt := bytes.Clone(compressed)
+	corrupt[len(corrupt)-10] ^= 0xFF
+	var be *BloscError
+	if _, err := DecompressTo(io.Discard, corrupt[:len(corrupt)-5]); !errors.As(err, &be) {
+		t.Errorf("expected a *BloscError, got %v", err)
+	}
+	if _, err := DecompressTo(io.Discard, compressed[:8]); !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("expected ErrInvalidHeader, got %v", err)
+	}
+}
+
+func TestMaxCompressedSize(t *testing.T) {
+	noise := randomBytes(70000)
+	ramp := makeTestData(70000)
+	filters := [MaxFilters]FilterStage{{Filter: FilterShuffle}, {Filter: FilterByteDelta}}
+	for _, codec := range []Codec{BloscLZ, LZ4, LZ4HC, Snappy, ZLIB, ZSTD} {
+		for _, opts := range []Options{
+			{Codec: codec, Level: 9, Shuffle: Shuffle1, TypeSize: 4},
+			{Codec: codec, Level: 1, Shuffle: BitShuffle, TypeSize: 8, BlockSize: 256},
+			{Codec: codec, Level: 5, TypeSize: 4, BlockSize: 1024, Filters: filters},
+			{Codec: codec, Level: 5, TypeSize: 2, LegacyFormat: true},
+			{Codec: codec, Level: 5, TypeSize: 8, SpecialValues: true},
+			{Codec: codec, Level: 0, Shuffle: Shuffle1, TypeSize: 4},
+			{Codec: codec, Level: 0, Shuffle: AutoShuffle, TypeSize: 4},
+			{Codec: codec, Level: 0, Shuffle: AutoShuffle, TypeSize: TypeSizeAuto},
+			{Codec: codec, Level: 0, TypeSize: 4, BlockSize: 1024, Filters: filters},
+		} {
+			for _, n := range []int{1, 127, 128, 1000, len(noise)} {
+				// Noise, zeros, and a ramp that AutoShuffle shuffles
+				for _, data := range [][]byte{noise[:n], make([]byte, n), ramp[:n]} {
+					chunk, err := CompressWithOptions(data, opts)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if bound := MaxCompressedSize(n, opts); len(chunk) > bound {
+						t.Errorf("%s %+v: %d bytes compressed to %d, bound %d", codec, opts, n, len(chunk), bound)
+					}
+				}
+			}
+		}
+	}
+	if got := MaxCompressedSize(100, Options{}); got != 116 {
+		t.Errorf("MaxCompressedSize(100) = %d, want 116", got)
+	}
+}
+
+func TestStoreLevel(t *testing.T) {
+	data := makeFloatData(100000)
+	plain, err := Compress(data, ZSTD, 0, NoShuffle, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h, _ := ParseHeader(plain); !h.IsMemcpy() || len(plain) != HeaderSize+len(data) {
+		t.Errorf("level 0 without filters: memcpy %v, %d bytes", h.IsMemcpy(), len(plain))
+	}
+
+	filters := [MaxFilters]FilterStage{{Filter: FilterShuffle}, {Filter: FilterByteDelta, Meta: 4}}
+	for _, opts := range []Options{
+		{Codec: LZ4, Shuffle: Shuffle1, TypeSize: 4},
+		{Codec: ZSTD, Shuffle: BitShuffle, TypeSize: 8},
+		{Codec: BloscLZ, TypeSize: 4, Filters: filters},
+	} {
+		chunk, err := CompressWithOptions(data, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h, _ := ParseHeader(chunk)
+		if h.IsMemcpy() || len(chunk) < len(data) || len(chunk) > MaxCompressedSize(len(data), opts) {
+			t.Errorf("%+v: memcpy %v, %d bytes", opts, h.IsMemcpy(), len(chunk))
+		}
+		if err := Validate(chunk); err != nil {
+			t.Errorf("%+v: %v", opts, err)
+		}
+		got, err := Decompress(chunk)
+		if err != nil || !bytes.Equal(got, data) {
+			t.Errorf("%+v: round trip failed: %v", opts, err)
+		}
+
+		// The stored streams recompress like the shuffled input they are
+		opts.Level = 9
+		packed, err := CompressWithOptions(data, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(packed) >= len(chunk) {
+			t.Errorf("%+v: level 9 chunk of %d bytes is no smaller than level 0's %d", opts, len(packed), len(chunk))
+		}
+	}
+
+	legacy, err := CompressWithOptions(data, Options{Codec: LZ4, Shuffle: Shuffle1, TypeSize: 4, LegacyFormat: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h, _ := ParseHeader(legacy); !h.IsMemcpy() {
+		t.Error("legacy level 0 chunk is not memcpy")
+	}
+}
+
+func TestDecompressAppend(t *testing.T) {
+	data := makeFloatData(100000)
+	var chunks [][]byte
+	for _, c := range []struct {
+		data []byte
+		opts Options
+	}{
+		{data, Options{Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: 4, BlockSize: 16384}},
+		{data, Options{Codec: ZSTD, Level: 5, TypeSize: 4, Filters: [MaxFilters]FilterStage{{Filter: FilterShuffle}}}},
+		{data[:100], Options{Codec: LZ4, Level: 5, TypeSize: 4}}, // memcpy
+		{data, Options{Codec: ZLIB, Level: 5, Shuffle: Shuffle1, TypeSize: 4, LegacyFormat: true}},
+	} {
+		compressed, err := CompressWithOptions(c.data, c.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunks = append(chunks, compressed)
+	}
+
+	// Chunks accumulate after the existing contents, in a buffer sized once
+	want := []byte("prefix")
+	dst := make([]byte, 0, 4*len(data))
+	dst = append(dst, want...)
+	for _, compressed := range chunks {
+		size, _ := GetDecompressedSize(compressed)
+		want = append(want, data[:size]...)
+		var err error
+		if dst, err = DecompressAppend(dst, compressed); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !bytes.Equal(dst, want) {
+		t.Errorf("got %d bytes, want %d", len(dst), len(want))
+	}
+
+	// A large enough buffer is decoded into in place
+	before := &dst[:1][0]
+	if dst, _ = DecompressAppend(dst[:0], chunks[0]); &dst[0] != before {
+		t.Error("output buffer was reallocated")
+	}
+
+	// On error, dst comes back unchanged
+	truncated := chunks[0][:len(chunks[0])-5]
+	out, err := DecompressAppend(dst[:3], truncated)
+	if err == nil || len(out) != 3 {
+		t.Errorf("corrupt chunk: got %d bytes, %v", len(out), err)
+	}
+	if out, err := DecompressAppend(nil, chunks[0][:8]); !errors.Is(err, ErrInvalidHeader) || out != nil {
+		t.Errorf("expected ErrInvalidHeader, got %v", err)
+	}
+}
+
+func TestCompressFrom(t *testing.T) {
+	// Compressible blocks followed by enough noise that the chunk falls back
+	// to memcpy after some blocks were compressed
+	fallback := make([]byte, 64*1024)
+	cryptorand.Read(fallback)
+	clear(fallback[:400])
+
+	cases := map[string]struct {
+		data []byte
+		opts Options
+	}{
+		"blocks":   {makeFloatData(100000), Options{Codec: ZSTD, Level: 5, Shuffle: Shuffle1, TypeSize: 4, BlockSize: 16384}},
+		"filters":  {makeFloatData(30000), Options{Codec: LZ4, Level: 5, TypeSize: 4, Filters: [MaxFilters]FilterStage{{Filter: FilterBitShuffle}}}},
+		"small":    {makeTestData(100), Options{Codec: LZ4, Level: 5}},
+		"legacy":   {makeTestData(5000), Options{Codec: ZLIB, Level: 5, LegacyFormat: true}},
+		"fallback": {fallback, Options{Codec: LZ4, Level: 5, BlockSize: 1024}},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			want, err := CompressWithOptions(c.data, c.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := CompressFrom(iotest.OneByteReader(bytes.NewReader(c.data)), int64(len(c.data)), c.opts)
+			if err != nil {
+				t.Fatalf("CompressFrom failed: %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Error("CompressFrom differs from CompressWithOptions")
+			}
+			if name == "fallback" {
+				if header, _ := ParseHeader(got); !header.IsMemcpy() {
+					t.Error("expected a memcpy chunk")
+				}
+			}
+		})
+	}
+}
+
+func TestCompressFromErrors(t *testing.T) {
+	data := makeTestData(10000)
+	if _, err := CompressFrom(bytes.NewReader(data), int64(len(data))+1, DefaultOptions()); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+	if _, err := CompressFrom(bytes.NewReader(nil), 10, DefaultOptions()); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected io.ErrUnexpectedEOF for an empty reader, got %v", err)
+	}
+	if _, err := CompressFrom(bytes.NewReader(data), 0, DefaultOptions()); !errors.Is(err, ErrInvalidData) {
+		t.Errorf("expected ErrInvalidData, got %v", err)
+	}
+	if _, err := CompressFrom(bytes.NewReader(data), 1<<33, DefaultOptions()); !errors.Is(err, ErrDataTooLarge) {
+		t.Errorf("expected ErrDataTooLarge, got %v", err)
+	}
+}
+
+// scaleFloat32s multiplies each float32 in b by f
+func scaleFloat32s(b []byte, f float32) {
+	for i := 0; i+4 <= len(b); i += 4 {
+		v := math.Float32frombits(binary.LittleEndian.Uint32(b[i:]))
+		binary.LittleEndian.PutUint32(b[i:], math.Float32bits(v*f))
+	}
+}
+
+func TestPrefilterPostfilter(t *testing.T) {
+	for _, n := range []int{100, 1000, 100000} {
+		data := makeFloatData(n)
+		scaled := bytes.Clone(data)
+		scaleFloat32s(scaled, 2)
+		input := bytes.Clone(data)
+
+		for _, opts := range []Options{
+			{Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: 4, BlockSize: 4096},
+			{Codec: ZSTD, Level: 9, TypeSize: 4, Filters: [MaxFilters]FilterStage{{Filter: FilterByteDelta, Meta: 4}}},
+			{Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: 4, LegacyFormat: true},
+		} {
+			want, err := CompressWithOptions(scaled, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var next int
+			opts.Hooks = &Hooks{Prefilter: func(block []byte, offset int) {
+				if offset == next {
+					next += len(block)
+				}
+				scaleFloat32s(block, 2)
+			}}
+			chunk, err := CompressWithOptions(data, opts)
+			if err != nil || !bytes.Equal(chunk, want) {
+				t.Errorf("%d floats, %+v: prefiltered chunk differs: %v", n, opts, err)
+			}
+			if next != len(data) {
+				t.Errorf("%d floats: blocks covered %d of %d bytes", n, next, len(data))
+			}
+			if !bytes.Equal(data, input) {
+				t.Fatalf("%d floats: prefilter modified the input", n)
+			}
+			streamed, err := CompressFrom(bytes.NewReader(data), int64(len(data)), opts)
+			if err != nil || !bytes.Equal(streamed, want) {
+				t.Errorf("%d floats, %+v: CompressFrom chunk differs: %v", n, opts, err)
+			}
+
+			got, err := DecompressWithOptions(chunk, DecompressOptions{
+				Postfilter: func(block []byte, offset int) { scaleFloat32s(block, 0.5) },
+			})
+			if err != nil || !bytes.Equal(got, data) {
+				t.Errorf("%d floats, %+v: postfilter round trip: %v", n, opts, err)
+			}
+		}
+	}
+
+	// Input that stops compressing part way is stored prefiltered
+	data := make([]byte, 100000)
+	rand.New(rand.NewSource(1)).Read(data[512:])
+	flip := func(block []byte, offset int) {
+		for i := range block {
+			block[i] ^= byte(offset + i)
+		}
+	}
+	flipped := bytes.Clone(data)
+	flip(flipped, 0)
+	opts := Options{Codec: LZ4, Level: 5, TypeSize: 1, BlockSize: 256, Hooks: &Hooks{Prefilter: flip}}
+	chunk, err := CompressFrom(bytes.NewReader(data), int64(len(data)), opts)
+	if header, _ := ParseHeader(chunk); err != nil || !header.IsMemcpy() || !bytes.Equal(chunk[HeaderSize:], flipped) {
+		t.Errorf("memcpy fallback: %v", err)
+	}
+	got, err := DecompressWithOptions(chunk, DecompressOptions{Postfilter: flip})
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("memcpy postfilter round trip: %v", err)
+	}
+
+	// Postfilters also see special-value chunks
+	chunk, err = NewZeroChunk(1<<20, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = DecompressWithOptions(chunk, DecompressOptions{
+		Postfilter: func(block []byte, offset int) { fillBytes(block, 1) },
+	})
+	if err != nil || !bytes.Equal(got, bytes.Repeat([]byte{1}, 1<<20)) {
+		t.Errorf("special chunk postfilter: %v", err)
+	}
+}
+
+// progressRecorder returns a Progress callback and a check that its calls
+// counted up to total in at least calls steps
+func progressRecorder(t *testing.T) (func(done, total int64), func(name string, total int64, calls int)) {
+	var got [][2]int64
+	record := func(done, total int64) { got = append(got, [2]int64{done, total}) }
+	check := func(name string, total int64, calls int) {
+		t.Helper()
+		defer func() { got = nil }()
+		if len(got) < calls {
+			t.Errorf("%s: %d progress calls, want at least %d", name, len(got), calls)
+			return
+		}
+		for i, c := range got {
+			if c[1] != total || c[0] <= 0 || c[0] > total || i > 0 && c[0] < got[i-1][0] {
+				t.Errorf("%s: progress calls %v, want up to %d", name, got, total)
+				return
+			}
+		}
+		if last := got[len(got)-1]; last[0] != total {
+			t.Errorf("%s: progress ends at %d of %d", name, last[0], total)
+		}
+	}
+	return record, check
+}
+
+func TestProgress(t *testing.T) {
+	progress, check := progressRecorder(t)
+	data := makeTestData(100000)
+	n := int64(len(data))
+	opts := Options{Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: 4, BlockSize: 8192, Hooks: &Hooks{Progress: progress}}
+	nblocks := (len(data) + 8191) / 8192
+
+	chunk, err := CompressWithOptions(data, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("Compress", n, nblocks)
+	if _, err := CompressFrom(bytes.NewReader(data), n, opts); err != nil {
+		t.Fatal(err)
+	}
+	check("CompressFrom", n, nblocks)
+	got, err := DecompressWithOptions(chunk, DecompressOptions{Progress: progress})
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("round trip: %v", err)
+	}
+	check("Decompress", n, nblocks)
+
+	// Chunks not built block by block report once, when done
+	random := makeRandomData(100000)
+	for name, o := range map[string]Options{
+		"memcpy":  opts,
+		"legacy":  {Codec: LZ4, Level: 5, TypeSize: 4, LegacyFormat: true, Hooks: &Hooks{Progress: progress}},
+		"special": {Codec: LZ4, Level: 5, TypeSize: 4, SpecialValues: true, Hooks: &Hooks{Progress: progress}},
+	} {
+		input := random
+		if name == "special" {
+			input = make([]byte, len(random))
+		}
+		chunk, err := CompressWithOptions(input, o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(name, n, 1)
+		if _, err := DecompressWithOptions(chunk, DecompressOptions{Progress: progress}); err != nil {
+			t.Fatal(err)
+		}
+		check(name+" decompress", n, 1)
+	}
+
+	// AppendFrom counts across its chunks
+	sc := NewSChunk(opts)
+	if _, err := sc.AppendFrom(bytes.NewReader(data), n, 32768); err != nil {
+		t.Fatal(err)
+	}
+	check("AppendFrom", n, nblocks)
+}
+
+func TestMaxBufferSize(t *testing.T) {
+	// Sizes past what a chunk holds are refused, not truncated
+	huge := int64(MaxBufferSize) + 1
+	if _, err := CompressFrom(bytes.NewReader(nil), huge, DefaultOptions()); !errors.Is(err, ErrDataTooLarge) {
+		t.Errorf("CompressFrom of %d bytes: got %v", huge, err)
+	}
+	if _, err := CompressFrom(bytes.NewReader(nil), 5<<30, DefaultOptions()); !errors.Is(err, ErrDataTooLarge) {
+		t.Errorf("CompressFrom of 5 GiB: got %v", err)
+	}
+	if _, err := NewZeroChunk(int(huge), 1); !errors.Is(err, ErrDataTooLarge) {
+		t.Errorf("NewZeroChunk of %d bytes: got %v", huge, err)
+	}
+
+	// The largest chunk costs only a header when it is all zeros
+	chunk, err := NewZeroChunk(MaxBufferSize, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size, err := GetDecompressedSize(chunk); err != nil || size != MaxBufferSize {
+		t.Errorf("decompressed size %d, %v", size, err)
+	}
+	if err := Validate(chunk); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBlockChecksums(t *testing.T) {
+	const blockSize = 4096
+	inputs := map[string][]byte{
+		"compressible": makeTestData(10*blockSize + 100),
+		"random":       makeRandomData(3 * blockSize),
+		"tiny":         {42},
+	}
+	for name, data := range inputs {
+		for _, level := range []int{0, 5} {
+			opts := Options{Codec: LZ4, Level: level, Shuffle: Shuffle1, TypeSize: 4, BlockSize: blockSize, BlockChecksums: true}
+			chunk, err := CompressWithOptions(data, opts)
+			if err != nil {
+				t.Fatalf("%s level %d: %v", name, level, err)
+			}
+			h, err := ParseHeader(chunk)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !h.HasBlockChecksums() || h.IsMemcpy() {
+				t.Errorf("%s level %d: checksums %v, memcpy %v", name, level, h.HasBlockChecksums(), h.IsMemcpy())
+			}
+			if bound := MaxCompressedSize(len(data), opts); len(chunk) > bound {
+				t.Errorf("%s level %d: %d bytes exceed the bound of %d", name, level, len(chunk), bound)
+			}
+			got, err := Decompress(chunk)
+			if err != nil || !bytes.Equal(got, data) {
+				t.Errorf("%s level %d: round trip failed: %v", name, level, err)
+			}
+			if err := Validate(chunk); err != nil {
+				t.Errorf("%s level %d: %v", name, level, err)
+			}
+		}
+	}
+
+	// A damaged block fails its checksum, and only that block is lost
+	data := inputs["compressible"]
+	chunk, err := CompressWithOptions(data, Options{Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: 4, BlockSize: blockSize, BlockChecksums: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	damaged := append([]byte(nil), chunk...)
+	damaged[binary.LittleEndian.Uint32(damaged[ExtendedHeaderSize+4*2:])+8] ^= 1
+	_, err = Decompress(damaged)
+	var berr *BloscError
+	if !errors.Is(err, ErrChecksum) || !errors.As(err, &berr) || berr.Block != 2 {
+		t.Fatalf("damaged block: got %v, want ErrChecksum in block 2", err)
+	}
+	if err := Validate(damaged); !errors.Is(err, ErrChecksum) {
+		t.Errorf("Validate: got %v, want ErrChecksum", err)
+	}
+	if got, err := GetItems(damaged, 3*blockSize/4, blockSize/4); err != nil || !bytes.Equal(got, data[3*blockSize:4*blockSize]) {
+		t.Errorf("GetItems past the damaged block: %v", err)
+	}
+	if _, err := GetItems(damaged, 2*blockSize/4, 1); !errors.Is(err, ErrChecksum) {
+		t.Errorf("GetItems in the damaged block: got %v, want ErrChecksum", err)
+	}
+	got, report, err := Salvage(damaged)
+	if err == nil || len(report.Errors) != 1 {
+		t.Fatalf("Salvage: got %v, %d errors", err, len(report.Errors))
+	}
+	checkSalvaged(t, got, data, report)
+
+	// Checksums need the Blosc2 format
+	opts := Options{Codec: LZ4, Level: 5, TypeSize: 4, LegacyFormat: true, BlockChecksums: true}
+	if _, err := CompressWithOptions(data, opts); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("legacy format: got %v, want ErrInvalidOption", err)
+	}
+	if err := opts.Validate(); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Options.Validate: got %v, want ErrInvalidOption", err)
+	}
+}
+
+func TestCompressVec(t *testing.T) {
+	data := makeFloatData(100000)
+	opts := Options{Codec: ZSTD, Level: 5, Shuffle: Shuffle1, TypeSize: 4, BlockSize: 16384}
+	want, err := CompressWithOptions(data, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, bufs := range map[string][][]byte{
+		"one":        {data},
+		"halves":     {data[:200000], data[200000:]},
+		"unaligned":  {data[:7], data[7:16390], nil, data[16390:300001], data[300001:]},
+		"many small": splitEvery(data, 1000),
+	} {
+		got, err := CompressVec(bufs, opts)
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("%s: differs from CompressWithOptions: %v", name, err)
+		}
+	}
+
+	zeros := [][]byte{make([]byte, 4096), make([]byte, 4096)}
+	special := Options{Codec: LZ4, Level: 5, TypeSize: 8, SpecialValues: true}
+	if got, err := CompressVec(zeros, special); err != nil {
+		t.Fatal(err)
+	} else if h, _ := ParseHeader(got); h.Special() != SpecialZero || h.NBytesOrig != 8192 {
+		t.Errorf("zeros: %+v", h)
+	}
+
+	for _, bufs := range [][][]byte{nil, {}, {nil, {}}} {
+		if _, err := CompressVec(bufs, opts); !errors.Is(err, ErrInvalidData) {
+			t.Errorf("%d empty buffers: %v", len(bufs), err)
+		}
+	}
+}
+
+// splitEvery splits data into pieces of n bytes
+func splitEvery(data []byte, n int) [][]byte {
+	var pieces [][]byte
+	for len(data) > n {
+		pieces = append(pieces, data[:n])
+		data = data[n:]
+	}
+	return append(pieces, data)
+}
@@ -0,0 +1,209 @@
+package blosc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+)
+
+// =============================================================================
+// BloscLZ Codec
+// =============================================================================
+
+// BloscLZ is the FastLZ-derived default codec of c-blosc. A stream is a
+// sequence of tokens, always starting and ending with a literal run:
+//
+//	000nnnnn                      literal run of n+1 bytes that follow
+//	lllddddd [l...] dddddddd      match of length l+2, distance d+1
+//	lll11111 [l...] 0xFF hi lo    match of length l+2, distance 8192+(hi<<8|lo)
+//
+// A 3-bit length of 7 is continued by bytes that are added to it, up to one
+// below 255.
+const (
+	blosclzMaxCopy        = 32                              // Longest literal run
+	blosclzMaxDistance    = 8191                            // Escape value of the 13-bit distance
+	blosclzMaxFarDistance = 0xFFFF + blosclzMaxDistance + 1 // Longest distance
+	blosclzHashLog        = 14
+	blosclzMinMatch       = 4
+)
+
+type bloscLZCodec struct{}
+
+func (c *bloscLZCodec) Name() string { return "blosclz" }
+
+func (c *bloscLZCodec) Capabilities() CodecCapabilities {
+	return CodecCapabilities{MinLevel: 1, MaxLevel: 9, DistinctLevels: 1}
+}
+
+// Compress writes a greedy BloscLZ stream. It is readable by c-blosc, but
+// not byte-identical to what c-blosc writes.
+func (c *bloscLZCodec) Compress(data []byte, level int) ([]byte, error) {
+	return blosclzCompress(data), nil
+}
+
+func (c *bloscLZCodec) compressScratch(s *codecScratch, data []byte, level int, params *CodecParams) ([]byte, error) {
+	s.buf = blosclzAppend(s.buf[:0], data)
+	return s.buf, nil
+}
+
+// maxRatio bounds BloscLZ expansion as lz4MaxRatio does LZ4's: match
+// lengths grow by at most 255 a byte
+func (c *bloscLZCodec) maxRatio() int { return 256 }
+
+func (c *bloscLZCodec) Decompress(data []byte, expectedSize int) ([]byte, error) {
+	if err := checkExpectedSize(data, expectedSize, c.maxRatio()); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, expectedSize)
+	if _, err := c.decompressInto(buf, data); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func (c *bloscLZCodec) decompressInto(dst, data []byte) (int, error) {
+	n, err := blosclzDecompress(dst, data)
+	if err != nil {
+		return 0, err
+	}
+	if n != len(dst) {
+		return 0, fmt.Errorf("blosclz decode: got %d bytes, expected %d", n, len(dst))
+	}
+	return n, nil
+}
+
+func blosclzCompress(src []byte) []byte {
+	return blosclzAppend(nil, src)
+}
+
+// blosclzAppend is blosclzCompress appending to dst
+func blosclzAppend(dst, src []byte) []byte {
+	dst = slices.Grow(dst, len(src)+len(src)/blosclzMaxCopy+1)
+	var table [1 << blosclzHashLog]int32 // Position+1 of the last 4 bytes with each hash
+
+	// Matches stop short of the end, so that a literal run ends the stream
+	limit := len(src) - blosclzMinMatch - 1
+	anchor := 0
+	for i := 1; i < limit; {
+		v := binary.LittleEndian.Uint32(src[i:])
+		h := (v * 2654435761) >> (32 - blosclzHashLog)
+		ref := int(table[h]) - 1
+		table[h] = int32(i + 1)
+		if ref < 0 || i-ref > blosclzMaxFarDistance || binary.LittleEndian.Uint32(src[ref:]) != v {
+			i++
+			continue
+		}
+		n := blosclzMinMatch
+		for i+n < len(src)-1 && src[ref+n] == src[i+n] {
+			n++
+		}
+		dst = blosclzAppendLiterals(dst, src[anchor:i])
+		dst = blosclzAppendMatch(dst, n, i-ref)
+		i += n
+		anchor = i
+	}
+	return blosclzAppendLiterals(dst, src[anchor:])
+}
+
+// blosclzAppendLiterals appends lit as literal runs
+func blosclzAppendLiterals(dst, lit []byte) []byte {
+	for len(lit) > 0 {
+		n := min(len(lit), blosclzMaxCopy)
+		dst = append(dst, byte(n-1))
+		dst = append(dst, lit[:n]...)
+		lit = lit[n:]
+	}
+	return dst
+}
+
+// blosclzAppendMatch appends a match of n >= 3 bytes at distance d
+func blosclzAppendMatch(dst []byte, n, d int) []byte {
+	l := n - 2
+	d--
+	far := d >= blosclzMaxDistance
+	ofs := byte(d >> 8)
+	if far {
+		d -= blosclzMaxDistance
+		ofs = 31
+	}
+	if l < 7 {
+		dst = append(dst, byte(l<<5)+ofs)
+	} else {
+		dst = append(dst, 7<<5+ofs)
+		for l -= 7; l >= 255; l -= 255 {
+			dst = append(dst, 255)
+		}
+		dst = append(dst, byte(l))
+	}
+	if far {
+		return append(dst, 255, byte(d>>8), byte(d))
+	}
+	return append(dst, byte(d))
+}
+
+// blosclzDecompress decodes src into dst and returns the decoded size. It
+// fails rather than write past dst.
+func blosclzDecompress(dst, src []byte) (int, error) {
+	if len(src) == 0 {
+		return 0, fmt.Errorf("blosclz decode: empty stream")
+	}
+	ip, op := 1, 0
+	ctrl := int(src[0] & 31) // The first token is a literal run
+	for {
+		if ctrl >= 32 {
+			n := ctrl>>5 - 1
+			ofs := (ctrl & 31) << 8
+			if n == 6 {
+				for {
+					if ip >= len(src) {
+						return op, fmt.Errorf("blosclz decode: truncated match at input offset %d", ip)
+					}
+					code := src[ip]
+					ip++
+					n += int(code)
+					if code != 255 {
+						break
+					}
+				}
+			}
+			if ip >= len(src) {
+				return op, fmt.Errorf("blosclz decode: truncated match at input offset %d", ip)
+			}
+			code := int(src[ip])
+			ip++
+			n += 3
+			d := ofs + code + 1
+			if code == 255 && ofs == 31<<8 {
+				if ip+2 > len(src) {
+					return op, fmt.Errorf("blosclz decode: truncated match at input offset %d", ip)
+				}
+				d = (int(src[ip])<<8 | int(src[ip+1])) + blosclzMaxDistance + 1
+				ip += 2
+			}
+			if d > op || n > len(dst)-op {
+				return op, fmt.Errorf("blosclz decode: invalid match at output offset %d", op)
+			}
+			if d >= n {
+				copy(dst[op:op+n], dst[op-d:])
+			} else {
+				for k := op; k < op+n; k++ {
+					dst[k] = dst[k-d]
+				}
+			}
+			op += n
+		} else {
+			n := ctrl + 1
+			if n > len(dst)-op || n > len(src)-ip {
+				return op, fmt.Errorf("blosclz decode: invalid literal run at output offset %d", op)
+			}
+			copy(dst[op:], src[ip:ip+n])
+			op += n
+			ip += n
+		}
+		if ip >= len(src) {
+			return op, nil
+		}
+		ctrl = int(src[ip])
+		ip++
+	}
+}
@@ -0,0 +1,137 @@
+package blosc
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBloscLZDecodeVectors(t *testing.T) {
+	far := make([]byte, 9000)
+	for i := range far {
+		far[i] = byte(i * 7 / 3)
+	}
+	tests := []struct {
+		name   string
+		stream []byte
+		want   []byte
+	}{
+		{"literals", []byte{2, 'a', 'b', 'c'}, []byte("abc")},
+		// Match of 3+6 bytes at distance 3, overlapping its own output
+		{"overlap", []byte{2, 'a', 'b', 'c', 7 << 5, 0, 2, 0, 'x'}, []byte("abcabcabcabcx")},
+		// Run of one byte, length 5 at distance 1
+		{"run", []byte{0, 'z', 3 << 5, 0, 0, '!'}, []byte("zzzzzz!")},
+		// Long length: 6 + 255 + 4 + 3 bytes
+		{"long", []byte{0, 'q', 7 << 5, 255, 4, 0, 0, 'q'}, bytes.Repeat([]byte("q"), 1+268+1)},
+		// Distance 8192 + 1 with the 16-bit escape
+		{"far", append(blosclzAppendLiterals(nil, far[:8200]), 1<<5|31, 255, 0, 1, 0, '.'),
+			append(append(far[:8200:8200], far[7:10]...), '.')},
+	}
+	for _, tt := range tests {
+		dst := make([]byte, len(tt.want))
+		n, err := blosclzDecompress(dst, tt.stream)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if n != len(tt.want) || !bytes.Equal(dst, tt.want) {
+			t.Errorf("%s: got %q", tt.name, dst[:n])
+		}
+	}
+}
+
+func TestBloscLZRoundTrip(t *testing.T) {
+	inputs := map[string][]byte{
+		"tiny":   []byte("abcd"),
+		"text":   bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog "), 500),
+		"random": randomBytes(50000),
+		"zeros":  make([]byte, 100000),
+		"typed":  makeTestData(70000),
+	}
+	c := &bloscLZCodec{}
+	for name, data := range inputs {
+		stream, err := c.Compress(data, 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stream[0] >= 32 || stream[lastToken(t, stream)] >= 32 {
+			t.Errorf("%s: stream must start and end with literal runs", name)
+		}
+		got, err := c.Decompress(stream, len(data))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("%s: round trip differs", name)
+		}
+	}
+	if stream, _ := c.Compress(inputs["zeros"], 5); len(stream) > 1000 {
+		t.Errorf("zeros compressed to %d bytes", len(stream))
+	}
+}
+
+// lastToken returns the offset of the control byte of the last token of a
+// stream written by blosclzCompress
+func lastToken(t *testing.T, stream []byte) int {
+	t.Helper()
+	last := 0
+	for ip := 0; ip < len(stream); {
+		last = ip
+		ctrl := int(stream[ip])
+		ip++
+		if ctrl < 32 {
+			ip += ctrl + 1
+			continue
+		}
+		if ctrl>>5 == 7 {
+			for stream[ip] == 255 {
+				ip++
+			}
+			ip++
+		}
+		if ctrl&31 == 31 && stream[ip] == 255 {
+			ip += 2
+		}
+		ip++
+	}
+	return last
+}
+
+func TestBloscLZChunks(t *testing.T) {
+	data := makeTestData(200000)
+	for _, shuffle := range []Shuffle{NoShuffle, Shuffle1, BitShuffle} {
+		chunk, err := CompressWithOptions(data, Options{Codec: BloscLZ, Level: 5, Shuffle: shuffle, TypeSize: 4})
+		if err != nil {
+			t.Fatal(err)
+		}
+		header, _ := ParseHeader(chunk)
+		if header.Codec() != BloscLZ || header.IsLegacy() || header.IsMemcpy() {
+			t.Errorf("%s: codec %s, legacy %v, memcpy %v", shuffle, header.Codec(), header.IsLegacy(), header.IsMemcpy())
+		}
+		if err := Validate(chunk); err != nil {
+			t.Fatal(err)
+		}
+		got, err := Decompress(chunk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("%s: round trip differs", shuffle)
+		}
+	}
+}
+
+func TestBloscLZCorrupt(t *testing.T) {
+	for name, stream := range map[string][]byte{
+		"empty":          {},
+		"long literal":   {9, 'a', 'b'},
+		"before start":   {0, 'a', 1 << 5, 4, 0},
+		"truncated":      {0, 'a', 1 << 5},
+		"truncated long": {0, 'a', 7 << 5, 255},
+		"truncated far":  {0, 'a', 1<<5 | 31, 255, 0},
+		"overflow":       {0, 'a', 7 << 5, 200, 0, 0, 'b'},
+	} {
+		dst := make([]byte, 64)
+		if _, err := blosclzDecompress(dst, stream); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package blosc
+
+import (
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestBuildTagDeps checks that each codec build tag keeps the packages its
+// codec needs out of the build
+func TestBuildTagDeps(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs go list")
+	}
+	gotool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+	for tag, banned := range map[string][]string{
+		"blosc_nozlib":   {"compress/flate", "compress/zlib", "archive/zip"},
+		"blosc_nozstd":   {"github.com/klauspost/compress/zstd"},
+		"blosc_nosnappy": {"github.com/klauspost/compress/snappy"},
+	} {
+		out, err := exec.Command(gotool, "list", "-deps", "-tags", tag, ".").Output()
+		if err != nil {
+			t.Fatalf("go list -tags %s: %v", tag, err)
+		}
+		deps := strings.Fields(string(out))
+		for _, pkg := range banned {
+			if slices.Contains(deps, pkg) {
+				t.Errorf("-tags %s links %s", tag, pkg)
+			}
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package blosc
+
+import (
+	"container/list"
+	"sync"
+)
+
+// chunkCache keeps recently decompressed chunks, by chunk index, within a
+// byte budget, evicting the least recently used. It is safe for concurrent
+// use, and a nil cache holds nothing.
+type chunkCache struct {
+	mu      sync.Mutex
+	budget  int64
+	size    int64     // Bytes held
+	lru     list.List // Of *cacheEntry, most recently used first
+	entries map[int]*list.Element
+}
+
+type cacheEntry struct {
+	index int
+	data  []byte
+}
+
+// newChunkCache returns a cache of up to budget bytes, or nil if budget is
+// not positive
+func newChunkCache(budget int64) *chunkCache {
+	if budget <= 0 {
+		return nil
+	}
+	return &chunkCache{budget: budget, entries: make(map[int]*list.Element)}
+}
+
+// get returns a copy of chunk i, if cached
+func (c *chunkCache) get(i int) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[i]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(e)
+	return append([]byte(nil), e.Value.(*cacheEntry).data...), true
+}
+
+// put caches a copy of chunk i, unless it alone exceeds the budget
+func (c *chunkCache) put(i int, data []byte) {
+	if c == nil || int64(len(data)) > c.budget {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removeLocked(i)
+	c.entries[i] = c.lru.PushFront(&cacheEntry{index: i, data: append([]byte(nil), data...)})
+	c.size += int64(len(data))
+	for c.size > c.budget {
+		c.removeLocked(c.lru.Back().Value.(*cacheEntry).index)
+	}
+}
+
+// remove drops chunk i
+func (c *chunkCache) remove(i int) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removeLocked(i)
+}
+
+func (c *chunkCache) removeLocked(i int) {
+	if e, ok := c.entries[i]; ok {
+		c.size -= int64(len(e.Value.(*cacheEntry).data))
+		c.lru.Remove(e)
+		delete(c.entries, i)
+	}
+}
+
+// clear drops every chunk, as when chunk indexes change
+func (c *chunkCache) clear() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lru.Init()
+	clear(c.entries)
+	c.size = 0
+}
+
+// SetCache keeps up to maxBytes of recently decompressed chunks in memory,
+// so that DecompressChunk returns a copy of a cached chunk instead of
+// decompressing it again, which pays off when the same chunks are read over
+// and over, as in interactive viewers. Least recently used chunks are
+// evicted first. A maxBytes of 0 disables the cache, which is the default.
+func (s *SChunk) SetCache(maxBytes int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cache = newChunkCache(maxBytes)
+}
+
+// SetCache keeps up to maxBytes of recently decompressed chunks in memory,
+// as SChunk.SetCache does. Call it before using the frame concurrently.
+func (f *Frame) SetCache(maxBytes int64) {
+	f.cache = newChunkCache(maxBytes)
+}
+
+// SetStoredCache keeps up to maxBytes of chunks, as stored, that a frame
+// opened with OpenFrameReaderAt has read, so that reading a chunk again, in
+// whole or in part with GetItems, fetches nothing from its io.ReaderAt.
+// Unlike SetCache it holds compressed bytes, so a budget holds more chunks,
+// at the cost of decompressing them on each read; the two can be used
+// together. Other frames hold their chunks in memory already, and the cache
+// does nothing for them. Call it before using the frame concurrently.
+func (f *Frame) SetStoredCache(maxBytes int64) {
+	f.storedCache = newChunkCache(maxBytes)
+}
@@ -0,0 +1,200 @@
+package blosc
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestChunkCacheLRU(t *testing.T) {
+	c := newChunkCache(300)
+	for i := 0; i < 3; i++ {
+		c.put(i, bytes.Repeat([]byte{byte(i)}, 100))
+	}
+	c.get(0) // Chunk 1 is now the least recently used
+	c.put(3, make([]byte, 100))
+	if _, ok := c.get(1); ok {
+		t.Error("least recently used chunk was not evicted")
+	}
+	for _, i := range []int{0, 2, 3} {
+		if _, ok := c.get(i); !ok {
+			t.Errorf("chunk %d was evicted", i)
+		}
+	}
+	if c.size != 300 {
+		t.Errorf("size %d", c.size)
+	}
+
+	// Copies go in and out
+	data, _ := c.get(2)
+	data[0] = 99
+	if again, _ := c.get(2); again[0] != 2 {
+		t.Error("modifying a returned chunk changed the cache")
+	}
+
+	c.put(4, make([]byte, 301))
+	if _, ok := c.get(4); ok {
+		t.Error("cached a chunk larger than the budget")
+	}
+	c.remove(0)
+	c.put(2, make([]byte, 50))
+	if c.size != 150 || len(c.entries) != 2 {
+		t.Errorf("size %d in %d entries", c.size, len(c.entries))
+	}
+	c.clear()
+	if c.size != 0 || c.lru.Len() != 0 {
+		t.Errorf("size %d after clear", c.size)
+	}
+
+	// A disabled cache holds nothing
+	none := newChunkCache(0)
+	none.put(0, []byte{1})
+	if _, ok := none.get(0); ok {
+		t.Error("nil cache returned a chunk")
+	}
+	none.remove(0)
+	none.clear()
+}
+
+func TestSChunkCache(t *testing.T) {
+	sc := NewSChunk(Options{Codec: LZ4, Level: 5})
+	sc.SetCache(1 << 20)
+	want := [][]byte{makeTestData(1000), makeTestData(2000), makeTestData(3000)}
+	var chunks [][]byte
+	for _, data := range want {
+		chunk, err := Compress(data, LZ4, 5, NoShuffle, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := sc.AppendChunk(chunk); err != nil {
+			t.Fatal(err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	checkChunks(t, sc, want)
+
+	// Cached chunks are not decompressed again: the SChunk holds the
+	// appended chunks without copying, so corrupting them goes unnoticed
+	for _, chunk := range chunks {
+		clear(chunk[HeaderSize:])
+	}
+	checkChunks(t, sc, want)
+
+	// Edits drop what they invalidate
+	updated := makeFloatData(100)
+	chunk, _ := Compress(updated, LZ4, 5, NoShuffle, 1)
+	if err := sc.UpdateChunk(1, chunk); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := sc.DecompressChunk(1); err != nil || !bytes.Equal(got, updated) {
+		t.Errorf("updated chunk: %v", err)
+	}
+	chunk, _ = Compress(want[0], LZ4, 5, NoShuffle, 1)
+	if err := sc.InsertChunk(0, chunk); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := sc.DecompressChunk(2); err != nil || !bytes.Equal(got, updated) {
+		t.Errorf("chunk after insert: %v", err)
+	}
+	if err := sc.DeleteChunk(0); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := sc.DecompressChunk(1); err != nil || !bytes.Equal(got, updated) {
+		t.Errorf("chunk after delete: %v", err)
+	}
+}
+
+func TestFrameCache(t *testing.T) {
+	sc := NewSChunk(Options{Codec: ZSTD, Level: 3})
+	var want [][]byte
+	for i := 0; i < 8; i++ {
+		data := makeTestData(10000 + i)
+		if _, err := sc.AppendBuffer(data); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, data)
+	}
+	var buf bytes.Buffer
+	if _, err := sc.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	f, err := OpenFrame(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A budget of three chunks, read concurrently
+	f.SetCache(3 * 10010)
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for round := 0; round < 3; round++ {
+				for i := range want {
+					got, err := f.DecompressChunk(i)
+					if err != nil || !bytes.Equal(got, want[i]) {
+						t.Errorf("chunk %d: %v", i, err)
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if f.cache.size > f.cache.budget || f.cache.lru.Len() > 3 {
+		t.Errorf("cache holds %d bytes in %d chunks", f.cache.size, f.cache.lru.Len())
+	}
+}
+
+func TestFrameStoredCache(t *testing.T) {
+	sc, want := makeSChunk(t, 4)
+	frame := mustFrameBytes(t, sc)
+	r := &rangeReader{data: frame}
+	f, err := OpenFrameReaderAt(r, int64(len(frame)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, length, _ := f.ChunkOffset(1)
+	f.SetStoredCache(2*length + 1)
+
+	// Repeated partial reads of a chunk fetch it once
+	r.ranges = nil
+	for _, start := range []int{10, 500, 10} {
+		got, err := f.GetItems(1, start, 100)
+		if err != nil || !bytes.Equal(got, want[1][4*start:4*(start+100)]) {
+			t.Fatalf("items %d of chunk 1: %v", start, err)
+		}
+	}
+	if got, err := f.DecompressChunk(1); err != nil || !bytes.Equal(got, want[1]) {
+		t.Fatalf("chunk 1: %v", err)
+	}
+	if len(r.ranges) != 1 {
+		t.Errorf("fetched %v, want chunk 1 once", r.ranges)
+	}
+
+	// The budget holds two chunks of that size, so reading two others
+	// evicts chunk 1
+	for _, i := range []int{0, 2, 1} {
+		if _, err := f.GetItems(i, 0, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(r.ranges) != 4 {
+		t.Errorf("fetched %d ranges, want 4 after eviction", len(r.ranges))
+	}
+
+	// Frames in memory need no stored cache
+	mem, err := OpenFrame(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mem.SetStoredCache(1 << 20)
+	if got, err := mem.GetItems(3, 0, 3); err != nil || !bytes.Equal(got, want[3][:12]) {
+		t.Errorf("GetItems of an in-memory frame: %v", err)
+	}
+	if _, err := mem.GetItems(4, 0, 1); !errors.Is(err, ErrChunkIndex) {
+		t.Errorf("chunk 4 of 4: %v", err)
+	}
+}
@@ -0,0 +1,248 @@
+package blosc
+
+import (
+	"fmt"
+	"slices"
+)
+
+// chunkDelta is the state of SetChunkDelta, guarded by SChunk.encMu
+type chunkDelta struct {
+	interval int // Every interval-th chunk is stored whole; 0 disables deltas
+
+	// Decoded content of the chunk last appended, at index last in storage
+	// lastKey, so that the next chunk need not decode it; nil if unknown
+	content []byte
+	last    int
+	lastKey storageKey
+}
+
+// SetChunkDelta makes AppendBuffer, AppendBufferWith and AppendFrom store
+// chunks as the XOR of their data with the decoded content of the chunk
+// before them, which for snapshots of a slowly changing state is mostly
+// zeros and compresses far better than the state itself. Every
+// keyframeInterval-th chunk, by index, is stored whole, so decompressing a
+// chunk decodes at most keyframeInterval chunks; SetCache keeps decoded
+// chunks for the ones after them to use. An interval below 2 stores every
+// chunk whole again, the default; chunks already stored are kept as they
+// are.
+//
+// Such chunks carry FilterChunkDelta and are decoded against the chunk
+// before them by DecompressChunk, Frame.DecompressChunk and Frame.GetItems,
+// but cannot be decoded on their own, as by Decompress or LazyChunk.Bytes.
+// Chunks compressed with FilterFloatQuantize or a Prefilter, which change the
+// data, and chunks whose options leave no free filter slot, are stored
+// whole. Updating, inserting or deleting a chunk stores the chunk after it
+// whole, if it was a delta against the chunk that changed.
+func (s *SChunk) SetChunkDelta(keyframeInterval int) {
+	s.encMu.Lock()
+	defer s.encMu.Unlock()
+	s.delta = chunkDelta{interval: keyframeInterval}
+}
+
+// deltaReference returns the decoded content of the last chunk of s, which
+// the chunk to append next, compressed with opts, is to be stored against,
+// and that chunk's index. It returns no content for a chunk to be stored
+// whole. The caller holds s.encMu.
+func (s *SChunk) deltaReference(opts Options) ([]byte, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	n := len(s.chunks)
+	d := &s.delta
+	if d.interval < 2 || n%d.interval == 0 || !deltaCapable(opts) {
+		return nil, n, nil
+	}
+	if d.content != nil && d.last == n-1 && s.chunks[n-1].key() == d.lastKey {
+		return d.content, n, nil
+	}
+	ref, err := s.decompress(n - 1)
+	return ref, n, err
+}
+
+// appended notes data, compressed with opts, as the content of chunk i,
+// stored as c, for the next chunk to be stored against
+func (d *chunkDelta) appended(c storedChunk, i int, data []byte, opts Options) {
+	d.content = nil
+	if d.interval >= 2 && deltaCapable(opts) {
+		d.content, d.last, d.lastKey = slices.Clone(data), i, c.key()
+	}
+}
+
+// deltaCapable reports whether chunks compressed with opts decode to the
+// data they were compressed from and can carry FilterChunkDelta
+func deltaCapable(opts Options) bool {
+	_, ok := codecFormat(opts.Codec)
+	return ok && !opts.LegacyFormat && opts.prefilter() == nil && !quantizes(opts.Filters)
+}
+
+// encodeDelta compresses data as a FilterChunkDelta chunk against ref, the
+// decoded content of the chunk before it, or whole if opts leave no filter
+// slot for it
+func (e *chunkEncoder) encodeDelta(data, ref []byte, opts Options) ([]byte, error) {
+	whole := opts
+	diff := slices.Clone(data)
+	xorBytes(diff, ref)
+
+	// Settings picked from the data are picked from the difference, before
+	// the shuffle mode turns into a filter slot. Special-value chunks have
+	// no filter slots, so the difference is always compressed.
+	opts, err := normalizeOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.TypeSize == TypeSizeAuto {
+		opts.TypeSize = pickTypeSize(diff, opts)
+	}
+	if opts.Shuffle == AutoShuffle {
+		opts.Shuffle = pickShuffle(diff, opts)
+	}
+	slots := opts.Filters
+	if slots == ([MaxFilters]FilterStage{}) {
+		slots = shuffleSlots(opts.Shuffle, opts.TypeSize)
+	}
+	free := slices.Index(slots[:], FilterStage{})
+	if free < 0 {
+		return e.encode(data, whole)
+	}
+	slots[free] = FilterStage{Filter: FilterChunkDelta}
+	opts.Filters = slots
+	opts.SpecialValues = false
+
+	e.quantError = -1
+	env := filterEnv{chunkDelta: true}
+	if e.env != nil {
+		env = *e.env
+		env.chunkDelta = true
+	}
+	return compressWithEnv(diff, opts, &env)
+}
+
+// checkDeltaFirst reports a FilterChunkDelta chunk, with header h, about to
+// become chunk i when that is chunk 0, which has no chunk before it
+func checkDeltaFirst(i int, h *Header) error {
+	if i == 0 && isChunkDelta(h) {
+		return fmt.Errorf("%w: a %s chunk cannot be chunk 0", ErrInvalidFilter, FilterChunkDelta)
+	}
+	return nil
+}
+
+// follower decodes chunk i, if it is a FilterChunkDelta chunk, ahead of an
+// edit of the chunk before it, for rekey to store whole after the edit. It
+// returns nil for other chunks. The caller holds s.mu.
+func (s *SChunk) follower(i int) ([]byte, error) {
+	if i >= len(s.chunks) {
+		return nil, nil
+	}
+	chunk, err := s.chunk(i)
+	if err != nil {
+		return nil, err
+	}
+	if header, err := ParseHeader(chunk); err != nil || !isChunkDelta(header) {
+		return nil, nil
+	}
+	return s.decompress(i)
+}
+
+// rekey stores data, the content follower decoded, whole as chunk i. It does
+// nothing for nil data. The caller holds s.encMu and s.mu.
+func (s *SChunk) rekey(i int, data []byte) error {
+	if data == nil {
+		return nil
+	}
+	chunk, err := s.enc.encode(data, s.enc.opts)
+	if err != nil {
+		return err
+	}
+	if err := s.setChunk(i, chunk, len(data)); err != nil {
+		return err
+	}
+	if s.enc.quantError >= 0 {
+		s.noteQuantizeError(s.enc.quantError)
+	}
+	return nil
+}
+
+// xorBytes XORs the bytes of dst with those of ref, as far as both go
+func xorBytes(dst, ref []byte) {
+	ref = ref[:min(len(dst), len(ref))]
+	for i, b := range ref {
+		dst[i] ^= b
+	}
+}
+
+// chunkDeltaStage is FilterChunkDelta in the filter pipeline, where it only
+// copies: the SChunk or Frame takes the difference before compression and
+// undoes it after decompression
+func chunkDeltaStage(dst, src []byte, typeSize int, meta uint8, env *filterEnv) error {
+	if env == nil || !env.chunkDelta {
+		return errChunkDelta()
+	}
+	copy(dst, src)
+	return nil
+}
+
+// checkChunkDelta reports a FilterChunkDelta chunk decoded without the chunk
+// before it
+func checkChunkDelta(h *Header, env *filterEnv) error {
+	if (env == nil || !env.chunkDelta) && isChunkDelta(h) {
+		return errChunkDelta()
+	}
+	return nil
+}
+
+func errChunkDelta() error {
+	return fmt.Errorf("%w: a %s chunk is decoded against the chunk before it, through its SChunk or Frame", ErrInvalidFilter, FilterChunkDelta)
+}
+
+// isChunkDelta reports whether a chunk with header h carries FilterChunkDelta
+func isChunkDelta(h *Header) bool {
+	return slices.ContainsFunc(h.Filters[:], func(s FilterStage) bool { return s.Filter == FilterChunkDelta })
+}
+
+// decompressChained decompresses chunk i of a container whose chunks get
+// returns, decoding FilterChunkDelta chunks against the chunks before them,
+// back to one stored whole or held decoded in cache
+func decompressChained(i int, get func(int) ([]byte, error), cache *chunkCache, env *filterEnv) ([]byte, error) {
+	var deltas [][]byte // Delta chunks from i down
+	var data []byte
+	for j := i; ; j-- {
+		if j < i {
+			if cached, ok := cache.get(j); ok {
+				data = cached
+				break
+			}
+		}
+		chunk, err := get(j)
+		if err != nil {
+			return nil, err
+		}
+		header, err := ParseHeader(chunk)
+		if err != nil {
+			return nil, headerError(0, err)
+		}
+		if !isChunkDelta(header) {
+			if data, err = decompressBackend(nil, chunk, 0, -1, env); err != nil {
+				return nil, err
+			}
+			break
+		}
+		if j == 0 {
+			return nil, fmt.Errorf("%w: chunk 0 is a %s chunk", ErrInvalidFilter, FilterChunkDelta)
+		}
+		deltas = append(deltas, chunk)
+	}
+
+	deltaEnv := filterEnv{chunkDelta: true}
+	if env != nil {
+		deltaEnv = *env
+		deltaEnv.chunkDelta = true
+	}
+	for k := len(deltas) - 1; k >= 0; k-- {
+		diff, err := decompressBackend(nil, deltas[k], 0, -1, &deltaEnv)
+		if err != nil {
+			return nil, err
+		}
+		xorBytes(diff, data)
+		data = diff
+	}
+	return data, nil
+}
@@ -0,0 +1,144 @@
+package blosc
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"slices"
+	"testing"
+)
+
+// snapshots returns n states of a slowly changing float64 field, each
+// differing from the one before in a few elements
+func snapshots(n, size int) [][]byte {
+	state := floats64(size)
+	var out [][]byte
+	for i := 0; i < n; i++ {
+		for j := 0; j < 16; j++ {
+			k := (i*7919 + j*104729) % size
+			v := math.Float64frombits(binary.LittleEndian.Uint64(state[8*k:]))
+			binary.LittleEndian.PutUint64(state[8*k:], math.Float64bits(v+float64(i)))
+		}
+		out = append(out, slices.Clone(state))
+	}
+	return out
+}
+
+func TestSChunkChunkDelta(t *testing.T) {
+	opts := Options{Codec: ZSTD, Level: 5, Shuffle: Shuffle1, TypeSize: 8}
+	want := snapshots(20, 1<<13)
+	plain := NewSChunk(opts)
+	sc := NewSChunk(opts)
+	sc.SetChunkDelta(8)
+	for _, data := range want {
+		if _, err := plain.AppendBuffer(data); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := sc.AppendBuffer(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkChunks(t, sc, want)
+	if sc.CBytes()*3 > plain.CBytes() {
+		t.Errorf("deltas take %d bytes, whole chunks %d", sc.CBytes(), plain.CBytes())
+	}
+	for i := range want {
+		chunk, _ := sc.Chunk(i)
+		header, _ := ParseHeader(chunk)
+		if isChunkDelta(header) != (i%8 != 0) {
+			t.Errorf("chunk %d: delta %v", i, isChunkDelta(header))
+		}
+		if _, err := Decompress(chunk); i%8 != 0 && !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("chunk %d decompressed alone: %v", i, err)
+		}
+	}
+
+	// Frames decode the deltas too, with or without a cache
+	f, err := OpenFrame(mustFrameBytes(t, sc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkChunks(t, f, want)
+	f.SetCache(1 << 20)
+	checkChunks(t, f, want)
+	items, err := f.GetItems(13, 100, 5)
+	if err != nil || !slices.Equal(items, want[13][800:840]) {
+		t.Errorf("GetItems of a delta chunk: %v", err)
+	}
+
+	// Edits store the chunk after the edited one whole
+	edited := slices.Clone(want[4])
+	edited[0] ^= 0xff
+	chunk, err := CompressWithOptions(edited, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sc.UpdateChunk(4, chunk); err != nil {
+		t.Fatal(err)
+	}
+	want[4] = edited
+	checkChunks(t, sc, want)
+	if err := sc.DeleteChunk(10); err != nil {
+		t.Fatal(err)
+	}
+	want = slices.Delete(want, 10, 11)
+	checkChunks(t, sc, want)
+	if err := sc.InsertChunk(2, chunk); err != nil {
+		t.Fatal(err)
+	}
+	want = slices.Insert(want, 2, edited)
+	checkChunks(t, sc, want)
+	if err := sc.DeleteChunk(0); err != nil {
+		t.Fatal(err)
+	}
+	want = want[1:]
+	checkChunks(t, sc, want)
+
+	// Appends after edits decode the last chunk for their reference
+	next := snapshots(21, 1<<13)[20]
+	if _, err := sc.AppendBuffer(next); err != nil {
+		t.Fatal(err)
+	}
+	want = append(want, next)
+	checkChunks(t, sc, want)
+}
+
+func TestChunkDeltaErrors(t *testing.T) {
+	opts := Options{Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: 8}
+	want := snapshots(2, 1024)
+	sc := NewSChunk(opts)
+	sc.SetChunkDelta(4)
+	for _, data := range want {
+		if _, err := sc.AppendBuffer(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	delta, _ := sc.Chunk(1)
+	if _, err := NewSChunk(opts).AppendChunk(delta); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("delta chunk appended as chunk 0: %v", err)
+	}
+	if err := sc.UpdateChunk(0, delta); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("delta chunk updated as chunk 0: %v", err)
+	}
+	if _, err := GetItems(delta, 0, 1); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("GetItems of a delta chunk alone: %v", err)
+	}
+	opts.Filters = [MaxFilters]FilterStage{{Filter: FilterChunkDelta}}
+	if _, err := CompressWithOptions(want[0], opts); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("FilterChunkDelta in Options.Filters: %v", err)
+	}
+
+	// Options that change the data store chunks whole
+	quantized := NewSChunk(Options{Codec: LZ4, Level: 5, TypeSize: 8, ErrorBound: ErrorBound{Absolute: 1e-3},
+		Filters: [MaxFilters]FilterStage{{Filter: FilterFloatQuantize}, {Filter: FilterShuffle}}})
+	quantized.SetChunkDelta(4)
+	for _, data := range want {
+		if _, err := quantized.AppendBuffer(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	chunk, _ := quantized.Chunk(1)
+	if header, _ := ParseHeader(chunk); isChunkDelta(header) {
+		t.Error("quantized chunk stored as a delta")
+	}
+}
@@ -0,0 +1,193 @@
+package blosc
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"sync"
+)
+
+// ChunkStore holds the chunks of an SChunk under int64 keys, so super-chunks
+// can live in an object store, a key-value database or anywhere else. Keys
+// identify stored chunks, not chunk positions: the SChunk numbers its chunks
+// as they are stored and keeps their order in an index, stored as a sparse
+// frame index under IndexKey, so inserting or deleting a chunk rewrites only
+// that chunk and the index. A chunk is never overwritten while an index
+// refers to it, and is deleted only after a new index replaces it.
+type ChunkStore interface {
+	// Get returns the bytes stored under key, or an error wrapping
+	// fs.ErrNotExist if there are none. The SChunk does not modify them.
+	Get(key int64) ([]byte, error)
+	// Put stores chunk under key, replacing anything there. Put under
+	// IndexKey must replace the index atomically for commits to be atomic.
+	Put(key int64, chunk []byte) error
+	// Delete removes what is stored under key. Deleting a missing key is not
+	// an error.
+	Delete(key int64) error
+}
+
+// IndexKey is the ChunkStore key of an SChunk's index.
+const IndexKey int64 = -1
+
+// storeBacking keeps the chunks of an SChunk in a ChunkStore
+type storeBacking struct {
+	store    ChunkStore
+	next     int64   // Key of the next chunk
+	released []int64 // Chunks to delete once no index refers to them
+}
+
+// write stores p under a new key and returns it
+func (b *storeBacking) write(p []byte) (int64, error) {
+	key := b.next
+	if err := b.store.Put(key, p); err != nil {
+		return 0, err
+	}
+	b.next++
+	return key, nil
+}
+
+// read returns the first length bytes of the chunk under key
+func (b *storeBacking) read(key, length int64) ([]byte, error) {
+	p, err := b.store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(p)) < length {
+		return nil, fmt.Errorf("%w: chunk %08X has %d bytes, index says %d", ErrInvalidFrame, key, len(p), length)
+	}
+	return p[:length], nil
+}
+
+func (b *storeBacking) release(key int64) {
+	b.released = append(b.released, key)
+}
+
+// commit stores a sparse frame index, then deletes the chunks it no longer
+// refers to
+func (b *storeBacking) commit(trailer []byte) error {
+	index := append(frameHeader(frameHeaderSize, len(trailer), frameSparse), trailer...)
+	if err := b.store.Put(IndexKey, index); err != nil {
+		return err
+	}
+	for len(b.released) > 0 {
+		if err := b.store.Delete(b.released[0]); err != nil {
+			return err
+		}
+		b.released = b.released[1:]
+	}
+	return nil
+}
+
+func (b *storeBacking) close() error {
+	return nil
+}
+
+// CreateSChunkStore returns an empty SChunk whose chunks are kept in store,
+// compressed from appended buffers with opts. An SChunk already in store is
+// replaced, and its chunks deleted, once the new empty index is stored.
+// Chunks are stored as they are appended; Sync and Close commit the index,
+// along with the metalayers, so that OpenSChunkStore sees them.
+func CreateSChunkStore(store ChunkStore, opts Options) (*SChunk, error) {
+	b := &storeBacking{store: store}
+	if old, err := readStoreIndex(store); err == nil {
+		for _, e := range old.chunks {
+			b.release(e.offset)
+			b.next = max(b.next, e.offset+1)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return createSChunk(b, opts)
+}
+
+// createSChunk returns an empty SChunk in backing b, committed
+func createSChunk(b *storeBacking, opts Options) (*SChunk, error) {
+	s := NewSChunk(opts)
+	s.backing = b
+	s.dirty = true
+	if err := s.Sync(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// OpenSChunkStore opens the SChunk kept in store to read its chunks and
+// append new ones, compressed with opts. Chunks stored after the last commit
+// are ignored. An encrypted SChunk needs SetKeys before chunks can be read
+// or appended.
+func OpenSChunkStore(store ChunkStore, opts Options) (*SChunk, error) {
+	frame, err := readStoreIndex(store)
+	if err != nil {
+		return nil, err
+	}
+	b := &storeBacking{store: store}
+	for _, e := range frame.chunks {
+		b.next = max(b.next, e.offset+1)
+	}
+	s := openSChunkIndex(frame, opts)
+	s.backing = b
+	return s, nil
+}
+
+// readStoreIndex reads and parses the index in store
+func readStoreIndex(store ChunkStore) (*Frame, error) {
+	index, err := store.Get(IndexKey)
+	if err != nil {
+		return nil, err
+	}
+	offset, length, flags, err := parseFrameHeader(index, int64(len(index)), true)
+	if err != nil {
+		return nil, err
+	}
+	var frame Frame
+	if err := frame.parseTrailer(index[offset:offset+length], offset, flags); err != nil {
+		return nil, err
+	}
+	return &frame, nil
+}
+
+// MemStore is an in-memory ChunkStore. It keeps chunks without copying and
+// is safe for concurrent use.
+type MemStore struct {
+	mu     sync.RWMutex
+	chunks map[int64][]byte
+}
+
+// NewMemStore returns an empty MemStore.
+func NewMemStore() *MemStore {
+	return &MemStore{chunks: make(map[int64][]byte)}
+}
+
+// Get returns the chunk stored under key.
+func (m *MemStore) Get(key int64) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	chunk, ok := m.chunks[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: chunk %08X", fs.ErrNotExist, key)
+	}
+	return chunk, nil
+}
+
+// Put stores chunk under key.
+func (m *MemStore) Put(key int64, chunk []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.chunks[key] = chunk
+	return nil
+}
+
+// Delete removes the chunk stored under key.
+func (m *MemStore) Delete(key int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.chunks, key)
+	return nil
+}
+
+// Len returns the number of keys in use, the index included.
+func (m *MemStore) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.chunks)
+}
@@ -0,0 +1,165 @@
+package blosc
+
+import (
+	"errors"
+	"io/fs"
+	"slices"
+	"testing"
+)
+
+// loggingStore records the operations on a MemStore, failing Put under
+// failKey
+type loggingStore struct {
+	*MemStore
+	ops     []string
+	failKey int64
+}
+
+func (s *loggingStore) Put(key int64, chunk []byte) error {
+	if key == s.failKey {
+		return errors.New("put failed")
+	}
+	s.ops = append(s.ops, "put")
+	return s.MemStore.Put(key, chunk)
+}
+
+func (s *loggingStore) Delete(key int64) error {
+	s.ops = append(s.ops, "delete")
+	return s.MemStore.Delete(key)
+}
+
+func TestSChunkStore(t *testing.T) {
+	store := NewMemStore()
+	opts := Options{Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: 4}
+	sc, err := CreateSChunkStore(store, opts)
+	if err != nil {
+		t.Fatalf("CreateSChunkStore failed: %v", err)
+	}
+	want := [][]byte{makeFloatData(1000), makeTestData(2000), makeTestData(3000)}
+	for _, data := range want {
+		if _, err := sc.AppendBuffer(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sc.SetMetalayer("units", []byte("volts"))
+	testUpdateInsertDelete(t, sc, want)
+	var got [][]byte
+	for i := 0; i < sc.NumChunks(); i++ {
+		data, err := sc.DecompressChunk(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, data)
+	}
+	if err := sc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Replaced and deleted chunks are gone once the index is committed
+	if store.Len() != len(got)+1 {
+		t.Errorf("store holds %d keys for %d chunks", store.Len(), len(got))
+	}
+
+	sc, err = OpenSChunkStore(store, opts)
+	if err != nil {
+		t.Fatalf("OpenSChunkStore failed: %v", err)
+	}
+	checkChunks(t, sc, got)
+	if content, _ := sc.Metalayer("units"); string(content) != "volts" {
+		t.Errorf("metalayer = %q", content)
+	}
+
+	// New chunks do not overwrite indexed ones
+	more := makeTestData(500)
+	if _, err := sc.AppendBuffer(more); err != nil {
+		t.Fatal(err)
+	}
+	checkChunks(t, sc, append(got, more))
+
+	// Creating over an existing SChunk deletes its chunks
+	sc, err = CreateSChunkStore(store, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.NumChunks() != 0 || store.Len() != 2 {
+		t.Errorf("%d chunks, %d keys after create", sc.NumChunks(), store.Len())
+	}
+}
+
+func TestSChunkStoreCommitOrder(t *testing.T) {
+	store := &loggingStore{MemStore: NewMemStore(), failKey: -2}
+	sc, err := CreateSChunkStore(store, Options{Codec: LZ4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := sc.AppendBuffer(makeTestData(1000)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sc.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A deleted chunk stays until an index without it is stored
+	store.ops = nil
+	if err := sc.DeleteChunk(0); err != nil {
+		t.Fatal(err)
+	}
+	store.failKey = IndexKey
+	if err := sc.Sync(); err == nil {
+		t.Fatal("Sync succeeded without storing the index")
+	}
+	if len(store.ops) != 0 {
+		t.Errorf("operations %v after a failed commit", store.ops)
+	}
+	store.failKey = -2
+	if err := sc.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(store.ops, []string{"put", "delete"}) {
+		t.Errorf("commit did %v", store.ops)
+	}
+
+	// Failed chunk writes leave the SChunk as it was
+	store.failKey = 2
+	if _, err := sc.AppendBuffer(makeTestData(1000)); err == nil {
+		t.Error("AppendBuffer succeeded without storing the chunk")
+	}
+	if sc.NumChunks() != 1 {
+		t.Errorf("%d chunks after a failed append", sc.NumChunks())
+	}
+}
+
+func TestChunkStoreErrors(t *testing.T) {
+	store := NewMemStore()
+	if _, err := store.Get(0); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Get of a missing key: got %v", err)
+	}
+	if err := store.Delete(0); err != nil {
+		t.Errorf("Delete of a missing key: %v", err)
+	}
+	if _, err := OpenSChunkStore(store, Options{}); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("OpenSChunkStore without an index: got %v", err)
+	}
+	if err := store.Put(IndexKey, []byte("not an index")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenSChunkStore(store, Options{}); !errors.Is(err, ErrInvalidFrame) {
+		t.Errorf("OpenSChunkStore with a bad index: got %v", err)
+	}
+	if _, err := CreateSChunkStore(store, Options{}); !errors.Is(err, ErrInvalidFrame) {
+		t.Errorf("CreateSChunkStore over a bad index: got %v", err)
+	}
+
+	dir, err := NewDirStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dir.Put(-5, nil); !errors.Is(err, ErrChunkIndex) {
+		t.Errorf("DirStore.Put of a negative key: got %v", err)
+	}
+	if _, err := dir.Get(3); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("DirStore.Get of a missing key: got %v", err)
+	}
+}
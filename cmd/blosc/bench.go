@@ -0,0 +1,195 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	blosc "github.com/mrjoshuak/go-blosc"
+)
+
+// benchResult is the fastest of the trials of one set of options
+type benchResult struct {
+	trial  blosc.TuneTrial
+	nbytes int
+}
+
+func runBench(args []string) error {
+	fs := newFlags("bench")
+	codecs := fs.String("codecs", "", "comma-separated codec `names` (default: every registered codec)")
+	levels := fs.String("levels", "1,5,9", "comma-separated compression `levels`")
+	shuffles := fs.Bool("shuffles", false, "try noshuffle, shuffle and bitshuffle rather than shuffle alone")
+	typeSize := fs.Int("typesize", 0, "element size in `bytes` (default: from the chunks of a frame, or 4)")
+	chunkSize := fs.Int("chunksize", 0, "chunk size in `bytes` (default: blosc.DefaultChunkSize)")
+	benchTime := fs.Duration("benchtime", 200*time.Millisecond, "time to repeat each trial for, keeping the fastest")
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+
+	candidates, err := benchCandidates(*codecs, *levels, *shuffles)
+	if err != nil {
+		return err
+	}
+	data, headerTypeSize, err := loadData(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return fmt.Errorf("%s holds no data", fs.Arg(0))
+	}
+	for i := range candidates {
+		candidates[i].TypeSize = firstNonZero(*typeSize, headerTypeSize, 4)
+	}
+
+	fmt.Printf("%s: %d bytes in elements of %d\n\n", fs.Arg(0), len(data), candidates[0].TypeSize)
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "codec\tlevel\tshuffle\tratio\tcompress MB/s\tdecompress MB/s\t")
+	for _, opts := range candidates {
+		r, err := bench(data, *chunkSize, opts, *benchTime)
+		if err != nil {
+			return fmt.Errorf("%s level %d %s: %w", opts.Codec, opts.Level, opts.Shuffle, err)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%.2f\t%.1f\t%.1f\t\n", opts.Codec, opts.Level, opts.Shuffle,
+			r.trial.Ratio, r.speed(r.trial.CompressTime), r.speed(r.trial.DecompressTime))
+	}
+	return tw.Flush()
+}
+
+// benchCandidates returns the options for every codec and level listed, with
+// byte shuffle or with every shuffle mode. Codecs whose levels all compress
+// alike are tried at one level.
+func benchCandidates(codecs, levels string, shuffles bool) ([]blosc.Options, error) {
+	var ids []blosc.Codec
+	if codecs == "" {
+		ids = blosc.ListCodecs()
+		slices.Sort(ids)
+	}
+	for _, name := range split(codecs) {
+		id, err := blosc.ParseCodec(name)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	var levelList []int
+	for _, s := range split(levels) {
+		level, err := strconv.Atoi(s)
+		if err != nil || level < 0 || level > 9 {
+			return nil, fmt.Errorf("level %q is not 0 to 9", s)
+		}
+		levelList = append(levelList, level)
+	}
+	modes := []blosc.Shuffle{blosc.Shuffle1}
+	if shuffles {
+		modes = []blosc.Shuffle{blosc.NoShuffle, blosc.Shuffle1, blosc.BitShuffle}
+	}
+
+	var candidates []blosc.Options
+	for _, id := range ids {
+		codecLevels := levelList
+		if caps, err := blosc.CodecInfo(id); err == nil && !caps.LevelsMatter && len(codecLevels) > 0 {
+			codecLevels = codecLevels[:1]
+		}
+		for _, level := range codecLevels {
+			for _, mode := range modes {
+				candidates = append(candidates, blosc.Options{Codec: id, Level: level, Shuffle: mode})
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, errors.New("no codec, level and shuffle to try")
+	}
+	return candidates, nil
+}
+
+// bench runs trials of opts on data for at least benchTime, and at least
+// once, keeping the fastest compression and decompression
+func bench(data []byte, chunkSize int, opts blosc.Options, benchTime time.Duration) (benchResult, error) {
+	r := benchResult{nbytes: len(data)}
+	start := time.Now()
+	for n := 0; n == 0 || time.Since(start) < benchTime; n++ {
+		trial, err := blosc.RunTrial(data, chunkSize, opts)
+		if err != nil {
+			return r, err
+		}
+		if n == 0 {
+			r.trial = trial
+			continue
+		}
+		r.trial.CompressTime = min(r.trial.CompressTime, trial.CompressTime)
+		r.trial.DecompressTime = min(r.trial.DecompressTime, trial.DecompressTime)
+	}
+	return r, nil
+}
+
+// speed returns the MB/s of handling the data in d
+func (r benchResult) speed(d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(r.nbytes) / (1 << 20) / d.Seconds()
+}
+
+// loadData returns the data to benchmark from the file at path: the
+// decompressed chunks of a frame or lone chunk, along with the element size
+// of the first, or the file itself, with an element size of 0
+func loadData(path string) ([]byte, int, error) {
+	s, closeS, err := openSChunk(path)
+	if errors.Is(err, blosc.ErrInvalidFrame) {
+		if chunk, ok := readLoneChunk(path); ok {
+			h, _ := blosc.ParseHeader(chunk)
+			data, err := blosc.Decompress(chunk)
+			return data, int(h.TypeSize), err
+		}
+		data, err := os.ReadFile(path)
+		return data, 0, err
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer closeS()
+
+	typeSize := 0
+	data := make([]byte, 0, s.NBytes())
+	for i := 0; i < s.NumChunks(); i++ {
+		chunk, err := s.Chunk(i)
+		if err != nil {
+			return nil, 0, err
+		}
+		if h, err := blosc.ParseHeader(chunk); err == nil && i == 0 {
+			typeSize = int(h.TypeSize)
+		}
+		decompressed, err := s.DecompressChunk(i)
+		if err != nil {
+			return nil, 0, fmt.Errorf("chunk %d: %w", i, err)
+		}
+		data = append(data, decompressed...)
+	}
+	return data, typeSize, nil
+}
+
+// split returns the comma-separated items of list
+func split(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// firstNonZero returns the first of values that is not zero
+func firstNonZero(values ...int) int {
+	for _, v := range values {
+		if v != 0 {
+			return v
+		}
+	}
+	return 0
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	blosc "github.com/mrjoshuak/go-blosc"
+)
+
+func TestBenchCandidates(t *testing.T) {
+	got, err := benchCandidates("lz4,snappy", "1,9", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Snappy ignores the level, so it is tried at the first only
+	if len(got) != 2*3+3 {
+		t.Fatalf("got %d candidates", len(got))
+	}
+	if got[0].Codec != blosc.LZ4 || got[0].Level != 1 || got[0].Shuffle != blosc.NoShuffle {
+		t.Errorf("first candidate %+v", got[0])
+	}
+	if last := got[len(got)-1]; last.Codec != blosc.Snappy || last.Level != 1 || last.Shuffle != blosc.BitShuffle {
+		t.Errorf("last candidate %+v", last)
+	}
+
+	got, err = benchCandidates("zstd", "5", false)
+	if err != nil || len(got) != 1 || got[0].Shuffle != blosc.Shuffle1 {
+		t.Errorf("without -shuffles: %+v, %v", got, err)
+	}
+	for _, bad := range [][2]string{{"nope", "1"}, {"lz4", "10"}, {"lz4", "x"}, {"lz4", ""}} {
+		if _, err := benchCandidates(bad[0], bad[1], false); err == nil {
+			t.Errorf("codecs %q levels %q: no error", bad[0], bad[1])
+		}
+	}
+}
+
+func TestBenchLoadData(t *testing.T) {
+	dir := t.TempDir()
+	ramp := make([]byte, 40000)
+	for i := range ramp {
+		ramp[i] = byte(i / 8)
+	}
+
+	raw := filepath.Join(dir, "raw")
+	if err := os.WriteFile(raw, ramp, 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if data, typeSize, err := loadData(raw); err != nil || typeSize != 0 || !bytes.Equal(data, ramp) {
+		t.Errorf("raw file: type size %d, %v", typeSize, err)
+	}
+
+	opts := blosc.Options{Codec: blosc.LZ4, Level: 5, Shuffle: blosc.Shuffle1, TypeSize: 8}
+	chunk, err := blosc.CompressWithOptions(ramp, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lone := filepath.Join(dir, "chunk.b2")
+	if err := os.WriteFile(lone, chunk, 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if data, typeSize, err := loadData(lone); err != nil || typeSize != 8 || !bytes.Equal(data, ramp) {
+		t.Errorf("lone chunk: type size %d, %v", typeSize, err)
+	}
+
+	frame := filepath.Join(dir, "data.gbframe")
+	s, err := blosc.CreateSChunkFile(frame, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, part := range [][]byte{ramp[:24000], ramp[24000:]} {
+		if _, err := s.AppendBuffer(part); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if data, typeSize, err := loadData(frame); err != nil || typeSize != 8 || !bytes.Equal(data, ramp) {
+		t.Errorf("frame: type size %d, %v", typeSize, err)
+	}
+
+	if err := runBench([]string{"-codecs", "lz4", "-levels", "1", "-benchtime", "1ms", frame}); err != nil {
+		t.Fatal(err)
+	}
+	if err := runBench([]string{"-levels", "1", "-benchtime", "1ms", filepath.Join(dir, "missing")}); err == nil {
+		t.Error("missing file: no error")
+	}
+}
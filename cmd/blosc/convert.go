@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	blosc "github.com/mrjoshuak/go-blosc"
+)
+
+func runConvert(args []string) error {
+	fs := newFlags("convert")
+	sparse := fs.Bool("sparse", false, "write a sparse frame directory")
+	if err := parseFlags(fs, args, 2); err != nil {
+		return err
+	}
+	src, dst := fs.Arg(0), fs.Arg(1)
+
+	if err := checkDistinct(src, dst); err != nil {
+		return err
+	}
+
+	in, closeIn, err := openSChunk(src)
+	if err != nil {
+		return err
+	}
+	defer closeIn()
+
+	out, err := createSChunk(dst, *sparse, blosc.Options{})
+	if err != nil {
+		return err
+	}
+	if err := in.CopyTo(out); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// checkDistinct checks that src exists and that dst, which creating clears,
+// is not src
+func checkDistinct(src, dst string) error {
+	in, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if out, err := os.Stat(dst); err == nil && os.SameFile(in, out) {
+		return fmt.Errorf("%s and %s are the same", src, dst)
+	}
+	return nil
+}
+
+// createSChunk creates a frame file at path, or a sparse frame directory if
+// sparse is set, compressing with opts
+func createSChunk(path string, sparse bool, opts blosc.Options) (*blosc.SChunk, error) {
+	if sparse {
+		return blosc.CreateSChunkDir(path, opts)
+	}
+	return blosc.CreateSChunkFile(path, opts)
+}
+
+// openSChunk opens the frame at path, a sparse frame directory or a frame
+// file, to read its chunks as stored. The returned function releases it.
+func openSChunk(path string) (*blosc.SChunk, func() error, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.IsDir() {
+		s, err := blosc.OpenSChunkDir(path, blosc.Options{})
+		if err != nil {
+			return nil, nil, err
+		}
+		return s, s.Close, nil
+	}
+	f, err := blosc.OpenFrameMmap(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f.ToSChunk(blosc.Options{}), f.Close, nil
+}
+
+// readLoneChunk reads the file at path if it holds a single chunk rather
+// than a frame
+func readLoneChunk(path string) ([]byte, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	h, err := blosc.ParseHeader(data)
+	if err != nil || int(h.NBytesComp) != len(data) {
+		return nil, false
+	}
+	return data, true
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	blosc "github.com/mrjoshuak/go-blosc"
+)
+
+func TestConvert(t *testing.T) {
+	dir := t.TempDir()
+	frame := filepath.Join(dir, "data.gbframe")
+	s, err := blosc.CreateSChunkFile(frame, blosc.Options{Codec: blosc.LZ4, Level: 5, Shuffle: blosc.Shuffle1, TypeSize: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want [][]byte
+	for i := 0; i < 3; i++ {
+		data := bytes.Repeat([]byte{byte(i), 1, 2, 3}, 5000)
+		if _, err := s.AppendBuffer(data); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, data)
+	}
+	s.SetMetalayer("units", []byte("kelvin"))
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Frame file to sparse directory, and back to a frame file
+	sparse, back := filepath.Join(dir, "sparse"), filepath.Join(dir, "back.gbframe")
+	if err := runConvert([]string{"-sparse", frame, sparse}); err != nil {
+		t.Fatal(err)
+	}
+	if err := runConvert([]string{sparse, back}); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{sparse, back} {
+		s, closeS, err := openSChunk(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.NumChunks() != len(want) {
+			t.Fatalf("%s: %d chunks, want %d", path, s.NumChunks(), len(want))
+		}
+		for i, data := range want {
+			if got, err := s.DecompressChunk(i); err != nil || !bytes.Equal(got, data) {
+				t.Errorf("%s: chunk %d: %v", path, i, err)
+			}
+		}
+		if content, ok := s.Metalayer("units"); !ok || string(content) != "kelvin" {
+			t.Errorf("%s: metalayer = %q, %v", path, content, ok)
+		}
+		closeS()
+	}
+
+	if err := runConvert([]string{"-sparse", sparse, sparse}); err == nil {
+		t.Error("converted a directory onto itself")
+	}
+	if err := runConvert([]string{frame}); !errors.Is(err, errUsage) {
+		t.Errorf("one argument: got %v, want errUsage", err)
+	}
+}
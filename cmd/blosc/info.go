@@ -0,0 +1,292 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+	"unicode/utf8"
+
+	blosc "github.com/mrjoshuak/go-blosc"
+)
+
+// maxContent is how much of a metalayer info prints
+const maxContent = 64
+
+// fileInfo describes a frame, or a file holding a single chunk
+type fileInfo struct {
+	Path       string      `json:"path"`
+	Layout     string      `json:"layout"` // "frame", "sparse" or "chunk"
+	KeyID      string      `json:"key_id,omitempty"`
+	NChunks    int         `json:"nchunks"`
+	NBytes     int64       `json:"nbytes"`
+	CBytes     int64       `json:"cbytes"`
+	Ratio      float64     `json:"ratio"`
+	Metalayers []metaInfo  `json:"metalayers,omitempty"`
+	Chunks     []chunkInfo `json:"chunks"`
+}
+
+// metaInfo describes a metalayer, whose content is shown as text if it is
+// printable and in hex otherwise
+type metaInfo struct {
+	Name string `json:"name"`
+	Size int    `json:"size"`
+	Text string `json:"text,omitempty"`
+	Hex  string `json:"hex,omitempty"`
+}
+
+// chunkInfo describes the header of a chunk, and any problem Validate finds
+type chunkInfo struct {
+	Index          int      `json:"index"`
+	Version        uint8    `json:"version"`
+	VersionLZ      uint8    `json:"versionlz"`
+	Flags          string   `json:"flags"`
+	Codec          string   `json:"codec"`
+	TypeSize       uint8    `json:"typesize"`
+	BlockSize      uint32   `json:"blocksize"`
+	NBlocks        int      `json:"nblocks"`
+	NBytes         uint32   `json:"nbytes"`
+	CBytes         uint32   `json:"cbytes"`
+	Ratio          float64  `json:"ratio"`
+	Filters        []string `json:"filters"`
+	Extended       bool     `json:"extended"`
+	Legacy         bool     `json:"legacy"`
+	Memcpy         bool     `json:"memcpy"`
+	Special        string   `json:"special,omitempty"`
+	BlockChecksums bool     `json:"block_checksums"`
+	Error          string   `json:"error,omitempty"`
+}
+
+func runInfo(args []string) error {
+	fs := newFlags("info")
+	asJSON := fs.Bool("json", false, "print JSON instead of a table")
+	if err := parseFlags(fs, args, 1); err != nil {
+		return err
+	}
+	info, err := describe(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(info)
+	}
+	return info.print(os.Stdout)
+}
+
+// describe reads the frame or chunk at path
+func describe(path string) (*fileInfo, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if stat.IsDir() {
+		s, err := blosc.OpenSChunkDir(path, blosc.Options{})
+		if err != nil {
+			return nil, err
+		}
+		defer s.Close()
+		return describeSChunk(path, "sparse", s), nil
+	}
+
+	f, err := blosc.OpenFrameMmap(path)
+	if err == nil {
+		defer f.Close()
+		return describeSChunk(path, "frame", f.ToSChunk(blosc.Options{})), nil
+	}
+	// Not a frame, but perhaps a chunk on its own
+	if data, ok := readLoneChunk(path); ok {
+		c := describeChunk(0, data)
+		return &fileInfo{
+			Path:    path,
+			Layout:  "chunk",
+			NChunks: 1,
+			NBytes:  int64(c.NBytes),
+			CBytes:  int64(c.CBytes),
+			Ratio:   c.Ratio,
+			Chunks:  []chunkInfo{c},
+		}, nil
+	}
+	return nil, err
+}
+
+// describeSChunk describes the chunks and metalayers of s
+func describeSChunk(path, layout string, s *blosc.SChunk) *fileInfo {
+	info := &fileInfo{
+		Path:    path,
+		Layout:  layout,
+		KeyID:   s.KeyID(),
+		NChunks: s.NumChunks(),
+		NBytes:  s.NBytes(),
+		CBytes:  s.CBytes(),
+		Ratio:   ratio(s.NBytes(), s.CBytes()),
+		Chunks:  []chunkInfo{},
+	}
+	for _, name := range s.Metalayers() {
+		content, _ := s.Metalayer(name)
+		m := metaInfo{Name: name, Size: len(content)}
+		if printable(content) {
+			m.Text = string(content)
+		} else {
+			m.Hex = hex.EncodeToString(content[:min(len(content), maxContent)])
+		}
+		info.Metalayers = append(info.Metalayers, m)
+	}
+	for i := 0; i < s.NumChunks(); i++ {
+		chunk, err := s.Chunk(i)
+		if err != nil {
+			info.Chunks = append(info.Chunks, chunkInfo{Index: i, Error: err.Error()})
+			continue
+		}
+		info.Chunks = append(info.Chunks, describeChunk(i, chunk))
+	}
+	return info
+}
+
+// describeChunk describes chunk i
+func describeChunk(i int, chunk []byte) chunkInfo {
+	h, err := blosc.GetInfo(chunk)
+	if err != nil {
+		return chunkInfo{Index: i, Error: err.Error()}
+	}
+	c := chunkInfo{
+		Index:          i,
+		Version:        h.Version,
+		VersionLZ:      h.VersionLZ,
+		Flags:          fmt.Sprintf("0x%02x", h.Flags),
+		Codec:          h.CodecName,
+		TypeSize:       h.TypeSize,
+		BlockSize:      h.BlockSize,
+		NBlocks:        h.Blocks,
+		NBytes:         h.NBytesOrig,
+		CBytes:         h.NBytesComp,
+		Ratio:          h.Ratio,
+		Filters:        []string{},
+		Extended:       h.IsExtended(),
+		Legacy:         h.IsLegacy(),
+		Memcpy:         h.IsMemcpy(),
+		BlockChecksums: h.Checksums,
+	}
+	if h.IsExtended() {
+		for _, f := range h.Filters {
+			switch {
+			case f.Filter == blosc.FilterNone:
+			case f.Meta != 0:
+				c.Filters = append(c.Filters, fmt.Sprintf("%s(%d)", f.Filter, f.Meta))
+			default:
+				c.Filters = append(c.Filters, f.Filter.String())
+			}
+		}
+	} else if mode := h.ShuffleMode(); mode != blosc.NoShuffle {
+		c.Filters = append(c.Filters, mode.String())
+	}
+	if kind := h.Special(); kind != blosc.SpecialNone {
+		c.Special = kind.String()
+	}
+	if err := blosc.Validate(chunk); err != nil {
+		c.Error = err.Error()
+	}
+	return c
+}
+
+// print writes info as a table
+func (info *fileInfo) print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "path\t%s\n", info.Path)
+	fmt.Fprintf(tw, "layout\t%s\n", info.Layout)
+	if info.KeyID != "" {
+		fmt.Fprintf(tw, "key id\t%s\n", info.KeyID)
+	}
+	fmt.Fprintf(tw, "chunks\t%d\n", info.NChunks)
+	fmt.Fprintf(tw, "nbytes\t%d\n", info.NBytes)
+	fmt.Fprintf(tw, "cbytes\t%d\n", info.CBytes)
+	fmt.Fprintf(tw, "ratio\t%.2f\n", info.Ratio)
+	for _, m := range info.Metalayers {
+		content := fmt.Sprintf("%q", m.Text)
+		if m.Hex != "" {
+			content = m.Hex
+			if m.Size > maxContent {
+				content += "..."
+			}
+		}
+		fmt.Fprintf(tw, "metalayer %s\t%d bytes: %s\n", m.Name, m.Size, content)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if len(info.Chunks) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "chunk\tversion\tcodec\ttypesize\tblocksize\tblocks\tnbytes\tcbytes\tratio\tflags\tdetails\t")
+	for _, c := range info.Chunks {
+		if c.Version == 0 && c.Error != "" {
+			fmt.Fprintf(tw, "%d\t-\t-\t-\t-\t-\t-\t-\t-\t-\t-\t\n", c.Index)
+			continue
+		}
+		fmt.Fprintf(tw, "%d\t%d/%d\t%s\t%d\t%d\t%d\t%d\t%d\t%.2f\t%s\t%s\t\n",
+			c.Index, c.Version, c.VersionLZ, c.Codec, c.TypeSize, c.BlockSize, c.NBlocks,
+			c.NBytes, c.CBytes, c.Ratio, c.Flags, orNone(strings.Join(slices.Concat(c.Filters, c.notes()), ",")))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, c := range info.Chunks {
+		if c.Error != "" {
+			fmt.Fprintf(w, "chunk %d: %s\n", c.Index, c.Error)
+		}
+	}
+	return nil
+}
+
+// notes lists the properties of c the table has no column for
+func (c *chunkInfo) notes() []string {
+	var notes []string
+	if c.Legacy {
+		notes = append(notes, "legacy")
+	}
+	if c.Memcpy {
+		notes = append(notes, "memcpy")
+	}
+	if c.Special != "" {
+		notes = append(notes, "special="+c.Special)
+	}
+	if c.BlockChecksums {
+		notes = append(notes, "checksums")
+	}
+	return notes
+}
+
+// ratio returns the compression ratio of nbytes stored in cbytes
+func ratio(nbytes, cbytes int64) float64 {
+	if cbytes == 0 {
+		return 0
+	}
+	return float64(nbytes) / float64(cbytes)
+}
+
+// printable reports whether content reads as text
+func printable(content []byte) bool {
+	if len(content) > maxContent || !utf8.Valid(content) {
+		return false
+	}
+	return strings.IndexFunc(string(content), func(r rune) bool {
+		return !unicode.IsPrint(r) && !unicode.IsSpace(r)
+	}) < 0
+}
+
+// orNone returns s, or "-" if it is empty
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	blosc "github.com/mrjoshuak/go-blosc"
+)
+
+func TestInfo(t *testing.T) {
+	dir := t.TempDir()
+	frame := filepath.Join(dir, "data.gbframe")
+	s, err := blosc.CreateSChunkFile(frame, blosc.Options{Codec: blosc.ZSTD, Level: 3, Shuffle: blosc.Shuffle1, TypeSize: 4, BlockChecksums: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetMetalayer("units", []byte("kelvin"))
+	s.SetMetalayer("raw", []byte{0, 1, 2})
+	if _, err := s.AppendBuffer(bytes.Repeat([]byte{1, 2, 3, 4, 5, 6, 7}, 4000)); err != nil {
+		t.Fatal(err)
+	}
+	zeros, err := blosc.NewZeroChunk(8000, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AppendChunk(zeros); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := describe(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Layout != "frame" || info.NChunks != 2 || info.NBytes != 36000 || len(info.Chunks) != 2 {
+		t.Fatalf("got %+v", info)
+	}
+	want := []metaInfo{{Name: "units", Size: 6, Text: "kelvin"}, {Name: "raw", Size: 3, Hex: "000102"}}
+	if !slices.Equal(info.Metalayers, want) {
+		t.Errorf("metalayers %+v, want %+v", info.Metalayers, want)
+	}
+	c := info.Chunks[0]
+	if c.Codec != "zstd" || c.TypeSize != 4 || !slices.Equal(c.Filters, []string{"shuffle"}) || !c.BlockChecksums || c.Error != "" {
+		t.Errorf("chunk 0: %+v", c)
+	}
+	if c := info.Chunks[1]; c.Special != "zero" || c.NBytes != 8000 || c.CBytes != blosc.ExtendedHeaderSize {
+		t.Errorf("chunk 1: %+v", c)
+	}
+
+	// The table and the JSON report carry the same facts
+	var table bytes.Buffer
+	if err := info.print(&table); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"frame\n", `"kelvin"`, "000102", "shuffle,checksums", "special=zero"} {
+		if !strings.Contains(table.String(), s) {
+			t.Errorf("table lacks %q:\n%s", s, table.String())
+		}
+	}
+	raw, err := json.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded fileInfo
+	if err := json.Unmarshal(raw, &decoded); err != nil || decoded.Chunks[1].Special != "zero" {
+		t.Errorf("JSON round trip: %v", err)
+	}
+
+	// A sparse frame, and a lone chunk, damaged
+	sparse := filepath.Join(dir, "sparse")
+	if err := runConvert([]string{"-sparse", frame, sparse}); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := describe(sparse); err != nil || info.Layout != "sparse" || info.NChunks != 2 {
+		t.Errorf("sparse: %+v, %v", info, err)
+	}
+	chunk, err := blosc.Compress(bytes.Repeat([]byte("abcdefgh"), 1000), blosc.LZ4, 5, blosc.BitShuffle, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunk[len(chunk)-1] ^= 0xff
+	path := filepath.Join(dir, "chunk.b2")
+	if err := os.WriteFile(path, chunk, 0o666); err != nil {
+		t.Fatal(err)
+	}
+	info, err = describe(path)
+	if err != nil || info.Layout != "chunk" || info.Chunks[0].Codec != "lz4" {
+		t.Fatalf("chunk: %+v, %v", info, err)
+	}
+
+	// Anything else is an error
+	if err := os.WriteFile(path, []byte("not blosc data at all"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := describe(path); err == nil {
+		t.Error("described a text file")
+	}
+}
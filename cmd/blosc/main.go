@@ -0,0 +1,162 @@
+// Command blosc works with go-blosc frames from the command line.
+//
+// Usage:
+//
+//	blosc bench [-codecs list] [-levels list] [-shuffles] [flags] path
+//	blosc convert [-sparse] src dst
+//	blosc info [-json] path
+//	blosc recompress [-codec name] [-level n] [flags] src dst
+//	blosc verify [-q] path...
+//
+// The bench command compresses the data at path, the decompressed chunks of a
+// frame or chunk or else the file as it is, with each codec in -codecs (all
+// registered codecs by default) at each level in -levels (1, 5 and 9), and
+// prints a table of ratio and compression and decompression speed in MB/s.
+// Trials use byte shuffle, or each shuffle mode with -shuffles, and repeat
+// for -benchtime, keeping the fastest. The element size is the chunks' unless
+// -typesize is given, and 4 for plain files.
+//
+// The convert command rewrites the frame at src, a contiguous frame file or
+// a sparse frame directory, as a frame file at dst, or as a sparse frame
+// directory with -sparse. Chunks are copied as stored, so nothing is
+// decompressed or recompressed, and encrypted frames need no keys.
+//
+// The info command prints the layout, sizes and metalayers of the frame at
+// path, and the header of each chunk: codec, element and block sizes, ratio,
+// filters and flags, along with any problem Validate finds. A file holding a
+// single chunk is described too. With -json the report is printed as JSON.
+//
+// The recompress command decompresses the chunks of the frame or chunk at
+// src one at a time and writes them compressed again, with -codec (zstd by
+// default) and -level (5), to dst, along with the metalayers. Each chunk
+// keeps its element size and shuffle unless -typesize or -shuffle is given;
+// other filters are dropped. Chunks whose elements are all equal become
+// special-value chunks. -blocksize, -checksums and -sparse set the block
+// size, block checksums and a sparse frame directory for dst.
+//
+// The verify command checks every chunk of the frames at the given paths
+// with Verify, which decodes each block and checks block checksums. Paths
+// may be frame files, lone chunks, sparse frame directories, or directories
+// searched for frame files and sparse frames. Each problem is printed with
+// the chunk and its offset in the frame file, then a summary unless -q is
+// given. The exit status is 0 if everything checked out, 1 if damage was
+// found, and 3 if some frames could not be checked, such as encrypted ones
+// or unreadable files.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// command is a blosc subcommand
+type command struct {
+	usage string // Arguments, after the command name
+	help  string // One-line description
+	run   func(args []string) error
+}
+
+// commands are the subcommands by name, set in init since their flag sets
+// refer to the table for usage messages
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"bench":      {"[-codecs list] [-levels list] [-shuffles] [flags] path", "measure ratio and speed of codecs, levels and shuffles on data", runBench},
+		"convert":    {"[-sparse] src dst", "rewrite a frame file as a sparse frame directory or back", runConvert},
+		"info":       {"[-json] path", "describe the chunks and metalayers of a frame or chunk", runInfo},
+		"verify":     {"[-q] path...", "check the chunks of frames and directory trees of frames", runVerify},
+		"recompress": {"[-codec name] [-level n] [flags] src dst", "compress the chunks of a frame or chunk again with new settings", runRecompress},
+	}
+}
+
+// errUsage reports bad arguments, which the usage message explains
+var errUsage = errors.New("usage")
+
+// exitError is an error that sets the exit status
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+	name := os.Args[1]
+	cmd, ok := commands[name]
+	if !ok {
+		if name != "help" && name != "-h" && name != "-help" {
+			fmt.Fprintf(os.Stderr, "blosc: unknown command %q\n", name)
+		}
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+	if err := cmd.run(os.Args[2:]); err != nil {
+		if errors.Is(err, errUsage) {
+			os.Exit(2)
+		}
+		fmt.Fprintf(os.Stderr, "blosc %s: %v\n", name, err)
+		var exit *exitError
+		if errors.As(err, &exit) {
+			os.Exit(exit.code)
+		}
+		os.Exit(1)
+	}
+}
+
+// usage lists the commands
+func usage(w io.Writer) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(w, "usage: blosc <command> [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, name := range names {
+		cmd := commands[name]
+		fmt.Fprintf(w, "  %-8s %s\n", name, cmd.help)
+		fmt.Fprintf(w, "  %-8s   blosc %s %s\n", "", name, cmd.usage)
+	}
+}
+
+// newFlags returns a flag set for command name that reports errors as
+// errUsage rather than exiting
+func newFlags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: blosc %s %s\n", name, commands[name].usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses args with fs and checks that n arguments are left, or
+// at least one if n is negative. On failure, the usage message has been
+// printed.
+func parseFlags(fs *flag.FlagSet, args []string, n int) error {
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if n >= 0 && fs.NArg() != n || n < 0 && fs.NArg() == 0 {
+		fs.Usage()
+		return errUsage
+	}
+	return nil
+}
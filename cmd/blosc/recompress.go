@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	blosc "github.com/mrjoshuak/go-blosc"
+)
+
+// recompressor compresses chunks again with new settings. A zero TypeSize,
+// or keepShuffle, keeps what each chunk's header has.
+type recompressor struct {
+	opts        blosc.Options
+	keepShuffle bool
+}
+
+func runRecompress(args []string) error {
+	fs := newFlags("recompress")
+	codec := fs.String("codec", "zstd", "codec `name`")
+	level := fs.Int("level", 5, "compression level, 0 to 9")
+	shuffle := fs.String("shuffle", "", "shuffle `mode`: noshuffle, shuffle or bitshuffle (default: as each chunk)")
+	typeSize := fs.Int("typesize", 0, "element size in `bytes` (default: as each chunk)")
+	blockSize := fs.Int("blocksize", 0, "block size in `bytes` (default: automatic)")
+	checksums := fs.Bool("checksums", false, "store a checksum of each block")
+	sparse := fs.Bool("sparse", false, "write a sparse frame directory")
+	if err := parseFlags(fs, args, 2); err != nil {
+		return err
+	}
+	src, dst := fs.Arg(0), fs.Arg(1)
+
+	r := recompressor{opts: blosc.Options{
+		Level:          *level,
+		TypeSize:       *typeSize,
+		BlockSize:      *blockSize,
+		SpecialValues:  true,
+		BlockChecksums: *checksums,
+	}}
+	var err error
+	if r.opts.Codec, err = blosc.ParseCodec(*codec); err != nil {
+		return err
+	}
+	if *shuffle == "" {
+		r.keepShuffle = true
+	} else if r.opts.Shuffle, err = blosc.ParseShuffle(*shuffle); err != nil {
+		return err
+	}
+	check := r.opts
+	check.TypeSize = max(check.TypeSize, 1)
+	if err := check.Validate(); err != nil {
+		return err
+	}
+	if err := checkDistinct(src, dst); err != nil {
+		return err
+	}
+
+	in, closeIn, err := openSChunk(src)
+	if errors.Is(err, blosc.ErrInvalidFrame) {
+		// Not a frame, but perhaps a chunk on its own
+		chunk, ok := readLoneChunk(src)
+		if !ok {
+			return err
+		}
+		data, err := blosc.Decompress(chunk)
+		if err != nil {
+			return err
+		}
+		if chunk, err = r.compress(data, chunk); err != nil {
+			return err
+		}
+		return os.WriteFile(dst, chunk, 0o666)
+	}
+	if err != nil {
+		return err
+	}
+	defer closeIn()
+
+	out, err := createSChunk(dst, *sparse, r.opts)
+	if err != nil {
+		return err
+	}
+	if err := r.copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// copy appends the chunks of in to out, recompressed one at a time, and
+// sets the metalayers of in on out
+func (r *recompressor) copy(out, in *blosc.SChunk) error {
+	for _, name := range in.Metalayers() {
+		content, _ := in.Metalayer(name)
+		out.SetMetalayer(name, content)
+	}
+	for i := 0; i < in.NumChunks(); i++ {
+		old, err := in.Chunk(i)
+		if err != nil {
+			return err
+		}
+		data, err := in.DecompressChunk(i)
+		if err != nil {
+			return fmt.Errorf("chunk %d: %w", i, err)
+		}
+		chunk, err := r.compress(data, old)
+		if err != nil {
+			return fmt.Errorf("chunk %d: %w", i, err)
+		}
+		if _, err := out.AppendChunk(chunk); err != nil {
+			return fmt.Errorf("chunk %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// compress compresses data, decompressed from chunk old, with the settings
+// of r
+func (r *recompressor) compress(data, old []byte) ([]byte, error) {
+	h, err := blosc.ParseHeader(old)
+	if err != nil {
+		return nil, err
+	}
+	opts := r.opts
+	if opts.TypeSize == 0 {
+		opts.TypeSize = max(int(h.TypeSize), 1)
+	}
+	if r.keepShuffle {
+		opts.Shuffle = h.ShuffleMode()
+	}
+	return blosc.CompressWithOptions(data, opts)
+}
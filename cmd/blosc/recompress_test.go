@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	blosc "github.com/mrjoshuak/go-blosc"
+)
+
+func TestRecompress(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.gbframe")
+	s, err := blosc.CreateSChunkFile(src, blosc.Options{Codec: blosc.LZ4, Level: 1, Shuffle: blosc.BitShuffle, TypeSize: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetMetalayer("units", []byte("kelvin"))
+	ramp := make([]byte, 24000)
+	for i := range ramp {
+		ramp[i] = byte(i / 10)
+	}
+	want := [][]byte{ramp, make([]byte, 8000)}
+	for _, data := range want {
+		if _, err := s.AppendBuffer(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(dir, "dst")
+	if err := runRecompress([]string{"-codec", "zstd", "-level", "9", "-checksums", "-sparse", src, dst}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := describe(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Layout != "sparse" || len(info.Metalayers) != 1 || info.Metalayers[0].Text != "kelvin" {
+		t.Errorf("got %+v", info)
+	}
+	c := info.Chunks[0]
+	if c.Codec != "zstd" || c.TypeSize != 8 || len(c.Filters) != 1 || c.Filters[0] != "bitshuffle" || !c.BlockChecksums {
+		t.Errorf("chunk 0: %+v", c)
+	}
+	if info.Chunks[1].Special != "zero" {
+		t.Errorf("chunk 1: %+v", info.Chunks[1])
+	}
+	out, err := blosc.OpenSChunkDir(dst, blosc.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	for i, data := range want {
+		if got, err := out.DecompressChunk(i); err != nil || !bytes.Equal(got, data) {
+			t.Errorf("chunk %d: %v", i, err)
+		}
+	}
+
+	// A lone chunk is written back as a chunk, with the shuffle overridden
+	chunk, err := blosc.Compress(want[0], blosc.LZ4, 5, blosc.Shuffle1, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "chunk.b2")
+	if err := os.WriteFile(path, chunk, 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := runRecompress([]string{"-codec", "zlib", "-shuffle", "noshuffle", path, path + ".new"}); err != nil {
+		t.Fatal(err)
+	}
+	info, err = describe(path + ".new")
+	if err != nil || info.Layout != "chunk" || info.Chunks[0].Codec != "zlib" || len(info.Chunks[0].Filters) != 0 {
+		t.Errorf("chunk: %+v, %v", info, err)
+	}
+
+	for _, args := range [][]string{
+		{"-codec", "nope", src, dst + "2"},
+		{"-level", "10", src, dst + "2"},
+		{"-shuffle", "twice", src, dst + "2"},
+		{src, src},
+	} {
+		if err := runRecompress(args); err == nil {
+			t.Errorf("%q: no error", args)
+		}
+	}
+}
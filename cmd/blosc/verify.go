@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	blosc "github.com/mrjoshuak/go-blosc"
+)
+
+// frameMagic starts every go-blosc frame file, and tells frames from other
+// files when searching a directory
+const frameMagic = "GBFRAME\x00"
+
+// Exit statuses of verify beyond success
+const (
+	exitDamaged   = 1
+	exitUnchecked = 3
+)
+
+// verifier checks frames and tallies what it finds
+type verifier struct {
+	w         io.Writer
+	quiet     bool
+	files     int // Frames and chunk files checked
+	chunks    int // Chunks checked
+	damaged   int // Damaged chunks and frames
+	unchecked int // Files that could not be checked
+}
+
+func runVerify(args []string) error {
+	fs := newFlags("verify")
+	quiet := fs.Bool("q", false, "print only problems")
+	if err := parseFlags(fs, args, -1); err != nil {
+		return err
+	}
+	v := verifier{w: os.Stdout, quiet: *quiet}
+	for _, path := range fs.Args() {
+		v.walk(path)
+	}
+	return v.result()
+}
+
+// walk checks the frame or chunk at root, or the frames in the directory
+// tree at root
+func (v *verifier) walk(root string) {
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		switch {
+		case err != nil:
+			v.unreadable(path, err)
+		case d.IsDir():
+			// A directory with an index is a sparse frame, whose chunk
+			// files are checked through it
+			s, err := blosc.OpenSChunkDir(path, blosc.Options{})
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			v.sparse(path, s, err)
+			return filepath.SkipDir
+		case path == root:
+			v.file(path)
+		case isFrameFile(path):
+			v.file(path)
+		}
+		return nil
+	})
+}
+
+// file checks the frame or chunk in the file at path
+func (v *verifier) file(path string) {
+	f, err := blosc.OpenFrameMmap(path)
+	if err != nil {
+		if chunk, ok := readLoneChunk(path); ok {
+			v.files++
+			v.chunk(path, 0, -1, chunk)
+		} else if isFrameFile(path) {
+			v.files++
+			v.damage("%s: %v", path, err)
+		} else {
+			v.unreadable(path, err)
+		}
+		return
+	}
+	defer f.Close()
+	v.files++
+	if f.KeyID() != "" {
+		v.encrypted(path, f.KeyID())
+		return
+	}
+	for i := 0; i < f.NumChunks(); i++ {
+		chunk, err := f.Chunk(i)
+		offset, _, _ := f.ChunkOffset(i)
+		if err != nil {
+			v.damage("%s: chunk %d at offset %d: %v", path, i, offset, err)
+			continue
+		}
+		v.chunk(path, i, offset, chunk)
+	}
+}
+
+// sparse checks the sparse frame in directory path, opened as s or failing
+// with err
+func (v *verifier) sparse(path string, s *blosc.SChunk, err error) {
+	switch {
+	case errors.Is(err, blosc.ErrInvalidFrame) || errors.Is(err, blosc.ErrChecksum) || errors.Is(err, blosc.ErrInvalidVersion):
+		v.files++
+		v.damage("%s: %v", path, err)
+		return
+	case err != nil:
+		v.unreadable(path, err)
+		return
+	}
+	defer s.Close()
+	v.files++
+	if s.KeyID() != "" {
+		v.encrypted(path, s.KeyID())
+		return
+	}
+	for i := 0; i < s.NumChunks(); i++ {
+		chunk, err := s.Chunk(i)
+		if err != nil {
+			v.damage("%s: chunk %d: %v", path, i, err)
+			continue
+		}
+		v.chunk(path, i, -1, chunk)
+	}
+}
+
+// chunk checks chunk i of the frame at path, stored at offset in the frame
+// file or at an unknown place if offset is negative
+func (v *verifier) chunk(path string, i int, offset int64, chunk []byte) {
+	v.chunks++
+	err := blosc.Verify(chunk)
+	switch {
+	case err == nil:
+	case offset >= 0:
+		v.damage("%s: chunk %d at offset %d: %v", path, i, offset, err)
+	default:
+		v.damage("%s: chunk %d: %v", path, i, err)
+	}
+}
+
+func (v *verifier) damage(format string, args ...any) {
+	v.damaged++
+	fmt.Fprintf(v.w, format+"\n", args...)
+}
+
+func (v *verifier) unreadable(path string, err error) {
+	v.unchecked++
+	fmt.Fprintf(v.w, "%s: not checked: %v\n", path, err)
+}
+
+func (v *verifier) encrypted(path, keyID string) {
+	v.unchecked++
+	fmt.Fprintf(v.w, "%s: not checked: encrypted with key %q\n", path, keyID)
+}
+
+// result prints the summary and returns the error that sets the exit status
+func (v *verifier) result() error {
+	if !v.quiet {
+		fmt.Fprintf(v.w, "%d chunks in %d files: %d damaged, %d files not checked\n", v.chunks, v.files, v.damaged, v.unchecked)
+	}
+	switch {
+	case v.damaged > 0:
+		return &exitError{exitDamaged, fmt.Errorf("%d damaged chunks or frames", v.damaged)}
+	case v.unchecked > 0:
+		return &exitError{exitUnchecked, fmt.Errorf("%d files not checked", v.unchecked)}
+	}
+	return nil
+}
+
+// isFrameFile reports whether the file at path starts like a frame
+func isFrameFile(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	magic := make([]byte, len(frameMagic))
+	_, err = io.ReadFull(f, magic)
+	return err == nil && bytes.Equal(magic, []byte(frameMagic))
+}
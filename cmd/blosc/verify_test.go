@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	blosc "github.com/mrjoshuak/go-blosc"
+)
+
+// writeFrame writes a frame of three checksummed chunks to path
+func writeFrame(t *testing.T, path string) {
+	t.Helper()
+	s, err := blosc.CreateSChunkFile(path, blosc.Options{Codec: blosc.LZ4, Level: 5, Shuffle: blosc.Shuffle1, TypeSize: 4, BlockChecksums: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, 40000)
+	for i := range data {
+		data[i] = byte(i / 3)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := s.AppendBuffer(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// verify runs the verifier on paths and returns its report and exit status
+func verify(paths ...string) (string, int) {
+	var out bytes.Buffer
+	v := verifier{w: &out}
+	for _, path := range paths {
+		v.walk(path)
+	}
+	err := v.result()
+	var exit *exitError
+	switch {
+	case err == nil:
+		return out.String(), 0
+	case errors.As(err, &exit):
+		return out.String(), exit.code
+	}
+	return out.String(), -1
+}
+
+func TestVerify(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "archive", "good.gbframe")
+	if err := os.MkdirAll(filepath.Dir(good), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	writeFrame(t, good)
+	if err := runConvert([]string{"-sparse", good, filepath.Join(dir, "archive", "sparse")}); err != nil {
+		t.Fatal(err)
+	}
+	// Other files in the tree are not frames, and are skipped
+	if err := os.WriteFile(filepath.Join(dir, "archive", "README"), []byte("notes"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	report, code := verify(filepath.Join(dir, "archive"))
+	if code != 0 || !strings.Contains(report, "6 chunks in 2 files: 0 damaged") {
+		t.Fatalf("intact tree: status %d\n%s", code, report)
+	}
+
+	// A flipped byte in chunk 1 is found, at the chunk's offset
+	f, err := blosc.OpenFrameMmap(good)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offset, _, _ := f.ChunkOffset(1)
+	f.Close()
+	frame, err := os.ReadFile(good)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := int64(binary.LittleEndian.Uint32(frame[offset+blosc.ExtendedHeaderSize:]))
+	frame[offset+first+8] ^= 0xff
+	bad := filepath.Join(dir, "archive", "bad.gbframe")
+	if err := os.WriteFile(bad, frame, 0o666); err != nil {
+		t.Fatal(err)
+	}
+	report, code = verify(bad)
+	if code != exitDamaged || !strings.Contains(report, "chunk 1 at offset") || !strings.Contains(report, "checksum") {
+		t.Errorf("damaged chunk: status %d\n%s", code, report)
+	}
+
+	// So is a frame whose trailer is damaged, and a lost chunk file
+	frame[len(frame)-1] ^= 0xff
+	if err := os.WriteFile(bad, frame, 0o666); err != nil {
+		t.Fatal(err)
+	}
+	chunks, _ := filepath.Glob(filepath.Join(dir, "archive", "sparse", "*.chunk"))
+	if len(chunks) != 3 {
+		t.Fatalf("%d chunk files", len(chunks))
+	}
+	if err := os.Remove(chunks[2]); err != nil {
+		t.Fatal(err)
+	}
+	report, code = verify(filepath.Join(dir, "archive"))
+	if code != exitDamaged || strings.Count(report, "\n") != 3 || !strings.Contains(report, "5 chunks in 3 files: 2 damaged") {
+		t.Errorf("damaged tree: status %d\n%s", code, report)
+	}
+
+	// Encrypted frames and missing files cannot be checked
+	s, err := blosc.CreateSChunkFile(filepath.Join(dir, "secret.gbframe"), blosc.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetEncryption("k", blosc.StaticKeys{"k": make([]byte, 32)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	report, code = verify(filepath.Join(dir, "secret.gbframe"), filepath.Join(dir, "missing"))
+	if code != exitUnchecked || !strings.Contains(report, `encrypted with key "k"`) {
+		t.Errorf("unchecked: status %d\n%s", code, report)
+	}
+}
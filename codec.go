@@ -1,19 +1,21 @@
 package blosc
 
 import (
-	"bytes"
+	"encoding/binary"
 	"fmt"
-	"io"
+	"math"
+	"math/bits"
+	"slices"
+	"sync/atomic"
 
-	"github.com/klauspost/compress/snappy"
-	kzlib "github.com/klauspost/compress/zlib"
-	"github.com/klauspost/compress/zstd"
 	"github.com/pierrec/lz4/v4"
 )
 
 // CodecInterface defines the interface for compression codecs
 type CodecInterface interface {
-	// Compress compresses data with the given level (1-9)
+	// Compress compresses data with the given level (1-9). It may return
+	// ErrIncompressible instead of output no smaller than data, but never
+	// data itself as if it were compressed.
 	Compress(data []byte, level int) ([]byte, error)
 
 	// Decompress decompresses data to the expected size
@@ -23,18 +25,228 @@ type CodecInterface interface {
 	Name() string
 }
 
-// codecs maps codec IDs to implementations
+// codecs maps codec IDs to implementations. ZLIB, ZSTD and Snappy register
+// themselves from their own files, which the blosc_nozlib, blosc_nozstd and
+// blosc_nosnappy build tags leave out, so that binaries that do not need
+// them do not link their libraries.
 var codecs = map[Codec]CodecInterface{
-	LZ4:    &lz4Codec{},
-	LZ4HC:  &lz4hcCodec{},
-	ZLIB:   &zlibCodec{},
-	ZSTD:   &zstdCodec{},
-	Snappy: &snappyCodec{},
+	BloscLZ: &bloscLZCodec{},
+	LZ4:     &lz4Codec{},
+	LZ4HC:   &lz4hcCodec{},
 }
 
-// RegisterCodec registers a custom codec implementation
-func RegisterCodec(id Codec, codec CodecInterface) {
+// CodecCapabilities describes what a codec supports, so that applications can
+// choose a codec and level without trial and error.
+type CodecCapabilities struct {
+	Name           string // Codec name
+	MinLevel       int    // Lowest accepted level
+	MaxLevel       int    // Highest accepted level
+	DistinctLevels int    // Number of different settings the level range maps to
+	LevelsMatter   bool   // Whether the level changes the output at all
+	MaxInputSize   int    // Largest input a single Compress call accepts (0 = no limit)
+	Streaming      bool   // Whether the codec's format can be decoded incrementally
+}
+
+// CapableCodec is an optional interface for codecs that report their
+// capabilities through CodecInfo.
+type CapableCodec interface {
+	CodecInterface
+	Capabilities() CodecCapabilities
+}
+
+// CodecInfo returns the capabilities of a registered codec. Codecs that do not
+// implement CapableCodec are reported as accepting levels 1-9, each distinct,
+// with no input limit and no streaming support.
+func CodecInfo(id Codec) (CodecCapabilities, error) {
+	c, ok := codecs[id]
+	if !ok {
+		return CodecCapabilities{}, fmt.Errorf("%w: %s", ErrInvalidCodec, id)
+	}
+	if cc, ok := c.(CapableCodec); ok {
+		caps := cc.Capabilities()
+		caps.Name = c.Name()
+		return caps, nil
+	}
+	return CodecCapabilities{
+		Name:           c.Name(),
+		MinLevel:       1,
+		MaxLevel:       9,
+		DistinctLevels: 9,
+		LevelsMatter:   true,
+	}, nil
+}
+
+// lz4MaxInputSize is LZ4_MAX_INPUT_SIZE in the reference library
+const lz4MaxInputSize = 0x7E000000
+
+// Range of ZSTDParams.Level, as ZSTD_minCLevel and ZSTD_maxCLevel report it
+const (
+	zstdMinLevel = -1 << 17
+	zstdMaxLevel = 22
+)
+
+// paramCompressor is implemented by codecs that accept CodecParams
+type paramCompressor interface {
+	compressParams(data []byte, level int, params *CodecParams) ([]byte, error)
+}
+
+// configuredCompressor is implemented by codecs whose output depends on
+// process-wide settings. compressDefault compresses as if they were never
+// changed, for Options.Deterministic.
+type configuredCompressor interface {
+	compressDefault(data []byte, level int, params *CodecParams) ([]byte, error)
+}
+
+// codecCompress compresses data with c, passing CodecParams to codecs that
+// take them
+func codecCompress(c CodecInterface, data []byte, opts *Options) ([]byte, error) {
+	if dc, ok := c.(configuredCompressor); ok && opts.Deterministic {
+		return dc.compressDefault(data, opts.Level, &opts.CodecParams)
+	}
+	if pc, ok := c.(paramCompressor); ok && opts.CodecParams != (CodecParams{}) {
+		return pc.compressParams(data, opts.Level, &opts.CodecParams)
+	}
+	return c.Compress(data, opts.Level)
+}
+
+// codecScratch holds the codec state and output buffer that an Encoder
+// reuses from one stream to the next
+type codecScratch struct {
+	buf   []byte
+	lz4   *lz4.Compressor
+	lz4hc *lz4.CompressorHC
+	table []int32 // lz4AppendFast hash table
+	zlib  *zlibState
+}
+
+// scratchCompressor is implemented by codecs that can compress with reused
+// state. The result may share memory with s and is valid until the next
+// call.
+type scratchCompressor interface {
+	compressScratch(s *codecScratch, data []byte, level int, params *CodecParams) ([]byte, error)
+}
+
+// intoDecompressor is implemented by codecs that can decompress straight
+// into a buffer of the expected size. They return the number of bytes the
+// stream holds, which is more than len(dst) only for streams too large to
+// fit.
+type intoDecompressor interface {
+	decompressInto(dst, data []byte) (int, error)
+}
+
+// boundedDecompressor is implemented by codecs whose streams decode to at
+// most maxRatio times their own size, so that sizes claimed for a stream
+// can be checked before anything of that size is allocated
+type boundedDecompressor interface {
+	maxRatio() int
+}
+
+// maxDecodedSize is the cap SetMaxDecodedSize sets, or 0 for none
+var maxDecodedSize atomic.Int64
+
+// SetMaxDecodedSize caps the bytes any chunk or codec stream may decode to,
+// for processes that decode untrusted input: chunks whose header claims
+// more, and Decompress calls of the built-in codecs expecting more, fail
+// with ErrOutputTooLarge before any output is allocated, as with
+// DecompressLimited. n <= 0 removes the cap, the default. It returns the
+// previous cap, or 0.
+//
+// Whatever the cap, the built-in codecs also refuse an expected size that
+// their stream could not decode to, at the codec's largest expansion ratio
+// (about 255 for LZ4 and BloscLZ, 1032 for ZLIB), so a short stream cannot
+// claim gigabytes.
+func SetMaxDecodedSize(n int64) int64 {
+	if n < 0 {
+		n = 0
+	}
+	return maxDecodedSize.Swap(n)
+}
+
+// decodeLimit returns the lower of maxBytes, unless negative, and the cap
+// SetMaxDecodedSize sets, or -1 if there is neither
+func decodeLimit(maxBytes int) int {
+	limit := maxDecodedSize.Load()
+	if limit <= 0 || limit > math.MaxInt || maxBytes >= 0 && int64(maxBytes) <= limit {
+		return maxBytes
+	}
+	return int(limit)
+}
+
+// checkExpectedSize checks expectedSize, the size data is to decode to with
+// a codec that expands at most maxRatio times, before a buffer of that size
+// is allocated
+func checkExpectedSize(data []byte, expectedSize, maxRatio int) error {
+	if expectedSize < 0 {
+		return fmt.Errorf("%w: expected size %d", ErrInvalidData, expectedSize)
+	}
+	if limit := decodeLimit(-1); limit >= 0 && expectedSize > limit {
+		return fmt.Errorf("%w: expected %d bytes, limit is %d", ErrOutputTooLarge, expectedSize, limit)
+	}
+	if expectedSize/maxRatio > len(data) {
+		return fmt.Errorf("%w: %d bytes of stream cannot decode to %d", ErrInvalidData, len(data), expectedSize)
+	}
+	return nil
+}
+
+// codecCompressScratch is codecCompress, reusing s with codecs that can
+func codecCompressScratch(c CodecInterface, data []byte, opts *Options, s *codecScratch) ([]byte, error) {
+	if _, ok := c.(configuredCompressor); ok && opts.Deterministic {
+		return codecCompress(c, data, opts)
+	}
+	if sc, ok := c.(scratchCompressor); ok && s != nil {
+		return sc.compressScratch(s, data, opts.Level, &opts.CodecParams)
+	}
+	return codecCompress(c, data, opts)
+}
+
+// codecDecompressInto decompresses data into dst, which holds the expected
+// size, and returns the decompressed size
+func codecDecompressInto(c CodecInterface, dst, data []byte) (int, error) {
+	if d, ok := c.(intoDecompressor); ok {
+		return d.decompressInto(dst, data)
+	}
+	out, err := c.Decompress(data, len(dst))
+	if err != nil {
+		return 0, err
+	}
+	copy(dst, out)
+	return len(out), nil
+}
+
+// scratchBuffer returns buf resized to n bytes, reallocated only if it is
+// too small
+func scratchBuffer(buf []byte, n int) []byte {
+	if cap(buf) < n {
+		return make([]byte, n)
+	}
+	return buf[:n]
+}
+
+// Codec ID ranges, as c-blosc2 assigns them. IDs below CodecRegisteredStart
+// belong to the built-in codecs. IDs from CodecRegisteredStart to 159 are
+// those of the c-blosc2 plugin registry, such as NDLZ (32) and ZFP (33 to
+// 35), and IDs from CodecUserStart are free for any codec.
+const (
+	CodecRegisteredStart Codec = 32
+	CodecUserStart       Codec = 160
+)
+
+// RegisterCodec registers a codec under id, replacing any earlier
+// registration of it. Chunks it compresses have an extended header holding
+// id in UDCodec, as c-blosc2 writes chunks of its codec plugins, so a
+// c-blosc2 plugin registered under the same ID reads them and the other way
+// round. id must be a plugin registry ID the codec implements (32 to 159)
+// or a user ID (160 to 254): lower IDs belong to the built-in codecs and 255
+// is reserved, and those fail with ErrInvalidCodec.
+func RegisterCodec(id Codec, codec CodecInterface) error {
+	if id < CodecRegisteredStart || id == unknownCodec {
+		return fmt.Errorf("%w: ID %d is reserved; register codecs under 32 to 254", ErrInvalidCodec, id)
+	}
+	if codec == nil {
+		return fmt.Errorf("%w: nil codec for ID %d", ErrInvalidCodec, id)
+	}
 	codecs[id] = codec
+	return nil
 }
 
 // GetCodec returns the codec implementation for the given ID
@@ -60,7 +272,38 @@ type lz4Codec struct{}
 
 func (c *lz4Codec) Name() string { return "lz4" }
 
+func (c *lz4Codec) Capabilities() CodecCapabilities {
+	// Levels 1-3 select accelerations, and 4-9 the default compressor
+	return CodecCapabilities{MinLevel: 1, MaxLevel: 9, DistinctLevels: 4, LevelsMatter: true, MaxInputSize: lz4MaxInputSize}
+}
+
+// lz4Acceleration returns the acceleration factor for a level, unless
+// LZ4Params.Acceleration sets one: 8, 4 and 2 for levels 1 to 3, trading
+// ratio for speed, and 1, the default compressor, above
+func lz4Acceleration(level int, params *CodecParams) int {
+	if params.LZ4.Acceleration > 0 {
+		return params.LZ4.Acceleration
+	}
+	switch level {
+	case 1:
+		return 8
+	case 2:
+		return 4
+	case 3:
+		return 2
+	default:
+		return 1
+	}
+}
+
 func (c *lz4Codec) Compress(data []byte, level int) ([]byte, error) {
+	return c.compressParams(data, level, &CodecParams{})
+}
+
+func (c *lz4Codec) compressParams(data []byte, level int, params *CodecParams) ([]byte, error) {
+	if accel := lz4Acceleration(level, params); accel > 1 {
+		return lz4CompressFast(data, accel), nil
+	}
 	// LZ4 standard compression
 	buf := make([]byte, lz4.CompressBlockBound(len(data)))
 	n, err := lz4.CompressBlock(data, buf, nil)
@@ -68,177 +311,255 @@ func (c *lz4Codec) Compress(data []byte, level int) ([]byte, error) {
 		return nil, fmt.Errorf("lz4 compress: %w", err)
 	}
 	if n == 0 {
-		// Data is incompressible, return as-is
-		return data, nil
+		return nil, ErrIncompressible
 	}
 	return buf[:n], nil
 }
 
-func (c *lz4Codec) Decompress(data []byte, expectedSize int) ([]byte, error) {
-	buf := make([]byte, expectedSize)
-	n, err := lz4.UncompressBlock(data, buf)
+func (c *lz4Codec) compressScratch(s *codecScratch, data []byte, level int, params *CodecParams) ([]byte, error) {
+	if accel := lz4Acceleration(level, params); accel > 1 {
+		if s.table == nil {
+			s.table = make([]int32, 1<<lz4HashLog)
+		}
+		s.buf = lz4AppendFast(s.buf[:0], data, accel, s.table)
+		return s.buf, nil
+	}
+	if s.lz4 == nil {
+		s.lz4 = new(lz4.Compressor)
+	}
+	s.buf = scratchBuffer(s.buf, lz4.CompressBlockBound(len(data)))
+	n, err := s.lz4.CompressBlock(data, s.buf)
 	if err != nil {
-		return nil, fmt.Errorf("lz4 decompress: %w", err)
+		return nil, fmt.Errorf("lz4 compress: %w", err)
 	}
-	return buf[:n], nil
+	if n == 0 {
+		return nil, ErrIncompressible
+	}
+	return s.buf[:n], nil
 }
 
-// =============================================================================
-// LZ4HC Codec (High Compression)
-// =============================================================================
-
-type lz4hcCodec struct{}
+// lz4MaxRatio bounds LZ4 expansion: beyond a sequence's first bytes, each
+// byte of match length adds at most 255 bytes
+const lz4MaxRatio = 255
 
-func (c *lz4hcCodec) Name() string { return "lz4hc" }
+func (c *lz4Codec) maxRatio() int { return lz4MaxRatio }
 
-func (c *lz4hcCodec) Compress(data []byte, level int) ([]byte, error) {
-	// Map 1-9 to LZ4 compression levels
-	var lz4Level lz4.CompressionLevel
-	switch {
-	case level <= 3:
-		lz4Level = lz4.Level1
-	case level <= 5:
-		lz4Level = lz4.Level5
-	case level <= 7:
-		lz4Level = lz4.Level7
-	default:
-		lz4Level = lz4.Level9
+func (c *lz4Codec) Decompress(data []byte, expectedSize int) ([]byte, error) {
+	if err := checkExpectedSize(data, expectedSize, lz4MaxRatio); err != nil {
+		return nil, err
 	}
-
-	buf := make([]byte, lz4.CompressBlockBound(len(data)))
-	ht := make([]int, 1<<16) // Hash table for HC
-	n, err := lz4.CompressBlockHC(data, buf, lz4Level, ht, nil)
+	buf := make([]byte, expectedSize)
+	n, err := c.decompressInto(buf, data)
 	if err != nil {
-		return nil, fmt.Errorf("lz4hc compress: %w", err)
-	}
-	if n == 0 {
-		return data, nil
+		return nil, err
 	}
 	return buf[:n], nil
 }
 
-func (c *lz4hcCodec) Decompress(data []byte, expectedSize int) ([]byte, error) {
-	// Decompression is the same as standard LZ4
-	buf := make([]byte, expectedSize)
-	n, err := lz4.UncompressBlock(data, buf)
+func (c *lz4Codec) decompressInto(dst, data []byte) (int, error) {
+	n, err := lz4.UncompressBlock(data, dst)
 	if err != nil {
-		return nil, fmt.Errorf("lz4hc decompress: %w", err)
+		return 0, fmt.Errorf("lz4 decompress: %w", err)
 	}
-	return buf[:n], nil
+	return n, nil
 }
 
-// =============================================================================
-// ZLIB Codec (using klauspost/compress for better performance)
-// =============================================================================
-
-type zlibCodec struct{}
+// LZ4 block format constants
+const (
+	lz4MinMatch     = 4
+	lz4HashLog      = 12 // 16 KB table, the reference default
+	lz4SkipTrigger  = 6  // Misses before the search step grows by one
+	lz4LastLiterals = 5  // The last 5 bytes are always literals
+	lz4MFLimit      = 12 // The last match must start 12 bytes before the end
+	lz4MaxOffset    = 65535
+)
 
-func (c *zlibCodec) Name() string { return "zlib" }
+// lz4CompressFast compresses data into an LZ4 block with the acceleration
+// factor of the reference library's LZ4_compress_fast: the search starts with
+// a step of acceleration bytes and widens after every 64 misses. The output
+// decodes with any LZ4 block decoder.
+func lz4CompressFast(data []byte, acceleration int) []byte {
+	// The fixed-size table stays on the stack
+	dst := make([]byte, 0, lz4.CompressBlockBound(len(data)))
+	return lz4AppendFast(dst, data, acceleration, make([]int32, 1<<lz4HashLog))
+}
 
-func (c *zlibCodec) Compress(data []byte, level int) ([]byte, error) {
-	var buf bytes.Buffer
-	w, err := kzlib.NewWriterLevel(&buf, level)
-	if err != nil {
-		return nil, fmt.Errorf("zlib create writer: %w", err)
+// lz4AppendFast is lz4CompressFast appending to dst, with a hash table of
+// 1<<lz4HashLog entries that it clears before use
+func lz4AppendFast(dst, data []byte, acceleration int, table []int32) []byte {
+	n := len(data)
+	dst = slices.Grow(dst, lz4.CompressBlockBound(n))
+	anchor := 0
+
+	if n >= lz4MFLimit+1 {
+		// Positions are stored +1 so that zero means empty
+		clear(table)
+		matchLimit := n - lz4LastLiterals
+		mfLimit := n - lz4MFLimit
+		si := 0
+
+	search:
+		for {
+			step := 1
+			searchMatchNb := acceleration << lz4SkipTrigger
+			var ref int
+			for {
+				if si > mfLimit {
+					break search
+				}
+				h := lz4Hash(binary.LittleEndian.Uint32(data[si:]))
+				ref = int(table[h]) - 1
+				table[h] = int32(si + 1)
+				if ref >= 0 && si-ref <= lz4MaxOffset &&
+					binary.LittleEndian.Uint32(data[ref:]) == binary.LittleEndian.Uint32(data[si:]) {
+					break
+				}
+				si += step
+				step = searchMatchNb >> lz4SkipTrigger
+				searchMatchNb++
+			}
+
+			// Extend the match backwards over pending literals, then forwards
+			for si > anchor && ref > 0 && data[si-1] == data[ref-1] {
+				si--
+				ref--
+			}
+			matchLen := lz4MinMatch + lz4CommonPrefix(data[si+lz4MinMatch:matchLimit], data[ref+lz4MinMatch:])
+
+			dst = lz4AppendSequence(dst, data[anchor:si], si-ref, matchLen)
+			si += matchLen
+			anchor = si
+		}
 	}
-	if _, err := w.Write(data); err != nil {
-		w.Close()
-		return nil, fmt.Errorf("zlib write: %w", err)
+
+	return lz4AppendSequence(dst, data[anchor:], 0, 0)
+}
+
+// lz4CommonPrefix returns the length of the common prefix of a and b, where
+// len(b) >= len(a)
+func lz4CommonPrefix(a, b []byte) int {
+	n := 0
+	for ; n+8 <= len(a); n += 8 {
+		if diff := binary.LittleEndian.Uint64(a[n:]) ^ binary.LittleEndian.Uint64(b[n:]); diff != 0 {
+			return n + bits.TrailingZeros64(diff)/8
+		}
 	}
-	if err := w.Close(); err != nil {
-		return nil, fmt.Errorf("zlib close: %w", err)
+	for n < len(a) && a[n] == b[n] {
+		n++
 	}
-	return buf.Bytes(), nil
+	return n
 }
 
-func (c *zlibCodec) Decompress(data []byte, expectedSize int) ([]byte, error) {
-	r, err := kzlib.NewReader(bytes.NewReader(data))
-	if err != nil {
-		return nil, fmt.Errorf("zlib create reader: %w", err)
+// lz4Hash hashes 4 bytes into a table index
+func lz4Hash(v uint32) uint32 {
+	return (v * 2654435761) >> (32 - lz4HashLog)
+}
+
+// lz4AppendSequence appends one LZ4 sequence: the literals followed by a match
+// of matchLen bytes at offset. An offset of 0 ends the block with literals only.
+func lz4AppendSequence(dst, literals []byte, offset, matchLen int) []byte {
+	litLen := len(literals)
+	token := byte(min(litLen, 15)) << 4
+	if offset > 0 {
+		token |= byte(min(matchLen-lz4MinMatch, 15))
+	}
+	dst = append(dst, token)
+	if litLen >= 15 {
+		dst = lz4AppendLength(dst, litLen-15)
 	}
-	defer r.Close()
+	dst = append(dst, literals...)
+	if offset == 0 {
+		return dst
+	}
+	dst = append(dst, byte(offset), byte(offset>>8))
+	if matchLen-lz4MinMatch >= 15 {
+		dst = lz4AppendLength(dst, matchLen-lz4MinMatch-15)
+	}
+	return dst
+}
 
-	buf := make([]byte, expectedSize)
-	n, err := io.ReadFull(r, buf)
-	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
-		return nil, fmt.Errorf("zlib read: %w", err)
+// lz4AppendLength appends the 255-run encoding of a length extension
+func lz4AppendLength(dst []byte, n int) []byte {
+	for ; n >= 255; n -= 255 {
+		dst = append(dst, 255)
 	}
-	return buf[:n], nil
+	return append(dst, byte(n))
 }
 
 // =============================================================================
-// ZSTD Codec (with persistent encoders/decoders for performance)
+// LZ4HC Codec (High Compression)
 // =============================================================================
 
-type zstdCodec struct{}
+type lz4hcCodec struct{}
+
+func (c *lz4hcCodec) Name() string { return "lz4hc" }
 
-func (c *zstdCodec) Name() string { return "zstd" }
+func (c *lz4hcCodec) Capabilities() CodecCapabilities {
+	return CodecCapabilities{MinLevel: 1, MaxLevel: 9, DistinctLevels: 4, LevelsMatter: true, MaxInputSize: lz4MaxInputSize}
+}
 
-// Persistent ZSTD encoders by level - initialized once, reused forever.
-// EncodeAll is concurrent-safe, so multiple goroutines can share these.
-var zstdEncoders = func() [4]*zstd.Encoder {
-	var encoders [4]*zstd.Encoder
-	levels := []zstd.EncoderLevel{
-		zstd.SpeedFastest,
-		zstd.SpeedDefault,
-		zstd.SpeedBetterCompression,
-		zstd.SpeedBestCompression,
+func (c *lz4hcCodec) Compress(data []byte, level int) ([]byte, error) {
+	// The library pools the hash tables of one-shot calls
+	buf := make([]byte, lz4.CompressBlockBound(len(data)))
+	n, err := lz4.CompressBlockHC(data, buf, lz4hcLevel(level), nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("lz4hc compress: %w", err)
 	}
-	for i, level := range levels {
-		e, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(level))
-		encoders[i] = e
+	if n == 0 {
+		return nil, ErrIncompressible
 	}
-	return encoders
-}()
+	return buf[:n], nil
+}
 
-// Persistent ZSTD decoder - DecodeAll is concurrent-safe.
-var zstdDecoder = func() *zstd.Decoder {
-	d, _ := zstd.NewReader(nil)
-	return d
-}()
+func (c *lz4hcCodec) compressScratch(s *codecScratch, data []byte, level int, params *CodecParams) ([]byte, error) {
+	if s.lz4hc == nil {
+		s.lz4hc = new(lz4.CompressorHC)
+	}
+	s.lz4hc.Level = lz4hcLevel(level)
+	s.buf = scratchBuffer(s.buf, lz4.CompressBlockBound(len(data)))
+	n, err := s.lz4hc.CompressBlock(data, s.buf)
+	if err != nil {
+		return nil, fmt.Errorf("lz4hc compress: %w", err)
+	}
+	if n == 0 {
+		return nil, ErrIncompressible
+	}
+	return s.buf[:n], nil
+}
 
-func (c *zstdCodec) Compress(data []byte, level int) ([]byte, error) {
-	// Map 1-9 to encoder index (0-3)
-	var idx int
+// lz4hcLevel maps levels 1-9 to LZ4HC search depths
+func lz4hcLevel(level int) lz4.CompressionLevel {
 	switch {
-	case level <= 2:
-		idx = 0
-	case level <= 4:
-		idx = 1
-	case level <= 6:
-		idx = 2
+	case level <= 3:
+		return lz4.Level1
+	case level <= 5:
+		return lz4.Level5
+	case level <= 7:
+		return lz4.Level7
 	default:
-		idx = 3
+		return lz4.Level9
 	}
-	return zstdEncoders[idx].EncodeAll(data, nil), nil
 }
 
-func (c *zstdCodec) Decompress(data []byte, expectedSize int) ([]byte, error) {
-	buf, err := zstdDecoder.DecodeAll(data, make([]byte, 0, expectedSize))
+func (c *lz4hcCodec) maxRatio() int { return lz4MaxRatio }
+
+func (c *lz4hcCodec) Decompress(data []byte, expectedSize int) ([]byte, error) {
+	// Decompression is the same as standard LZ4
+	if err := checkExpectedSize(data, expectedSize, lz4MaxRatio); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, expectedSize)
+	n, err := c.decompressInto(buf, data)
 	if err != nil {
-		return nil, fmt.Errorf("zstd decode: %w", err)
+		return nil, err
 	}
-	return buf, nil
-}
-
-// =============================================================================
-// Snappy Codec
-// =============================================================================
-
-type snappyCodec struct{}
-
-func (c *snappyCodec) Name() string { return "snappy" }
-
-func (c *snappyCodec) Compress(data []byte, level int) ([]byte, error) {
-	// Snappy doesn't have compression levels
-	return snappy.Encode(nil, data), nil
+	return buf[:n], nil
 }
 
-func (c *snappyCodec) Decompress(data []byte, expectedSize int) ([]byte, error) {
-	buf := make([]byte, expectedSize)
-	result, err := snappy.Decode(buf, data)
+func (c *lz4hcCodec) decompressInto(dst, data []byte) (int, error) {
+	n, err := lz4.UncompressBlock(data, dst)
 	if err != nil {
-		return nil, fmt.Errorf("snappy decode: %w", err)
+		return 0, fmt.Errorf("lz4hc decompress: %w", err)
 	}
-	return result, nil
+	return n, nil
 }
@@ -0,0 +1,6 @@
+//go:build blosc_nozlib
+
+package blosc
+
+// zlibState is the zlib codec's Encoder state, which this build leaves out
+type zlibState struct{}
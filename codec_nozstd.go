@@ -0,0 +1,25 @@
+//go:build blosc_nozstd
+
+package blosc
+
+import "fmt"
+
+// ConfigureZSTD fails with ErrInvalidCodec, since the blosc_nozstd build tag
+// leaves the ZSTD codec out.
+func ConfigureZSTD(cfg ZSTDConfig) error {
+	return fmt.Errorf("%w: zstd is left out by the blosc_nozstd build tag", ErrInvalidCodec)
+}
+
+// zstdDictCodec stands in for the codec of a ZSTDDict, which NewZSTDDict
+// cannot make without the ZSTD codec
+type zstdDictCodec struct{}
+
+func newZSTDDictCodec(d *ZSTDDict) (*zstdDictCodec, error) {
+	return nil, fmt.Errorf("%w: zstd is left out by the blosc_nozstd build tag", ErrInvalidCodec)
+}
+
+// decompressor is never called, since no ZSTDDict is made and ZSTD chunks
+// fail before their dictionary is needed
+func (d *ZSTDDict) decompressor() CodecInterface {
+	return nil
+}
@@ -0,0 +1,144 @@
+//go:build !blosc_nosnappy
+
+package blosc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"slices"
+	"strconv"
+
+	"github.com/klauspost/compress/snappy"
+)
+
+// Snappy codec. Building with the blosc_nosnappy tag leaves it out.
+
+// snappyMaxInputSize is the input limit of the snappy block format, which
+// stores lengths as uint32: no limit where int is 32 bits
+const snappyMaxInputSize = (1<<32 - 1) >> (64 - strconv.IntSize)
+
+// snappyWasmSegment is the input size above which snappyEncode splits its
+// input on WebAssembly: the encoder recurses once per 64 bytes of a long
+// match, and WebAssembly runs Go calls on the engine's fixed-size native
+// stack, so a few megabytes of zeros overflow it. 64 KB is the fragment size
+// of the reference snappy encoder.
+const snappyWasmSegment = 64 << 10
+
+// snappyStreamID is the chunk that starts a stream in the snappy framing
+// format, which some writers put in place of a bare block
+var snappyStreamID = []byte("\xff\x06\x00\x00sNaPpY")
+
+type snappyCodec struct{}
+
+func init() {
+	codecs[Snappy] = &snappyCodec{}
+}
+
+func (c *snappyCodec) Name() string { return "snappy" }
+
+func (c *snappyCodec) Capabilities() CodecCapabilities {
+	return CodecCapabilities{MinLevel: 1, MaxLevel: 9, DistinctLevels: 1, MaxInputSize: snappyMaxInputSize}
+}
+
+func (c *snappyCodec) Compress(data []byte, level int) ([]byte, error) {
+	// Snappy doesn't have compression levels
+	return snappyEncode(nil, data), nil
+}
+
+func (c *snappyCodec) compressScratch(s *codecScratch, data []byte, level int, params *CodecParams) ([]byte, error) {
+	s.buf = scratchBuffer(s.buf, snappy.MaxEncodedLen(len(data)))
+	return snappyEncode(s.buf, data), nil
+}
+
+// snappyEncode is snappy.Encode, except that on WebAssembly it encodes inputs
+// larger than snappyWasmSegment in segments
+func snappyEncode(dst, src []byte) []byte {
+	if runtime.GOARCH != "wasm" || len(src) <= snappyWasmSegment {
+		return snappy.Encode(dst, src)
+	}
+	return snappyEncodeSegments(dst, src, snappyWasmSegment)
+}
+
+// snappyEncodeSegments encodes src as one snappy stream whose copies stay
+// within segments of at most segment bytes. A stream is its decoded length
+// followed by literals and copies, so the streams of consecutive segments
+// join into one once their length prefixes are replaced.
+func snappyEncodeSegments(dst, src []byte, segment int) []byte {
+	if n := snappy.MaxEncodedLen(len(src)); cap(dst) < n {
+		dst = make([]byte, 0, n)
+	}
+	dst = binary.AppendUvarint(dst[:0], uint64(len(src)))
+	for len(src) > 0 {
+		// Encode in place after the output so far, then move the stream
+		// back over its length prefix
+		n := min(len(src), segment)
+		dst = slices.Grow(dst, snappy.MaxEncodedLen(n))
+		enc := snappy.Encode(dst[len(dst):cap(dst)], src[:n])
+		_, k := binary.Uvarint(enc)
+		dst = append(dst, enc[k:]...)
+		src = src[n:]
+	}
+	return dst
+}
+
+// maxRatio bounds Snappy expansion: a 3-byte copy yields at most 64 bytes
+func (c *snappyCodec) maxRatio() int { return 22 }
+
+func (c *snappyCodec) Decompress(data []byte, expectedSize int) ([]byte, error) {
+	if err := checkExpectedSize(data, expectedSize, c.maxRatio()); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, expectedSize)
+	n, err := c.decompressInto(buf, data)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+func (c *snappyCodec) decompressInto(dst, data []byte) (int, error) {
+	// Decode allocates a larger buffer when the stream claims more than
+	// dst holds, so refuse those streams up front, and shorter ones too.
+	// The stream identifier also reads as the start of a block of 895
+	// bytes, so framed streams are told apart by the length.
+	n, err := snappy.DecodedLen(data)
+	if (err != nil || n != len(dst)) && bytes.HasPrefix(data, snappyStreamID) {
+		return snappyDecodeFramed(dst, data)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("snappy decode: %w", err)
+	}
+	if n != len(dst) {
+		return 0, fmt.Errorf("%w: snappy stream holds %d bytes, expected %d", ErrSizeMismatch, n, len(dst))
+	}
+	result, err := snappy.Decode(dst, data)
+	if err != nil {
+		return 0, fmt.Errorf("snappy decode: %w", err)
+	}
+	return len(result), nil
+}
+
+// snappyDecodeFramed decodes a stream in the snappy framing format, checking
+// the CRC32C of each of its chunks, into dst, which it must fill exactly
+func snappyDecodeFramed(dst, data []byte) (int, error) {
+	r := snappy.NewReader(bytes.NewReader(data))
+	n, err := io.ReadFull(r, dst)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return 0, fmt.Errorf("%w: framed snappy stream holds %d bytes, expected %d", ErrSizeMismatch, n, len(dst))
+	}
+	if err == nil {
+		var extra [1]byte
+		var more int
+		if more, err = r.Read(extra[:]); more > 0 {
+			return 0, fmt.Errorf("%w: framed snappy stream holds more than %d bytes", ErrSizeMismatch, len(dst))
+		}
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return 0, fmt.Errorf("snappy decode: %w", err)
+	}
+	return n, nil
+}
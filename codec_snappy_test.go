@@ -0,0 +1,81 @@
+//go:build !blosc_nosnappy
+
+package blosc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/klauspost/compress/snappy"
+)
+
+func TestSnappyEncodeSegments(t *testing.T) {
+	c, _ := GetCodec(Snappy)
+	inputs := map[string][]byte{
+		"zeros":   make([]byte, 300000),
+		"pattern": makeTestData(300001),
+		"noise":   makeTestDataPure(70001),
+		"short":   makeTestData(1000),
+	}
+	for name, data := range inputs {
+		for _, segment := range []int{1 << 10, 64 << 10, len(data)} {
+			stream := snappyEncodeSegments(nil, data, segment)
+			got, err := c.Decompress(stream, len(data))
+			if err != nil || !bytes.Equal(got, data) {
+				t.Errorf("%s in %d-byte segments: %v", name, segment, err)
+			}
+		}
+	}
+}
+
+func TestSnappyDecompressSize(t *testing.T) {
+	c, _ := GetCodec(Snappy)
+	data := makeTestData(100000)
+	compressed, err := c.Compress(data, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, size := range []int{len(data) - 1, len(data) + 1} {
+		if _, err := c.Decompress(compressed, size); !errors.Is(err, ErrSizeMismatch) {
+			t.Errorf("expected size %d: expected ErrSizeMismatch, got %v", size, err)
+		}
+	}
+
+	// The framing format, as snappy.NewBufferedWriter writes it, in frames
+	// of up to 64 KB
+	var framed bytes.Buffer
+	w := snappy.NewBufferedWriter(&framed)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.Decompress(framed.Bytes(), len(data))
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("framed stream does not decode: %v", err)
+	}
+	for _, size := range []int{len(data) - 1, len(data) + 1} {
+		if _, err := c.Decompress(framed.Bytes(), size); !errors.Is(err, ErrSizeMismatch) {
+			t.Errorf("framed, expected size %d: expected ErrSizeMismatch, got %v", size, err)
+		}
+	}
+	corrupt := bytes.Clone(framed.Bytes())
+	corrupt[len(snappyStreamID)+4] ^= 0xFF // CRC of the first frame
+	if _, err := c.Decompress(corrupt, len(data)); err == nil {
+		t.Error("expected a checksum error")
+	}
+
+	// Framed streams decode in chunks too
+	chunk, err := CompressWithOptions(data, Options{Codec: Snappy, Level: 5, TypeSize: 1, LegacyFormat: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunk = append(chunk[:HeaderSize], framed.Bytes()...)
+	binary.LittleEndian.PutUint32(chunk[offsetNBytesComp:], uint32(len(chunk)))
+	if got, err := Decompress(chunk); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("chunk with a framed stream does not decode: %v", err)
+	}
+}
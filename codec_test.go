@@ -5,8 +5,14 @@ import (
 	cryptorand "crypto/rand"
 	"encoding/binary"
 	"errors"
+	"fmt"
+	"io"
 	"math/rand"
+	"runtime"
+	"strings"
 	"testing"
+
+	"github.com/pierrec/lz4/v4"
 )
 
 // =============================================================================
@@ -34,6 +40,55 @@ func BenchmarkBitShuffleOnly(b *testing.B) {
 	}
 }
 
+// BenchmarkShuffleGeneric measures the portable byte shuffle, which SIMD
+// kernels do not cover for most element sizes, on buffers in and out of cache
+func BenchmarkShuffleGeneric(b *testing.B) {
+	defer func(off bool) { simdOff.Store(off) }(simdOff.Load())
+	DisableSIMD()
+	for _, size := range []int{100 << 10, 16 << 20} {
+		data := makeTestDataPure(size)
+		dst := make([]byte, size)
+		for _, typeSize := range []int{2, 4, 8, 16} {
+			shuffled := shuffleBytes(data, typeSize)
+			b.Run(fmt.Sprintf("shuffle-%d-%dKB", typeSize, size>>10), func(b *testing.B) {
+				b.SetBytes(int64(size))
+				for i := 0; i < b.N; i++ {
+					shuffleBytesTo(dst, data, typeSize)
+				}
+			})
+			b.Run(fmt.Sprintf("unshuffle-%d-%dKB", typeSize, size>>10), func(b *testing.B) {
+				b.SetBytes(int64(size))
+				for i := 0; i < b.N; i++ {
+					unshuffleBytesTo(dst, shuffled, typeSize)
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkBitShuffleGeneric measures the portable bit transpose, which
+// every platform without AVX-512 uses, on 4- and 8-byte elements
+func BenchmarkBitShuffleGeneric(b *testing.B) {
+	defer func(off bool) { simdOff.Store(off) }(simdOff.Load())
+	DisableSIMD()
+	data := makeTestDataPure(102400)
+	for _, typeSize := range []int{4, 8} {
+		shuffled := bitShuffle(data, typeSize)
+		b.Run(fmt.Sprintf("shuffle-%d", typeSize), func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				_ = bitShuffle(data, typeSize)
+			}
+		})
+		b.Run(fmt.Sprintf("unshuffle-%d", typeSize), func(b *testing.B) {
+			b.SetBytes(int64(len(shuffled)))
+			for i := 0; i < b.N; i++ {
+				_ = bitUnshuffle(shuffled, typeSize)
+			}
+		})
+	}
+}
+
 func TestDecompressWithSizeUnknownCodec(t *testing.T) {
 	// Create a valid header but with unknown codec ID
 	header := make([]byte, HeaderSize)
@@ -464,11 +519,358 @@ func TestDecompressInvalidDataSize(t *testing.T) {
 	data := append(header, make([]byte, 50)...)
 
 	_, err := Decompress(data)
-	if err != ErrInvalidData {
+	if !errors.Is(err, ErrInvalidData) {
 		t.Errorf("expected ErrInvalidData, got %v", err)
 	}
 }
 
+// =============================================================================
+// Codec Parameter Tests
+// =============================================================================
+
+func TestLZ4CompressFastRoundTrip(t *testing.T) {
+	random := make([]byte, 50000)
+	_, _ = cryptorand.Read(random)
+	text := bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog "), 2000)
+	mixed := append(append([]byte{}, text[:20000]...), random[:20000]...)
+
+	inputs := map[string][]byte{
+		"empty":  {},
+		"tiny":   []byte("abcdefghijkl"),
+		"short":  []byte("abcabcabcabcabcabc"),
+		"zeros":  make([]byte, 70000),
+		"text":   text,
+		"random": random,
+		"mixed":  mixed,
+		"ramp":   makeTestDataPure(100000),
+	}
+
+	for name, data := range inputs {
+		for _, accel := range []int{1, 2, 8, 65} {
+			compressed := lz4CompressFast(data, accel)
+			out := make([]byte, len(data))
+			n, err := lz4.UncompressBlock(compressed, out)
+			if err != nil {
+				t.Fatalf("%s/accel=%d: decode failed: %v", name, accel, err)
+			}
+			if !bytes.Equal(out[:n], data) {
+				t.Errorf("%s/accel=%d: round-trip mismatch", name, accel)
+			}
+		}
+	}
+}
+
+func TestCodecParamsRoundTrip(t *testing.T) {
+	data := makeTestData(200000)
+
+	testCases := []struct {
+		name   string
+		codec  Codec
+		params CodecParams
+	}{
+		{"lz4 accel 4", LZ4, CodecParams{LZ4: LZ4Params{Acceleration: 4}}},
+		{"lz4 accel 1", LZ4, CodecParams{LZ4: LZ4Params{Acceleration: 1}}},
+		{"zstd window 12", ZSTD, CodecParams{ZSTD: ZSTDParams{WindowLog: 12}}},
+		{"zstd window clamped low", ZSTD, CodecParams{ZSTD: ZSTDParams{WindowLog: 1}}},
+		{"zstd window clamped high", ZSTD, CodecParams{ZSTD: ZSTDParams{WindowLog: 60}}},
+		{"zstd level 22", ZSTD, CodecParams{ZSTD: ZSTDParams{Level: 22}}},
+		{"zstd level -5 window 16", ZSTD, CodecParams{ZSTD: ZSTDParams{Level: -5, WindowLog: 16}}},
+		{"zlib huffman only", ZLIB, CodecParams{ZLIB: ZLIBParams{HuffmanOnly: true}}},
+		{"params for other codec", Snappy, CodecParams{LZ4: LZ4Params{Acceleration: 8}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := Options{Codec: tc.codec, Level: 5, Shuffle: Shuffle1, TypeSize: 4, CodecParams: tc.params}
+			compressed, err := CompressWithOptions(data, opts)
+			if err != nil {
+				t.Fatalf("compress failed: %v", err)
+			}
+			decompressed, err := Decompress(compressed)
+			if err != nil {
+				t.Fatalf("decompress failed: %v", err)
+			}
+			if !bytes.Equal(data, decompressed) {
+				t.Error("data mismatch")
+			}
+		})
+	}
+}
+
+func TestCodecParamsChangeOutput(t *testing.T) {
+	data := makeTestData(100000)
+
+	plain, err := CompressWithOptions(data, Options{Codec: ZLIB, Level: 9, TypeSize: 4})
+	if err != nil {
+		t.Fatalf("compress failed: %v", err)
+	}
+	huffman, err := CompressWithOptions(data, Options{Codec: ZLIB, Level: 9, TypeSize: 4,
+		CodecParams: CodecParams{ZLIB: ZLIBParams{HuffmanOnly: true}}})
+	if err != nil {
+		t.Fatalf("compress failed: %v", err)
+	}
+	if bytes.Equal(plain, huffman) {
+		t.Error("HuffmanOnly did not change zlib output")
+	}
+
+	// Higher acceleration searches less and should not compress better
+	slow := lz4CompressFast(data, 1)
+	fast := lz4CompressFast(data, 32)
+	if len(fast) < len(slow) {
+		t.Errorf("acceleration 32 produced %d bytes, acceleration 1 produced %d", len(fast), len(slow))
+	}
+}
+
+func TestZSTDLevelMapping(t *testing.T) {
+	data := makeFloatData(25000)
+	compress := func(level, zstdLevel int) []byte {
+		t.Helper()
+		chunk, err := CompressWithOptions(data, Options{Codec: ZSTD, Level: level, Shuffle: Shuffle1, TypeSize: 4,
+			CodecParams: CodecParams{ZSTD: ZSTDParams{Level: zstdLevel}}})
+		if err != nil {
+			t.Fatalf("compress failed: %v", err)
+		}
+		if got, err := Decompress(chunk); err != nil || !bytes.Equal(got, data) {
+			t.Fatalf("zstd level %d: round trip failed: %v", zstdLevel, err)
+		}
+		return chunk
+	}
+
+	// zstd levels override Options.Level and share its four strategies
+	for _, tc := range []struct{ level, zstdLevel int }{{1, 1}, {1, 2}, {3, 3}, {5, 7}, {9, 19}, {9, 40}} {
+		if !bytes.Equal(compress(5, tc.zstdLevel), compress(tc.level, 0)) {
+			t.Errorf("zstd level %d differs from level %d", tc.zstdLevel, tc.level)
+		}
+	}
+	// Negative levels skip entropy coding of literals
+	fast, fastest := compress(5, -3), compress(1, 0)
+	if len(fast) <= len(fastest) {
+		t.Errorf("zstd level -3 gave %d bytes, no more than level 1's %d", len(fast), len(fastest))
+	}
+	if !bytes.Equal(fast, compress(5, -100)) {
+		t.Error("negative zstd levels differ")
+	}
+}
+
+func TestLZ4LevelAcceleration(t *testing.T) {
+	data := shuffleBytes(makeFloatData(25000), 4)
+	codec, _ := GetCodec(LZ4)
+	for level, accel := range map[int]int{1: 8, 2: 4, 3: 2} {
+		out, err := codec.Compress(data, level)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(out, lz4CompressFast(data, accel)) {
+			t.Errorf("level %d does not compress with acceleration %d", level, accel)
+		}
+	}
+	fastest, _ := codec.Compress(data, 1)
+	def, _ := codec.Compress(data, 4)
+	if len(fastest) <= len(def) {
+		t.Errorf("level 1 gave %d bytes, no more than level 4's %d", len(fastest), len(def))
+	}
+	for level := 5; level <= 9; level++ {
+		if out, _ := codec.Compress(data, level); !bytes.Equal(out, def) {
+			t.Errorf("level %d output differs from level 4", level)
+		}
+	}
+
+	// An explicit acceleration overrides the level
+	opts := Options{Codec: LZ4, Level: 1, TypeSize: 4, CodecParams: CodecParams{LZ4: LZ4Params{Acceleration: 1}}}
+	explicit, err := codecCompress(codec, data, &opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(explicit, def) {
+		t.Error("acceleration 1 at level 1 differs from the default compressor")
+	}
+}
+
+func TestConfigureZSTD(t *testing.T) {
+	t.Cleanup(func() { ConfigureZSTD(ZSTDConfig{}) })
+	data := makeFloatData(1 << 16)
+	want, err := Compress(data, ZSTD, 5, Shuffle1, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := ZSTDConfig{EncoderConcurrency: 1, DecoderConcurrency: 1, LowMemory: true, MaxDecodedSize: 1 << 20}
+	if err := ConfigureZSTD(cfg); err != nil {
+		t.Fatal(err)
+	}
+	for _, opts := range []Options{
+		{Codec: ZSTD, Level: 5, Shuffle: Shuffle1, TypeSize: 4},
+		{Codec: ZSTD, Level: 5, Shuffle: Shuffle1, TypeSize: 4, CodecParams: CodecParams{ZSTD: ZSTDParams{Level: -1}}},
+	} {
+		chunk, err := CompressWithOptions(data, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := Decompress(chunk); err != nil || !bytes.Equal(got, data) {
+			t.Fatalf("%+v: round trip failed: %v", opts, err)
+		}
+	}
+	if got, err := Decompress(want); err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("chunk from the default configuration: %v", err)
+	}
+
+	// A smaller window changes the output, as ZSTDParams.WindowLog does
+	cfg.WindowLog = 10
+	if err := ConfigureZSTD(cfg); err != nil {
+		t.Fatal(err)
+	}
+	small, err := Compress(data, ZSTD, 5, Shuffle1, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tuned, err := CompressWithOptions(data, Options{Codec: ZSTD, Level: 5, Shuffle: Shuffle1, TypeSize: 4,
+		CodecParams: CodecParams{ZSTD: ZSTDParams{WindowLog: 10}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(small, want) || !bytes.Equal(small, tuned) {
+		t.Error("WindowLog 10 does not match ZSTDParams.WindowLog 10")
+	}
+
+	// Streams decoding past MaxDecodedSize fail
+	if err := ConfigureZSTD(ZSTDConfig{MaxDecodedSize: 1 << 10}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decompress(want); err == nil {
+		t.Error("expected an error past MaxDecodedSize")
+	}
+	if err := ConfigureZSTD(ZSTDConfig{EncoderConcurrency: -1}); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("expected ErrInvalidOption, got %v", err)
+	}
+}
+
+func TestCodecCompressPooled(t *testing.T) {
+	// One-shot calls reuse zlib writers, and keep LZ4 hash tables on the
+	// stack or in the library's pools, so they only allocate their output
+	if raceEnabled {
+		t.Skip("allocation counts are not meaningful under the race detector")
+	}
+	data := shuffleBytes(makeFloatData(25000), 4)
+	for _, tc := range []struct {
+		codec Codec
+		level int
+	}{{LZ4, 1}, {LZ4, 5}, {LZ4HC, 9}, {ZLIB, 1}, {ZLIB, 9}} {
+		codec, _ := GetCodec(tc.codec)
+		out, err := codec.Compress(data, tc.level)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bound := uint64(len(data) + len(data)/2)
+		if got := allocBytes(func() { codec.Compress(data, tc.level) }); got > bound {
+			t.Errorf("%s level %d: %d bytes allocated per call for %d bytes of output", tc.codec, tc.level, got, len(out))
+		}
+	}
+}
+
+func BenchmarkCodecCompress(b *testing.B) {
+	data := shuffleBytes(makeFloatData(65536), 4)
+	for _, tc := range []struct {
+		codec Codec
+		level int
+	}{{LZ4, 1}, {LZ4, 5}, {LZ4HC, 9}, {ZLIB, 1}, {ZLIB, 9}} {
+		codec, _ := GetCodec(tc.codec)
+		b.Run(fmt.Sprintf("%s/level=%d", tc.codec, tc.level), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				_, _ = codec.Compress(data, tc.level)
+			}
+		})
+	}
+}
+
+func BenchmarkLZ4Acceleration(b *testing.B) {
+	// Partly random data, so that skipping ahead on misses pays off
+	data := makeTestDataPure(100000)
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < len(data); i += 3 {
+		data[i] = byte(rng.Intn(256))
+	}
+	for _, accel := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("accel=%d", accel), func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				_ = lz4CompressFast(data, accel)
+			}
+		})
+	}
+}
+
+func TestCodecInfo(t *testing.T) {
+	tests := []struct {
+		codec        Codec
+		levelsMatter bool
+		streaming    bool
+	}{
+		{LZ4, true, false},
+		{LZ4HC, true, false},
+		{ZLIB, true, true},
+		{ZSTD, true, true},
+		{Snappy, false, false},
+	}
+
+	for _, tt := range tests {
+		caps, err := CodecInfo(tt.codec)
+		if err != nil {
+			t.Fatalf("%s: CodecInfo failed: %v", tt.codec, err)
+		}
+		if caps.Name != tt.codec.String() {
+			t.Errorf("%s: Name = %q", tt.codec, caps.Name)
+		}
+		if caps.LevelsMatter != tt.levelsMatter {
+			t.Errorf("%s: LevelsMatter = %v, want %v", tt.codec, caps.LevelsMatter, tt.levelsMatter)
+		}
+		if caps.LevelsMatter != (caps.DistinctLevels > 1) {
+			t.Errorf("%s: LevelsMatter = %v with %d distinct levels", tt.codec, caps.LevelsMatter, caps.DistinctLevels)
+		}
+		if caps.Streaming != tt.streaming {
+			t.Errorf("%s: Streaming = %v, want %v", tt.codec, caps.Streaming, tt.streaming)
+		}
+		if caps.MinLevel != 1 || caps.MaxLevel != 9 {
+			t.Errorf("%s: level range %d-%d, want 1-9", tt.codec, caps.MinLevel, caps.MaxLevel)
+		}
+	}
+}
+
+func TestCodecInfoLevelsMatchOutput(t *testing.T) {
+	// Codecs that report levels as irrelevant must produce identical output
+	// across the whole level range
+	data := makeTestData(50000)
+	for _, id := range []Codec{Snappy} {
+		codec, _ := GetCodec(id)
+		first, _ := codec.Compress(data, 1)
+		for level := 2; level <= 9; level++ {
+			out, _ := codec.Compress(data, level)
+			if !bytes.Equal(first, out) {
+				t.Errorf("%s: level %d output differs from level 1", id, level)
+			}
+		}
+	}
+}
+
+func TestCodecInfoCustomCodec(t *testing.T) {
+	customID := Codec(101)
+	RegisterCodec(customID, &mockCodecImpl{name: "mock"})
+	defer delete(codecs, customID)
+
+	caps, err := CodecInfo(customID)
+	if err != nil {
+		t.Fatalf("CodecInfo failed: %v", err)
+	}
+	if caps.Name != "mock" || !caps.LevelsMatter || caps.DistinctLevels != 9 {
+		t.Errorf("unexpected defaults for custom codec: %+v", caps)
+	}
+
+	if _, err := CodecInfo(Codec(201)); !errors.Is(err, ErrInvalidCodec) {
+		t.Errorf("expected ErrInvalidCodec for unregistered codec, got %v", err)
+	}
+}
+
 // mockCodecImpl is a simple mock codec for testing RegisterCodec
 type mockCodecImpl struct {
 	name string
@@ -494,3 +896,288 @@ func makeTestDataPure(size int) []byte {
 	}
 	return data
 }
+
+func TestDeterministic(t *testing.T) {
+	t.Cleanup(func() { ConfigureZSTD(ZSTDConfig{}) })
+	data := makeFloatData(1 << 16)
+	compress := func(opts Options) []byte {
+		t.Helper()
+		chunk, err := CompressWithOptions(data, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return chunk
+	}
+
+	for _, codec := range ListCodecs() {
+		opts := Options{Codec: codec, Level: 5, Shuffle: Shuffle1, TypeSize: 4, Deterministic: true}
+		want := compress(opts)
+		plain := opts
+		plain.Deterministic = false
+		if !bytes.Equal(compress(plain), want) {
+			t.Errorf("%s: output differs from the same options without Deterministic", codec)
+		}
+
+		prev := runtime.GOMAXPROCS(1)
+		one := compress(opts)
+		runtime.GOMAXPROCS(prev)
+		if !bytes.Equal(one, want) {
+			t.Errorf("%s: output differs with GOMAXPROCS=1", codec)
+		}
+
+		threaded := opts
+		threaded.NumThreads = 4
+		a := NewAsyncCompressor(threaded)
+		if err := a.Submit(0, data); err != nil {
+			t.Fatal(err)
+		}
+		a.Close()
+		if r := <-a.Results(); r.Err != nil || !bytes.Equal(r.Chunk, want) {
+			t.Errorf("%s: AsyncCompressor output differs: %v", codec, r.Err)
+		}
+		if chunk, err := NewEncoder(opts).EncodeAll(data, nil); err != nil || !bytes.Equal(chunk, want) {
+			t.Errorf("%s: Encoder output differs: %v", codec, err)
+		}
+	}
+
+	// ConfigureZSTD changes ZSTD output, except with Deterministic
+	opts := Options{Codec: ZSTD, Level: 5, Shuffle: Shuffle1, TypeSize: 4}
+	plain := compress(opts)
+	opts.Deterministic = true
+	want := compress(opts)
+	if err := ConfigureZSTD(ZSTDConfig{WindowLog: 10, LowMemory: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got := compress(opts); !bytes.Equal(got, want) {
+		t.Error("Deterministic output changed with ConfigureZSTD")
+	}
+	if chunk, err := NewEncoder(opts).EncodeAll(data, nil); err != nil || !bytes.Equal(chunk, want) {
+		t.Errorf("Encoder output changed with ConfigureZSTD: %v", err)
+	}
+	opts.Deterministic = false
+	if bytes.Equal(compress(opts), plain) {
+		t.Error("ConfigureZSTD left the output alone")
+	}
+
+	var decoded Options
+	if err := decoded.UnmarshalText([]byte("codec=zstd,deterministic=true")); err != nil || !decoded.Deterministic {
+		t.Errorf("UnmarshalText: %+v, %v", decoded, err)
+	}
+	if cfg, err := NewConfig(WithDeterministic()); err != nil || !cfg.Options().Deterministic {
+		t.Errorf("WithDeterministic: %v", err)
+	}
+}
+
+func TestCodecExpectedSize(t *testing.T) {
+	// The most compressible input stays within each codec's ratio bound
+	zeros := make([]byte, 1<<22)
+	for id, c := range codecs {
+		bounded, ok := c.(boundedDecompressor)
+		if !ok {
+			continue
+		}
+		for _, level := range []int{1, 9} {
+			compressed, err := c.Compress(zeros, level)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, err := c.Decompress(compressed, len(zeros)); err != nil || !bytes.Equal(got, zeros) {
+				t.Errorf("%s level %d: %d bytes: %v", id, level, len(compressed), err)
+			}
+			claim := (len(compressed) + 1) * bounded.maxRatio()
+			if _, err := c.Decompress(compressed, claim); !errors.Is(err, ErrInvalidData) {
+				t.Errorf("%s: %d bytes decoded to a claimed %d: %v", id, len(compressed), claim, err)
+			}
+		}
+	}
+
+	// Legacy chunk headers cannot claim more than their stream holds
+	chunk, err := CompressWithOptions(makeTestData(10000), Options{Codec: LZ4, Level: 5, TypeSize: 4, LegacyFormat: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary.LittleEndian.PutUint32(chunk[offsetNBytesOrig:], 1<<30)
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err = Decompress(chunk)
+	runtime.ReadMemStats(&after)
+	if !errors.Is(err, ErrInvalidData) || after.TotalAlloc-before.TotalAlloc > 1<<20 {
+		t.Errorf("forged legacy header: %v, %d bytes allocated", err, after.TotalAlloc-before.TotalAlloc)
+	}
+
+	// SetMaxDecodedSize caps codecs and chunks alike
+	prev := SetMaxDecodedSize(1 << 20)
+	defer SetMaxDecodedSize(prev)
+	compressed, _ := codecs[LZ4].Compress(zeros, 5)
+	if _, err := codecs[LZ4].Decompress(compressed, len(zeros)); !errors.Is(err, ErrOutputTooLarge) {
+		t.Errorf("codec over the cap: %v", err)
+	}
+	chunk, _ = CompressWithOptions(zeros, Options{Codec: LZ4, Level: 5, TypeSize: 4})
+	if _, err := DecompressLimited(chunk, len(zeros)); !errors.Is(err, ErrOutputTooLarge) {
+		t.Errorf("chunk over the cap: %v", err)
+	}
+	small, _ := CompressWithOptions(zeros[:1<<20], Options{Codec: LZ4, Level: 5, TypeSize: 4})
+	if _, err := Decompress(small); err != nil {
+		t.Errorf("chunk at the cap: %v", err)
+	}
+	if SetMaxDecodedSize(0) != 1<<20 {
+		t.Error("SetMaxDecodedSize did not return the previous cap")
+	}
+	if _, err := Decompress(chunk); err != nil {
+		t.Errorf("without a cap: %v", err)
+	}
+}
+
+// storingCodec reports every input as incompressible and fails any attempt
+// to decode its streams
+type storingCodec struct {
+	CodecInterface
+}
+
+func (storingCodec) Compress(data []byte, level int) ([]byte, error) {
+	return nil, ErrIncompressible
+}
+
+func (storingCodec) Decompress(data []byte, expectedSize int) ([]byte, error) {
+	return nil, errors.New("raw stream passed to the codec")
+}
+
+func TestCodecIncompressible(t *testing.T) {
+	codecs[ZSTD] = storingCodec{codecs[ZSTD]}
+	defer func() { codecs[ZSTD] = codecs[ZSTD].(storingCodec).CodecInterface }()
+
+	data := makeTestData(100000)
+	for name, opts := range map[string]Options{
+		"blocks":    {Codec: ZSTD, Level: 5, Shuffle: Shuffle1, TypeSize: 4},
+		"checksums": {Codec: ZSTD, Level: 5, Shuffle: Shuffle1, TypeSize: 4, BlockChecksums: true},
+		"legacy":    {Codec: ZSTD, Level: 5, Shuffle: Shuffle1, TypeSize: 4, LegacyFormat: true},
+	} {
+		chunk, err := CompressWithOptions(data, opts)
+		if err != nil {
+			t.Fatalf("%s: compress failed: %v", name, err)
+		}
+		header, _ := ParseHeader(chunk)
+		// Checksummed chunks keep their blocks, of raw streams
+		if header.IsMemcpy() == opts.BlockChecksums {
+			t.Errorf("%s: memcpy flag %v", name, header.IsMemcpy())
+		}
+		got, err := Decompress(chunk)
+		if err != nil || !bytes.Equal(got, data) {
+			t.Errorf("%s: round trip failed: %v", name, err)
+		}
+	}
+
+	// The built-in codecs never pass their input off as compressed output
+	noise := make([]byte, 10000)
+	_, _ = cryptorand.Read(noise)
+	for _, id := range []Codec{LZ4, LZ4HC} {
+		compressed, err := codecs[id].Compress(noise, 5)
+		if errors.Is(err, ErrIncompressible) {
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", id, err)
+		}
+		if got, err := codecs[id].Decompress(compressed, len(noise)); err != nil || !bytes.Equal(got, noise) {
+			t.Errorf("%s: output does not decode: %v", id, err)
+		}
+	}
+}
+
+func TestZLIBTruncated(t *testing.T) {
+	c, _ := GetCodec(ZLIB)
+	data := makeTestData(100000)
+	compressed, err := c.Compress(data, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Cut inside the header, the deflate data and the Adler-32 checksum
+	for _, cut := range []int{1, len(compressed) / 2, len(compressed) - 4, len(compressed) - 1} {
+		_, err := codecDecompressInto(c, make([]byte, len(data)), compressed[:cut])
+		if !errors.Is(err, ErrDecompressionFailed) || !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("cut at %d of %d: expected truncation error, got %v", cut, len(compressed), err)
+		}
+	}
+	for _, size := range []int{len(data) - 1, len(data) + 1} {
+		if _, err := c.Decompress(compressed, size); !errors.Is(err, ErrSizeMismatch) {
+			t.Errorf("expected size %d: expected ErrSizeMismatch, got %v", size, err)
+		}
+	}
+
+	// A legacy chunk holds one stream for all its data, so its size is the
+	// only check after the codec
+	chunk, err := CompressWithOptions(data, Options{Codec: ZLIB, Level: 5, TypeSize: 4, LegacyFormat: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunk = chunk[:len(chunk)-2]
+	binary.LittleEndian.PutUint32(chunk[offsetNBytesComp:], uint32(len(chunk)))
+	if _, err := Decompress(chunk); !errors.Is(err, ErrDecompressionFailed) {
+		t.Errorf("truncated chunk: expected ErrDecompressionFailed, got %v", err)
+	}
+}
+
+func TestRegisterCodecIDs(t *testing.T) {
+	for _, id := range []Codec{BloscLZ, ZSTD, 6, CodecRegisteredStart - 1, 255} {
+		if err := RegisterCodec(id, namedCodec{"reserved"}); !errors.Is(err, ErrInvalidCodec) {
+			t.Errorf("ID %d: expected ErrInvalidCodec, got %v", id, err)
+		}
+	}
+	if _, ok := codecs[6]; ok || codecs[ZSTD].Name() != "zstd" {
+		t.Fatal("rejected registration replaced a codec")
+	}
+	if err := RegisterCodec(CodecUserStart+10, nil); !errors.Is(err, ErrInvalidCodec) {
+		t.Errorf("nil codec: expected ErrInvalidCodec, got %v", err)
+	}
+
+	const id = CodecUserStart + 10
+	if err := RegisterCodec(id, namedCodec{"userlz4"}); err != nil {
+		t.Fatal(err)
+	}
+	defer delete(codecs, id)
+	data := makeTestData(100000)
+	for _, opts := range []Options{
+		{Codec: id, Level: 5, Shuffle: Shuffle1, TypeSize: 4},
+		{Codec: id, Level: 5, TypeSize: 4, BlockChecksums: true, Filters: [MaxFilters]FilterStage{{Filter: FilterByteDelta}, {Filter: FilterShuffle}}},
+	} {
+		if err := opts.Validate(); err != nil {
+			t.Errorf("Validate: %v", err)
+		}
+		chunk, err := CompressWithOptions(data, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The codec ID goes where c-blosc2 puts the ID of a codec plugin
+		h, _ := ParseHeader(chunk)
+		if !h.IsExtended() || h.IsLegacy() || h.Flags>>flagCodecShift != formatUDCodec || chunk[offsetUDCodec] != byte(id) || h.Codec() != id {
+			t.Errorf("header %+v does not hold the user codec", h)
+		}
+		if got, err := Decompress(chunk); err != nil || !bytes.Equal(got, data) {
+			t.Errorf("round trip failed: %v", err)
+		}
+		if err := Validate(chunk); err != nil {
+			t.Errorf("Validate: %v", err)
+		}
+		if s := h.String(); !strings.HasPrefix(s, "userlz4, ") {
+			t.Errorf("header described as %q", s)
+		}
+	}
+
+	// Legacy chunks still hold the ID in VersionLZ
+	legacy, err := CompressWithOptions(data, Options{Codec: id, Level: 5, TypeSize: 4, LegacyFormat: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h, _ := ParseHeader(legacy); !h.IsLegacy() || h.Codec() != id {
+		t.Errorf("legacy header %+v", h)
+	}
+
+	chunk, err := CompressWithOptions(data, Options{Codec: id, Level: 5, TypeSize: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	delete(codecs, id)
+	if _, err := Decompress(chunk); !errors.Is(err, ErrInvalidCodec) {
+		t.Errorf("unregistered codec: expected ErrInvalidCodec, got %v", err)
+	}
+}
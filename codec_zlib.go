@@ -0,0 +1,160 @@
+//go:build !blosc_nozlib
+
+package blosc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+
+	kzlib "github.com/klauspost/compress/zlib"
+)
+
+// ZLIB codec, using klauspost/compress for better performance. Building
+// with the blosc_nozlib tag leaves it out.
+
+type zlibCodec struct{}
+
+func init() {
+	codecs[ZLIB] = &zlibCodec{}
+}
+
+func (c *zlibCodec) Name() string { return "zlib" }
+
+func (c *zlibCodec) Capabilities() CodecCapabilities {
+	return CodecCapabilities{MinLevel: 1, MaxLevel: 9, DistinctLevels: 9, LevelsMatter: true, Streaming: true}
+}
+
+// zlibState is a zlib writer with the buffer it writes to, pooled in
+// zlibStates by level and kept by an Encoder's codecScratch
+type zlibState struct {
+	w     *kzlib.Writer
+	buf   bytes.Buffer
+	level int // Level w was created with
+}
+
+// zlibStates pools zlib writers for one-shot calls, one pool per level from
+// kzlib.HuffmanOnly to kzlib.BestCompression, since each writer holds
+// several hundred KB of match-finder state
+var zlibStates [kzlib.BestCompression - kzlib.HuffmanOnly + 1]sync.Pool
+
+func (c *zlibCodec) Compress(data []byte, level int) ([]byte, error) {
+	if level < kzlib.HuffmanOnly || level > kzlib.BestCompression {
+		return nil, fmt.Errorf("zlib create writer: invalid compression level %d", level)
+	}
+	pool := &zlibStates[level-kzlib.HuffmanOnly]
+	z, ok := pool.Get().(*zlibState)
+	if ok {
+		z.buf.Reset()
+		z.w.Reset(&z.buf)
+	} else {
+		z = new(zlibState)
+		w, err := kzlib.NewWriterLevel(&z.buf, level)
+		if err != nil {
+			return nil, fmt.Errorf("zlib create writer: %w", err)
+		}
+		z.w, z.level = w, level
+	}
+	defer pool.Put(z)
+
+	if _, err := z.w.Write(data); err != nil {
+		return nil, fmt.Errorf("zlib write: %w", err)
+	}
+	if err := z.w.Close(); err != nil {
+		return nil, fmt.Errorf("zlib close: %w", err)
+	}
+	return bytes.Clone(z.buf.Bytes()), nil
+}
+
+func (c *zlibCodec) compressParams(data []byte, level int, params *CodecParams) ([]byte, error) {
+	if params.ZLIB.HuffmanOnly {
+		level = kzlib.HuffmanOnly
+	}
+	return c.Compress(data, level)
+}
+
+func (c *zlibCodec) compressScratch(s *codecScratch, data []byte, level int, params *CodecParams) ([]byte, error) {
+	if params.ZLIB.HuffmanOnly {
+		level = kzlib.HuffmanOnly
+	}
+	if s.zlib == nil {
+		s.zlib = new(zlibState)
+	}
+	z := s.zlib
+	z.buf.Reset()
+	if z.w == nil || z.level != level {
+		w, err := kzlib.NewWriterLevel(&z.buf, level)
+		if err != nil {
+			return nil, fmt.Errorf("zlib create writer: %w", err)
+		}
+		z.w, z.level = w, level
+	} else {
+		z.w.Reset(&z.buf)
+	}
+	if _, err := z.w.Write(data); err != nil {
+		return nil, fmt.Errorf("zlib write: %w", err)
+	}
+	if err := z.w.Close(); err != nil {
+		return nil, fmt.Errorf("zlib close: %w", err)
+	}
+	return z.buf.Bytes(), nil
+}
+
+// maxRatio bounds ZLIB expansion, the largest ratio of deflate
+func (c *zlibCodec) maxRatio() int { return 1032 }
+
+func (c *zlibCodec) Decompress(data []byte, expectedSize int) ([]byte, error) {
+	if err := checkExpectedSize(data, expectedSize, c.maxRatio()); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, expectedSize)
+	n, err := c.decompressInto(buf, data)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+func (c *zlibCodec) decompressInto(dst, data []byte) (int, error) {
+	r, err := kzlib.NewReader(bytes.NewReader(data))
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return 0, zlibTruncated(0, len(dst), err)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("zlib create reader: %w", err)
+	}
+	defer r.Close()
+
+	// Read up to the end of the stream, where the reader checks the Adler-32
+	// checksum, to tell a stream cut short from one that ends early
+	n := 0
+	for {
+		var extra [1]byte
+		buf := dst[n:]
+		if n == len(dst) {
+			buf = extra[:]
+		}
+		k, err := r.Read(buf)
+		if n == len(dst) && k > 0 {
+			return 0, fmt.Errorf("%w: zlib stream holds more than %d bytes", ErrSizeMismatch, len(dst))
+		}
+		n += k
+		switch {
+		case err == io.EOF && n < len(dst):
+			return 0, fmt.Errorf("%w: zlib stream holds %d bytes, expected %d", ErrSizeMismatch, n, len(dst))
+		case err == io.EOF:
+			return n, nil
+		case err == io.ErrUnexpectedEOF:
+			return 0, zlibTruncated(n, len(dst), err)
+		case err != nil:
+			return 0, fmt.Errorf("zlib read: %w", err)
+		}
+	}
+}
+
+// zlibTruncated reports a zlib stream that ends before its checksum, after
+// decoding n of the expected bytes
+func zlibTruncated(n, expected int, err error) error {
+	return fmt.Errorf("%w: zlib stream truncated after %d of %d bytes: %w", ErrDecompressionFailed, n, expected, err)
+}
@@ -0,0 +1,347 @@
+//go:build !blosc_nozstd
+
+package blosc
+
+import (
+	"fmt"
+	"math/bits"
+	"sync"
+	"sync/atomic"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ZSTD codec, with persistent encoders and decoder for performance.
+// Building with the blosc_nozstd tag leaves it out.
+
+type zstdCodec struct{}
+
+func init() {
+	codecs[ZSTD] = &zstdCodec{}
+}
+
+func (c *zstdCodec) Name() string { return "zstd" }
+
+func (c *zstdCodec) Capabilities() CodecCapabilities {
+	return CodecCapabilities{MinLevel: 1, MaxLevel: 9, DistinctLevels: len(zstdLevels), LevelsMatter: true, Streaming: true}
+}
+
+// ConfigureZSTD replaces the shared ZSTD encoders and decoder with ones
+// built from cfg. Calls already running finish with the old ones.
+func ConfigureZSTD(cfg ZSTDConfig) error {
+	if cfg.EncoderConcurrency < 0 || cfg.DecoderConcurrency < 0 || cfg.WindowLog < 0 {
+		return fmt.Errorf("%w: negative zstd setting", ErrInvalidOption)
+	}
+	z, err := newZSTDShared(cfg)
+	if err != nil {
+		return err
+	}
+	zstdState.Store(z)
+	return nil
+}
+
+// zstdShared is the ZSTD codec state built from a ZSTDConfig. EncodeAll and
+// DecodeAll are safe for concurrent use, so all goroutines share it.
+type zstdShared struct {
+	config   ZSTDConfig
+	encoders [len(zstdLevels)]*zstd.Encoder
+	tuned    sync.Map // Encoders for ZSTDParams, by zstdEncoderKey
+	decoder  *zstd.Decoder
+}
+
+// zstdDefaultState is the zstdShared of the zero ZSTDConfig, which
+// Options.Deterministic compresses with whatever ConfigureZSTD sets
+var zstdDefaultState = func() *zstdShared {
+	z, err := newZSTDShared(ZSTDConfig{})
+	if err != nil {
+		panic(err)
+	}
+	return z
+}()
+
+// zstdState holds the zstdShared in use, replaced by ConfigureZSTD
+var zstdState = func() *atomic.Pointer[zstdShared] {
+	var p atomic.Pointer[zstdShared]
+	p.Store(zstdDefaultState)
+	return &p
+}()
+
+func newZSTDShared(cfg ZSTDConfig) (*zstdShared, error) {
+	z := &zstdShared{config: cfg}
+	for i := range zstdLevels {
+		e, err := z.newEncoder(zstdEncoderKey{index: i})
+		if err != nil {
+			return nil, err
+		}
+		z.encoders[i] = e
+	}
+
+	d, err := z.newDecoder()
+	if err != nil {
+		return nil, err
+	}
+	z.decoder = d
+	return z, nil
+}
+
+// newDecoder builds a decoder with the shared configuration and extra
+// options
+func (z *zstdShared) newDecoder(extra ...zstd.DOption) (*zstd.Decoder, error) {
+	// DecodeAll is capped at the capacity of its destination, so a stream
+	// cannot expand past the size the chunk header claims
+	options := []zstd.DOption{zstd.WithDecodeAllCapLimit(true), zstd.WithDecoderLowmem(z.config.LowMemory)}
+	if z.config.DecoderConcurrency > 0 {
+		options = append(options, zstd.WithDecoderConcurrency(z.config.DecoderConcurrency))
+	}
+	if z.config.MaxDecodedSize > 0 {
+		options = append(options, zstd.WithDecoderMaxMemory(z.config.MaxDecodedSize))
+	}
+	d, err := zstd.NewReader(nil, append(options, extra...)...)
+	if err != nil {
+		return nil, fmt.Errorf("zstd create decoder: %w", err)
+	}
+	return d, nil
+}
+
+// newEncoder builds an encoder for key with the shared configuration and
+// extra options
+func (z *zstdShared) newEncoder(key zstdEncoderKey, extra ...zstd.EOption) (*zstd.Encoder, error) {
+	options := []zstd.EOption{
+		zstd.WithEncoderLevel(zstdLevels[key.index]),
+		zstd.WithNoEntropyCompression(key.noEntropy),
+		zstd.WithLowerEncoderMem(z.config.LowMemory),
+	}
+	if z.config.EncoderConcurrency > 0 {
+		options = append(options, zstd.WithEncoderConcurrency(z.config.EncoderConcurrency))
+	}
+	windowLog := key.windowLog
+	if windowLog == 0 {
+		windowLog = zstdWindowLog(z.config.WindowLog)
+	}
+	if windowLog != 0 {
+		options = append(options, zstd.WithWindowSize(1<<windowLog))
+	}
+	e, err := zstd.NewWriter(nil, append(options, extra...)...)
+	if err != nil {
+		return nil, fmt.Errorf("zstd create encoder: %w", err)
+	}
+	return e, nil
+}
+
+// zstdWindowLog clamps a nonzero window log to the range the encoder
+// supports
+func zstdWindowLog(windowLog int) int {
+	if windowLog == 0 {
+		return 0
+	}
+	minLog := bits.Len(uint(zstd.MinWindowSize)) - 1
+	maxLog := bits.Len(uint(zstd.MaxWindowSize)) - 1
+	return min(max(windowLog, minLog), maxLog)
+}
+
+// zstdLevels maps encoder indexes to zstd encoder levels
+var zstdLevels = [4]zstd.EncoderLevel{
+	zstd.SpeedFastest,
+	zstd.SpeedDefault,
+	zstd.SpeedBetterCompression,
+	zstd.SpeedBestCompression,
+}
+
+// zstdEncoderIndex maps levels 1-9 to an encoder index (0-3)
+func zstdEncoderIndex(level int) int {
+	switch {
+	case level <= 2:
+		return 0
+	case level <= 4:
+		return 1
+	case level <= 6:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// zstdLevelIndex maps ZSTDParams.Level to an encoder index, as
+// zstd.EncoderLevelFromZstd does
+func zstdLevelIndex(level int) int {
+	return int(zstd.EncoderLevelFromZstd(level)) - int(zstd.SpeedFastest)
+}
+
+// zstdEncoderKey identifies the encoder for a level and ZSTDParams
+type zstdEncoderKey struct {
+	index     int
+	windowLog int  // 0 for the level's window
+	noEntropy bool // Negative levels skip entropy coding
+}
+
+func (c *zstdCodec) Compress(data []byte, level int) ([]byte, error) {
+	return zstdState.Load().encoders[zstdEncoderIndex(level)].EncodeAll(data, nil), nil
+}
+
+func (c *zstdCodec) compressParams(data []byte, level int, params *CodecParams) ([]byte, error) {
+	e, err := zstdEncoder(zstdState.Load(), level, params)
+	if err != nil {
+		return nil, err
+	}
+	return e.EncodeAll(data, nil), nil
+}
+
+func (c *zstdCodec) compressDefault(data []byte, level int, params *CodecParams) ([]byte, error) {
+	e, err := zstdEncoder(zstdDefaultState, level, params)
+	if err != nil {
+		return nil, err
+	}
+	return e.EncodeAll(data, nil), nil
+}
+
+func (c *zstdCodec) compressScratch(s *codecScratch, data []byte, level int, params *CodecParams) ([]byte, error) {
+	e, err := zstdEncoder(zstdState.Load(), level, params)
+	if err != nil {
+		return nil, err
+	}
+	s.buf = e.EncodeAll(data, s.buf[:0])
+	return s.buf, nil
+}
+
+// zstdEncoder returns the encoder of z for a level and ZSTDParams
+func zstdEncoder(z *zstdShared, level int, params *CodecParams) (*zstd.Encoder, error) {
+	key := zstdEncoderKey{index: zstdEncoderIndex(level)}
+	if params.ZSTD.Level != 0 {
+		key.index = zstdLevelIndex(params.ZSTD.Level)
+		key.noEntropy = params.ZSTD.Level < 0
+	}
+	key.windowLog = zstdWindowLog(params.ZSTD.WindowLog)
+	if dict := params.ZSTD.Dict; dict != nil {
+		if dict.codec == nil {
+			return nil, fmt.Errorf("%w: zstd dictionary not made by NewZSTDDict", ErrInvalidOption)
+		}
+		return dict.codec.encoder(z, key)
+	}
+	if key.windowLog == 0 && !key.noEntropy {
+		return z.encoders[key.index], nil
+	}
+
+	e, ok := z.tuned.Load(key)
+	if !ok {
+		enc, err := z.newEncoder(key)
+		if err != nil {
+			return nil, err
+		}
+		e, _ = z.tuned.LoadOrStore(key, enc)
+	}
+	return e.(*zstd.Encoder), nil
+}
+
+// maxRatio bounds ZSTD expansion: a 4-byte RLE block yields at most 128
+// KiB
+func (c *zstdCodec) maxRatio() int { return 1 << 15 }
+
+func (c *zstdCodec) Decompress(data []byte, expectedSize int) ([]byte, error) {
+	if err := checkExpectedSize(data, expectedSize, c.maxRatio()); err != nil {
+		return nil, err
+	}
+	buf, err := zstdState.Load().decoder.DecodeAll(data, make([]byte, 0, expectedSize))
+	if err != nil {
+		return nil, fmt.Errorf("zstd decode: %w", err)
+	}
+	return buf, nil
+}
+
+func (c *zstdCodec) decompressInto(dst, data []byte) (int, error) {
+	// The capacity limit keeps the output in dst
+	out, err := zstdState.Load().decoder.DecodeAll(data, dst[:0:len(dst)])
+	if err != nil {
+		return 0, fmt.Errorf("zstd decode: %w", err)
+	}
+	return len(out), nil
+}
+
+// zstdDictCodec decompresses ZSTD streams with a ZSTDDict, and keeps the
+// encoders that compress with it
+type zstdDictCodec struct {
+	zstdCodec
+	dict     *ZSTDDict
+	encoders sync.Map // Encoders by zstdDictKey
+	decoder  *zstd.Decoder
+}
+
+// zstdDictKey identifies the encoder of a dictionary for an encoder key and
+// the shared state whose configuration it is built with. Encoders of states
+// ConfigureZSTD has replaced are dropped, so the keys stay bounded.
+type zstdDictKey struct {
+	shared *zstdShared
+	key    zstdEncoderKey
+}
+
+func newZSTDDictCodec(d *ZSTDDict) (*zstdDictCodec, error) {
+	option := zstd.WithDecoderDictRaw(d.id, d.data)
+	if d.formatted() {
+		option = zstd.WithDecoderDicts(d.data)
+	}
+	decoder, err := zstdState.Load().newDecoder(option)
+	if err != nil {
+		return nil, fmt.Errorf("%w: zstd dictionary: %w", ErrInvalidOption, err)
+	}
+	return &zstdDictCodec{dict: d, decoder: decoder}, nil
+}
+
+// decompressor returns the codec that decompresses streams compressed with d
+func (d *ZSTDDict) decompressor() CodecInterface {
+	return d.codec
+}
+
+// encoder returns the encoder of the dictionary for key, built with the
+// configuration of z
+func (c *zstdDictCodec) encoder(z *zstdShared, key zstdEncoderKey) (*zstd.Encoder, error) {
+	if key.windowLog == 0 {
+		key.windowLog = zstdWindowLog(z.config.WindowLog)
+	}
+	e, ok := c.encoders.Load(zstdDictKey{z, key})
+	if !ok {
+		option := zstd.WithEncoderDictRaw(c.dict.id, c.dict.data)
+		if c.dict.formatted() {
+			option = zstd.WithEncoderDict(c.dict.data)
+		}
+		enc, err := z.newEncoder(key, option)
+		if err != nil {
+			return nil, fmt.Errorf("%w: zstd dictionary: %w", ErrInvalidOption, err)
+		}
+		var loaded bool
+		if e, loaded = c.encoders.LoadOrStore(zstdDictKey{z, key}, enc); !loaded {
+			c.dropStale()
+		}
+	}
+	return e.(*zstd.Encoder), nil
+}
+
+// dropStale removes the encoders built for shared states other than the one
+// in use and the default one Deterministic compresses with, which
+// ConfigureZSTD has replaced
+func (c *zstdDictCodec) dropStale() {
+	current := zstdState.Load()
+	c.encoders.Range(func(k, _ any) bool {
+		if z := k.(zstdDictKey).shared; z != current && z != zstdDefaultState {
+			c.encoders.Delete(k)
+		}
+		return true
+	})
+}
+
+func (c *zstdDictCodec) Decompress(data []byte, expectedSize int) ([]byte, error) {
+	if err := checkExpectedSize(data, expectedSize, c.maxRatio()); err != nil {
+		return nil, err
+	}
+	buf, err := c.decoder.DecodeAll(data, make([]byte, 0, expectedSize))
+	if err != nil {
+		return nil, fmt.Errorf("zstd decode: %w", err)
+	}
+	return buf, nil
+}
+
+func (c *zstdDictCodec) decompressInto(dst, data []byte) (int, error) {
+	out, err := c.decoder.DecodeAll(data, dst[:0:len(dst)])
+	if err != nil {
+		return 0, fmt.Errorf("zstd decode: %w", err)
+	}
+	return len(out), nil
+}
@@ -0,0 +1,43 @@
+//go:build !blosc_nozstd
+
+package blosc
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestZSTDDictEncodersAfterConfigure(t *testing.T) {
+	t.Cleanup(func() { ConfigureZSTD(ZSTDConfig{}) })
+	dict, err := NewZSTDDict(makeRecords(100, 40))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := makeRecords(2000, 3)
+	opts := Options{Codec: ZSTD, Level: 5, TypeSize: 1, CodecParams: CodecParams{ZSTD: ZSTDParams{Dict: dict}}}
+	for i := 0; i < 20; i++ {
+		if err := ConfigureZSTD(ZSTDConfig{WindowLog: 15 + i%5}); err != nil {
+			t.Fatal(err)
+		}
+		chunk, err := CompressWithOptions(data, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := DecompressWithOptions(chunk, DecompressOptions{Dict: dict}); err != nil || !bytes.Equal(got, data) {
+			t.Fatalf("configuration %d: round trip: %v", i, err)
+		}
+	}
+
+	// Only the encoders of the configuration in use are kept
+	n := 0
+	dict.codec.encoders.Range(func(k, _ any) bool {
+		if k.(zstdDictKey).shared != zstdState.Load() {
+			t.Errorf("encoder kept for a replaced configuration")
+		}
+		n++
+		return true
+	})
+	if n != 1 {
+		t.Errorf("%d encoders kept, want 1", n)
+	}
+}
@@ -0,0 +1,375 @@
+package blosc
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+)
+
+// Config is a validated set of compression options, built by NewConfig. It
+// cannot be changed once built, so it can be shared freely.
+type Config struct {
+	opts Options
+}
+
+// Option sets one compression setting for NewConfig, reporting an error for
+// values that Options would silently clamp or misread.
+type Option func(*Options) error
+
+// NewConfig returns the Config of DefaultOptions with options applied in
+// order, or the first error an option or the resulting combination gives.
+// Unlike the fields of Options, which compression clamps to their ranges,
+// out-of-range values are rejected here.
+func NewConfig(options ...Option) (Config, error) {
+	opts := DefaultOptions()
+	for _, option := range options {
+		if err := option(&opts); err != nil {
+			return Config{}, err
+		}
+	}
+	if err := opts.Validate(); err != nil {
+		return Config{}, err
+	}
+	return Config{opts: opts}, nil
+}
+
+// Validate reports the first option that compression would reject, clamp
+// or round, as an error wrapping ErrInvalidOption, and also ErrInvalidCodec,
+// ErrInvalidShuffle or ErrInvalidFilter for those settings. It returns nil
+// for options that compression uses as they are. Validate does not check
+// options against the data, so a BlockSize larger than the input, which
+// shrinks to fit, is valid.
+func (o Options) Validate() error {
+	if _, ok := codecs[o.Codec]; !ok {
+		return fmt.Errorf("%w: %w: %s is not registered", ErrInvalidOption, ErrInvalidCodec, o.Codec)
+	}
+	if o.Level < 0 || o.Level > 9 {
+		return fmt.Errorf("%w: level %d is outside 0 to 9", ErrInvalidOption, o.Level)
+	}
+	if o.Shuffle > AutoShuffle {
+		return fmt.Errorf("%w: %w: %d", ErrInvalidOption, ErrInvalidShuffle, o.Shuffle)
+	}
+	if (o.TypeSize < 1 || o.TypeSize > 255) && o.TypeSize != TypeSizeAuto {
+		return fmt.Errorf("%w: type size %d is outside 1 to 255", ErrInvalidOption, o.TypeSize)
+	}
+
+	filtered := o.Filters != ([MaxFilters]FilterStage{})
+	switch {
+	case o.BlockSize < 0 || int64(o.BlockSize) > MaxBufferSize:
+		return fmt.Errorf("%w: block size %d is outside 0 to %d", ErrInvalidOption, o.BlockSize, MaxBufferSize)
+	case o.BlockSize > 0 && o.BlockSize < minBufferSize && !filtered:
+		return fmt.Errorf("%w: block size %d is below the %d bytes chunks without filters use", ErrInvalidOption, o.BlockSize, minBufferSize)
+	case o.BlockSize > o.TypeSize && o.TypeSize != TypeSizeAuto && o.BlockSize%o.TypeSize != 0:
+		return fmt.Errorf("%w: block size %d is not a multiple of the type size %d", ErrInvalidOption, o.BlockSize, o.TypeSize)
+	}
+	if o.NumThreads < 0 {
+		return fmt.Errorf("%w: %d threads", ErrInvalidOption, o.NumThreads)
+	}
+	if o.CodecParams.LZ4.Acceleration < 0 || o.CodecParams.ZSTD.WindowLog < 0 {
+		return fmt.Errorf("%w: negative codec parameter", ErrInvalidOption)
+	}
+	if err := checkZSTDLevel(o.CodecParams.ZSTD.Level); err != nil {
+		return err
+	}
+	if err := o.ErrorBound.check(); err != nil {
+		return err
+	}
+
+	if _, ok := codecFormat(o.Codec); o.BlockChecksums && (o.LegacyFormat || !ok) {
+		return fmt.Errorf("%w: block checksums need the Blosc2 format, which %s cannot be written in", ErrInvalidOption, o.Codec)
+	}
+	if o.Codec == ZSTD && o.CodecParams.ZSTD.Dict != nil && o.LegacyFormat {
+		return fmt.Errorf("%w: dictionaries need the Blosc2 format", ErrInvalidOption)
+	}
+
+	if !filtered {
+		return nil
+	}
+	if _, err := newPipeline(o.Filters, true); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidOption, err)
+	}
+	if _, ok := codecFormat(o.Codec); o.LegacyFormat || !ok {
+		return fmt.Errorf("%w: %w: filters need the Blosc2 format, which %s cannot be written in", ErrInvalidOption, ErrInvalidFilter, o.Codec)
+	}
+	if quantizes(o.Filters) && o.ErrorBound.IsZero() {
+		return fmt.Errorf("%w: %s needs an error bound", ErrInvalidOption, FilterFloatQuantize)
+	}
+	return nil
+}
+
+// Options returns a copy of the options c holds, for APIs that take Options.
+func (c Config) Options() Options {
+	return c.opts
+}
+
+// Compress compresses data into a chunk with the options c holds.
+func (c Config) Compress(data []byte) ([]byte, error) {
+	return CompressWithOptions(data, c.opts)
+}
+
+// WithProfile starts from the preset Profile returns for name, so that later
+// options adjust it.
+func WithProfile(name string) Option {
+	return func(o *Options) error {
+		p, err := Profile(name)
+		if err != nil {
+			return err
+		}
+		*o = p
+		return nil
+	}
+}
+
+// WithCodec sets the codec, which must be registered.
+func WithCodec(codec Codec) Option {
+	return func(o *Options) error {
+		if _, ok := codecs[codec]; !ok {
+			return fmt.Errorf("%w: %s is not registered", ErrInvalidCodec, codec)
+		}
+		o.Codec = codec
+		return nil
+	}
+}
+
+// WithLevel sets the compression level, from 1 to 9, or 0 to store the
+// filtered input without a codec.
+func WithLevel(level int) Option {
+	return func(o *Options) error {
+		if level < 0 || level > 9 {
+			return fmt.Errorf("%w: level %d is outside 0 to 9", ErrInvalidOption, level)
+		}
+		o.Level = level
+		return nil
+	}
+}
+
+// WithTypeSize sets the element size in bytes, from 1 to 255, or
+// TypeSizeAuto, without changing the shuffle mode.
+func WithTypeSize(typeSize int) Option {
+	return func(o *Options) error {
+		if (typeSize < 1 || typeSize > 255) && typeSize != TypeSizeAuto {
+			return fmt.Errorf("%w: type size %d is outside 1 to 255", ErrInvalidOption, typeSize)
+		}
+		o.TypeSize = typeSize
+		return nil
+	}
+}
+
+// WithShuffle selects byte shuffle of typeSize-byte elements.
+func WithShuffle(typeSize int) Option {
+	return withShuffle(Shuffle1, typeSize)
+}
+
+// WithBitShuffle selects bit shuffle of typeSize-byte elements.
+func WithBitShuffle(typeSize int) Option {
+	return withShuffle(BitShuffle, typeSize)
+}
+
+// WithAutoShuffle picks the shuffle mode of typeSize-byte elements for each
+// chunk, as AutoShuffle does.
+func WithAutoShuffle(typeSize int) Option {
+	return withShuffle(AutoShuffle, typeSize)
+}
+
+// WithNoShuffle turns shuffling off.
+func WithNoShuffle() Option {
+	return func(o *Options) error {
+		o.Shuffle = NoShuffle
+		return nil
+	}
+}
+
+func withShuffle(shuffle Shuffle, typeSize int) Option {
+	setTypeSize := WithTypeSize(typeSize)
+	return func(o *Options) error {
+		if err := setTypeSize(o); err != nil {
+			return err
+		}
+		o.Shuffle = shuffle
+		return nil
+	}
+}
+
+// WithBlockSize sets the block size in bytes, at most MaxBufferSize. 0
+// selects it automatically.
+func WithBlockSize(blockSize int) Option {
+	return func(o *Options) error {
+		if blockSize < 0 || int64(blockSize) > MaxBufferSize {
+			return fmt.Errorf("%w: block size %d is outside 0 to %d", ErrInvalidOption, blockSize, MaxBufferSize)
+		}
+		o.BlockSize = blockSize
+		return nil
+	}
+}
+
+// WithThreads sets the codec goroutines of an AsyncCompressor. 0 uses
+// MaxWorkers.
+func WithThreads(n int) Option {
+	return func(o *Options) error {
+		if n < 0 {
+			return fmt.Errorf("%w: %d threads", ErrInvalidOption, n)
+		}
+		o.NumThreads = n
+		return nil
+	}
+}
+
+// WithFilters sets the Blosc2 filter pipeline, at most MaxFilters stages,
+// each of which must be one that compression can apply.
+func WithFilters(stages ...FilterStage) Option {
+	return func(o *Options) error {
+		if len(stages) > MaxFilters {
+			return fmt.Errorf("%w: %d filters, at most %d fit", ErrInvalidFilter, len(stages), MaxFilters)
+		}
+		var filters [MaxFilters]FilterStage
+		copy(filters[:], stages)
+		if _, err := newPipeline(filters, true); err != nil {
+			return err
+		}
+		o.Filters = filters
+		return nil
+	}
+}
+
+// WithTranspose adds FilterTranspose ahead of the filters already set, for
+// data that is a row-major matrix of rows by cols elements, and sets the
+// block size to rows whole rows, so that each block stores its columns one
+// after another. rows is at most 255; matrices with more rows are handled
+// in bands of rows. Set the type size first, since the block size depends
+// on it.
+func WithTranspose(rows, cols int) Option {
+	return func(o *Options) error {
+		if rows < 1 || rows > math.MaxUint8 || cols < 1 {
+			return fmt.Errorf("%w: %w: %d rows of %d columns; rows must be 1 to %d", ErrInvalidOption, ErrInvalidFilter, rows, cols, math.MaxUint8)
+		}
+		if o.Filters[MaxFilters-1].Filter != FilterNone {
+			return fmt.Errorf("%w: %d filters, at most %d fit", ErrInvalidFilter, MaxFilters+1, MaxFilters)
+		}
+		copy(o.Filters[1:], o.Filters[:MaxFilters-1])
+		o.Filters[0] = FilterStage{Filter: FilterTranspose, Meta: uint8(rows)}
+		o.BlockSize = rows * cols * max(o.TypeSize, 1)
+		return nil
+	}
+}
+
+// WithErrorBound sets the error FilterFloatQuantize may introduce, as
+// Options.ErrorBound does.
+func WithErrorBound(bound ErrorBound) Option {
+	return func(o *Options) error {
+		if err := bound.check(); err != nil {
+			return err
+		}
+		o.ErrorBound = bound
+		return nil
+	}
+}
+
+// WithCodecParams sets codec-specific tuning.
+func WithCodecParams(params CodecParams) Option {
+	return func(o *Options) error {
+		o.CodecParams = params
+		return nil
+	}
+}
+
+// WithZSTDLevel selects ZSTD at a level on zstd's own scale, from -131072 to
+// 22, as ZSTDParams.Level does. Options.Level keeps its value, so a later
+// WithLevel(0) still selects store mode.
+func WithZSTDLevel(level int) Option {
+	return func(o *Options) error {
+		if err := checkZSTDLevel(level); err != nil {
+			return err
+		}
+		o.Codec = ZSTD
+		o.CodecParams.ZSTD.Level = level
+		return nil
+	}
+}
+
+// WithZSTDDict selects ZSTD with a dictionary, as ZSTDParams.Dict does.
+func WithZSTDDict(dict *ZSTDDict) Option {
+	return func(o *Options) error {
+		o.Codec = ZSTD
+		o.CodecParams.ZSTD.Dict = dict
+		return nil
+	}
+}
+
+func checkZSTDLevel(level int) error {
+	if level < zstdMinLevel || level > zstdMaxLevel {
+		return fmt.Errorf("%w: zstd level %d is outside %d to %d", ErrInvalidOption, level, zstdMinLevel, zstdMaxLevel)
+	}
+	return nil
+}
+
+// WithLegacyFormat writes chunks readable by go-blosc 1.0.x, as
+// Options.LegacyFormat does.
+func WithLegacyFormat() Option {
+	return func(o *Options) error {
+		o.LegacyFormat = true
+		return nil
+	}
+}
+
+// WithSpecialValues writes special-value chunks for constant input, as
+// Options.SpecialValues does.
+func WithSpecialValues() Option {
+	return func(o *Options) error {
+		o.SpecialValues = true
+		return nil
+	}
+}
+
+// WithSkipIncompressible stores streams that look random without running the
+// codec, as Options.SkipIncompressible does.
+func WithSkipIncompressible() Option {
+	return func(o *Options) error {
+		o.SkipIncompressible = true
+		return nil
+	}
+}
+
+// WithBlockChecksums stores a checksum of each block, as
+// Options.BlockChecksums does.
+func WithBlockChecksums() Option {
+	return func(o *Options) error {
+		o.BlockChecksums = true
+		return nil
+	}
+}
+
+// WithPrefilter sets a per-block callback run before the filters and codec,
+// as Hooks.Prefilter does.
+func WithPrefilter(f func(block []byte, offset int)) Option {
+	return func(o *Options) error {
+		o.ownHooks().Prefilter = f
+		return nil
+	}
+}
+
+// WithLogger sets the logger that receives a Debug record for each chunk
+// compressed, as Hooks.Logger does.
+func WithLogger(l *slog.Logger) Option {
+	return func(o *Options) error {
+		o.ownHooks().Logger = l
+		return nil
+	}
+}
+
+// WithProgress sets the callback told of each block compressed, as
+// Hooks.Progress is.
+func WithProgress(f func(done, total int64)) Option {
+	return func(o *Options) error {
+		o.ownHooks().Progress = f
+		return nil
+	}
+}
+
+// WithDeterministic makes equal input compress to equal bytes in every run
+// and process, as Options.Deterministic does.
+func WithDeterministic() Option {
+	return func(o *Options) error {
+		o.Deterministic = true
+		return nil
+	}
+}
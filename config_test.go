@@ -0,0 +1,167 @@
+package blosc
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"testing"
+)
+
+func TestNewConfig(t *testing.T) {
+	cfg, err := NewConfig(WithCodec(ZSTD), WithLevel(7), WithBitShuffle(8), WithBlockSize(256<<10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := cfg.Options()
+	if opts.Codec != ZSTD || opts.Level != 7 || opts.Shuffle != BitShuffle || opts.TypeSize != 8 || opts.BlockSize != 256<<10 {
+		t.Fatalf("got %+v", opts)
+	}
+	opts.Level = 1
+	if cfg.Options().Level != 7 {
+		t.Error("changing the returned Options changed the Config")
+	}
+
+	data := makeFloatData(1 << 18)
+	chunk, err := cfg.Compress(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := CompressWithOptions(data, cfg.Options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(chunk, want) {
+		t.Error("Compress differs from CompressWithOptions")
+	}
+	got, err := Decompress(chunk)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("round trip failed: %v", err)
+	}
+
+	if cfg, err := NewConfig(WithProfile(ProfileFastest), WithLevel(3)); err != nil || cfg.Options().Level != 3 {
+		t.Errorf("profile then level: got %+v, %v", cfg.Options(), err)
+	}
+	if cfg, err := NewConfig(WithZSTDLevel(19)); err != nil || cfg.Options().Codec != ZSTD || cfg.Options().CodecParams.ZSTD.Level != 19 {
+		t.Errorf("zstd level: got %+v, %v", cfg.Options(), err)
+	}
+	if cfg, err := NewConfig(); err != nil || cfg.Options().Codec != DefaultOptions().Codec {
+		t.Errorf("no options: got %+v, %v", cfg.Options(), err)
+	}
+}
+
+func TestNewConfigRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []Option
+		want    error
+	}{
+		{"negative level", []Option{WithLevel(-1)}, ErrInvalidOption},
+		{"level 10", []Option{WithLevel(10)}, ErrInvalidOption},
+		{"zstd level 23", []Option{WithZSTDLevel(23)}, ErrInvalidOption},
+		{"zstd params level 23", []Option{WithCodecParams(CodecParams{ZSTD: ZSTDParams{Level: 23}})}, ErrInvalidOption},
+		{"type size 0", []Option{WithShuffle(0)}, ErrInvalidOption},
+		{"type size 256", []Option{WithBitShuffle(256)}, ErrInvalidOption},
+		{"negative block size", []Option{WithBlockSize(-1)}, ErrInvalidOption},
+		{"negative threads", []Option{WithThreads(-1)}, ErrInvalidOption},
+		{"negative acceleration", []Option{WithCodecParams(CodecParams{LZ4: LZ4Params{Acceleration: -1}})}, ErrInvalidOption},
+		{"unregistered codec", []Option{WithCodec(Codec(200))}, ErrInvalidCodec},
+		{"unknown profile", []Option{WithProfile("turbo")}, ErrInvalidProfile},
+		{"unknown filter", []Option{WithFilters(FilterStage{Filter: Filter(99)})}, ErrInvalidFilter},
+		{"too many filters", []Option{WithFilters(make([]FilterStage, MaxFilters+1)...)}, ErrInvalidFilter},
+		{"legacy filters", []Option{WithFilters(FilterStage{Filter: FilterShuffle}), WithLegacyFormat()}, ErrInvalidFilter},
+	}
+	for _, tt := range tests {
+		if _, err := NewConfig(tt.options...); !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	for _, name := range Profiles() {
+		opts, _ := Profile(name)
+		if err := opts.Validate(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	valid := DefaultOptions()
+	valid.Level = 0
+	valid.BlockSize = 64
+	valid.Filters[0] = FilterStage{Filter: FilterShuffle}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("%+v: %v", valid, err)
+	}
+
+	tests := []struct {
+		name string
+		set  func(o *Options)
+		want error
+	}{
+		{"level 12", func(o *Options) { o.Level = 12 }, ErrInvalidOption},
+		{"type size 0", func(o *Options) { o.TypeSize = 0 }, ErrInvalidOption},
+		{"small block", func(o *Options) { o.BlockSize = 64 }, ErrInvalidOption},
+		{"uneven block", func(o *Options) { o.BlockSize = 1001 }, ErrInvalidOption},
+		{"shuffle", func(o *Options) { o.Shuffle = 7 }, ErrInvalidShuffle},
+		{"codec", func(o *Options) { o.Codec = Codec(200) }, ErrInvalidCodec},
+		{"filter", func(o *Options) { o.Filters[0].Filter = Filter(99) }, ErrInvalidFilter},
+		{"zstd level", func(o *Options) { o.CodecParams.ZSTD.Level = 30 }, ErrInvalidOption},
+	}
+	data := makeFloatData(1 << 14)
+	for _, tt := range tests {
+		opts := DefaultOptions()
+		tt.set(&opts)
+		if err := opts.Validate(); !errors.Is(err, tt.want) || !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+
+		// Compression adjusts the options unless Strict is set
+		if tt.want == ErrInvalidOption {
+			if _, err := CompressWithOptions(data, opts); err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+		}
+		opts.Strict = true
+		if _, err := CompressWithOptions(data, opts); !errors.Is(err, tt.want) {
+			t.Errorf("%s: strict compression: expected %v, got %v", tt.name, tt.want, err)
+		}
+		if _, err := CompressFrom(bytes.NewReader(data), int64(len(data)), opts); !errors.Is(err, tt.want) {
+			t.Errorf("%s: strict CompressFrom: expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+
+	a := NewAsyncCompressor(Options{Codec: LZ4, Level: 12, TypeSize: 4, Strict: true})
+	go func() {
+		a.Submit(0, data)
+		a.Close()
+	}()
+	for r := range a.Results() {
+		if !errors.Is(r.Err, ErrInvalidOption) {
+			t.Errorf("strict AsyncCompressor: expected ErrInvalidOption, got %v", r.Err)
+		}
+	}
+}
+
+func TestOptionsComparable(t *testing.T) {
+	base, err := NewConfig(WithCodec(LZ4), WithProgress(func(int64, int64) {}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := base.Options()
+	if opts != base.Options() {
+		t.Error("copies of the same options compare unequal")
+	}
+
+	// Setting a hook on a copy leaves the Hooks it shared alone
+	for _, o := range []Option{WithPrefilter(func([]byte, int) {}), WithLogger(slog.Default()), WithProgress(nil)} {
+		changed := opts
+		if err := o(&changed); err != nil {
+			t.Fatal(err)
+		}
+		if changed == opts {
+			t.Error("options compare equal after a hook was set")
+		}
+	}
+	if opts.Hooks.Prefilter != nil || opts.Hooks.Logger != nil || opts.Hooks.Progress == nil {
+		t.Errorf("setting hooks on copies changed the original: %+v", opts.Hooks)
+	}
+}
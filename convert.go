@@ -0,0 +1,132 @@
+package blosc
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+)
+
+// ToSChunk returns an in-memory SChunk holding the chunks and metalayers of
+// f as stored, so nothing is decompressed or recompressed, that compresses
+// appended buffers with opts. Chunks are shared with the frame data, except
+// that those of a memory-mapped frame are copied so that the SChunk outlives
+// Close, and those of a frame opened with OpenFrameReaderAt are read from its
+// io.ReaderAt when needed, like the frame's own. An encrypted frame gives an SChunk encrypted with the same key,
+// which needs SetKeys unless the frame's keys were set.
+func (f *Frame) ToSChunk(opts Options) *SChunk {
+	s := NewSChunk(opts)
+	s.chunks = make([]storedChunk, len(f.chunks))
+	s.source = f.ra
+	var clones map[int64][]byte // Copies of mapped chunks, shared as in f
+	if f.mapped != nil {
+		clones = make(map[int64][]byte)
+	}
+	for i, e := range f.chunks {
+		if f.ra != nil {
+			s.chunks[i] = storedChunk{offset: e.offset, length: e.length, nbytes: e.nbytes}
+			continue
+		}
+		chunk := f.data[e.offset : e.offset+e.length]
+		if clones != nil {
+			if clones[e.offset] == nil {
+				clones[e.offset] = slices.Clone(chunk)
+			}
+			chunk = clones[e.offset]
+		}
+		s.chunks[i] = storedChunk{data: chunk, length: e.length, nbytes: e.nbytes}
+	}
+	s.recount()
+	for _, name := range f.metaNames {
+		content := f.metalayers[name]
+		if f.mapped != nil {
+			content = slices.Clone(content)
+		}
+		s.SetMetalayer(name, content)
+	}
+	s.cipher = f.cipher
+	if s.cipher == nil {
+		s.lockedKey, s.lockedFrame = f.keyID, f.frameID
+	}
+	return s
+}
+
+// ToFrame returns s serialized as a frame in memory, as WriteTo writes it.
+// Encrypted chunks stay encrypted, and the frame has the keys of s.
+func (s *SChunk) ToFrame() (*Frame, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var buf bytes.Buffer
+	buf.Grow(int(frameHeaderSize + s.cbytes))
+	if _, err := s.writeTo(&buf); err != nil {
+		return nil, err
+	}
+	f, err := OpenFrame(buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	f.cipher = s.cipher
+	return f, nil
+}
+
+// CopyTo appends the chunks of s to dst as stored, and sets its metalayers,
+// so that converting between frame files, sparse frames and ChunkStores
+// costs no decompression. Encrypted chunks are copied still encrypted. An
+// empty dst takes on the encryption of s, and its chunks are copied as
+// stored, needing no keys; any other dst must be encrypted with the same key
+// ID, and since encrypted chunks are bound to their index and SChunk, they
+// are decrypted and sealed again for it, which needs the keys of both.
+// In-memory chunks are shared between s and an in-memory dst, and chunks
+// sharing storage in s share it in dst. The copy to a file-backed dst is
+// committed by Sync or Close, as any change is.
+func (s *SChunk) CopyTo(dst *SChunk) error {
+	if dst == s {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	} else {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		dst.mu.Lock()
+		defer dst.mu.Unlock()
+	}
+	empty := len(dst.chunks) == 0
+	if empty {
+		dst.cipher, dst.lockedKey, dst.lockedFrame = s.cipher, s.lockedKey, s.lockedFrame
+	} else if dst.keyID() != s.keyID() {
+		return fmt.Errorf("%w: copying chunks encrypted with key %q into an SChunk with key %q", ErrInvalidKey, s.keyID(), dst.keyID())
+	}
+	// Chunks sharing storage in s share it in dst, and unencrypted ones are
+	// deduplicated against dst under its SetDedup
+	copied := make(map[storageKey]storedChunk)
+	encrypted := s.cipher != nil || s.lockedKey != ""
+	for i, c := range s.chunks {
+		stored, ok := copied[c.key()]
+		if !ok {
+			var chunk []byte
+			var err error
+			if encrypted && !empty {
+				chunk, err = s.chunk(i)
+			} else {
+				chunk, err = s.stored(i)
+			}
+			if err != nil {
+				return err
+			}
+			if encrypted && empty {
+				stored, err = dst.storeWith(nil, i, chunk, int(c.nbytes))
+			} else {
+				stored, _, err = dst.store(len(dst.chunks), chunk, int(c.nbytes))
+			}
+			if err != nil {
+				return err
+			}
+			copied[c.key()] = stored
+		}
+		dst.chunks = append(dst.chunks, stored)
+	}
+	dst.recount()
+	for _, name := range s.metaNames {
+		dst.setMetalayer(name, s.metalayers[name])
+	}
+	dst.touch()
+	return nil
+}
@@ -0,0 +1,136 @@
+package blosc
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// checkConverted checks that s holds the chunks and metalayer of makeSChunk
+func checkConverted(t *testing.T, s interface {
+	NumChunks() int
+	DecompressChunk(int) ([]byte, error)
+	Metalayer(string) ([]byte, bool)
+}, want [][]byte) {
+	t.Helper()
+	checkChunks(t, s, want)
+	if content, ok := s.Metalayer("units"); !ok || string(content) != "kelvin" {
+		t.Errorf("metalayer = %q, %v", content, ok)
+	}
+}
+
+func TestFrameSChunkConversion(t *testing.T) {
+	sc, want := makeSChunk(t, 3)
+	f, err := sc.ToFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkConverted(t, f, want)
+	if f.NBytes() != sc.NBytes() || f.CBytes() != sc.CBytes() {
+		t.Errorf("frame has %d/%d bytes, want %d/%d", f.NBytes(), f.CBytes(), sc.NBytes(), sc.CBytes())
+	}
+
+	back := f.ToSChunk(sc.Options())
+	checkConverted(t, back, want)
+	if back.NBytes() != sc.NBytes() || back.CBytes() != sc.CBytes() {
+		t.Errorf("SChunk has %d/%d bytes, want %d/%d", back.NBytes(), back.CBytes(), sc.NBytes(), sc.CBytes())
+	}
+	// The SChunk can grow
+	data := makeTestData(3000)
+	if _, err := back.AppendBuffer(data); err != nil {
+		t.Fatal(err)
+	}
+	checkConverted(t, back, append(want, data))
+}
+
+func TestConvertSparse(t *testing.T) {
+	sc, want := makeSChunk(t, 3)
+	dir := t.TempDir()
+
+	// Contiguous frame file to sparse directory and back
+	path := filepath.Join(dir, "data.gbframe")
+	file, err := CreateSChunkFile(path, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sc.CopyTo(file); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f, err := OpenFrameMmap(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sparse, err := CreateSChunkDir(filepath.Join(dir, "sparse"), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.ToSChunk(Options{}).CopyTo(sparse); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sparse.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	sparse, err = OpenSChunkDir(filepath.Join(dir, "sparse"), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkConverted(t, sparse, want)
+	f, err = sparse.ToFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkConverted(t, f, want)
+}
+
+func TestConvertEncrypted(t *testing.T) {
+	sc, want := makeSChunk(t, 2)
+	if err := sc.SetEncryption("k1", testKeys); err != nil {
+		t.Fatal(err)
+	}
+	f, err := sc.ToFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkConverted(t, f, want)
+
+	// Without keys, chunks are copied but cannot be read
+	locked, err := OpenFrame(mustFrameBytes(t, sc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied := NewSChunk(Options{})
+	if err := locked.ToSChunk(Options{}).CopyTo(copied); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := copied.Chunk(0); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("locked copy: got %v, want ErrInvalidKey", err)
+	}
+	if err := copied.SetKeys(testKeys); err != nil {
+		t.Fatal(err)
+	}
+	checkConverted(t, copied, want)
+
+	// Chunks under one key do not go into an SChunk under another
+	other, _ := makeSChunk(t, 1)
+	if err := sc.CopyTo(other); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("mixed keys: got %v, want ErrInvalidKey", err)
+	}
+}
+
+// mustFrameBytes returns s written as a frame
+func mustFrameBytes(t *testing.T, s *SChunk) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if _, err := s.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,172 @@
+package blosc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// optionsVersion is the version of the binary options encoding that
+// Options.Marshal writes. Later versions may add settings; UnmarshalOptions
+// rejects versions it does not know rather than drop settings.
+const optionsVersion = 1
+
+// Flag bits of the binary options encoding
+const (
+	optLegacy = 1 << iota
+	optSpecial
+	optSkip
+	optChecksums
+	optStrict
+	optDeterministic
+	optHuffmanOnly
+)
+
+// Marshal encodes the settings MarshalText encodes in a compact, versioned
+// binary form, like the cparams of Blosc2, for services that store or
+// exchange exact compression settings alongside data. Default options take
+// 14 bytes. UnmarshalOptions decodes it. Options with a Prefilter or a ZSTD
+// dictionary cannot be encoded; other Hooks are not encoded.
+//
+// The encoding starts with a version byte, followed by the codec, shuffle
+// and a byte of boolean flags, then level, type size, block size and
+// threads as varints, the filter slots up to the last one set, the codec
+// parameters as varints and the error bounds as the uvarints of their
+// IEEE 754 bits.
+func (o Options) Marshal() ([]byte, error) {
+	if o.prefilter() != nil {
+		return nil, errors.New("blosc: cannot encode options with a Prefilter")
+	}
+	if o.CodecParams.ZSTD.Dict != nil {
+		return nil, errors.New("blosc: cannot encode options with a ZSTD dictionary")
+	}
+	var flags byte
+	for bit, set := range map[byte]bool{
+		optLegacy:        o.LegacyFormat,
+		optSpecial:       o.SpecialValues,
+		optSkip:          o.SkipIncompressible,
+		optChecksums:     o.BlockChecksums,
+		optStrict:        o.Strict,
+		optDeterministic: o.Deterministic,
+		optHuffmanOnly:   o.CodecParams.ZLIB.HuffmanOnly,
+	} {
+		if set {
+			flags |= bit
+		}
+	}
+	b := []byte{optionsVersion, byte(o.Codec), byte(o.Shuffle), flags}
+	b = binary.AppendVarint(b, int64(o.Level))
+	b = binary.AppendVarint(b, int64(o.TypeSize))
+	b = binary.AppendVarint(b, int64(o.BlockSize))
+	b = binary.AppendVarint(b, int64(o.NumThreads))
+
+	n := MaxFilters
+	for n > 0 && o.Filters[n-1] == (FilterStage{}) {
+		n--
+	}
+	b = append(b, byte(n))
+	for _, stage := range o.Filters[:n] {
+		b = append(b, byte(stage.Filter), stage.Meta)
+	}
+
+	p := o.CodecParams
+	b = binary.AppendVarint(b, int64(p.LZ4.Acceleration))
+	b = binary.AppendVarint(b, int64(p.ZSTD.WindowLog))
+	b = binary.AppendVarint(b, int64(p.ZSTD.Level))
+	b = binary.AppendUvarint(b, math.Float64bits(o.ErrorBound.Absolute))
+	b = binary.AppendUvarint(b, math.Float64bits(o.ErrorBound.Relative))
+	return b, nil
+}
+
+// UnmarshalOptions decodes options encoded by Options.Marshal. Errors wrap
+// ErrInvalidOption, and also ErrInvalidVersion for encodings of a later
+// version. Hooks are left unset.
+func UnmarshalOptions(data []byte) (Options, error) {
+	if len(data) < 4 {
+		return Options{}, fmt.Errorf("%w: %d bytes of encoded options", ErrInvalidOption, len(data))
+	}
+	if data[0] != optionsVersion {
+		return Options{}, fmt.Errorf("%w: %w: options encoding version %d, expected %d", ErrInvalidOption, ErrInvalidVersion, data[0], optionsVersion)
+	}
+	o := Options{Codec: Codec(data[1]), Shuffle: Shuffle(data[2])}
+	flags := data[3]
+	if flags&^(optHuffmanOnly<<1-1) != 0 {
+		return Options{}, fmt.Errorf("%w: unknown option flags 0x%02x", ErrInvalidOption, flags)
+	}
+	o.LegacyFormat = flags&optLegacy != 0
+	o.SpecialValues = flags&optSpecial != 0
+	o.SkipIncompressible = flags&optSkip != 0
+	o.BlockChecksums = flags&optChecksums != 0
+	o.Strict = flags&optStrict != 0
+	o.Deterministic = flags&optDeterministic != 0
+	o.CodecParams.ZLIB.HuffmanOnly = flags&optHuffmanOnly != 0
+
+	d := optionsDecoder{data: data[4:]}
+	o.Level = d.int()
+	o.TypeSize = d.int()
+	o.BlockSize = d.int()
+	o.NumThreads = d.int()
+	if n := int(d.byte()); n > MaxFilters {
+		d.fail("%d filters, at most %d fit", n, MaxFilters)
+	} else {
+		for i := range o.Filters[:n] {
+			o.Filters[i] = FilterStage{Filter: Filter(d.byte()), Meta: d.byte()}
+		}
+	}
+	o.CodecParams.LZ4.Acceleration = d.int()
+	o.CodecParams.ZSTD.WindowLog = d.int()
+	o.CodecParams.ZSTD.Level = d.int()
+	o.ErrorBound.Absolute = math.Float64frombits(d.uint())
+	o.ErrorBound.Relative = math.Float64frombits(d.uint())
+	if d.err == nil && len(d.data) > 0 {
+		d.fail("%d bytes after the options", len(d.data))
+	}
+	if d.err != nil {
+		return Options{}, d.err
+	}
+	return o, nil
+}
+
+// optionsDecoder reads the fields of encoded options, keeping the first
+// error
+type optionsDecoder struct {
+	data []byte
+	err  error
+}
+
+func (d *optionsDecoder) fail(format string, args ...any) {
+	if d.err == nil {
+		d.err = fmt.Errorf("%w: "+format, append([]any{ErrInvalidOption}, args...)...)
+	}
+}
+
+func (d *optionsDecoder) byte() byte {
+	if len(d.data) == 0 {
+		d.fail("encoded options are truncated")
+		return 0
+	}
+	b := d.data[0]
+	d.data = d.data[1:]
+	return b
+}
+
+func (d *optionsDecoder) int() int {
+	v, n := binary.Varint(d.data)
+	if n <= 0 || v < math.MinInt || v > math.MaxInt {
+		d.fail("encoded options are truncated or out of range")
+		return 0
+	}
+	d.data = d.data[n:]
+	return int(v)
+}
+
+func (d *optionsDecoder) uint() uint64 {
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.fail("encoded options are truncated or out of range")
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
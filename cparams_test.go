@@ -0,0 +1,60 @@
+package blosc
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestOptionsMarshal(t *testing.T) {
+	float, _ := Profile(ProfileFloatData)
+	for _, opts := range []Options{
+		{},
+		DefaultOptions(),
+		float,
+		{Codec: ZLIB, Level: 9, Shuffle: BitShuffle, TypeSize: 8, BlockSize: 1 << 16, NumThreads: 4, LegacyFormat: true, SkipIncompressible: true, Strict: true,
+			CodecParams: CodecParams{LZ4: LZ4Params{Acceleration: 8}, ZSTD: ZSTDParams{WindowLog: 20, Level: -3}, ZLIB: ZLIBParams{HuffmanOnly: true}}},
+		{Codec: ZSTD, Level: 1, TypeSize: TypeSizeAuto, BlockChecksums: true, SpecialValues: true, Deterministic: true, Filters: [MaxFilters]FilterStage{2: {Filter: FilterNDCell, Meta: 4}, 3: {Filter: FilterShuffle}}},
+		{Codec: ZSTD, Level: 5, TypeSize: 8, Filters: [MaxFilters]FilterStage{{Filter: FilterFloatQuantize}, {Filter: FilterShuffle}}, ErrorBound: ErrorBound{Absolute: 1e-6, Relative: 0.25}},
+	} {
+		b, err := opts.Marshal()
+		if err != nil {
+			t.Fatalf("%+v: %v", opts, err)
+		}
+		got, err := UnmarshalOptions(b)
+		if err != nil {
+			t.Fatalf("%x: %v", b, err)
+		}
+		if !reflect.DeepEqual(got, opts) {
+			t.Errorf("%x: got %+v, want %+v", b, got, opts)
+		}
+	}
+
+	b, err := DefaultOptions().Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) != 14 {
+		t.Errorf("default options encode to %d bytes, want 14", len(b))
+	}
+	for i := range b {
+		if _, err := UnmarshalOptions(b[:i]); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("truncated to %d bytes: expected ErrInvalidOption, got %v", i, err)
+		}
+	}
+	if _, err := UnmarshalOptions(append(b, 0)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("trailing byte: expected ErrInvalidOption, got %v", err)
+	}
+	newer := append([]byte{optionsVersion + 1}, b[1:]...)
+	if _, err := UnmarshalOptions(newer); !errors.Is(err, ErrInvalidOption) || !errors.Is(err, ErrInvalidVersion) {
+		t.Errorf("expected ErrInvalidVersion, got %v", err)
+	}
+	flags := append([]byte(nil), b...)
+	flags[3] = 0x80
+	if _, err := UnmarshalOptions(flags); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("unknown flag: expected ErrInvalidOption, got %v", err)
+	}
+	if _, err := (Options{Hooks: &Hooks{Prefilter: func([]byte, int) {}}}).Marshal(); err == nil {
+		t.Error("options with a Prefilter encoded")
+	}
+}
@@ -0,0 +1,91 @@
+package blosc
+
+import "crypto/sha256"
+
+// dedupIndex finds the stored chunks of an SChunk by content, so that
+// identical chunks are stored once. Chunks are known by the SHA-256 of their
+// compressed bytes before any encryption, which no two different chunks share
+// in practice, so a match needs no byte comparison. A nil index finds nothing.
+type dedupIndex struct {
+	chunks map[[sha256.Size]byte]storedChunk
+	sums   map[storageKey][sha256.Size]byte // Digests of the chunks, by storage
+}
+
+// storageKey identifies the storage of a chunk: its bytes in memory, or its
+// position in the backing or source frame
+type storageKey struct {
+	data   *byte
+	offset int64
+}
+
+// key returns where c is stored; chunks sharing storage have the same key
+func (c storedChunk) key() storageKey {
+	if len(c.data) > 0 {
+		return storageKey{data: &c.data[0]}
+	}
+	return storageKey{offset: c.offset}
+}
+
+func newDedupIndex() *dedupIndex {
+	return &dedupIndex{
+		chunks: make(map[[sha256.Size]byte]storedChunk),
+		sums:   make(map[storageKey][sha256.Size]byte),
+	}
+}
+
+// add makes c, whose bytes before encryption have digest sum, the stored
+// chunk for that content
+func (d *dedupIndex) add(sum [sha256.Size]byte, c storedChunk) {
+	d.chunks[sum] = c
+	d.sums[c.key()] = sum
+}
+
+// remove forgets the chunk stored at k, once no chunk is stored there
+func (d *dedupIndex) remove(k storageKey) {
+	if d == nil {
+		return
+	}
+	if sum, ok := d.sums[k]; ok {
+		delete(d.chunks, sum)
+		delete(d.sums, k)
+	}
+}
+
+// SetDedup makes s store identical chunks once. A chunk appended, inserted
+// or updated with the same compressed bytes as a chunk already in s shares
+// its storage, in memory, in a backing file or in a ChunkStore, and frames
+// written by WriteTo hold it once, however many chunk indexes refer to it.
+// Buffers compressed with the same options give the same chunk, so repeated
+// buffers, such as simulation snapshots that return to the same state, cost
+// one chunk per distinct content. Encrypted chunks, which SetEncryption
+// binds to their index, are never shared. Enabling it reads and hashes the
+// chunks already in s, which an SChunk opened encrypted needs SetKeys for;
+// chunks already sharing storage stay shared when it is disabled, the
+// default.
+func (s *SChunk) SetDedup(on bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !on {
+		s.dedup = nil
+		return nil
+	}
+	if s.dedup != nil {
+		return nil
+	}
+	dedup := newDedupIndex()
+	for i, c := range s.chunks {
+		if _, ok := dedup.sums[c.key()]; ok {
+			continue
+		}
+		chunk, err := s.chunk(i)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(chunk)
+		if _, ok := dedup.chunks[sum]; !ok {
+			dedup.add(sum, c)
+		}
+	}
+	s.dedup = dedup
+	return nil
+}
@@ -0,0 +1,160 @@
+package blosc
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+)
+
+// appendSnapshots appends rounds of the same three buffers to sc and returns
+// what each chunk holds
+func appendSnapshots(t *testing.T, sc *SChunk, rounds int) [][]byte {
+	t.Helper()
+	states := [][]byte{makeTestData(20000), makeFloatData(5000), makeTestData(30000)}
+	var want [][]byte
+	for r := 0; r < rounds; r++ {
+		for _, data := range states {
+			if _, err := sc.AppendBuffer(data); err != nil {
+				t.Fatal(err)
+			}
+			want = append(want, data)
+		}
+	}
+	return want
+}
+
+func TestSChunkDedup(t *testing.T) {
+	opts := Options{Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: 4}
+	plain := NewSChunk(opts)
+	appendSnapshots(t, plain, 10)
+	sc := NewSChunk(opts)
+	if err := sc.SetDedup(true); err != nil {
+		t.Fatal(err)
+	}
+	want := appendSnapshots(t, sc, 10)
+	checkChunks(t, sc, want)
+	if sc.NBytes() != plain.NBytes() || sc.CBytes()*10 != plain.CBytes() {
+		t.Errorf("dedup holds %d/%d bytes, plain %d/%d", sc.NBytes(), sc.CBytes(), plain.NBytes(), plain.CBytes())
+	}
+
+	// Frames hold each chunk once and keep the sharing
+	frame := mustFrameBytes(t, sc)
+	if full := mustFrameBytes(t, plain); int64(len(full)-len(frame)) != plain.CBytes()-sc.CBytes() {
+		t.Errorf("frame is %d bytes, %d without dedup", len(frame), len(full))
+	}
+	f, err := OpenFrame(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.CBytes() != sc.CBytes() || f.NBytes() != sc.NBytes() {
+		t.Errorf("frame holds %d/%d bytes, SChunk %d/%d", f.NBytes(), f.CBytes(), sc.NBytes(), sc.CBytes())
+	}
+	back := f.ToSChunk(opts)
+	checkChunks(t, back, want)
+	if back.CBytes() != sc.CBytes() || !bytes.Equal(mustFrameBytes(t, back), frame) {
+		t.Errorf("reopened SChunk holds %d bytes, want %d", back.CBytes(), sc.CBytes())
+	}
+
+	// Shared storage counts until its last chunk goes
+	cbytes := sc.CBytes()
+	for i := 0; i < 9; i++ {
+		if err := sc.DeleteChunk(0); err != nil {
+			t.Fatal(err)
+		}
+		want = want[1:]
+		if i%3 == 2 && sc.CBytes() != cbytes {
+			t.Fatalf("after %d deletions: %d bytes, want %d", i+1, sc.CBytes(), cbytes)
+		}
+	}
+	checkChunks(t, sc, want)
+
+	// Updating a chunk to another state shares that state's storage
+	first, _ := sc.Chunk(0)
+	if err := sc.UpdateChunk(1, first); err != nil {
+		t.Fatal(err)
+	}
+	want[1] = want[0]
+	checkChunks(t, sc, want)
+	if sc.CBytes() != cbytes {
+		t.Errorf("after update: %d bytes, want %d", sc.CBytes(), cbytes)
+	}
+
+	// Encrypted chunks are bound to their index, so they stop sharing
+	if err := sc.SetEncryption("k", StaticKeys{"k": make([]byte, 32)}); err != nil {
+		t.Fatal(err)
+	}
+	checkChunks(t, sc, want)
+	if len(sc.shared) != 0 {
+		t.Errorf("%d encrypted chunks share storage", len(sc.shared))
+	}
+	cbytes = sc.CBytes()
+	if _, err := sc.AppendBuffer(want[0]); err != nil {
+		t.Fatal(err)
+	}
+	want = append(want, want[0])
+	checkChunks(t, sc, want)
+	if sc.CBytes() == cbytes {
+		t.Error("encrypted duplicate shares storage")
+	}
+}
+
+func TestSChunkDedupStore(t *testing.T) {
+	opts := Options{Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: 4}
+	store := NewMemStore()
+	sc, err := CreateSChunkStore(store, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sc.SetDedup(true); err != nil {
+		t.Fatal(err)
+	}
+	want := appendSnapshots(t, sc, 4)
+	if err := sc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := store.Len(); n != 3+1 {
+		t.Errorf("store holds %d keys, want 3 chunks and the index", n)
+	}
+
+	// Reopened, the shared chunks stay until their last index entry goes
+	sc, err = OpenSChunkStore(store, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkChunks(t, sc, want)
+	for i := 0; i < 3; i++ {
+		if err := sc.DeleteChunk(0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want = want[3:]
+	if err := sc.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	checkChunks(t, sc, want)
+	if n := store.Len(); n != 3+1 {
+		t.Errorf("store holds %d keys after deletions, want 4", n)
+	}
+
+	// Chunks appended after reopening find the stored ones once enabled
+	if err := sc.SetDedup(true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sc.AppendBuffer(want[0]); err != nil {
+		t.Fatal(err)
+	}
+	want = append(want, want[0])
+	for sc.NumChunks() > 1 {
+		if err := sc.DeleteChunk(1); err != nil {
+			t.Fatal(err)
+		}
+		want = slices.Delete(want, 1, 2)
+	}
+	if err := sc.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	checkChunks(t, sc, want)
+	if n := store.Len(); n != 1+1 {
+		t.Errorf("store holds %d keys, want 1 chunk and the index", n)
+	}
+}
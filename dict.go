@@ -0,0 +1,87 @@
+package blosc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"slices"
+)
+
+// zstdDictMetalayer is the metalayer an SChunk keeps its ZSTDDict in, so
+// that the frames it writes decompress without being given the dictionary
+const zstdDictMetalayer = "zdict"
+
+// zstdDictMagic starts dictionaries in the zstd format, as zstd --train
+// writes them
+const zstdDictMagic = 0xEC30A437
+
+// ZSTDDict is a zstd dictionary for ZSTDParams.Dict, ready to compress and
+// decompress with. Build it once and share it: it keeps the encoders and
+// decoder it needs, built with the ZSTDConfig in effect.
+type ZSTDDict struct {
+	data  []byte
+	id    uint32
+	codec *zstdDictCodec
+}
+
+// NewZSTDDict returns the dictionary held in data: one in the zstd format,
+// as zstd --train or the zstd package's BuildDict write, or else raw content
+// that streams are primed with as if it came before them. data is copied.
+// Without the ZSTD codec, as under the blosc_nozstd build tag, it fails with
+// ErrInvalidCodec.
+func NewZSTDDict(data []byte) (*ZSTDDict, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%w: empty zstd dictionary", ErrInvalidOption)
+	}
+	d := &ZSTDDict{data: slices.Clone(data)}
+	if d.formatted() {
+		d.id = binary.LittleEndian.Uint32(data[4:])
+	}
+	codec, err := newZSTDDictCodec(d)
+	if err != nil {
+		return nil, err
+	}
+	d.codec = codec
+	return d, nil
+}
+
+// formatted reports whether the dictionary is in the zstd format, rather
+// than raw content
+func (d *ZSTDDict) formatted() bool {
+	return len(d.data) >= 8 && binary.LittleEndian.Uint32(d.data) == zstdDictMagic
+}
+
+// ID returns the dictionary ID that zstd frames compressed with a
+// dictionary in the zstd format refer to it by, or 0 for raw content.
+func (d *ZSTDDict) ID() uint32 {
+	return d.id
+}
+
+// Bytes returns the dictionary as NewZSTDDict was given it. The slice is
+// shared with d and must not be modified.
+func (d *ZSTDDict) Bytes() []byte {
+	return d.data
+}
+
+// containerEnv returns what filters and codecs need to know from the
+// metalayers of an SChunk or frame, or nil if nothing. dict, if it holds
+// the content of the "zdict" metalayer, is used rather than parsed again. A
+// malformed metalayer is ignored, leaving the chunks that need it to fail.
+func containerEnv(metalayers map[string][]byte, dict *ZSTDDict) *filterEnv {
+	var env filterEnv
+	if meta, ok := metalayers[b2ndMetalayer]; ok {
+		if e := b2ndEnv(meta); e != nil {
+			env = *e
+		}
+	}
+	if content, ok := metalayers[zstdDictMetalayer]; ok {
+		if dict == nil || !bytes.Equal(dict.data, content) {
+			dict, _ = NewZSTDDict(content)
+		}
+		env.dict = dict
+	}
+	if env.blockShape == nil && env.dict == nil {
+		return nil
+	}
+	return &env
+}
@@ -0,0 +1,170 @@
+package blosc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// makeRecords returns n small JSON records, the kind of data dictionaries
+// help with
+func makeRecords(first, n int) []byte {
+	var b bytes.Buffer
+	for i := first; i < first+n; i++ {
+		fmt.Fprintf(&b, `{"id":%d,"sensor":"station-%d","status":"nominal","unit":"celsius","reading":%d}`+"\n", i, i%7, i*37%1000)
+	}
+	return b.Bytes()
+}
+
+func TestZSTDDict(t *testing.T) {
+	samples := [][]byte{makeRecords(0, 20), makeRecords(500, 20), makeRecords(900, 20)}
+	trained, err := zstd.BuildDict(zstd.BuildDictOptions{ID: 1234, Contents: samples, History: makeRecords(100, 40)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := makeRecords(2000, 3)
+	plain, err := CompressWithOptions(data, Options{Codec: ZSTD, Level: 5, Shuffle: NoShuffle, TypeSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name string
+		dict []byte
+		id   uint32
+	}{
+		{"raw", makeRecords(100, 40), 0},
+		{"trained", trained, 1234},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dict, err := NewZSTDDict(tt.dict)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dict.ID() != tt.id || !bytes.Equal(dict.Bytes(), tt.dict) {
+				t.Errorf("dictionary has ID %d and %d bytes, want %d and %d", dict.ID(), len(dict.Bytes()), tt.id, len(tt.dict))
+			}
+			opts := Options{Codec: ZSTD, Level: 5, Shuffle: NoShuffle, TypeSize: 1, CodecParams: CodecParams{ZSTD: ZSTDParams{Dict: dict}}}
+			chunk, err := CompressWithOptions(data, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			h, _ := ParseHeader(chunk)
+			if !h.HasDict() {
+				t.Fatalf("chunk header %s does not record the dictionary", h)
+			}
+			if len(chunk) >= len(plain) {
+				t.Errorf("%d bytes with the dictionary, %d without", len(chunk), len(plain))
+			}
+
+			if _, err := Decompress(chunk); !errors.Is(err, ErrMissingDict) {
+				t.Errorf("expected ErrMissingDict, got %v", err)
+			}
+			got, err := DecompressWithOptions(chunk, DecompressOptions{Dict: dict})
+			if err != nil || !bytes.Equal(got, data) {
+				t.Fatalf("decompressing with the dictionary: %v", err)
+			}
+			if err := Validate(chunk); err != nil {
+				t.Errorf("Validate: %v", err)
+			}
+		})
+	}
+
+	if _, err := NewZSTDDict(nil); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("empty dictionary: expected ErrInvalidOption, got %v", err)
+	}
+	dict, err := NewZSTDDict(trained)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := Options{Codec: ZSTD, Level: 5, TypeSize: 1, LegacyFormat: true, CodecParams: CodecParams{ZSTD: ZSTDParams{Dict: dict}}}
+	if _, err := CompressWithOptions(data, legacy); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("legacy chunk with a dictionary: expected ErrInvalidOption, got %v", err)
+	}
+	if err := legacy.Validate(); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Validate: expected ErrInvalidOption, got %v", err)
+	}
+	if _, err := legacy.MarshalText(); err == nil {
+		t.Error("options with a dictionary encoded")
+	}
+
+	// Other codecs do not use the dictionary, and chunks claiming one fail
+	lz4 := Options{Codec: LZ4, Level: 5, TypeSize: 4, CodecParams: CodecParams{ZSTD: ZSTDParams{Dict: dict}}}
+	chunk, err := CompressWithOptions(makeTestData(20000), lz4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h, _ := ParseHeader(chunk); h.HasDict() {
+		t.Error("LZ4 chunk records a dictionary")
+	}
+	chunk, err = CompressWithOptions(makeTestData(20000), Options{Codec: LZ4, Level: 5, TypeSize: 4, BlockChecksums: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunk[offsetBlosc2Flags] |= blosc2FlagDict
+	if _, err := DecompressWithOptions(chunk, DecompressOptions{Dict: dict}); !errors.Is(err, ErrUnsupportedFeature) {
+		t.Errorf("Decompress: expected ErrUnsupportedFeature, got %v", err)
+	}
+	if err := Validate(chunk); !errors.Is(err, ErrUnsupportedFeature) {
+		t.Errorf("Validate: expected ErrUnsupportedFeature, got %v", err)
+	}
+}
+
+func TestZSTDDictFrame(t *testing.T) {
+	dict, err := NewZSTDDict(makeRecords(100, 40))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewSChunk(Options{Codec: ZSTD, Level: 5, TypeSize: 1, CodecParams: CodecParams{ZSTD: ZSTDParams{Dict: dict}}})
+	for i := 0; i < 3; i++ {
+		if _, err := s.AppendBuffer(makeRecords(i*10, 10)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.AppendBufferWith(makeRecords(30, 10), WithCodecParams(CodecParams{})); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("changing the dictionary: expected ErrInvalidOption, got %v", err)
+	}
+	var buf bytes.Buffer
+	if _, err := s.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// The frame decompresses with the dictionary it holds
+	f, err := OpenFrame(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content, ok := f.Metalayer(zstdDictMetalayer); !ok || !bytes.Equal(content, dict.Bytes()) {
+		t.Fatal("frame does not hold the dictionary")
+	}
+	for i := 0; i < 3; i++ {
+		got, err := f.DecompressChunk(i)
+		if err != nil || !bytes.Equal(got, makeRecords(i*10, 10)) {
+			t.Fatalf("chunk %d: %v", i, err)
+		}
+	}
+
+	// Chunks appended after reopening use the frame's dictionary, whatever
+	// the options give
+	reopened := f.ToSChunk(Options{Codec: ZSTD, Level: 5, TypeSize: 1})
+	if reopened.Options().CodecParams.ZSTD.Dict == nil {
+		t.Fatal("reopened SChunk compresses without the dictionary")
+	}
+	i, err := reopened.AppendBuffer(makeRecords(30, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunk, err := reopened.Chunk(i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h, _ := ParseHeader(chunk); !h.HasDict() {
+		t.Error("appended chunk does not use the dictionary")
+	}
+	if got, err := reopened.DecompressChunk(i); err != nil || !bytes.Equal(got, makeRecords(30, 10)) {
+		t.Errorf("appended chunk: %v", err)
+	}
+}
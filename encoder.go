@@ -0,0 +1,156 @@
+package blosc
+
+import "slices"
+
+// Allocator provides the memory an Encoder or Decoder builds chunks and
+// decodes blocks in, and the output it appends to a destination too small to
+// hold it, so that it can come from an arena, huge pages or C memory rather
+// than the Go heap. Alloc returns a buffer of length n; its contents need not
+// be zeroed. Free is passed each buffer Alloc returned, at full capacity, once
+// the Encoder or Decoder is done with it, except output buffers, which the
+// caller owns. Codec state, such as hash tables, stays on the Go heap.
+type Allocator interface {
+	Alloc(n int) []byte
+	Free(buf []byte)
+}
+
+// scratch holds the buffers and codec state that an Encoder or Decoder
+// reuses from one chunk to the next
+type scratch struct {
+	alloc  Allocator // Source of result, tmp and pre, if not the Go heap
+	result []byte    // Chunk being built
+	tmp    []byte    // Filter and decoding buffers
+	pre    []byte    // Block copy for Options.Prefilter
+	codec  codecScratch
+}
+
+// buffer returns buf resliced to n bytes, or if it is too small, a buffer
+// from s.alloc in its place, freeing buf
+func (s *scratch) buffer(buf []byte, n int) []byte {
+	if s.alloc == nil || cap(buf) >= n {
+		return scratchBuffer(buf, n)
+	}
+	if buf != nil {
+		s.alloc.Free(buf[:cap(buf)])
+	}
+	return s.alloc.Alloc(n)[:n]
+}
+
+// release frees the buffers of s to its allocator
+func (s *scratch) release() {
+	for _, buf := range [][]byte{s.result, s.tmp, s.pre} {
+		if s.alloc != nil && buf != nil {
+			s.alloc.Free(buf[:cap(buf)])
+		}
+	}
+	s.result, s.tmp, s.pre = nil, nil, nil
+}
+
+// grow returns dst with room for n more bytes, from s.alloc if dst is too
+// small; the caller owns the new buffer
+func (s *scratch) grow(dst []byte, n int) []byte {
+	if s.alloc == nil || cap(dst)-len(dst) >= n {
+		return slices.Grow(dst, n)
+	}
+	buf := s.alloc.Alloc(len(dst) + n)
+	return buf[:copy(buf, dst)]
+}
+
+// Encoder compresses chunks with fixed options, like CompressWithOptions,
+// but keeps its buffers, hash tables and codec state from one chunk to the
+// next instead of allocating them on every call. It holds on to memory for
+// the largest chunk it has built. An Encoder is not safe for concurrent use;
+// give each goroutine its own.
+type Encoder struct {
+	opts Options
+	env  filterEnv
+}
+
+// NewEncoder returns an Encoder that compresses with opts.
+func NewEncoder(opts Options) *Encoder {
+	e := &Encoder{opts: opts}
+	e.env.scratch = new(scratch)
+	return e
+}
+
+// EncodeAll compresses src into one chunk, the same chunk
+// CompressWithOptions returns, and appends it to dst.
+func (e *Encoder) EncodeAll(src, dst []byte) ([]byte, error) {
+	chunk, err := compressWithEnv(src, e.opts, &e.env)
+	if err != nil {
+		return dst, err
+	}
+	return append(e.env.scratch.grow(dst, len(chunk)), chunk...), nil
+}
+
+// SetAllocator makes e take its buffers, and the output EncodeAll appends
+// to a dst too small for it, from a, or from the Go heap if a is nil. The
+// buffers held so far are freed first.
+func (e *Encoder) SetAllocator(a Allocator) {
+	e.env.scratch.release()
+	e.env.scratch.alloc = a
+}
+
+// Close frees the buffers e holds to its Allocator. e may be used again, and
+// allocates afresh.
+func (e *Encoder) Close() error {
+	e.env.scratch.release()
+	return nil
+}
+
+// Decoder decompresses chunks like DecompressAppend, but keeps its block
+// buffers from one chunk to the next. A Decoder is not safe for concurrent
+// use; give each goroutine its own.
+type Decoder struct {
+	env filterEnv
+}
+
+// NewDecoder returns a Decoder.
+func NewDecoder() *Decoder {
+	d := &Decoder{}
+	d.env.scratch = new(scratch)
+	return d
+}
+
+// DecodeAll decompresses the chunk in src and appends the data to dst. On
+// error, dst is returned unchanged.
+func (d *Decoder) DecodeAll(src, dst []byte) ([]byte, error) {
+	return d.decodeAll(src, dst, -1)
+}
+
+// decodeAll is DecodeAll failing with ErrOutputTooLarge for chunks of more
+// than maxBytes, unless negative
+func (d *Decoder) decodeAll(src, dst []byte, maxBytes int) ([]byte, error) {
+	s := d.env.scratch
+	if s.alloc == nil {
+		return decompressBackend(dst, src, 0, maxBytes, &d.env)
+	}
+	header, _, err := checkChunk(src, 0, maxBytes)
+	if err != nil {
+		return dst, err
+	}
+	out := s.grow(dst, int(header.NBytesOrig))
+	out, err = decompressBackend(out, src, 0, maxBytes, &d.env)
+	if err != nil {
+		if cap(out) != cap(dst) {
+			s.alloc.Free(out[:cap(out)])
+		}
+		return dst, err
+	}
+	return out, nil
+}
+
+// SetAllocator makes d take its buffers, and the output DecodeAll appends
+// to a dst too small for it, from a, or from the Go heap if a is nil. The
+// buffers held so far are freed first.
+func (d *Decoder) SetAllocator(a Allocator) {
+	d.env.scratch.release()
+	d.env.scratch.alloc = a
+}
+
+// Close frees the buffers d holds to its Allocator. d may be used again,
+// and allocates afresh.
+func (d *Decoder) Close() error {
+	d.env.scratch.release()
+	return nil
+}
@@ -0,0 +1,181 @@
+package blosc
+
+import (
+	"bytes"
+	"runtime"
+	"testing"
+)
+
+func TestEncoderDecoder(t *testing.T) {
+	inputs := [][]byte{
+		makeTestData(300000),
+		makeFloatData(50000),
+		makeTestData(100),
+		randomBytes(70000),
+		make([]byte, 40000),
+		makeTestData(1 << 20),
+	}
+	scale := func(block []byte, offset int) {
+		for i := range block {
+			block[i] ^= byte(offset)
+		}
+	}
+	for _, opts := range []Options{
+		{Codec: BloscLZ, Level: 5, Shuffle: Shuffle1, TypeSize: 4},
+		{Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: 4},
+		{Codec: LZ4, Level: 5, TypeSize: 4, CodecParams: CodecParams{LZ4: LZ4Params{Acceleration: 8}}},
+		{Codec: LZ4HC, Level: 9, Shuffle: BitShuffle, TypeSize: 8, BlockSize: 1 << 16},
+		{Codec: Snappy, Level: 5, Shuffle: Shuffle1, TypeSize: 2, SkipIncompressible: true},
+		{Codec: ZLIB, Level: 6, Shuffle: Shuffle1, TypeSize: 4},
+		{Codec: ZLIB, Level: 6, TypeSize: 4, CodecParams: CodecParams{ZLIB: ZLIBParams{HuffmanOnly: true}}},
+		{Codec: ZSTD, Level: 3, TypeSize: 4, Filters: [MaxFilters]FilterStage{{Filter: FilterShuffle}, {Filter: FilterByteDelta}}, SpecialValues: true},
+		{Codec: ZSTD, Level: 9, Shuffle: Shuffle1, TypeSize: 4, CodecParams: CodecParams{ZSTD: ZSTDParams{WindowLog: 16}}, Hooks: &Hooks{Prefilter: scale}},
+		{Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: 4, LegacyFormat: true},
+	} {
+		enc := NewEncoder(opts)
+		dec := NewDecoder()
+		var chunks [][]byte
+		for _, data := range inputs {
+			chunk, err := enc.EncodeAll(data, []byte("prefix"))
+			if err != nil {
+				t.Fatalf("%s: %v", opts.Codec, err)
+			}
+			chunks = append(chunks, chunk)
+		}
+
+		// Earlier chunks are unaffected by the buffers being reused
+		for i, data := range inputs {
+			want, err := CompressWithOptions(data, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(chunks[i], append([]byte("prefix"), want...)) {
+				t.Errorf("%s: chunk %d differs from CompressWithOptions", opts.Codec, i)
+			}
+			wantData, err := Decompress(want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := dec.DecodeAll(chunks[i][len("prefix"):], []byte("prefix"))
+			if err != nil || !bytes.Equal(got, append([]byte("prefix"), wantData...)) {
+				t.Errorf("%s: chunk %d decoded differently: %v", opts.Codec, i, err)
+			}
+		}
+	}
+
+	// A bad chunk leaves dst as it was
+	if got, err := NewDecoder().DecodeAll([]byte("not a chunk"), []byte("dst")); err == nil || string(got) != "dst" {
+		t.Errorf("bad chunk: got %q, %v", got, err)
+	}
+}
+
+func TestEncoderAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("allocation counts are not meaningful under the race detector")
+	}
+	data := makeTestData(1 << 20)
+	for _, codec := range []Codec{BloscLZ, LZ4, LZ4HC, Snappy, ZSTD} {
+		opts := Options{Codec: codec, Level: 5, Shuffle: Shuffle1, TypeSize: 4}
+		enc := NewEncoder(opts)
+		dec := NewDecoder()
+		var chunk, out []byte
+		encoded := allocBytes(func() {
+			chunk, _ = enc.EncodeAll(data, chunk[:0])
+		})
+		decoded := allocBytes(func() {
+			out, _ = dec.DecodeAll(chunk, out[:0])
+		})
+		// Against several times len(data) for CompressWithOptions and Decompress
+		limit := uint64(len(data) / 64)
+		if codec == Snappy && runtime.GOARCH != "amd64" {
+			// The portable snappy encoder, used without the amd64
+			// assembly, allocates its hash tables for every block
+			encoded = 0
+		}
+		if encoded > limit || decoded > limit {
+			t.Errorf("%s: encoder allocates %d, decoder %d bytes per call", codec, encoded, decoded)
+		}
+	}
+}
+
+// allocBytes returns the bytes f allocates per call once warmed up
+func allocBytes(f func()) uint64 {
+	var before, after runtime.MemStats
+	f()
+	runtime.ReadMemStats(&before)
+	for i := 0; i < 10; i++ {
+		f()
+	}
+	runtime.ReadMemStats(&after)
+	return (after.TotalAlloc - before.TotalAlloc) / 10
+}
+
+// arena is an Allocator that tracks the buffers it has handed out
+type arena struct {
+	t     *testing.T
+	live  map[*byte]int // Capacity of each buffer handed out
+	alloc int
+}
+
+func (a *arena) Alloc(n int) []byte {
+	buf := make([]byte, n, n+64)
+	a.live[&buf[:1][0]] = cap(buf)
+	a.alloc++
+	return buf
+}
+
+func (a *arena) Free(buf []byte) {
+	p := &buf[:1][0]
+	if c, ok := a.live[p]; !ok || c != len(buf) {
+		a.t.Errorf("Free of a buffer of %d bytes not from Alloc", len(buf))
+	}
+	delete(a.live, p)
+}
+
+func TestEncoderDecoderAllocator(t *testing.T) {
+	a := &arena{t: t, live: make(map[*byte]int)}
+	enc := NewEncoder(Options{Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: 4, BlockSize: 8192, BlockChecksums: true})
+	dec := NewDecoder()
+	enc.SetAllocator(a)
+	dec.SetAllocator(a)
+
+	for _, size := range []int{1000, 100000, 300000, 50000} {
+		data := makeTestData(size)
+		chunk, err := enc.EncodeAll(data, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := dec.DecodeAll(chunk, nil)
+		if err != nil || !bytes.Equal(got, data) {
+			t.Fatalf("%d bytes: round trip: %v", size, err)
+		}
+		// Output too big for dst comes from the allocator, for the caller
+		// to free
+		for _, out := range [][]byte{chunk, got} {
+			if _, ok := a.live[&out[0]]; !ok {
+				t.Errorf("%d bytes: output not from the allocator", size)
+			}
+			a.Free(out[:cap(out)])
+		}
+		if _, err := dec.DecodeAll(chunk[:len(chunk)-1], nil); err == nil {
+			t.Errorf("%d bytes: truncated chunk decoded", size)
+		}
+	}
+	if a.alloc == 0 {
+		t.Fatal("allocator unused")
+	}
+
+	// Output that fits dst stays in it
+	data := makeTestData(100000)
+	dst := make([]byte, 0, 2*len(data))
+	chunk, _ := enc.EncodeAll(data, dst)
+	if &chunk[0] != &dst[:1][0] {
+		t.Error("EncodeAll output left dst")
+	}
+
+	enc.Close()
+	dec.Close()
+	if len(a.live) != 0 {
+		t.Errorf("%d buffers not freed after Close", len(a.live))
+	}
+}
@@ -0,0 +1,107 @@
+package blosc
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+)
+
+// KeyProvider looks up encryption keys by ID. Frames record only the key ID,
+// so keys can be kept in a key management service rather than with the data.
+type KeyProvider interface {
+	Key(id string) ([]byte, error)
+}
+
+// StaticKeys is a KeyProvider holding keys in memory.
+type StaticKeys map[string][]byte
+
+// Key returns the key stored under id.
+func (k StaticKeys) Key(id string) ([]byte, error) {
+	key, ok := k[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: no key %q", ErrInvalidKey, id)
+	}
+	return key, nil
+}
+
+// frameIDSize is the size of the random ID that binds sealed chunks to the
+// SChunk or frame holding them
+const frameIDSize = 16
+
+// chunkCipher encrypts chunks with AES-GCM. Each stored chunk is a random
+// nonce followed by the sealed chunk and its authentication tag; the frame
+// ID, the chunk's index and the key ID are authenticated along with it, so
+// that chunks cannot be reordered or moved between frames undetected.
+type chunkCipher struct {
+	keyID   string
+	frameID []byte
+	aead    cipher.AEAD
+}
+
+// newFrameID returns a new random frame ID
+func newFrameID() ([]byte, error) {
+	id := make([]byte, frameIDSize)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	return id, nil
+}
+
+// newChunkCipher looks up the key for keyID, which must be 16, 24 or 32
+// bytes long to select AES-128, AES-192 or AES-256, for the chunks of the
+// frame with the given ID
+func newChunkCipher(keyID string, frameID []byte, keys KeyProvider) (*chunkCipher, error) {
+	if keys == nil {
+		return nil, fmt.Errorf("%w: no key provider for key %q", ErrInvalidKey, keyID)
+	}
+	key, err := keys.Key(keyID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: key %q: %w", ErrInvalidKey, keyID, err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: key %q: %w", ErrInvalidKey, keyID, err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("%w: key %q: %w", ErrInvalidKey, keyID, err)
+	}
+	if len(frameID) != frameIDSize {
+		return nil, fmt.Errorf("%w: frame ID of %d bytes", ErrInvalidFrame, len(frameID))
+	}
+	return &chunkCipher{keyID: keyID, frameID: frameID, aead: aead}, nil
+}
+
+// additionalData returns what is authenticated along with chunk i: the frame
+// ID, the index and the key ID
+func (c *chunkCipher) additionalData(i int) []byte {
+	ad := make([]byte, 0, frameIDSize+8+len(c.keyID))
+	ad = append(ad, c.frameID...)
+	ad = binary.LittleEndian.AppendUint64(ad, uint64(i))
+	return append(ad, c.keyID...)
+}
+
+// seal encrypts chunk i into a new slice
+func (c *chunkCipher) seal(chunk []byte, i int) ([]byte, error) {
+	n := c.aead.NonceSize()
+	out := make([]byte, n, n+len(chunk)+c.aead.Overhead())
+	if _, err := rand.Read(out); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(out, out, chunk, c.additionalData(i)), nil
+}
+
+// open decrypts and authenticates sealed chunk i into a new slice
+func (c *chunkCipher) open(sealed []byte, i int) ([]byte, error) {
+	n := c.aead.NonceSize()
+	if len(sealed) < n+c.aead.Overhead() {
+		return nil, fmt.Errorf("%w: chunk %d: %d bytes is too short", ErrAuthFailed, i, len(sealed))
+	}
+	chunk, err := c.aead.Open(nil, sealed[:n], sealed[n:], c.additionalData(i))
+	if err != nil {
+		return nil, fmt.Errorf("%w: chunk %d", ErrAuthFailed, i)
+	}
+	return chunk, nil
+}
@@ -0,0 +1,234 @@
+package blosc
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+var testKeys = StaticKeys{
+	"k1": bytes.Repeat([]byte{1}, 32),
+	"k2": bytes.Repeat([]byte{2}, 16),
+}
+
+func TestSChunkEncryption(t *testing.T) {
+	sc, want := makeSChunk(t, 2)
+	plain := sc.CBytes()
+	if err := sc.SetEncryption("k1", testKeys); err != nil {
+		t.Fatalf("SetEncryption failed: %v", err)
+	}
+	if sc.KeyID() != "k1" {
+		t.Errorf("KeyID = %q", sc.KeyID())
+	}
+	// Each chunk gains a nonce and a tag
+	if sc.CBytes() != plain+2*(12+16) {
+		t.Errorf("CBytes = %d, want %d", sc.CBytes(), plain+2*(12+16))
+	}
+
+	// Chunks appended later are encrypted too
+	data := makeTestData(5000)
+	if _, err := sc.AppendBuffer(data); err != nil {
+		t.Fatal(err)
+	}
+	want = append(want, data)
+	for i := range want {
+		got, err := sc.DecompressChunk(i)
+		if err != nil {
+			t.Fatalf("chunk %d: %v", i, err)
+		}
+		if !bytes.Equal(got, want[i]) {
+			t.Errorf("chunk %d differs", i)
+		}
+	}
+
+	// Stored chunks are not readable Blosc chunks
+	if _, err := ParseHeader(sc.chunks[0].data); err == nil && bytes.Contains(sc.chunks[0].data, want[0][:64]) {
+		t.Error("stored chunk is not encrypted")
+	}
+
+	// Switching keys and removing encryption re-encrypt existing chunks
+	if err := sc.SetEncryption("k2", testKeys); err != nil {
+		t.Fatal(err)
+	}
+	if err := sc.SetEncryption("", nil); err != nil {
+		t.Fatal(err)
+	}
+	if sc.CBytes() != plain+int64(len(mustChunk(t, sc, 2))) || sc.KeyID() != "" {
+		t.Errorf("CBytes = %d after removing encryption", sc.CBytes())
+	}
+	got, err := sc.DecompressChunk(0)
+	if err != nil || !bytes.Equal(got, want[0]) {
+		t.Errorf("chunk 0 after removing encryption: %v", err)
+	}
+}
+
+func mustChunk(t *testing.T, sc *SChunk, i int) []byte {
+	t.Helper()
+	chunk, err := sc.Chunk(i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return chunk
+}
+
+func TestFrameEncryption(t *testing.T) {
+	sc, want := makeSChunk(t, 3)
+	if err := sc.SetEncryption("k1", testKeys); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := sc.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := OpenFrame(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.KeyID() != "k1" {
+		t.Errorf("KeyID = %q", f.KeyID())
+	}
+	if _, err := f.DecompressChunk(0); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("expected ErrInvalidKey without keys, got %v", err)
+	}
+	if err := f.SetKeys(StaticKeys{}); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("expected ErrInvalidKey for a missing key, got %v", err)
+	}
+	if err := f.SetKeys(testKeys); err != nil {
+		t.Fatal(err)
+	}
+	for i := range want {
+		got, err := f.DecompressChunk(i)
+		if err != nil {
+			t.Fatalf("chunk %d: %v", i, err)
+		}
+		if !bytes.Equal(got, want[i]) {
+			t.Errorf("chunk %d differs", i)
+		}
+	}
+
+	// A wrong key under the same ID fails authentication
+	wrong, _ := OpenFrame(buf.Bytes())
+	if err := wrong.SetKeys(StaticKeys{"k1": bytes.Repeat([]byte{9}, 32)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wrong.Chunk(0); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("expected ErrAuthFailed for a wrong key, got %v", err)
+	}
+
+	// So does a modified chunk
+	data := bytes.Clone(buf.Bytes())
+	data[frameHeaderSize+20] ^= 1
+	tampered, _ := OpenFrame(data)
+	if err := tampered.SetKeys(testKeys); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tampered.Chunk(0); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("expected ErrAuthFailed for a modified chunk, got %v", err)
+	}
+	if _, err := tampered.Chunk(1); err != nil {
+		t.Errorf("unmodified chunk: %v", err)
+	}
+}
+
+func TestEncryptionBindsChunks(t *testing.T) {
+	sc, want := makeSChunk(t, 3)
+	if err := sc.SetEncryption("k1", testKeys); err != nil {
+		t.Fatal(err)
+	}
+	frame := mustFrameBytes(t, sc)
+
+	// Chunks swapped in the frame index fail authentication
+	swapped, err := OpenFrame(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := swapped.SetKeys(testKeys); err != nil {
+		t.Fatal(err)
+	}
+	swapped.chunks[0], swapped.chunks[1] = swapped.chunks[1], swapped.chunks[0]
+	for i := 0; i < 2; i++ {
+		if _, err := swapped.Chunk(i); !errors.Is(err, ErrAuthFailed) {
+			t.Errorf("swapped chunk %d: expected ErrAuthFailed, got %v", i, err)
+		}
+	}
+
+	// So does a chunk taken from another SChunk under the same key
+	other, _ := makeSChunk(t, 3)
+	if err := other.SetEncryption("k1", testKeys); err != nil {
+		t.Fatal(err)
+	}
+	other.chunks[0] = sc.chunks[0]
+	if _, err := other.Chunk(0); !errors.Is(err, ErrAuthFailed) {
+		t.Errorf("chunk of another SChunk: expected ErrAuthFailed, got %v", err)
+	}
+
+	// Inserting and deleting seal the moved chunks for their new index
+	inserted := makeTestData(3000)
+	chunk, err := Compress(inserted, LZ4, 5, Shuffle1, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sc.InsertChunk(1, chunk); err != nil {
+		t.Fatal(err)
+	}
+	want = append([][]byte{want[0], inserted}, want[1:]...)
+	checkChunks(t, sc, want)
+	if err := sc.DeleteChunk(0); err != nil {
+		t.Fatal(err)
+	}
+	want = want[1:]
+	checkChunks(t, sc, want)
+	f, err := OpenFrame(mustFrameBytes(t, sc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.SetKeys(testKeys); err != nil {
+		t.Fatal(err)
+	}
+	checkChunks(t, f, want)
+
+	// Copies into an SChunk with chunks of its own are sealed for it
+	dst, _ := makeSChunk(t, 1)
+	if err := dst.SetEncryption("k1", testKeys); err != nil {
+		t.Fatal(err)
+	}
+	if err := sc.CopyTo(dst); err != nil {
+		t.Fatal(err)
+	}
+	checkChunks(t, dst, append([][]byte{makeTestData(10000)}, want...))
+}
+
+func TestEncryptionErrors(t *testing.T) {
+	sc := NewSChunk(Options{Codec: LZ4})
+	if err := sc.SetEncryption("k3", testKeys); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("expected ErrInvalidKey for a missing key, got %v", err)
+	}
+	if err := sc.SetEncryption("bad", StaticKeys{"bad": make([]byte, 7)}); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("expected ErrInvalidKey for a 7 byte key, got %v", err)
+	}
+	if sc.KeyID() != "" {
+		t.Errorf("failed SetEncryption changed the key to %q", sc.KeyID())
+	}
+}
+
+func TestNDArrayEncrypted(t *testing.T) {
+	arr, err := NewNDArray([]int{20, 20}, []int{10, 10}, []int{5, 5}, "<f4", Options{Codec: LZ4, Level: 5, Shuffle: Shuffle1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := arr.SChunk().SetEncryption("k2", testKeys); err != nil {
+		t.Fatal(err)
+	}
+	data := makeFloatData(400)
+	if err := arr.Set([]int{0, 0}, []int{20, 20}, data); err != nil {
+		t.Fatal(err)
+	}
+	got, err := arr.Slice([]int{0, 0}, []int{20, 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("encrypted array does not round trip")
+	}
+}
@@ -0,0 +1,64 @@
+package blosc
+
+import "math"
+
+// Entropy probe parameters: up to entropyPieces pieces of entropyPieceSize
+// bytes, spread over the stream, are sampled
+const (
+	entropyPieces    = 4
+	entropyPieceSize = 1 << 10
+
+	// Sampled bytes this close to 8 bits of entropy each...
+	entropyThreshold = 7.9
+	// ...that repeat fewer than one in this many 4-byte sequences leave
+	// nothing for an entropy coder or LZ matches to gain
+	entropyRepeatRatio = 100
+)
+
+// incompressible reports whether stream is clearly not worth compressing,
+// judging by a sample of it: its bytes are close to uniformly distributed
+// and hardly ever repeat a 4-byte sequence, the shortest LZ match. Streams
+// too short to sample reliably are never judged incompressible.
+func incompressible(stream []byte) bool {
+	if len(stream) < entropyPieces*entropyPieceSize {
+		return false
+	}
+	var counts [256]int
+	var seen [1 << 12]int32 // Sample position + 1 of each hashed 4-byte sequence
+	var sample [entropyPieces * entropyPieceSize]byte
+	step := (len(stream) - entropyPieceSize) / (entropyPieces - 1)
+	for i := 0; i < entropyPieces; i++ {
+		copy(sample[i*entropyPieceSize:], stream[i*step:i*step+entropyPieceSize])
+	}
+
+	repeats := 0
+	for i, c := range sample {
+		counts[c]++
+		if i+4 > len(sample) {
+			continue
+		}
+		seq := uint32(sample[i]) | uint32(sample[i+1])<<8 | uint32(sample[i+2])<<16 | uint32(sample[i+3])<<24
+		h := seq * 2654435761 >> 20
+		if prev := seen[h]; prev > 0 {
+			p := prev - 1
+			if sample[p] == sample[i] && sample[p+1] == sample[i+1] && sample[p+2] == sample[i+2] && sample[p+3] == sample[i+3] {
+				repeats++
+			}
+		}
+		seen[h] = int32(i + 1)
+	}
+	if repeats*entropyRepeatRatio > len(sample) {
+		return false
+	}
+
+	// Shannon entropy of the byte distribution, in bits per byte
+	n := float64(len(sample))
+	entropy := 0.0
+	for _, c := range counts {
+		if c > 0 {
+			p := float64(c) / n
+			entropy -= p * math.Log2(p)
+		}
+	}
+	return entropy >= entropyThreshold
+}
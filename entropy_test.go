@@ -0,0 +1,103 @@
+package blosc
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+// countingCodec counts the Compress calls of the codec it wraps
+type countingCodec struct {
+	CodecInterface
+	calls int
+}
+
+func (c *countingCodec) Compress(data []byte, level int) ([]byte, error) {
+	c.calls++
+	return c.CodecInterface.Compress(data, level)
+}
+
+func TestIncompressible(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	noise := make([]byte, 64<<10)
+	rng.Read(noise)
+	compressed, err := Compress(makeFloatData(1<<16), ZSTD, 9, NoShuffle, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		stream []byte
+		want   bool
+	}{
+		{"noise", noise, true},
+		{"zstd output", compressed[HeaderSize+64:], true},
+		{"short noise", noise[:1000], false},
+		{"floats", makeFloatData(16 << 10), false},
+		{"text", makeTestData(64 << 10), false},
+		{"zeros", make([]byte, 64<<10), false},
+		{"repeated noise", bytes.Repeat(noise[:500], 100), false},
+	}
+	for _, tt := range tests {
+		if got := incompressible(tt.stream); got != tt.want {
+			t.Errorf("%s: incompressible = %v", tt.name, got)
+		}
+	}
+}
+
+func TestSkipIncompressible(t *testing.T) {
+	counter := &countingCodec{CodecInterface: codecs[ZSTD]}
+	codecs[ZSTD] = counter
+	defer func() { codecs[ZSTD] = counter.CodecInterface }()
+
+	rng := rand.New(rand.NewSource(2))
+	noise := make([]byte, 1<<20)
+	rng.Read(noise)
+	mixed := append(makeFloatData(128<<10), noise[:512<<10]...)
+
+	for _, tt := range []struct {
+		name string
+		data []byte
+	}{
+		{"noise", noise},
+		{"mixed", mixed},
+		{"floats", makeFloatData(256 << 10)},
+	} {
+		opts := Options{Codec: ZSTD, Level: 5, Shuffle: Shuffle1, TypeSize: 4, BlockSize: 64 << 10}
+		counter.calls = 0
+		want, err := CompressWithOptions(tt.data, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		full := counter.calls
+
+		opts.SkipIncompressible = true
+		counter.calls = 0
+		chunk, err := CompressWithOptions(tt.data, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		skipped := full - counter.calls
+		got, err := Decompress(chunk)
+		if err != nil || !bytes.Equal(got, tt.data) {
+			t.Fatalf("%s: round trip: %v", tt.name, err)
+		}
+		streamed, err := CompressFrom(bytes.NewReader(tt.data), int64(len(tt.data)), opts)
+		if err != nil || !bytes.Equal(streamed, chunk) {
+			t.Errorf("%s: CompressFrom differs: %v", tt.name, err)
+		}
+
+		switch tt.name {
+		case "noise":
+			if counter.calls != 0 || !bytes.Equal(chunk, want) {
+				t.Errorf("noise: %d codec calls, chunk equal %v", counter.calls, bytes.Equal(chunk, want))
+			}
+		default:
+			// The noisy low mantissa bytes of floats are skipped too
+			if skipped == 0 || len(chunk) > len(want)+len(want)/100 {
+				t.Errorf("%s: skipped %d of %d streams, %d bytes against %d", tt.name, skipped, full, len(chunk), len(want))
+			}
+		}
+	}
+}
@@ -134,7 +134,7 @@ func Example_getInfo() {
 	}
 
 	fmt.Printf("Version: %d\n", header.Version)
-	fmt.Printf("Codec: %s\n", blosc.Codec(header.VersionLZ))
+	fmt.Printf("Codec: %s\n", header.Codec())
 	fmt.Printf("Original size: %d bytes\n", header.NBytesOrig)
 	fmt.Printf("Type size: %d bytes\n", header.TypeSize)
 	fmt.Printf("Has shuffle: %v\n", header.HasShuffle())
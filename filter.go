@@ -0,0 +1,619 @@
+package blosc
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Filter identifies a stage of the Blosc2 filter pipeline. Values match the
+// filter IDs of c-blosc2.
+type Filter uint8
+
+const (
+	FilterNone       Filter = 0  // Empty pipeline slot
+	FilterShuffle    Filter = 1  // Byte shuffle; Meta adds extra rounds
+	FilterBitShuffle Filter = 2  // Bit shuffle
+	FilterDelta      Filter = 3  // Delta against the first block (not supported)
+	FilterTruncPrec  Filter = 4  // Float precision truncation (decoding only)
+	FilterNDCell     Filter = 32 // NDArray cells of Meta elements per side
+	FilterByteDelta  Filter = 35 // Per-byte-plane delta; Meta overrides the element size
+
+	// Integer filters of this package, in the c-blosc2 user filter range
+	FilterZigZag  Filter = 160 // Zigzag signed ints; Meta 1 zigzags deltas between elements
+	FilterBitPack Filter = 161 // Pack unsigned ints to the bit width of each block's range
+
+	// FilterFloatQuantize rounds floats within Options.ErrorBound, and is
+	// lossy. Compression sets Meta to record the largest error introduced,
+	// which Header.QuantizeError returns.
+	FilterFloatQuantize Filter = 162
+
+	// FilterTranspose stores each block, a matrix of Meta rows, column by
+	// column; set Options.BlockSize to Meta whole rows, as WithTranspose does
+	FilterTranspose Filter = 163
+
+	// FilterChunkDelta marks a chunk that holds the XOR of its data with the
+	// chunk before it in its SChunk or frame, as SChunk.SetChunkDelta writes
+	// them. It is not set in Options.Filters.
+	FilterChunkDelta Filter = 164
+)
+
+// MaxFilters is the number of filter slots in a Blosc2 chunk header.
+const MaxFilters = 6
+
+// FilterStage is one slot of the filter pipeline.
+type FilterStage struct {
+	Filter Filter
+	Meta   uint8 // Filter parameter, stored in the chunk header
+}
+
+// String returns the filter name.
+func (f Filter) String() string {
+	switch f {
+	case FilterNone:
+		return "none"
+	case FilterShuffle:
+		return "shuffle"
+	case FilterBitShuffle:
+		return "bitshuffle"
+	case FilterDelta:
+		return "delta"
+	case FilterTruncPrec:
+		return "trunc_prec"
+	case FilterNDCell:
+		return "ndcell"
+	case FilterByteDelta:
+		return "bytedelta"
+	case FilterZigZag:
+		return "zigzag"
+	case FilterBitPack:
+		return "bitpack"
+	case FilterFloatQuantize:
+		return "quantize"
+	case FilterTranspose:
+		return "transpose"
+	case FilterChunkDelta:
+		return "chunkdelta"
+	default:
+		return fmt.Sprintf("unknown(%d)", f)
+	}
+}
+
+// filterEnv carries what filters need to know beyond the block itself, as
+// c-blosc2 filters find it in the super-chunk
+type filterEnv struct {
+	blockShape []int                               // NDArray block shape, for FilterNDCell
+	postfilter func(block []byte, offset int)      // Run on each decoded block
+	progress   func(done, total int64)             // Told of each decoded block
+	scratch    *scratch                            // Buffers of an Encoder or Decoder
+	quantize   *quantizer                          // Bound and largest error of FilterFloatQuantize in a chunk
+	quantError *float64                            // Receives the largest FilterFloatQuantize error of a chunk
+	chunkDelta bool                                // The caller handles FilterChunkDelta
+	dict       *ZSTDDict                           // Dictionary of chunks with the dict flag
+	skippable  func(block, id int, content []byte) // Told of zstd skippable frames
+}
+
+// buffers returns the scratch space to reuse, or nil to allocate afresh
+func (env *filterEnv) buffers() *scratch {
+	if env == nil {
+		return nil
+	}
+	return env.scratch
+}
+
+// reportProgress tells the caller, if it asked, that done of total bytes
+// are decoded
+func (env *filterEnv) reportProgress(done, total int) {
+	if env != nil {
+		reportProgress(env.progress, done, total)
+	}
+}
+
+// filterFunc transforms src into dst, which has the same length
+type filterFunc func(dst, src []byte, typeSize int, meta uint8, env *filterEnv) error
+
+// filterImpl holds the two directions of a filter. A nil forward function
+// means the filter can be decoded but not applied.
+type filterImpl struct {
+	forward  filterFunc
+	backward filterFunc
+}
+
+// filters maps filter IDs to implementations
+var filters = map[Filter]filterImpl{
+	FilterShuffle: {
+		forward: func(dst, src []byte, typeSize int, meta uint8, env *filterEnv) error {
+			shuffleBytesTo(dst, src, typeSize)
+			return nil
+		},
+		backward: func(dst, src []byte, typeSize int, meta uint8, env *filterEnv) error {
+			unshuffleBytesTo(dst, src, typeSize)
+			return nil
+		},
+	},
+	FilterBitShuffle: {
+		forward: func(dst, src []byte, typeSize int, meta uint8, env *filterEnv) error {
+			bitShuffleTo(dst, src, typeSize)
+			return nil
+		},
+		backward: func(dst, src []byte, typeSize int, meta uint8, env *filterEnv) error {
+			bitUnshuffleTo(dst, src, typeSize)
+			return nil
+		},
+	},
+	// Truncation only zeroes low mantissa bits, so decoding is a copy
+	FilterTruncPrec: {
+		backward: func(dst, src []byte, typeSize int, meta uint8, env *filterEnv) error {
+			copy(dst, src)
+			return nil
+		},
+	},
+	FilterNDCell: {
+		forward: func(dst, src []byte, typeSize int, meta uint8, env *filterEnv) error {
+			return ndcell(dst, src, typeSize, meta, env, true)
+		},
+		backward: func(dst, src []byte, typeSize int, meta uint8, env *filterEnv) error {
+			return ndcell(dst, src, typeSize, meta, env, false)
+		},
+	},
+	FilterByteDelta: {
+		forward: func(dst, src []byte, typeSize int, meta uint8, env *filterEnv) error {
+			byteDelta(dst, src, typeSize, meta, true)
+			return nil
+		},
+		backward: func(dst, src []byte, typeSize int, meta uint8, env *filterEnv) error {
+			byteDelta(dst, src, typeSize, meta, false)
+			return nil
+		},
+	},
+	FilterZigZag: {
+		forward: func(dst, src []byte, typeSize int, meta uint8, env *filterEnv) error {
+			return zigZag(dst, src, typeSize, meta, true)
+		},
+		backward: func(dst, src []byte, typeSize int, meta uint8, env *filterEnv) error {
+			return zigZag(dst, src, typeSize, meta, false)
+		},
+	},
+	FilterBitPack: {
+		forward: func(dst, src []byte, typeSize int, meta uint8, env *filterEnv) error {
+			return bitPack(dst, src, typeSize)
+		},
+		backward: func(dst, src []byte, typeSize int, meta uint8, env *filterEnv) error {
+			return bitUnpack(dst, src, typeSize)
+		},
+	},
+	FilterTranspose: {
+		forward: func(dst, src []byte, typeSize int, meta uint8, env *filterEnv) error {
+			return transpose(dst, src, typeSize, meta, true)
+		},
+		backward: func(dst, src []byte, typeSize int, meta uint8, env *filterEnv) error {
+			return transpose(dst, src, typeSize, meta, false)
+		},
+	},
+	// Quantized floats are stored as they are, so decoding is a copy
+	FilterFloatQuantize: {
+		forward: func(dst, src []byte, typeSize int, meta uint8, env *filterEnv) error {
+			return floatQuantize(dst, src, typeSize, env)
+		},
+		backward: func(dst, src []byte, typeSize int, meta uint8, env *filterEnv) error {
+			copy(dst, src)
+			return nil
+		},
+	},
+	FilterChunkDelta: {
+		forward:  chunkDeltaStage,
+		backward: chunkDeltaStage,
+	},
+}
+
+// pipeline is the sequence of filters applied to each block, in forward
+// order, with repeated shuffle rounds expanded
+type pipeline []FilterStage
+
+// newPipeline builds a pipeline from header or option filter slots. It fails
+// for unknown filters, and for filters that cannot be applied if forward is set.
+func newPipeline(slots [MaxFilters]FilterStage, forward bool) (pipeline, error) {
+	var p pipeline
+	for _, s := range slots {
+		if s.Filter == FilterNone {
+			continue
+		}
+		impl, ok := filters[s.Filter]
+		if !ok || forward && impl.forward == nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidFilter, s.Filter)
+		}
+		// c-blosc2 runs shuffle Meta extra times
+		rounds := 1
+		if s.Filter == FilterShuffle {
+			rounds += int(s.Meta)
+		}
+		for i := 0; i < rounds; i++ {
+			p = append(p, s)
+		}
+	}
+	return p, nil
+}
+
+// shufflePipeline returns the pipeline of a Blosc1 shuffle mode
+func shufflePipeline(s Shuffle, typeSize int) pipeline {
+	switch {
+	case s == Shuffle1 && typeSize > 1:
+		return pipeline{{Filter: FilterShuffle}}
+	case s == BitShuffle:
+		return pipeline{{Filter: FilterBitShuffle}}
+	default:
+		return nil
+	}
+}
+
+// shuffleSlots returns the extended header filter slots of a Blosc1 shuffle
+// mode, with the shuffle in the last slot, as c-blosc2 writes it
+func shuffleSlots(s Shuffle, typeSize int) [MaxFilters]FilterStage {
+	var slots [MaxFilters]FilterStage
+	if p := shufflePipeline(s, typeSize); len(p) > 0 {
+		slots[MaxFilters-1] = p[0]
+	}
+	return slots
+}
+
+// forward runs the pipeline over block, using a and b as scratch space of
+// at least len(block) bytes, and returns the filtered block
+func (p pipeline) forward(block, a, b []byte, typeSize int, env *filterEnv) ([]byte, error) {
+	src := block
+	for _, s := range p {
+		dst := a[:len(block)]
+		if &src[0] == &a[0] {
+			dst = b[:len(block)]
+		}
+		if err := filters[s.Filter].forward(dst, src, typeSize, s.Meta, env); err != nil {
+			return nil, err
+		}
+		src = dst
+	}
+	return src, nil
+}
+
+// backward undoes the pipeline, writing the result to dst. block is
+// overwritten, and scratch must hold at least len(block) bytes.
+func (p pipeline) backward(dst, block, scratch []byte, typeSize int, env *filterEnv) error {
+	if len(p) == 0 {
+		copy(dst, block)
+		return nil
+	}
+	src := block
+	scratch = scratch[:len(block)]
+	for i := len(p) - 1; i >= 0; i-- {
+		out := dst
+		if i > 0 {
+			out = scratch
+			if &src[0] == &scratch[0] {
+				out = block
+			}
+		}
+		s := p[i]
+		if err := filters[s.Filter].backward(out, src, typeSize, s.Meta, env); err != nil {
+			return err
+		}
+		src = out
+	}
+	return nil
+}
+
+// ndcell implements the c-blosc2 NDCELL filter. The block, a C-order array
+// of env.blockShape elements, is cut into cells of meta elements per side
+// (smaller at the edges); forward writes the cells one after another in C
+// order, each cell's elements in C order, and backward restores the block.
+func ndcell(dst, src []byte, typeSize int, meta uint8, env *filterEnv, forward bool) error {
+	if env == nil || len(env.blockShape) == 0 {
+		return fmt.Errorf("%w: %s needs the block shape of an NDArray", ErrInvalidFilter, FilterNDCell)
+	}
+	shape := env.blockShape
+	cell := int(meta)
+	if cell == 0 {
+		return fmt.Errorf("%w: %s cell size 0", ErrInvalidFilter, FilterNDCell)
+	}
+	if prod(shape)*typeSize != len(src) {
+		return fmt.Errorf("%w: %s block of %d bytes does not match block shape %v", ErrInvalidFilter, FilterNDCell, len(src), shape)
+	}
+
+	ndim := len(shape)
+	last := ndim - 1
+	cells := make([]int, ndim)
+	for d := range shape {
+		cells[d] = (shape[d] + cell - 1) / cell
+	}
+	pos := make([]int, ndim)
+	zero := make([]int, ndim)
+	lo := make([]int, ndim)
+	hi := make([]int, ndim)
+	row := make([]int, ndim)
+
+	out := 0
+	for {
+		for d := range shape {
+			lo[d] = pos[d] * cell
+			hi[d] = min(lo[d]+cell, shape[d])
+		}
+		n := (hi[last] - lo[last]) * typeSize
+		copy(row, lo)
+		hi[last] = lo[last] + 1 // Rows run along the last dimension
+		for {
+			in := cIndex(row, shape) * typeSize
+			if forward {
+				copy(dst[out:out+n], src[in:in+n])
+			} else {
+				copy(dst[in:in+n], src[out:out+n])
+			}
+			out += n
+			if !nextIndex(row, lo, hi) {
+				break
+			}
+		}
+		if !nextIndex(pos, zero, cells) {
+			return nil
+		}
+	}
+}
+
+// byteDelta implements the c-blosc2 bytedelta filter, meant to follow a
+// shuffle. The block is read as one stream per byte of the element, and each
+// byte is replaced by its difference from the previous byte of its stream, so
+// slowly varying values leave long runs of small numbers. Bytes past the last
+// whole element are copied. backward restores the block with running sums.
+func byteDelta(dst, src []byte, typeSize int, meta uint8, forward bool) {
+	if meta != 0 {
+		typeSize = int(meta)
+	}
+	typeSize = max(typeSize, 1)
+	n := len(src) / typeSize
+	for s := 0; s < typeSize; s++ {
+		in := src[s*n : (s+1)*n]
+		out := dst[s*n : (s+1)*n]
+		var prev byte
+		for i, v := range in {
+			if forward {
+				out[i] = v - prev
+				prev = v
+			} else {
+				prev += v
+				out[i] = prev
+			}
+		}
+	}
+	copy(dst[n*typeSize:], src[n*typeSize:])
+}
+
+// intWidth checks that f can work on elements of typeSize bytes, which it
+// reads as little-endian unsigned integers
+func intWidth(f Filter, typeSize int) error {
+	switch typeSize {
+	case 1, 2, 4, 8:
+		return nil
+	}
+	return fmt.Errorf("%w: %s needs a type size of 1, 2, 4 or 8, not %d", ErrInvalidFilter, f, typeSize)
+}
+
+// loadUint reads the little-endian integer of typeSize bytes at the start of b
+func loadUint(b []byte, typeSize int) uint64 {
+	switch typeSize {
+	case 1:
+		return uint64(b[0])
+	case 2:
+		return uint64(binary.LittleEndian.Uint16(b))
+	case 4:
+		return uint64(binary.LittleEndian.Uint32(b))
+	default:
+		return binary.LittleEndian.Uint64(b)
+	}
+}
+
+// storeUint writes v as a little-endian integer of typeSize bytes
+func storeUint(b []byte, typeSize int, v uint64) {
+	switch typeSize {
+	case 1:
+		b[0] = byte(v)
+	case 2:
+		binary.LittleEndian.PutUint16(b, uint16(v))
+	case 4:
+		binary.LittleEndian.PutUint32(b, uint32(v))
+	default:
+		binary.LittleEndian.PutUint64(b, v)
+	}
+}
+
+// zigZag implements FilterZigZag. Each element, a signed integer of
+// typeSize bytes, is mapped to an unsigned one that is small when its
+// magnitude is: 0, -1, 1, -2 become 0, 1, 2, 3. With meta 1 the difference
+// from the previous element is mapped instead, so sorted IDs and timestamps
+// become runs of small numbers. Bytes past the last whole element are copied.
+func zigZag(dst, src []byte, typeSize int, meta uint8, forward bool) error {
+	if err := intWidth(FilterZigZag, typeSize); err != nil {
+		return err
+	}
+	if meta > 1 {
+		return fmt.Errorf("%w: %s meta %d is not 0 or 1", ErrInvalidFilter, FilterZigZag, meta)
+	}
+	bits := uint(8 * typeSize)
+	mask := uint64(1)<<(bits-1)<<1 - 1
+	var prev uint64
+	n := len(src) / typeSize
+	for i := 0; i < n; i++ {
+		v := loadUint(src[i*typeSize:], typeSize)
+		var out uint64
+		if forward {
+			d := (v - prev) & mask
+			if meta == 1 {
+				prev = v
+			}
+			signed := int64(d<<(64-bits)) >> (64 - bits)
+			out = uint64(signed<<1^signed>>63) & mask
+		} else {
+			out = (uint64(int64(v>>1)^-int64(v&1)) + prev) & mask
+			if meta == 1 {
+				prev = out
+			}
+		}
+		storeUint(dst[i*typeSize:], typeSize, out)
+	}
+	copy(dst[n*typeSize:], src[n*typeSize:])
+	return nil
+}
+
+// bitPack implements FilterBitPack. The elements of the block, unsigned
+// integers of typeSize bytes, are stored as their difference from the
+// smallest, in just as many bits as the largest difference needs. The
+// block starts with that bit width in one byte and the smallest element,
+// followed by the packed differences, least significant bits first, and any
+// bytes past the last whole element; the rest is zeroed. Signed integers
+// should go through FilterZigZag first. A block whose range is too wide to
+// pack into fewer bytes than it holds cannot be filtered.
+func bitPack(dst, src []byte, typeSize int) error {
+	if err := intWidth(FilterBitPack, typeSize); err != nil {
+		return err
+	}
+	n := len(src) / typeSize
+	if n == 0 {
+		copy(dst, src)
+		return nil
+	}
+	lo, hi := loadUint(src, typeSize), loadUint(src, typeSize)
+	for i := 1; i < n; i++ {
+		v := loadUint(src[i*typeSize:], typeSize)
+		if v < lo {
+			lo = v
+		}
+		if v > hi {
+			hi = v
+		}
+	}
+	width := 0
+	for r := hi - lo; r != 0; r >>= 1 {
+		width++
+	}
+	tail := src[n*typeSize:]
+	packed := 1 + typeSize + (n*width+7)/8
+	if packed+len(tail) > len(src) {
+		return fmt.Errorf("%w: %s block values span %d bits of %d", ErrInvalidFilter, FilterBitPack, width, 8*typeSize)
+	}
+
+	dst[0] = byte(width)
+	storeUint(dst[1:], typeSize, lo)
+	w := bitWriter{buf: dst[1+typeSize : packed]}
+	for i := 0; i < n; i++ {
+		w.put(loadUint(src[i*typeSize:], typeSize)-lo, width)
+	}
+	w.flush()
+	clear(dst[packed+copy(dst[packed:], tail):])
+	return nil
+}
+
+// bitUnpack undoes bitPack
+func bitUnpack(dst, src []byte, typeSize int) error {
+	if err := intWidth(FilterBitPack, typeSize); err != nil {
+		return err
+	}
+	n := len(src) / typeSize
+	if n == 0 {
+		copy(dst, src)
+		return nil
+	}
+	width := int(src[0])
+	packed := 1 + typeSize + (n*width+7)/8
+	tail := len(src) - n*typeSize
+	if width > 8*typeSize || packed+tail > len(src) {
+		return fmt.Errorf("%w: %s block of %d bytes with bit width %d", ErrInvalidFilter, FilterBitPack, len(src), width)
+	}
+	lo := loadUint(src[1:], typeSize)
+	r := bitReader{buf: src[1+typeSize : packed]}
+	for i := 0; i < n; i++ {
+		storeUint(dst[i*typeSize:], typeSize, lo+r.get(width))
+	}
+	copy(dst[n*typeSize:], src[packed:packed+tail])
+	return nil
+}
+
+// bitWriter writes values of up to 64 bits to buf, least significant bits
+// first
+type bitWriter struct {
+	buf   []byte
+	pos   int
+	acc   uint64
+	nbits int
+}
+
+// put writes the low width bits of v, which has no bits above them
+func (w *bitWriter) put(v uint64, width int) {
+	if width > 32 {
+		w.put(v&(1<<32-1), 32)
+		w.put(v>>32, width-32)
+		return
+	}
+	w.acc |= v << w.nbits
+	w.nbits += width
+	for w.nbits >= 8 {
+		w.buf[w.pos] = byte(w.acc)
+		w.pos++
+		w.acc >>= 8
+		w.nbits -= 8
+	}
+}
+
+// flush writes the bits of a partial last byte
+func (w *bitWriter) flush() {
+	if w.nbits > 0 {
+		w.buf[w.pos] = byte(w.acc)
+		w.pos++
+		w.acc, w.nbits = 0, 0
+	}
+}
+
+// bitReader reads the values a bitWriter wrote
+type bitReader struct {
+	buf   []byte
+	pos   int
+	acc   uint64
+	nbits int
+}
+
+// get reads a value of width bits
+func (r *bitReader) get(width int) uint64 {
+	if width > 32 {
+		v := r.get(32)
+		return v | r.get(width-32)<<32
+	}
+	for r.nbits < width {
+		r.acc |= uint64(r.buf[r.pos]) << r.nbits
+		r.pos++
+		r.nbits += 8
+	}
+	v := r.acc & (1<<width - 1)
+	r.acc >>= width
+	r.nbits -= width
+	return v
+}
+
+// transpose implements FilterTranspose. The block is read as a row-major
+// matrix of meta rows, as many columns as its whole elements fill, and
+// forward writes it column by column, so that values of a column, which in
+// images and matrices often vary slowly, sit together for the shuffle and
+// codec. Elements that do not fill a last column, and bytes past the last
+// whole element, are copied. backward restores the rows.
+func transpose(dst, src []byte, typeSize int, meta uint8, forward bool) error {
+	if meta == 0 {
+		return fmt.Errorf("%w: %s with 0 rows", ErrInvalidFilter, FilterTranspose)
+	}
+	typeSize = max(typeSize, 1)
+	rows := int(meta)
+	cols := len(src) / typeSize / rows
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			in, out := (r*cols+c)*typeSize, (c*rows+r)*typeSize
+			if !forward {
+				in, out = out, in
+			}
+			copy(dst[out:out+typeSize], src[in:in+typeSize])
+		}
+	}
+	n := rows * cols * typeSize
+	copy(dst[n:], src[n:])
+	return nil
+}
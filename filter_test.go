@@ -0,0 +1,437 @@
+package blosc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/rand/v2"
+	"testing"
+)
+
+func TestFilterPipelineRoundTrip(t *testing.T) {
+	data := makeFloatData(50000)
+	pipelines := map[string][MaxFilters]FilterStage{
+		"shuffle":            {{Filter: FilterShuffle}},
+		"bitshuffle":         {5: {Filter: FilterBitShuffle}},
+		"shuffle rounds":     {{Filter: FilterShuffle, Meta: 2}},
+		"shuffle bitshuffle": {{Filter: FilterShuffle}, {}, {Filter: FilterBitShuffle}},
+		"shuffle bytedelta":  {{Filter: FilterShuffle}, {Filter: FilterByteDelta}},
+		"bytedelta meta":     {{Filter: FilterShuffle}, {Filter: FilterByteDelta, Meta: 2}},
+		"zigzag delta":       {{Filter: FilterZigZag, Meta: 1}, {Filter: FilterShuffle}},
+	}
+
+	for name, filters := range pipelines {
+		t.Run(name, func(t *testing.T) {
+			compressed, err := CompressWithOptions(data, Options{Codec: ZSTD, Level: 5, TypeSize: 4, BlockSize: 32768, Filters: filters})
+			if err != nil {
+				t.Fatalf("compress failed: %v", err)
+			}
+			header, err := ParseHeader(compressed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !header.IsExtended() || header.Size() != ExtendedHeaderSize || header.Version != Blosc2FormatVersion {
+				t.Errorf("expected an extended Blosc2 header, got %+v", header)
+			}
+			if header.Filters != filters {
+				t.Errorf("header filters %v, want %v", header.Filters, filters)
+			}
+			if err := Validate(compressed); err != nil {
+				t.Errorf("Validate: %v", err)
+			}
+			decompressed, err := Decompress(compressed)
+			if err != nil {
+				t.Fatalf("decompress failed: %v", err)
+			}
+			if !bytes.Equal(decompressed, data) {
+				t.Error("round trip failed")
+			}
+		})
+	}
+}
+
+func TestFilterPipelineMatchesShuffle(t *testing.T) {
+	// A lone shuffle filter must filter blocks exactly as Shuffle1 does
+	data := makeFloatData(20000)
+	blosc1, err := CompressWithOptions(data, Options{Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	blosc2, err := CompressWithOptions(data, Options{Codec: LZ4, Level: 5, TypeSize: 4, Filters: [MaxFilters]FilterStage{{Filter: FilterShuffle}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(blosc1[HeaderSize+4:], blosc2[ExtendedHeaderSize+4:]) {
+		t.Error("block contents differ between Shuffle1 and FilterShuffle")
+	}
+}
+
+func TestExtendedHeaderLayout(t *testing.T) {
+	data := makeTestData(1000)
+	compressed, err := CompressWithOptions(data, Options{Codec: ZSTD, Level: 5, TypeSize: 2,
+		Filters: [MaxFilters]FilterStage{{Filter: FilterShuffle, Meta: 1}, {Filter: FilterBitShuffle}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := compressed[:ExtendedHeaderSize]
+	if h[0] != Blosc2FormatVersion || h[2]&flagExtended != flagExtended || h[2]>>flagCodecShift != formatZstd {
+		t.Errorf("unexpected header start % x", h[:4])
+	}
+	if want := []byte{1, 2, 0, 0, 0, 0}; !bytes.Equal(h[16:22], want) {
+		t.Errorf("filters % x, want % x", h[16:22], want)
+	}
+	if want := []byte{1, 0, 0, 0, 0, 0}; !bytes.Equal(h[24:30], want) {
+		t.Errorf("filters meta % x, want % x", h[24:30], want)
+	}
+}
+
+func TestExtendedRunStreams(t *testing.T) {
+	// One unsplit block of 256 bytes stored as a run of 0x2A, and a second
+	// block stored as zeros
+	header := Header{
+		Version:    Blosc2FormatVersion,
+		VersionLZ:  codecFormatVersion,
+		Flags:      flagExtended | flagDontSplit | formatLZ4<<flagCodecShift,
+		TypeSize:   1,
+		NBytesOrig: 512,
+		BlockSize:  256,
+	}
+	chunk := header.Bytes()
+	chunk = binary.LittleEndian.AppendUint32(chunk, ExtendedHeaderSize+8)
+	chunk = binary.LittleEndian.AppendUint32(chunk, ExtendedHeaderSize+12)
+	chunk = binary.LittleEndian.AppendUint32(chunk, uint32(0xFFFFFFFF-0x2A+1))
+	chunk = binary.LittleEndian.AppendUint32(chunk, 0)
+	binary.LittleEndian.PutUint32(chunk[12:], uint32(len(chunk)))
+
+	if err := Validate(chunk); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+	got, err := Decompress(chunk)
+	if err != nil {
+		t.Fatalf("decompress failed: %v", err)
+	}
+	want := append(bytes.Repeat([]byte{0x2A}, 256), make([]byte, 256)...)
+	if !bytes.Equal(got, want) {
+		t.Error("run streams decoded incorrectly")
+	}
+}
+
+func TestFilterErrors(t *testing.T) {
+	data := makeTestData(1000)
+	bad := map[string]Options{
+		"trunc_prec encode": {Codec: LZ4, Filters: [MaxFilters]FilterStage{{Filter: FilterTruncPrec, Meta: 10}}},
+		"unknown filter":    {Codec: LZ4, Filters: [MaxFilters]FilterStage{{Filter: 99}}},
+		"ndcell no array":   {Codec: LZ4, Filters: [MaxFilters]FilterStage{{Filter: FilterNDCell, Meta: 4}}},
+		"legacy":            {Codec: LZ4, LegacyFormat: true, Filters: [MaxFilters]FilterStage{{Filter: FilterShuffle}}},
+	}
+	for name, opts := range bad {
+		if _, err := CompressWithOptions(data, opts); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("%s: expected ErrInvalidFilter, got %v", name, err)
+		}
+	}
+
+	// Unknown filters in a chunk are rejected when decoding
+	compressed, err := CompressWithOptions(data, Options{Codec: LZ4, TypeSize: 4, Filters: [MaxFilters]FilterStage{{Filter: FilterShuffle}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed[offsetFilters] = 99
+	if _, err := Decompress(compressed); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("expected ErrInvalidFilter decoding an unknown filter, got %v", err)
+	}
+	if err := Validate(compressed); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("expected Validate to report ErrInvalidFilter, got %v", err)
+	}
+}
+
+func TestNDCellOrder(t *testing.T) {
+	// A 3x5 block of bytes cut into 2x2 cells
+	src := make([]byte, 15)
+	for i := range src {
+		src[i] = byte(i)
+	}
+	env := &filterEnv{blockShape: []int{3, 5}}
+	dst := make([]byte, len(src))
+	if err := ndcell(dst, src, 1, 2, env, true); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		0, 1, 5, 6, // Cell (0, 0)
+		2, 3, 7, 8, // Cell (0, 1)
+		4, 9, // Cell (0, 2), one column wide
+		10, 11, // Cell (1, 0), one row high
+		12, 13,
+		14,
+	}
+	if !bytes.Equal(dst, want) {
+		t.Errorf("got %v, want %v", dst, want)
+	}
+
+	back := make([]byte, len(src))
+	if err := ndcell(back, dst, 1, 2, env, false); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(back, src) {
+		t.Error("backward does not invert forward")
+	}
+}
+
+func TestNDArrayWithNDCell(t *testing.T) {
+	shape := []int{40, 30, 20}
+	opts := Options{Codec: ZSTD, Level: 5, Filters: [MaxFilters]FilterStage{{Filter: FilterNDCell, Meta: 4}, {Filter: FilterShuffle}}}
+	arr, err := NewNDArray(shape, []int{20, 16, 20}, []int{10, 8, 10}, "<f4", opts)
+	if err != nil {
+		t.Fatalf("NewNDArray failed: %v", err)
+	}
+
+	data := makeFloatData(prod(shape))
+	zero := make([]int, len(shape))
+	if err := arr.Set(zero, shape, data); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	got, err := arr.Slice(zero, shape)
+	if err != nil {
+		t.Fatalf("Slice failed: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("NDCELL array does not round trip")
+	}
+
+	chunk, _ := arr.SChunk().Chunk(0)
+	header, _ := ParseHeader(chunk)
+	if header.Filters[0] != (FilterStage{Filter: FilterNDCell, Meta: 4}) {
+		t.Errorf("chunk filters %v", header.Filters)
+	}
+	// Without the array's block shape the chunk cannot be decoded
+	if _, err := Decompress(chunk); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("expected ErrInvalidFilter decoding outside the array, got %v", err)
+	}
+}
+
+func TestByteDelta(t *testing.T) {
+	// Two streams of three bytes, plus a trailing byte that is copied
+	src := []byte{1, 3, 6, 10, 9, 9, 7}
+	dst := make([]byte, len(src))
+	byteDelta(dst, src, 2, 0, true)
+	want := []byte{1, 2, 3, 10, 255, 0, 7}
+	if !bytes.Equal(dst, want) {
+		t.Errorf("got %v, want %v", dst, want)
+	}
+
+	back := make([]byte, len(src))
+	byteDelta(back, dst, 2, 0, false)
+	if !bytes.Equal(back, src) {
+		t.Errorf("backward got %v, want %v", back, src)
+	}
+
+	// Meta overrides the element size
+	byteDelta(dst, src, 2, 1, true)
+	if want := []byte{1, 2, 3, 4, 255, 0, 254}; !bytes.Equal(dst, want) {
+		t.Errorf("meta 1: got %v, want %v", dst, want)
+	}
+}
+
+func TestByteDeltaImprovesRatio(t *testing.T) {
+	data := makeFloatData(100000)
+	shuffled, err := CompressWithOptions(data, Options{Codec: LZ4, Level: 5, TypeSize: 4,
+		Filters: [MaxFilters]FilterStage{{Filter: FilterShuffle}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	delta, err := CompressWithOptions(data, Options{Codec: LZ4, Level: 5, TypeSize: 4,
+		Filters: [MaxFilters]FilterStage{{Filter: FilterShuffle}, {Filter: FilterByteDelta}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(delta) >= len(shuffled) {
+		t.Errorf("bytedelta chunk is %d bytes, shuffle alone %d", len(delta), len(shuffled))
+	}
+}
+
+func TestZigZag(t *testing.T) {
+	// 0, -1, 1, -2, 127, -128 as int8, plus a trailing byte that is copied
+	src := []byte{0, 0xff, 1, 0xfe, 127, 0x80}
+	dst := make([]byte, len(src))
+	if err := zigZag(dst, src, 1, 0, true); err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0, 1, 2, 3, 254, 255}; !bytes.Equal(dst, want) {
+		t.Errorf("got %v, want %v", dst, want)
+	}
+
+	// Meta 1 zigzags deltas: 1000, 1003, 1001 as int16, then a tail byte
+	src = []byte{0xe8, 0x03, 0xeb, 0x03, 0xe9, 0x03, 9}
+	dst = make([]byte, len(src))
+	if err := zigZag(dst, src, 2, 1, true); err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0xd0, 0x07, 6, 0, 3, 0, 9}; !bytes.Equal(dst, want) {
+		t.Errorf("meta 1: got %v, want %v", dst, want)
+	}
+	back := make([]byte, len(src))
+	if err := zigZag(back, dst, 2, 1, false); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(back, src) {
+		t.Errorf("backward got %v, want %v", back, src)
+	}
+
+	for _, typeSize := range []int{1, 2, 4, 8} {
+		for _, meta := range []uint8{0, 1} {
+			src := randomBytes(typeSize*100 + 3)
+			dst := make([]byte, len(src))
+			back := make([]byte, len(src))
+			if err := zigZag(dst, src, typeSize, meta, true); err != nil {
+				t.Fatal(err)
+			}
+			if err := zigZag(back, dst, typeSize, meta, false); err != nil || !bytes.Equal(back, src) {
+				t.Errorf("typesize %d meta %d: round trip failed (%v)", typeSize, meta, err)
+			}
+		}
+	}
+
+	if err := zigZag(dst, src, 3, 0, true); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("type size 3: %v", err)
+	}
+	if err := zigZag(dst, src, 2, 2, true); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("meta 2: %v", err)
+	}
+}
+
+func TestBitPack(t *testing.T) {
+	// 100 to 103 as uint16 pack to 2 bits each after the width and minimum
+	src := []byte{100, 0, 103, 0, 101, 0, 102, 0, 7}
+	dst := make([]byte, len(src))
+	if err := bitPack(dst, src, 2); err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{2, 100, 0, 0b10_01_11_00, 7, 0, 0, 0, 0}; !bytes.Equal(dst, want) {
+		t.Errorf("got %08b, want %08b", dst, want)
+	}
+
+	for _, typeSize := range []int{1, 2, 4, 8} {
+		for _, width := range []int{0, 1, 5, 8*typeSize - 4} {
+			src := make([]byte, typeSize*1000+typeSize-1)
+			for i := 0; i < 1000; i++ {
+				storeUint(src[i*typeSize:], typeSize, uint64(1)<<(8*typeSize-1)+uint64(i*7919)%(1<<width))
+			}
+			dst := make([]byte, len(src))
+			back := make([]byte, len(src))
+			if err := bitPack(dst, src, typeSize); err != nil {
+				t.Fatalf("typesize %d width %d: %v", typeSize, width, err)
+			}
+			if err := bitUnpack(back, dst, typeSize); err != nil || !bytes.Equal(back, src) {
+				t.Errorf("typesize %d width %d: round trip failed (%v)", typeSize, width, err)
+			}
+		}
+	}
+
+	// A full-width range leaves no room for the width and minimum
+	if err := bitPack(dst, []byte{0, 0, 0xff, 0xff}, 2); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("full width: %v", err)
+	}
+	if err := bitUnpack(dst, []byte{17, 0, 0, 0}, 2); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("bad width: %v", err)
+	}
+}
+
+func TestIntFiltersImproveRatio(t *testing.T) {
+	// Timestamps a second apart, give or take a few milliseconds
+	data := make([]byte, 8*50000)
+	ts := int64(1_700_000_000_000)
+	for i := 0; i < 50000; i++ {
+		ts += 1000 + int64(i*7919%11) - 5
+		binary.LittleEndian.PutUint64(data[8*i:], uint64(ts))
+	}
+	compress := func(filters [MaxFilters]FilterStage) []byte {
+		t.Helper()
+		chunk, err := CompressWithOptions(data, Options{Codec: LZ4, Level: 5, TypeSize: 8, Filters: filters})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decompressed, err := Decompress(chunk); err != nil || !bytes.Equal(decompressed, data) {
+			t.Fatalf("%v: round trip failed (%v)", filters, err)
+		}
+		return chunk
+	}
+	shuffled := compress([MaxFilters]FilterStage{{Filter: FilterShuffle}})
+	packed := compress([MaxFilters]FilterStage{{Filter: FilterZigZag, Meta: 1}, {Filter: FilterBitPack}})
+	if len(packed) >= len(shuffled) {
+		t.Errorf("zigzag and bitpack chunk is %d bytes, shuffle alone %d", len(packed), len(shuffled))
+	}
+	t.Logf("shuffle %d bytes, zigzag and bitpack %d", len(shuffled), len(packed))
+}
+
+func TestTranspose(t *testing.T) {
+	// Two rows of three uint16, a partial column and a trailing byte
+	src := []byte{1, 0, 2, 0, 3, 0, 4, 0, 5, 0, 6, 0, 7, 0, 9}
+	dst := make([]byte, len(src))
+	if err := transpose(dst, src, 2, 2, true); err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{1, 0, 4, 0, 2, 0, 5, 0, 3, 0, 6, 0, 7, 0, 9}; !bytes.Equal(dst, want) {
+		t.Errorf("got %v, want %v", dst, want)
+	}
+	back := make([]byte, len(src))
+	if err := transpose(back, dst, 2, 2, false); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(back, src) {
+		t.Errorf("backward got %v, want %v", back, src)
+	}
+	if err := transpose(dst, src, 2, 0, true); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("0 rows: %v", err)
+	}
+}
+
+func TestTransposeImprovesRatio(t *testing.T) {
+	// A matrix of columns that each wander slowly from their own start,
+	// so that rows jump about and never repeat
+	const rows, cols = 64, 1000
+	rng := rand.New(rand.NewPCG(1, 2))
+	column := make([]uint32, cols)
+	for c := range column {
+		column[c] = rng.Uint32()
+	}
+	data := make([]byte, 4*rows*cols*4)
+	for i := 0; i < len(data)/4; i++ {
+		c := i % cols
+		column[c] += uint32(rng.IntN(16))
+		binary.LittleEndian.PutUint32(data[4*i:], column[c])
+	}
+	filters := WithFilters(FilterStage{Filter: FilterShuffle}, FilterStage{Filter: FilterByteDelta})
+	plain, err := NewConfig(WithCodec(ZSTD), WithTypeSize(4), filters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transposed, err := NewConfig(WithCodec(ZSTD), WithTypeSize(4), filters, WithTranspose(rows, cols))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts := transposed.Options(); opts.Filters[0] != (FilterStage{Filter: FilterTranspose, Meta: rows}) ||
+		opts.Filters[1].Filter != FilterShuffle || opts.BlockSize != rows*cols*4 {
+		t.Errorf("WithTranspose options %+v", opts)
+	}
+
+	shuffled, err := plain.Compress(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunk, err := transposed.Compress(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Decompress(chunk); err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("round trip failed (%v)", err)
+	}
+	if len(chunk) >= len(shuffled) {
+		t.Errorf("transposed chunk is %d bytes, without transpose %d", len(chunk), len(shuffled))
+	}
+	t.Logf("shuffle and bytedelta %d bytes, with transpose %d", len(shuffled), len(chunk))
+
+	for _, rows := range []int{0, 256} {
+		if _, err := NewConfig(WithTranspose(rows, cols)); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("%d rows: %v", rows, err)
+		}
+	}
+}
@@ -0,0 +1,479 @@
+package blosc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"slices"
+)
+
+// Frame layout. All integers are little-endian.
+//
+//	header   magic "GBFRAME\x00", version u8, flags u8, 2 reserved bytes,
+//	         header checksum u32, trailer offset u64, trailer length u64
+//	         (32 bytes)
+//	chunks   stored chunks, at the offsets listed in the trailer
+//	trailer  chunk count u32, then per chunk its offset u64, stored length u64
+//	         and uncompressed size u64; metalayer count u32, then per
+//	         metalayer its name length u16, name, content length u32 and
+//	         content; key ID length u16 and key ID ("" if not encrypted),
+//	         followed in encrypted frames by a 16-byte random frame ID;
+//	         trailer checksum u32
+//
+// The header points at the trailer, so a frame can be read without scanning
+// its chunks, and chunks need not be contiguous. The index of a sparse frame
+// (see CreateSChunkDir) has the frameSparse flag and no chunks; its trailer
+// offsets are the numbers of the chunk files.
+//
+// Frames with the frameChecksums flag carry CRC32C checksums of the header,
+// computed over every header byte but the checksum itself, and of the
+// trailer. Frames written before checksums were added lack the flag and the
+// trailer checksum, and are read unchecked.
+const (
+	frameMagic      = "GBFRAME\x00"
+	frameVersion    = 1
+	frameHeaderSize = 32
+	frameEntrySize  = 24
+	frameSparse     = 0x1
+	frameChecksums  = 0x2
+)
+
+// castagnoli is the CRC32C table for frame and block checksums
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// WriteTo writes s to w as a frame, which OpenFrame reads back. Encrypted
+// chunks are written as stored, along with the key ID and the frame ID they
+// are bound to, and chunks sharing storage under SetDedup are written once.
+func (s *SChunk) WriteTo(w io.Writer) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.writeTo(w)
+}
+
+func (s *SChunk) writeTo(w io.Writer) (int64, error) {
+	// Chunks go back to back after the header, each stored chunk once
+	entries := make([]frameEntry, len(s.chunks))
+	offset := int64(frameHeaderSize)
+	var written map[storageKey]int64
+	if len(s.shared) > 0 {
+		written = make(map[storageKey]int64)
+	}
+	for i, c := range s.chunks {
+		if written != nil {
+			if at, ok := written[c.key()]; ok {
+				entries[i] = frameEntry{offset: at, length: c.length, nbytes: c.nbytes}
+				continue
+			}
+			written[c.key()] = offset
+		}
+		entries[i] = frameEntry{offset: offset, length: c.length, nbytes: c.nbytes}
+		offset += c.length
+	}
+	trailer := s.frameTrailer(entries)
+
+	var n int64
+	write := func(p []byte) error {
+		m, err := w.Write(p)
+		n += int64(m)
+		return err
+	}
+	if err := write(frameHeader(offset, len(trailer), 0)); err != nil {
+		return n, err
+	}
+	for i, e := range entries {
+		if e.offset < n {
+			continue // Shares a chunk written already
+		}
+		chunk, err := s.stored(i)
+		if err != nil {
+			return n, err
+		}
+		if err := write(chunk); err != nil {
+			return n, err
+		}
+	}
+	return n, write(trailer)
+}
+
+// frameHeader encodes a checksummed frame header pointing at the trailer
+func frameHeader(trailerOffset int64, trailerLength int, flags byte) []byte {
+	header := make([]byte, frameHeaderSize)
+	copy(header, frameMagic)
+	header[8] = frameVersion
+	header[9] = flags | frameChecksums
+	binary.LittleEndian.PutUint64(header[16:], uint64(trailerOffset))
+	binary.LittleEndian.PutUint64(header[24:], uint64(trailerLength))
+	binary.LittleEndian.PutUint32(header[12:], headerChecksum(header))
+	return header
+}
+
+// headerChecksum is the CRC32C of a frame header, skipping its checksum field
+func headerChecksum(header []byte) uint32 {
+	crc := crc32.Update(0, castagnoli, header[:12])
+	return crc32.Update(crc, castagnoli, header[16:frameHeaderSize])
+}
+
+// frameTrailer encodes the trailer of s for chunks at the given places. The
+// caller holds s.mu.
+func (s *SChunk) frameTrailer(entries []frameEntry) []byte {
+	var b []byte
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(entries)))
+	for _, e := range entries {
+		b = binary.LittleEndian.AppendUint64(b, uint64(e.offset))
+		b = binary.LittleEndian.AppendUint64(b, uint64(e.length))
+		b = binary.LittleEndian.AppendUint64(b, uint64(e.nbytes))
+	}
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(s.metaNames)))
+	for _, name := range s.metaNames {
+		b = binary.LittleEndian.AppendUint16(b, uint16(len(name)))
+		b = append(b, name...)
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(s.metalayers[name])))
+		b = append(b, s.metalayers[name]...)
+	}
+	keyID := s.keyID()
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(keyID)))
+	b = append(b, keyID...)
+	if keyID != "" {
+		b = append(b, s.frameID()...)
+	}
+	return binary.LittleEndian.AppendUint32(b, crc32.Checksum(b, castagnoli))
+}
+
+// Frame is a read-only view of a serialized SChunk. Chunks are decompressed
+// on demand. A Frame is safe for concurrent use once its keys are set.
+type Frame struct {
+	data        []byte
+	ra          io.ReaderAt // Source of the chunks instead of data, if set
+	chunks      []frameEntry
+	nbytes      int64
+	cbytes      int64
+	metaNames   []string
+	metalayers  map[string][]byte
+	keyID       string
+	frameID     []byte // Binds the chunks to the frame, if encrypted
+	cipher      *chunkCipher
+	env         *filterEnv
+	mapped      []byte      // Memory mapping to release on Close
+	cache       *chunkCache // Recently decompressed chunks, if enabled
+	storedCache *chunkCache // Recently read chunks as stored, if enabled for ra
+}
+
+// frameEntry locates a stored chunk in a frame
+type frameEntry struct {
+	offset int64
+	length int64
+	nbytes int64
+}
+
+// OpenFrame parses the frame in data, which is used without copying. An
+// encrypted frame needs SetKeys before its chunks can be read.
+func OpenFrame(data []byte) (*Frame, error) {
+	offset, length, flags, err := parseFrameHeader(data, int64(len(data)), false)
+	if err != nil {
+		return nil, err
+	}
+	f := &Frame{data: data}
+	if err := f.parseTrailer(data[offset:offset+length], offset, flags); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// OpenFrameReaderAt parses the frame in the first size bytes of r, reading
+// only its header and trailer, so that frames in object stores can be read
+// through range requests: each chunk is read from r when Chunk or
+// DecompressChunk asks for it, and nothing else is fetched. r must allow
+// concurrent ReadAt calls, as io.ReaderAt documents, for the Frame to be
+// safe for concurrent use. Close does nothing for such frames; close r
+// when done with the frame.
+func OpenFrameReaderAt(r io.ReaderAt, size int64) (*Frame, error) {
+	if size < frameHeaderSize {
+		return nil, fmt.Errorf("%w: %d bytes", ErrInvalidFrame, size)
+	}
+	header, err := readAt(r, 0, frameHeaderSize)
+	if err != nil {
+		return nil, err
+	}
+	offset, length, flags, err := parseFrameHeader(header, size, false)
+	if err != nil {
+		return nil, err
+	}
+	trailer, err := readAt(r, offset, length)
+	if err != nil {
+		return nil, err
+	}
+	f := &Frame{ra: r}
+	if err := f.parseTrailer(trailer, offset, flags); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// readAt reads the length bytes at offset in r
+func readAt(r io.ReaderAt, offset, length int64) ([]byte, error) {
+	if int64(int(length)) != length {
+		return nil, fmt.Errorf("%w: %d bytes at %d", ErrDataTooLarge, length, offset)
+	}
+	p := make([]byte, length)
+	n, err := r.ReadAt(p, offset)
+	if n == len(p) {
+		// ReadAt may report io.EOF along with the last bytes
+		return p, nil
+	}
+	if err == nil || err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return nil, fmt.Errorf("reading %d bytes at %d: %w", length, offset, err)
+}
+
+// parseFrameHeader checks the header of a frame of size bytes, which must be
+// a sparse frame index if sparse is set, and returns where its trailer is
+// along with the header flags
+func parseFrameHeader(header []byte, size int64, sparse bool) (offset, length int64, flags byte, err error) {
+	if len(header) < frameHeaderSize || !bytes.Equal(header[:8], []byte(frameMagic)) {
+		return 0, 0, 0, fmt.Errorf("%w: missing frame header", ErrInvalidFrame)
+	}
+	// Frames without checksums leave the reserved bytes zero, so a checksum
+	// whose flag is missing shows that the flags byte was damaged
+	flags = header[9]
+	crc, sum := headerChecksum(header), binary.LittleEndian.Uint32(header[12:])
+	if flags&frameChecksums != 0 && crc != sum || flags&frameChecksums == 0 && sum != 0 {
+		return 0, 0, 0, fmt.Errorf("%w: %w: header checksum %08x, stored %08x", ErrInvalidFrame, ErrChecksum, crc, sum)
+	}
+	if header[8] != frameVersion {
+		return 0, 0, 0, fmt.Errorf("%w: frame version %d", ErrInvalidVersion, header[8])
+	}
+	switch isSparse := flags&frameSparse != 0; {
+	case isSparse && !sparse:
+		return 0, 0, 0, fmt.Errorf("%w: sparse frame index, open its directory with OpenSChunkDir", ErrInvalidFrame)
+	case !isSparse && sparse:
+		return 0, 0, 0, fmt.Errorf("%w: not a sparse frame index", ErrInvalidFrame)
+	}
+	off := binary.LittleEndian.Uint64(header[16:])
+	n := binary.LittleEndian.Uint64(header[24:])
+	if off < frameHeaderSize || off > uint64(size) || n > uint64(size)-off || int64(int(n)) != int64(n) {
+		return 0, 0, 0, fmt.Errorf("%w: trailer at %d+%d outside %d bytes", ErrInvalidFrame, off, n, size)
+	}
+	return int64(off), int64(n), flags, nil
+}
+
+// parseTrailer decodes the trailer of a frame with the given header flags
+// whose chunks end by limit, or of a sparse frame index, whose chunk offsets
+// are file numbers
+func (f *Frame) parseTrailer(t []byte, limit int64, flags byte) error {
+	if flags&frameChecksums != 0 {
+		if len(t) < 4 {
+			return fmt.Errorf("%w: truncated trailer", ErrInvalidFrame)
+		}
+		sum := binary.LittleEndian.Uint32(t[len(t)-4:])
+		t = t[:len(t)-4]
+		if crc := crc32.Checksum(t, castagnoli); crc != sum {
+			return fmt.Errorf("%w: %w: trailer checksum %08x, stored %08x", ErrInvalidFrame, ErrChecksum, crc, sum)
+		}
+	}
+	sparse := flags&frameSparse != 0
+	r := frameReader{b: t}
+	count := r.uint32()
+	if uint64(count) > uint64(len(t)/frameEntrySize) {
+		return fmt.Errorf("%w: %d chunks in a %d byte trailer", ErrInvalidFrame, count, len(t))
+	}
+	f.chunks = make([]frameEntry, count)
+	var seen map[int64]bool // Chunk offsets, once they stop increasing
+	for i := range f.chunks {
+		e := frameEntry{offset: int64(r.uint64()), length: int64(r.uint64()), nbytes: int64(r.uint64())}
+		if sparse {
+			if e.offset < 0 || e.length < 0 || e.nbytes < 0 {
+				return fmt.Errorf("%w: chunk %d in file %d of %d bytes", ErrInvalidFrame, i, e.offset, e.length)
+			}
+		} else if e.offset < frameHeaderSize || e.length < 0 || e.offset > limit || e.length > limit-e.offset || e.nbytes < 0 {
+			return fmt.Errorf("%w: chunk %d at %d+%d outside the frame", ErrInvalidFrame, i, e.offset, e.length)
+		}
+		f.chunks[i] = e
+		f.nbytes += e.nbytes
+
+		// Chunks sharing storage, as SetDedup writes them, count once
+		if seen == nil && i > 0 && e.offset <= f.chunks[i-1].offset {
+			seen = make(map[int64]bool, count)
+			for _, p := range f.chunks[:i] {
+				seen[p.offset] = true
+			}
+		}
+		if seen != nil {
+			if seen[e.offset] {
+				continue
+			}
+			seen[e.offset] = true
+		}
+		f.cbytes += e.length
+	}
+
+	count = r.uint32()
+	f.metalayers = make(map[string][]byte)
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		name := string(r.bytes(int(r.uint16())))
+		content := r.bytes(int(r.uint32()))
+		if _, ok := f.metalayers[name]; !ok {
+			f.metaNames = append(f.metaNames, name)
+		}
+		f.metalayers[name] = content
+	}
+	f.keyID = string(r.bytes(int(r.uint16())))
+	if f.keyID != "" {
+		f.frameID = slices.Clone(r.bytes(frameIDSize))
+	}
+	if r.err != nil {
+		return r.err
+	}
+	f.env = containerEnv(f.metalayers, nil)
+	return nil
+}
+
+// frameReader decodes little-endian fields, recording the first overrun
+type frameReader struct {
+	b   []byte
+	err error
+}
+
+func (r *frameReader) bytes(n int) []byte {
+	if r.err != nil || n < 0 || n > len(r.b) {
+		if r.err == nil {
+			r.err = fmt.Errorf("%w: truncated trailer", ErrInvalidFrame)
+		}
+		return nil
+	}
+	p := r.b[:n]
+	r.b = r.b[n:]
+	return p
+}
+
+func (r *frameReader) uint16() uint16 {
+	if p := r.bytes(2); p != nil {
+		return binary.LittleEndian.Uint16(p)
+	}
+	return 0
+}
+
+func (r *frameReader) uint32() uint32 {
+	if p := r.bytes(4); p != nil {
+		return binary.LittleEndian.Uint32(p)
+	}
+	return 0
+}
+
+func (r *frameReader) uint64() uint64 {
+	if p := r.bytes(8); p != nil {
+		return binary.LittleEndian.Uint64(p)
+	}
+	return 0
+}
+
+// SetKeys sets the provider of the key the frame is encrypted with. It fails
+// if the key cannot be found or is not a valid AES key.
+func (f *Frame) SetKeys(keys KeyProvider) error {
+	if f.keyID == "" {
+		return nil
+	}
+	c, err := newChunkCipher(f.keyID, f.frameID, keys)
+	if err != nil {
+		return err
+	}
+	f.cipher = c
+	return nil
+}
+
+// KeyID returns the ID of the key the frame is encrypted with, or "" if it is
+// not encrypted.
+func (f *Frame) KeyID() string {
+	return f.keyID
+}
+
+// NumChunks returns the number of chunks.
+func (f *Frame) NumChunks() int {
+	return len(f.chunks)
+}
+
+// NBytes returns the uncompressed size of all chunks.
+func (f *Frame) NBytes() int64 {
+	return f.nbytes
+}
+
+// CBytes returns the stored size of all chunks, counting chunks that share
+// storage once.
+func (f *Frame) CBytes() int64 {
+	return f.cbytes
+}
+
+// Metalayer returns the content stored under name.
+func (f *Frame) Metalayer(name string) ([]byte, bool) {
+	content, ok := f.metalayers[name]
+	return content, ok
+}
+
+// Metalayers returns the metalayer names in the order they were added.
+func (f *Frame) Metalayers() []string {
+	return slices.Clone(f.metaNames)
+}
+
+// Chunk returns compressed chunk i. The slice is shared with the frame data,
+// unless the frame is encrypted and the chunk is decrypted into a new one, or
+// the frame was opened with OpenFrameReaderAt and the chunk is read into a
+// new one.
+func (f *Frame) Chunk(i int) ([]byte, error) {
+	if i < 0 || i >= len(f.chunks) {
+		return nil, fmt.Errorf("%w: %d of %d", ErrChunkIndex, i, len(f.chunks))
+	}
+	stored, err := f.stored(i)
+	if err != nil {
+		return nil, err
+	}
+	if f.keyID == "" {
+		return stored, nil
+	}
+	if f.cipher == nil {
+		return nil, fmt.Errorf("%w: frame is encrypted with key %q", ErrInvalidKey, f.keyID)
+	}
+	return f.cipher.open(stored, i)
+}
+
+// stored returns chunk i as stored, reading it from r for frames opened
+// with OpenFrameReaderAt
+func (f *Frame) stored(i int) ([]byte, error) {
+	e := f.chunks[i]
+	if f.ra != nil {
+		if chunk, ok := f.storedCache.get(i); ok {
+			return chunk, nil
+		}
+		chunk, err := readAt(f.ra, e.offset, e.length)
+		if err != nil {
+			return nil, err
+		}
+		f.storedCache.put(i, chunk)
+		return chunk, nil
+	}
+	return f.data[e.offset : e.offset+e.length], nil
+}
+
+// ChunkOffset returns where chunk i is stored in the frame data and its
+// stored length, to locate damage that Verify reports within the chunk.
+func (f *Frame) ChunkOffset(i int) (offset, length int64, err error) {
+	if i < 0 || i >= len(f.chunks) {
+		return 0, 0, fmt.Errorf("%w: %d of %d", ErrChunkIndex, i, len(f.chunks))
+	}
+	return f.chunks[i].offset, f.chunks[i].length, nil
+}
+
+// DecompressChunk decompresses chunk i, or copies it from the cache set
+// with SetCache.
+func (f *Frame) DecompressChunk(i int) ([]byte, error) {
+	if data, ok := f.cache.get(i); ok {
+		return data, nil
+	}
+	data, err := decompressChained(i, f.Chunk, f.cache, f.env)
+	if err != nil {
+		return nil, err
+	}
+	f.cache.put(i, data)
+	return data, nil
+}
@@ -0,0 +1,297 @@
+package blosc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// makeSChunk returns an SChunk of n chunks of makeTestData, with a metalayer
+func makeSChunk(t *testing.T, n int) (*SChunk, [][]byte) {
+	t.Helper()
+	sc := NewSChunk(Options{Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: 4})
+	sc.SetMetalayer("units", []byte("kelvin"))
+	var want [][]byte
+	for i := 0; i < n; i++ {
+		data := makeTestData(10000 + 1000*i)
+		if _, err := sc.AppendBuffer(data); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, data)
+	}
+	return sc, want
+}
+
+func TestFrameRoundTrip(t *testing.T) {
+	sc, want := makeSChunk(t, 4)
+
+	var buf bytes.Buffer
+	n, err := sc.WriteTo(&buf)
+	if err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("WriteTo returned %d, wrote %d", n, buf.Len())
+	}
+
+	f, err := OpenFrame(buf.Bytes())
+	if err != nil {
+		t.Fatalf("OpenFrame failed: %v", err)
+	}
+	if f.NumChunks() != len(want) || f.NBytes() != sc.NBytes() || f.CBytes() != sc.CBytes() {
+		t.Errorf("frame has %d chunks, %d/%d bytes; want %d, %d/%d",
+			f.NumChunks(), f.NBytes(), f.CBytes(), len(want), sc.NBytes(), sc.CBytes())
+	}
+	if f.KeyID() != "" {
+		t.Errorf("unexpected key ID %q", f.KeyID())
+	}
+	if content, ok := f.Metalayer("units"); !ok || string(content) != "kelvin" {
+		t.Errorf("metalayer = %q, %v", content, ok)
+	}
+	if !slices.Equal(f.Metalayers(), []string{"units"}) {
+		t.Errorf("metalayers = %v", f.Metalayers())
+	}
+	for i, data := range want {
+		got, err := f.DecompressChunk(i)
+		if err != nil {
+			t.Fatalf("chunk %d: %v", i, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("chunk %d differs", i)
+		}
+	}
+	if _, err := f.Chunk(len(want)); !errors.Is(err, ErrChunkIndex) {
+		t.Errorf("expected ErrChunkIndex, got %v", err)
+	}
+
+	// Chunks are where ChunkOffset says
+	for i := range want {
+		offset, length, err := f.ChunkOffset(i)
+		chunk, _ := f.Chunk(i)
+		if err != nil || !bytes.Equal(buf.Bytes()[offset:offset+length], chunk) {
+			t.Errorf("chunk %d: offset %d+%d: %v", i, offset, length, err)
+		}
+	}
+	if _, _, err := f.ChunkOffset(-1); !errors.Is(err, ErrChunkIndex) {
+		t.Errorf("ChunkOffset(-1): expected ErrChunkIndex, got %v", err)
+	}
+}
+
+func TestFrameNDCell(t *testing.T) {
+	// The block shape needed by NDCELL comes from the frame's metalayer
+	opts := Options{Codec: ZSTD, Level: 5, Filters: [MaxFilters]FilterStage{{Filter: FilterNDCell, Meta: 2}}}
+	arr, err := NewNDArray([]int{8, 8}, []int{8, 8}, []int{4, 4}, "<f4", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := makeFloatData(64)
+	if err := arr.Set([]int{0, 0}, []int{8, 8}, data); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := arr.SChunk().WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	f, err := OpenFrame(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.DecompressChunk(0); err != nil {
+		t.Errorf("DecompressChunk: %v", err)
+	}
+}
+
+func TestFrameErrors(t *testing.T) {
+	sc, _ := makeSChunk(t, 2)
+	var buf bytes.Buffer
+	if _, err := sc.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	frame := buf.Bytes()
+
+	corrupt := func(f func(b []byte) []byte) []byte {
+		return f(slices.Clone(frame))
+	}
+	bad := map[string][]byte{
+		"empty":     nil,
+		"magic":     corrupt(func(b []byte) []byte { b[0] = 'X'; return b }),
+		"truncated": frame[:len(frame)-1],
+		"trailer offset": corrupt(func(b []byte) []byte {
+			binary.LittleEndian.PutUint64(b[16:], uint64(len(b)))
+			return b
+		}),
+		"chunk count": corrupt(func(b []byte) []byte {
+			off := binary.LittleEndian.Uint64(b[16:])
+			binary.LittleEndian.PutUint32(b[off:], 1<<30)
+			return b
+		}),
+		"chunk offset": corrupt(func(b []byte) []byte {
+			off := binary.LittleEndian.Uint64(b[16:])
+			binary.LittleEndian.PutUint64(b[off+4:], uint64(len(b)))
+			return b
+		}),
+	}
+	for name, data := range bad {
+		if _, err := OpenFrame(data); !errors.Is(err, ErrInvalidFrame) {
+			t.Errorf("%s: expected ErrInvalidFrame, got %v", name, err)
+		}
+	}
+
+	version := corrupt(func(b []byte) []byte {
+		b[8] = 99
+		binary.LittleEndian.PutUint32(b[12:], headerChecksum(b))
+		return b
+	})
+	if _, err := OpenFrame(version); !errors.Is(err, ErrInvalidVersion) {
+		t.Errorf("expected ErrInvalidVersion, got %v", err)
+	}
+}
+
+func TestFrameChecksums(t *testing.T) {
+	sc, want := makeSChunk(t, 2)
+	var buf bytes.Buffer
+	if _, err := sc.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	frame := buf.Bytes()
+	trailer := int(binary.LittleEndian.Uint64(frame[16:]))
+
+	// Every single bit flip in the header or trailer is caught, including
+	// those that leave sizes and offsets plausible
+	for _, pos := range []int{9, 12, 17, 24, 25, trailer + 2, trailer + 30, len(frame) - 8, len(frame) - 1} {
+		for bit := 0; bit < 8; bit++ {
+			data := slices.Clone(frame)
+			data[pos] ^= 1 << bit
+			_, err := OpenFrame(data)
+			if !errors.Is(err, ErrInvalidFrame) {
+				t.Errorf("byte %d bit %d: expected ErrInvalidFrame, got %v", pos, bit, err)
+			}
+			if !errors.Is(err, ErrChecksum) {
+				t.Errorf("byte %d bit %d: expected ErrChecksum, got %v", pos, bit, err)
+			}
+		}
+	}
+
+	// Frames from before checksums are read unchecked
+	old := slices.Clone(frame[:len(frame)-4])
+	old[9] = 0
+	clear(old[12:16])
+	binary.LittleEndian.PutUint64(old[24:], uint64(len(old)-trailer))
+	f, err := OpenFrame(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, data := range want {
+		got, err := f.DecompressChunk(i)
+		if err != nil || !bytes.Equal(got, data) {
+			t.Errorf("chunk %d of an unchecked frame: %v", i, err)
+		}
+	}
+	if units, _ := f.Metalayer("units"); string(units) != "kelvin" {
+		t.Errorf("metalayer of an unchecked frame: %q", units)
+	}
+}
+
+// rangeReader serves ReadAt from data, recording each range asked for, as
+// an object store serving range requests would
+type rangeReader struct {
+	data   []byte
+	ranges [][2]int64
+	fail   bool
+}
+
+func (r *rangeReader) ReadAt(p []byte, off int64) (int, error) {
+	r.ranges = append(r.ranges, [2]int64{off, int64(len(p))})
+	if r.fail {
+		return 0, errors.New("connection reset")
+	}
+	return bytes.NewReader(r.data).ReadAt(p, off)
+}
+
+func TestOpenFrameReaderAt(t *testing.T) {
+	sc, want := makeSChunk(t, 4)
+	frame := mustFrameBytes(t, sc)
+	r := &rangeReader{data: frame}
+	f, err := OpenFrameReaderAt(r, int64(len(frame)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.ranges) != 2 {
+		t.Errorf("opening read %v, want the header and trailer", r.ranges)
+	}
+	if f.NumChunks() != 4 || f.NBytes() != sc.NBytes() {
+		t.Errorf("%d chunks of %d bytes", f.NumChunks(), f.NBytes())
+	}
+	if units, _ := f.Metalayer("units"); string(units) != "kelvin" {
+		t.Errorf("metalayer %q", units)
+	}
+
+	// Only the requested chunk is read
+	r.ranges = nil
+	got, err := f.DecompressChunk(2)
+	if err != nil || !bytes.Equal(got, want[2]) {
+		t.Fatalf("chunk 2: %v", err)
+	}
+	offset, length, _ := f.ChunkOffset(2)
+	if len(r.ranges) != 1 || r.ranges[0] != [2]int64{offset, length} {
+		t.Errorf("chunk 2 read %v, want [%d %d]", r.ranges, offset, length)
+	}
+	for i, data := range f.DecompressedChunks() {
+		if !bytes.Equal(data, want[i]) {
+			t.Errorf("chunk %d differs", i)
+		}
+	}
+
+	// ToSChunk reads from r as well, and writes the same frame back
+	s := f.ToSChunk(Options{Codec: LZ4, Level: 5})
+	if !bytes.Equal(mustFrameBytes(t, s), frame) {
+		t.Error("ToSChunk wrote a different frame")
+	}
+	if _, err := s.AppendBuffer(makeTestData(5000)); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.DecompressChunk(1); err != nil || !bytes.Equal(got, want[1]) {
+		t.Errorf("chunk 1 of ToSChunk: %v", err)
+	}
+
+	// Read errors are reported by the chunks that need the reads
+	r.fail = true
+	if _, err := f.Chunk(0); err == nil || !strings.Contains(err.Error(), "connection reset") {
+		t.Errorf("failing reader: %v", err)
+	}
+	if _, err := OpenFrameReaderAt(r, int64(len(frame))); err == nil {
+		t.Error("opened through a failing reader")
+	}
+	r.fail = false
+	for _, size := range []int64{0, frameHeaderSize, int64(len(frame)) - 1} {
+		if _, err := OpenFrameReaderAt(r, size); !errors.Is(err, ErrInvalidFrame) {
+			t.Errorf("size %d: %v", size, err)
+		}
+	}
+	if _, err := OpenFrameReaderAt(&rangeReader{data: frame[:100]}, int64(len(frame))); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("short source: %v", err)
+	}
+
+	// Encrypted frames need keys as usual
+	if err := sc.SetEncryption("k1", testKeys); err != nil {
+		t.Fatal(err)
+	}
+	frame = mustFrameBytes(t, sc)
+	f, err = OpenFrameReaderAt(bytes.NewReader(frame), int64(len(frame)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Chunk(0); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("locked frame: %v", err)
+	}
+	if err := f.SetKeys(testKeys); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := f.DecompressChunk(3); err != nil || !bytes.Equal(got, want[3]) {
+		t.Errorf("encrypted chunk 3: %v", err)
+	}
+}
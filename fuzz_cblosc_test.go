@@ -0,0 +1,110 @@
+//go:build cgo && (cblosc || cgo_blosc)
+
+package blosc
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mrjoshuak/go-blosc/internal/cblosc"
+)
+
+// Differential fuzz targets against c-blosc, which flag inputs the two
+// implementations disagree on. Run with:
+//
+//	go test -tags cblosc -run '^$' -fuzz FuzzCBloscDecompress .
+//
+// Both decode with the pure Go codecs even under the cgo_blosc tag, where
+// Decompress would hand the chunks to libblosc and compare it with itself.
+
+// fuzzCBloscLimit bounds the original size of chunks the targets decode,
+// as both sides allocate it up front
+const fuzzCBloscLimit = 1 << 22
+
+// FuzzCBloscDecompress decodes inputs with both implementations and fails
+// when one accepts a chunk the other rejects, or both accept it and decode
+// different data. It only compares Blosc1 chunks, the only ones c-blosc
+// reads.
+func FuzzCBloscDecompress(f *testing.F) {
+	data := makeCompressibleData(5000)
+	for _, codec := range interopCodecs {
+		for _, shuffle := range []Shuffle{NoShuffle, Shuffle1, BitShuffle} {
+			for _, typeSize := range []int{1, 4, 20} {
+				chunk, err := Compress(data, codec, 5, shuffle, typeSize)
+				if err != nil {
+					f.Fatal(err)
+				}
+				f.Add(chunk)
+				if chunk, err := cblosc.CompressBlocks(data, codec.String(), 5, int(shuffle), typeSize, 1024, 1); err == nil {
+					f.Add(chunk)
+				}
+			}
+		}
+	}
+	// Memcpyed, truncated and split chunks
+	memcpyed, _ := CompressWithOptions(makeTestDataPure(3000), Options{Codec: LZ4, Level: 5, TypeSize: 4, SkipIncompressible: true})
+	f.Add(memcpyed)
+	chunk, _ := Compress(data, ZSTD, 5, Shuffle1, 8)
+	f.Add(chunk[:len(chunk)/2])
+	split, _ := CompressWithOptions(data, Options{Codec: LZ4, Level: 9, Shuffle: Shuffle1, TypeSize: 4, BlockSize: 1024})
+	f.Add(split)
+
+	f.Fuzz(func(t *testing.T, chunk []byte) {
+		h, err := ParseHeader(chunk)
+		if err != nil || h.Version != FormatVersion || h.legacy || h.IsExtended() || h.NBytesOrig > fuzzCBloscLimit {
+			return
+		}
+		got, goErr := decompressGo(nil, chunk, 0, fuzzCBloscLimit, nil)
+		want, cErr := cblosc.Decompress(chunk)
+		switch {
+		case goErr != nil && cErr == nil:
+			t.Fatalf("go-blosc rejects a chunk c-blosc decodes (%s): %v", h, goErr)
+		case goErr == nil && cErr != nil:
+			t.Fatalf("go-blosc decodes a chunk c-blosc rejects (%s): %v", h, cErr)
+		case goErr == nil && !bytes.Equal(got, want):
+			t.Fatalf("go-blosc and c-blosc decode %s to different data", h)
+		}
+	})
+}
+
+// FuzzCBloscRoundTrip compresses data with each implementation and checks
+// that the other decodes it
+func FuzzCBloscRoundTrip(f *testing.F) {
+	f.Add(makeCompressibleData(5000), uint8(LZ4), uint8(5), uint8(Shuffle1), uint8(4), uint16(0))
+	f.Add(makeTestDataPure(3000), uint8(ZSTD), uint8(9), uint8(BitShuffle), uint8(8), uint16(1024))
+	f.Add([]byte("a"), uint8(BloscLZ), uint8(1), uint8(NoShuffle), uint8(1), uint16(0))
+
+	f.Fuzz(func(t *testing.T, data []byte, codecID, level, shuffleID, typeSize uint8, blockSize uint16) {
+		if len(data) == 0 {
+			return
+		}
+		codec := interopCodecs[int(codecID)%len(interopCodecs)]
+		shuffle := Shuffle(shuffleID % 3)
+		opts, err := normalizeOptions(Options{
+			Codec:     codec,
+			Level:     1 + int(level)%9,
+			Shuffle:   shuffle,
+			TypeSize:  1 + int(typeSize)%255,
+			BlockSize: int(blockSize),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		chunk, err := compressGo(data, opts, nil)
+		if err != nil {
+			t.Fatalf("go-blosc compressing with %s: %v", opts, err)
+		}
+		if got, err := cblosc.Decompress(chunk); err != nil || !bytes.Equal(got, data) {
+			t.Fatalf("c-blosc decoding a go-blosc chunk of %s: %v", opts, err)
+		}
+
+		chunk, err = cblosc.CompressBlocks(data, codec.String(), opts.Level, int(shuffle), opts.TypeSize, opts.BlockSize, 1)
+		if err != nil {
+			return // c-blosc built without the codec
+		}
+		if got, err := decompressGo(nil, chunk, 0, -1, nil); err != nil || !bytes.Equal(got, data) {
+			t.Fatalf("go-blosc decoding a c-blosc chunk of %s: %v", opts, err)
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package blosc
+
+import "fmt"
+
+// HeaderFlag is a bit, or field of bits, of the Flags byte of a chunk header
+// or the Blosc2Flags byte of an extended header, as listed by HeaderFlags.
+type HeaderFlag struct {
+	Name   string
+	Offset int   // Header byte holding the bits: 2 for Flags, 31 for Blosc2Flags
+	Mask   uint8 // Bits of the byte the flag occupies
+
+	// Supported reports whether chunks with the flag set decode. Those with
+	// an unsupported flag set, such as a reserved bit or a feature of a
+	// later format, fail with ErrUnsupportedFeature instead of decoding to
+	// the wrong data.
+	Supported bool
+}
+
+// headerFlags accounts for every bit of both flag bytes, so a bit that is
+// not supported is never silently ignored. A later format that gives a
+// reserved bit a meaning adds it here, with the code that decodes it.
+var headerFlags = []HeaderFlag{
+	{"shuffle", offsetFlags, flagShuffle, true},
+	{"memcpy", offsetFlags, flagMemcpy, true},
+	{"bitshuffle", offsetFlags, flagBitShuffle, true},
+	{"reserved", offsetFlags, flagReserved, false},
+	{"dontsplit", offsetFlags, flagDontSplit, true},
+	{"codec", offsetFlags, 0x7 << flagCodecShift, true},
+	{"dict", offsetBlosc2Flags, blosc2FlagDict, true},
+	{"bigendian", offsetBlosc2Flags, blosc2FlagBigEndian, true},
+	{"checksums", offsetBlosc2Flags, blosc2FlagSums, true},
+	{"reserved", offsetBlosc2Flags, blosc2FlagReserved, false},
+	{"special", offsetBlosc2Flags, blosc2SpecialMask << blosc2SpecialShift, true},
+	{"instrumented", offsetBlosc2Flags, blosc2FlagInstr, false},
+}
+
+// HeaderFlags returns the flags of chunk headers this package knows, in
+// header order, and whether it decodes chunks that set them. Writers can
+// check it before relying on a feature, and readers compare it against what
+// a chunk sets with Header.UnsupportedFlags.
+func HeaderFlags() []HeaderFlag {
+	return append([]HeaderFlag(nil), headerFlags...)
+}
+
+// UnsupportedFlags returns the flags h sets that this package cannot decode
+// chunks with, or nil if it sets none. Blosc2Flags count only in an
+// extended header.
+func (h *Header) UnsupportedFlags() []HeaderFlag {
+	var flags []HeaderFlag
+	for _, f := range headerFlags {
+		b := h.Flags
+		if f.Offset == offsetBlosc2Flags {
+			b = h.Blosc2Flags
+		}
+		if !f.Supported && b&f.Mask != 0 {
+			flags = append(flags, f)
+		}
+	}
+	return flags
+}
+
+// checkFeatures reports the first flag h sets that chunks cannot be decoded
+// with, as ErrUnsupportedFeature
+func checkFeatures(h *Header) error {
+	if flags := h.UnsupportedFlags(); flags != nil {
+		return headerError(flags[0].Offset, unsupportedFlag(flags[0]))
+	}
+	return nil
+}
+
+func unsupportedFlag(f HeaderFlag) error {
+	return fmt.Errorf("%w: %s flag 0x%02x of header byte %d", ErrUnsupportedFeature, f.Name, f.Mask, f.Offset)
+}
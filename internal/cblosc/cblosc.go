@@ -0,0 +1,81 @@
+//go:build cgo && (cblosc || cgo_blosc)
+
+// Package cblosc wraps the c-blosc library, for the cgo_blosc backend and
+// for differential tests. It needs c-blosc 1.17 or later.
+package cblosc
+
+/*
+#cgo LDFLAGS: -lblosc
+#include <stdlib.h>
+#include <blosc.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+)
+
+// Compress compresses src with c-blosc using the named compressor. shuffle
+// takes the c-blosc values: 0 no shuffle, 1 byte shuffle, 2 bit shuffle.
+func Compress(src []byte, compressor string, level, shuffle, typeSize int) ([]byte, error) {
+	return CompressBlocks(src, compressor, level, shuffle, typeSize, 0, 1)
+}
+
+// CompressBlocks is Compress with a block size, 0 to let c-blosc choose, and
+// the number of threads c-blosc compresses with.
+func CompressBlocks(src []byte, compressor string, level, shuffle, typeSize, blockSize, threads int) ([]byte, error) {
+	if len(src) == 0 {
+		return nil, errors.New("cblosc: empty input")
+	}
+	cname := C.CString(compressor)
+	defer C.free(unsafe.Pointer(cname))
+
+	dst := make([]byte, len(src)+C.BLOSC_MAX_OVERHEAD)
+	n := C.blosc_compress_ctx(C.int(level), C.int(shuffle), C.size_t(typeSize),
+		C.size_t(len(src)), unsafe.Pointer(&src[0]),
+		unsafe.Pointer(&dst[0]), C.size_t(len(dst)), cname, C.size_t(blockSize), C.int(threads))
+	if n <= 0 {
+		return nil, fmt.Errorf("cblosc: blosc_compress_ctx returned %d", int(n))
+	}
+	return dst[:n], nil
+}
+
+// Decompress decompresses a Blosc chunk with c-blosc.
+func Decompress(src []byte) ([]byte, error) {
+	if len(src) < C.BLOSC_MIN_HEADER_LENGTH {
+		return nil, errors.New("cblosc: chunk shorter than header")
+	}
+	var nbytes, cbytes, blocksize C.size_t
+	C.blosc_cbuffer_sizes(unsafe.Pointer(&src[0]), &nbytes, &cbytes, &blocksize)
+	if int(cbytes) > len(src) {
+		return nil, fmt.Errorf("cblosc: chunk claims %d bytes, have %d", int(cbytes), len(src))
+	}
+	if nbytes == 0 {
+		return []byte{}, nil
+	}
+
+	dst := make([]byte, int(nbytes))
+	if err := DecompressInto(dst, src, 1); err != nil {
+		return nil, err
+	}
+	return dst, nil
+}
+
+// DecompressInto decompresses a Blosc chunk with c-blosc into dst, which must
+// be exactly as long as the chunk's original data, using threads threads.
+func DecompressInto(dst, src []byte, threads int) error {
+	if len(src) < C.BLOSC_MIN_HEADER_LENGTH || len(dst) == 0 {
+		return errors.New("cblosc: chunk shorter than header or empty output")
+	}
+	var nbytes C.size_t
+	if C.blosc_cbuffer_validate(unsafe.Pointer(&src[0]), C.size_t(len(src)), &nbytes) < 0 || int(nbytes) != len(dst) {
+		return errors.New("cblosc: c-blosc rejected the chunk header")
+	}
+	n := C.blosc_decompress_ctx(unsafe.Pointer(&src[0]), unsafe.Pointer(&dst[0]), C.size_t(len(dst)), C.int(threads))
+	if n < 0 || int(n) != len(dst) {
+		return fmt.Errorf("cblosc: blosc_decompress_ctx returned %d", int(n))
+	}
+	return nil
+}
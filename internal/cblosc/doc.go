@@ -0,0 +1,3 @@
+// Package cblosc wraps the C Blosc library for differential tests. It is only
+// built with the cblosc build tag and needs cgo, blosc.h and libblosc.
+package cblosc
@@ -0,0 +1,232 @@
+package blosc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// ErrNoFixtures is returned by LoadFixtures for a directory holding no
+// fixtures
+var ErrNoFixtures = errors.New("blosc: no fixtures")
+
+// Fixture is a chunk written by another Blosc implementation, such as the
+// c-blosc corpus under testdata/interop, as LoadFixtures reads it
+type Fixture struct {
+	Name     string // File name without the .blosc suffix, such as "zstd-bitshuffle-ts4-bs1024"
+	Codec    Codec
+	Shuffle  Shuffle
+	TypeSize int
+	Variant  string // What follows the type size, such as "bs1024" or "nosplit", or ""
+	Chunk    []byte
+}
+
+// LoadFixtures reads the fixtures in dir, files named
+// <codec>-<shuffle>-ts<typesize>[-<variant>].blosc, in name order. A
+// directory that is missing or holds none fails with ErrNoFixtures, and a
+// file named otherwise with an error naming it.
+func LoadFixtures(dir string) ([]Fixture, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.blosc"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("%w in %s", ErrNoFixtures, dir)
+	}
+	slices.Sort(paths)
+	fixtures := make([]Fixture, 0, len(paths))
+	for _, path := range paths {
+		f, err := parseFixtureName(strings.TrimSuffix(filepath.Base(path), ".blosc"))
+		if err != nil {
+			return nil, err
+		}
+		if f.Chunk, err = os.ReadFile(path); err != nil {
+			return nil, err
+		}
+		fixtures = append(fixtures, f)
+	}
+	return fixtures, nil
+}
+
+// parseFixtureName splits a fixture name into its codec, shuffle, type size
+// and variant
+func parseFixtureName(name string) (Fixture, error) {
+	f := Fixture{Name: name}
+	parts := strings.SplitN(name, "-", 4)
+	if len(parts) < 3 || !strings.HasPrefix(parts[2], "ts") {
+		return f, fmt.Errorf("blosc: fixture %q is not named <codec>-<shuffle>-ts<typesize>[-<variant>]", name)
+	}
+	codec, err := ParseCodec(parts[0])
+	if err != nil {
+		return f, fmt.Errorf("blosc: fixture %q: %w", name, err)
+	}
+	f.Codec = codec
+	shuffle := slices.IndexFunc(fixtureShuffles, func(s Shuffle) bool { return s.String() == parts[1] })
+	if shuffle < 0 {
+		return f, fmt.Errorf("blosc: fixture %q: unknown shuffle %q", name, parts[1])
+	}
+	f.Shuffle = fixtureShuffles[shuffle]
+	typeSize, err := strconv.Atoi(parts[2][2:])
+	if err != nil || typeSize < 1 {
+		return f, fmt.Errorf("blosc: fixture %q: bad type size %q", name, parts[2])
+	}
+	f.TypeSize = typeSize
+	if len(parts) == 4 {
+		f.Variant = parts[3]
+	}
+	return f, nil
+}
+
+// fixtureShuffles are the shuffles fixture names use, in report order
+var fixtureShuffles = []Shuffle{NoShuffle, Shuffle1, BitShuffle}
+
+// InteropPayload returns the data every chunk under testdata/interop
+// decompresses to: 1024 little-endian uint32 values i*4 plus a little
+// noise, followed by the bytes 01 02 03. testdata/interop/generate_cblosc.py
+// builds the same bytes.
+func InteropPayload() []byte {
+	const n = 1024
+	data := make([]byte, 4*n+3)
+	x := uint32(1)
+	for i := 0; i < n; i++ {
+		x = x*1103515245 + 12345
+		binary.LittleEndian.PutUint32(data[4*i:], uint32(i)*4+(x>>16)&3)
+	}
+	copy(data[4*n:], []byte{1, 2, 3})
+	return data
+}
+
+// FixtureResult is the outcome of checking one fixture
+type FixtureResult struct {
+	Fixture Fixture
+	Skipped bool  // The fixture's codec is not registered, as in a blosc_nozstd build
+	Err     error // Why the fixture failed, or nil
+}
+
+// FixtureReport is the outcome of checking a fixture corpus, as
+// CheckFixtures returns it. Its String method gives a compatibility table of
+// passing fixtures by codec and shuffle, followed by each failure.
+type FixtureReport struct {
+	Results []FixtureResult
+}
+
+// CheckFixtures checks that each fixture validates and decompresses to
+// payload, and that its header reports the shuffle and type size its name
+// gives. Fixtures whose codec is not registered are skipped.
+func CheckFixtures(fixtures []Fixture, payload []byte) FixtureReport {
+	report := FixtureReport{Results: make([]FixtureResult, 0, len(fixtures))}
+	for _, f := range fixtures {
+		res := FixtureResult{Fixture: f}
+		if _, ok := GetCodec(f.Codec); ok {
+			res.Err = checkFixture(f, payload)
+		} else {
+			res.Skipped = true
+		}
+		report.Results = append(report.Results, res)
+	}
+	return report
+}
+
+// checkFixture checks one fixture for CheckFixtures
+func checkFixture(f Fixture, payload []byte) error {
+	header, err := ParseHeader(f.Chunk)
+	if err != nil {
+		return err
+	}
+	if int(header.TypeSize) != f.TypeSize {
+		return fmt.Errorf("header type size %d", header.TypeSize)
+	}
+	// Memcpy chunks and single bytes are stored as they are, whatever the
+	// shuffle asked for
+	if !header.IsMemcpy() && f.TypeSize > 1 && header.ShuffleMode() != f.Shuffle {
+		return fmt.Errorf("header shuffle %s", header.ShuffleMode())
+	}
+	if err := Validate(f.Chunk); err != nil {
+		return err
+	}
+	got, err := Decompress(f.Chunk)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(got, payload) {
+		return fmt.Errorf("decompressed %d bytes that differ from the %d-byte payload", len(got), len(payload))
+	}
+	return nil
+}
+
+// Failed returns the results of the fixtures that failed
+func (r FixtureReport) Failed() []FixtureResult {
+	var failed []FixtureResult
+	for _, res := range r.Results {
+		if res.Err != nil {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
+
+// String formats r as a table of passing fixtures out of those checked, by
+// codec and shuffle, followed by one line per failure. Skipped fixtures
+// count as "skip".
+func (r FixtureReport) String() string {
+	type cell struct{ passed, checked, skipped int }
+	cells := make(map[Codec]map[Shuffle]*cell)
+	var codecIDs []Codec
+	for _, res := range r.Results {
+		row, ok := cells[res.Fixture.Codec]
+		if !ok {
+			row = make(map[Shuffle]*cell)
+			cells[res.Fixture.Codec] = row
+			codecIDs = append(codecIDs, res.Fixture.Codec)
+		}
+		c, ok := row[res.Fixture.Shuffle]
+		if !ok {
+			c = new(cell)
+			row[res.Fixture.Shuffle] = c
+		}
+		switch {
+		case res.Skipped:
+			c.skipped++
+		case res.Err == nil:
+			c.passed++
+			c.checked++
+		default:
+			c.checked++
+		}
+	}
+	slices.Sort(codecIDs)
+
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprint(w, "codec\t")
+	for _, s := range fixtureShuffles {
+		fmt.Fprintf(w, "%s\t", s)
+	}
+	fmt.Fprintln(w)
+	for _, id := range codecIDs {
+		fmt.Fprintf(w, "%s\t", id)
+		for _, s := range fixtureShuffles {
+			switch c := cells[id][s]; {
+			case c == nil:
+				fmt.Fprint(w, "-\t")
+			case c.checked == 0:
+				fmt.Fprint(w, "skip\t")
+			default:
+				fmt.Fprintf(w, "%d/%d\t", c.passed, c.checked)
+			}
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+	for _, res := range r.Failed() {
+		fmt.Fprintf(&b, "%s: %v\n", res.Fixture.Name, res.Err)
+	}
+	return b.String()
+}
@@ -0,0 +1,73 @@
+//go:build cgo && (cblosc || cgo_blosc)
+
+package blosc
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/mrjoshuak/go-blosc/internal/cblosc"
+)
+
+// Differential tests against c-blosc. Run with: go test -tags cblosc -run CBlosc .
+// The cgo_blosc tag also runs them, against the libblosc backend.
+
+var interopCodecs = []Codec{BloscLZ, LZ4, LZ4HC, Snappy, ZLIB, ZSTD}
+
+func TestCBloscDecompressesGoChunks(t *testing.T) {
+	inputs := map[string][]byte{
+		"payload":    InteropPayload(),
+		"large":      makeTestData(300000),
+		"small":      makeTestData(100),
+		"random-ish": makeTestDataPure(70001),
+	}
+
+	for _, codec := range interopCodecs {
+		for _, shuffle := range []Shuffle{NoShuffle, Shuffle1, BitShuffle} {
+			for _, typeSize := range []int{1, 2, 4, 8, 16, 20} {
+				for inputName, data := range inputs {
+					name := fmt.Sprintf("%s-%s-ts%d-%s", codec, shuffle, typeSize, inputName)
+					t.Run(name, func(t *testing.T) {
+						compressed, err := Compress(data, codec, 5, shuffle, typeSize)
+						if err != nil {
+							t.Fatalf("compress failed: %v", err)
+						}
+						got, err := cblosc.Decompress(compressed)
+						if err != nil {
+							t.Fatalf("c-blosc rejected chunk: %v", err)
+						}
+						if !bytes.Equal(got, data) {
+							t.Error("c-blosc decoded different data")
+						}
+					})
+				}
+			}
+		}
+	}
+}
+
+func TestGoDecompressesCBloscChunks(t *testing.T) {
+	data := makeTestData(300000)
+
+	for _, codec := range interopCodecs {
+		for _, shuffle := range []Shuffle{NoShuffle, Shuffle1, BitShuffle} {
+			for _, typeSize := range []int{1, 2, 4, 8, 16, 20} {
+				name := fmt.Sprintf("%s-%s-ts%d", codec, shuffle, typeSize)
+				t.Run(name, func(t *testing.T) {
+					compressed, err := cblosc.Compress(data, codec.String(), 5, int(shuffle), typeSize)
+					if err != nil {
+						t.Skipf("c-blosc cannot compress: %v", err)
+					}
+					got, err := Decompress(compressed)
+					if err != nil {
+						t.Fatalf("decompress failed: %v", err)
+					}
+					if !bytes.Equal(got, data) {
+						t.Error("data mismatch")
+					}
+				})
+			}
+		}
+	}
+}
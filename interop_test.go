@@ -0,0 +1,123 @@
+package blosc
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestInteropGoBlosc10 decodes chunks written by go-blosc v1.0.2
+func TestInteropGoBlosc10(t *testing.T) {
+	testInteropFixtures(t, "go-blosc-1.0", true)
+}
+
+// TestInteropCBlosc decodes chunks written by c-blosc
+func TestInteropCBlosc(t *testing.T) {
+	testInteropFixtures(t, "c-blosc", false)
+}
+
+func testInteropFixtures(t *testing.T, dir string, legacy bool) {
+	fixtures, err := LoadFixtures(filepath.Join("testdata", "interop", dir))
+	if errors.Is(err, ErrNoFixtures) {
+		t.Skipf("no fixtures in testdata/interop/%s (see testdata/interop/README.md)", dir)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := CheckFixtures(fixtures, InteropPayload())
+	for _, res := range report.Results {
+		t.Run(res.Fixture.Name, func(t *testing.T) {
+			if res.Skipped {
+				t.Skipf("%s is not registered", res.Fixture.Codec)
+			}
+			if res.Err != nil {
+				t.Fatal(res.Err)
+			}
+
+			header, err := ParseHeader(res.Fixture.Chunk)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Legacy LZ4 memcpy chunks are read as spec BloscLZ chunks,
+			// whose layout they share
+			wantLegacy := legacy && !(header.IsMemcpy() && header.VersionLZ == codecFormatVersion)
+			if header.IsLegacy() != wantLegacy {
+				t.Errorf("IsLegacy: got %v, want %v", header.IsLegacy(), wantLegacy)
+			}
+
+			// Spec chunks cannot tell LZ4HC from LZ4
+			wantCodec := res.Fixture.Codec
+			if !legacy && wantCodec == LZ4HC {
+				wantCodec = LZ4
+			}
+			if got := header.Codec(); got != wantCodec && header.IsLegacy() == legacy {
+				t.Errorf("Codec: got %s, want %s", got, wantCodec)
+			}
+		})
+	}
+	t.Logf("%s fixtures:\n%s", dir, report)
+}
+
+func TestLoadFixtures(t *testing.T) {
+	fixtures, err := LoadFixtures(filepath.Join("testdata", "interop", "go-blosc-1.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) != 60 {
+		t.Errorf("loaded %d fixtures, want 60", len(fixtures))
+	}
+	f := fixtures[0]
+	if f.Name != "lz4-bitshuffle-ts1" || f.Codec != LZ4 || f.Shuffle != BitShuffle || f.TypeSize != 1 || f.Variant != "" {
+		t.Errorf("first fixture parsed as %+v", f)
+	}
+
+	dir := t.TempDir()
+	if _, err := LoadFixtures(dir); !errors.Is(err, ErrNoFixtures) {
+		t.Errorf("empty directory: got %v, want ErrNoFixtures", err)
+	}
+	if _, err := LoadFixtures(filepath.Join(dir, "missing")); !errors.Is(err, ErrNoFixtures) {
+		t.Errorf("missing directory: got %v, want ErrNoFixtures", err)
+	}
+	for _, name := range []string{"lz4.blosc", "lz5-shuffle-ts4.blosc", "lz4-twist-ts4.blosc", "lz4-shuffle-ts0.blosc"} {
+		sub := filepath.Join(dir, strings.TrimSuffix(name, ".blosc"))
+		if err := os.MkdirAll(sub, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(sub, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadFixtures(sub); err == nil || errors.Is(err, ErrNoFixtures) {
+			t.Errorf("%s: got %v", name, err)
+		}
+	}
+}
+
+func TestCheckFixtures(t *testing.T) {
+	fixtures, err := LoadFixtures(filepath.Join("testdata", "interop", "go-blosc-1.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := CheckFixtures(fixtures, InteropPayload())
+	if failed := report.Failed(); len(failed) != 0 {
+		t.Errorf("%d fixtures failed:\n%s", len(failed), report)
+	}
+	for _, res := range report.Results {
+		_, registered := GetCodec(res.Fixture.Codec)
+		if res.Skipped == registered {
+			t.Errorf("%s: skipped %v with the codec registered %v", res.Fixture.Name, res.Skipped, registered)
+		}
+	}
+	if s := report.String(); !strings.Contains(s, "lz4hc") || !strings.Contains(s, "4/4") {
+		t.Errorf("report:\n%s", s)
+	}
+
+	f := fixtures[0]
+	f.Chunk = f.Chunk[:len(f.Chunk)-1]
+	report = CheckFixtures([]Fixture{f}, InteropPayload())
+	if len(report.Failed()) != 1 || !strings.Contains(report.String(), "lz4-bitshuffle-ts1: ") {
+		t.Errorf("truncated fixture passed:\n%s", report)
+	}
+}
@@ -0,0 +1,104 @@
+package blosc
+
+import "fmt"
+
+// GetItems decompresses nitems elements of the chunk in data, starting at
+// element start, as blosc_getitem does in c-blosc. Elements are TypeSize
+// bytes as the header gives it. Only the blocks holding the elements are
+// decoded, and in chunks written with Options.BlockChecksums only those
+// blocks are verified, so reading a few elements of a large chunk costs the
+// blocks they span rather than the whole chunk. Legacy go-blosc chunks are a
+// single block and are decoded whole.
+func GetItems(data []byte, start, nitems int) ([]byte, error) {
+	return getItems(data, start, nitems, nil)
+}
+
+// getItems implements GetItems for filters that need env
+func getItems(data []byte, start, nitems int, env *filterEnv) ([]byte, error) {
+	if len(data) < HeaderSize {
+		return nil, headerError(0, ErrInvalidHeader)
+	}
+	header, typeSize, err := checkChunk(data, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkChunkDelta(header, env); err != nil {
+		return nil, err
+	}
+	size := max(int(header.TypeSize), 1)
+	count := int(header.NBytesOrig) / size
+	if start < 0 || nitems < 0 || start > count || nitems > count-start {
+		return nil, fmt.Errorf("%w: items %d to %d of a chunk of %d", ErrInvalidData, start, start+nitems, count)
+	}
+	lo, hi := start*size, (start+nitems)*size
+	out := make([]byte, hi-lo)
+	if lo == hi {
+		return out, nil
+	}
+	chunk := data[:header.NBytesComp]
+
+	switch {
+	case header.IsMemcpy():
+		payload := chunk[header.Size():]
+		if len(payload) != int(header.NBytesOrig) {
+			return nil, blockError(StageCodec, -1, header.Size(), fmt.Errorf("%w: got %d, expected %d", ErrSizeMismatch, len(payload), header.NBytesOrig))
+		}
+		copy(out, payload[lo:hi])
+	case header.Special() != SpecialNone:
+		// Elements repeat, and the range starts at one
+		if err := decodeSpecial(header, chunk, out, nil); err != nil {
+			return nil, err
+		}
+	case header.legacy:
+		whole, err := decompressBackend(nil, data, 0, -1, env)
+		if err != nil {
+			return nil, err
+		}
+		copy(out, whole[lo:hi])
+	default:
+		d, err := newBlockDecoder(header, chunk, typeSize, env)
+		if err != nil {
+			return nil, err
+		}
+		if err := d.checkTable(); err != nil {
+			return nil, err
+		}
+		block := make([]byte, d.bufSize)
+		for i := lo / d.blockSize; i*d.blockSize < hi; i++ {
+			offset, n := i*d.blockSize, d.blockLen(i)
+			if err := d.decode(i, block[:n]); err != nil {
+				return nil, err
+			}
+			copy(out[max(offset-lo, 0):], block[max(lo-offset, 0):min(n, hi-offset)])
+		}
+	}
+	return out, nil
+}
+
+// GetItems decompresses nitems elements of chunk i, starting at element
+// start, decoding only the blocks that hold them, as the package-level
+// GetItems does. For a frame opened with OpenFrameReaderAt, SetStoredCache
+// keeps the chunk's bytes so that further reads of it fetch nothing. Chunks
+// that SChunk.SetChunkDelta stored as deltas are decompressed whole.
+func (f *Frame) GetItems(i, start, nitems int) ([]byte, error) {
+	chunk, err := f.Chunk(i)
+	if err != nil {
+		return nil, err
+	}
+	header, err := ParseHeader(chunk)
+	if err != nil || !isChunkDelta(header) {
+		return getItems(chunk, start, nitems, f.env)
+	}
+
+	// Delta chunks are decoded whole, against the chunks before them
+	size := max(int(header.TypeSize), 1)
+	count := int(header.NBytesOrig) / size
+	if start < 0 || nitems < 0 || start > count || nitems > count-start {
+		return nil, fmt.Errorf("%w: items %d to %d of a chunk of %d", ErrInvalidData, start, start+nitems, count)
+	}
+	data, err := f.DecompressChunk(i)
+	if err != nil {
+		return nil, err
+	}
+	return data[start*size : (start+nitems)*size], nil
+}
@@ -0,0 +1,66 @@
+package blosc
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestGetItems(t *testing.T) {
+	data := makeTestData(40000)
+	chunks := map[string]Options{
+		"spec":      {Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: 4, BlockSize: 4096},
+		"checksums": {Codec: ZSTD, Level: 3, Shuffle: BitShuffle, TypeSize: 4, BlockSize: 4096, BlockChecksums: true},
+		"legacy":    {Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: 4, LegacyFormat: true},
+		"memcpy":    {Codec: LZ4, Level: 0, TypeSize: 4},
+	}
+	for name, opts := range chunks {
+		chunk, err := CompressWithOptions(data, opts)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		count := len(data) / 4
+		for _, r := range [][2]int{{0, 0}, {0, 1}, {0, count}, {1000, 1}, {1023, 2}, {1000, 3000}, {count - 1, 1}, {count, 0}} {
+			got, err := GetItems(chunk, r[0], r[1])
+			if err != nil {
+				t.Fatalf("%s: items %d+%d: %v", name, r[0], r[1], err)
+			}
+			if want := data[4*r[0] : 4*(r[0]+r[1])]; !bytes.Equal(got, want) {
+				t.Errorf("%s: items %d+%d differ", name, r[0], r[1])
+			}
+		}
+		for _, r := range [][2]int{{-1, 1}, {0, -1}, {count, 1}, {count + 1, 0}, {1, count}} {
+			if _, err := GetItems(chunk, r[0], r[1]); !errors.Is(err, ErrInvalidData) {
+				t.Errorf("%s: items %d+%d: got %v, want ErrInvalidData", name, r[0], r[1], err)
+			}
+		}
+	}
+
+	// Special-value chunks repeat their value
+	value := []byte{1, 2, 3, 4}
+	chunk, err := NewValueChunk(4000, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := GetItems(chunk, 10, 3)
+	if err != nil || !bytes.Equal(got, bytes.Repeat(value, 3)) {
+		t.Errorf("repeated value: got %v, %v", got, err)
+	}
+
+	if _, err := GetItems(chunk[:HeaderSize-1], 0, 1); !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("short chunk: got %v, want ErrInvalidHeader", err)
+	}
+}
+
+func BenchmarkGetItems(b *testing.B) {
+	data := makeTestData(1 << 20)
+	chunk, err := CompressWithOptions(data, Options{Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: 4, BlockSize: 16 << 10})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, _ = GetItems(chunk, 100000, 16)
+	}
+}
@@ -0,0 +1,56 @@
+package blosc
+
+import "iter"
+
+// Chunks returns an iterator over the index and compressed bytes of each
+// chunk, as Chunk returns them:
+//
+//	for i, chunk := range s.Chunks() {
+//		...
+//	}
+//
+// Iteration stops early at a chunk that cannot be read, such as one of an
+// encrypted SChunk without keys; Chunk with the next index gives the error.
+func (s *SChunk) Chunks() iter.Seq2[int, []byte] {
+	return eachChunk(s.NumChunks, s.Chunk)
+}
+
+// DecompressedChunks returns an iterator over the index and decompressed
+// data of each chunk, as DecompressChunk returns it. Each chunk is
+// decompressed only when the loop reaches it, so breaking out early skips
+// the rest. Iteration stops early at a chunk that cannot be decompressed;
+// DecompressChunk with the next index gives the error.
+func (s *SChunk) DecompressedChunks() iter.Seq2[int, []byte] {
+	return eachChunk(s.NumChunks, s.DecompressChunk)
+}
+
+// Chunks returns an iterator over the index and compressed bytes of each
+// chunk, as Chunk returns them. Iteration stops early at a chunk that
+// cannot be read, such as one of an encrypted frame without keys; Chunk
+// with the next index gives the error.
+func (f *Frame) Chunks() iter.Seq2[int, []byte] {
+	return eachChunk(f.NumChunks, f.Chunk)
+}
+
+// DecompressedChunks returns an iterator over the index and decompressed
+// data of each chunk, as DecompressChunk returns it, decompressing each
+// chunk only when the loop reaches it. Iteration stops early at a chunk
+// that cannot be decompressed; DecompressChunk with the next index gives
+// the error.
+func (f *Frame) DecompressedChunks() iter.Seq2[int, []byte] {
+	return eachChunk(f.NumChunks, f.DecompressChunk)
+}
+
+// eachChunk returns an iterator over get(i) for i below n(), which is
+// called again for each index so that chunks appended during iteration are
+// included
+func eachChunk(n func() int, get func(int) ([]byte, error)) iter.Seq2[int, []byte] {
+	return func(yield func(int, []byte) bool) {
+		for i := 0; i < n(); i++ {
+			chunk, err := get(i)
+			if err != nil || !yield(i, chunk) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package blosc
+
+import (
+	"bytes"
+	"errors"
+	"iter"
+	"testing"
+)
+
+func TestChunkIterators(t *testing.T) {
+	sc, want := makeSChunk(t, 4)
+	f, err := OpenFrame(mustFrameBytes(t, sc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, seq := range map[string]iter.Seq2[int, []byte]{
+		"SChunk.DecompressedChunks": sc.DecompressedChunks(),
+		"Frame.DecompressedChunks":  f.DecompressedChunks(),
+	} {
+		count := 0
+		for i, data := range seq {
+			if i != count || !bytes.Equal(data, want[i]) {
+				t.Errorf("%s: chunk %d differs", name, i)
+			}
+			count++
+		}
+		if count != len(want) {
+			t.Errorf("%s: %d chunks, want %d", name, count, len(want))
+		}
+	}
+	for i, chunk := range sc.Chunks() {
+		if stored, _ := sc.Chunk(i); !bytes.Equal(chunk, stored) {
+			t.Errorf("SChunk.Chunks: chunk %d differs", i)
+		}
+	}
+	count := 0
+	for i, chunk := range f.Chunks() {
+		if data, err := Decompress(chunk); err != nil || !bytes.Equal(data, want[i]) {
+			t.Errorf("Frame.Chunks: chunk %d: %v", i, err)
+		}
+		count++
+	}
+	if count != 4 {
+		t.Errorf("Frame.Chunks: %d chunks", count)
+	}
+
+	// Breaking out stops decompressing
+	var visited []int
+	for i := range sc.DecompressedChunks() {
+		visited = append(visited, i)
+		if i == 1 {
+			break
+		}
+	}
+	if len(visited) != 2 {
+		t.Errorf("visited %v after break", visited)
+	}
+
+	// An encrypted frame without keys yields nothing, and Chunk tells why
+	if err := sc.SetEncryption("k1", testKeys); err != nil {
+		t.Fatal(err)
+	}
+	locked, err := OpenFrame(mustFrameBytes(t, sc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range locked.DecompressedChunks() {
+		t.Errorf("locked frame yielded chunk %d", i)
+	}
+	if _, err := locked.Chunk(0); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("locked frame: %v", err)
+	}
+}
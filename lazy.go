@@ -0,0 +1,126 @@
+package blosc
+
+// LazyChunk is a handle to a chunk of an SChunk that parses the chunk header
+// up front and decompresses only when Bytes is called, so metadata can be
+// scanned cheaply. For SChunks backed by a frame file only the header is
+// read until then, unless the chunk is encrypted; a ChunkStore is asked for
+// the whole chunk. A handle refers to the chunk it was created for even if
+// later edits change the chunk indexes, until a commit deletes the chunk
+// from a ChunkStore.
+type LazyChunk struct {
+	sc     *SChunk
+	stored storedChunk
+	chunk  []byte // Compressed chunk, once loaded
+	header Header
+	pinned bool
+	data   []byte // Decompressed data, kept while pinned
+}
+
+// LazyChunk returns a handle to chunk i.
+func (s *SChunk) LazyChunk(i int) (*LazyChunk, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if err := s.checkIndex(i, len(s.chunks)); err != nil {
+		return nil, err
+	}
+	c := &LazyChunk{sc: s, stored: s.chunks[i]}
+
+	var head []byte
+	switch {
+	case s.cipher != nil || s.lockedKey != "":
+		chunk, err := s.chunk(i)
+		if err != nil {
+			return nil, err
+		}
+		c.chunk, head = chunk, chunk
+	case c.stored.data != nil:
+		c.chunk, head = c.stored.data, c.stored.data
+	default:
+		// A plain chunk in a file only needs its header read
+		n := c.stored.length
+		if n > ExtendedHeaderSize {
+			n = ExtendedHeaderSize
+		}
+		var err error
+		if head, err = s.backing.read(c.stored.offset, n); err != nil {
+			return nil, err
+		}
+	}
+
+	header, err := ParseHeader(head)
+	if err != nil {
+		return nil, headerError(0, err)
+	}
+	c.header = *header
+	return c, nil
+}
+
+// Header returns the chunk header.
+func (c *LazyChunk) Header() *Header {
+	return &c.header
+}
+
+// NBytes returns the uncompressed size of the chunk.
+func (c *LazyChunk) NBytes() int {
+	return int(c.header.NBytesOrig)
+}
+
+// CBytes returns the compressed size of the chunk.
+func (c *LazyChunk) CBytes() int {
+	return int(c.header.NBytesComp)
+}
+
+// Chunk returns the compressed chunk, reading it if needed.
+func (c *LazyChunk) Chunk() ([]byte, error) {
+	if c.chunk != nil {
+		return c.chunk, nil
+	}
+	c.sc.mu.RLock()
+	chunk, err := c.sc.backing.read(c.stored.offset, c.stored.length)
+	c.sc.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	c.chunk = chunk
+	return chunk, nil
+}
+
+// Bytes decompresses the chunk. Unless the handle is pinned, every call
+// decompresses again and the result is not retained.
+func (c *LazyChunk) Bytes() ([]byte, error) {
+	if c.data != nil {
+		return c.data, nil
+	}
+	chunk, err := c.Chunk()
+	if err != nil {
+		return nil, err
+	}
+	c.sc.mu.RLock()
+	env := c.sc.enc.env
+	c.sc.mu.RUnlock()
+	data, err := decompressBackend(nil, chunk, 0, -1, env)
+	if err != nil {
+		return nil, err
+	}
+	if c.pinned {
+		c.data = data
+	}
+	return data, nil
+}
+
+// Pin makes the handle keep the data the next call to Bytes decompresses,
+// so later calls return it directly.
+func (c *LazyChunk) Pin() {
+	c.pinned = true
+}
+
+// Unpin releases the data kept by a pinned handle.
+func (c *LazyChunk) Unpin() {
+	c.pinned = false
+	c.data = nil
+}
+
+// Pinned reports whether the handle is pinned.
+func (c *LazyChunk) Pinned() bool {
+	return c.pinned
+}
@@ -0,0 +1,91 @@
+package blosc
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestLazyChunk(t *testing.T) {
+	sc, want := makeSChunk(t, 3)
+	c, err := sc.LazyChunk(1)
+	if err != nil {
+		t.Fatalf("LazyChunk failed: %v", err)
+	}
+	chunk, _ := sc.Chunk(1)
+	if c.NBytes() != len(want[1]) || c.CBytes() != len(chunk) || c.Header().Codec() != LZ4 {
+		t.Errorf("handle reports %d/%d bytes, codec %v", c.NBytes(), c.CBytes(), c.Header().Codec())
+	}
+
+	a, err := c.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes failed: %v", err)
+	}
+	b, _ := c.Bytes()
+	if !bytes.Equal(a, want[1]) || &a[0] == &b[0] {
+		t.Error("unpinned handle should decompress on every call")
+	}
+
+	c.Pin()
+	a, _ = c.Bytes()
+	b, _ = c.Bytes()
+	if !c.Pinned() || &a[0] != &b[0] {
+		t.Error("pinned handle should keep its data")
+	}
+	c.Unpin()
+	if b, _ = c.Bytes(); c.Pinned() || &a[0] == &b[0] {
+		t.Error("unpinned handle kept its data")
+	}
+
+	if _, err := sc.LazyChunk(3); !errors.Is(err, ErrChunkIndex) {
+		t.Errorf("expected ErrChunkIndex, got %v", err)
+	}
+}
+
+func TestLazyChunkFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lazy.gbframe")
+	sc, err := CreateSChunkFile(path, Options{Codec: ZSTD, Level: 5, Filters: [MaxFilters]FilterStage{{Filter: FilterShuffle}}, TypeSize: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sc.Close()
+	want := [][]byte{makeFloatData(1000), makeFloatData(2000)}
+	for _, data := range want {
+		if _, err := sc.AppendBuffer(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c, err := sc.LazyChunk(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := c.Header(); !h.IsExtended() || c.NBytes() != len(want[1]) {
+		t.Errorf("unexpected header %+v", h)
+	}
+
+	// The handle keeps referring to its chunk after the indexes shift
+	if err := sc.DeleteChunk(0); err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes failed: %v", err)
+	}
+	if !bytes.Equal(got, want[1]) {
+		t.Error("lazy chunk does not round trip")
+	}
+
+	// Encrypted chunks are decrypted to read the header
+	if err := sc.SetEncryption("k1", testKeys); err != nil {
+		t.Fatal(err)
+	}
+	c, err = sc.LazyChunk(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := c.Bytes(); err != nil || !bytes.Equal(got, want[1]) {
+		t.Errorf("encrypted lazy chunk: %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package blosc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// This file holds the go-blosc 1.0.x chunk layout: a 16-byte header with the
+// Codec ID in VersionLZ, followed directly by the codec output for the whole
+// (byte or bit shuffled) input. It is kept so that chunks written by those
+// releases stay readable and, with Options.LegacyFormat, can still be written.
+
+// compressLegacy compresses data as a single block in the go-blosc 1.0.x layout
+func compressLegacy(data []byte, opts Options, compressor CodecInterface) ([]byte, error) {
+	header := Header{
+		Version:    FormatVersion,
+		VersionLZ:  uint8(opts.Codec),
+		Flags:      shuffleFlags(opts.Shuffle),
+		TypeSize:   uint8(opts.TypeSize),
+		NBytesOrig: uint32(len(data)),
+		BlockSize:  uint32(len(data)), // Single block
+	}
+
+	// Level 0 stores the input as it is. The shuffle flags are dropped
+	// because 1.0.x readers unshuffle memcpy chunks.
+	if opts.Level == 0 {
+		header.Flags = flagMemcpy
+		return appendMemcpy(header, data), nil
+	}
+
+	// Apply shuffle preprocessing
+	shuffled := data
+	if opts.Shuffle == Shuffle1 && opts.TypeSize > 1 {
+		shuffled = shuffleBytes(data, opts.TypeSize)
+	} else if opts.Shuffle == BitShuffle && opts.TypeSize > 1 {
+		shuffled = bytes.Clone(data)
+		legacyBitTranspose(shuffled, opts.TypeSize, false)
+	}
+
+	// Compress the data
+	compressed, err := codecCompress(compressor, shuffled, &opts)
+	if err != nil && !errors.Is(err, ErrIncompressible) {
+		return nil, blockError(StageCodec, 0, HeaderSize, fmt.Errorf("%w: %w", ErrCompressionFailed, err))
+	}
+
+	// Store uncompressed if compression was not beneficial, as above
+	if err != nil || len(compressed) >= len(data) {
+		header.Flags = flagMemcpy
+		return appendMemcpy(header, data), nil
+	}
+
+	header.NBytesComp = uint32(HeaderSize + len(compressed))
+	result := make([]byte, HeaderSize+len(compressed))
+	copy(result[:HeaderSize], header.Bytes())
+	copy(result[HeaderSize:], compressed)
+	return result, nil
+}
+
+// decompressLegacyInto decodes the payload of a go-blosc 1.0.x chunk into
+// dst, which holds NBytesOrig bytes. Bit shuffled data is unshuffled in place,
+// so only byte shuffled data needs a second buffer.
+func decompressLegacyInto(dst []byte, header *Header, payload []byte, typeSize int) error {
+	// Get codec decompressor
+	codec := header.Codec()
+	decompressor, ok := codecs[codec]
+	if !ok {
+		return headerError(offsetVersionLZ, fmt.Errorf("%w: %s", ErrInvalidCodec, codec))
+	}
+
+	// Decompress
+	byteShuffled := !header.HasBitShuffle() && header.HasShuffle() && typeSize > 1
+	out := dst
+	if byteShuffled {
+		out = make([]byte, len(dst))
+	}
+	n, err := codecDecompressInto(decompressor, out, payload)
+	if err != nil {
+		return blockError(StageCodec, 0, HeaderSize, fmt.Errorf("%w: %w", ErrDecompressionFailed, err))
+	}
+	if n != len(dst) {
+		return blockError(StageCodec, -1, HeaderSize, fmt.Errorf("%w: got %d, expected %d", ErrSizeMismatch, n, len(dst)))
+	}
+
+	// Apply unshuffle
+	if header.HasBitShuffle() {
+		legacyBitTranspose(dst, typeSize, true)
+	} else if byteShuffled {
+		unshuffleBytesTo(dst, out, typeSize)
+	}
+	return nil
+}
+
+// legacyBitTranspose applies the bit shuffle of go-blosc 1.0.x to data in
+// place, or with unshuffle its inverse. Each group of 8 elements is
+// transposed on its own: for byte position j, shuffled byte j*8+k holds bit
+// 7-k of byte j of the group's 8 elements, element i at bit 7-i. Groups are
+// copied to a scratch buffer of 8*typeSize bytes before being rewritten, so
+// no buffer of the size of data is needed. Elements past the last full group
+// and trailing bytes are left as they are, and typeSize 1 data is unchanged.
+func legacyBitTranspose(data []byte, typeSize int, unshuffle bool) {
+	if typeSize <= 1 || len(data) < typeSize {
+		return
+	}
+	group := make([]byte, 8*typeSize)
+	numGroups := len(data) / typeSize / 8
+
+	for g := 0; g < numGroups; g++ {
+		dst := data[g*len(group) : (g+1)*len(group)]
+		copy(group, dst)
+		for j := 0; j < typeSize; j++ {
+			for k := 0; k < 8; k++ {
+				var out byte
+				for i := 0; i < 8; i++ {
+					b := group[i*typeSize+j]
+					if unshuffle {
+						b = group[j*8+i]
+					}
+					if b&(0x80>>k) != 0 {
+						out |= 0x80 >> i
+					}
+				}
+				if unshuffle {
+					dst[k*typeSize+j] = out
+				} else {
+					dst[j*8+k] = out
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package blosc
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Reasons a chunk is stored uncompressed, for Hooks.Logger
+const (
+	memcpyTooSmall       = "too small to compress"
+	memcpyLevel0         = "level 0 without filters"
+	memcpyIncompressible = "compressed larger than a copy"
+)
+
+// debugLogger returns the Logger of opts.Hooks if it logs at Debug level, or
+// nil, so that compression only gathers and times what it would log when it
+// is wanted
+func debugLogger(opts *Options) *slog.Logger {
+	if opts.Hooks == nil || opts.Hooks.Logger == nil || !opts.Hooks.Logger.Enabled(context.Background(), slog.LevelDebug) {
+		return nil
+	}
+	return opts.Hooks.Logger
+}
+
+// logChunk logs the decisions b took building chunk out of nbytes of input
+func (b *chunkBuilder) logChunk(nbytes int, chunk []byte) {
+	if b.log == nil {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("codec", b.opts.Codec.String()),
+		slog.Int("clevel", b.opts.Level),
+		slog.String("filters", filterNames(b.opts)),
+		slog.Int("typesize", b.opts.TypeSize),
+		slog.Int("nbytes", nbytes),
+		slog.Int("cbytes", len(chunk)),
+	}
+	switch {
+	case b.legacy:
+		attrs = append(attrs, slog.String("layout", "legacy"))
+	case b.memcpy():
+		attrs = append(attrs, slog.String("layout", "memcpy"), slog.String("reason", b.reason))
+	default:
+		attrs = append(attrs, slog.String("layout", "blocks"))
+	}
+	if !b.legacy {
+		attrs = append(attrs,
+			slog.Int("blocksize", int(b.header.BlockSize)),
+			slog.Int("nblocks", b.nblocks),
+			slog.Bool("split", b.split))
+	}
+	if b.skipped > 0 {
+		attrs = append(attrs, slog.Int("skipped_streams", b.skipped))
+	}
+	if len(b.filters) > 0 {
+		attrs = append(attrs, slog.String("simd", SIMDInfo().String()))
+	}
+	attrs = append(attrs,
+		slog.Duration("codec_time", b.codecTime),
+		slog.Duration("elapsed", time.Since(b.start)))
+	b.log.LogAttrs(context.Background(), slog.LevelDebug, "blosc: compressed chunk", attrs...)
+}
+
+// logSpecial logs that nbytes of input with opts became the special-value
+// chunk
+func logSpecial(opts *Options, nbytes int, chunk []byte) {
+	log := debugLogger(opts)
+	if log == nil {
+		return
+	}
+	var kind Special
+	if h, err := ParseHeader(chunk); err == nil {
+		kind = h.Special()
+	}
+	log.LogAttrs(context.Background(), slog.LevelDebug, "blosc: compressed chunk",
+		slog.String("codec", opts.Codec.String()),
+		slog.Int("clevel", opts.Level),
+		slog.Int("typesize", opts.TypeSize),
+		slog.Int("nbytes", nbytes),
+		slog.Int("cbytes", len(chunk)),
+		slog.String("layout", "special"),
+		slog.String("special", kind.String()))
+}
+
+// filterNames describes the filters of opts: the pipeline slots joined
+// with "+", such as "shuffle:0+bytedelta:4", or else the shuffle mode
+func filterNames(opts Options) string {
+	if opts.Filters == ([MaxFilters]FilterStage{}) {
+		return opts.Shuffle.String()
+	}
+	var slots []string
+	for _, stage := range opts.Filters {
+		if stage.Filter != FilterNone {
+			slots = append(slots, fmt.Sprintf("%s:%d", stage.Filter, stage.Meta))
+		}
+	}
+	return strings.Join(slots, "+")
+}
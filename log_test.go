@@ -0,0 +1,122 @@
+package blosc
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// debugRecords compresses data with opts, logging at level into JSON, and
+// returns the records logged
+func debugRecords(t *testing.T, data []byte, opts Options, level slog.Level) []map[string]any {
+	t.Helper()
+	var buf bytes.Buffer
+	opts.ownHooks().Logger = slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: level}))
+	if _, err := CompressWithOptions(data, opts); err != nil {
+		t.Fatal(err)
+	}
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var r map[string]any
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("%q: %v", line, err)
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+func TestLogger(t *testing.T) {
+	random := make([]byte, 64<<10)
+	rand.Read(random)
+	opts := Options{Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: 4}
+
+	tests := []struct {
+		name   string
+		data   []byte
+		opts   Options
+		layout string
+		reason string
+	}{
+		{"blocks", makeFloatData(1 << 14), opts, "blocks", ""},
+		{"incompressible", random, opts, "memcpy", memcpyIncompressible},
+		{"small", []byte("tiny"), opts, "memcpy", memcpyTooSmall},
+		{"level 0", makeFloatData(1 << 14), Options{Codec: LZ4, TypeSize: 4}, "memcpy", memcpyLevel0},
+		{"legacy", makeFloatData(1 << 14), Options{Codec: LZ4, Level: 5, TypeSize: 4, LegacyFormat: true}, "legacy", ""},
+		{"special", make([]byte, 1<<16), Options{Codec: LZ4, Level: 5, TypeSize: 4, SpecialValues: true}, "special", ""},
+	}
+	for _, tt := range tests {
+		records := debugRecords(t, tt.data, tt.opts, slog.LevelDebug)
+		if len(records) != 1 {
+			t.Errorf("%s: %d records", tt.name, len(records))
+			continue
+		}
+		r := records[0]
+		if r["level"] != "DEBUG" || r["clevel"] != float64(tt.opts.Level) || r["msg"] != "blosc: compressed chunk" || r["codec"] != "lz4" ||
+			r["nbytes"] != float64(len(tt.data)) || r["layout"] != tt.layout {
+			t.Errorf("%s: %v", tt.name, r)
+		}
+		if reason, _ := r["reason"].(string); reason != tt.reason {
+			t.Errorf("%s: reason %q, want %q", tt.name, reason, tt.reason)
+		}
+	}
+
+	r := debugRecords(t, makeFloatData(1<<14), opts, slog.LevelDebug)[0]
+	for _, key := range []string{"blocksize", "nblocks", "split", "simd", "codec_time", "elapsed", "cbytes"} {
+		if _, ok := r[key]; !ok {
+			t.Errorf("no %s in %v", key, r)
+		}
+	}
+	if r["filters"] != "shuffle" {
+		t.Errorf("filters %v", r["filters"])
+	}
+
+	skip := opts
+	skip.SkipIncompressible = true
+	skip.Shuffle = NoShuffle
+	if r := debugRecords(t, random, skip, slog.LevelDebug)[0]; r["skipped_streams"] == nil {
+		t.Errorf("SkipIncompressible: %v", r)
+	}
+
+	if records := debugRecords(t, makeFloatData(1<<14), opts, slog.LevelInfo); len(records) != 0 {
+		t.Errorf("logged %v above Debug", records)
+	}
+}
+
+func TestLoggerEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	cfg, err := NewConfig(WithCodec(ZSTD), WithLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewEncoder(cfg.Options())
+	for i := 0; i < 3; i++ {
+		if _, err := e.EncodeAll(makeFloatData(1<<14), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := strings.Count(buf.String(), "codec=zstd"); n != 3 {
+		t.Errorf("%d records:\n%s", n, buf.String())
+	}
+	if text, err := cfg.Options().MarshalText(); err != nil || strings.Contains(string(text), "log") {
+		t.Errorf("MarshalText: %q, %v", text, err)
+	}
+}
+
+func BenchmarkLoggerDisabled(b *testing.B) {
+	data := makeFloatData(1 << 18)
+	opts := Options{Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: 4}
+	opts.ownHooks().Logger = slog.New(slog.NewTextHandler(&bytes.Buffer{}, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		if _, err := CompressWithOptions(data, opts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
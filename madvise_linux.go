@@ -0,0 +1,14 @@
+package blosc
+
+import "syscall"
+
+var madviseFlags = [...]int{
+	AdviceNormal:     syscall.MADV_NORMAL,
+	AdviceSequential: syscall.MADV_SEQUENTIAL,
+	AdviceRandom:     syscall.MADV_RANDOM,
+	adviceWillNeed:   syscall.MADV_WILLNEED,
+}
+
+func madvise(data []byte, advice Advice) error {
+	return syscall.Madvise(data, madviseFlags[advice])
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package blosc
+
+// The syscall package only offers madvise on Linux, so elsewhere hints are
+// dropped
+func madvise(data []byte, advice Advice) error {
+	return nil
+}
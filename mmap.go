@@ -0,0 +1,105 @@
+package blosc
+
+import (
+	"fmt"
+	"os"
+)
+
+// Advice is an access pattern hint for a memory-mapped frame, passed on to
+// the operating system as by madvise(2).
+type Advice int
+
+const (
+	AdviceNormal     Advice = iota // No particular access pattern
+	AdviceSequential               // Chunks are read in order, so read ahead aggressively
+	AdviceRandom                   // Chunks are read in random order, so do not read ahead
+
+	adviceWillNeed // Read the range soon, used by Prefetch
+)
+
+// OpenFrameMmap opens the frame file at path by mapping it into memory, so
+// chunks are read from disk only as they are decompressed. Close the frame
+// to release the mapping; slices returned by Chunk must not be used after
+// that. On platforms without mmap the file is read into memory.
+func OpenFrameMmap(path string) (*Frame, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+	if size < frameHeaderSize {
+		return nil, fmt.Errorf("%w: %s holds %d bytes", ErrInvalidFrame, path, size)
+	}
+	if int64(int(size)) != size {
+		return nil, fmt.Errorf("%w: %s holds %d bytes", ErrDataTooLarge, path, size)
+	}
+
+	data, err := mmapFile(file, int(size))
+	if err != nil {
+		return nil, err
+	}
+	f, err := OpenFrame(data)
+	if err != nil {
+		munmap(data)
+		return nil, err
+	}
+	f.mapped = data
+	return f, nil
+}
+
+// Close releases the mapping of a frame opened with OpenFrameMmap. Other
+// frames need no closing, and Close does nothing for them. The frame holds
+// no chunks afterwards.
+func (f *Frame) Close() error {
+	if f.mapped == nil {
+		return nil
+	}
+	err := munmap(f.mapped)
+	f.mapped, f.data, f.chunks = nil, nil, nil
+	f.cache.clear()
+	return err
+}
+
+// Advise tells the operating system how the chunks of a memory-mapped frame
+// will be read. It does nothing for other frames, or where the platform has
+// no madvise.
+func (f *Frame) Advise(advice Advice) error {
+	if advice < AdviceNormal || advice > AdviceRandom {
+		return fmt.Errorf("blosc: unknown advice %d", advice)
+	}
+	if f.mapped == nil {
+		return nil
+	}
+	return madvise(f.mapped, advice)
+}
+
+// Prefetch asks the operating system to start reading chunks [first,
+// first+n) of a memory-mapped frame from disk, so that a sequential scan
+// finds them in memory when it gets there.
+func (f *Frame) Prefetch(first, n int) error {
+	if first < 0 || n < 0 || first > len(f.chunks)-n {
+		return fmt.Errorf("%w: %d+%d of %d", ErrChunkIndex, first, n, len(f.chunks))
+	}
+	if f.mapped == nil || n == 0 {
+		return nil
+	}
+
+	// Chunks need not be contiguous, so cover all of them
+	start, end := f.chunks[first].offset, int64(0)
+	for _, e := range f.chunks[first : first+n] {
+		if e.offset < start {
+			start = e.offset
+		}
+		if e.offset+e.length > end {
+			end = e.offset + e.length
+		}
+	}
+	start -= start % int64(os.Getpagesize())
+	return madvise(f.mapped[start:end], adviceWillNeed)
+}
@@ -0,0 +1,21 @@
+//go:build !unix
+
+package blosc
+
+import (
+	"io"
+	"os"
+)
+
+// Without mmap, the file is read into memory
+func mmapFile(file *os.File, size int) ([]byte, error) {
+	data := make([]byte, size)
+	if _, err := io.ReadFull(file, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func munmap(data []byte) error {
+	return nil
+}
@@ -0,0 +1,107 @@
+package blosc
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFrameFile writes sc as a frame file in a temporary directory
+func writeFrameFile(t *testing.T, sc *SChunk) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if _, err := sc.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "data.gbframe")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestOpenFrameMmap(t *testing.T) {
+	sc, want := makeSChunk(t, 5)
+	f, err := OpenFrameMmap(writeFrameFile(t, sc))
+	if err != nil {
+		t.Fatalf("OpenFrameMmap failed: %v", err)
+	}
+	defer f.Close()
+
+	if err := f.Advise(AdviceSequential); err != nil {
+		t.Errorf("Advise: %v", err)
+	}
+	for i, data := range want {
+		if err := f.Prefetch(i, min(2, len(want)-i)); err != nil {
+			t.Errorf("Prefetch(%d): %v", i, err)
+		}
+		got, err := f.DecompressChunk(i)
+		if err != nil {
+			t.Fatalf("chunk %d: %v", i, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("chunk %d differs", i)
+		}
+	}
+	if content, ok := f.Metalayer("units"); !ok || string(content) != "kelvin" {
+		t.Errorf("metalayer = %q, %v", content, ok)
+	}
+
+	if err := f.Prefetch(4, 2); !errors.Is(err, ErrChunkIndex) {
+		t.Errorf("expected ErrChunkIndex, got %v", err)
+	}
+	if err := f.Advise(Advice(9)); err == nil {
+		t.Error("expected an error for unknown advice")
+	}
+
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := f.Chunk(0); !errors.Is(err, ErrChunkIndex) {
+		t.Errorf("expected ErrChunkIndex after Close, got %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+}
+
+func TestOpenFrameMmapEncrypted(t *testing.T) {
+	sc, want := makeSChunk(t, 2)
+	if err := sc.SetEncryption("k1", testKeys); err != nil {
+		t.Fatal(err)
+	}
+	f, err := OpenFrameMmap(writeFrameFile(t, sc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := f.SetKeys(testKeys); err != nil {
+		t.Fatal(err)
+	}
+	got, err := f.DecompressChunk(1)
+	if err != nil || !bytes.Equal(got, want[1]) {
+		t.Errorf("chunk 1: %v", err)
+	}
+}
+
+func TestOpenFrameMmapErrors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := OpenFrameMmap(filepath.Join(dir, "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected os.ErrNotExist, got %v", err)
+	}
+
+	for name, data := range map[string][]byte{
+		"empty":   nil,
+		"garbage": bytes.Repeat([]byte{0xAB}, 100),
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := OpenFrameMmap(path); !errors.Is(err, ErrInvalidFrame) {
+			t.Errorf("%s: expected ErrInvalidFrame, got %v", name, err)
+		}
+	}
+}
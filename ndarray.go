@@ -0,0 +1,439 @@
+package blosc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+// b2ndMetalayer is the metalayer name python-blosc2 uses for NDArrays
+const b2ndMetalayer = "b2nd"
+
+// b2ndMaxDim is the largest number of dimensions b2nd supports
+const b2ndMaxDim = 8
+
+// NDArray is an n-dimensional array stored in an SChunk, following the b2nd
+// layout of Blosc2. The array is split into chunks of ChunkShape, stored in C
+// order over the chunk grid, and each chunk into blocks of BlockShape, so that
+// reading a small region only decompresses the chunks it touches. Edge chunks
+// are padded to the full chunk shape. Shape, chunk shape, block shape and
+// dtype are kept in the "b2nd" metalayer.
+type NDArray struct {
+	sc         *SChunk
+	shape      []int
+	chunkShape []int
+	blockShape []int
+	dtype      string
+	itemSize   int
+
+	// Derived geometry
+	grid      []int // Chunks along each dimension
+	extChunk  []int // Chunk shape rounded up to whole blocks
+	blockGrid []int // Blocks along each dimension of a chunk
+}
+
+// NewNDArray creates a zero-filled array. dtype is a NumPy type string such
+// as "<f4" or "|u1", whose size sets the element size; opts.TypeSize and
+// opts.BlockSize are derived from dtype and blockShape. FilterNDCell may be
+// used in opts.Filters.
+func NewNDArray(shape, chunkShape, blockShape []int, dtype string, opts Options) (*NDArray, error) {
+	itemSize, err := dtypeItemSize(dtype)
+	if err != nil {
+		return nil, err
+	}
+	a := &NDArray{
+		shape:      slices.Clone(shape),
+		chunkShape: slices.Clone(chunkShape),
+		blockShape: slices.Clone(blockShape),
+		dtype:      dtype,
+		itemSize:   itemSize,
+	}
+	if err := a.init(); err != nil {
+		return nil, err
+	}
+
+	opts.TypeSize = itemSize
+	opts.BlockSize = prod(blockShape) * itemSize
+	a.sc = NewSChunk(opts)
+	a.sc.SetMetalayer(b2ndMetalayer, a.encodeMeta())
+
+	// Every chunk starts out as the same compressed zero chunk
+	nchunks := prod(a.grid)
+	if nchunks > 0 {
+		zero, err := compressWithEnv(make([]byte, a.chunkBytes()), opts, a.sc.enc.env)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < nchunks; i++ {
+			if _, err := a.sc.append(zero, a.chunkBytes()); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return a, nil
+}
+
+// OpenNDArray returns the array stored in sc, as described by its "b2nd"
+// metalayer.
+func OpenNDArray(sc *SChunk) (*NDArray, error) {
+	meta, ok := sc.Metalayer(b2ndMetalayer)
+	if !ok {
+		return nil, fmt.Errorf("%w: no %s metalayer", ErrInvalidShape, b2ndMetalayer)
+	}
+	a := &NDArray{sc: sc}
+	if err := a.decodeMeta(meta); err != nil {
+		return nil, err
+	}
+	itemSize, err := dtypeItemSize(a.dtype)
+	if err != nil {
+		return nil, err
+	}
+	a.itemSize = itemSize
+	if err := a.init(); err != nil {
+		return nil, err
+	}
+	if sc.NumChunks() != prod(a.grid) {
+		return nil, fmt.Errorf("%w: %d chunks, shape needs %d", ErrInvalidShape, sc.NumChunks(), prod(a.grid))
+	}
+	return a, nil
+}
+
+// init checks the shapes and derives the chunk and block geometry
+func (a *NDArray) init() error {
+	ndim := len(a.shape)
+	if ndim == 0 || ndim > b2ndMaxDim {
+		return fmt.Errorf("%w: %d dimensions, need 1 to %d", ErrInvalidShape, ndim, b2ndMaxDim)
+	}
+	if len(a.chunkShape) != ndim || len(a.blockShape) != ndim {
+		return fmt.Errorf("%w: shape, chunk shape and block shape differ in length", ErrInvalidShape)
+	}
+
+	a.grid = make([]int, ndim)
+	a.extChunk = make([]int, ndim)
+	a.blockGrid = make([]int, ndim)
+	for d := 0; d < ndim; d++ {
+		if a.shape[d] < 0 || a.chunkShape[d] < 1 || a.blockShape[d] < 1 || a.blockShape[d] > a.chunkShape[d] {
+			return fmt.Errorf("%w: dimension %d has shape %d, chunk %d, block %d",
+				ErrInvalidShape, d, a.shape[d], a.chunkShape[d], a.blockShape[d])
+		}
+		a.grid[d] = (a.shape[d] + a.chunkShape[d] - 1) / a.chunkShape[d]
+		a.blockGrid[d] = (a.chunkShape[d] + a.blockShape[d] - 1) / a.blockShape[d]
+		a.extChunk[d] = a.blockGrid[d] * a.blockShape[d]
+	}
+	if uint64(prod(a.extChunk))*uint64(a.itemSize) > maxChunkSize {
+		return fmt.Errorf("%w: chunk of %v elements", ErrDataTooLarge, a.extChunk)
+	}
+	return nil
+}
+
+// maxChunkSize is the most data a chunk header can describe
+const maxChunkSize = 1<<31 - 1 - HeaderSize
+
+// Shape returns the array shape.
+func (a *NDArray) Shape() []int { return slices.Clone(a.shape) }
+
+// ChunkShape returns the shape of each chunk.
+func (a *NDArray) ChunkShape() []int { return slices.Clone(a.chunkShape) }
+
+// BlockShape returns the shape of each block within a chunk.
+func (a *NDArray) BlockShape() []int { return slices.Clone(a.blockShape) }
+
+// DType returns the NumPy type string of the elements.
+func (a *NDArray) DType() string { return a.dtype }
+
+// ItemSize returns the size of an element in bytes.
+func (a *NDArray) ItemSize() int { return a.itemSize }
+
+// SChunk returns the super-chunk holding the array.
+func (a *NDArray) SChunk() *SChunk { return a.sc }
+
+// chunkBytes returns the uncompressed size of a padded chunk
+func (a *NDArray) chunkBytes() int {
+	return prod(a.extChunk) * a.itemSize
+}
+
+// Slice returns the elements in [start, stop) along each dimension, in C
+// order.
+func (a *NDArray) Slice(start, stop []int) ([]byte, error) {
+	if err := a.checkRegion(start, stop); err != nil {
+		return nil, err
+	}
+	out := make([]byte, regionSize(start, stop)*a.itemSize)
+	err := a.forEachChunk(start, stop, func(i int, origin []int) error {
+		chunk, err := a.sc.DecompressChunk(i)
+		if err != nil {
+			return err
+		}
+		a.copyRegion(chunk, out, origin, start, stop, false)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Set overwrites the elements in [start, stop) along each dimension with
+// data, given in C order. Each chunk the region touches is recompressed.
+func (a *NDArray) Set(start, stop []int, data []byte) error {
+	if err := a.checkRegion(start, stop); err != nil {
+		return err
+	}
+	if want := regionSize(start, stop) * a.itemSize; len(data) != want {
+		return fmt.Errorf("%w: region holds %d bytes, got %d", ErrInvalidShape, want, len(data))
+	}
+	return a.forEachChunk(start, stop, func(i int, origin []int) error {
+		chunk, err := a.sc.DecompressChunk(i)
+		if err != nil {
+			return err
+		}
+		a.copyRegion(chunk, data, origin, start, stop, true)
+		return a.sc.recompressChunk(i, chunk)
+	})
+}
+
+func (a *NDArray) checkRegion(start, stop []int) error {
+	if len(start) != len(a.shape) || len(stop) != len(a.shape) {
+		return fmt.Errorf("%w: region has %d/%d dimensions, array has %d", ErrInvalidShape, len(start), len(stop), len(a.shape))
+	}
+	for d := range a.shape {
+		if start[d] < 0 || start[d] > stop[d] || stop[d] > a.shape[d] {
+			return fmt.Errorf("%w: dimension %d region [%d, %d) outside [0, %d)", ErrInvalidShape, d, start[d], stop[d], a.shape[d])
+		}
+	}
+	return nil
+}
+
+// forEachChunk calls fn with the index and origin of every chunk that
+// overlaps the non-empty region [start, stop)
+func (a *NDArray) forEachChunk(start, stop []int, fn func(i int, origin []int) error) error {
+	ndim := len(a.shape)
+	lo := make([]int, ndim)
+	hi := make([]int, ndim)
+	for d := 0; d < ndim; d++ {
+		if start[d] == stop[d] {
+			return nil
+		}
+		lo[d] = start[d] / a.chunkShape[d]
+		hi[d] = (stop[d]-1)/a.chunkShape[d] + 1
+	}
+
+	pos := append([]int(nil), lo...)
+	origin := make([]int, ndim)
+	for {
+		for d := range pos {
+			origin[d] = pos[d] * a.chunkShape[d]
+		}
+		if err := fn(cIndex(pos, a.grid), origin); err != nil {
+			return err
+		}
+		if !nextIndex(pos, lo, hi) {
+			return nil
+		}
+	}
+}
+
+// copyRegion copies the part of region [start, stop) that lies in the chunk
+// at origin between the decompressed chunk and region, in the direction
+// given by toChunk. Elements are copied in runs along the last dimension,
+// broken at block boundaries.
+func (a *NDArray) copyRegion(chunk, region []byte, origin, start, stop []int, toChunk bool) {
+	ndim := len(a.shape)
+	last := ndim - 1
+	lo := make([]int, ndim)
+	hi := make([]int, ndim)
+	for d := 0; d < ndim; d++ {
+		lo[d] = max(start[d], origin[d])
+		hi[d] = min(stop[d], origin[d]+a.chunkShape[d])
+	}
+	regionShape := make([]int, ndim)
+	for d := range regionShape {
+		regionShape[d] = stop[d] - start[d]
+	}
+
+	// Iterate over every dimension but the last, which is walked in runs
+	outerHi := append([]int(nil), hi...)
+	outerHi[last] = lo[last] + 1
+	pos := append([]int(nil), lo...)
+	local := make([]int, ndim)
+	rel := make([]int, ndim)
+	for {
+		for x := lo[last]; x < hi[last]; {
+			pos[last] = x
+			for d := range pos {
+				local[d] = pos[d] - origin[d]
+				rel[d] = pos[d] - start[d]
+			}
+			blockEnd := origin[last] + (local[last]/a.blockShape[last]+1)*a.blockShape[last]
+			n := (min(hi[last], blockEnd) - x) * a.itemSize
+
+			c := a.chunkOffset(local) * a.itemSize
+			r := cIndex(rel, regionShape) * a.itemSize
+			if toChunk {
+				copy(chunk[c:c+n], region[r:r+n])
+			} else {
+				copy(region[r:r+n], chunk[c:c+n])
+			}
+			x = min(hi[last], blockEnd)
+		}
+		pos[last] = lo[last]
+		if !nextIndex(pos, lo, outerHi) {
+			return
+		}
+	}
+}
+
+// chunkOffset returns the element index of a chunk-local coordinate in the
+// chunk buffer, where blocks are stored one after another in C order and
+// elements within a block in C order
+func (a *NDArray) chunkOffset(local []int) int {
+	block, inner := 0, 0
+	for d := range local {
+		block = block*a.blockGrid[d] + local[d]/a.blockShape[d]
+		inner = inner*a.blockShape[d] + local[d]%a.blockShape[d]
+	}
+	return block*prod(a.blockShape) + inner
+}
+
+// encodeMeta serializes the array description as python-blosc2 does: a
+// msgpack array of version, ndim, shape (int64), chunk shape and block shape
+// (int32), dtype format (0 for NumPy) and dtype string
+func (a *NDArray) encodeMeta() []byte {
+	ndim := len(a.shape)
+	b := []byte{0x90 + 7, 0, byte(ndim)}
+	b = append(b, 0x90+byte(ndim))
+	for _, v := range a.shape {
+		b = binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(v))
+	}
+	for _, s := range [][]int{a.chunkShape, a.blockShape} {
+		b = append(b, 0x90+byte(ndim))
+		for _, v := range s {
+			b = binary.BigEndian.AppendUint32(append(b, 0xd2), uint32(v))
+		}
+	}
+	b = append(b, 0)
+	b = binary.BigEndian.AppendUint32(append(b, 0xdb), uint32(len(a.dtype)))
+	return append(b, a.dtype...)
+}
+
+// decodeMeta parses the output of encodeMeta
+// b2ndEnv returns the filter environment of the array described by a "b2nd"
+// metalayer, or nil if the metalayer is malformed. Filters such as NDCELL work
+// on the block shape of the array.
+func b2ndEnv(meta []byte) *filterEnv {
+	var a NDArray
+	if a.decodeMeta(meta) != nil {
+		return nil
+	}
+	return &filterEnv{blockShape: a.blockShape}
+}
+
+func (a *NDArray) decodeMeta(meta []byte) error {
+	bad := fmt.Errorf("%w: malformed %s metalayer", ErrInvalidShape, b2ndMetalayer)
+	if len(meta) < 4 || meta[0] != 0x90+7 || meta[1] != 0 {
+		return bad
+	}
+	ndim := int(meta[2])
+	if ndim == 0 || ndim > b2ndMaxDim {
+		return bad
+	}
+	p := meta[3:]
+
+	readInts := func(marker byte, width int) ([]int, bool) {
+		if len(p) < 1+ndim*(1+width) || p[0] != 0x90+byte(ndim) {
+			return nil, false
+		}
+		p = p[1:]
+		v := make([]int, ndim)
+		for d := range v {
+			if p[0] != marker {
+				return nil, false
+			}
+			if width == 8 {
+				v[d] = int(int64(binary.BigEndian.Uint64(p[1:])))
+			} else {
+				v[d] = int(int32(binary.BigEndian.Uint32(p[1:])))
+			}
+			p = p[1+width:]
+		}
+		return v, true
+	}
+
+	var ok bool
+	if a.shape, ok = readInts(0xd3, 8); !ok {
+		return bad
+	}
+	if a.chunkShape, ok = readInts(0xd2, 4); !ok {
+		return bad
+	}
+	if a.blockShape, ok = readInts(0xd2, 4); !ok {
+		return bad
+	}
+	if len(p) < 6 || p[0] != 0 || p[1] != 0xdb {
+		return bad
+	}
+	n := int(binary.BigEndian.Uint32(p[2:]))
+	if n != len(p)-6 {
+		return bad
+	}
+	a.dtype = string(p[6:])
+	return nil
+}
+
+// dtypeItemSize returns the element size of a NumPy type string such as
+// "<f8": an optional byte order, a kind letter and a size in bytes
+func dtypeItemSize(dtype string) (int, error) {
+	s := dtype
+	if len(s) > 0 && (s[0] == '<' || s[0] == '>' || s[0] == '|' || s[0] == '=') {
+		s = s[1:]
+	}
+	if len(s) < 2 || !(s[0] >= 'a' && s[0] <= 'z' || s[0] >= 'A' && s[0] <= 'Z') {
+		return 0, fmt.Errorf("%w: dtype %q", ErrInvalidShape, dtype)
+	}
+	n, err := strconv.Atoi(s[1:])
+	if err != nil || n < 1 || n > 255 {
+		return 0, fmt.Errorf("%w: dtype %q", ErrInvalidShape, dtype)
+	}
+	return n, nil
+}
+
+// prod returns the product of dims
+func prod(dims []int) int {
+	p := 1
+	for _, d := range dims {
+		p *= d
+	}
+	return p
+}
+
+// regionSize returns the number of elements in [start, stop)
+func regionSize(start, stop []int) int {
+	n := 1
+	for d := range start {
+		n *= stop[d] - start[d]
+	}
+	return n
+}
+
+// cIndex returns the C-order linear index of pos in an array of shape
+func cIndex(pos, shape []int) int {
+	i := 0
+	for d := range pos {
+		i = i*shape[d] + pos[d]
+	}
+	return i
+}
+
+// nextIndex advances pos in C order within [lo, hi) and reports false once
+// every position has been visited
+func nextIndex(pos, lo, hi []int) bool {
+	for d := len(pos) - 1; d >= 0; d-- {
+		pos[d]++
+		if pos[d] < hi[d] {
+			return true
+		}
+		pos[d] = lo[d]
+	}
+	return false
+}
@@ -0,0 +1,180 @@
+package blosc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// refArray is a plain C-order uint16 array used to check NDArray regions
+type refArray struct {
+	shape []int
+	data  []uint16
+}
+
+func (r *refArray) region(start, stop []int) []byte {
+	var out []byte
+	pos := append([]int(nil), start...)
+	for {
+		out = binary.LittleEndian.AppendUint16(out, r.data[cIndex(pos, r.shape)])
+		if !nextIndex(pos, start, stop) {
+			return out
+		}
+	}
+}
+
+func (r *refArray) set(start, stop []int, data []byte) {
+	pos := append([]int(nil), start...)
+	for i := 0; ; i++ {
+		r.data[cIndex(pos, r.shape)] = binary.LittleEndian.Uint16(data[2*i:])
+		if !nextIndex(pos, start, stop) {
+			return
+		}
+	}
+}
+
+func TestNDArraySliceAndSet(t *testing.T) {
+	shape := []int{10, 13, 7}
+	arr, err := NewNDArray(shape, []int{4, 5, 7}, []int{2, 3, 4}, "<u2", Options{Codec: ZSTD, Level: 5, Shuffle: Shuffle1})
+	if err != nil {
+		t.Fatalf("NewNDArray failed: %v", err)
+	}
+	if got := arr.SChunk().NumChunks(); got != 3*3*1 {
+		t.Errorf("NumChunks = %d, want 9", got)
+	}
+
+	ref := &refArray{shape: shape, data: make([]uint16, prod(shape))}
+	zero := make([]int, len(shape))
+
+	// Fill the whole array, then overwrite a region spanning several chunks
+	full := make([]byte, 2*prod(shape))
+	for i := range ref.data {
+		binary.LittleEndian.PutUint16(full[2*i:], uint16(i))
+	}
+	if err := arr.Set(zero, shape, full); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	ref.set(zero, shape, full)
+
+	start, stop := []int{3, 2, 1}, []int{9, 11, 6}
+	patch := make([]byte, 2*regionSize(start, stop))
+	for i := range patch {
+		patch[i] = byte(i * 7)
+	}
+	if err := arr.Set(start, stop, patch); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	ref.set(start, stop, patch)
+
+	regions := [][2][]int{
+		{zero, shape},
+		{start, stop},
+		{{0, 0, 0}, {1, 1, 1}},
+		{{9, 12, 6}, {10, 13, 7}},
+		{{2, 4, 0}, {6, 6, 7}},
+		{{5, 5, 5}, {5, 9, 7}}, // Empty
+	}
+	for _, r := range regions {
+		got, err := arr.Slice(r[0], r[1])
+		if err != nil {
+			t.Fatalf("Slice(%v, %v) failed: %v", r[0], r[1], err)
+		}
+		if regionSize(r[0], r[1]) == 0 {
+			if len(got) != 0 {
+				t.Errorf("Slice(%v, %v) returned %d bytes for an empty region", r[0], r[1], len(got))
+			}
+			continue
+		}
+		if !bytes.Equal(got, ref.region(r[0], r[1])) {
+			t.Errorf("Slice(%v, %v) does not match the reference", r[0], r[1])
+		}
+	}
+}
+
+func TestNDArrayOpen(t *testing.T) {
+	arr, err := NewNDArray([]int{100, 30}, []int{32, 16}, []int{8, 16}, "<f8", DefaultOptions())
+	if err != nil {
+		t.Fatalf("NewNDArray failed: %v", err)
+	}
+	data := make([]byte, 8*20)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	if err := arr.Set([]int{40, 5}, []int{50, 7}, data); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+
+	opened, err := OpenNDArray(arr.SChunk())
+	if err != nil {
+		t.Fatalf("OpenNDArray failed: %v", err)
+	}
+	if opened.DType() != "<f8" || opened.ItemSize() != 8 {
+		t.Errorf("dtype %q itemsize %d", opened.DType(), opened.ItemSize())
+	}
+	for _, pair := range [][2][]int{
+		{opened.Shape(), arr.Shape()},
+		{opened.ChunkShape(), arr.ChunkShape()},
+		{opened.BlockShape(), arr.BlockShape()},
+	} {
+		if !slices.Equal(pair[0], pair[1]) {
+			t.Errorf("opened %v, created %v", pair[0], pair[1])
+		}
+	}
+	got, err := opened.Slice([]int{40, 5}, []int{50, 7})
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("opened array does not hold the data: %v", err)
+	}
+}
+
+func TestNDArrayMetalayerLayout(t *testing.T) {
+	arr, err := NewNDArray([]int{5}, []int{4}, []int{2}, "|u1", DefaultOptions())
+	if err != nil {
+		t.Fatalf("NewNDArray failed: %v", err)
+	}
+	meta, _ := arr.SChunk().Metalayer("b2nd")
+	want := []byte{
+		0x97, 0, 1,
+		0x91, 0xd3, 0, 0, 0, 0, 0, 0, 0, 5,
+		0x91, 0xd2, 0, 0, 0, 4,
+		0x91, 0xd2, 0, 0, 0, 2,
+		0, 0xdb, 0, 0, 0, 3, '|', 'u', '1',
+	}
+	if !bytes.Equal(meta, want) {
+		t.Errorf("metalayer\n got %x\nwant %x", meta, want)
+	}
+}
+
+func TestNDArrayErrors(t *testing.T) {
+	bad := []struct {
+		shape, chunk, block []int
+		dtype               string
+	}{
+		{[]int{}, []int{}, []int{}, "<f4"},
+		{[]int{10}, []int{5, 5}, []int{5}, "<f4"},
+		{[]int{10}, []int{5}, []int{6}, "<f4"},
+		{[]int{10}, []int{0}, []int{1}, "<f4"},
+		{[]int{10}, []int{5}, []int{5}, "float"},
+		{make([]int, 9), make([]int, 9), make([]int, 9), "<f4"},
+	}
+	for _, b := range bad {
+		if _, err := NewNDArray(b.shape, b.chunk, b.block, b.dtype, DefaultOptions()); !errors.Is(err, ErrInvalidShape) {
+			t.Errorf("NewNDArray(%v, %v, %v, %q): expected ErrInvalidShape, got %v", b.shape, b.chunk, b.block, b.dtype, err)
+		}
+	}
+
+	arr, err := NewNDArray([]int{10, 10}, []int{5, 5}, []int{5, 5}, "<i4", DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := arr.Slice([]int{0, 0}, []int{11, 5}); !errors.Is(err, ErrInvalidShape) {
+		t.Errorf("expected ErrInvalidShape for out-of-bounds slice, got %v", err)
+	}
+	if err := arr.Set([]int{0, 0}, []int{2, 2}, make([]byte, 3)); !errors.Is(err, ErrInvalidShape) {
+		t.Errorf("expected ErrInvalidShape for short data, got %v", err)
+	}
+	if _, err := OpenNDArray(NewSChunk(DefaultOptions())); !errors.Is(err, ErrInvalidShape) {
+		t.Errorf("expected ErrInvalidShape for an SChunk without metadata, got %v", err)
+	}
+}
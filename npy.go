@@ -0,0 +1,331 @@
+package blosc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// npyMagic starts every NumPy .npy file
+const npyMagic = "\x93NUMPY"
+
+// NpyHeader describes the array in a NumPy .npy file.
+type NpyHeader struct {
+	DType        string // NumPy type string, such as "<f8"
+	Shape        []int
+	FortranOrder bool // Elements are in column-major order
+}
+
+// ItemSize returns the size in bytes of one element.
+func (h NpyHeader) ItemSize() (int, error) {
+	s := h.DType
+	if len(s) > 0 && strings.IndexByte("<>|=", s[0]) >= 0 {
+		s = s[1:]
+	}
+	if len(s) < 2 {
+		return 0, fmt.Errorf("%w: dtype %q", ErrInvalidNpy, h.DType)
+	}
+	n, err := strconv.Atoi(s[1:])
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%w: dtype %q", ErrInvalidNpy, h.DType)
+	}
+	switch s[0] {
+	case 'b', 'i', 'u', 'f', 'c', 'm', 'M', 'S', 'V', 'a':
+		return n, nil
+	case 'U':
+		return 4 * n, nil // UCS-4 code points
+	}
+	return 0, fmt.Errorf("%w: dtype %q", ErrInvalidNpy, h.DType)
+}
+
+// DataSize returns the size in bytes of the array data.
+func (h NpyHeader) DataSize() (int64, error) {
+	itemSize, err := h.ItemSize()
+	if err != nil {
+		return 0, err
+	}
+	n := int64(itemSize)
+	for _, d := range h.Shape {
+		if d < 0 {
+			return 0, fmt.Errorf("%w: shape %v", ErrInvalidNpy, h.Shape)
+		}
+		if d > 0 && n > math.MaxInt64/int64(d) {
+			return 0, fmt.Errorf("%w: shape %v", ErrDataTooLarge, h.Shape)
+		}
+		n *= int64(d)
+	}
+	return n, nil
+}
+
+// ReadNpyHeader reads the header of a .npy file from r, leaving r at the
+// start of the array data.
+func ReadNpyHeader(r io.Reader) (NpyHeader, error) {
+	var prefix [12]byte
+	if _, err := readFull(r, prefix[:10]); err != nil {
+		return NpyHeader{}, err
+	}
+	if string(prefix[:6]) != npyMagic {
+		return NpyHeader{}, fmt.Errorf("%w: bad magic", ErrInvalidNpy)
+	}
+
+	// Version 1 has a 16-bit header length, versions 2 and 3 a 32-bit one
+	var n int
+	switch prefix[6] {
+	case 1:
+		n = int(binary.LittleEndian.Uint16(prefix[8:]))
+	case 2, 3:
+		if _, err := readFull(r, prefix[10:12]); err != nil {
+			return NpyHeader{}, err
+		}
+		size := binary.LittleEndian.Uint32(prefix[8:])
+		if size > 1<<20 {
+			return NpyHeader{}, fmt.Errorf("%w: %d byte header", ErrInvalidNpy, size)
+		}
+		n = int(size)
+	default:
+		return NpyHeader{}, fmt.Errorf("%w: version %d.%d", ErrInvalidNpy, prefix[6], prefix[7])
+	}
+	dict := make([]byte, n)
+	if _, err := readFull(r, dict); err != nil {
+		return NpyHeader{}, err
+	}
+	return parseNpyDict(string(dict))
+}
+
+// parseNpyDict parses the Python dict literal of a .npy header
+func parseNpyDict(s string) (NpyHeader, error) {
+	bad := func(why string) (NpyHeader, error) {
+		return NpyHeader{}, fmt.Errorf("%w: %s in header %q", ErrInvalidNpy, why, s)
+	}
+	p := strings.TrimSpace(s)
+	if !strings.HasPrefix(p, "{") || !strings.HasSuffix(p, "}") {
+		return bad("no dict")
+	}
+	p = p[1 : len(p)-1]
+
+	var h NpyHeader
+	var seen [3]bool
+	for {
+		p = strings.TrimLeft(p, " ")
+		if p == "" {
+			break
+		}
+		key, rest, ok := npyString(p)
+		if !ok {
+			return bad("bad key")
+		}
+		p = strings.TrimLeft(rest, " ")
+		if !strings.HasPrefix(p, ":") {
+			return bad("no colon")
+		}
+		p = strings.TrimLeft(p[1:], " ")
+
+		switch key {
+		case "descr":
+			if h.DType, p, ok = npyString(p); !ok {
+				return bad("structured or bad descr")
+			}
+			seen[0] = true
+		case "fortran_order":
+			switch {
+			case strings.HasPrefix(p, "True"):
+				h.FortranOrder, p = true, p[4:]
+			case strings.HasPrefix(p, "False"):
+				h.FortranOrder, p = false, p[5:]
+			default:
+				return bad("bad fortran_order")
+			}
+			seen[1] = true
+		case "shape":
+			end := strings.IndexByte(p, ')')
+			if !strings.HasPrefix(p, "(") || end < 0 {
+				return bad("bad shape")
+			}
+			h.Shape = []int{}
+			for _, f := range strings.Split(p[1:end], ",") {
+				f = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(f), "L"))
+				if f == "" {
+					continue
+				}
+				d, err := strconv.Atoi(f)
+				if err != nil || d < 0 {
+					return bad("bad shape")
+				}
+				h.Shape = append(h.Shape, d)
+			}
+			p = p[end+1:]
+			seen[2] = true
+		default:
+			return bad("unknown key " + strconv.Quote(key))
+		}
+
+		p = strings.TrimLeft(p, " ")
+		if strings.HasPrefix(p, ",") {
+			p = p[1:]
+		} else if strings.TrimSpace(p) != "" {
+			return bad("no comma")
+		}
+	}
+	if seen != [3]bool{true, true, true} {
+		return bad("missing key")
+	}
+	if _, err := h.ItemSize(); err != nil {
+		return NpyHeader{}, err
+	}
+	return h, nil
+}
+
+// npyString parses a quoted Python string at the start of p
+func npyString(p string) (value, rest string, ok bool) {
+	if p == "" || (p[0] != '\'' && p[0] != '"') {
+		return "", p, false
+	}
+	end := strings.IndexByte(p[1:], p[0])
+	if end < 0 {
+		return "", p, false
+	}
+	return p[1 : end+1], p[end+2:], true
+}
+
+// AppendNpyHeader appends the .npy encoding of h to dst. The header is
+// padded so that the array data starts on a 64-byte boundary, as NumPy
+// writes it.
+func AppendNpyHeader(dst []byte, h NpyHeader) ([]byte, error) {
+	if _, err := h.DataSize(); err != nil {
+		return nil, err
+	}
+	var dict strings.Builder
+	fmt.Fprintf(&dict, "{'descr': '%s', 'fortran_order': ", h.DType)
+	if h.FortranOrder {
+		dict.WriteString("True")
+	} else {
+		dict.WriteString("False")
+	}
+	dict.WriteString(", 'shape': (")
+	for i, d := range h.Shape {
+		if i > 0 {
+			dict.WriteString(", ")
+		}
+		dict.WriteString(strconv.Itoa(d))
+	}
+	if len(h.Shape) == 1 {
+		dict.WriteByte(',')
+	}
+	dict.WriteString("), }")
+
+	// Pad with spaces up to a newline that ends the header on the boundary
+	prefix := 10
+	n := dict.Len() + 1
+	if prefix+n > math.MaxUint16 {
+		prefix = 12
+	}
+	n += (64 - (prefix+n)%64) % 64
+
+	dst = append(dst, npyMagic...)
+	if prefix == 10 {
+		dst = append(dst, 1, 0)
+		dst = binary.LittleEndian.AppendUint16(dst, uint16(n))
+	} else {
+		dst = append(dst, 2, 0)
+		dst = binary.LittleEndian.AppendUint32(dst, uint32(n))
+	}
+	dst = append(dst, dict.String()...)
+	for i := dict.Len() + 1; i < n; i++ {
+		dst = append(dst, ' ')
+	}
+	return append(dst, '\n'), nil
+}
+
+// npyOptions returns opts with the element size of h. Unless opts sets a
+// shuffle mode or filters, multi-byte elements are byte shuffled.
+func npyOptions(h NpyHeader, opts Options) (Options, error) {
+	itemSize, err := h.ItemSize()
+	if err != nil {
+		return opts, err
+	}
+	if itemSize > 255 {
+		// Too wide for the header; c-blosc compresses such data as bytes
+		itemSize = 1
+	}
+	opts.TypeSize = itemSize
+	if opts.Shuffle == NoShuffle && opts.Filters == ([MaxFilters]FilterStage{}) && itemSize > 1 {
+		opts.Shuffle = Shuffle1
+	}
+	return opts, nil
+}
+
+// CompressNpy reads a .npy file from r and compresses its array data into a
+// chunk, with TypeSize set to the dtype's element size. Unless opts sets a
+// shuffle mode or filters, multi-byte elements are byte shuffled. The data
+// is read one block at a time, as by CompressFrom. Empty arrays cannot be
+// compressed and fail with ErrInvalidData.
+func CompressNpy(r io.Reader, opts Options) ([]byte, NpyHeader, error) {
+	h, err := ReadNpyHeader(r)
+	if err != nil {
+		return nil, NpyHeader{}, err
+	}
+	opts, err = npyOptions(h, opts)
+	if err != nil {
+		return nil, NpyHeader{}, err
+	}
+	n, err := h.DataSize()
+	if err != nil {
+		return nil, NpyHeader{}, err
+	}
+	chunk, err := CompressFrom(r, n, opts)
+	if err != nil {
+		return nil, NpyHeader{}, err
+	}
+	return chunk, h, nil
+}
+
+// WriteNpy writes a .npy file holding the array in chunk, described by h,
+// to w, decompressing the chunk one block at a time. The chunk must
+// decompress to exactly the data h describes.
+func WriteNpy(w io.Writer, h NpyHeader, chunk []byte) (int64, error) {
+	want, err := h.DataSize()
+	if err != nil {
+		return 0, err
+	}
+	header, err := ParseHeader(chunk)
+	if err != nil {
+		return 0, err
+	}
+	if int64(header.NBytesOrig) != want {
+		return 0, fmt.Errorf("%w: chunk holds %d bytes, %s%v needs %d",
+			ErrSizeMismatch, header.NBytesOrig, h.DType, h.Shape, want)
+	}
+	prefix, err := AppendNpyHeader(nil, h)
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(prefix)
+	if err != nil {
+		return int64(n), err
+	}
+	m, err := DecompressTo(w, chunk)
+	return int64(n) + m, err
+}
+
+// DecodeNpy decodes a .npy file held in memory, returning its header and
+// array data. The data shares memory with npy.
+func DecodeNpy(npy []byte) (NpyHeader, []byte, error) {
+	r := bytes.NewReader(npy)
+	h, err := ReadNpyHeader(r)
+	if err != nil {
+		return NpyHeader{}, nil, err
+	}
+	n, err := h.DataSize()
+	if err != nil {
+		return NpyHeader{}, nil, err
+	}
+	start := len(npy) - r.Len()
+	if int64(r.Len()) < n {
+		return NpyHeader{}, nil, fmt.Errorf("%w: %d of %d data bytes", ErrInvalidNpy, r.Len(), n)
+	}
+	return h, npy[start : start+int(n)], nil
+}
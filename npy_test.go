@@ -0,0 +1,130 @@
+package blosc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+)
+
+// npyFloats returns a .npy file holding n float64 values of shape
+func npyFloats(t *testing.T, shape []int) (NpyHeader, []byte, []byte) {
+	t.Helper()
+	h := NpyHeader{DType: "<f8", Shape: shape}
+	n := prod(shape)
+	data := make([]byte, 8*n)
+	for i := 0; i < n; i++ {
+		binary.LittleEndian.PutUint64(data[8*i:], math.Float64bits(float64(i)*0.25))
+	}
+	npy, err := AppendNpyHeader(nil, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h, data, append(npy, data...)
+}
+
+func TestNpyHeaderLayout(t *testing.T) {
+	for _, shape := range [][]int{{}, {7}, {3, 4}, {2, 3, 5}} {
+		npy, err := AppendNpyHeader(nil, NpyHeader{DType: "<i4", Shape: shape, FortranOrder: len(shape) == 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(npy)%64 != 0 || npy[len(npy)-1] != '\n' {
+			t.Errorf("shape %v: header of %d bytes, last byte %q", shape, len(npy), npy[len(npy)-1])
+		}
+		h, err := ReadNpyHeader(bytes.NewReader(npy))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.DType != "<i4" || len(h.Shape) != len(shape) || h.FortranOrder != (len(shape) == 2) {
+			t.Errorf("shape %v: read back %+v", shape, h)
+		}
+	}
+
+	// The 1-d shape is a Python tuple
+	npy, _ := AppendNpyHeader(nil, NpyHeader{DType: "|u1", Shape: []int{7}})
+	if !bytes.Contains(npy, []byte("'shape': (7,)")) {
+		t.Errorf("header %q", npy)
+	}
+}
+
+func TestReadNpyHeaderNumPy(t *testing.T) {
+	// Headers as written by NumPy 1.x and 2.x
+	dicts := []string{
+		"{'descr': '<f4', 'fortran_order': False, 'shape': (2, 3), }",
+		"{'descr': '<f4', 'fortran_order': False, 'shape': (2, 3)}",
+		`{"descr": "<f4", "shape": (2,3), "fortran_order": False}`,
+	}
+	for _, dict := range dicts {
+		h, err := parseNpyDict(dict + "   \n")
+		if err != nil {
+			t.Fatalf("%s: %v", dict, err)
+		}
+		if h.DType != "<f4" || len(h.Shape) != 2 || h.Shape[0] != 2 || h.Shape[1] != 3 {
+			t.Errorf("%s: %+v", dict, h)
+		}
+	}
+
+	// Version 2 header
+	dict := "{'descr': '<U3', 'fortran_order': True, 'shape': (4,), }\n"
+	npy := append([]byte(npyMagic+"\x02\x00"), binary.LittleEndian.AppendUint32(nil, uint32(len(dict)))...)
+	h, err := ReadNpyHeader(bytes.NewReader(append(npy, dict...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size, _ := h.DataSize(); size != 48 || !h.FortranOrder {
+		t.Errorf("%+v: %d data bytes", h, size)
+	}
+}
+
+func TestReadNpyHeaderErrors(t *testing.T) {
+	for _, dict := range []string{
+		"{'descr': [('a', '<i4')], 'fortran_order': False, 'shape': (2,), }",
+		"{'descr': '|O', 'fortran_order': False, 'shape': (2,), }",
+		"{'descr': '<f8', 'shape': (2,), }",
+		"{'descr': '<f8', 'fortran_order': Maybe, 'shape': (2,), }",
+		"{'descr': '<f8', 'fortran_order': False, 'shape': (-1,), }",
+		"'descr': '<f8'",
+	} {
+		if _, err := parseNpyDict(dict); !errors.Is(err, ErrInvalidNpy) {
+			t.Errorf("%s: got %v, want ErrInvalidNpy", dict, err)
+		}
+	}
+	if _, err := ReadNpyHeader(bytes.NewReader([]byte("PK\x03\x04abcdefgh"))); !errors.Is(err, ErrInvalidNpy) {
+		t.Errorf("bad magic: got %v", err)
+	}
+}
+
+func TestNpyRoundTrip(t *testing.T) {
+	h, data, npy := npyFloats(t, []int{100, 50})
+	chunk, got, err := CompressNpy(bytes.NewReader(npy), Options{Codec: LZ4, Level: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, _ := ParseHeader(chunk)
+	if header.TypeSize != 8 || !header.HasShuffle() {
+		t.Errorf("typeSize %d, flags %#x: want byte shuffled 8-byte elements", header.TypeSize, header.Flags)
+	}
+	if len(chunk) >= len(data) {
+		t.Errorf("compressed %d bytes to %d", len(data), len(chunk))
+	}
+
+	var out bytes.Buffer
+	n, err := WriteNpy(&out, got, chunk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(out.Len()) || !bytes.Equal(out.Bytes(), npy) {
+		t.Fatalf("WriteNpy wrote %d bytes, not the original file", n)
+	}
+	h2, data2, err := DecodeNpy(out.Bytes())
+	if err != nil || h2.DType != h.DType || !bytes.Equal(data2, data) {
+		t.Errorf("DecodeNpy: %+v, %v", h2, err)
+	}
+
+	// The chunk must match the header
+	if _, err := WriteNpy(&out, NpyHeader{DType: "<f8", Shape: []int{3}}, chunk); !errors.Is(err, ErrSizeMismatch) {
+		t.Errorf("mismatched shape: got %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+//go:build !blosc_nozlib
+
+package blosc
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// The .npz helpers are left out of blosc_nozlib builds, as archive/zip links
+// in compress/flate.
+
+// NpzEntry is one array of a NumPy .npz archive.
+type NpzEntry struct {
+	Name   string // Array name, without the ".npy" suffix
+	Header NpyHeader
+	Chunk  []byte
+}
+
+// CompressNpz compresses every array of the .npz archive in r, of size
+// bytes, as CompressNpy does, in archive order.
+func CompressNpz(r io.ReaderAt, size int64, opts Options) ([]NpzEntry, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidNpy, err)
+	}
+	entries := make([]NpzEntry, 0, len(zr.File))
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrInvalidNpy, f.Name, err)
+		}
+		chunk, h, err := CompressNpy(rc, opts)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		entries = append(entries, NpzEntry{Name: strings.TrimSuffix(f.Name, ".npy"), Header: h, Chunk: chunk})
+	}
+	return entries, nil
+}
+
+// WriteNpz writes entries to w as an uncompressed .npz archive, as
+// numpy.savez would, decompressing one block at a time.
+func WriteNpz(w io.Writer, entries []NpzEntry) error {
+	zw := zip.NewWriter(w)
+	for _, e := range entries {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: e.Name + ".npy", Method: zip.Store})
+		if err != nil {
+			return err
+		}
+		if _, err := WriteNpy(f, e.Header, e.Chunk); err != nil {
+			return fmt.Errorf("%s: %w", e.Name, err)
+		}
+	}
+	return zw.Close()
+}
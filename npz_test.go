@@ -0,0 +1,46 @@
+//go:build !blosc_nozlib
+
+package blosc
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestNpz(t *testing.T) {
+	_, _, a := npyFloats(t, []int{64})
+	_, _, b := npyFloats(t, []int{8, 8, 2})
+	in := []NpzEntry{{Name: "a"}, {Name: "b"}}
+	for i, npy := range [][]byte{a, b} {
+		var err error
+		if in[i].Chunk, in[i].Header, err = CompressNpy(bytes.NewReader(npy), Options{Codec: ZSTD, Level: 3}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var archive bytes.Buffer
+	if err := WriteNpz(&archive, in); err != nil {
+		t.Fatal(err)
+	}
+	out, err := CompressNpz(bytes.NewReader(archive.Bytes()), int64(archive.Len()), Options{Codec: LZ4, Level: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 || out[0].Name != "a" || out[1].Name != "b" {
+		t.Fatalf("entries %+v", out)
+	}
+	for i, want := range [][]byte{a, b} {
+		var npy bytes.Buffer
+		if _, err := WriteNpy(&npy, out[i].Header, out[i].Chunk); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(npy.Bytes(), want) {
+			t.Errorf("%s: round trip differs", out[i].Name)
+		}
+	}
+
+	if _, err := CompressNpz(bytes.NewReader(a), int64(len(a)), Options{}); !errors.Is(err, ErrInvalidNpy) {
+		t.Errorf("not a zip: got %v", err)
+	}
+}
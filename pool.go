@@ -0,0 +1,77 @@
+package blosc
+
+import (
+	"runtime"
+	"sync"
+)
+
+// workers bounds the goroutines compressing at once across the package: the
+// filter and codec stages of every AsyncCompressor, and the threads libblosc
+// is given
+var workers = newWorkerPool()
+
+// workerPool hands out up to max slots at once, or GOMAXPROCS if max is 0
+type workerPool struct {
+	mu      sync.Mutex
+	cond    *sync.Cond // Signaled as slots free up or max changes
+	max     int
+	running int
+}
+
+func newWorkerPool() *workerPool {
+	p := &workerPool{}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+// SetMaxWorkers caps the goroutines that compress at once across all
+// AsyncCompressors, whatever their Options.NumThreads, and the threads
+// libblosc compresses a chunk with, so that an application embedding the
+// package can keep cores for its own work. n <= 0 restores the default,
+// GOMAXPROCS. Work already running finishes first; later work waits for a
+// slot. Options.NumThreads still sets the goroutines of each AsyncCompressor,
+// which may be fewer. It returns the previous cap, 0 for the default.
+// One-shot calls, Encoders and Writers compress on the caller's goroutine
+// and are not counted.
+func SetMaxWorkers(n int) int {
+	workers.mu.Lock()
+	defer workers.mu.Unlock()
+	prev := workers.max
+	workers.max = max(n, 0)
+	workers.cond.Broadcast()
+	return prev
+}
+
+// MaxWorkers returns the number of goroutines that may compress at once, as
+// set by SetMaxWorkers or GOMAXPROCS by default.
+func MaxWorkers() int {
+	workers.mu.Lock()
+	defer workers.mu.Unlock()
+	return workers.limit()
+}
+
+// limit returns the slots available in all; the caller holds p.mu
+func (p *workerPool) limit() int {
+	if p.max > 0 {
+		return p.max
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// acquire waits for a free slot and takes it
+func (p *workerPool) acquire() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for p.running >= p.limit() {
+		p.cond.Wait()
+	}
+	p.running++
+}
+
+// release returns a slot taken by acquire
+func (p *workerPool) release() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.running--
+	p.cond.Signal()
+}
@@ -0,0 +1,69 @@
+package blosc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Names of the option presets returned by Profile.
+const (
+	// ProfileFastest favors speed: LZ4 at level 1 with byte shuffle, and
+	// incompressible streams skip the codec.
+	ProfileFastest = "fastest"
+
+	// ProfileBalanced is DefaultOptions: LZ4 at level 5 with byte shuffle.
+	ProfileBalanced = "balanced"
+
+	// ProfileMaxRatio favors ratio: ZSTD at level 9 with byte shuffle and
+	// 1 MB blocks.
+	ProfileMaxRatio = "max-ratio"
+
+	// ProfileFloatData suits slowly varying floating-point data: ZSTD at
+	// level 5 over a shuffle and bytedelta pipeline, with special-value
+	// chunks for all-zero and all-NaN input. Chunks need a Blosc2 reader.
+	ProfileFloatData = "float"
+
+	// ProfileIntData suits integers of small magnitude or range: LZ4 at
+	// level 5 with bit shuffle, with special-value chunks for constant
+	// input. Chunks need a Blosc2 reader.
+	ProfileIntData = "int"
+)
+
+// profiles lists the presets in the order Profiles reports them
+var profiles = []string{ProfileFastest, ProfileBalanced, ProfileMaxRatio, ProfileFloatData, ProfileIntData}
+
+// Profile returns the option preset with the given name, matched without
+// regard to case, so that settings can be chosen without knowing the codecs
+// and filters. Presets assume 4-byte elements; set TypeSize to the element
+// size of the data, such as 8 for float64. An unknown name returns
+// ErrInvalidProfile.
+func Profile(name string) (Options, error) {
+	opts := DefaultOptions()
+	switch strings.ToLower(name) {
+	case ProfileFastest:
+		opts.Level = 1
+		opts.SkipIncompressible = true
+	case ProfileBalanced:
+	case ProfileMaxRatio:
+		opts.Codec = ZSTD
+		opts.Level = 9
+		opts.BlockSize = 1 << 20
+	case ProfileFloatData:
+		opts.Codec = ZSTD
+		opts.Shuffle = NoShuffle
+		opts.Filters[0] = FilterStage{Filter: FilterShuffle}
+		opts.Filters[1] = FilterStage{Filter: FilterByteDelta}
+		opts.SpecialValues = true
+	case ProfileIntData:
+		opts.Shuffle = BitShuffle
+		opts.SpecialValues = true
+	default:
+		return Options{}, fmt.Errorf("%w: %q", ErrInvalidProfile, name)
+	}
+	return opts, nil
+}
+
+// Profiles returns the names Profile accepts.
+func Profiles() []string {
+	return append([]string(nil), profiles...)
+}
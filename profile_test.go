@@ -0,0 +1,48 @@
+package blosc
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestProfile(t *testing.T) {
+	data := makeFloatData(1 << 18)
+	sizes := make(map[string]int)
+	for _, name := range Profiles() {
+		opts, err := Profile(name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		chunk, err := CompressWithOptions(data, opts)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got, err := Decompress(chunk)
+		if err != nil || !bytes.Equal(got, data) {
+			t.Fatalf("%s: round trip failed: %v", name, err)
+		}
+		sizes[name] = len(chunk)
+	}
+	if sizes[ProfileMaxRatio] >= sizes[ProfileFastest] {
+		t.Errorf("max-ratio chunk of %d bytes is no smaller than fastest's %d", sizes[ProfileMaxRatio], sizes[ProfileFastest])
+	}
+
+	if opts, err := Profile("Balanced"); err != nil || opts.Codec != DefaultOptions().Codec || opts.Level != DefaultOptions().Level {
+		t.Errorf("Balanced: got %+v, %v", opts, err)
+	}
+	zeros, err := Profile(ProfileFloatData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunk, err := CompressWithOptions(make([]byte, 4096), zeros)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h, _ := ParseHeader(chunk); h.Special() != SpecialZero {
+		t.Errorf("float profile: zeros stored as %v", h.Special())
+	}
+	if _, err := Profile("turbo"); !errors.Is(err, ErrInvalidProfile) {
+		t.Errorf("expected ErrInvalidProfile, got %v", err)
+	}
+}
@@ -0,0 +1,217 @@
+package blosc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// quantizeMetalayer is the metalayer an SChunk keeps the largest error of
+// its FilterFloatQuantize chunks in, as a little-endian float64
+const quantizeMetalayer = "quantize"
+
+// quantizeErrorBias offsets the base-2 exponent of the error bound that a
+// chunk records in the Meta of its FilterFloatQuantize stage, so that Meta
+// 1 to 254 stand for bounds of 2^-149 to 2^104
+const quantizeErrorBias = 150
+
+// ErrorBound limits the error FilterFloatQuantize introduces in each value.
+// Set either or both; with both, every value respects the tighter of them.
+type ErrorBound struct {
+	Absolute float64 // Largest difference from the original value, or 0
+	Relative float64 // Largest difference as a fraction of the original magnitude, or 0
+}
+
+// IsZero reports whether neither bound is set.
+func (e ErrorBound) IsZero() bool {
+	return e.Absolute == 0 && e.Relative == 0
+}
+
+// check reports bounds that are negative, infinite or NaN
+func (e ErrorBound) check() error {
+	for _, v := range []float64{e.Absolute, e.Relative} {
+		if v < 0 || math.IsInf(v, 0) || math.IsNaN(v) {
+			return fmt.Errorf("%w: error bound %v is not a finite value of 0 or more", ErrInvalidOption, v)
+		}
+	}
+	return nil
+}
+
+// allowed returns the error bound of a value of magnitude abs, or 0 if it
+// must be kept exactly
+func (e ErrorBound) allowed(abs float64) float64 {
+	allowed := e.Absolute
+	if r := e.Relative * abs; e.Relative > 0 && (allowed == 0 || r < allowed) {
+		allowed = r
+	}
+	return allowed
+}
+
+// quantizer carries the bound of FilterFloatQuantize through the filter
+// pipeline of one chunk and gathers the largest error it introduced
+type quantizer struct {
+	bound  ErrorBound
+	maxErr float64
+}
+
+// quantizes reports whether the filter slots hold FilterFloatQuantize
+func quantizes(slots [MaxFilters]FilterStage) bool {
+	for _, s := range slots {
+		if s.Filter == FilterFloatQuantize {
+			return true
+		}
+	}
+	return false
+}
+
+// floatQuantize implements the forward FilterFloatQuantize. Each float32 or
+// float64 element is rounded to the fewest mantissa bits that keep it within
+// the bound, leaving runs of zero bits that the codec and a later shuffle
+// compress well. Zeros, infinities and NaNs are kept as they are, and bytes
+// past the last whole element are copied. Decoding is a copy.
+func floatQuantize(dst, src []byte, typeSize int, env *filterEnv) error {
+	if typeSize != 4 && typeSize != 8 {
+		return fmt.Errorf("%w: %s needs a type size of 4 or 8, not %d", ErrInvalidFilter, FilterFloatQuantize, typeSize)
+	}
+	if env == nil || env.quantize == nil || env.quantize.bound.IsZero() {
+		return fmt.Errorf("%w: %s needs Options.ErrorBound", ErrInvalidFilter, FilterFloatQuantize)
+	}
+	q := env.quantize
+	n := len(src) / typeSize
+	for i := 0; i < n; i++ {
+		b := src[i*typeSize:]
+		var v, r float64
+		if typeSize == 4 {
+			bits := roundMantissa(uint64(binary.LittleEndian.Uint32(b)), 8, 23, q.bound)
+			binary.LittleEndian.PutUint32(dst[i*typeSize:], uint32(bits))
+			v = float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+			r = float64(math.Float32frombits(uint32(bits)))
+		} else {
+			bits := roundMantissa(binary.LittleEndian.Uint64(b), 11, 52, q.bound)
+			binary.LittleEndian.PutUint64(dst[i*typeSize:], bits)
+			v, r = math.Float64frombits(binary.LittleEndian.Uint64(b)), math.Float64frombits(bits)
+		}
+		if d := math.Abs(v - r); d > q.maxErr {
+			q.maxErr = d
+		}
+	}
+	copy(dst[n*typeSize:], src[n*typeSize:])
+	return nil
+}
+
+// roundMantissa rounds the IEEE 754 value in bits, with the given exponent
+// and mantissa widths, to the fewest mantissa bits whose rounding error,
+// half a unit in the last place kept, stays within bound
+func roundMantissa(bits uint64, expBits, mantBits uint, bound ErrorBound) uint64 {
+	expMax := uint64(1)<<expBits - 1
+	exp := bits >> mantBits & expMax
+	if exp == expMax || bits<<(64-expBits-mantBits) == 0 {
+		return bits // Infinity, NaN or zero
+	}
+	// A unit in the last place of the mantissa is 2^(exp - bias - mantBits),
+	// with subnormals scaled as the smallest normal exponent
+	bias := int(expMax >> 1)
+	lsb := max(int(exp), 1) - bias - int(mantBits)
+	abs := math.Abs(math.Float64frombits(bits))
+	if expBits == 8 {
+		abs = math.Abs(float64(math.Float32frombits(uint32(bits))))
+	}
+	allowed := bound.allowed(abs)
+	if allowed == 0 {
+		return bits
+	}
+	// Dropping d bits errs by at most 2^(lsb + d - 1), which must not
+	// exceed allowed, at least 2^(e - 1)
+	_, e := math.Frexp(allowed)
+	d := min(max(e-lsb, 0), int(mantBits))
+	if d == 0 {
+		return bits
+	}
+	mask := uint64(1)<<d - 1
+	rounded := (bits + 1<<(d-1)) &^ mask
+	if rounded>>mantBits&expMax == expMax {
+		return bits &^ (mask >> 1) // Rounding up would overflow to infinity
+	}
+	return rounded
+}
+
+// quantizeErrorCode encodes err as the Meta of a FilterFloatQuantize stage:
+// 0 for no error, the biased exponent of the smallest power of two no less
+// than err, or 255 when that is too large to tell
+func quantizeErrorCode(err float64) uint8 {
+	if err <= 0 {
+		return 0
+	}
+	frac, exp := math.Frexp(err)
+	if frac == 0.5 {
+		exp-- // err is a power of two
+	}
+	return uint8(min(max(exp+quantizeErrorBias, 1), math.MaxUint8))
+}
+
+// QuantizeError returns the largest error FilterFloatQuantize introduced in
+// the values of the chunk, rounded up to a power of two, and whether the
+// chunk went through that filter. The bound is 0 for chunks stored exactly,
+// such as memcpy chunks, and +Inf when too large for the header to record.
+func (h *Header) QuantizeError() (float64, bool) {
+	for _, s := range h.Filters {
+		if s.Filter != FilterFloatQuantize {
+			continue
+		}
+		switch s.Meta {
+		case 0:
+			return 0, true
+		case math.MaxUint8:
+			return math.Inf(1), true
+		default:
+			return math.Ldexp(1, int(s.Meta)-quantizeErrorBias), true
+		}
+	}
+	return 0, false
+}
+
+// QuantizeError returns the largest error FilterFloatQuantize introduced in
+// any chunk, from the "quantize" metalayer, and whether there is one. It is
+// exact for chunks the SChunk compressed, and the bound the header records
+// for chunks added already compressed.
+func (s *SChunk) QuantizeError() (float64, bool) {
+	return decodeQuantizeError(s.Metalayer(quantizeMetalayer))
+}
+
+// quantizeError is QuantizeError for a caller holding s.mu
+func (s *SChunk) quantizeError() (float64, bool) {
+	content, ok := s.metalayers[quantizeMetalayer]
+	return decodeQuantizeError(content, ok)
+}
+
+// QuantizeError returns the largest error FilterFloatQuantize introduced in
+// any chunk of the frame, as SChunk.QuantizeError does.
+func (f *Frame) QuantizeError() (float64, bool) {
+	return decodeQuantizeError(f.Metalayer(quantizeMetalayer))
+}
+
+func decodeQuantizeError(content []byte, ok bool) (float64, bool) {
+	if !ok || len(content) != 8 {
+		return 0, false
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(content)), true
+}
+
+// noteQuantizeError raises the "quantize" metalayer to err if it is larger.
+// The caller holds s.mu.
+func (s *SChunk) noteQuantizeError(err float64) {
+	if old, ok := s.quantizeError(); ok && old >= err {
+		return
+	}
+	s.setMetalayer(quantizeMetalayer, binary.LittleEndian.AppendUint64(nil, math.Float64bits(err)))
+}
+
+// noteChunkQuantizeError notes the error bound in the header of an already
+// compressed chunk, if it went through FilterFloatQuantize
+func (s *SChunk) noteChunkQuantizeError(chunk []byte) {
+	if h, err := ParseHeader(chunk); err == nil {
+		if bound, ok := h.QuantizeError(); ok {
+			s.noteQuantizeError(bound)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package blosc
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+)
+
+// floats64 returns n slowly varying float64 values as bytes
+func floats64(n int) []byte {
+	data := make([]byte, 8*n)
+	for i := 0; i < n; i++ {
+		v := 1000*math.Sin(float64(i)/500) + float64(i)/7
+		binary.LittleEndian.PutUint64(data[8*i:], math.Float64bits(v))
+	}
+	return data
+}
+
+// quantizeErrors returns the largest absolute and relative difference
+// between the float elements of a and b
+func quantizeErrors(t *testing.T, a, b []byte, typeSize int) (abs, rel float64) {
+	t.Helper()
+	if len(a) != len(b) {
+		t.Fatalf("lengths %d and %d", len(a), len(b))
+	}
+	for i := 0; i+typeSize <= len(a); i += typeSize {
+		var x, y float64
+		if typeSize == 4 {
+			x = float64(math.Float32frombits(binary.LittleEndian.Uint32(a[i:])))
+			y = float64(math.Float32frombits(binary.LittleEndian.Uint32(b[i:])))
+		} else {
+			x = math.Float64frombits(binary.LittleEndian.Uint64(a[i:]))
+			y = math.Float64frombits(binary.LittleEndian.Uint64(b[i:]))
+		}
+		d := math.Abs(x - y)
+		abs = max(abs, d)
+		if x != 0 {
+			rel = max(rel, d/math.Abs(x))
+		}
+	}
+	return abs, rel
+}
+
+func TestFloatQuantize(t *testing.T) {
+	quantize := [MaxFilters]FilterStage{{Filter: FilterFloatQuantize}, {Filter: FilterShuffle}}
+	tests := []struct {
+		name     string
+		data     []byte
+		typeSize int
+		bound    ErrorBound
+	}{
+		{"float64 absolute", floats64(1 << 15), 8, ErrorBound{Absolute: 1e-3}},
+		{"float64 relative", floats64(1 << 15), 8, ErrorBound{Relative: 1e-5}},
+		{"float64 both", floats64(1 << 15), 8, ErrorBound{Absolute: 1e-2, Relative: 1e-6}},
+		{"float32 absolute", makeFloatData(1 << 15), 4, ErrorBound{Absolute: 0.01}},
+		{"float32 relative", makeFloatData(1 << 15), 4, ErrorBound{Relative: 1e-3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Options{Codec: ZSTD, Level: 5, TypeSize: tt.typeSize, Filters: quantize, ErrorBound: tt.bound}
+			chunk, err := CompressWithOptions(tt.data, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Decompress(chunk)
+			if err != nil {
+				t.Fatal(err)
+			}
+			abs, rel := quantizeErrors(t, tt.data, got, tt.typeSize)
+			if tt.bound.Absolute > 0 && abs > tt.bound.Absolute || tt.bound.Relative > 0 && tt.bound.Absolute == 0 && rel > tt.bound.Relative {
+				t.Errorf("errors %g absolute, %g relative exceed %+v", abs, rel, tt.bound)
+			}
+			if abs == 0 {
+				t.Error("nothing was quantized")
+			}
+
+			info, err := GetInfo(chunk)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bound, ok := info.QuantizeError(); !ok || bound < abs || bound >= 2*abs {
+				t.Errorf("header error bound %g, %v for error %g", bound, ok, abs)
+			}
+
+			opts.Filters = [MaxFilters]FilterStage{{Filter: FilterShuffle}}
+			exact, err := CompressWithOptions(tt.data, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(chunk) >= len(exact) {
+				t.Errorf("quantized chunk is %d bytes, exact %d", len(chunk), len(exact))
+			}
+		})
+	}
+}
+
+func TestFloatQuantizeSpecialValues(t *testing.T) {
+	values := []float64{0, math.Copysign(0, -1), math.Inf(1), math.Inf(-1), math.NaN(), math.MaxFloat64, -math.MaxFloat64, math.SmallestNonzeroFloat64, 1.5}
+	src := make([]byte, 8*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint64(src[8*i:], math.Float64bits(v))
+	}
+	dst := make([]byte, len(src))
+	env := &filterEnv{quantize: &quantizer{bound: ErrorBound{Relative: 0.1}}}
+	if err := floatQuantize(dst, src, 8, env); err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range values {
+		got := math.Float64frombits(binary.LittleEndian.Uint64(dst[8*i:]))
+		switch {
+		case math.IsNaN(v):
+			if !math.IsNaN(got) {
+				t.Errorf("NaN became %v", got)
+			}
+		case math.IsInf(v, 0) || v == 0:
+			if math.Float64bits(got) != math.Float64bits(v) {
+				t.Errorf("%v became %v", v, got)
+			}
+		default:
+			if math.IsInf(got, 0) || math.Abs(got-v) > 0.1*math.Abs(v) {
+				t.Errorf("%v became %v", v, got)
+			}
+		}
+	}
+	if env.quantize.maxErr == 0 || math.IsNaN(env.quantize.maxErr) || math.IsInf(env.quantize.maxErr, 0) {
+		t.Errorf("largest error %v", env.quantize.maxErr)
+	}
+}
+
+func TestFloatQuantizeErrors(t *testing.T) {
+	data := floats64(1 << 12)
+	quantize := [MaxFilters]FilterStage{{Filter: FilterFloatQuantize}}
+	if _, err := CompressWithOptions(data, Options{Codec: LZ4, Level: 5, TypeSize: 8, Filters: quantize}); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("no bound: %v", err)
+	}
+	if _, err := CompressWithOptions(data, Options{Codec: LZ4, Level: 5, TypeSize: 2, Filters: quantize, ErrorBound: ErrorBound{Absolute: 1}}); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("type size 2: %v", err)
+	}
+	for _, opts := range []Options{
+		{Codec: LZ4, Level: 5, TypeSize: 8, Filters: quantize},
+		{Codec: LZ4, Level: 5, TypeSize: 8, ErrorBound: ErrorBound{Absolute: -1}},
+		{Codec: LZ4, Level: 5, TypeSize: 8, ErrorBound: ErrorBound{Relative: math.NaN()}},
+	} {
+		if err := opts.Validate(); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Validate(%+v): %v", opts.ErrorBound, err)
+		}
+	}
+	if _, err := NewConfig(WithErrorBound(ErrorBound{Absolute: math.Inf(1)})); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("WithErrorBound: %v", err)
+	}
+
+	// Chunks without the filter record nothing
+	chunk, err := CompressWithOptions(data, Options{Codec: LZ4, Level: 5, TypeSize: 8, Shuffle: Shuffle1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h, _ := GetInfo(chunk); h != nil {
+		if _, ok := h.QuantizeError(); ok {
+			t.Error("unquantized chunk reports an error")
+		}
+	}
+}
+
+func TestSChunkQuantizeError(t *testing.T) {
+	opts := Options{Codec: ZSTD, Level: 5, TypeSize: 8, ErrorBound: ErrorBound{Absolute: 1e-4},
+		Filters: [MaxFilters]FilterStage{{Filter: FilterFloatQuantize}, {Filter: FilterShuffle}}}
+	s := NewSChunk(opts)
+	if _, ok := s.QuantizeError(); ok {
+		t.Error("empty SChunk reports an error")
+	}
+	data := floats64(1 << 16)
+	var want float64
+	for i := 0; i < 4; i++ {
+		part := data[i*len(data)/4 : (i+1)*len(data)/4]
+		if _, err := s.AppendBuffer(part); err != nil {
+			t.Fatal(err)
+		}
+		got, err := s.DecompressChunk(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		abs, _ := quantizeErrors(t, part, got, 8)
+		want = max(want, abs)
+	}
+	if got, ok := s.QuantizeError(); !ok || got != want {
+		t.Errorf("QuantizeError %g, %v; want %g", got, ok, want)
+	}
+
+	// The metalayer travels with the frame
+	f, err := s.ToFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := f.QuantizeError(); !ok || got != want {
+		t.Errorf("frame QuantizeError %g, %v; want %g", got, ok, want)
+	}
+
+	// Chunks added compressed raise it to the bound in their header
+	opts.ErrorBound = ErrorBound{Absolute: 0.5}
+	chunk, err := CompressWithOptions(data[:8192], opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AppendChunk(chunk); err != nil {
+		t.Fatal(err)
+	}
+	h, _ := GetInfo(chunk)
+	bound, _ := h.QuantizeError()
+	if got, _ := s.QuantizeError(); got != bound || bound <= want {
+		t.Errorf("after AppendChunk: %g, header bound %g", got, bound)
+	}
+}
+
+func TestQuantizeErrorCode(t *testing.T) {
+	tests := []struct {
+		err  float64
+		code uint8
+	}{
+		{0, 0},
+		{1, quantizeErrorBias},
+		{0.75, quantizeErrorBias},
+		{1.5, quantizeErrorBias + 1},
+		{math.SmallestNonzeroFloat64, 1},
+		{math.MaxFloat64, math.MaxUint8},
+	}
+	for _, tt := range tests {
+		if code := quantizeErrorCode(tt.err); code != tt.code {
+			t.Errorf("quantizeErrorCode(%g) = %d, want %d", tt.err, code, tt.code)
+		}
+	}
+}
@@ -0,0 +1,7 @@
+//go:build race
+
+package blosc
+
+// raceEnabled reports a -race build, where sync.Pool drops items at random
+// and allocation counts are not meaningful
+const raceEnabled = true
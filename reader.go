@@ -0,0 +1,132 @@
+package blosc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// MultiChunkReader decompresses a stream of back-to-back Blosc chunks, such
+// as a Writer produces or formats that concatenate chunks without a frame,
+// and reads back their concatenated data. Each chunk is found by advancing
+// its NBytesComp bytes, so chunks may differ in codec, options and format
+// version.
+type MultiChunkReader struct {
+	r      io.Reader
+	dec    *Decoder
+	chunk  bytes.Buffer // Compressed chunk being decoded
+	out    []byte       // Decompressed data of the current chunk
+	pos    int          // Bytes of out already read
+	chunks int          // Chunks decoded so far
+	offset int64        // Stream offset of the next chunk
+	limit  int          // Most bytes a chunk may decompress to, or -1
+	err    error
+}
+
+// NewMultiChunkReader returns a MultiChunkReader that reads chunks from r.
+// It reads r only as far as the chunk being decompressed.
+func NewMultiChunkReader(r io.Reader) *MultiChunkReader {
+	return &MultiChunkReader{r: r, dec: NewDecoder(), limit: -1}
+}
+
+// NewMultiChunkReaderLimit is like NewMultiChunkReader, but fails with
+// ErrOutputTooLarge on a chunk whose header claims more than maxBytes, as
+// DecompressLimited does, before the rest of the chunk is read. Servers
+// reading untrusted streams can bound each reader's memory this way
+// instead of with the process-wide SetMaxDecodedSize, which still applies.
+func NewMultiChunkReaderLimit(r io.Reader, maxBytes int) *MultiChunkReader {
+	return &MultiChunkReader{r: r, dec: NewDecoder(), limit: max(maxBytes, 0)}
+}
+
+// Read reads decompressed data into p. It returns io.EOF once the stream ends
+// on a chunk boundary; a stream that ends inside a chunk is reported as
+// ErrInvalidData wrapping io.ErrUnexpectedEOF. Errors from decompressing a
+// chunk give its index and stream offset.
+func (m *MultiChunkReader) Read(p []byte) (int, error) {
+	for m.pos == len(m.out) {
+		if m.err != nil {
+			return 0, m.err
+		}
+		if m.err = m.next(); m.err != nil {
+			return 0, m.err
+		}
+	}
+	n := copy(p, m.out[m.pos:])
+	m.pos += n
+	return n, nil
+}
+
+// WriteTo writes the remaining decompressed data to w, a chunk at a time.
+func (m *MultiChunkReader) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	for {
+		if m.pos < len(m.out) {
+			n, err := w.Write(m.out[m.pos:])
+			m.pos += n
+			total += int64(n)
+			if err != nil {
+				return total, err
+			}
+		}
+		if m.err == nil {
+			m.err = m.next()
+		}
+		if m.err == io.EOF {
+			return total, nil
+		}
+		if m.err != nil {
+			return total, m.err
+		}
+	}
+}
+
+// Chunks returns the number of chunks decompressed so far.
+func (m *MultiChunkReader) Chunks() int {
+	return m.chunks
+}
+
+// next reads and decompresses the next chunk into m.out
+func (m *MultiChunkReader) next() error {
+	m.out, m.pos = m.out[:0], 0
+	m.chunk.Reset()
+	n, err := io.CopyN(&m.chunk, m.r, HeaderSize)
+	if n == 0 && err == io.EOF {
+		return io.EOF
+	}
+	if err == nil {
+		head := m.chunk.Bytes()
+		if nbytes, limit := binary.LittleEndian.Uint32(head[offsetNBytesOrig:]), decodeLimit(m.limit); limit >= 0 && uint64(nbytes) > uint64(limit) {
+			return m.chunkError(headerError(offsetNBytesOrig, fmt.Errorf("%w: header claims %d bytes, limit is %d", ErrOutputTooLarge, nbytes, limit)))
+		}
+		size := int64(binary.LittleEndian.Uint32(head[offsetNBytesComp:]))
+		if size < HeaderSize {
+			return m.chunkError(headerError(offsetNBytesComp, fmt.Errorf("%w: compressed size %d is smaller than the header", ErrInvalidData, size)))
+		}
+		// The buffer grows as data arrives, so a forged size fails at the end
+		// of the stream instead of allocating what it claims up front
+		_, err = io.CopyN(&m.chunk, m.r, size-HeaderSize)
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("%w: chunk %d at offset %d is truncated: %w", ErrInvalidData, m.chunks, m.offset, err)
+		}
+		return err
+	}
+
+	m.out, err = m.dec.decodeAll(m.chunk.Bytes(), m.out, m.limit)
+	if err != nil {
+		return m.chunkError(err)
+	}
+	m.chunks++
+	m.offset += int64(m.chunk.Len())
+	return nil
+}
+
+func (m *MultiChunkReader) chunkError(err error) error {
+	return fmt.Errorf("chunk %d at offset %d: %w", m.chunks, m.offset, err)
+}
@@ -0,0 +1,140 @@
+package blosc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestMultiChunkReader(t *testing.T) {
+	data := makeFloatData(50000)
+	var stream bytes.Buffer
+	w := NewWriterSize(&stream, Options{Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: 4}, 30000)
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	want := append([]byte(nil), data...)
+	// Chunks from other writers, with other codecs and header formats
+	for _, opts := range []Options{
+		{Codec: ZSTD, Level: 3, Shuffle: BitShuffle, TypeSize: 8, BlockChecksums: true},
+		{Codec: Snappy, Level: 1, TypeSize: 4, LegacyFormat: true},
+		{Codec: LZ4, Level: 5, TypeSize: 4, SpecialValues: true},
+	} {
+		part := make([]byte, 4096)
+		if !opts.SpecialValues {
+			part = randomBytes(4096)
+		}
+		chunk, err := CompressWithOptions(part, opts)
+		if err != nil {
+			t.Fatalf("compress failed: %v", err)
+		}
+		stream.Write(chunk)
+		want = append(want, part...)
+	}
+
+	r := NewMultiChunkReader(iotest.OneByteReader(bytes.NewReader(stream.Bytes())))
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("stream does not round trip")
+	}
+	if n := r.Chunks(); n != 10 {
+		t.Errorf("decoded %d chunks, want 10", n)
+	}
+
+	var out bytes.Buffer
+	if n, err := NewMultiChunkReader(bytes.NewReader(stream.Bytes())).WriteTo(&out); err != nil || n != int64(len(want)) {
+		t.Fatalf("WriteTo returned %d, %v", n, err)
+	}
+	if !bytes.Equal(out.Bytes(), want) {
+		t.Error("WriteTo does not round trip")
+	}
+
+	if got, err := io.ReadAll(NewMultiChunkReader(bytes.NewReader(nil))); err != nil || len(got) != 0 {
+		t.Errorf("empty stream read %d bytes, %v", len(got), err)
+	}
+}
+
+func TestMultiChunkReaderErrors(t *testing.T) {
+	data := makeTestData(20000)
+	chunk, err := Compress(data, LZ4, 5, Shuffle1, 4)
+	if err != nil {
+		t.Fatalf("compress failed: %v", err)
+	}
+	stream := append(append([]byte(nil), chunk...), chunk...)
+
+	for _, cut := range []int{len(chunk) + 10, len(stream) - 1} {
+		got, err := io.ReadAll(NewMultiChunkReader(bytes.NewReader(stream[:cut])))
+		if !errors.Is(err, ErrInvalidData) || !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("cut at %d: expected truncation error, got %v", cut, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("cut at %d: read %d bytes before the error, want the first chunk", cut, len(got))
+		}
+	}
+
+	corrupt := append([]byte(nil), stream...)
+	corrupt[len(chunk)] = 9
+	r := NewMultiChunkReader(bytes.NewReader(corrupt))
+	if _, err := io.ReadAll(r); !errors.Is(err, ErrInvalidVersion) {
+		t.Errorf("expected ErrInvalidVersion, got %v", err)
+	}
+	if r.Chunks() != 1 {
+		t.Errorf("decoded %d chunks before the error, want 1", r.Chunks())
+	}
+	// The error is sticky
+	if n, err := r.Read(make([]byte, 10)); n != 0 || !errors.Is(err, ErrInvalidVersion) {
+		t.Errorf("read after error returned %d, %v", n, err)
+	}
+
+	// A forged compressed size is not allocated up front
+	forged := append([]byte(nil), chunk...)
+	forged[offsetNBytesComp+3] = 0xFF
+	if _, err := io.ReadAll(NewMultiChunkReader(bytes.NewReader(forged))); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected truncation error, got %v", err)
+	}
+}
+
+func TestMultiChunkReaderLimit(t *testing.T) {
+	small, err := Compress(makeTestData(1000), LZ4, 5, Shuffle1, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	large, err := Compress(makeTestData(20000), LZ4, 5, Shuffle1, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := append(append([]byte(nil), small...), large...)
+
+	r := NewMultiChunkReaderLimit(bytes.NewReader(stream), 10000)
+	got, err := io.ReadAll(r)
+	if !errors.Is(err, ErrOutputTooLarge) {
+		t.Errorf("expected ErrOutputTooLarge, got %v", err)
+	}
+	if len(got) != 1000 || r.Chunks() != 1 {
+		t.Errorf("read %d bytes in %d chunks before the error, want the first chunk", len(got), r.Chunks())
+	}
+
+	if got, err := io.ReadAll(NewMultiChunkReaderLimit(bytes.NewReader(stream), 20000)); err != nil || len(got) != 21000 {
+		t.Errorf("within the limit: read %d bytes, %v", len(got), err)
+	}
+
+	// A chunk claiming 2 GiB fails before its payload is read
+	bomb := append([]byte(nil), small...)
+	binary.LittleEndian.PutUint32(bomb[offsetNBytesOrig:], 1<<31)
+	src := bytes.NewReader(bomb)
+	if _, err := io.ReadAll(NewMultiChunkReaderLimit(src, 1<<20)); !errors.Is(err, ErrOutputTooLarge) {
+		t.Errorf("bomb: expected ErrOutputTooLarge, got %v", err)
+	}
+	if read := len(bomb) - src.Len(); read > HeaderSize {
+		t.Errorf("bomb: read %d bytes of the chunk", read)
+	}
+}
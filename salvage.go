@@ -0,0 +1,122 @@
+package blosc
+
+import "fmt"
+
+// ByteRange is a span of decompressed data.
+type ByteRange struct {
+	Offset int
+	Length int
+}
+
+// SalvageReport describes what Salvage recovered from a damaged chunk.
+type SalvageReport struct {
+	NBytes    int         // Decompressed size from the header
+	Recovered []ByteRange // Ranges of the output that were decoded, in order, with adjacent blocks merged
+	Errors    []error     // Why each lost block could not be decoded, as *BloscError
+}
+
+// recovered records that n bytes at offset were decoded
+func (r *SalvageReport) recovered(offset, n int) {
+	if n == 0 {
+		return
+	}
+	if last := len(r.Recovered) - 1; last >= 0 && r.Recovered[last].Offset+r.Recovered[last].Length == offset {
+		r.Recovered[last].Length += n
+		return
+	}
+	r.Recovered = append(r.Recovered, ByteRange{Offset: offset, Length: n})
+}
+
+// Salvage decodes as much of a damaged chunk as it can. Each block is decoded
+// on its own, so a corrupt stream, a bad block offset or a truncated chunk
+// loses only the blocks it reaches. partial holds the NBytesOrig bytes the
+// header claims, with lost blocks left zero, and report lists the byte ranges
+// that were recovered.
+//
+// The error is nil if the whole chunk was recovered and the first block
+// error otherwise. If the header itself cannot be used, partial is nil and
+// nothing is recovered. A truncated memcpy chunk yields only the bytes that
+// are left, so partial is shorter than NBytes; legacy go-blosc chunks and
+// special-value chunks have no separate blocks and are recovered whole or
+// not at all.
+//
+// Headers are checked as Decompress checks them before partial is
+// allocated: a chunk claiming more than SetMaxDecodedSize allows fails with
+// ErrOutputTooLarge, and one whose streams cannot decode to the size it
+// claims with ErrInvalidData.
+//
+// Unless a chunk was written with Options.BlockChecksums, a damaged block
+// that still decodes, such as a stored stream with flipped bits, cannot be
+// told from an intact one. With them, such a block fails its checksum and is
+// left zeroed, but blocks of a chunk truncated into its checksums are
+// recovered unchecked.
+func Salvage(data []byte) (partial []byte, report SalvageReport, err error) {
+	header, err := ParseHeader(data)
+	if err != nil {
+		return nil, report, headerError(0, err)
+	}
+	if err := checkFlags(header); err != nil {
+		return nil, report, err
+	}
+	if limit := decodeLimit(-1); limit >= 0 && uint64(header.NBytesOrig) > uint64(limit) {
+		return nil, report, headerError(offsetNBytesOrig, fmt.Errorf("%w: header claims %d bytes, limit is %d", ErrOutputTooLarge, header.NBytesOrig, limit))
+	}
+	hsize := header.Size()
+	if int(header.NBytesComp) < hsize {
+		return nil, report, headerError(offsetNBytesComp, fmt.Errorf("%w: compressed size %d is smaller than the header", ErrInvalidData, header.NBytesComp))
+	}
+	chunk := data
+	if int64(header.NBytesComp) < int64(len(data)) {
+		chunk = data[:header.NBytesComp]
+	}
+
+	nbytes := int(header.NBytesOrig)
+	report.NBytes = nbytes
+	switch {
+	case header.IsMemcpy():
+		// Sized by the bytes present, not the header, which may be damaged
+		partial = make([]byte, min(nbytes, len(chunk)-hsize))
+		n := copy(partial, chunk[hsize:])
+		report.recovered(0, n)
+		if n < nbytes {
+			report.Errors = append(report.Errors, blockError(StageCodec, n/max(int(header.BlockSize), 1), len(chunk),
+				fmt.Errorf("%w: memcpy chunk truncated after %d of %d bytes", ErrInvalidData, n, nbytes)))
+		}
+	case header.legacy || header.Special() != SpecialNone:
+		if header.legacy {
+			if err := checkLegacyExpansion(header); err != nil {
+				return nil, SalvageReport{}, err
+			}
+		}
+		partial = make([]byte, nbytes)
+		if _, err := decompressBackend(partial[:0], data, 0, -1, nil); err != nil {
+			clear(partial)
+			report.Errors = append(report.Errors, err)
+		} else {
+			report.recovered(0, nbytes)
+		}
+	default:
+		// Checked before allocating, as a damaged NBytesOrig would show here
+		if err := checkPayload(header, chunk); err != nil {
+			return nil, SalvageReport{}, err
+		}
+		d, err := newBlockDecoder(header, chunk, int(header.TypeSize), nil)
+		if err != nil {
+			return nil, SalvageReport{}, err
+		}
+		partial = make([]byte, nbytes)
+		for i := 0; i < d.nblocks; i++ {
+			dst := partial[i*d.blockSize : i*d.blockSize+d.blockLen(i)]
+			if err := d.decode(i, dst); err != nil {
+				clear(dst)
+				report.Errors = append(report.Errors, err)
+				continue
+			}
+			report.recovered(i*d.blockSize, len(dst))
+		}
+	}
+	if len(report.Errors) > 0 {
+		return partial, report, report.Errors[0]
+	}
+	return partial, report, nil
+}
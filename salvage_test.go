@@ -0,0 +1,147 @@
+package blosc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestSalvage(t *testing.T) {
+	const blockSize = 4096
+	data := makeTestData(16*blockSize + 100)
+	chunk, err := CompressWithOptions(data, Options{Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: 4, BlockSize: blockSize})
+	if err != nil {
+		t.Fatal(err)
+	}
+	offset := func(c []byte, i int) int {
+		return int(binary.LittleEndian.Uint32(c[HeaderSize+4*i:]))
+	}
+
+	// An intact chunk is recovered whole
+	got, report, err := Salvage(chunk)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("intact chunk: %v", err)
+	}
+	if want := []ByteRange{{0, len(data)}}; !reflect.DeepEqual(report.Recovered, want) {
+		t.Errorf("intact chunk: recovered %v, want %v", report.Recovered, want)
+	}
+
+	// A bad block offset and a bad stream size each lose one block
+	damaged := slices.Clone(chunk)
+	binary.LittleEndian.PutUint32(damaged[HeaderSize+4*3:], 1)
+	binary.LittleEndian.PutUint32(damaged[offset(chunk, 9):], 1<<30)
+	if _, err := Decompress(damaged); err == nil {
+		t.Fatal("damaged chunk decompressed")
+	}
+	got, report, err = Salvage(damaged)
+	var berr *BloscError
+	if !errors.As(err, &berr) || berr.Block != 3 || len(report.Errors) != 2 {
+		t.Fatalf("damaged chunk: got %v, %d errors", err, len(report.Errors))
+	}
+	want := []ByteRange{{0, 3 * blockSize}, {4 * blockSize, 5 * blockSize}, {10 * blockSize, len(data) - 10*blockSize}}
+	if !reflect.DeepEqual(report.Recovered, want) {
+		t.Errorf("damaged chunk: recovered %v, want %v", report.Recovered, want)
+	}
+	checkSalvaged(t, got, data, report)
+
+	// A truncated chunk keeps the blocks before the cut
+	truncated := chunk[:offset(chunk, 6)+10]
+	got, report, err = Salvage(truncated)
+	if !errors.Is(err, ErrInvalidData) || len(report.Errors) != 11 {
+		t.Fatalf("truncated chunk: got %v, %d errors", err, len(report.Errors))
+	}
+	if want := []ByteRange{{0, 6 * blockSize}}; !reflect.DeepEqual(report.Recovered, want) {
+		t.Errorf("truncated chunk: recovered %v, want %v", report.Recovered, want)
+	}
+	checkSalvaged(t, got, data, report)
+
+	// So does a truncated memcpy chunk
+	noise := makeRandomData(10000)
+	memcpy, err := CompressWithOptions(noise, Options{Codec: LZ4, Level: 5, TypeSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, report, err = Salvage(memcpy[:HeaderSize+5000])
+	if !errors.Is(err, ErrInvalidData) || !reflect.DeepEqual(report.Recovered, []ByteRange{{0, 5000}}) {
+		t.Fatalf("truncated memcpy chunk: got %v, recovered %v", err, report.Recovered)
+	}
+	if !bytes.Equal(got, noise[:5000]) || report.NBytes != len(noise) {
+		t.Errorf("truncated memcpy chunk: got %d bytes, NBytes %d", len(got), report.NBytes)
+	}
+
+	// An unusable header recovers nothing
+	for name, bad := range map[string][]byte{
+		"short":   chunk[:10],
+		"version": append([]byte{99}, chunk[1:]...),
+		"table":   binary.LittleEndian.AppendUint32(slices.Clone(chunk[:12]), HeaderSize+3),
+	} {
+		if got, report, err := Salvage(bad); err == nil || got != nil || report.Recovered != nil {
+			t.Errorf("%s: got %d bytes, %v", name, len(got), err)
+		}
+	}
+}
+
+// checkSalvaged checks that the recovered ranges match want and that the
+// rest of got is zero
+func checkSalvaged(t *testing.T, got, want []byte, report SalvageReport) {
+	t.Helper()
+	if len(got) != len(want) || report.NBytes != len(want) {
+		t.Fatalf("got %d bytes, NBytes %d, want %d", len(got), report.NBytes, len(want))
+	}
+	lost := slices.Clone(got)
+	for _, r := range report.Recovered {
+		if !bytes.Equal(got[r.Offset:r.Offset+r.Length], want[r.Offset:r.Offset+r.Length]) {
+			t.Errorf("range %v differs", r)
+		}
+		clear(lost[r.Offset : r.Offset+r.Length])
+	}
+	if !bytes.Equal(lost, make([]byte, len(lost))) {
+		t.Error("lost blocks are not zero")
+	}
+}
+
+func TestSalvageHeaderBomb(t *testing.T) {
+	// Chunks of a few bytes whose headers claim 1 GiB must not make Salvage
+	// allocate it
+	const claimed = 1 << 30
+	memcpy := Header{Version: FormatVersion, VersionLZ: codecFormatVersion, Flags: flagMemcpy, TypeSize: 1, NBytesOrig: claimed, BlockSize: claimed, NBytesComp: HeaderSize + claimed}
+	chunk := append(memcpy.Bytes(), 1, 2, 3, 4)
+	var partial []byte
+	var err error
+	if allocated := allocBytes(func() { partial, _, err = Salvage(chunk) }); allocated > 1<<20 {
+		t.Errorf("memcpy: allocated %d bytes", allocated)
+	}
+	if !errors.Is(err, ErrInvalidData) || !bytes.Equal(partial, []byte{1, 2, 3, 4}) {
+		t.Errorf("memcpy: got %v, %v", partial, err)
+	}
+
+	legacy, err := CompressWithOptions(makeTestData(1000), Options{Codec: LZ4, Level: 5, TypeSize: 4, LegacyFormat: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary.LittleEndian.PutUint32(legacy[4:8], claimed)
+	if allocated := allocBytes(func() { _, _, err = Salvage(legacy) }); allocated > 1<<20 {
+		t.Errorf("legacy: allocated %d bytes", allocated)
+	}
+	if !errors.Is(err, ErrInvalidData) {
+		t.Errorf("legacy: expected ErrInvalidData, got %v", err)
+	}
+
+	// Special-value chunks do claim that much, so only SetMaxDecodedSize
+	// limits them
+	zeros, err := NewZeroChunk(claimed, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := SetMaxDecodedSize(1 << 20)
+	defer SetMaxDecodedSize(prev)
+	if allocated := allocBytes(func() { _, _, err = Salvage(zeros) }); allocated > 1<<20 {
+		t.Errorf("special: allocated %d bytes", allocated)
+	}
+	if !errors.Is(err, ErrOutputTooLarge) {
+		t.Errorf("special: expected ErrOutputTooLarge, got %v", err)
+	}
+}
@@ -0,0 +1,789 @@
+package blosc
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+)
+
+// AdaptivePolicy makes an SChunk or Writer re-pick the codec, level and
+// shuffle mode as data arrives. Every chunk header records how the chunk was
+// compressed, so chunks written under different settings decode the same way.
+type AdaptivePolicy struct {
+	// Interval re-tunes on every Interval-th chunk, starting with the first.
+	// 0 disables adaptation.
+	Interval int
+
+	// Objective is what each re-tune optimizes for.
+	Objective TuneObjective
+
+	// Budget bounds the time of each re-tune (0 = try every candidate).
+	Budget time.Duration
+}
+
+// chunkEncoder compresses successive chunks, re-tuning its options under an
+// AdaptivePolicy. The element size stays fixed, since it describes the data.
+type chunkEncoder struct {
+	opts   Options
+	policy AdaptivePolicy
+	count  int        // Chunks compressed so far
+	env    *filterEnv // What filters know about the container
+
+	// Largest FilterFloatQuantize error of the last chunk, or -1 if it was
+	// not quantized
+	quantError float64
+}
+
+// compress compresses one chunk, first re-tuning if the policy calls for it
+func (e *chunkEncoder) compress(data []byte) ([]byte, error) {
+	e.retune(data)
+	return e.encode(data, e.opts)
+}
+
+// retune re-picks the codec, level and shuffle mode for the next chunk, data,
+// if the policy calls for it
+func (e *chunkEncoder) retune(data []byte) {
+	if e.policy.Interval > 0 && e.count%e.policy.Interval == 0 && len(data) > 0 {
+		typeSize := max(e.opts.TypeSize, 1)
+		tuned, _, err := tune(data, e.policy.Budget, e.policy.Objective, tuneShufflesFor(typeSize))
+		if err == nil {
+			e.opts.Codec = tuned.Codec
+			e.opts.Level = tuned.Level
+			e.opts.Shuffle = tuned.Shuffle
+		}
+	}
+	e.count++
+}
+
+// encode compresses one chunk with opts, noting its FilterFloatQuantize error
+func (e *chunkEncoder) encode(data []byte, opts Options) ([]byte, error) {
+	e.quantError = -1
+	if !quantizes(opts.Filters) {
+		return compressWithEnv(data, opts, e.env)
+	}
+	env := filterEnv{quantError: &e.quantError}
+	if e.env != nil {
+		env = *e.env
+		env.quantError = &e.quantError
+	}
+	return compressWithEnv(data, opts, &env)
+}
+
+// SChunk is an in-memory super-chunk: an ordered sequence of independently
+// compressed chunks, as in Blosc2, plus named metalayers describing them.
+//
+// An SChunk is safe for concurrent use: one goroutine may append chunks while
+// others read and decompress earlier ones. Appended buffers are compressed
+// before the chunk index is locked, so readers wait only while a chunk is
+// stored, and only one buffer is compressed at a time.
+type SChunk struct {
+	encMu sync.Mutex   // Serializes use of enc; taken before mu
+	mu    sync.RWMutex // Guards everything else
+	enc   chunkEncoder
+	delta chunkDelta // Guarded by encMu
+
+	cipher      *chunkCipher // Encrypts stored chunks, if set
+	lockedKey   string       // Key ID of an opened file until SetKeys
+	lockedFrame []byte       // Frame ID of an opened file until SetKeys
+	backing     chunkBacking // Backing frame file or directory, if any
+	source      io.ReaderAt  // Frame the chunks of ToSChunk are read from, if not in memory
+	dirty       bool         // Changed since the backing was last committed
+	cache       *chunkCache  // Recently decompressed chunks, if enabled
+	dedup       *dedupIndex  // Stored chunks by content, if enabled
+	chunks      []storedChunk
+	shared      map[storageKey]int // Chunks beyond the first that share storage
+	nbytes      int64              // Uncompressed size of all chunks
+	cbytes      int64              // Stored size of all chunks, shared storage once
+	metaNames   []string
+	metalayers  map[string][]byte
+}
+
+// storedChunk is a chunk as kept in an SChunk: a Blosc chunk, or one sealed
+// by a chunkCipher, held in memory or at offset in the backing file
+type storedChunk struct {
+	data   []byte // nil when in the backing file
+	offset int64
+	length int64
+	nbytes int64 // Uncompressed size
+}
+
+// NewSChunk returns an empty SChunk that compresses appended buffers with opts.
+// A ZSTDParams.Dict is kept in the "zdict" metalayer, so that frames written
+// from the SChunk hold it.
+func NewSChunk(opts Options) *SChunk {
+	s := &SChunk{enc: chunkEncoder{opts: opts}}
+	if dict := opts.CodecParams.ZSTD.Dict; dict != nil {
+		s.setMetalayer(zstdDictMetalayer, dict.data)
+	}
+	return s
+}
+
+// SetAdaptive sets the policy used to re-tune compression of later buffers.
+func (s *SChunk) SetAdaptive(policy AdaptivePolicy) {
+	s.encMu.Lock()
+	defer s.encMu.Unlock()
+	s.enc.policy = policy
+	s.enc.count = 0
+}
+
+// Options returns the options the next appended buffer will be compressed
+// with, before any re-tuning.
+func (s *SChunk) Options() Options {
+	s.encMu.Lock()
+	defer s.encMu.Unlock()
+	return s.enc.opts
+}
+
+// AppendBuffer compresses data as a new chunk and returns its index.
+func (s *SChunk) AppendBuffer(data []byte) (int, error) {
+	s.encMu.Lock()
+	defer s.encMu.Unlock()
+	s.enc.retune(data)
+	return s.appendBuffer(data, s.enc.opts)
+}
+
+// AppendBufferWith compresses data as a new chunk with the options of s
+// changed by options, such as WithCodec(ZSTD) or WithFilters, and returns
+// its index. The changes apply to this chunk alone, and settings they leave
+// alone keep the values of s, so a stream can keep a text chunk with ZSTD
+// and no shuffle among float chunks. Each chunk's header records its codec,
+// shuffle, type size and filters, so the chunks read as any other. An
+// AdaptivePolicy does not re-tune such chunks. The ZSTD dictionary stays
+// that of s, which its frames hold.
+func (s *SChunk) AppendBufferWith(data []byte, options ...Option) (int, error) {
+	s.encMu.Lock()
+	defer s.encMu.Unlock()
+	opts := s.enc.opts
+	for _, option := range options {
+		if err := option(&opts); err != nil {
+			return 0, err
+		}
+	}
+	if opts.CodecParams.ZSTD.Dict != s.enc.opts.CodecParams.ZSTD.Dict {
+		return 0, fmt.Errorf("%w: the ZSTD dictionary of an SChunk cannot change", ErrInvalidOption)
+	}
+	return s.appendBuffer(data, opts)
+}
+
+// appendBuffer compresses data with opts, as a FilterChunkDelta chunk if
+// SetChunkDelta calls for one, and appends it. The caller holds s.encMu.
+func (s *SChunk) appendBuffer(data []byte, opts Options) (int, error) {
+	ref, n, err := s.deltaReference(opts)
+	if err != nil {
+		return 0, err
+	}
+	var chunk []byte
+	if ref != nil {
+		chunk, err = s.enc.encodeDelta(data, ref, opts)
+	} else {
+		chunk, err = s.enc.encode(data, opts)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ref != nil && len(s.chunks) != n {
+		// Chunks were appended since the reference was taken
+		if chunk, err = s.enc.encode(data, opts); err != nil {
+			return 0, err
+		}
+	}
+	i, err := s.append(chunk, len(data))
+	if err != nil {
+		return 0, err
+	}
+	if s.enc.quantError >= 0 {
+		s.noteQuantizeError(s.enc.quantError)
+	}
+	s.delta.appended(s.chunks[i], i, data, opts)
+	return i, nil
+}
+
+// AppendFrom compresses the next n bytes of r into chunks of chunkSize
+// bytes, the last one shorter, and appends them, reading one chunk at a time.
+// It returns the number of chunks appended, which remain appended if a
+// later read or compression fails. chunkSize is handled as by NewWriterSize.
+// Since sizes are 64 bits, data beyond what one chunk holds, including 4 GiB
+// and more, is split into chunks rather than truncated. Hooks.Progress is
+// told of the bytes done out of all n.
+func (s *SChunk) AppendFrom(r io.Reader, n int64, chunkSize int) (int, error) {
+	size := int64(chunkSizeFor(chunkSize, s.Options().TypeSize))
+	if n < size {
+		size = n
+	}
+	buf := make([]byte, max(size, 0))
+	count := 0
+	total := n
+	for ; n > 0; n -= int64(len(buf)) {
+		if n < int64(len(buf)) {
+			buf = buf[:n]
+		}
+		if _, err := readFull(r, buf); err != nil {
+			return count, err
+		}
+		if _, err := s.appendPart(buf, total-n, total); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// appendPart appends data like AppendBuffer, as the bytes from offset on of
+// the total AppendFrom reports progress for
+func (s *SChunk) appendPart(data []byte, offset, total int64) (int, error) {
+	s.encMu.Lock()
+	defer s.encMu.Unlock()
+	s.enc.retune(data)
+	opts := s.enc.opts
+	if progress := opts.progress(); progress != nil {
+		opts.ownHooks().Progress = func(done, _ int64) { progress(offset+done, total) }
+	}
+	return s.appendBuffer(data, opts)
+}
+
+// AppendChunk appends an already compressed chunk and returns its index. The
+// chunk is checked with Validate and, unless it is encrypted or written to a
+// file, kept without copying.
+func (s *SChunk) AppendChunk(chunk []byte) (int, error) {
+	if err := Validate(chunk); err != nil {
+		return 0, err
+	}
+	header, _ := ParseHeader(chunk)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := checkDeltaFirst(len(s.chunks), header); err != nil {
+		return 0, err
+	}
+	i, err := s.append(chunk, int(header.NBytesOrig))
+	if err == nil {
+		s.noteChunkQuantizeError(chunk)
+	}
+	return i, err
+}
+
+// UpdateChunk replaces chunk i with an already compressed chunk, checked with
+// Validate.
+func (s *SChunk) UpdateChunk(i int, chunk []byte) error {
+	s.encMu.Lock()
+	defer s.encMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkIndex(i, len(s.chunks)); err != nil {
+		return err
+	}
+	if err := Validate(chunk); err != nil {
+		return err
+	}
+	header, _ := ParseHeader(chunk)
+	if err := checkDeltaFirst(i, header); err != nil {
+		return err
+	}
+	next, err := s.follower(i + 1)
+	if err != nil {
+		return err
+	}
+	if err := s.setChunk(i, chunk, int(header.NBytesOrig)); err != nil {
+		return err
+	}
+	s.noteChunkQuantizeError(chunk)
+	return s.rekey(i+1, next)
+}
+
+// InsertChunk inserts an already compressed chunk, checked with Validate, so
+// that it becomes chunk i; i may be NumChunks to append. Later chunks move up
+// by one, and in an encrypted SChunk are sealed again for their new index.
+func (s *SChunk) InsertChunk(i int, chunk []byte) error {
+	s.encMu.Lock()
+	defer s.encMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkIndex(i, len(s.chunks)+1); err != nil {
+		return err
+	}
+	if err := Validate(chunk); err != nil {
+		return err
+	}
+	header, _ := ParseHeader(chunk)
+	if err := checkDeltaFirst(i, header); err != nil {
+		return err
+	}
+	next, err := s.follower(i)
+	if err != nil {
+		return err
+	}
+	moved, err := s.moveSealed(i, 1)
+	if err != nil {
+		return err
+	}
+	stored, shared, err := s.store(i, chunk, int(header.NBytesOrig))
+	if err != nil {
+		return err
+	}
+	s.add(stored, shared)
+	s.chunks = slices.Insert(s.chunks, i, stored)
+	s.replaceSealed(i+1, moved)
+	s.cache.clear()
+	s.noteChunkQuantizeError(chunk)
+	return s.rekey(i+1, next)
+}
+
+// DeleteChunk removes chunk i. Later chunks move down by one, and in an
+// encrypted SChunk are sealed again for their new index. In a file, the
+// chunk's bytes stay in place but are no longer indexed; in a directory, the
+// chunk's file is removed by the next Sync.
+func (s *SChunk) DeleteChunk(i int) error {
+	s.encMu.Lock()
+	defer s.encMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkIndex(i, len(s.chunks)); err != nil {
+		return err
+	}
+	next, err := s.follower(i + 1)
+	if err != nil {
+		return err
+	}
+	moved, err := s.moveSealed(i+1, -1)
+	if err != nil {
+		return err
+	}
+	old := s.chunks[i]
+	s.chunks = slices.Delete(s.chunks, i, i+1)
+	s.drop(old)
+	s.replaceSealed(i, moved)
+	s.cache.clear()
+	s.touch()
+	return s.rekey(i, next)
+}
+
+// checkIndex checks that 0 <= i < n
+func (s *SChunk) checkIndex(i, n int) error {
+	if i < 0 || i >= n {
+		return fmt.Errorf("%w: %d of %d", ErrChunkIndex, i, len(s.chunks))
+	}
+	return nil
+}
+
+func (s *SChunk) append(chunk []byte, nbytes int) (int, error) {
+	stored, shared, err := s.store(len(s.chunks), chunk, nbytes)
+	if err != nil {
+		return 0, err
+	}
+	s.add(stored, shared)
+	s.chunks = append(s.chunks, stored)
+	return len(s.chunks) - 1, nil
+}
+
+// setChunk replaces chunk i, which must exist, with a chunk of nbytes
+// uncompressed bytes. In a file, the new chunk is appended and the old one
+// left in place.
+func (s *SChunk) setChunk(i int, chunk []byte, nbytes int) error {
+	stored, shared, err := s.store(i, chunk, nbytes)
+	if err != nil {
+		return err
+	}
+	s.add(stored, shared)
+	old := s.chunks[i]
+	s.chunks[i] = stored
+	s.drop(old)
+	s.cache.remove(i)
+	return nil
+}
+
+// recompressChunk compresses data with the options of s as chunk i, which
+// must exist
+func (s *SChunk) recompressChunk(i int, data []byte) error {
+	s.encMu.Lock()
+	defer s.encMu.Unlock()
+	chunk, err := s.enc.compress(data)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkIndex(i, len(s.chunks)); err != nil {
+		return err
+	}
+	next, err := s.follower(i + 1)
+	if err != nil {
+		return err
+	}
+	if err := s.setChunk(i, chunk, len(data)); err != nil {
+		return err
+	}
+	if s.enc.quantError >= 0 {
+		s.noteQuantizeError(s.enc.quantError)
+	}
+	return s.rekey(i+1, next)
+}
+
+// store prepares a chunk for storage as chunk i, encrypting it if needed,
+// and reports whether it shares the storage of an identical chunk under
+// SetDedup. Encrypted chunks are bound to their index and never shared.
+func (s *SChunk) store(i int, chunk []byte, nbytes int) (storedChunk, bool, error) {
+	if s.lockedKey != "" {
+		return storedChunk{}, false, fmt.Errorf("%w: SChunk is encrypted with key %q", ErrInvalidKey, s.lockedKey)
+	}
+	if s.dedup == nil || s.cipher != nil {
+		stored, err := s.storeWith(s.cipher, i, chunk, nbytes)
+		return stored, false, err
+	}
+	sum := sha256.Sum256(chunk)
+	if stored, ok := s.dedup.chunks[sum]; ok {
+		s.touch()
+		return stored, true, nil
+	}
+	stored, err := s.storeWith(s.cipher, i, chunk, nbytes)
+	if err != nil {
+		return storedChunk{}, false, err
+	}
+	s.dedup.add(sum, stored)
+	return stored, false, nil
+}
+
+// add counts a chunk about to be indexed in the totals, its storage only if
+// not shared with another chunk
+func (s *SChunk) add(c storedChunk, shared bool) {
+	s.nbytes += c.nbytes
+	if !shared {
+		s.cbytes += c.length
+		return
+	}
+	if s.shared == nil {
+		s.shared = make(map[storageKey]int)
+	}
+	s.shared[c.key()]++
+}
+
+// drop removes a chunk no longer indexed from the totals, and releases its
+// storage unless another chunk still shares it
+func (s *SChunk) drop(c storedChunk) {
+	s.nbytes -= c.nbytes
+	k := c.key()
+	if n := s.shared[k]; n > 0 {
+		if n == 1 {
+			delete(s.shared, k)
+		} else {
+			s.shared[k] = n - 1
+		}
+		return
+	}
+	s.cbytes -= c.length
+	s.dedup.remove(k)
+	s.release(c)
+}
+
+// recount recomputes the totals from the chunk index, finding the chunks
+// that share storage
+func (s *SChunk) recount() {
+	s.nbytes, s.cbytes, s.shared = 0, 0, nil
+	seen := make(map[storageKey]bool, len(s.chunks))
+	for _, c := range s.chunks {
+		k := c.key()
+		s.add(c, seen[k])
+		seen[k] = true
+	}
+}
+
+// storeWith prepares a chunk for storage as chunk i under cipher c, which
+// may be nil, writing it to the backing file if there is one
+func (s *SChunk) storeWith(c *chunkCipher, i int, chunk []byte, nbytes int) (storedChunk, error) {
+	if c != nil {
+		sealed, err := c.seal(chunk, i)
+		if err != nil {
+			return storedChunk{}, err
+		}
+		chunk = sealed
+	}
+	stored := storedChunk{data: chunk, length: int64(len(chunk)), nbytes: int64(nbytes)}
+	if s.backing != nil {
+		offset, err := s.backing.write(chunk)
+		if err != nil {
+			return storedChunk{}, err
+		}
+		stored.data, stored.offset = nil, offset
+		s.dirty = true
+	}
+	return stored, nil
+}
+
+// moveSealed returns the chunks of s from index from on sealed again for the
+// index shift away, where an insertion or deletion is about to move them,
+// or nil if s is not encrypted. Sealed chunks authenticate their index, so
+// they cannot move as they are. Nothing in s changes until replaceSealed.
+func (s *SChunk) moveSealed(from, shift int) ([]storedChunk, error) {
+	if s.cipher == nil && s.lockedKey == "" {
+		return nil, nil
+	}
+	moved := make([]storedChunk, 0, len(s.chunks)-from)
+	for i := from; i < len(s.chunks); i++ {
+		chunk, err := s.chunk(i)
+		if err != nil {
+			return nil, err
+		}
+		stored, err := s.storeWith(s.cipher, i+shift, chunk, int(s.chunks[i].nbytes))
+		if err != nil {
+			return nil, err
+		}
+		moved = append(moved, stored)
+	}
+	return moved, nil
+}
+
+// replaceSealed replaces the chunks of s from index from on with moved, as
+// moveSealed returned them once they have moved
+func (s *SChunk) replaceSealed(from int, moved []storedChunk) {
+	for i, stored := range moved {
+		s.add(stored, false)
+		s.drop(s.chunks[from+i])
+		s.chunks[from+i] = stored
+	}
+}
+
+// release tells the backing, if any, that a chunk is no longer indexed
+func (s *SChunk) release(c storedChunk) {
+	if s.backing != nil && c.data == nil {
+		s.backing.release(c.offset)
+	}
+}
+
+// stored returns chunk i as stored, reading it from the backing file or
+// source frame if needed
+func (s *SChunk) stored(i int) ([]byte, error) {
+	c := s.chunks[i]
+	switch {
+	case c.data != nil:
+		return c.data, nil
+	case s.backing != nil:
+		return s.backing.read(c.offset, c.length)
+	case s.source != nil:
+		return readAt(s.source, c.offset, c.length)
+	}
+	return c.data, nil
+}
+
+// SetEncryption encrypts the chunks of s with AES-GCM under the key that keys
+// returns for keyID. Chunks are compressed, then encrypted one by one, so
+// each can still be read on its own, and each is bound to its index and to
+// a random ID of s, which its frames record, so that chunks reordered or
+// taken from another frame fail authentication. Encrypted chunks therefore
+// never share storage under SetDedup. Chunks already in s are re-encrypted
+// under the new key and a new ID; a nil keys removes encryption.
+func (s *SChunk) SetEncryption(keyID string, keys KeyProvider) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var c *chunkCipher
+	if keys != nil {
+		frameID, err := newFrameID()
+		if err != nil {
+			return err
+		}
+		if c, err = newChunkCipher(keyID, frameID, keys); err != nil {
+			return err
+		}
+	}
+
+	// Re-encrypt into a copy, so that s is unchanged on failure. A file
+	// gets new copies of its chunks, shared as the old ones were unless
+	// they are encrypted.
+	chunks := make([]storedChunk, len(s.chunks))
+	moved := make(map[storageKey]storedChunk)
+	var dedup *dedupIndex
+	if s.dedup != nil {
+		dedup = newDedupIndex()
+	}
+	for i, old := range s.chunks {
+		if stored, ok := moved[old.key()]; ok && c == nil {
+			chunks[i] = stored
+			continue
+		}
+		chunk, err := s.chunk(i)
+		if err != nil {
+			return err
+		}
+		if chunks[i], err = s.storeWith(c, i, chunk, int(old.nbytes)); err != nil {
+			return err
+		}
+		moved[old.key()] = chunks[i]
+		if dedup != nil && c == nil {
+			dedup.add(sha256.Sum256(chunk), chunks[i])
+		}
+	}
+	for _, old := range s.chunks {
+		if _, ok := moved[old.key()]; ok {
+			delete(moved, old.key())
+			s.release(old)
+		}
+	}
+	s.cipher = c
+	s.chunks = chunks
+	s.dedup = dedup
+	s.recount()
+	s.touch()
+	return nil
+}
+
+// SetKeys sets the provider of the key an SChunk opened from an encrypted
+// file is encrypted with. It does nothing for other SChunks.
+func (s *SChunk) SetKeys(keys KeyProvider) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lockedKey == "" {
+		return nil
+	}
+	c, err := newChunkCipher(s.lockedKey, s.lockedFrame, keys)
+	if err != nil {
+		return err
+	}
+	s.cipher = c
+	s.lockedKey, s.lockedFrame = "", nil
+	return nil
+}
+
+// KeyID returns the ID of the key s is encrypted with, or "" if it is not
+// encrypted.
+func (s *SChunk) KeyID() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.keyID()
+}
+
+func (s *SChunk) keyID() string {
+	if s.cipher == nil {
+		return s.lockedKey
+	}
+	return s.cipher.keyID
+}
+
+// frameID returns the ID the chunks of s are bound to, if it is encrypted
+func (s *SChunk) frameID() []byte {
+	if s.cipher == nil {
+		return s.lockedFrame
+	}
+	return s.cipher.frameID
+}
+
+// SetMetalayer stores content under name, replacing any previous content.
+// Metalayers carry metadata about the chunks, such as an NDArray's shape.
+func (s *SChunk) SetMetalayer(name string, content []byte) {
+	s.encMu.Lock()
+	defer s.encMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setMetalayer(name, content)
+}
+
+// setMetalayer implements SetMetalayer. The caller holds s.mu, and s.encMu
+// too for the "b2nd" and "zdict" metalayers, which change how chunks are
+// compressed. The dictionary in "zdict" compresses later ZSTD chunks, so
+// that all of them decompress with the one the SChunk keeps.
+func (s *SChunk) setMetalayer(name string, content []byte) {
+	if s.metalayers == nil {
+		s.metalayers = make(map[string][]byte)
+	}
+	if _, ok := s.metalayers[name]; !ok {
+		s.metaNames = append(s.metaNames, name)
+	}
+	s.metalayers[name] = content
+	s.touch()
+
+	if name == b2ndMetalayer || name == zstdDictMetalayer {
+		s.enc.env = containerEnv(s.metalayers, s.enc.opts.CodecParams.ZSTD.Dict)
+		if name == zstdDictMetalayer {
+			s.enc.opts.CodecParams.ZSTD.Dict = nil
+			if s.enc.env != nil {
+				s.enc.opts.CodecParams.ZSTD.Dict = s.enc.env.dict
+			}
+		}
+		s.cache.clear()
+	}
+}
+
+// Metalayer returns the content stored under name.
+func (s *SChunk) Metalayer(name string) ([]byte, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	content, ok := s.metalayers[name]
+	return content, ok
+}
+
+// Metalayers returns the metalayer names in the order they were added.
+func (s *SChunk) Metalayers() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.metaNames)
+}
+
+// NumChunks returns the number of chunks.
+func (s *SChunk) NumChunks() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.chunks)
+}
+
+// NBytes returns the uncompressed size of all chunks.
+func (s *SChunk) NBytes() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.nbytes
+}
+
+// CBytes returns the stored size of all chunks, headers and any encryption
+// overhead included. Chunks that share storage under SetDedup count once.
+func (s *SChunk) CBytes() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cbytes
+}
+
+// Chunk returns compressed chunk i. The slice is shared with the SChunk,
+// unless the chunk is read from a file or decrypted into a new one.
+func (s *SChunk) Chunk(i int) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.chunk(i)
+}
+
+func (s *SChunk) chunk(i int) ([]byte, error) {
+	if err := s.checkIndex(i, len(s.chunks)); err != nil {
+		return nil, err
+	}
+	if s.lockedKey != "" {
+		return nil, fmt.Errorf("%w: SChunk is encrypted with key %q", ErrInvalidKey, s.lockedKey)
+	}
+	stored, err := s.stored(i)
+	if err != nil {
+		return nil, err
+	}
+	if s.cipher != nil {
+		return s.cipher.open(stored, i)
+	}
+	return stored, nil
+}
+
+// DecompressChunk decompresses chunk i, or copies it from the cache set
+// with SetCache.
+func (s *SChunk) DecompressChunk(i int) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.decompress(i)
+}
+
+// decompress implements DecompressChunk for a caller holding s.mu
+func (s *SChunk) decompress(i int) ([]byte, error) {
+	if data, ok := s.cache.get(i); ok {
+		return data, nil
+	}
+	data, err := decompressChained(i, s.chunk, s.cache, s.enc.env)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.put(i, data)
+	return data, nil
+}
@@ -0,0 +1,315 @@
+package blosc
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestSChunkAppendAndDecompress(t *testing.T) {
+	sc := NewSChunk(Options{Codec: ZSTD, Level: 5, Shuffle: Shuffle1, TypeSize: 4})
+	inputs := [][]byte{makeFloatData(1000), makeTestData(5000), makeFloatData(3)}
+
+	for i, data := range inputs {
+		idx, err := sc.AppendBuffer(data)
+		if err != nil {
+			t.Fatalf("append %d failed: %v", i, err)
+		}
+		if idx != i {
+			t.Errorf("append returned index %d, want %d", idx, i)
+		}
+	}
+
+	precompressed, err := Compress(makeTestData(777), LZ4, 5, NoShuffle, 1)
+	if err != nil {
+		t.Fatalf("compress failed: %v", err)
+	}
+	if _, err := sc.AppendChunk(precompressed); err != nil {
+		t.Fatalf("append chunk failed: %v", err)
+	}
+	inputs = append(inputs, makeTestData(777))
+
+	if sc.NumChunks() != len(inputs) {
+		t.Fatalf("NumChunks = %d, want %d", sc.NumChunks(), len(inputs))
+	}
+	var nbytes int64
+	for i, want := range inputs {
+		got, err := sc.DecompressChunk(i)
+		if err != nil {
+			t.Fatalf("decompress chunk %d failed: %v", i, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("chunk %d does not round trip", i)
+		}
+		nbytes += int64(len(want))
+	}
+	if sc.NBytes() != nbytes {
+		t.Errorf("NBytes = %d, want %d", sc.NBytes(), nbytes)
+	}
+	if sc.CBytes() <= 0 || sc.CBytes() >= nbytes {
+		t.Errorf("CBytes = %d for %d bytes", sc.CBytes(), nbytes)
+	}
+}
+
+func TestSChunkErrors(t *testing.T) {
+	sc := NewSChunk(DefaultOptions())
+	if _, err := sc.Chunk(0); !errors.Is(err, ErrChunkIndex) {
+		t.Errorf("expected ErrChunkIndex, got %v", err)
+	}
+	if _, err := sc.DecompressChunk(-1); !errors.Is(err, ErrChunkIndex) {
+		t.Errorf("expected ErrChunkIndex, got %v", err)
+	}
+	if _, err := sc.AppendChunk([]byte{1, 2, 3}); err == nil {
+		t.Error("expected an error appending a malformed chunk")
+	}
+	if sc.NumChunks() != 0 {
+		t.Errorf("failed appends added chunks: %d", sc.NumChunks())
+	}
+}
+
+func TestSChunkAppendFrom(t *testing.T) {
+	sc := NewSChunk(Options{Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: 4})
+	data := makeFloatData(10000)
+	n, err := sc.AppendFrom(bytes.NewReader(data), int64(len(data)), 10001)
+	if err != nil {
+		t.Fatalf("AppendFrom failed: %v", err)
+	}
+	// 10001 rounds down to 10000, a multiple of the element size
+	if n != 4 || sc.NumChunks() != 4 || sc.NBytes() != int64(len(data)) {
+		t.Errorf("appended %d chunks, %d in all, of %d bytes", n, sc.NumChunks(), sc.NBytes())
+	}
+	checkChunks(t, sc, [][]byte{data[:10000], data[10000:20000], data[20000:30000], data[30000:]})
+
+	// Chunks read before a short read stay appended
+	n, err = sc.AppendFrom(bytes.NewReader(data[:25000]), int64(len(data)), 10000)
+	if !errors.Is(err, io.ErrUnexpectedEOF) || n != 2 || sc.NumChunks() != 6 {
+		t.Errorf("short read: %d chunks appended, %v", n, err)
+	}
+	if n, err := sc.AppendFrom(bytes.NewReader(nil), 0, 0); n != 0 || err != nil {
+		t.Errorf("empty input: %d chunks, %v", n, err)
+	}
+}
+
+func TestSChunkAdaptive(t *testing.T) {
+	opts := Options{Codec: Snappy, Level: 5, Shuffle: NoShuffle, TypeSize: 4}
+	sc := NewSChunk(opts)
+	sc.SetAdaptive(AdaptivePolicy{Interval: 2, Objective: TuneRatio})
+
+	data := makeFloatData(20000)
+	for i := 0; i < 4; i++ {
+		if _, err := sc.AppendBuffer(data); err != nil {
+			t.Fatalf("append failed: %v", err)
+		}
+	}
+
+	for i := 0; i < sc.NumChunks(); i++ {
+		chunk, _ := sc.Chunk(i)
+		header, err := ParseHeader(chunk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Shuffling float data always beats storing it as-is
+		if header.ShuffleMode() == NoShuffle || header.TypeSize != 4 {
+			t.Errorf("chunk %d: shuffle %s typeSize %d after re-tuning", i, header.ShuffleMode(), header.TypeSize)
+		}
+		got, err := sc.DecompressChunk(i)
+		if err != nil || !bytes.Equal(got, data) {
+			t.Errorf("chunk %d does not round trip: %v", i, err)
+		}
+	}
+	if reflect.DeepEqual(sc.Options(), opts) {
+		t.Error("options were not re-tuned")
+	}
+}
+
+func TestSChunkAppendBufferWith(t *testing.T) {
+	opts := Options{Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: 4}
+	sc := NewSChunk(opts)
+	floats := makeFloatData(20000)
+	text := bytes.Repeat([]byte("name=sensor-7 units=kelvin rate=10Hz\n"), 500)
+
+	if _, err := sc.AppendBuffer(floats); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sc.AppendBufferWith(text, WithCodec(ZSTD), WithLevel(9), WithNoShuffle(), WithTypeSize(1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sc.AppendBufferWith(floats, WithFilters(FilterStage{Filter: FilterShuffle}, FilterStage{Filter: FilterByteDelta})); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sc.AppendBuffer(floats); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		codec    Codec
+		shuffle  Shuffle
+		typeSize uint8
+		extended bool
+	}{
+		{LZ4, Shuffle1, 4, false},
+		{ZSTD, NoShuffle, 1, false},
+		{LZ4, Shuffle1, 4, true}, // Defaults kept, filters added
+		{LZ4, Shuffle1, 4, false},
+	}
+	for i, w := range want {
+		chunk, _ := sc.Chunk(i)
+		h, err := ParseHeader(chunk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.Codec() != w.codec || h.ShuffleMode() != w.shuffle || h.TypeSize != w.typeSize || h.IsExtended() != w.extended {
+			t.Errorf("chunk %d: %s %s typesize %d extended %v", i, h.Codec(), h.ShuffleMode(), h.TypeSize, h.IsExtended())
+		}
+		data := floats
+		if i == 1 {
+			data = text
+		}
+		if got, err := sc.DecompressChunk(i); err != nil || !bytes.Equal(got, data) {
+			t.Errorf("chunk %d does not round trip: %v", i, err)
+		}
+	}
+	if !reflect.DeepEqual(sc.Options(), opts) {
+		t.Errorf("options changed to %+v", sc.Options())
+	}
+
+	if _, err := sc.AppendBufferWith(text, WithLevel(12)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("WithLevel(12): %v", err)
+	}
+	if sc.NumChunks() != len(want) {
+		t.Errorf("%d chunks after a failed append", sc.NumChunks())
+	}
+}
+
+func TestSChunkConcurrentAppendRead(t *testing.T) {
+	sc := NewSChunk(Options{Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: 4})
+	sc.SetCache(1 << 16)
+	chunkData := func(i int) []byte {
+		return bytes.Repeat([]byte{byte(i), byte(i >> 8), 1, 2}, 1000+i)
+	}
+
+	// One goroutine appends while others read what is there so far
+	const n = 200
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(done)
+		for i := 0; i < n; i++ {
+			if _, err := sc.AppendBuffer(chunkData(i)); err != nil {
+				t.Errorf("append %d: %v", i, err)
+				return
+			}
+		}
+	}()
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				for i := 0; i < sc.NumChunks(); i++ {
+					got, err := sc.DecompressChunk(i)
+					if err != nil || !bytes.Equal(got, chunkData(i)) {
+						t.Errorf("chunk %d: %v", i, err)
+						return
+					}
+				}
+				_ = sc.NBytes()
+				_, _ = sc.Metalayer("meta")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := sc.NumChunks(); got != n {
+		t.Fatalf("%d chunks, want %d", got, n)
+	}
+	for i, chunk := range sc.DecompressedChunks() {
+		if !bytes.Equal(chunk, chunkData(i)) {
+			t.Errorf("chunk %d differs", i)
+		}
+	}
+}
+
+func TestSChunkUpdateInsertDelete(t *testing.T) {
+	sc := NewSChunk(Options{Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: 4})
+	want := [][]byte{makeTestData(1000), makeTestData(2000), makeTestData(3000)}
+	for _, data := range want {
+		if _, err := sc.AppendBuffer(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	testUpdateInsertDelete(t, sc, want)
+}
+
+// testUpdateInsertDelete edits sc, which holds want, and checks the result
+func testUpdateInsertDelete(t *testing.T, sc *SChunk, want [][]byte) {
+	t.Helper()
+	compress := func(data []byte) []byte {
+		chunk, err := Compress(data, ZSTD, 5, NoShuffle, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return chunk
+	}
+
+	updated := makeFloatData(500)
+	if err := sc.UpdateChunk(1, compress(updated)); err != nil {
+		t.Fatalf("UpdateChunk failed: %v", err)
+	}
+	want[1] = updated
+
+	first, last := makeTestData(10), makeTestData(4000)
+	if err := sc.InsertChunk(0, compress(first)); err != nil {
+		t.Fatalf("InsertChunk failed: %v", err)
+	}
+	if err := sc.InsertChunk(sc.NumChunks(), compress(last)); err != nil {
+		t.Fatalf("InsertChunk at the end failed: %v", err)
+	}
+	want = append([][]byte{first}, append(want, last)...)
+
+	if err := sc.DeleteChunk(2); err != nil {
+		t.Fatalf("DeleteChunk failed: %v", err)
+	}
+	want = append(want[:2], want[3:]...)
+
+	checkChunks(t, sc, want)
+	var nbytes, cbytes int64
+	for i, data := range want {
+		nbytes += int64(len(data))
+		chunk, _ := sc.Chunk(i)
+		cbytes += int64(len(chunk))
+	}
+	if sc.NBytes() != nbytes {
+		t.Errorf("NBytes = %d, want %d", sc.NBytes(), nbytes)
+	}
+	if sc.KeyID() == "" && sc.CBytes() != cbytes {
+		t.Errorf("CBytes = %d, want %d", sc.CBytes(), cbytes)
+	}
+
+	n := sc.NumChunks()
+	if err := sc.UpdateChunk(n, compress(first)); !errors.Is(err, ErrChunkIndex) {
+		t.Errorf("expected ErrChunkIndex updating past the end, got %v", err)
+	}
+	if err := sc.InsertChunk(n+1, compress(first)); !errors.Is(err, ErrChunkIndex) {
+		t.Errorf("expected ErrChunkIndex inserting past the end, got %v", err)
+	}
+	if err := sc.DeleteChunk(-1); !errors.Is(err, ErrChunkIndex) {
+		t.Errorf("expected ErrChunkIndex deleting -1, got %v", err)
+	}
+	if err := sc.InsertChunk(0, []byte{1, 2, 3}); err == nil {
+		t.Error("expected an error inserting a malformed chunk")
+	}
+	if sc.NumChunks() != n {
+		t.Errorf("failed edits changed the chunk count to %d", sc.NumChunks())
+	}
+}
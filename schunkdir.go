@@ -0,0 +1,169 @@
+package blosc
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Sparse frame layout: a directory holding each chunk in a file of its own,
+// named by its key as %08X.chunk, and the index file chunks.gbframe, a frame
+// with the frameSparse flag and no chunk data. The chunk files are named as
+// in Blosc2 sparse frames, but the index is a go-blosc frame, which c-blosc2
+// cannot open.
+const (
+	sparseIndexName   = "chunks.gbframe"
+	sparseChunkSuffix = ".chunk"
+)
+
+// DirStore is a ChunkStore keeping each chunk in a file of its own in a
+// directory, in the sparse frame layout. Files are replaced by writing a
+// temporary file and renaming it, so each Put is atomic.
+type DirStore struct {
+	dir string
+}
+
+// NewDirStore returns a DirStore in the directory at dir, creating it if
+// needed.
+func NewDirStore(dir string) (*DirStore, error) {
+	if err := os.MkdirAll(dir, 0o777); err != nil {
+		return nil, err
+	}
+	return &DirStore{dir: dir}, nil
+}
+
+// path returns the path of the file holding key
+func (d *DirStore) path(key int64) (string, error) {
+	switch {
+	case key == IndexKey:
+		return filepath.Join(d.dir, sparseIndexName), nil
+	case key < 0:
+		return "", fmt.Errorf("%w: chunk key %d", ErrChunkIndex, key)
+	}
+	return filepath.Join(d.dir, fmt.Sprintf("%08X%s", key, sparseChunkSuffix)), nil
+}
+
+// Get reads the file holding key.
+func (d *DirStore) Get(key int64) ([]byte, error) {
+	path, err := d.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+// Put writes chunk to the file for key and flushes it to disk.
+func (d *DirStore) Put(key int64, chunk []byte) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	if err := writeFileSync(path+".tmp", chunk); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+
+	// Make the rename durable before later deletes. Some platforms cannot
+	// sync a directory, and rely on rename being durable instead.
+	syncDir(d.dir)
+	return nil
+}
+
+// Delete removes the file holding key.
+func (d *DirStore) Delete(key int64) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// keys returns the keys of the chunk files in the directory
+func (d *DirStore) keys() ([]int64, error) {
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		return nil, err
+	}
+	var keys []int64
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), sparseChunkSuffix)
+		if !ok || len(name) != 8 {
+			continue
+		}
+		if key, err := strconv.ParseInt(name, 16, 64); err == nil {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// writeFileSync writes p to the file at path, replacing it, and flushes it
+// to disk
+func writeFileSync(path string, p []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o666)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(p)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// syncDir flushes the entries of directory dir to disk
+func syncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = f.Sync()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// CreateSChunkDir creates a sparse frame in the directory at path, creating
+// the directory if needed and replacing any sparse frame already there, and
+// returns an empty SChunk backed by it that compresses appended buffers with
+// opts. It is CreateSChunkStore with a DirStore, except that every chunk
+// file in the directory is removed, committed or not. Each chunk is written
+// to a file of its own as it is appended, so chunks can be copied to and
+// from object stores one at a time.
+func CreateSChunkDir(path string, opts Options) (*SChunk, error) {
+	d, err := NewDirStore(path)
+	if err != nil {
+		return nil, err
+	}
+	old, err := d.keys()
+	if err != nil {
+		return nil, err
+	}
+	b := &storeBacking{store: d}
+	for _, key := range old {
+		b.release(key)
+		b.next = max(b.next, key+1)
+	}
+	return createSChunk(b, opts)
+}
+
+// OpenSChunkDir opens the sparse frame in the directory at path to read its
+// chunks and append new ones, compressed with opts. It is OpenSChunkStore
+// with a DirStore.
+func OpenSChunkDir(path string, opts Options) (*SChunk, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	return OpenSChunkStore(&DirStore{dir: path}, opts)
+}
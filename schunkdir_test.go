@@ -0,0 +1,258 @@
+package blosc
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// listChunkFiles returns the sorted numbers of the chunk files in dir
+func listChunkFiles(t *testing.T, dir string) []int64 {
+	t.Helper()
+	keys, err := (&DirStore{dir: dir}).keys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func TestSChunkDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data.gbframe")
+	opts := Options{Codec: ZSTD, Level: 3, Shuffle: Shuffle1, TypeSize: 4}
+	sc, err := CreateSChunkDir(dir, opts)
+	if err != nil {
+		t.Fatalf("CreateSChunkDir failed: %v", err)
+	}
+	var want [][]byte
+	for i := 0; i < 3; i++ {
+		data := makeTestData(8000 + 100*i)
+		if _, err := sc.AppendBuffer(data); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, data)
+	}
+	sc.SetMetalayer("units", []byte("volts"))
+	checkChunks(t, sc, want)
+	if err := sc.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// Each chunk is a file of its own holding the chunk as it is
+	if got := listChunkFiles(t, dir); !slices.Equal(got, []int64{0, 1, 2}) {
+		t.Fatalf("chunk files %v", got)
+	}
+	stored, err := os.ReadFile(filepath.Join(dir, "00000001.chunk"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Decompress(stored); err != nil || !bytes.Equal(got, want[1]) {
+		t.Errorf("chunk file does not decompress: %v", err)
+	}
+
+	// The index is not a contiguous frame
+	index, err := os.ReadFile(filepath.Join(dir, "chunks.gbframe"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenFrame(index); !errors.Is(err, ErrInvalidFrame) {
+		t.Errorf("OpenFrame on the index: got %v", err)
+	}
+
+	// Reopen, edit and append
+	sc, err = OpenSChunkDir(dir, opts)
+	if err != nil {
+		t.Fatalf("OpenSChunkDir failed: %v", err)
+	}
+	if content, _ := sc.Metalayer("units"); string(content) != "volts" {
+		t.Errorf("metalayer = %q", content)
+	}
+	checkChunks(t, sc, want)
+	more := makeTestData(3000)
+	if _, err := sc.AppendBuffer(more); err != nil {
+		t.Fatal(err)
+	}
+	if err := sc.DeleteChunk(0); err != nil {
+		t.Fatal(err)
+	}
+	want = append(want[1:], more)
+
+	// Deleted chunk files stay until the index no longer refers to them
+	if got := listChunkFiles(t, dir); !slices.Equal(got, []int64{0, 1, 2, 3}) {
+		t.Errorf("chunk files before Sync %v", got)
+	}
+	if err := sc.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if got := listChunkFiles(t, dir); !slices.Equal(got, []int64{1, 2, 3}) {
+		t.Errorf("chunk files after Sync %v", got)
+	}
+	nbytes, cbytes := sc.NBytes(), sc.CBytes()
+
+	lazy, err := sc.LazyChunk(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := lazy.Bytes(); err != nil || !bytes.Equal(got, want[0]) {
+		t.Errorf("LazyChunk: %v", err)
+	}
+
+	// A sparse frame converts to a contiguous one
+	var buf bytes.Buffer
+	if _, err := sc.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	f, err := OpenFrame(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkChunks(t, f, want)
+	if err := sc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	sc, err = OpenSChunkDir(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sc.Close()
+	checkChunks(t, sc, want)
+	if sc.NBytes() != nbytes || sc.CBytes() != cbytes {
+		t.Errorf("sizes %d/%d after reopening, want %d/%d", sc.NBytes(), sc.CBytes(), nbytes, cbytes)
+	}
+}
+
+func TestSChunkDirUpdateInsertDelete(t *testing.T) {
+	dir := t.TempDir()
+	sc, err := CreateSChunkDir(dir, Options{Codec: LZ4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sc.SetEncryption("k2", testKeys); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]byte{makeTestData(1000), makeTestData(2000), makeTestData(3000)}
+	for _, data := range want {
+		if _, err := sc.AppendBuffer(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	testUpdateInsertDelete(t, sc, want)
+	var got [][]byte
+	for i := 0; i < sc.NumChunks(); i++ {
+		data, err := sc.DecompressChunk(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, data)
+	}
+	if err := sc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(listChunkFiles(t, dir)); n != len(got) {
+		t.Errorf("%d chunk files for %d chunks", n, len(got))
+	}
+
+	sc, err = OpenSChunkDir(dir, Options{Codec: LZ4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sc.Close()
+	if err := sc.SetKeys(testKeys); err != nil {
+		t.Fatal(err)
+	}
+	checkChunks(t, sc, got)
+}
+
+func TestSChunkDirUncommitted(t *testing.T) {
+	dir := t.TempDir()
+	sc, err := CreateSChunkDir(dir, Options{Codec: LZ4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sc.Close()
+	committed := [][]byte{makeTestData(1000)}
+	if _, err := sc.AppendBuffer(committed[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := sc.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sc.AppendBuffer(makeTestData(2000)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Readers see only the committed chunk, and may reuse the file of the
+	// uncommitted one
+	reopened, err := OpenSChunkDir(dir, Options{Codec: LZ4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkChunks(t, reopened, committed)
+	if _, err := reopened.AppendBuffer(makeTestData(500)); err != nil {
+		t.Fatal(err)
+	}
+	checkChunks(t, reopened, append(committed, makeTestData(500)))
+	if err := reopened.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Creating a frame over an old one removes its chunks
+	sc, err = CreateSChunkDir(dir, Options{Codec: LZ4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := listChunkFiles(t, dir); len(got) != 0 || sc.NumChunks() != 0 {
+		t.Errorf("chunk files %v, %d chunks after create", got, sc.NumChunks())
+	}
+}
+
+func TestSChunkDirErrors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := OpenSChunkDir(filepath.Join(dir, "missing"), Options{}); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected os.ErrNotExist, got %v", err)
+	}
+
+	// A contiguous frame is not a sparse index
+	sc := NewSChunk(Options{Codec: LZ4})
+	var buf bytes.Buffer
+	if _, err := sc.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "chunks.gbframe"), buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenSChunkDir(dir, Options{}); !errors.Is(err, ErrInvalidFrame) {
+		t.Errorf("expected ErrInvalidFrame, got %v", err)
+	}
+
+	// Chunk files that do not match the index
+	sc, err := CreateSChunkDir(dir, Options{Codec: LZ4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sc.AppendBuffer(makeTestData(1000)); err != nil {
+		t.Fatal(err)
+	}
+	if err := sc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "00000000.chunk")
+	if err := os.WriteFile(path, []byte("short"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sc, err = OpenSChunkDir(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sc.Chunk(0); !errors.Is(err, ErrInvalidFrame) {
+		t.Errorf("truncated chunk file: got %v", err)
+	}
+	os.Remove(path)
+	if _, err := sc.Chunk(0); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing chunk file: got %v", err)
+	}
+}
@@ -0,0 +1,198 @@
+package blosc
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// chunkBacking is where a persistent SChunk keeps its chunks and index.
+// Chunks are located by the position write returns, which the frame trailer
+// records as the chunk offset.
+type chunkBacking interface {
+	// write stores a chunk and returns its position
+	write(p []byte) (int64, error)
+	// read returns the length bytes of the chunk at position pos
+	read(pos, length int64) ([]byte, error)
+	// release tells the backing that the chunk at pos is no longer indexed
+	// once the next commit succeeds
+	release(pos int64)
+	// commit durably replaces the index with trailer
+	commit(trailer []byte) error
+	close() error
+}
+
+// schunkFile is the frame file backing an SChunk. Chunks are appended after
+// everything already in the file, and a new trailer takes effect only once
+// the header points at it, so the file always holds the last committed state.
+type schunkFile struct {
+	f   *os.File
+	end int64 // Where the next chunk or trailer goes
+}
+
+// write appends p to the file and returns its offset
+func (f *schunkFile) write(p []byte) (int64, error) {
+	offset := f.end
+	if _, err := f.f.WriteAt(p, offset); err != nil {
+		return 0, err
+	}
+	f.end += int64(len(p))
+	return offset, nil
+}
+
+func (f *schunkFile) read(offset, length int64) ([]byte, error) {
+	p := make([]byte, length)
+	if _, err := f.f.ReadAt(p, offset); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// release leaves the chunk's bytes in place, since later chunks follow them
+func (f *schunkFile) release(offset int64) {}
+
+// commit writes the trailer after the last chunk, flushes the file to disk,
+// and only then points the header at the new trailer, so that a crash
+// leaves the file in either its old or its new state
+func (f *schunkFile) commit(trailer []byte) error {
+	offset, err := f.write(trailer)
+	if err != nil {
+		return err
+	}
+	if err := f.f.Sync(); err != nil {
+		return err
+	}
+	if _, err := f.f.WriteAt(frameHeader(offset, len(trailer), 0), 0); err != nil {
+		return err
+	}
+	return f.f.Sync()
+}
+
+func (f *schunkFile) close() error {
+	return f.f.Close()
+}
+
+// touch marks a change that the next commit must record
+func (s *SChunk) touch() {
+	s.dirty = true
+}
+
+// CreateSChunkFile creates the frame file at path, replacing any existing
+// file, and returns an empty SChunk backed by it that compresses appended
+// buffers with opts. Chunks are written to the file as they are appended and
+// are not kept in memory; Sync and Close commit them, along with the
+// metalayers, so that OpenSChunkFile, OpenFrame and OpenFrameMmap see them.
+func CreateSChunkFile(path string, opts Options) (*SChunk, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+	if err != nil {
+		return nil, err
+	}
+	s := NewSChunk(opts)
+	s.backing = &schunkFile{f: f, end: frameHeaderSize}
+	s.dirty = true
+	if err := s.Sync(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// OpenSChunkFile opens the frame file at path to read its chunks and append
+// new ones, compressed with opts. Anything written after the last commit is
+// ignored. An encrypted file needs SetKeys before chunks can be read or
+// appended.
+func OpenSChunkFile(path string, opts Options) (*SChunk, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	s, err := openSChunkFile(f, opts)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func openSChunkFile(f *os.File, opts Options) (*SChunk, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, frameHeaderSize)
+	n, err := f.ReadAt(header, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	offset, length, flags, err := parseFrameHeader(header[:n], info.Size(), false)
+	if err != nil {
+		return nil, err
+	}
+	trailer := make([]byte, length)
+	if _, err := f.ReadAt(trailer, offset); err != nil {
+		return nil, err
+	}
+	var frame Frame
+	if err := frame.parseTrailer(trailer, offset, flags); err != nil {
+		return nil, err
+	}
+	s := openSChunkIndex(&frame, opts)
+	s.backing = &schunkFile{f: f, end: info.Size()}
+	return s, nil
+}
+
+// openSChunkIndex returns an SChunk holding the chunk index and metalayers
+// of frame, whose chunks are in a backing yet to be set
+func openSChunkIndex(frame *Frame, opts Options) *SChunk {
+	s := NewSChunk(opts)
+	s.chunks = make([]storedChunk, len(frame.chunks))
+	for i, e := range frame.chunks {
+		s.chunks[i] = storedChunk{offset: e.offset, length: e.length, nbytes: e.nbytes}
+	}
+	s.recount()
+	for _, name := range frame.metaNames {
+		s.SetMetalayer(name, frame.metalayers[name])
+	}
+	s.lockedKey, s.lockedFrame = frame.keyID, frame.frameID
+	s.dirty = false
+	return s
+}
+
+// Sync commits a file-backed SChunk: it writes a new index of the chunks and
+// metalayers, so that a crash leaves the file or directory in either its old
+// or its new state. It does nothing for in-memory SChunks.
+func (s *SChunk) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sync()
+}
+
+func (s *SChunk) sync() error {
+	if s.backing == nil || !s.dirty {
+		return nil
+	}
+	entries := make([]frameEntry, len(s.chunks))
+	for i, c := range s.chunks {
+		entries[i] = frameEntry{offset: c.offset, length: c.length, nbytes: c.nbytes}
+	}
+	if err := s.backing.commit(s.frameTrailer(entries)); err != nil {
+		return err
+	}
+	s.dirty = false
+	return nil
+}
+
+// Close commits a file-backed SChunk with Sync and closes its file. It does
+// nothing for in-memory SChunks.
+func (s *SChunk) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.backing == nil {
+		return nil
+	}
+	err := s.sync()
+	if cerr := s.backing.close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,230 @@
+package blosc
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func checkChunks(t *testing.T, sc interface {
+	NumChunks() int
+	DecompressChunk(int) ([]byte, error)
+}, want [][]byte) {
+	t.Helper()
+	if sc.NumChunks() != len(want) {
+		t.Fatalf("%d chunks, want %d", sc.NumChunks(), len(want))
+	}
+	for i, data := range want {
+		got, err := sc.DecompressChunk(i)
+		if err != nil {
+			t.Fatalf("chunk %d: %v", i, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("chunk %d differs", i)
+		}
+	}
+}
+
+func TestSChunkFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.gbframe")
+	opts := Options{Codec: ZSTD, Level: 3, Shuffle: Shuffle1, TypeSize: 4}
+	sc, err := CreateSChunkFile(path, opts)
+	if err != nil {
+		t.Fatalf("CreateSChunkFile failed: %v", err)
+	}
+	var want [][]byte
+	for i := 0; i < 3; i++ {
+		data := makeTestData(8000 + 100*i)
+		if _, err := sc.AppendBuffer(data); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, data)
+	}
+	sc.SetMetalayer("units", []byte("volts"))
+	checkChunks(t, sc, want)
+	if err := sc.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// The file is a frame
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := OpenFrame(data)
+	if err != nil {
+		t.Fatalf("OpenFrame failed: %v", err)
+	}
+	checkChunks(t, f, want)
+	if content, _ := f.Metalayer("units"); string(content) != "volts" {
+		t.Errorf("metalayer = %q", content)
+	}
+
+	// Reopen and append
+	sc, err = OpenSChunkFile(path, opts)
+	if err != nil {
+		t.Fatalf("OpenSChunkFile failed: %v", err)
+	}
+	if content, _ := sc.Metalayer("units"); string(content) != "volts" {
+		t.Errorf("metalayer = %q", content)
+	}
+	more := makeTestData(3000)
+	if _, err := sc.AppendBuffer(more); err != nil {
+		t.Fatal(err)
+	}
+	want = append(want, more)
+	checkChunks(t, sc, want)
+	nbytes, cbytes := sc.NBytes(), sc.CBytes()
+	if err := sc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	sc, err = OpenSChunkFile(path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sc.Close()
+	checkChunks(t, sc, want)
+	if sc.NBytes() != nbytes || sc.CBytes() != cbytes {
+		t.Errorf("sizes %d/%d after reopening, want %d/%d", sc.NBytes(), sc.CBytes(), nbytes, cbytes)
+	}
+}
+
+func TestSChunkFileUpdateInsertDelete(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "edit.gbframe")
+	sc, err := CreateSChunkFile(path, Options{Codec: LZ4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sc.SetEncryption("k2", testKeys); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]byte{makeTestData(1000), makeTestData(2000), makeTestData(3000)}
+	for _, data := range want {
+		if _, err := sc.AppendBuffer(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	testUpdateInsertDelete(t, sc, want)
+	var got [][]byte
+	for i := 0; i < sc.NumChunks(); i++ {
+		data, err := sc.DecompressChunk(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, data)
+	}
+	if err := sc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	sc, err = OpenSChunkFile(path, Options{Codec: LZ4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sc.Close()
+	if err := sc.SetKeys(testKeys); err != nil {
+		t.Fatal(err)
+	}
+	checkChunks(t, sc, got)
+}
+
+func TestSChunkFileUncommitted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.gbframe")
+	sc, err := CreateSChunkFile(path, Options{Codec: LZ4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sc.Close()
+	committed := [][]byte{makeTestData(1000)}
+	if _, err := sc.AppendBuffer(committed[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := sc.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if _, err := sc.AppendBuffer(makeTestData(2000)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Until the next commit, readers see only the committed chunk, as they
+	// would after a crash
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := OpenFrame(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkChunks(t, f, committed)
+
+	reopened, err := OpenSChunkFile(path, Options{Codec: LZ4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	checkChunks(t, reopened, committed)
+}
+
+func TestSChunkFileEncrypted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret.gbframe")
+	sc, err := CreateSChunkFile(path, Options{Codec: LZ4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]byte{makeTestData(4000)}
+	if _, err := sc.AppendBuffer(want[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := sc.SetEncryption("k1", testKeys); err != nil {
+		t.Fatal(err)
+	}
+	if err := sc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	sc, err = OpenSChunkFile(path, Options{Codec: LZ4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sc.Close()
+	if sc.KeyID() != "k1" {
+		t.Errorf("KeyID = %q", sc.KeyID())
+	}
+	if _, err := sc.Chunk(0); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("expected ErrInvalidKey before SetKeys, got %v", err)
+	}
+	if _, err := sc.AppendBuffer(want[0]); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("expected ErrInvalidKey appending before SetKeys, got %v", err)
+	}
+	if err := sc.SetKeys(testKeys); err != nil {
+		t.Fatal(err)
+	}
+	checkChunks(t, sc, want)
+}
+
+func TestSChunkFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := OpenSChunkFile(filepath.Join(dir, "missing"), Options{}); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected os.ErrNotExist, got %v", err)
+	}
+	path := filepath.Join(dir, "short")
+	if err := os.WriteFile(path, []byte("GBFRAME"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenSChunkFile(path, Options{}); !errors.Is(err, ErrInvalidFrame) {
+		t.Errorf("expected ErrInvalidFrame, got %v", err)
+	}
+
+	// In-memory SChunks have nothing to commit
+	sc := NewSChunk(Options{Codec: LZ4})
+	if err := sc.Sync(); err != nil {
+		t.Errorf("Sync: %v", err)
+	}
+	if err := sc.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+}
@@ -1,5 +1,11 @@
 package blosc
 
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+)
+
 func init() {
 	initSIMD()
 }
@@ -17,28 +23,63 @@ func shuffleBytes(src []byte, typeSize int) []byte {
 	if typeSize <= 1 || len(src) < typeSize {
 		return src
 	}
+	dst := make([]byte, len(src))
+	shuffleBytesTo(dst, src, typeSize)
+	return dst
+}
 
+// shuffleBytesTo is like shuffleBytes but writes the result into dst, which must be the
+// same length as src and must not overlap it.
+func shuffleBytesTo(dst, src []byte, typeSize int) {
 	n := len(src)
+	if typeSize <= 1 || n < typeSize {
+		copy(dst, src)
+		return
+	}
 	numElements := n / typeSize
-	dst := make([]byte, n)
 
-	// Try SIMD acceleration for typeSize=4
-	if typeSize == 4 {
+	// Try SIMD acceleration for typeSize=4 (all kernels), typeSize=8 (SSE2)
+	// and typeSize 2 to 8 (RVV)
+	if (typeSize == 4 || typeSize == 8 || useRVV && typeSize <= 8) && !simdOff.Load() {
 		var usedSIMD bool
 		var chunkElements int
 
+		// Try AVX-512 (processes 16 elements = 64 bytes at a time)
+		if useAVX512 && n >= 64 {
+			usedSIMD = shuffleBytesAVX512(dst, src, typeSize)
+			chunkElements = 16
+		}
+
 		// Try AVX2 (processes 8 elements = 32 bytes at a time)
-		if useAVX2 && n >= 32 {
+		if !usedSIMD && useAVX2 && n >= 32 {
 			usedSIMD = shuffleBytesAVX2(dst, src, typeSize)
 			chunkElements = 8
 		}
 
+		// Try SSE2 (processes 16 elements at a time)
+		if !usedSIMD && useSSE2 && numElements >= 16 {
+			usedSIMD = shuffleBytesSSE2(dst, src, typeSize)
+			chunkElements = 16
+		}
+
 		// Try NEON (processes 4 elements = 16 bytes at a time)
 		if !usedSIMD && useNEON && n >= 16 {
 			usedSIMD = shuffleBytesNEON(dst, src, typeSize)
 			chunkElements = 4
 		}
 
+		// Try WebAssembly SIMD128 (processes 4 elements = 16 bytes at a time)
+		if !usedSIMD && useSIMD128 && n >= 16 {
+			usedSIMD = shuffleBytesSIMD128(dst, src, typeSize)
+			chunkElements = 4
+		}
+
+		// Try RISC-V vectors (processes every element)
+		if !usedSIMD && useRVV {
+			usedSIMD = shuffleBytesRVV(dst, src, typeSize)
+			chunkElements = 1
+		}
+
 		if usedSIMD {
 			// SIMD processed full chunks, handle remainder elements
 			processedElements := (numElements / chunkElements) * chunkElements
@@ -52,24 +93,18 @@ func shuffleBytes(src []byte, typeSize int) []byte {
 			if remainder > 0 {
 				copy(dst[numElements*typeSize:], src[numElements*typeSize:])
 			}
-			return dst
+			return
 		}
 	}
 
 	// Generic implementation
-	for i := 0; i < numElements; i++ {
-		for j := 0; j < typeSize; j++ {
-			dst[j*numElements+i] = src[i*typeSize+j]
-		}
-	}
+	shuffleGeneric(dst, src, typeSize, numElements)
 
 	// Handle remaining bytes (if any)
 	remainder := n % typeSize
 	if remainder > 0 {
 		copy(dst[numElements*typeSize:], src[numElements*typeSize:])
 	}
-
-	return dst
 }
 
 // unshuffleBytes reverses the byte-level shuffle operation.
@@ -77,28 +112,63 @@ func unshuffleBytes(src []byte, typeSize int) []byte {
 	if typeSize <= 1 || len(src) < typeSize {
 		return src
 	}
+	dst := make([]byte, len(src))
+	unshuffleBytesTo(dst, src, typeSize)
+	return dst
+}
 
+// unshuffleBytesTo is like unshuffleBytes but writes the result into dst, which must be the
+// same length as src and must not overlap it.
+func unshuffleBytesTo(dst, src []byte, typeSize int) {
 	n := len(src)
+	if typeSize <= 1 || n < typeSize {
+		copy(dst, src)
+		return
+	}
 	numElements := n / typeSize
-	dst := make([]byte, n)
 
-	// Try SIMD acceleration for typeSize=4
-	if typeSize == 4 {
+	// Try SIMD acceleration for typeSize=4 (all kernels), typeSize=8 (SSE2)
+	// and typeSize 2 to 8 (RVV)
+	if (typeSize == 4 || typeSize == 8 || useRVV && typeSize <= 8) && !simdOff.Load() {
 		var usedSIMD bool
 		var chunkElements int
 
+		// Try AVX-512 (processes 16 elements = 64 bytes at a time)
+		if useAVX512 && n >= 64 {
+			usedSIMD = unshuffleBytesAVX512(dst, src, typeSize)
+			chunkElements = 16
+		}
+
 		// Try AVX2 (processes 8 elements = 32 bytes at a time)
-		if useAVX2 && n >= 32 {
+		if !usedSIMD && useAVX2 && n >= 32 {
 			usedSIMD = unshuffleBytesAVX2(dst, src, typeSize)
 			chunkElements = 8
 		}
 
+		// Try SSE2 (processes 16 elements at a time)
+		if !usedSIMD && useSSE2 && numElements >= 16 {
+			usedSIMD = unshuffleBytesSSE2(dst, src, typeSize)
+			chunkElements = 16
+		}
+
 		// Try NEON (processes 4 elements = 16 bytes at a time)
 		if !usedSIMD && useNEON && n >= 16 {
 			usedSIMD = unshuffleBytesNEON(dst, src, typeSize)
 			chunkElements = 4
 		}
 
+		// Try WebAssembly SIMD128 (processes 4 elements = 16 bytes at a time)
+		if !usedSIMD && useSIMD128 && n >= 16 {
+			usedSIMD = unshuffleBytesSIMD128(dst, src, typeSize)
+			chunkElements = 4
+		}
+
+		// Try RISC-V vectors (processes every element)
+		if !usedSIMD && useRVV {
+			usedSIMD = unshuffleBytesRVV(dst, src, typeSize)
+			chunkElements = 1
+		}
+
 		if usedSIMD {
 			// SIMD processed full chunks, handle remainder elements
 			processedElements := (numElements / chunkElements) * chunkElements
@@ -112,212 +182,535 @@ func unshuffleBytes(src []byte, typeSize int) []byte {
 			if remainder > 0 {
 				copy(dst[numElements*typeSize:], src[numElements*typeSize:])
 			}
-			return dst
+			return
 		}
 	}
 
 	// Generic implementation
-	for i := 0; i < numElements; i++ {
-		for j := 0; j < typeSize; j++ {
-			dst[i*typeSize+j] = src[j*numElements+i]
-		}
-	}
+	unshuffleGeneric(dst, src, typeSize, numElements)
 
 	// Handle remaining bytes (if any)
 	remainder := n % typeSize
 	if remainder > 0 {
 		copy(dst[numElements*typeSize:], src[numElements*typeSize:])
 	}
+}
 
-	return dst
+// shuffleTileBytes is the input the generic byte shuffle transposes at a
+// time. A tile stays in L1 cache while it is read once per 8 byte positions,
+// so that each pass writes only 8 streams, sequentially, instead of all
+// typeSize streams at once, which thrashes the cache on large buffers.
+const shuffleTileBytes = 4 << 10
+
+// shuffleGeneric byte-shuffles the first numElements elements of src into
+// dst without SIMD. Within a tile, it moves 8 elements by 8 byte positions at
+// a time through transposeBytes8x8, so that each stream gets 8 bytes a store.
+func shuffleGeneric(dst, src []byte, typeSize, numElements int) {
+	groups := numElements &^ 7
+	switch {
+	case strconv.IntSize == 32 && typeSize <= 8 && typeSize != 2:
+		// Up to 8 streams do not thrash the cache, and without 64-bit
+		// registers the transpose costs more than the stores it saves
+		groups = 0
+	case typeSize == 2:
+		// Two streams cannot thrash the cache; pack 8 elements at a time
+		for e := 0; e < groups; e += 8 {
+			lo := binary.LittleEndian.Uint64(src[2*e:])
+			hi := binary.LittleEndian.Uint64(src[2*e+8:])
+			binary.LittleEndian.PutUint64(dst[e:], evenBytes(lo)|evenBytes(hi)<<32)
+			binary.LittleEndian.PutUint64(dst[numElements+e:], evenBytes(lo>>8)|evenBytes(hi>>8)<<32)
+		}
+	default:
+		tile := max(shuffleTileBytes/typeSize&^7, 8)
+		for e0 := 0; e0 < groups; e0 += tile {
+			e1 := min(e0+tile, groups)
+			for j := 0; j < typeSize; j += 8 {
+				width := min(typeSize-j, 8)
+				for e := e0; e < e1; e += 8 {
+					var w [8]uint64
+					for g := range w {
+						w[g] = loadLE(src[(e+g)*typeSize+j:], width)
+					}
+					transposeBytes8x8(&w)
+					for k := 0; k < width; k++ {
+						binary.LittleEndian.PutUint64(dst[(j+k)*numElements+e:], w[k])
+					}
+				}
+			}
+		}
+	}
+	for i := groups; i < numElements; i++ {
+		for j := 0; j < typeSize; j++ {
+			dst[j*numElements+i] = src[i*typeSize+j]
+		}
+	}
 }
 
-// bitShuffle performs bit-level shuffle on data.
-//
-// This is a more aggressive transformation that groups bits by position across
-// all bytes. It can provide better compression for data with patterns at the
-// bit level, such as floating-point numbers with similar exponents.
-//
-// The algorithm:
-// 1. Group bytes into blocks of typeSize
-// 2. Within each block, rearrange so all MSBs are together, then next bits, etc.
-// 3. This creates long runs of similar bits that compress well
-func bitShuffle(src []byte, typeSize int) []byte {
-	if typeSize <= 1 || len(src) < typeSize {
-		return src
+// unshuffleGeneric reverses shuffleGeneric.
+func unshuffleGeneric(dst, src []byte, typeSize, numElements int) {
+	groups := numElements &^ 7
+	if typeSize == 2 {
+		for e := 0; e < groups; e += 8 {
+			a := binary.LittleEndian.Uint64(src[e:])
+			b := binary.LittleEndian.Uint64(src[numElements+e:])
+			binary.LittleEndian.PutUint64(dst[2*e:], spreadBytes(a)|spreadBytes(b)<<8)
+			binary.LittleEndian.PutUint64(dst[2*e+8:], spreadBytes(a>>32)|spreadBytes(b>>32)<<8)
+		}
+	} else {
+		tile := max(shuffleTileBytes/typeSize&^7, 8)
+		for e0 := 0; e0 < groups; e0 += tile {
+			e1 := min(e0+tile, groups)
+			for j := 0; j < typeSize; j += 8 {
+				width := min(typeSize-j, 8)
+				for e := e0; e < e1; e += 8 {
+					var w [8]uint64
+					for k := 0; k < width; k++ {
+						w[k] = binary.LittleEndian.Uint64(src[(j+k)*numElements+e:])
+					}
+					transposeBytes8x8(&w)
+					for g, x := range w {
+						storeLE(dst[(e+g)*typeSize+j:], width, x)
+					}
+				}
+			}
+		}
+	}
+	for i := groups; i < numElements; i++ {
+		for j := 0; j < typeSize; j++ {
+			dst[i*typeSize+j] = src[j*numElements+i]
+		}
 	}
+}
 
-	n := len(src)
-	numElements := n / typeSize
-	dst := make([]byte, n)
+// evenBytes packs bytes 0, 2, 4 and 6 of x into the low 4 bytes
+func evenBytes(x uint64) uint64 {
+	x &= 0x00FF00FF00FF00FF
+	x = (x | x>>8) & 0x0000FFFF0000FFFF
+	return (x | x>>16) & 0x00000000FFFFFFFF
+}
+
+// spreadBytes reverses evenBytes, moving the low 4 bytes of x to bytes 0, 2,
+// 4 and 6
+func spreadBytes(x uint64) uint64 {
+	x &= 0x00000000FFFFFFFF
+	x = (x | x<<16) & 0x0000FFFF0000FFFF
+	return (x | x<<8) & 0x00FF00FF00FF00FF
+}
 
-	// Try SIMD acceleration
-	var usedSIMD bool
-	if useAVX2 && n >= 64 {
-		usedSIMD = bitShuffleAVX2(dst, src, typeSize)
+// loadLE returns the first n bytes of b, at most 8, as a little-endian number
+func loadLE(b []byte, n int) uint64 {
+	switch n {
+	case 8:
+		return binary.LittleEndian.Uint64(b)
+	case 4:
+		return uint64(binary.LittleEndian.Uint32(b))
+	case 2:
+		return uint64(binary.LittleEndian.Uint16(b))
 	}
-	if !usedSIMD && useNEON && n >= 64 {
-		usedSIMD = bitShuffleNEON(dst, src, typeSize)
+	var x uint64
+	for i := n - 1; i >= 0; i-- {
+		x = x<<8 | uint64(b[i])
 	}
-	if usedSIMD {
-		// Handle remainder if any
-		processedElements := (numElements / 8) * 8
-		if processedElements < numElements {
-			startIdx := processedElements * typeSize
-			copy(dst[startIdx:numElements*typeSize], src[startIdx:numElements*typeSize])
-		}
-		remainder := n % typeSize
-		if remainder > 0 {
-			copy(dst[numElements*typeSize:], src[numElements*typeSize:])
+	return x
+}
+
+// storeLE writes the low n bytes of x, at most 8, to b in little-endian order
+func storeLE(b []byte, n int, x uint64) {
+	switch n {
+	case 8:
+		binary.LittleEndian.PutUint64(b, x)
+	case 4:
+		binary.LittleEndian.PutUint32(b, uint32(x))
+	case 2:
+		binary.LittleEndian.PutUint16(b, uint16(x))
+	default:
+		for i := 0; i < n; i++ {
+			b[i] = byte(x >> (8 * i))
 		}
-		return dst
 	}
+}
 
-	// Process in groups of 8 elements for efficiency
-	groupSize := 8
-	numGroups := numElements / groupSize
+// bitShuffleScratchSize is the stack scratch used to byte-shuffle one tile of
+// elements before its bits are spread across the bit rows.
+const bitShuffleScratchSize = 16384
 
-	for g := 0; g < numGroups; g++ {
-		baseIn := g * groupSize * typeSize
-		baseOut := g * groupSize * typeSize
+// bitShuffle performs bit-level shuffle on data.
+//
+// The layout matches the bitshuffle filter in c-blosc: for each byte position
+// j within an element and each bit k, there is a bit row of numElements/8 bytes
+// holding bit k of byte j of every element (element e at bit e%8 of byte e/8).
+// Rows are ordered by byte position, then by bit, so similar bits across
+// elements end up next to each other and compress well.
+//
+// As in c-blosc, the transform is only applied when the number of elements is
+// a multiple of 8; otherwise the data is copied unchanged. Trailing bytes that
+// do not form a whole element are always copied unchanged.
+func bitShuffle(src []byte, typeSize int) []byte {
+	if !bitShuffleApplies(len(src), typeSize) {
+		return src
+	}
+	dst := make([]byte, len(src))
+	bitShuffleTo(dst, src, typeSize)
+	return dst
+}
 
-		for byteIdx := 0; byteIdx < typeSize; byteIdx++ {
-			// Gather 8 bytes (one from each element at this byte position)
-			var bytes [8]byte
-			for elem := 0; elem < 8; elem++ {
-				bytes[elem] = src[baseIn+elem*typeSize+byteIdx]
-			}
+// bitShuffleApplies reports whether bitShuffle transforms n bytes of data.
+func bitShuffleApplies(n, typeSize int) bool {
+	return typeSize >= 1 && n >= 8*typeSize && (n/typeSize)%8 == 0
+}
 
-			// Transpose bits: output byte i gets bit i from each input byte
-			for outBit := 0; outBit < 8; outBit++ {
-				var outByte byte
-				for inByte := 0; inByte < 8; inByte++ {
-					if bytes[inByte]&(1<<(7-outBit)) != 0 {
-						outByte |= 1 << (7 - inByte)
-					}
-				}
-				dst[baseOut+byteIdx*8+outBit] = outByte
-			}
-		}
+// bitShuffleTo is like bitShuffle but writes the result into dst, which must
+// be the same length as src and must not overlap it.
+func bitShuffleTo(dst, src []byte, typeSize int) {
+	n := len(src)
+	if !bitShuffleApplies(n, typeSize) {
+		copy(dst, src)
+		return
 	}
+	numElements := n / typeSize
+	rowBytes := numElements / 8
 
-	// Handle remaining elements that don't fit in groups of 8
-	// These are copied without bit transposition since partial transpose is not reversible
-	remainingElements := numElements % groupSize
-	if remainingElements > 0 {
-		startIdx := numGroups * groupSize * typeSize
-		copy(dst[startIdx:numElements*typeSize], src[startIdx:numElements*typeSize])
+	if 8*typeSize > bitShuffleScratchSize {
+		bitShuffleWide(dst, src, typeSize)
+		return
 	}
 
-	// Handle remaining bytes that don't fit in complete elements
-	remainder := n % typeSize
-	if remainder > 0 {
-		copy(dst[numElements*typeSize:], src[numElements*typeSize:])
+	// Byte-shuffle a tile of elements into scratch, then spread the bits of
+	// each of the tile's byte planes into that plane's 8 bit rows.
+	var stack [bitShuffleScratchSize]byte
+	scratch := stack[:]
+	tileElements := (len(scratch) / typeSize) &^ 7
+
+	for e0 := 0; e0 < numElements; e0 += tileElements {
+		count := tileElements
+		if e0+count > numElements {
+			count = numElements - e0
+		}
+		tile := scratch[:count*typeSize]
+		shuffleBytesTo(tile, src[e0*typeSize:(e0+count)*typeSize], typeSize)
+		for j := 0; j < typeSize; j++ {
+			transposeBitRows(dst[j*numElements+e0/8:], rowBytes, tile[j*count:(j+1)*count])
+		}
 	}
 
-	return dst
+	// Handle remaining bytes that don't fit in complete elements
+	copy(dst[numElements*typeSize:], src[numElements*typeSize:])
 }
 
 // bitUnshuffle reverses the bit-level shuffle operation.
 func bitUnshuffle(src []byte, typeSize int) []byte {
-	if typeSize <= 1 || len(src) < typeSize {
+	if !bitShuffleApplies(len(src), typeSize) {
 		return src
 	}
+	dst := make([]byte, len(src))
+	bitUnshuffleTo(dst, src, typeSize)
+	return dst
+}
 
+// bitUnshuffleTo is like bitUnshuffle but writes the result into dst, which
+// must be the same length as src and must not overlap it.
+func bitUnshuffleTo(dst, src []byte, typeSize int) {
 	n := len(src)
+	if !bitShuffleApplies(n, typeSize) {
+		copy(dst, src)
+		return
+	}
 	numElements := n / typeSize
-	dst := make([]byte, n)
+	rowBytes := numElements / 8
 
-	// Try SIMD acceleration
-	var usedSIMD bool
-	if useAVX2 && n >= 64 {
-		usedSIMD = bitUnshuffleAVX2(dst, src, typeSize)
-	}
-	if !usedSIMD && useNEON && n >= 64 {
-		usedSIMD = bitUnshuffleNEON(dst, src, typeSize)
+	if 8*typeSize > bitShuffleScratchSize {
+		bitUnshuffleWide(dst, src, typeSize)
+		return
 	}
-	if usedSIMD {
-		// Handle remainder if any
-		processedElements := (numElements / 8) * 8
-		if processedElements < numElements {
-			startIdx := processedElements * typeSize
-			copy(dst[startIdx:numElements*typeSize], src[startIdx:numElements*typeSize])
+
+	var stack [bitShuffleScratchSize]byte
+	scratch := stack[:]
+	tileElements := (len(scratch) / typeSize) &^ 7
+
+	for e0 := 0; e0 < numElements; e0 += tileElements {
+		count := tileElements
+		if e0+count > numElements {
+			count = numElements - e0
 		}
-		remainder := n % typeSize
-		if remainder > 0 {
-			copy(dst[numElements*typeSize:], src[numElements*typeSize:])
+		tile := scratch[:count*typeSize]
+		for j := 0; j < typeSize; j++ {
+			untransposeBitRows(tile[j*count:(j+1)*count], src[j*numElements+e0/8:], rowBytes)
 		}
-		return dst
+		unshuffleBytesTo(dst[e0*typeSize:(e0+count)*typeSize], tile, typeSize)
 	}
 
-	// Process in groups of 8 elements for efficiency
-	groupSize := 8
-	numGroups := numElements / groupSize
-
-	for g := 0; g < numGroups; g++ {
-		baseIn := g * groupSize * typeSize
-		baseOut := g * groupSize * typeSize
+	// Handle remaining bytes
+	copy(dst[numElements*typeSize:], src[numElements*typeSize:])
+}
 
-		for byteIdx := 0; byteIdx < typeSize; byteIdx++ {
-			// Gather 8 shuffled bytes
-			var bytes [8]byte
+// bitShuffleWide is bitShuffleTo for elements too large for 8 of them to fit
+// in the stack scratch. It gathers byte j of 8 elements at a time straight from
+// src, without byte-shuffling a tile first.
+func bitShuffleWide(dst, src []byte, typeSize int) {
+	numElements := len(src) / typeSize
+	rowBytes := numElements / 8
+	for e0 := 0; e0 < numElements; e0 += 8 {
+		base := src[e0*typeSize:]
+		for j := 0; j < typeSize; j++ {
+			var x uint64
 			for i := 0; i < 8; i++ {
-				bytes[i] = src[baseIn+byteIdx*8+i]
+				x |= uint64(base[i*typeSize+j]) << (8 * i)
+			}
+			x = transposeBits8x8(x)
+			row := dst[j*numElements+e0/8:]
+			for k := 0; k < 8; k++ {
+				row[k*rowBytes] = byte(x >> (8 * k))
 			}
+		}
+	}
+	copy(dst[numElements*typeSize:], src[numElements*typeSize:])
+}
 
-			// Reverse transpose: output byte i gets bit i from each input byte
-			for outElem := 0; outElem < 8; outElem++ {
-				var outByte byte
-				for inBit := 0; inBit < 8; inBit++ {
-					if bytes[inBit]&(1<<(7-outElem)) != 0 {
-						outByte |= 1 << (7 - inBit)
-					}
-				}
-				dst[baseOut+outElem*typeSize+byteIdx] = outByte
+// bitUnshuffleWide reverses bitShuffleWide.
+func bitUnshuffleWide(dst, src []byte, typeSize int) {
+	numElements := len(src) / typeSize
+	rowBytes := numElements / 8
+	for e0 := 0; e0 < numElements; e0 += 8 {
+		base := dst[e0*typeSize:]
+		for j := 0; j < typeSize; j++ {
+			row := src[j*numElements+e0/8:]
+			var x uint64
+			for k := 0; k < 8; k++ {
+				x |= uint64(row[k*rowBytes]) << (8 * k)
+			}
+			x = transposeBits8x8(x)
+			for i := 0; i < 8; i++ {
+				base[i*typeSize+j] = byte(x >> (8 * i))
 			}
 		}
 	}
+	copy(dst[numElements*typeSize:], src[numElements*typeSize:])
+}
 
-	// Handle remaining elements (copied without bit transposition, matching bitShuffle)
-	remainingElements := numElements % groupSize
-	if remainingElements > 0 {
-		startIdx := numGroups * groupSize * typeSize
-		copy(dst[startIdx:numElements*typeSize], src[startIdx:numElements*typeSize])
+// transposeBitRows spreads bit k of every byte in src to bit row k, where row
+// k starts at dst[k*rowStride]. len(src) must be a multiple of 8; each group
+// of 8 source bytes produces one byte in every row.
+func transposeBitRows(dst []byte, rowStride int, src []byte) {
+	i := 0
+	if len(src) >= 64 && !simdOff.Load() &&
+		(useAVX512 && bitTransposeAVX512(dst, rowStride, src) || useAVX2 && bitTransposeAVX2(dst, rowStride, src)) {
+		i = len(src) &^ 63
+	}
+	// Transpose 8 groups at a time, so that each row gets 8 bytes at once
+	for ; i+64 <= len(src); i += 64 {
+		var w [8]uint64
+		for g := range w {
+			w[g] = transposeBits8x8(binary.LittleEndian.Uint64(src[i+8*g:]))
+		}
+		transposeBytes8x8(&w)
+		g := i / 8
+		for k, x := range w {
+			binary.LittleEndian.PutUint64(dst[k*rowStride+g:], x)
+		}
 	}
+	for ; i < len(src); i += 8 {
+		x := transposeBits8x8(binary.LittleEndian.Uint64(src[i:]))
+		g := i / 8
+		for k := 0; k < 8; k++ {
+			dst[k*rowStride+g] = byte(x >> (8 * k))
+		}
+	}
+}
 
-	// Handle remaining bytes
-	remainder := n % typeSize
-	if remainder > 0 {
-		copy(dst[numElements*typeSize:], src[numElements*typeSize:])
+// untransposeBitRows reverses transposeBitRows, filling dst from the bit rows
+// that start at src.
+func untransposeBitRows(dst, src []byte, rowStride int) {
+	i := 0
+	if len(dst) >= 64 && !simdOff.Load() &&
+		(useAVX512 && bitUntransposeAVX512(dst, src, rowStride) || useAVX2 && bitUntransposeAVX2(dst, src, rowStride)) {
+		i = len(dst) &^ 63
+	}
+	for ; i+64 <= len(dst); i += 64 {
+		var w [8]uint64
+		g := i / 8
+		for k := range w {
+			w[k] = binary.LittleEndian.Uint64(src[k*rowStride+g:])
+		}
+		transposeBytes8x8(&w)
+		for g, x := range w {
+			binary.LittleEndian.PutUint64(dst[i+8*g:], transposeBits8x8(x))
+		}
+	}
+	for ; i < len(dst); i += 8 {
+		g := i / 8
+		var x uint64
+		for k := 0; k < 8; k++ {
+			x |= uint64(src[k*rowStride+g]) << (8 * k)
+		}
+		binary.LittleEndian.PutUint64(dst[i:], transposeBits8x8(x))
 	}
+}
 
-	return dst
+// transposeBytes8x8 transposes w viewed as an 8x8 byte matrix whose row i is
+// w[i] and whose column j is byte j (little-endian), swapping blocks of 4, 2
+// and then 1 bytes across the diagonal. It is its own inverse.
+func transposeBytes8x8(w *[8]uint64) {
+	for i := 0; i < 4; i++ {
+		t := (w[i]>>32 ^ w[i+4]) & 0x00000000FFFFFFFF
+		w[i] ^= t << 32
+		w[i+4] ^= t
+	}
+	for _, i := range [4]int{0, 1, 4, 5} {
+		t := (w[i]>>16 ^ w[i+2]) & 0x0000FFFF0000FFFF
+		w[i] ^= t << 16
+		w[i+2] ^= t
+	}
+	for i := 0; i < 8; i += 2 {
+		t := (w[i]>>8 ^ w[i+1]) & 0x00FF00FF00FF00FF
+		w[i] ^= t << 8
+		w[i+1] ^= t
+	}
 }
 
-// ShuffleBuffer performs shuffle in-place on a buffer
-func ShuffleBuffer(data []byte, typeSize int, mode Shuffle) {
-	var result []byte
+// transposeBits8x8 transposes x viewed as an 8x8 bit matrix whose row i is
+// byte i (little-endian) and whose column j is bit j. It is its own inverse.
+func transposeBits8x8(x uint64) uint64 {
+	t := (x ^ (x >> 7)) & 0x00AA00AA00AA00AA
+	x = x ^ t ^ (t << 7)
+	t = (x ^ (x >> 14)) & 0x0000CCCC0000CCCC
+	x = x ^ t ^ (t << 14)
+	t = (x ^ (x >> 28)) & 0x00000000F0F0F0F0
+	x = x ^ t ^ (t << 28)
+	return x
+}
+
+// ShuffleTo shuffles src into dst using the given mode without allocating.
+//
+// dst must be at least len(src) bytes and must not overlap src; only
+// dst[:len(src)] is written. NoShuffle copies src unchanged. Custom codecs
+// and pipelines can use this to reuse their own buffers.
+func ShuffleTo(dst, src []byte, typeSize int, mode Shuffle) error {
+	if len(dst) < len(src) {
+		return fmt.Errorf("%w: got %d, need %d", ErrBufferTooSmall, len(dst), len(src))
+	}
+	dst = dst[:len(src)]
 	switch mode {
+	case NoShuffle:
+		copy(dst, src)
 	case Shuffle1:
-		result = shuffleBytes(data, typeSize)
+		shuffleBytesTo(dst, src, typeSize)
 	case BitShuffle:
-		result = bitShuffle(data, typeSize)
+		bitShuffleTo(dst, src, typeSize)
 	default:
-		return
+		return fmt.Errorf("%w: %s", ErrInvalidShuffle, mode)
 	}
-	copy(data, result)
+	return nil
 }
 
-// UnshuffleBuffer performs unshuffle in-place on a buffer
-func UnshuffleBuffer(data []byte, typeSize int, mode Shuffle) {
-	var result []byte
+// UnshuffleTo reverses ShuffleTo, writing the result into dst without allocating.
+// The same buffer requirements as ShuffleTo apply.
+func UnshuffleTo(dst, src []byte, typeSize int, mode Shuffle) error {
+	if len(dst) < len(src) {
+		return fmt.Errorf("%w: got %d, need %d", ErrBufferTooSmall, len(dst), len(src))
+	}
+	dst = dst[:len(src)]
 	switch mode {
+	case NoShuffle:
+		copy(dst, src)
 	case Shuffle1:
-		result = unshuffleBytes(data, typeSize)
+		unshuffleBytesTo(dst, src, typeSize)
 	case BitShuffle:
-		result = bitUnshuffle(data, typeSize)
+		bitUnshuffleTo(dst, src, typeSize)
 	default:
+		return fmt.Errorf("%w: %s", ErrInvalidShuffle, mode)
+	}
+	return nil
+}
+
+// ShuffleBuffer performs shuffle in-place on a buffer.
+//
+// Both modes are built from in-place transposes that follow permutation
+// cycles, needing only a bitmap of len(data)/8 bytes as scratch. Bit shuffle
+// additionally transposes the bits of each 8-byte group in place.
+func ShuffleBuffer(data []byte, typeSize int, mode Shuffle) {
+	switch mode {
+	case Shuffle1:
+		if typeSize <= 1 || len(data) < typeSize {
+			return
+		}
+		numElements := len(data) / typeSize
+		transposeInPlace(data[:numElements*typeSize], numElements, typeSize)
+	case BitShuffle:
+		if !bitShuffleApplies(len(data), typeSize) {
+			return
+		}
+		numElements := len(data) / typeSize
+		elems := data[:numElements*typeSize]
+		// Group bytes into planes, transpose the bits of each 8-byte group,
+		// then gather each plane's bytes into its 8 bit rows.
+		transposeInPlace(elems, numElements, typeSize)
+		transposeBitGroups(elems)
+		for j := 0; j < typeSize; j++ {
+			transposeInPlace(elems[j*numElements:(j+1)*numElements], numElements/8, 8)
+		}
+	}
+}
+
+// UnshuffleBuffer performs unshuffle in-place on a buffer.
+// It uses the same amount of scratch memory as ShuffleBuffer.
+func UnshuffleBuffer(data []byte, typeSize int, mode Shuffle) {
+	switch mode {
+	case Shuffle1:
+		if typeSize <= 1 || len(data) < typeSize {
+			return
+		}
+		numElements := len(data) / typeSize
+		transposeInPlace(data[:numElements*typeSize], typeSize, numElements)
+	case BitShuffle:
+		if !bitShuffleApplies(len(data), typeSize) {
+			return
+		}
+		numElements := len(data) / typeSize
+		elems := data[:numElements*typeSize]
+		for j := 0; j < typeSize; j++ {
+			transposeInPlace(elems[j*numElements:(j+1)*numElements], 8, numElements/8)
+		}
+		transposeBitGroups(elems)
+		transposeInPlace(elems, typeSize, numElements)
+	}
+}
+
+// transposeBitGroups applies transposeBits8x8 to every 8-byte group of data.
+func transposeBitGroups(data []byte) {
+	for i := 0; i+8 <= len(data); i += 8 {
+		x := binary.LittleEndian.Uint64(data[i:])
+		binary.LittleEndian.PutUint64(data[i:], transposeBits8x8(x))
+	}
+}
+
+// transposeInPlace transposes the rows x cols byte matrix stored row-major in
+// data into its cols x rows transpose, by following each permutation cycle
+// once. A visited bitmap marks positions that already hold their final value.
+func transposeInPlace(data []byte, rows, cols int) {
+	size := rows * cols
+	if rows <= 1 || cols <= 1 {
 		return
 	}
-	copy(data, result)
+
+	visited := make([]uint64, (size+63)/64)
+	// The first and last positions are fixed points of a transpose
+	for start := 1; start < size-1; start++ {
+		if visited[start>>6]&(1<<(start&63)) != 0 {
+			continue
+		}
+
+		// Carry the value at start to its destination, picking up the value
+		// displaced there, until the cycle returns to start.
+		v := data[start]
+		i := start
+		for {
+			next := (i%cols)*rows + i/cols
+			data[next], v = v, data[next]
+			visited[next>>6] |= 1 << (next & 63)
+			if next == start {
+				break
+			}
+			i = next
+		}
+	}
 }
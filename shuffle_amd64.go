@@ -5,12 +5,25 @@ package blosc
 // useAVX2 indicates whether AVX2 instructions are available.
 var useAVX2 bool
 
+// useAVX512 indicates whether AVX-512F and AVX-512BW instructions are available.
+var useAVX512 bool
+
+// useSSE2 is always true on amd64, where SSE2 is part of the baseline.
+var useSSE2 = true
+
 // useNEON is always false on amd64 platforms.
 var useNEON = false
 
-// initSIMD detects AVX2 support at package initialization.
+// useSIMD128 is always false on amd64 platforms.
+var useSIMD128 = false
+
+// useRVV is always false on amd64 platforms.
+var useRVV = false
+
+// initSIMD detects AVX2 and AVX-512 support at package initialization.
 func initSIMD() {
 	useAVX2 = hasAVX2()
+	useAVX512 = hasAVX512BW()
 }
 
 // shuffleBytesAVX2 shuffles bytes using AVX2 instructions.
@@ -27,24 +40,69 @@ func shuffleBytesAVX2(dst, src []byte, typeSize int) bool
 //go:noescape
 func unshuffleBytesAVX2(dst, src []byte, typeSize int) bool
 
-// bitShuffleAVX2 performs bit-level shuffle using AVX2 instructions.
-// Processes 64 bytes at a time (8 elements × 8 byte positions for typeSize=8,
-// or adjusts for other typeSizes).
-// Returns false if data is too small or typeSize is not supported.
+// shuffleBytesSSE2 shuffles bytes using SSE2 instructions, which every amd64
+// CPU supports. For typeSize 4 and 8, processes 16 elements at a time.
+// Falls back by returning false if data is too small for SIMD processing.
 //
 //go:noescape
-func bitShuffleAVX2(dst, src []byte, typeSize int) bool
+func shuffleBytesSSE2(dst, src []byte, typeSize int) bool
 
-// bitUnshuffleAVX2 reverses the bit-level shuffle using AVX2 instructions.
+// unshuffleBytesSSE2 unshuffles bytes using SSE2 instructions.
+// For typeSize 4 and 8, processes 16 elements at a time.
+// Falls back by returning false if data is too small for SIMD processing.
 //
 //go:noescape
-func bitUnshuffleAVX2(dst, src []byte, typeSize int) bool
+func unshuffleBytesSSE2(dst, src []byte, typeSize int) bool
 
 // hasAVX2 returns true if the CPU supports AVX2 instructions.
 //
 //go:noescape
 func hasAVX2() bool
 
+// shuffleBytesAVX512 shuffles bytes using AVX-512 instructions.
+// For typeSize=4, processes 64 bytes at a time (16 elements).
+// Falls back by returning false if data is too small for SIMD processing.
+//
+//go:noescape
+func shuffleBytesAVX512(dst, src []byte, typeSize int) bool
+
+// unshuffleBytesAVX512 unshuffles bytes using AVX-512 instructions.
+// For typeSize=4, processes 64 bytes at a time (16 elements).
+// Falls back by returning false if data is too small for SIMD processing.
+//
+//go:noescape
+func unshuffleBytesAVX512(dst, src []byte, typeSize int) bool
+
+// bitTransposeAVX512 spreads bit k of every byte in src to bit row k, where
+// row k starts at dst[k*rowStride]. Processes src in 64-byte chunks.
+// Returns false if src is shorter than 64 bytes.
+//
+//go:noescape
+func bitTransposeAVX512(dst []byte, rowStride int, src []byte) bool
+
+// bitUntransposeAVX512 reverses bitTransposeAVX512, gathering the bit rows
+// starting at src back into bytes of dst. Processes dst in 64-byte chunks.
+//
+//go:noescape
+func bitUntransposeAVX512(dst, src []byte, rowStride int) bool
+
+// bitTransposeAVX2 is bitTransposeAVX512 for CPUs with AVX2 only. Processes
+// src in 64-byte chunks and returns false if src is shorter than 64 bytes.
+//
+//go:noescape
+func bitTransposeAVX2(dst []byte, rowStride int, src []byte) bool
+
+// bitUntransposeAVX2 reverses bitTransposeAVX2, processing dst in 64-byte
+// chunks.
+//
+//go:noescape
+func bitUntransposeAVX2(dst, src []byte, rowStride int) bool
+
+// hasAVX512BW returns true if the CPU and OS support AVX-512F and AVX-512BW.
+//
+//go:noescape
+func hasAVX512BW() bool
+
 // shuffleBytesNEON is not available on amd64 platforms.
 func shuffleBytesNEON(dst, src []byte, typeSize int) bool {
 	return false
@@ -55,12 +113,22 @@ func unshuffleBytesNEON(dst, src []byte, typeSize int) bool {
 	return false
 }
 
-// bitShuffleNEON is not available on amd64 platforms.
-func bitShuffleNEON(dst, src []byte, typeSize int) bool {
+// shuffleBytesSIMD128 is not available on amd64 platforms.
+func shuffleBytesSIMD128(dst, src []byte, typeSize int) bool {
+	return false
+}
+
+// unshuffleBytesSIMD128 is not available on amd64 platforms.
+func unshuffleBytesSIMD128(dst, src []byte, typeSize int) bool {
+	return false
+}
+
+// shuffleBytesRVV is not available on amd64 platforms.
+func shuffleBytesRVV(dst, src []byte, typeSize int) bool {
 	return false
 }
 
-// bitUnshuffleNEON is not available on amd64 platforms.
-func bitUnshuffleNEON(dst, src []byte, typeSize int) bool {
+// unshuffleBytesRVV is not available on amd64 platforms.
+func unshuffleBytesRVV(dst, src []byte, typeSize int) bool {
 	return false
 }
@@ -330,1065 +330,792 @@ unshuffle_fallback:
     RET
 
 // =============================================================================
-// BitShuffle AVX2 Implementation
+// AVX-512 Implementation
 // =============================================================================
 //
-// BitShuffle transposes an 8x8 bit matrix for each byte position across 8 elements.
-// For typeSize bytes per element and 8 elements per group, we process:
-// - Input: 8*typeSize bytes (8 elements)
-// - Output: 8*typeSize bytes (transposed)
-//
-// The 8x8 bit transpose algorithm uses the "classic" approach:
-// Given 8 bytes B0-B7, we want to extract bit i from each byte and pack into output byte i.
-// This can be done efficiently using shift and mask operations.
+// These kernels require AVX512F and AVX512BW. They reuse the 128-bit lane masks
+// from the AVX2 kernels (broadcast to all four lanes) and add 512-bit cross-lane
+// permutations.
+
+// func hasAVX512BW() bool
+TEXT ·hasAVX512BW(SB), NOSPLIT, $0-1
+    // XGETBV may only be used when OSXSAVE is set (CPUID.1:ECX bit 27)
+    MOVL    $1, AX
+    XORL    CX, CX
+    CPUID
+    ANDL    $0x08000000, CX
+    JZ      no_avx512
+
+    // The OS must preserve XMM, YMM, opmask and ZMM state (XCR0 bits 1,2,5,6,7)
+    XORL    CX, CX
+    XGETBV
+    ANDL    $0xe6, AX
+    CMPL    AX, $0xe6
+    JNE     no_avx512
+
+    // AVX512F is bit 16 and AVX512BW is bit 30 of CPUID.7.0:EBX
+    MOVL    $7, AX
+    XORL    CX, CX
+    CPUID
+    ANDL    $0x40010000, BX
+    CMPL    BX, $0x40010000
+    JNE     no_avx512
+
+    MOVB    $1, ret+0(FP)
+    RET
 
-// func bitShuffleAVX2(dst, src []byte, typeSize int) bool
-TEXT ·bitShuffleAVX2(SB), NOSPLIT, $64-57
+no_avx512:
+    MOVB    $0, ret+0(FP)
+    RET
+
+// Dword permutation for typeSize=4 byte shuffle over 16 elements.
+// After VPSHUFB each lane holds [byte0s, byte1s, byte2s, byte3s] of 4 elements;
+// this gathers dword r of every lane into the r-th 128-bit output lane.
+// The permutation is a 4x4 transpose, so it is also its own inverse.
+DATA shuffle4_perm512<>+0(SB)/4, $0
+DATA shuffle4_perm512<>+4(SB)/4, $4
+DATA shuffle4_perm512<>+8(SB)/4, $8
+DATA shuffle4_perm512<>+12(SB)/4, $12
+DATA shuffle4_perm512<>+16(SB)/4, $1
+DATA shuffle4_perm512<>+20(SB)/4, $5
+DATA shuffle4_perm512<>+24(SB)/4, $9
+DATA shuffle4_perm512<>+28(SB)/4, $13
+DATA shuffle4_perm512<>+32(SB)/4, $2
+DATA shuffle4_perm512<>+36(SB)/4, $6
+DATA shuffle4_perm512<>+40(SB)/4, $10
+DATA shuffle4_perm512<>+44(SB)/4, $14
+DATA shuffle4_perm512<>+48(SB)/4, $3
+DATA shuffle4_perm512<>+52(SB)/4, $7
+DATA shuffle4_perm512<>+56(SB)/4, $11
+DATA shuffle4_perm512<>+60(SB)/4, $15
+GLOBL shuffle4_perm512<>(SB), RODATA, $64
+
+// func shuffleBytesAVX512(dst, src []byte, typeSize int) bool
+// For typeSize=4, processes 64 bytes (16 elements) per iteration.
+TEXT ·shuffleBytesAVX512(SB), NOSPLIT, $0-57
     MOVQ    dst_base+0(FP), DI      // dst pointer
     MOVQ    dst_len+8(FP), R8       // dst length (n)
     MOVQ    src_base+24(FP), SI     // src pointer
     MOVQ    typeSize+48(FP), DX     // typeSize
 
-    // Validate: need at least 64 bytes (8 elements) and typeSize >= 1
+    // Need typeSize == 4 and at least 64 bytes
+    CMPQ    DX, $4
+    JNE     shuffle512_fallback
     CMPQ    R8, $64
-    JL      bitshuffle_fallback
-    CMPQ    DX, $1
-    JL      bitshuffle_fallback
-
-    // Calculate number of elements and groups
-    // numElements = n / typeSize
-    // Since typeSize is an argument in DX, we'll use iterative approach for safety
-    MOVQ    $0, R10                 // R10 = numElements counter
-    MOVQ    R8, R11                 // R11 = remaining bytes
-    MOVQ    DX, R12                 // R12 = typeSize (preserve in R12)
-
-calc_elements_loop:
-    CMPQ    R11, R12
-    JL      calc_elements_done
-    SUBQ    R12, R11
-    INCQ    R10
-    JMP     calc_elements_loop
-
-calc_elements_done:
-
-    MOVQ    R10, AX
-    SHRQ    $3, AX                  // AX = numGroups = numElements / 8
-    JZ      bitshuffle_fallback
-
-    MOVQ    AX, CX                  // CX = numGroups
-    XORQ    BX, BX                  // group counter
-
-bitshuffle_group_loop:
-    // Process one group of 8 elements
-    // For each byte position (0 to typeSize-1), transpose 8x8 bits
-    XORQ    R9, R9                  // byteIdx = 0
-
-bitshuffle_byte_loop:
-    CMPQ    R9, DX
-    JGE     bitshuffle_next_group
-
-    // Gather 8 bytes from position byteIdx of each element
-    // Element i is at src[group*8*typeSize + i*typeSize + byteIdx]
-    MOVQ    BX, AX
-    SHLQ    $3, AX                  // group * 8
-    IMULQ   DX, AX                  // group * 8 * typeSize
-    ADDQ    SI, AX                  // base = src + group * 8 * typeSize
-
-    // Load byte from each of 8 elements
-    MOVQ    R9, R12                 // byteIdx
-    MOVBQZX (AX)(R12*1), R13        // b0 = src[base + 0*typeSize + byteIdx]
-    ADDQ    DX, R12
-    MOVBQZX (AX)(R12*1), R14        // b1
-    ADDQ    DX, R12
-    MOVBQZX (AX)(R12*1), R15        // b2
-    ADDQ    DX, R12
-
-    // Store first 3 bytes on stack
-    MOVB    R13B, 0(SP)
-    MOVB    R14B, 1(SP)
-    MOVB    R15B, 2(SP)
-
-    MOVBQZX (AX)(R12*1), R13        // b3
-    ADDQ    DX, R12
-    MOVBQZX (AX)(R12*1), R14        // b4
-    ADDQ    DX, R12
-    MOVBQZX (AX)(R12*1), R15        // b5
-    ADDQ    DX, R12
-
-    MOVB    R13B, 3(SP)
-    MOVB    R14B, 4(SP)
-    MOVB    R15B, 5(SP)
-
-    MOVBQZX (AX)(R12*1), R13        // b6
-    ADDQ    DX, R12
-    MOVBQZX (AX)(R12*1), R14        // b7
-
-    MOVB    R13B, 6(SP)
-    MOVB    R14B, 7(SP)
-
-    // Now we have 8 bytes at SP[0..7]
-    // Perform 8x8 bit transpose
-    // Load into GP register for bit manipulation
-    MOVQ    (SP), AX                // AX = 8 bytes packed
-
-    // Classic 8x8 transpose using shift-and-mask
-    // Output byte i gets bit i from each input byte
-    // We'll compute each output byte separately
-
-    // Compute output bytes and store at SP[8..15]
-    // out[0] = bit 7 of each input (MSB)
-    MOVQ    AX, R11
-    MOVQ    $0, R12                 // result
-
-    // Extract MSB (bit 7) from each byte
-    MOVQ    AX, R13
-    SHRQ    $7, R13
-    ANDQ    $1, R13
-    SHLQ    $7, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $15, R13
-    ANDQ    $1, R13
-    SHLQ    $6, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $23, R13
-    ANDQ    $1, R13
-    SHLQ    $5, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $31, R13
-    ANDQ    $1, R13
-    SHLQ    $4, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $39, R13
-    ANDQ    $1, R13
-    SHLQ    $3, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $47, R13
-    ANDQ    $1, R13
-    SHLQ    $2, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $55, R13
-    ANDQ    $1, R13
-    SHLQ    $1, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $63, R13
-    ORQ     R13, R12
-
-    MOVB    R12B, 8(SP)             // out[0]
-
-    // out[1] = bit 6 of each input
-    MOVQ    $0, R12
-    MOVQ    AX, R13
-    SHRQ    $6, R13
-    ANDQ    $1, R13
-    SHLQ    $7, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $14, R13
-    ANDQ    $1, R13
-    SHLQ    $6, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $22, R13
-    ANDQ    $1, R13
-    SHLQ    $5, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $30, R13
-    ANDQ    $1, R13
-    SHLQ    $4, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $38, R13
-    ANDQ    $1, R13
-    SHLQ    $3, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $46, R13
-    ANDQ    $1, R13
-    SHLQ    $2, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $54, R13
-    ANDQ    $1, R13
-    SHLQ    $1, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $62, R13
-    ANDQ    $1, R13
-    ORQ     R13, R12
-
-    MOVB    R12B, 9(SP)             // out[1]
-
-    // out[2] = bit 5 of each input
-    MOVQ    $0, R12
-    MOVQ    AX, R13
-    SHRQ    $5, R13
-    ANDQ    $1, R13
-    SHLQ    $7, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $13, R13
-    ANDQ    $1, R13
-    SHLQ    $6, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $21, R13
-    ANDQ    $1, R13
-    SHLQ    $5, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $29, R13
-    ANDQ    $1, R13
-    SHLQ    $4, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $37, R13
-    ANDQ    $1, R13
-    SHLQ    $3, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $45, R13
-    ANDQ    $1, R13
-    SHLQ    $2, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $53, R13
-    ANDQ    $1, R13
-    SHLQ    $1, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $61, R13
-    ANDQ    $1, R13
-    ORQ     R13, R12
-
-    MOVB    R12B, 10(SP)            // out[2]
-
-    // out[3] = bit 4 of each input
-    MOVQ    $0, R12
-    MOVQ    AX, R13
-    SHRQ    $4, R13
-    ANDQ    $1, R13
-    SHLQ    $7, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $12, R13
-    ANDQ    $1, R13
-    SHLQ    $6, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $20, R13
-    ANDQ    $1, R13
-    SHLQ    $5, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $28, R13
-    ANDQ    $1, R13
-    SHLQ    $4, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $36, R13
-    ANDQ    $1, R13
-    SHLQ    $3, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $44, R13
-    ANDQ    $1, R13
-    SHLQ    $2, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $52, R13
-    ANDQ    $1, R13
-    SHLQ    $1, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $60, R13
-    ANDQ    $1, R13
-    ORQ     R13, R12
-
-    MOVB    R12B, 11(SP)            // out[3]
-
-    // out[4] = bit 3 of each input
-    MOVQ    $0, R12
-    MOVQ    AX, R13
-    SHRQ    $3, R13
-    ANDQ    $1, R13
-    SHLQ    $7, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $11, R13
-    ANDQ    $1, R13
-    SHLQ    $6, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $19, R13
-    ANDQ    $1, R13
-    SHLQ    $5, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $27, R13
-    ANDQ    $1, R13
-    SHLQ    $4, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $35, R13
-    ANDQ    $1, R13
-    SHLQ    $3, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $43, R13
-    ANDQ    $1, R13
-    SHLQ    $2, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $51, R13
-    ANDQ    $1, R13
-    SHLQ    $1, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $59, R13
-    ANDQ    $1, R13
-    ORQ     R13, R12
-
-    MOVB    R12B, 12(SP)            // out[4]
-
-    // out[5] = bit 2 of each input
-    MOVQ    $0, R12
-    MOVQ    AX, R13
-    SHRQ    $2, R13
-    ANDQ    $1, R13
-    SHLQ    $7, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $10, R13
-    ANDQ    $1, R13
-    SHLQ    $6, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $18, R13
-    ANDQ    $1, R13
-    SHLQ    $5, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $26, R13
-    ANDQ    $1, R13
-    SHLQ    $4, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $34, R13
-    ANDQ    $1, R13
-    SHLQ    $3, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $42, R13
-    ANDQ    $1, R13
-    SHLQ    $2, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $50, R13
-    ANDQ    $1, R13
-    SHLQ    $1, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $58, R13
-    ANDQ    $1, R13
-    ORQ     R13, R12
-
-    MOVB    R12B, 13(SP)            // out[5]
-
-    // out[6] = bit 1 of each input
-    MOVQ    $0, R12
-    MOVQ    AX, R13
-    SHRQ    $1, R13
-    ANDQ    $1, R13
-    SHLQ    $7, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $9, R13
-    ANDQ    $1, R13
-    SHLQ    $6, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $17, R13
-    ANDQ    $1, R13
-    SHLQ    $5, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $25, R13
-    ANDQ    $1, R13
-    SHLQ    $4, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $33, R13
-    ANDQ    $1, R13
-    SHLQ    $3, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $41, R13
-    ANDQ    $1, R13
-    SHLQ    $2, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $49, R13
-    ANDQ    $1, R13
-    SHLQ    $1, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $57, R13
-    ANDQ    $1, R13
-    ORQ     R13, R12
-
-    MOVB    R12B, 14(SP)            // out[6]
-
-    // out[7] = bit 0 of each input (LSB)
-    MOVQ    $0, R12
-    MOVQ    AX, R13
-    ANDQ    $1, R13
-    SHLQ    $7, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $8, R13
-    ANDQ    $1, R13
-    SHLQ    $6, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $16, R13
-    ANDQ    $1, R13
-    SHLQ    $5, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $24, R13
-    ANDQ    $1, R13
-    SHLQ    $4, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $32, R13
-    ANDQ    $1, R13
-    SHLQ    $3, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $40, R13
-    ANDQ    $1, R13
-    SHLQ    $2, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $48, R13
-    ANDQ    $1, R13
-    SHLQ    $1, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $56, R13
-    ANDQ    $1, R13
-    ORQ     R13, R12
-
-    MOVB    R12B, 15(SP)            // out[7]
-
-    // Store 8 transposed bytes to output
-    // Output goes to dst[group*8*typeSize + byteIdx*8]
-    MOVQ    BX, AX
-    SHLQ    $3, AX                  // group * 8
-    IMULQ   DX, AX                  // group * 8 * typeSize
-    ADDQ    DI, AX                  // base = dst + group * 8 * typeSize
-
-    MOVQ    R9, R12
-    SHLQ    $3, R12                 // byteIdx * 8
-    ADDQ    R12, AX                 // dst offset
-
-    MOVQ    8(SP), R12              // Load 8 transposed bytes
-    MOVQ    R12, (AX)               // Store to destination
-
-    INCQ    R9                      // byteIdx++
-    JMP     bitshuffle_byte_loop
-
-bitshuffle_next_group:
-    INCQ    BX
-    CMPQ    BX, CX
-    JL      bitshuffle_group_loop
+    JL      shuffle512_fallback
+
+    MOVQ    R8, R10
+    SHRQ    $2, R10                 // R10 = numElements = n / 4
+    MOVQ    R10, CX
+    SHRQ    $4, CX                  // CX = numChunks = numElements / 16
+    JZ      shuffle512_fallback
+
+    VBROADCASTI32X4 shuffle4_lane<>(SB), Z2     // Lane shuffle mask in all 4 lanes
+    VMOVDQU32       shuffle4_perm512<>(SB), Z3  // Cross-lane permutation
 
+    // Output regions for byte positions 0..3
+    MOVQ    DI, R11
+    LEAQ    (DI)(R10*1), R12
+    LEAQ    (DI)(R10*2), R13
+    LEAQ    (R12)(R10*2), R14
+
+shuffle512_loop:
+    VMOVDQU32 (SI), Z0
+    VPSHUFB   Z2, Z0, Z1            // Group bytes by position within each lane
+    VPERMD    Z1, Z3, Z0            // Lane r now holds byte r of all 16 elements
+
+    VMOVDQU   X0, (R11)
+    VEXTRACTI32X4 $1, Z0, X1
+    VMOVDQU   X1, (R12)
+    VEXTRACTI32X4 $2, Z0, X1
+    VMOVDQU   X1, (R13)
+    VEXTRACTI32X4 $3, Z0, X1
+    VMOVDQU   X1, (R14)
+
+    ADDQ    $64, SI
+    ADDQ    $16, R11
+    ADDQ    $16, R12
+    ADDQ    $16, R13
+    ADDQ    $16, R14
+
+    DECQ    CX
+    JNZ     shuffle512_loop
+
+    VZEROUPPER
     MOVB    $1, ret+56(FP)
     RET
 
-bitshuffle_fallback:
+shuffle512_fallback:
     MOVB    $0, ret+56(FP)
     RET
 
-// func bitUnshuffleAVX2(dst, src []byte, typeSize int) bool
-// This is the inverse of bitShuffleAVX2 - it transposes bits back
-TEXT ·bitUnshuffleAVX2(SB), NOSPLIT, $64-57
+// func unshuffleBytesAVX512(dst, src []byte, typeSize int) bool
+TEXT ·unshuffleBytesAVX512(SB), NOSPLIT, $0-57
     MOVQ    dst_base+0(FP), DI      // dst pointer
     MOVQ    dst_len+8(FP), R8       // dst length (n)
     MOVQ    src_base+24(FP), SI     // src pointer
     MOVQ    typeSize+48(FP), DX     // typeSize
 
-    // Validate
+    CMPQ    DX, $4
+    JNE     unshuffle512_fallback
     CMPQ    R8, $64
-    JL      bitunshuffle_fallback
-    CMPQ    DX, $1
-    JL      bitunshuffle_fallback
-
-    // Calculate number of elements and groups
-    // numElements = n / typeSize
-    // Since typeSize is an argument in DX, we'll use iterative approach for safety
-    MOVQ    $0, R10                 // R10 = numElements counter
-    MOVQ    R8, R11                 // R11 = remaining bytes
-    MOVQ    DX, R12                 // R12 = typeSize (preserve in R12)
-
-calc_elements_unshuffle_loop:
-    CMPQ    R11, R12
-    JL      calc_elements_unshuffle_done
-    SUBQ    R12, R11
-    INCQ    R10
-    JMP     calc_elements_unshuffle_loop
-
-calc_elements_unshuffle_done:
-
-    MOVQ    R10, AX
-    SHRQ    $3, AX
-    JZ      bitunshuffle_fallback
-
-    MOVQ    AX, CX                  // numGroups
-    XORQ    BX, BX                  // group counter
-
-bitunshuffle_group_loop:
-    XORQ    R9, R9                  // byteIdx = 0
-
-bitunshuffle_byte_loop:
-    CMPQ    R9, DX
-    JGE     bitunshuffle_next_group
-
-    // Load 8 transposed bytes from src[group*8*typeSize + byteIdx*8]
-    MOVQ    BX, AX
-    SHLQ    $3, AX
-    IMULQ   DX, AX
-    ADDQ    SI, AX
-
-    MOVQ    R9, R12
-    SHLQ    $3, R12
-    ADDQ    R12, AX
-
-    MOVQ    (AX), AX                // AX = 8 transposed bytes
-
-    // Reverse transpose: out[i] gets bit i from each transposed byte
-    // Same algorithm as forward transpose (transpose is its own inverse)
-
-    // out[0] = bit 7 of each transposed byte
-    MOVQ    $0, R12
-    MOVQ    AX, R13
-    SHRQ    $7, R13
-    ANDQ    $1, R13
-    SHLQ    $7, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $15, R13
-    ANDQ    $1, R13
-    SHLQ    $6, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $23, R13
-    ANDQ    $1, R13
-    SHLQ    $5, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $31, R13
-    ANDQ    $1, R13
-    SHLQ    $4, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $39, R13
-    ANDQ    $1, R13
-    SHLQ    $3, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $47, R13
-    ANDQ    $1, R13
-    SHLQ    $2, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $55, R13
-    ANDQ    $1, R13
-    SHLQ    $1, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $63, R13
-    ORQ     R13, R12
-
-    MOVB    R12B, 8(SP)
-
-    // out[1] = bit 6
-    MOVQ    $0, R12
-    MOVQ    AX, R13
-    SHRQ    $6, R13
-    ANDQ    $1, R13
-    SHLQ    $7, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $14, R13
-    ANDQ    $1, R13
-    SHLQ    $6, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $22, R13
-    ANDQ    $1, R13
-    SHLQ    $5, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $30, R13
-    ANDQ    $1, R13
-    SHLQ    $4, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $38, R13
-    ANDQ    $1, R13
-    SHLQ    $3, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $46, R13
-    ANDQ    $1, R13
-    SHLQ    $2, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $54, R13
-    ANDQ    $1, R13
-    SHLQ    $1, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $62, R13
-    ANDQ    $1, R13
-    ORQ     R13, R12
-
-    MOVB    R12B, 9(SP)
-
-    // out[2] = bit 5
-    MOVQ    $0, R12
-    MOVQ    AX, R13
-    SHRQ    $5, R13
-    ANDQ    $1, R13
-    SHLQ    $7, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $13, R13
-    ANDQ    $1, R13
-    SHLQ    $6, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $21, R13
-    ANDQ    $1, R13
-    SHLQ    $5, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $29, R13
-    ANDQ    $1, R13
-    SHLQ    $4, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $37, R13
-    ANDQ    $1, R13
-    SHLQ    $3, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $45, R13
-    ANDQ    $1, R13
-    SHLQ    $2, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $53, R13
-    ANDQ    $1, R13
-    SHLQ    $1, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $61, R13
-    ANDQ    $1, R13
-    ORQ     R13, R12
-
-    MOVB    R12B, 10(SP)
-
-    // out[3] = bit 4
-    MOVQ    $0, R12
-    MOVQ    AX, R13
-    SHRQ    $4, R13
-    ANDQ    $1, R13
-    SHLQ    $7, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $12, R13
-    ANDQ    $1, R13
-    SHLQ    $6, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $20, R13
-    ANDQ    $1, R13
-    SHLQ    $5, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $28, R13
-    ANDQ    $1, R13
-    SHLQ    $4, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $36, R13
-    ANDQ    $1, R13
-    SHLQ    $3, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $44, R13
-    ANDQ    $1, R13
-    SHLQ    $2, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $52, R13
-    ANDQ    $1, R13
-    SHLQ    $1, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $60, R13
-    ANDQ    $1, R13
-    ORQ     R13, R12
-
-    MOVB    R12B, 11(SP)
-
-    // out[4] = bit 3
-    MOVQ    $0, R12
-    MOVQ    AX, R13
-    SHRQ    $3, R13
-    ANDQ    $1, R13
-    SHLQ    $7, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $11, R13
-    ANDQ    $1, R13
-    SHLQ    $6, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $19, R13
-    ANDQ    $1, R13
-    SHLQ    $5, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $27, R13
-    ANDQ    $1, R13
-    SHLQ    $4, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $35, R13
-    ANDQ    $1, R13
-    SHLQ    $3, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $43, R13
-    ANDQ    $1, R13
-    SHLQ    $2, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $51, R13
-    ANDQ    $1, R13
-    SHLQ    $1, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $59, R13
-    ANDQ    $1, R13
-    ORQ     R13, R12
-
-    MOVB    R12B, 12(SP)
-
-    // out[5] = bit 2
-    MOVQ    $0, R12
-    MOVQ    AX, R13
-    SHRQ    $2, R13
-    ANDQ    $1, R13
-    SHLQ    $7, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $10, R13
-    ANDQ    $1, R13
-    SHLQ    $6, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $18, R13
-    ANDQ    $1, R13
-    SHLQ    $5, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $26, R13
-    ANDQ    $1, R13
-    SHLQ    $4, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $34, R13
-    ANDQ    $1, R13
-    SHLQ    $3, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $42, R13
-    ANDQ    $1, R13
-    SHLQ    $2, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $50, R13
-    ANDQ    $1, R13
-    SHLQ    $1, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $58, R13
-    ANDQ    $1, R13
-    ORQ     R13, R12
-
-    MOVB    R12B, 13(SP)
-
-    // out[6] = bit 1
-    MOVQ    $0, R12
-    MOVQ    AX, R13
-    SHRQ    $1, R13
-    ANDQ    $1, R13
-    SHLQ    $7, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $9, R13
-    ANDQ    $1, R13
-    SHLQ    $6, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $17, R13
-    ANDQ    $1, R13
-    SHLQ    $5, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $25, R13
-    ANDQ    $1, R13
-    SHLQ    $4, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $33, R13
-    ANDQ    $1, R13
-    SHLQ    $3, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $41, R13
-    ANDQ    $1, R13
-    SHLQ    $2, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $49, R13
-    ANDQ    $1, R13
-    SHLQ    $1, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $57, R13
-    ANDQ    $1, R13
-    ORQ     R13, R12
-
-    MOVB    R12B, 14(SP)
-
-    // out[7] = bit 0
-    MOVQ    $0, R12
-    MOVQ    AX, R13
-    ANDQ    $1, R13
-    SHLQ    $7, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $8, R13
-    ANDQ    $1, R13
-    SHLQ    $6, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $16, R13
-    ANDQ    $1, R13
-    SHLQ    $5, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $24, R13
-    ANDQ    $1, R13
-    SHLQ    $4, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $32, R13
-    ANDQ    $1, R13
-    SHLQ    $3, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $40, R13
-    ANDQ    $1, R13
-    SHLQ    $2, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $48, R13
-    ANDQ    $1, R13
-    SHLQ    $1, R13
-    ORQ     R13, R12
-
-    MOVQ    AX, R13
-    SHRQ    $56, R13
-    ANDQ    $1, R13
-    ORQ     R13, R12
-
-    MOVB    R12B, 15(SP)
-
-    // Store 8 untransposed bytes to output positions
-    // out byte i goes to dst[group*8*typeSize + i*typeSize + byteIdx]
-    MOVQ    BX, AX
-    SHLQ    $3, AX
-    IMULQ   DX, AX
-    ADDQ    DI, AX                  // base = dst + group * 8 * typeSize
-
-    // Store each byte to its element position
-    MOVB    8(SP), R12B
-    MOVB    R12B, (AX)(R9*1)        // dst[base + 0*typeSize + byteIdx]
-
-    LEAQ    (AX)(DX*1), R11
-    MOVB    9(SP), R12B
-    MOVB    R12B, (R11)(R9*1)       // dst[base + 1*typeSize + byteIdx]
-
-    LEAQ    (R11)(DX*1), R11
-    MOVB    10(SP), R12B
-    MOVB    R12B, (R11)(R9*1)
-
-    LEAQ    (R11)(DX*1), R11
-    MOVB    11(SP), R12B
-    MOVB    R12B, (R11)(R9*1)
-
-    LEAQ    (R11)(DX*1), R11
-    MOVB    12(SP), R12B
-    MOVB    R12B, (R11)(R9*1)
-
-    LEAQ    (R11)(DX*1), R11
-    MOVB    13(SP), R12B
-    MOVB    R12B, (R11)(R9*1)
-
-    LEAQ    (R11)(DX*1), R11
-    MOVB    14(SP), R12B
-    MOVB    R12B, (R11)(R9*1)
-
-    LEAQ    (R11)(DX*1), R11
-    MOVB    15(SP), R12B
-    MOVB    R12B, (R11)(R9*1)
-
-    INCQ    R9
-    JMP     bitunshuffle_byte_loop
-
-bitunshuffle_next_group:
-    INCQ    BX
-    CMPQ    BX, CX
-    JL      bitunshuffle_group_loop
+    JL      unshuffle512_fallback
+
+    MOVQ    R8, R10
+    SHRQ    $2, R10                 // R10 = numElements
+    MOVQ    R10, CX
+    SHRQ    $4, CX                  // CX = numChunks
+    JZ      unshuffle512_fallback
+
+    VBROADCASTI32X4 unshuffle4_lane<>(SB), Z2
+    VMOVDQU32       shuffle4_perm512<>(SB), Z3
+
+    // Input regions for byte positions 0..3
+    MOVQ    SI, R11
+    LEAQ    (SI)(R10*1), R12
+    LEAQ    (SI)(R10*2), R13
+    LEAQ    (R12)(R10*2), R14
+
+unshuffle512_loop:
+    VMOVDQU   (R11), X0
+    VINSERTI32X4 $1, (R12), Z0, Z0
+    VINSERTI32X4 $2, (R13), Z0, Z0
+    VINSERTI32X4 $3, (R14), Z0, Z0
+
+    VPERMD    Z0, Z3, Z1            // Lane L now holds all byte positions of elements 4L..4L+3
+    VPSHUFB   Z2, Z1, Z0            // Restore element order within each lane
+    VMOVDQU32 Z0, (DI)
+
+    ADDQ    $64, DI
+    ADDQ    $16, R11
+    ADDQ    $16, R12
+    ADDQ    $16, R13
+    ADDQ    $16, R14
+
+    DECQ    CX
+    JNZ     unshuffle512_loop
+
+    VZEROUPPER
+    MOVB    $1, ret+56(FP)
+    RET
+
+unshuffle512_fallback:
+    MOVB    $0, ret+56(FP)
+    RET
+
+// -----------------------------------------------------------------------------
+// AVX-512 bit transpose
+// -----------------------------------------------------------------------------
+//
+// BitShuffle byte-shuffles each tile and then spreads bit k of every byte in a
+// byte plane to bit row k. VPMOVB2M collects the top bit of 64 bytes at once;
+// adding the register to itself shifts every byte left to expose the next bit.
+
+// func bitTransposeAVX512(dst []byte, rowStride int, src []byte) bool
+// Processes src in 64-byte chunks, writing 8 bytes to each of the 8 bit rows
+// that start at dst and are rowStride bytes apart.
+TEXT ·bitTransposeAVX512(SB), NOSPLIT, $0-57
+    MOVQ    dst_base+0(FP), DI      // row 0
+    MOVQ    rowStride+24(FP), R8
+    MOVQ    src_base+32(FP), SI
+    MOVQ    src_len+40(FP), CX
+
+    SHRQ    $6, CX                  // CX = number of 64-byte chunks
+    JZ      bittranspose512_fallback
+
+    LEAQ    (DI)(R8*1), R9          // row 1
+    LEAQ    (R9)(R8*1), R10         // row 2
+    LEAQ    (R10)(R8*1), R11        // row 3
+    LEAQ    (R11)(R8*1), R12        // row 4
+    LEAQ    (R12)(R8*1), R13        // row 5
+    LEAQ    (R13)(R8*1), R14        // row 6
+    LEAQ    (R14)(R8*1), R15        // row 7
+
+bittranspose512_loop:
+    VMOVDQU64 (SI), Z0
+
+    VPMOVB2M Z0, K1
+    KMOVQ    K1, AX
+    MOVQ     AX, (R15)
+    VPADDB   Z0, Z0, Z0
+    VPMOVB2M Z0, K1
+    KMOVQ    K1, AX
+    MOVQ     AX, (R14)
+    VPADDB   Z0, Z0, Z0
+    VPMOVB2M Z0, K1
+    KMOVQ    K1, AX
+    MOVQ     AX, (R13)
+    VPADDB   Z0, Z0, Z0
+    VPMOVB2M Z0, K1
+    KMOVQ    K1, AX
+    MOVQ     AX, (R12)
+    VPADDB   Z0, Z0, Z0
+    VPMOVB2M Z0, K1
+    KMOVQ    K1, AX
+    MOVQ     AX, (R11)
+    VPADDB   Z0, Z0, Z0
+    VPMOVB2M Z0, K1
+    KMOVQ    K1, AX
+    MOVQ     AX, (R10)
+    VPADDB   Z0, Z0, Z0
+    VPMOVB2M Z0, K1
+    KMOVQ    K1, AX
+    MOVQ     AX, (R9)
+    VPADDB   Z0, Z0, Z0
+    VPMOVB2M Z0, K1
+    KMOVQ    K1, AX
+    MOVQ     AX, (DI)
+
+    ADDQ    $64, SI
+    ADDQ    $8, DI
+    ADDQ    $8, R9
+    ADDQ    $8, R10
+    ADDQ    $8, R11
+    ADDQ    $8, R12
+    ADDQ    $8, R13
+    ADDQ    $8, R14
+    ADDQ    $8, R15
+    DECQ    CX
+    JNZ     bittranspose512_loop
+
+    VZEROUPPER
+    MOVB    $1, ret+56(FP)
+    RET
+
+bittranspose512_fallback:
+    MOVB    $0, ret+56(FP)
+    RET
+
+// func bitUntransposeAVX512(dst, src []byte, rowStride int) bool
+// Reverses bitTransposeAVX512: rebuilds dst in 64-byte chunks from the 8 bit
+// rows that start at src. Each byte is accumulated MSB first by doubling it
+// and subtracting the 0x00/0xff mask expanded from the next row's bits.
+TEXT ·bitUntransposeAVX512(SB), NOSPLIT, $0-57
+    MOVQ    dst_base+0(FP), DI
+    MOVQ    dst_len+8(FP), CX
+    MOVQ    src_base+24(FP), SI     // row 0
+    MOVQ    rowStride+48(FP), R8
+
+    SHRQ    $6, CX                  // CX = number of 64-byte chunks
+    JZ      bituntranspose512_fallback
+
+    LEAQ    (SI)(R8*1), R9          // row 1
+    LEAQ    (R9)(R8*1), R10         // row 2
+    LEAQ    (R10)(R8*1), R11        // row 3
+    LEAQ    (R11)(R8*1), R12        // row 4
+    LEAQ    (R12)(R8*1), R13        // row 5
+    LEAQ    (R13)(R8*1), R14        // row 6
+    LEAQ    (R14)(R8*1), R15        // row 7
+
+bituntranspose512_loop:
+    KMOVQ    (R15), K1
+    VPMOVM2B K1, Z0
+    VPXORQ   Z1, Z1, Z1
+    VPSUBB   Z0, Z1, Z0
+    KMOVQ    (R14), K1
+    VPMOVM2B K1, Z1
+    VPADDB   Z0, Z0, Z0
+    VPSUBB   Z1, Z0, Z0
+    KMOVQ    (R13), K1
+    VPMOVM2B K1, Z1
+    VPADDB   Z0, Z0, Z0
+    VPSUBB   Z1, Z0, Z0
+    KMOVQ    (R12), K1
+    VPMOVM2B K1, Z1
+    VPADDB   Z0, Z0, Z0
+    VPSUBB   Z1, Z0, Z0
+    KMOVQ    (R11), K1
+    VPMOVM2B K1, Z1
+    VPADDB   Z0, Z0, Z0
+    VPSUBB   Z1, Z0, Z0
+    KMOVQ    (R10), K1
+    VPMOVM2B K1, Z1
+    VPADDB   Z0, Z0, Z0
+    VPSUBB   Z1, Z0, Z0
+    KMOVQ    (R9), K1
+    VPMOVM2B K1, Z1
+    VPADDB   Z0, Z0, Z0
+    VPSUBB   Z1, Z0, Z0
+    KMOVQ    (SI), K1
+    VPMOVM2B K1, Z1
+    VPADDB   Z0, Z0, Z0
+    VPSUBB   Z1, Z0, Z0
+
+    VMOVDQU64 Z0, (DI)
+
+    ADDQ    $64, DI
+    ADDQ    $8, SI
+    ADDQ    $8, R9
+    ADDQ    $8, R10
+    ADDQ    $8, R11
+    ADDQ    $8, R12
+    ADDQ    $8, R13
+    ADDQ    $8, R14
+    ADDQ    $8, R15
+    DECQ    CX
+    JNZ     bituntranspose512_loop
+
+    VZEROUPPER
+    MOVB    $1, ret+56(FP)
+    RET
+
+bituntranspose512_fallback:
+    MOVB    $0, ret+56(FP)
+    RET
+
+// -----------------------------------------------------------------------------
+// AVX2 bit transpose
+// -----------------------------------------------------------------------------
+//
+// The AVX2 counterpart of the AVX-512 kernels above. VPMOVMSKB collects the top
+// bit of 32 bytes, so each 64-byte chunk is held in two registers whose masks
+// are joined into the 8 bytes of a bit row.
+
+// BITROW_MSB stores the top bits of the 64 bytes in Y0:Y1 to row, then shifts
+// every byte left to expose the next bit
+#define BITROW_MSB(row) \
+    VPMOVMSKB Y0, AX;   \
+    VPMOVMSKB Y1, BX;   \
+    SHLQ      $32, BX;  \
+    ORQ       BX, AX;   \
+    MOVQ      AX, (row); \
+    VPADDB    Y0, Y0, Y0; \
+    VPADDB    Y1, Y1, Y1
+
+// func bitTransposeAVX2(dst []byte, rowStride int, src []byte) bool
+// Processes src in 64-byte chunks, writing 8 bytes to each of the 8 bit rows
+// that start at dst and are rowStride bytes apart.
+TEXT ·bitTransposeAVX2(SB), NOSPLIT, $0-57
+    MOVQ    dst_base+0(FP), DI      // row 0
+    MOVQ    rowStride+24(FP), R8
+    MOVQ    src_base+32(FP), SI
+    MOVQ    src_len+40(FP), CX
+
+    SHRQ    $6, CX                  // CX = number of 64-byte chunks
+    JZ      bittranspose2_fallback
+
+    LEAQ    (DI)(R8*1), R9          // row 1
+    LEAQ    (R9)(R8*1), R10         // row 2
+    LEAQ    (R10)(R8*1), R11        // row 3
+    LEAQ    (R11)(R8*1), R12        // row 4
+    LEAQ    (R12)(R8*1), R13        // row 5
+    LEAQ    (R13)(R8*1), R14        // row 6
+    LEAQ    (R14)(R8*1), R15        // row 7
+
+bittranspose2_loop:
+    VMOVDQU (SI), Y0
+    VMOVDQU 32(SI), Y1
+
+    BITROW_MSB(R15)
+    BITROW_MSB(R14)
+    BITROW_MSB(R13)
+    BITROW_MSB(R12)
+    BITROW_MSB(R11)
+    BITROW_MSB(R10)
+    BITROW_MSB(R9)
+    BITROW_MSB(DI)
+
+    ADDQ    $64, SI
+    ADDQ    $8, DI
+    ADDQ    $8, R9
+    ADDQ    $8, R10
+    ADDQ    $8, R11
+    ADDQ    $8, R12
+    ADDQ    $8, R13
+    ADDQ    $8, R14
+    ADDQ    $8, R15
+    DECQ    CX
+    JNZ     bittranspose2_loop
+
+    VZEROUPPER
+    MOVB    $1, ret+56(FP)
+    RET
+
+bittranspose2_fallback:
+    MOVB    $0, ret+56(FP)
+    RET
+
+// bitrow_spread copies byte i/8 of a broadcast dword to byte i of each lane
+DATA bitrow_spread<>+0(SB)/8, $0x0000000000000000
+DATA bitrow_spread<>+8(SB)/8, $0x0101010101010101
+DATA bitrow_spread<>+16(SB)/8, $0x0202020202020202
+DATA bitrow_spread<>+24(SB)/8, $0x0303030303030303
+GLOBL bitrow_spread<>(SB), RODATA, $32
+
+// bitrow_bits holds bit i%8 in byte i, broadcast to every qword
+DATA bitrow_bits<>+0(SB)/8, $0x8040201008040201
+GLOBL bitrow_bits<>(SB), RODATA, $8
+
+// BITROW_EXPAND expands the 32 bits at addr to 0x00/0xff bytes in y
+#define BITROW_EXPAND(addr, y) \
+    VPBROADCASTD addr, y;  \
+    VPSHUFB  Y14, y, y;    \
+    VPAND    Y15, y, y;    \
+    VPCMPEQB Y15, y, y
+
+// BITROW_ADD shifts the bytes in Y0:Y1 left and adds in the next bit, taken
+// from row
+#define BITROW_ADD(row) \
+    BITROW_EXPAND((row), Y2);  \
+    BITROW_EXPAND(4(row), Y3); \
+    VPADDB   Y0, Y0, Y0;       \
+    VPSUBB   Y2, Y0, Y0;       \
+    VPADDB   Y1, Y1, Y1;       \
+    VPSUBB   Y3, Y1, Y1
+
+// func bitUntransposeAVX2(dst, src []byte, rowStride int) bool
+// Reverses bitTransposeAVX2: rebuilds dst in 64-byte chunks from the 8 bit
+// rows that start at src, accumulating each byte MSB first as the AVX-512
+// kernel does.
+TEXT ·bitUntransposeAVX2(SB), NOSPLIT, $0-57
+    MOVQ    dst_base+0(FP), DI
+    MOVQ    dst_len+8(FP), CX
+    MOVQ    src_base+24(FP), SI     // row 0
+    MOVQ    rowStride+48(FP), R8
+
+    SHRQ    $6, CX                  // CX = number of 64-byte chunks
+    JZ      bituntranspose2_fallback
+
+    LEAQ    (SI)(R8*1), R9          // row 1
+    LEAQ    (R9)(R8*1), R10         // row 2
+    LEAQ    (R10)(R8*1), R11        // row 3
+    LEAQ    (R11)(R8*1), R12        // row 4
+    LEAQ    (R12)(R8*1), R13        // row 5
+    LEAQ    (R13)(R8*1), R14        // row 6
+    LEAQ    (R14)(R8*1), R15        // row 7
+
+    VMOVDQU      bitrow_spread<>(SB), Y14
+    VPBROADCASTQ bitrow_bits<>(SB), Y15
+
+bituntranspose2_loop:
+    VPXOR   Y0, Y0, Y0
+    VPXOR   Y1, Y1, Y1
+
+    BITROW_ADD(R15)
+    BITROW_ADD(R14)
+    BITROW_ADD(R13)
+    BITROW_ADD(R12)
+    BITROW_ADD(R11)
+    BITROW_ADD(R10)
+    BITROW_ADD(R9)
+    BITROW_ADD(SI)
+
+    VMOVDQU Y0, (DI)
+    VMOVDQU Y1, 32(DI)
+
+    ADDQ    $64, DI
+    ADDQ    $8, SI
+    ADDQ    $8, R9
+    ADDQ    $8, R10
+    ADDQ    $8, R11
+    ADDQ    $8, R12
+    ADDQ    $8, R13
+    ADDQ    $8, R14
+    ADDQ    $8, R15
+    DECQ    CX
+    JNZ     bituntranspose2_loop
+
+    VZEROUPPER
+    MOVB    $1, ret+56(FP)
+    RET
+
+bituntranspose2_fallback:
+    MOVB    $0, ret+56(FP)
+    RET
+
+// =============================================================================
+// SSE2 Implementation
+// =============================================================================
+//
+// SSE2 is part of the amd64 baseline, so these kernels are always usable. SSE2
+// lacks PSHUFB; byte transposes are built from PUNPCKLBW interleaves instead.
+// Interleaving the two halves of a 16-byte register rotates each byte's 4-bit
+// index left by one, so two interleaves transpose a 4x4 byte matrix.
+
+// INTERLEAVE16B interleaves the low and high 8 bytes of x: [x0 x8 x1 x9 ...]
+#define INTERLEAVE16B(x, t) \
+    PSHUFD    $0x4e, x, t; \
+    PUNPCKLBW t, x
+
+// TRANSPOSE4X4B transposes x viewed as a 4x4 byte matrix (self-inverse)
+#define TRANSPOSE4X4B(x, t) \
+    INTERLEAVE16B(x, t); \
+    INTERLEAVE16B(x, t)
+
+// TRANSPOSE4X4D transposes the dwords of rows X0..X3.
+// Output rows are left in X6, X4, X7, X0. Clobbers X1, X2, X5.
+#define TRANSPOSE4X4D \
+    MOVO       X0, X4; \
+    PUNPCKLLQ  X1, X4; \
+    PUNPCKHLQ  X1, X0; \
+    MOVO       X2, X5; \
+    PUNPCKLLQ  X3, X5; \
+    PUNPCKHLQ  X3, X2; \
+    MOVO       X4, X6; \
+    PUNPCKLQDQ X5, X6; \
+    PUNPCKHQDQ X5, X4; \
+    MOVO       X0, X7; \
+    PUNPCKLQDQ X2, X7; \
+    PUNPCKHQDQ X2, X0
+
+// TRANSPOSE8X8W transposes the words of rows X0..X7.
+// Output rows are left in X12, X1, X13, X8, X14, X3, X15, X0.
+#define TRANSPOSE8X8W \
+    MOVO       X0, X8;  \
+    PUNPCKLWL  X1, X8;  \
+    PUNPCKHWL  X1, X0;  \
+    MOVO       X2, X9;  \
+    PUNPCKLWL  X3, X9;  \
+    PUNPCKHWL  X3, X2;  \
+    MOVO       X4, X10; \
+    PUNPCKLWL  X5, X10; \
+    PUNPCKHWL  X5, X4;  \
+    MOVO       X6, X11; \
+    PUNPCKLWL  X7, X11; \
+    PUNPCKHWL  X7, X6;  \
+    MOVO       X8, X1;  \
+    PUNPCKLLQ  X9, X1;  \
+    PUNPCKHLQ  X9, X8;  \
+    MOVO       X0, X3;  \
+    PUNPCKLLQ  X2, X3;  \
+    PUNPCKHLQ  X2, X0;  \
+    MOVO       X10, X5; \
+    PUNPCKLLQ  X11, X5; \
+    PUNPCKHLQ  X11, X10; \
+    MOVO       X4, X7;  \
+    PUNPCKLLQ  X6, X7;  \
+    PUNPCKHLQ  X6, X4;  \
+    MOVO       X1, X12; \
+    PUNPCKLQDQ X5, X12; \
+    PUNPCKHQDQ X5, X1;  \
+    MOVO       X8, X13; \
+    PUNPCKLQDQ X10, X13; \
+    PUNPCKHQDQ X10, X8; \
+    MOVO       X3, X14; \
+    PUNPCKLQDQ X7, X14; \
+    PUNPCKHQDQ X7, X3;  \
+    MOVO       X0, X15; \
+    PUNPCKLQDQ X4, X15; \
+    PUNPCKHQDQ X4, X0
+
+// DEINTERLEAVE16B reverses INTERLEAVE16B. mask must hold 0x00ff in every word.
+#define DEINTERLEAVE16B(x, t, mask) \
+    MOVO     x, t;    \
+    PSRLW    $8, t;   \
+    PAND     mask, x; \
+    PACKUSWB t, x
+
+// func shuffleBytesSSE2(dst, src []byte, typeSize int) bool
+// Processes 16 elements per iteration for typeSize 4 (64 bytes) and 8 (128 bytes).
+TEXT ·shuffleBytesSSE2(SB), NOSPLIT, $0-57
+    MOVQ    dst_base+0(FP), DI      // dst pointer
+    MOVQ    dst_len+8(FP), R8       // dst length (n)
+    MOVQ    src_base+24(FP), SI     // src pointer
+    MOVQ    typeSize+48(FP), DX     // typeSize
+
+    CMPQ    DX, $8
+    JEQ     shuffle_sse2_ts8
+    CMPQ    DX, $4
+    JNE     shuffle_sse2_fallback
+
+    MOVQ    R8, R10
+    SHRQ    $2, R10                 // R10 = numElements
+    MOVQ    R10, CX
+    SHRQ    $4, CX                  // CX = numChunks = numElements / 16
+    JZ      shuffle_sse2_fallback
+
+    MOVQ    DI, R11
+    LEAQ    (DI)(R10*1), R12
+    LEAQ    (DI)(R10*2), R13
+    LEAQ    (R12)(R10*2), R14
+
+shuffle_sse2_ts4_loop:
+    MOVOU   0(SI), X0
+    MOVOU   16(SI), X1
+    MOVOU   32(SI), X2
+    MOVOU   48(SI), X3
+    TRANSPOSE4X4B(X0, X8)
+    TRANSPOSE4X4B(X1, X8)
+    TRANSPOSE4X4B(X2, X8)
+    TRANSPOSE4X4B(X3, X8)
+    TRANSPOSE4X4D
+    MOVOU   X6, (R11)
+    MOVOU   X4, (R12)
+    MOVOU   X7, (R13)
+    MOVOU   X0, (R14)
+
+    ADDQ    $64, SI
+    ADDQ    $16, R11
+    ADDQ    $16, R12
+    ADDQ    $16, R13
+    ADDQ    $16, R14
+    DECQ    CX
+    JNZ     shuffle_sse2_ts4_loop
+
+    MOVB    $1, ret+56(FP)
+    RET
+
+shuffle_sse2_ts8:
+    MOVQ    R8, R10
+    SHRQ    $3, R10                 // R10 = numElements
+    MOVQ    R10, CX
+    SHRQ    $4, CX                  // CX = numChunks = numElements / 16
+    JZ      shuffle_sse2_fallback
+
+    MOVQ    DI, R11                 // R11 = output offset for byte position 0
+
+shuffle_sse2_ts8_loop:
+    MOVOU   0(SI), X0
+    MOVOU   16(SI), X1
+    MOVOU   32(SI), X2
+    MOVOU   48(SI), X3
+    MOVOU   64(SI), X4
+    MOVOU   80(SI), X5
+    MOVOU   96(SI), X6
+    MOVOU   112(SI), X7
+    INTERLEAVE16B(X0, X8)
+    INTERLEAVE16B(X1, X8)
+    INTERLEAVE16B(X2, X8)
+    INTERLEAVE16B(X3, X8)
+    INTERLEAVE16B(X4, X8)
+    INTERLEAVE16B(X5, X8)
+    INTERLEAVE16B(X6, X8)
+    INTERLEAVE16B(X7, X8)
+    TRANSPOSE8X8W
+
+    MOVQ    R11, R13
+    MOVOU   X12, (R13)
+    ADDQ    R10, R13
+    MOVOU   X1, (R13)
+    ADDQ    R10, R13
+    MOVOU   X13, (R13)
+    ADDQ    R10, R13
+    MOVOU   X8, (R13)
+    ADDQ    R10, R13
+    MOVOU   X14, (R13)
+    ADDQ    R10, R13
+    MOVOU   X3, (R13)
+    ADDQ    R10, R13
+    MOVOU   X15, (R13)
+    ADDQ    R10, R13
+    MOVOU   X0, (R13)
+
+    ADDQ    $128, SI
+    ADDQ    $16, R11
+    DECQ    CX
+    JNZ     shuffle_sse2_ts8_loop
+
+    MOVB    $1, ret+56(FP)
+    RET
+
+shuffle_sse2_fallback:
+    MOVB    $0, ret+56(FP)
+    RET
+
+// func unshuffleBytesSSE2(dst, src []byte, typeSize int) bool
+TEXT ·unshuffleBytesSSE2(SB), NOSPLIT, $0-57
+    MOVQ    dst_base+0(FP), DI      // dst pointer
+    MOVQ    dst_len+8(FP), R8       // dst length (n)
+    MOVQ    src_base+24(FP), SI     // src pointer
+    MOVQ    typeSize+48(FP), DX     // typeSize
+
+    CMPQ    DX, $8
+    JEQ     unshuffle_sse2_ts8
+    CMPQ    DX, $4
+    JNE     unshuffle_sse2_fallback
+
+    MOVQ    R8, R10
+    SHRQ    $2, R10                 // R10 = numElements
+    MOVQ    R10, CX
+    SHRQ    $4, CX                  // CX = numChunks
+    JZ      unshuffle_sse2_fallback
+
+    MOVQ    SI, R11
+    LEAQ    (SI)(R10*1), R12
+    LEAQ    (SI)(R10*2), R13
+    LEAQ    (R12)(R10*2), R14
+
+unshuffle_sse2_ts4_loop:
+    MOVOU   (R11), X0
+    MOVOU   (R12), X1
+    MOVOU   (R13), X2
+    MOVOU   (R14), X3
+    TRANSPOSE4X4D
+    TRANSPOSE4X4B(X6, X8)
+    TRANSPOSE4X4B(X4, X8)
+    TRANSPOSE4X4B(X7, X8)
+    TRANSPOSE4X4B(X0, X8)
+    MOVOU   X6, 0(DI)
+    MOVOU   X4, 16(DI)
+    MOVOU   X7, 32(DI)
+    MOVOU   X0, 48(DI)
+
+    ADDQ    $64, DI
+    ADDQ    $16, R11
+    ADDQ    $16, R12
+    ADDQ    $16, R13
+    ADDQ    $16, R14
+    DECQ    CX
+    JNZ     unshuffle_sse2_ts4_loop
+
+    MOVB    $1, ret+56(FP)
+    RET
+
+unshuffle_sse2_ts8:
+    MOVQ    R8, R10
+    SHRQ    $3, R10                 // R10 = numElements
+    MOVQ    R10, CX
+    SHRQ    $4, CX                  // CX = numChunks
+    JZ      unshuffle_sse2_fallback
+
+    MOVQ    SI, R11                 // R11 = input offset for byte position 0
+
+unshuffle_sse2_ts8_loop:
+    MOVQ    R11, R13
+    MOVOU   (R13), X0
+    ADDQ    R10, R13
+    MOVOU   (R13), X1
+    ADDQ    R10, R13
+    MOVOU   (R13), X2
+    ADDQ    R10, R13
+    MOVOU   (R13), X3
+    ADDQ    R10, R13
+    MOVOU   (R13), X4
+    ADDQ    R10, R13
+    MOVOU   (R13), X5
+    ADDQ    R10, R13
+    MOVOU   (R13), X6
+    ADDQ    R10, R13
+    MOVOU   (R13), X7
+    TRANSPOSE8X8W
+
+    PCMPEQW X9, X9
+    PSRLW   $8, X9                  // X9 = 0x00ff in every word
+    DEINTERLEAVE16B(X12, X2, X9)
+    DEINTERLEAVE16B(X1, X2, X9)
+    DEINTERLEAVE16B(X13, X2, X9)
+    DEINTERLEAVE16B(X8, X2, X9)
+    DEINTERLEAVE16B(X14, X2, X9)
+    DEINTERLEAVE16B(X3, X2, X9)
+    DEINTERLEAVE16B(X15, X2, X9)
+    DEINTERLEAVE16B(X0, X2, X9)
+    MOVOU   X12, 0(DI)
+    MOVOU   X1, 16(DI)
+    MOVOU   X13, 32(DI)
+    MOVOU   X8, 48(DI)
+    MOVOU   X14, 64(DI)
+    MOVOU   X3, 80(DI)
+    MOVOU   X15, 96(DI)
+    MOVOU   X0, 112(DI)
+
+    ADDQ    $128, DI
+    ADDQ    $16, R11
+    DECQ    CX
+    JNZ     unshuffle_sse2_ts8_loop
 
     MOVB    $1, ret+56(FP)
     RET
 
-bitunshuffle_fallback:
+unshuffle_sse2_fallback:
     MOVB    $0, ret+56(FP)
     RET
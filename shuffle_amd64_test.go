@@ -4,6 +4,7 @@ package blosc
 
 import (
 	"bytes"
+	"encoding/binary"
 	"testing"
 )
 
@@ -149,6 +150,285 @@ func TestShuffleRoundTripAVX2(t *testing.T) {
 	}
 }
 
+func TestHasAVX512BW(t *testing.T) {
+	// Just verify the function works - result depends on CPU
+	result := hasAVX512BW()
+	t.Logf("hasAVX512BW() = %v", result)
+}
+
+func TestShuffleBytesAVX512Direct(t *testing.T) {
+	if !hasAVX512BW() {
+		t.Skip("AVX-512BW not supported on this CPU")
+	}
+
+	tests := []struct {
+		name      string
+		dataLen   int
+		typeSize  int
+		expectAVX bool
+	}{
+		{"64 bytes typeSize=4", 64, 4, true},
+		{"128 bytes typeSize=4", 128, 4, true},
+		{"1000 bytes typeSize=4", 1000, 4, true},
+		{"32 bytes typeSize=4", 32, 4, false}, // Too small
+		{"64 bytes typeSize=2", 64, 2, false}, // Wrong typeSize
+		{"64 bytes typeSize=8", 64, 8, false}, // Wrong typeSize
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := makeTestData(tt.dataLen)
+			dst := make([]byte, len(src))
+
+			used := shuffleBytesAVX512(dst, src, tt.typeSize)
+
+			if used != tt.expectAVX {
+				t.Errorf("shuffleBytesAVX512 returned %v, expected %v", used, tt.expectAVX)
+			}
+
+			if used {
+				expected := shuffleBytesGeneric(src, tt.typeSize)
+				numElements := tt.dataLen / tt.typeSize
+				processedElements := (numElements / 16) * 16
+
+				for j := 0; j < tt.typeSize; j++ {
+					for i := 0; i < processedElements; i++ {
+						if dst[j*numElements+i] != expected[j*numElements+i] {
+							t.Errorf("mismatch at byte position %d, element %d: got %d, want %d",
+								j, i, dst[j*numElements+i], expected[j*numElements+i])
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestUnshuffleBytesAVX512Direct(t *testing.T) {
+	if !hasAVX512BW() {
+		t.Skip("AVX-512BW not supported on this CPU")
+	}
+
+	for _, dataLen := range []int{64, 128, 1000, 4096} {
+		original := makeTestData(dataLen)
+		shuffled := shuffleBytesGeneric(original, 4)
+
+		dst := make([]byte, len(shuffled))
+		if !unshuffleBytesAVX512(dst, shuffled, 4) {
+			t.Fatalf("unshuffleBytesAVX512 returned false for %d bytes", dataLen)
+		}
+
+		processed := (dataLen / 4 / 16) * 16 * 4
+		if !bytes.Equal(dst[:processed], original[:processed]) {
+			t.Errorf("unshuffle mismatch for %d bytes", dataLen)
+		}
+	}
+}
+
+func TestBitTransposeAVX512MatchesGeneric(t *testing.T) {
+	if !hasAVX512BW() {
+		t.Skip("AVX-512BW not supported on this CPU")
+	}
+
+	for _, planeLen := range []int{64, 128, 1024} {
+		src := makeRandomData(planeLen)
+		rowStride := planeLen / 8
+
+		// Reference result from the scalar path
+		expected := make([]byte, planeLen)
+		for i := 0; i < planeLen; i += 8 {
+			x := transposeBits8x8(binary.LittleEndian.Uint64(src[i:]))
+			for k := 0; k < 8; k++ {
+				expected[k*rowStride+i/8] = byte(x >> (8 * k))
+			}
+		}
+
+		dst := make([]byte, planeLen)
+		if !bitTransposeAVX512(dst, rowStride, src) {
+			t.Fatalf("bitTransposeAVX512 returned false for %d bytes", planeLen)
+		}
+		if !bytes.Equal(dst, expected) {
+			t.Errorf("bit transpose mismatch for %d bytes", planeLen)
+		}
+
+		back := make([]byte, planeLen)
+		if !bitUntransposeAVX512(back, dst, rowStride) {
+			t.Fatalf("bitUntransposeAVX512 returned false for %d bytes", planeLen)
+		}
+		if !bytes.Equal(back, src) {
+			t.Errorf("bit untranspose mismatch for %d bytes", planeLen)
+		}
+	}
+
+	// Inputs shorter than one 64-byte chunk fall back
+	if bitTransposeAVX512(make([]byte, 32), 4, makeTestData(32)) {
+		t.Error("bitTransposeAVX512 should not handle 32 bytes")
+	}
+}
+
+func TestBitShuffleAVX512MatchesScalar(t *testing.T) {
+	if !hasAVX512BW() {
+		t.Skip("AVX-512BW not supported on this CPU")
+	}
+
+	for _, typeSize := range []int{1, 2, 4, 8, 16} {
+		src := makeRandomData(typeSize * 8 * 1000)
+
+		saved := useAVX512
+		useAVX512 = false
+		expected := bitShuffle(src, typeSize)
+		useAVX512 = saved
+
+		shuffled := bitShuffle(src, typeSize)
+		if !bytes.Equal(shuffled, expected) {
+			t.Errorf("typeSize=%d: AVX-512 bitshuffle differs from scalar", typeSize)
+		}
+		if !bytes.Equal(bitUnshuffle(shuffled, typeSize), src) {
+			t.Errorf("typeSize=%d: AVX-512 bitshuffle round-trip failed", typeSize)
+		}
+	}
+}
+
+func TestBitTransposeAVX2MatchesGeneric(t *testing.T) {
+	if !hasAVX2() {
+		t.Skip("AVX2 not supported on this CPU")
+	}
+
+	for _, planeLen := range []int{64, 128, 1024, 1088} {
+		src := makeRandomData(planeLen)
+		rowStride := planeLen / 8
+
+		// Reference result from the scalar path
+		expected := make([]byte, planeLen)
+		for i := 0; i < planeLen; i += 8 {
+			x := transposeBits8x8(binary.LittleEndian.Uint64(src[i:]))
+			for k := 0; k < 8; k++ {
+				expected[k*rowStride+i/8] = byte(x >> (8 * k))
+			}
+		}
+
+		dst := make([]byte, planeLen)
+		if !bitTransposeAVX2(dst, rowStride, src) {
+			t.Fatalf("bitTransposeAVX2 returned false for %d bytes", planeLen)
+		}
+		if !bytes.Equal(dst, expected) {
+			t.Errorf("bit transpose mismatch for %d bytes", planeLen)
+		}
+
+		back := make([]byte, planeLen)
+		if !bitUntransposeAVX2(back, dst, rowStride) {
+			t.Fatalf("bitUntransposeAVX2 returned false for %d bytes", planeLen)
+		}
+		if !bytes.Equal(back, src) {
+			t.Errorf("bit untranspose mismatch for %d bytes", planeLen)
+		}
+	}
+
+	// Inputs shorter than one 64-byte chunk fall back
+	if bitTransposeAVX2(make([]byte, 32), 4, makeTestData(32)) {
+		t.Error("bitTransposeAVX2 should not handle 32 bytes")
+	}
+}
+
+func TestBitShuffleAVX2MatchesScalar(t *testing.T) {
+	if !hasAVX2() {
+		t.Skip("AVX2 not supported on this CPU")
+	}
+	savedAVX512, savedAVX2 := useAVX512, useAVX2
+	defer func() { useAVX512, useAVX2 = savedAVX512, savedAVX2 }()
+	useAVX512 = false
+
+	for _, typeSize := range []int{1, 2, 4, 8, 16} {
+		src := makeRandomData(typeSize * 8 * 1000)
+
+		useAVX2 = false
+		expected := bitShuffle(src, typeSize)
+		useAVX2 = true
+
+		shuffled := bitShuffle(src, typeSize)
+		if !bytes.Equal(shuffled, expected) {
+			t.Errorf("typeSize=%d: AVX2 bitshuffle differs from scalar", typeSize)
+		}
+		if !bytes.Equal(bitUnshuffle(shuffled, typeSize), src) {
+			t.Errorf("typeSize=%d: AVX2 bitshuffle round-trip failed", typeSize)
+		}
+	}
+}
+
+func TestShuffleBytesSSE2Direct(t *testing.T) {
+	tests := []struct {
+		name      string
+		dataLen   int
+		typeSize  int
+		expectSSE bool
+	}{
+		{"64 bytes typeSize=4", 64, 4, true},
+		{"1000 bytes typeSize=4", 1000, 4, true},
+		{"128 bytes typeSize=8", 128, 8, true},
+		{"1000 bytes typeSize=8", 1000, 8, true},
+		{"32 bytes typeSize=4", 32, 4, false},   // Too small
+		{"64 bytes typeSize=8", 64, 8, false},   // Too small
+		{"128 bytes typeSize=2", 128, 2, false}, // Wrong typeSize
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := makeTestData(tt.dataLen)
+			dst := make([]byte, len(src))
+
+			used := shuffleBytesSSE2(dst, src, tt.typeSize)
+			if used != tt.expectSSE {
+				t.Errorf("shuffleBytesSSE2 returned %v, expected %v", used, tt.expectSSE)
+			}
+
+			if used {
+				expected := shuffleBytesGeneric(src, tt.typeSize)
+				numElements := tt.dataLen / tt.typeSize
+				processedElements := (numElements / 16) * 16
+
+				for j := 0; j < tt.typeSize; j++ {
+					for i := 0; i < processedElements; i++ {
+						if dst[j*numElements+i] != expected[j*numElements+i] {
+							t.Fatalf("mismatch at byte position %d, element %d: got %d, want %d",
+								j, i, dst[j*numElements+i], expected[j*numElements+i])
+						}
+					}
+				}
+
+				back := make([]byte, len(dst))
+				if !unshuffleBytesSSE2(back, expected, tt.typeSize) {
+					t.Fatal("unshuffleBytesSSE2 returned false")
+				}
+				processed := processedElements * tt.typeSize
+				if !bytes.Equal(back[:processed], src[:processed]) {
+					t.Error("unshuffleBytesSSE2 did not restore processed elements")
+				}
+			}
+		})
+	}
+}
+
+func TestShuffleRoundTripSSE2(t *testing.T) {
+	// Force the SSE2 path by disabling the wider kernels
+	savedAVX512, savedAVX2 := useAVX512, useAVX2
+	useAVX512, useAVX2 = false, false
+	defer func() { useAVX512, useAVX2 = savedAVX512, savedAVX2 }()
+
+	for _, typeSize := range []int{4, 8} {
+		for _, dataLen := range []int{64, 100, 128, 1000, 100003} {
+			original := makeTestData(dataLen)
+
+			shuffled := shuffleBytes(original, typeSize)
+			if !bytes.Equal(shuffled, shuffleBytesGeneric(original, typeSize)) {
+				t.Errorf("shuffle mismatch for typeSize=%d, %d bytes", typeSize, dataLen)
+			}
+			if !bytes.Equal(original, unshuffleBytes(shuffled, typeSize)) {
+				t.Errorf("round-trip failed for typeSize=%d, %d bytes", typeSize, dataLen)
+			}
+		}
+	}
+}
+
 // shuffleBytesGeneric is a copy of the generic implementation for testing
 func shuffleBytesGeneric(src []byte, typeSize int) []byte {
 	if typeSize <= 1 || len(src) < typeSize {
@@ -213,3 +493,95 @@ func BenchmarkShuffleGenericOnly(b *testing.B) {
 		_ = shuffleBytesGeneric(data, 4)
 	}
 }
+
+func BenchmarkShuffleAVX512(b *testing.B) {
+	if !hasAVX512BW() {
+		b.Skip("AVX-512BW not supported on this CPU")
+	}
+
+	data := makeTestData(100000)
+	b.ResetTimer()
+	b.SetBytes(int64(len(data)))
+
+	for i := 0; i < b.N; i++ {
+		_ = shuffleBytes(data, 4)
+	}
+}
+
+func BenchmarkBitShuffleAVX512(b *testing.B) {
+	if !hasAVX512BW() {
+		b.Skip("AVX-512BW not supported on this CPU")
+	}
+
+	data := makeTestData(102400)
+	b.ResetTimer()
+	b.SetBytes(int64(len(data)))
+
+	for i := 0; i < b.N; i++ {
+		_ = bitShuffle(data, 8)
+	}
+}
+
+func BenchmarkBitShuffleAVX2(b *testing.B) {
+	if !hasAVX2() {
+		b.Skip("AVX2 not supported on this CPU")
+	}
+	saved := useAVX512
+	useAVX512 = false
+	defer func() { useAVX512 = saved }()
+
+	data := makeTestData(102400)
+	b.ResetTimer()
+	b.SetBytes(int64(len(data)))
+
+	for i := 0; i < b.N; i++ {
+		_ = bitShuffle(data, 8)
+	}
+}
+
+func BenchmarkBitUnshuffleAVX2(b *testing.B) {
+	if !hasAVX2() {
+		b.Skip("AVX2 not supported on this CPU")
+	}
+	saved := useAVX512
+	useAVX512 = false
+	defer func() { useAVX512 = saved }()
+
+	shuffled := bitShuffle(makeTestData(102400), 8)
+	b.ResetTimer()
+	b.SetBytes(int64(len(shuffled)))
+
+	for i := 0; i < b.N; i++ {
+		_ = bitUnshuffle(shuffled, 8)
+	}
+}
+
+// BenchmarkBitShuffleGenericOnly benchmarks the bit transpose without SIMD,
+// the path arm64 and the other platforms take
+func BenchmarkBitShuffleGenericOnly(b *testing.B) {
+	savedAVX512, savedAVX2 := useAVX512, useAVX2
+	useAVX512, useAVX2 = false, false
+	defer func() { useAVX512, useAVX2 = savedAVX512, savedAVX2 }()
+
+	data := makeTestData(102400)
+	b.ResetTimer()
+	b.SetBytes(int64(len(data)))
+
+	for i := 0; i < b.N; i++ {
+		_ = bitShuffle(data, 8)
+	}
+}
+
+func BenchmarkShuffleSSE2(b *testing.B) {
+	savedAVX512, savedAVX2 := useAVX512, useAVX2
+	useAVX512, useAVX2 = false, false
+	defer func() { useAVX512, useAVX2 = savedAVX512, savedAVX2 }()
+
+	data := makeTestData(100000)
+	b.ResetTimer()
+	b.SetBytes(int64(len(data)))
+
+	for i := 0; i < b.N; i++ {
+		_ = shuffleBytes(data, 8)
+	}
+}
@@ -9,6 +9,18 @@ var useNEON = true
 // useAVX2 is always false on ARM64 platforms.
 var useAVX2 = false
 
+// useAVX512 is always false on ARM64 platforms.
+var useAVX512 = false
+
+// useSSE2 is always false on ARM64 platforms.
+var useSSE2 = false
+
+// useSIMD128 is always false on ARM64 platforms.
+var useSIMD128 = false
+
+// useRVV is always false on ARM64 platforms.
+var useRVV = false
+
 // initSIMD is a no-op on ARM64 since NEON is always available.
 func initSIMD() {}
 
@@ -36,24 +48,62 @@ func unshuffleBytesAVX2(dst, src []byte, typeSize int) bool {
 	return false
 }
 
-// bitShuffleAVX2 is not available on ARM64 platforms.
-func bitShuffleAVX2(dst, src []byte, typeSize int) bool {
+// shuffleBytesSSE2 is not available on ARM64 platforms.
+func shuffleBytesSSE2(dst, src []byte, typeSize int) bool {
+	return false
+}
+
+// unshuffleBytesSSE2 is not available on ARM64 platforms.
+func unshuffleBytesSSE2(dst, src []byte, typeSize int) bool {
+	return false
+}
+
+// shuffleBytesAVX512 is not available on ARM64 platforms.
+func shuffleBytesAVX512(dst, src []byte, typeSize int) bool {
+	return false
+}
+
+// unshuffleBytesAVX512 is not available on ARM64 platforms.
+func unshuffleBytesAVX512(dst, src []byte, typeSize int) bool {
+	return false
+}
+
+// bitTransposeAVX512 is not available on ARM64 platforms.
+func bitTransposeAVX512(dst []byte, rowStride int, src []byte) bool {
+	return false
+}
+
+// bitUntransposeAVX512 is not available on ARM64 platforms.
+func bitUntransposeAVX512(dst, src []byte, rowStride int) bool {
+	return false
+}
+
+// bitTransposeAVX2 is not available on ARM64 platforms.
+func bitTransposeAVX2(dst []byte, rowStride int, src []byte) bool {
+	return false
+}
+
+// bitUntransposeAVX2 is not available on ARM64 platforms.
+func bitUntransposeAVX2(dst, src []byte, rowStride int) bool {
+	return false
+}
+
+// shuffleBytesSIMD128 is not available on ARM64 platforms.
+func shuffleBytesSIMD128(dst, src []byte, typeSize int) bool {
 	return false
 }
 
-// bitUnshuffleAVX2 is not available on ARM64 platforms.
-func bitUnshuffleAVX2(dst, src []byte, typeSize int) bool {
+// unshuffleBytesSIMD128 is not available on ARM64 platforms.
+func unshuffleBytesSIMD128(dst, src []byte, typeSize int) bool {
 	return false
 }
 
-// bitShuffleNEON performs bit-level shuffle using NEON instructions.
-// Currently not implemented - returns false to fall back to generic.
-func bitShuffleNEON(dst, src []byte, typeSize int) bool {
+// shuffleBytesRVV is not available on ARM64 platforms.
+func shuffleBytesRVV(dst, src []byte, typeSize int) bool {
 	return false
 }
 
-// bitUnshuffleNEON reverses bit-level shuffle using NEON instructions.
-// Currently not implemented - returns false to fall back to generic.
-func bitUnshuffleNEON(dst, src []byte, typeSize int) bool {
+// unshuffleBytesRVV is not available on ARM64 platforms.
+func unshuffleBytesRVV(dst, src []byte, typeSize int) bool {
 	return false
 }
@@ -1,13 +1,25 @@
-//go:build !amd64 && !arm64
+//go:build !amd64 && !arm64 && !(wasm && go1.27 && !blosc_nosimd128) && !(riscv64 && go1.25)
 
 package blosc
 
 // useAVX2 is always false on non-amd64/non-arm64 platforms.
 var useAVX2 = false
 
+// useAVX512 is always false on non-amd64 platforms.
+var useAVX512 = false
+
+// useSSE2 is always false on non-amd64 platforms.
+var useSSE2 = false
+
 // useNEON is always false on non-arm64 platforms.
 var useNEON = false
 
+// useSIMD128 is false without the WebAssembly SIMD128 kernels.
+var useSIMD128 = false
+
+// useRVV is false without the RISC-V vector kernels.
+var useRVV = false
+
 // initSIMD is a no-op on non-amd64 platforms.
 func initSIMD() {}
 
@@ -31,22 +43,62 @@ func unshuffleBytesNEON(dst, src []byte, typeSize int) bool {
 	return false
 }
 
-// bitShuffleAVX2 is not available on non-amd64 platforms.
-func bitShuffleAVX2(dst, src []byte, typeSize int) bool {
+// shuffleBytesSSE2 is not available on non-amd64 platforms.
+func shuffleBytesSSE2(dst, src []byte, typeSize int) bool {
+	return false
+}
+
+// unshuffleBytesSSE2 is not available on non-amd64 platforms.
+func unshuffleBytesSSE2(dst, src []byte, typeSize int) bool {
+	return false
+}
+
+// shuffleBytesAVX512 is not available on non-amd64 platforms.
+func shuffleBytesAVX512(dst, src []byte, typeSize int) bool {
+	return false
+}
+
+// unshuffleBytesAVX512 is not available on non-amd64 platforms.
+func unshuffleBytesAVX512(dst, src []byte, typeSize int) bool {
+	return false
+}
+
+// bitTransposeAVX512 is not available on non-amd64 platforms.
+func bitTransposeAVX512(dst []byte, rowStride int, src []byte) bool {
+	return false
+}
+
+// bitUntransposeAVX512 is not available on non-amd64 platforms.
+func bitUntransposeAVX512(dst, src []byte, rowStride int) bool {
+	return false
+}
+
+// bitTransposeAVX2 is not available on non-amd64 platforms.
+func bitTransposeAVX2(dst []byte, rowStride int, src []byte) bool {
+	return false
+}
+
+// bitUntransposeAVX2 is not available on non-amd64 platforms.
+func bitUntransposeAVX2(dst, src []byte, rowStride int) bool {
+	return false
+}
+
+// shuffleBytesSIMD128 is only available on WebAssembly with SIMD128.
+func shuffleBytesSIMD128(dst, src []byte, typeSize int) bool {
 	return false
 }
 
-// bitUnshuffleAVX2 is not available on non-amd64 platforms.
-func bitUnshuffleAVX2(dst, src []byte, typeSize int) bool {
+// unshuffleBytesSIMD128 is only available on WebAssembly with SIMD128.
+func unshuffleBytesSIMD128(dst, src []byte, typeSize int) bool {
 	return false
 }
 
-// bitShuffleNEON is not available on non-arm64 platforms.
-func bitShuffleNEON(dst, src []byte, typeSize int) bool {
+// shuffleBytesRVV is only available on RISC-V with Go 1.25 or later.
+func shuffleBytesRVV(dst, src []byte, typeSize int) bool {
 	return false
 }
 
-// bitUnshuffleNEON is not available on non-arm64 platforms.
-func bitUnshuffleNEON(dst, src []byte, typeSize int) bool {
+// unshuffleBytesRVV is only available on RISC-V with Go 1.25 or later.
+func unshuffleBytesRVV(dst, src []byte, typeSize int) bool {
 	return false
 }
@@ -0,0 +1,117 @@
+//go:build riscv64 && go1.25
+
+package blosc
+
+// useAVX2 is always false on RISC-V platforms.
+var useAVX2 = false
+
+// useAVX512 is always false on RISC-V platforms.
+var useAVX512 = false
+
+// useSSE2 is always false on RISC-V platforms.
+var useSSE2 = false
+
+// useNEON is always false on RISC-V platforms.
+var useNEON = false
+
+// useSIMD128 is always false on RISC-V platforms.
+var useSIMD128 = false
+
+// useRVV indicates whether the RISC-V vector (RVV 1.0) kernels are used.
+// It is set by initSIMD when the CPU has the V extension.
+var useRVV = false
+
+// initSIMD detects the V extension at package initialization.
+func initSIMD() {
+	useRVV = hasRVV()
+}
+
+// hasRVV reports whether every core has the V extension: always in a
+// GORISCV64=rva23u64 build, which requires it, and otherwise as the
+// riscv_hwprobe system call of Linux 6.4 and later reports. Other systems
+// get the generic code.
+func hasRVV() bool
+
+// shuffleBytesRVV shuffles bytes using RVV segment loads, for typeSize 2 to
+// 8. It processes every element, as many at a time as the vector length
+// allows, and returns false for other type sizes.
+//
+//go:noescape
+func shuffleBytesRVV(dst, src []byte, typeSize int) bool
+
+// unshuffleBytesRVV unshuffles bytes using RVV segment stores, for typeSize
+// 2 to 8. It processes every element and returns false for other type
+// sizes.
+//
+//go:noescape
+func unshuffleBytesRVV(dst, src []byte, typeSize int) bool
+
+// shuffleBytesAVX2 is not available on RISC-V platforms.
+func shuffleBytesAVX2(dst, src []byte, typeSize int) bool {
+	return false
+}
+
+// unshuffleBytesAVX2 is not available on RISC-V platforms.
+func unshuffleBytesAVX2(dst, src []byte, typeSize int) bool {
+	return false
+}
+
+// shuffleBytesSSE2 is not available on RISC-V platforms.
+func shuffleBytesSSE2(dst, src []byte, typeSize int) bool {
+	return false
+}
+
+// unshuffleBytesSSE2 is not available on RISC-V platforms.
+func unshuffleBytesSSE2(dst, src []byte, typeSize int) bool {
+	return false
+}
+
+// shuffleBytesAVX512 is not available on RISC-V platforms.
+func shuffleBytesAVX512(dst, src []byte, typeSize int) bool {
+	return false
+}
+
+// unshuffleBytesAVX512 is not available on RISC-V platforms.
+func unshuffleBytesAVX512(dst, src []byte, typeSize int) bool {
+	return false
+}
+
+// bitTransposeAVX512 is not available on RISC-V platforms.
+func bitTransposeAVX512(dst []byte, rowStride int, src []byte) bool {
+	return false
+}
+
+// bitUntransposeAVX512 is not available on RISC-V platforms.
+func bitUntransposeAVX512(dst, src []byte, rowStride int) bool {
+	return false
+}
+
+// bitTransposeAVX2 is not available on RISC-V platforms.
+func bitTransposeAVX2(dst []byte, rowStride int, src []byte) bool {
+	return false
+}
+
+// bitUntransposeAVX2 is not available on RISC-V platforms.
+func bitUntransposeAVX2(dst, src []byte, rowStride int) bool {
+	return false
+}
+
+// shuffleBytesNEON is not available on RISC-V platforms.
+func shuffleBytesNEON(dst, src []byte, typeSize int) bool {
+	return false
+}
+
+// unshuffleBytesNEON is not available on RISC-V platforms.
+func unshuffleBytesNEON(dst, src []byte, typeSize int) bool {
+	return false
+}
+
+// shuffleBytesSIMD128 is not available on RISC-V platforms.
+func shuffleBytesSIMD128(dst, src []byte, typeSize int) bool {
+	return false
+}
+
+// unshuffleBytesSIMD128 is not available on RISC-V platforms.
+func unshuffleBytesSIMD128(dst, src []byte, typeSize int) bool {
+	return false
+}
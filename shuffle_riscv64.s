@@ -0,0 +1,291 @@
+//go:build riscv64 && go1.25
+
+#include "textflag.h"
+
+// Both kernels work with vl elements at a time, vl being as many as fit a
+// vector register of bytes. A segment load (VLSEGnE8V) splits vl elements of
+// n bytes into n registers holding byte 0, byte 1, ... of each, which are
+// the next vl bytes of the n streams; a segment store (VSSEGnE8V) does the
+// reverse. Strip mining with VSETVLI covers the last, shorter group too.
+//
+// Registers:
+//   X10: interleaved elements (src of shuffle, dst of unshuffle)
+//   X11: stream 0 (dst of shuffle, src of unshuffle)
+//   X13: numElements, the stride between streams
+//   X14: typeSize
+//   X16: elements left
+//   X17: vl
+//   V8 to V15: one register per stream
+
+// func hasRVV() bool
+TEXT ·hasRVV(SB), NOSPLIT, $16-1
+#ifdef GORISCV64_rva23u64
+	MOV	$1, A0
+	MOVB	A0, ret+0(FP)
+	RET
+#else
+#ifdef GOOS_linux
+	// riscv_hwprobe(pairs, 1, 0, NULL, 0) with one key-value pair on the
+	// stack, for RISCV_HWPROBE_KEY_IMA_EXT_0 (4)
+	MOV	$4, A0
+	MOV	A0, 8(X2)
+	MOV	ZERO, 16(X2)
+	MOV	$8(X2), A0
+	MOV	$1, A1
+	MOV	ZERO, A2
+	MOV	ZERO, A3
+	MOV	ZERO, A4
+	MOV	$258, A7	// SYS_riscv_hwprobe
+	ECALL
+	BNEZ	A0, novector	// Linux before 6.4
+	MOV	8(X2), A0
+	MOV	$-1, A1
+	BEQ	A0, A1, novector	// Key not known to the kernel
+	MOV	16(X2), A0
+	AND	$4, A0	// RISCV_HWPROBE_IMA_V
+	SNEZ	A0, A0
+	MOVB	A0, ret+0(FP)
+	RET
+novector:
+#endif
+	MOVB	ZERO, ret+0(FP)
+	RET
+#endif
+
+// STORE_STREAM writes register V to the stream at X15 and moves X15 to the
+// next stream
+#define STORE_STREAM(V) \
+	VSE8V	V, (X15); \
+	ADD	X13, X15
+
+// LOAD_STREAM reads register V from the stream at X15 and moves X15 to the
+// next stream
+#define LOAD_STREAM(V) \
+	VLE8V	(X15), V; \
+	ADD	X13, X15
+
+// NEXT moves past the vl elements just done, and repeats from label while
+// elements are left
+#define NEXT(label) \
+	ADD	X17, X11; \
+	MUL	X17, X14, X5; \
+	ADD	X5, X10; \
+	SUB	X17, X16; \
+	BNEZ	X16, label; \
+	JMP	done
+
+// func shuffleBytesRVV(dst, src []byte, typeSize int) bool
+TEXT ·shuffleBytesRVV(SB), NOSPLIT, $0-57
+	MOV	dst_base+0(FP), X11
+	MOV	src_base+24(FP), X10
+	MOV	src_len+32(FP), X12
+	MOV	typeSize+48(FP), X14
+	DIVU	X14, X12, X13
+	MOV	X13, X16
+
+	MOV	$2, X5
+	BEQ	X14, X5, shuffle2
+	MOV	$3, X5
+	BEQ	X14, X5, shuffle3
+	MOV	$4, X5
+	BEQ	X14, X5, shuffle4
+	MOV	$5, X5
+	BEQ	X14, X5, shuffle5
+	MOV	$6, X5
+	BEQ	X14, X5, shuffle6
+	MOV	$7, X5
+	BEQ	X14, X5, shuffle7
+	MOV	$8, X5
+	BEQ	X14, X5, shuffle8
+	MOVB	ZERO, ret+56(FP)
+	RET
+
+shuffle2:
+	VSETVLI	X16, E8, M1, TA, MA, X17
+	VLSEG2E8V	(X10), V8
+	MOV	X11, X15
+	STORE_STREAM(V8)
+	STORE_STREAM(V9)
+	NEXT(shuffle2)
+
+shuffle3:
+	VSETVLI	X16, E8, M1, TA, MA, X17
+	VLSEG3E8V	(X10), V8
+	MOV	X11, X15
+	STORE_STREAM(V8)
+	STORE_STREAM(V9)
+	STORE_STREAM(V10)
+	NEXT(shuffle3)
+
+shuffle4:
+	VSETVLI	X16, E8, M1, TA, MA, X17
+	VLSEG4E8V	(X10), V8
+	MOV	X11, X15
+	STORE_STREAM(V8)
+	STORE_STREAM(V9)
+	STORE_STREAM(V10)
+	STORE_STREAM(V11)
+	NEXT(shuffle4)
+
+shuffle5:
+	VSETVLI	X16, E8, M1, TA, MA, X17
+	VLSEG5E8V	(X10), V8
+	MOV	X11, X15
+	STORE_STREAM(V8)
+	STORE_STREAM(V9)
+	STORE_STREAM(V10)
+	STORE_STREAM(V11)
+	STORE_STREAM(V12)
+	NEXT(shuffle5)
+
+shuffle6:
+	VSETVLI	X16, E8, M1, TA, MA, X17
+	VLSEG6E8V	(X10), V8
+	MOV	X11, X15
+	STORE_STREAM(V8)
+	STORE_STREAM(V9)
+	STORE_STREAM(V10)
+	STORE_STREAM(V11)
+	STORE_STREAM(V12)
+	STORE_STREAM(V13)
+	NEXT(shuffle6)
+
+shuffle7:
+	VSETVLI	X16, E8, M1, TA, MA, X17
+	VLSEG7E8V	(X10), V8
+	MOV	X11, X15
+	STORE_STREAM(V8)
+	STORE_STREAM(V9)
+	STORE_STREAM(V10)
+	STORE_STREAM(V11)
+	STORE_STREAM(V12)
+	STORE_STREAM(V13)
+	STORE_STREAM(V14)
+	NEXT(shuffle7)
+
+shuffle8:
+	VSETVLI	X16, E8, M1, TA, MA, X17
+	VLSEG8E8V	(X10), V8
+	MOV	X11, X15
+	STORE_STREAM(V8)
+	STORE_STREAM(V9)
+	STORE_STREAM(V10)
+	STORE_STREAM(V11)
+	STORE_STREAM(V12)
+	STORE_STREAM(V13)
+	STORE_STREAM(V14)
+	STORE_STREAM(V15)
+	NEXT(shuffle8)
+
+done:
+	MOV	$1, X5
+	MOVB	X5, ret+56(FP)
+	RET
+
+// func unshuffleBytesRVV(dst, src []byte, typeSize int) bool
+TEXT ·unshuffleBytesRVV(SB), NOSPLIT, $0-57
+	MOV	dst_base+0(FP), X10
+	MOV	src_base+24(FP), X11
+	MOV	src_len+32(FP), X12
+	MOV	typeSize+48(FP), X14
+	DIVU	X14, X12, X13
+	MOV	X13, X16
+
+	MOV	$2, X5
+	BEQ	X14, X5, unshuffle2
+	MOV	$3, X5
+	BEQ	X14, X5, unshuffle3
+	MOV	$4, X5
+	BEQ	X14, X5, unshuffle4
+	MOV	$5, X5
+	BEQ	X14, X5, unshuffle5
+	MOV	$6, X5
+	BEQ	X14, X5, unshuffle6
+	MOV	$7, X5
+	BEQ	X14, X5, unshuffle7
+	MOV	$8, X5
+	BEQ	X14, X5, unshuffle8
+	MOVB	ZERO, ret+56(FP)
+	RET
+
+unshuffle2:
+	VSETVLI	X16, E8, M1, TA, MA, X17
+	MOV	X11, X15
+	LOAD_STREAM(V8)
+	LOAD_STREAM(V9)
+	VSSEG2E8V	V8, (X10)
+	NEXT(unshuffle2)
+
+unshuffle3:
+	VSETVLI	X16, E8, M1, TA, MA, X17
+	MOV	X11, X15
+	LOAD_STREAM(V8)
+	LOAD_STREAM(V9)
+	LOAD_STREAM(V10)
+	VSSEG3E8V	V8, (X10)
+	NEXT(unshuffle3)
+
+unshuffle4:
+	VSETVLI	X16, E8, M1, TA, MA, X17
+	MOV	X11, X15
+	LOAD_STREAM(V8)
+	LOAD_STREAM(V9)
+	LOAD_STREAM(V10)
+	LOAD_STREAM(V11)
+	VSSEG4E8V	V8, (X10)
+	NEXT(unshuffle4)
+
+unshuffle5:
+	VSETVLI	X16, E8, M1, TA, MA, X17
+	MOV	X11, X15
+	LOAD_STREAM(V8)
+	LOAD_STREAM(V9)
+	LOAD_STREAM(V10)
+	LOAD_STREAM(V11)
+	LOAD_STREAM(V12)
+	VSSEG5E8V	V8, (X10)
+	NEXT(unshuffle5)
+
+unshuffle6:
+	VSETVLI	X16, E8, M1, TA, MA, X17
+	MOV	X11, X15
+	LOAD_STREAM(V8)
+	LOAD_STREAM(V9)
+	LOAD_STREAM(V10)
+	LOAD_STREAM(V11)
+	LOAD_STREAM(V12)
+	LOAD_STREAM(V13)
+	VSSEG6E8V	V8, (X10)
+	NEXT(unshuffle6)
+
+unshuffle7:
+	VSETVLI	X16, E8, M1, TA, MA, X17
+	MOV	X11, X15
+	LOAD_STREAM(V8)
+	LOAD_STREAM(V9)
+	LOAD_STREAM(V10)
+	LOAD_STREAM(V11)
+	LOAD_STREAM(V12)
+	LOAD_STREAM(V13)
+	LOAD_STREAM(V14)
+	VSSEG7E8V	V8, (X10)
+	NEXT(unshuffle7)
+
+unshuffle8:
+	VSETVLI	X16, E8, M1, TA, MA, X17
+	MOV	X11, X15
+	LOAD_STREAM(V8)
+	LOAD_STREAM(V9)
+	LOAD_STREAM(V10)
+	LOAD_STREAM(V11)
+	LOAD_STREAM(V12)
+	LOAD_STREAM(V13)
+	LOAD_STREAM(V14)
+	LOAD_STREAM(V15)
+	VSSEG8E8V	V8, (X10)
+	NEXT(unshuffle8)
+
+done:
+	MOV	$1, X5
+	MOVB	X5, ret+56(FP)
+	RET
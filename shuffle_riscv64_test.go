@@ -0,0 +1,98 @@
+//go:build riscv64 && go1.25 && !cgo_blosc
+
+package blosc
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestShuffleBytesRVVDirect(t *testing.T) {
+	if !useRVV {
+		t.Skip("CPU lacks the V extension")
+	}
+	for typeSize := 1; typeSize <= 9; typeSize++ {
+		for _, n := range []int{0, 1, 7, 64, 1000, 4099, 65536, 100003} {
+			src := makeTestData(n * typeSize)
+			dst := make([]byte, len(src))
+			expect := typeSize >= 2 && typeSize <= 8
+			if used := shuffleBytesRVV(dst, src, typeSize); used != expect {
+				t.Fatalf("typeSize %d: shuffleBytesRVV returned %v, expected %v", typeSize, used, expect)
+			}
+			if !expect {
+				continue
+			}
+			// Every element is shuffled, no tail is left to the caller
+			want := shuffleBytesGeneric(src, typeSize)
+			if !bytes.Equal(dst, want) {
+				t.Fatalf("typeSize %d, %d elements: differs from the generic shuffle", typeSize, n)
+			}
+			unshuffled := make([]byte, len(src))
+			if !unshuffleBytesRVV(unshuffled, want, typeSize) {
+				t.Fatal("unshuffleBytesRVV returned false")
+			}
+			if !bytes.Equal(unshuffled, src) {
+				t.Fatalf("typeSize %d, %d elements: unshuffle differs from the original", typeSize, n)
+			}
+		}
+	}
+}
+
+func TestShuffleRoundTripRVV(t *testing.T) {
+	for typeSize := 2; typeSize <= 8; typeSize++ {
+		for _, n := range []int{16, 100, 1000, 65536, 100003} {
+			original := makeTestData(n)
+			shuffled := shuffleBytes(original, typeSize)
+			if !bytes.Equal(shuffled, shuffleBytesGeneric(original, typeSize)) {
+				t.Errorf("typeSize %d, %d bytes: shuffle differs from the generic shuffle", typeSize, n)
+			}
+			if !bytes.Equal(unshuffleBytes(shuffled, typeSize), original) {
+				t.Errorf("typeSize %d, %d bytes: round trip failed", typeSize, n)
+			}
+		}
+	}
+}
+
+// shuffleBytesGeneric is a copy of the generic implementation for testing
+func shuffleBytesGeneric(src []byte, typeSize int) []byte {
+	if typeSize <= 1 || len(src) < typeSize {
+		return src
+	}
+
+	n := len(src)
+	numElements := n / typeSize
+	dst := make([]byte, n)
+
+	for i := 0; i < numElements; i++ {
+		for j := 0; j < typeSize; j++ {
+			dst[j*numElements+i] = src[i*typeSize+j]
+		}
+	}
+
+	remainder := n % typeSize
+	if remainder > 0 {
+		copy(dst[numElements*typeSize:], src[numElements*typeSize:])
+	}
+
+	return dst
+}
+
+func BenchmarkShuffleRVV(b *testing.B) {
+	data := makeTestData(100000)
+	b.ResetTimer()
+	b.SetBytes(int64(len(data)))
+
+	for i := 0; i < b.N; i++ {
+		_ = shuffleBytes(data, 4)
+	}
+}
+
+func BenchmarkUnshuffleRVV(b *testing.B) {
+	shuffled := shuffleBytes(makeTestData(100000), 4)
+	b.ResetTimer()
+	b.SetBytes(int64(len(shuffled)))
+
+	for i := 0; i < b.N; i++ {
+		_ = unshuffleBytes(shuffled, 4)
+	}
+}
@@ -5,6 +5,7 @@ package blosc
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"math"
 	"testing"
@@ -110,6 +111,266 @@ func TestShuffleBufferInPlace(t *testing.T) {
 	}
 }
 
+func TestShuffleBufferMatchesOutOfPlace(t *testing.T) {
+	for _, typeSize := range []int{2, 3, 4, 7, 8, 16} {
+		for _, size := range []int{0, 5, 64, 100, 1000, 5003, 20000} {
+			original := makeTestData(size)
+
+			for _, mode := range []Shuffle{Shuffle1, BitShuffle} {
+				var expected []byte
+				if mode == Shuffle1 {
+					expected = shuffleBytes(original, typeSize)
+				} else {
+					expected = bitShuffle(original, typeSize)
+				}
+
+				data := make([]byte, size)
+				copy(data, original)
+				ShuffleBuffer(data, typeSize, mode)
+				if !bytes.Equal(data, expected) {
+					t.Errorf("%s typeSize=%d size=%d: in-place result differs", mode, typeSize, size)
+				}
+
+				UnshuffleBuffer(data, typeSize, mode)
+				if !bytes.Equal(data, original) {
+					t.Errorf("%s typeSize=%d size=%d: in-place round-trip failed", mode, typeSize, size)
+				}
+			}
+		}
+	}
+}
+
+func TestShuffleToUnshuffleTo(t *testing.T) {
+	original := makeTestData(1003)
+
+	for _, mode := range []Shuffle{NoShuffle, Shuffle1, BitShuffle} {
+		shuffled := make([]byte, len(original))
+		if err := ShuffleTo(shuffled, original, 4, mode); err != nil {
+			t.Fatalf("%s: ShuffleTo failed: %v", mode, err)
+		}
+
+		// Larger destination buffers are allowed; only the prefix is written
+		restored := make([]byte, len(original)+16)
+		if err := UnshuffleTo(restored, shuffled, 4, mode); err != nil {
+			t.Fatalf("%s: UnshuffleTo failed: %v", mode, err)
+		}
+		if !bytes.Equal(restored[:len(original)], original) {
+			t.Errorf("%s: round-trip mismatch", mode)
+		}
+	}
+
+	if err := ShuffleTo(make([]byte, 10), original, 4, Shuffle1); !errors.Is(err, ErrBufferTooSmall) {
+		t.Errorf("expected ErrBufferTooSmall, got %v", err)
+	}
+	if err := UnshuffleTo(make([]byte, 10), original, 4, Shuffle1); !errors.Is(err, ErrBufferTooSmall) {
+		t.Errorf("expected ErrBufferTooSmall, got %v", err)
+	}
+	if err := ShuffleTo(make([]byte, len(original)), original, 4, Shuffle(99)); !errors.Is(err, ErrInvalidShuffle) {
+		t.Errorf("expected ErrInvalidShuffle, got %v", err)
+	}
+}
+
+func TestShuffleToNoAllocations(t *testing.T) {
+	// Elements of 4096 bytes are too large for the bit shuffle's stack tile
+	for _, typeSize := range []int{8, 4096} {
+		src := makeTestData(16 * typeSize)
+		dst := make([]byte, len(src))
+		for _, mode := range []Shuffle{Shuffle1, BitShuffle} {
+			allocs := testing.AllocsPerRun(10, func() {
+				_ = ShuffleTo(dst, src, typeSize, mode)
+				_ = UnshuffleTo(dst, src, typeSize, mode)
+			})
+			if allocs != 0 {
+				t.Errorf("%s, typeSize %d: expected no allocations, got %v", mode, typeSize, allocs)
+			}
+		}
+	}
+}
+
+// referenceBitShuffle is a direct port of the scalar bitshuffle used by
+// c-blosc (bshuf_trans_bit_elem_scal): a byte transpose, an 8x8 bit transpose
+// of every 8 bytes scattered into bit rows, and a final transpose of the
+// 8 x typeSize grid of bit rows. Leftovers are handled as in c-blosc.
+func referenceBitShuffle(src []byte, typeSize int) []byte {
+	out := make([]byte, len(src))
+	copy(out, src)
+	size := len(src) / typeSize
+	if size%8 != 0 {
+		return out
+	}
+	nbyte := size * typeSize
+
+	// bshuf_trans_byte_elem_scal
+	tmp := make([]byte, nbyte)
+	for i := 0; i < size; i++ {
+		for j := 0; j < typeSize; j++ {
+			tmp[j*size+i] = src[i*typeSize+j]
+		}
+	}
+
+	// bshuf_trans_bit_byte_scal
+	tmp2 := make([]byte, nbyte)
+	bitRow := nbyte / 8
+	for ii := 0; ii < bitRow; ii++ {
+		x := binary.LittleEndian.Uint64(tmp[ii*8:])
+		t := (x ^ (x >> 7)) & 0x00AA00AA00AA00AA
+		x = x ^ t ^ (t << 7)
+		t = (x ^ (x >> 14)) & 0x0000CCCC0000CCCC
+		x = x ^ t ^ (t << 14)
+		t = (x ^ (x >> 28)) & 0x00000000F0F0F0F0
+		x = x ^ t ^ (t << 28)
+		for kk := 0; kk < 8; kk++ {
+			tmp2[kk*bitRow+ii] = byte(x)
+			x >>= 8
+		}
+	}
+
+	// bshuf_trans_bitrow_eight: transpose 8 x typeSize blocks of size/8 bytes
+	blk := size / 8
+	for ii := 0; ii < 8; ii++ {
+		for jj := 0; jj < typeSize; jj++ {
+			copy(out[(jj*8+ii)*blk:(jj*8+ii+1)*blk], tmp2[(ii*typeSize+jj)*blk:(ii*typeSize+jj+1)*blk])
+		}
+	}
+	return out
+}
+
+func TestBitShuffleMatchesReference(t *testing.T) {
+	for _, typeSize := range []int{1, 2, 3, 4, 8, 12, 16} {
+		for _, numElements := range []int{8, 16, 64, 1000, 1024, 3000, 2001} {
+			for _, extra := range []int{0, 1} {
+				if extra >= typeSize {
+					continue
+				}
+				src := makeTestData(numElements*typeSize + extra)
+				expected := referenceBitShuffle(src, typeSize)
+
+				got := bitShuffle(src, typeSize)
+				if !bytes.Equal(got, expected) {
+					t.Errorf("typeSize=%d elements=%d extra=%d: layout differs from reference", typeSize, numElements, extra)
+					continue
+				}
+				if !bytes.Equal(bitUnshuffle(got, typeSize), src) {
+					t.Errorf("typeSize=%d elements=%d extra=%d: round-trip failed", typeSize, numElements, extra)
+				}
+			}
+		}
+	}
+}
+
+// TestBitShuffleWideMatchesReference checks elements too large to tile
+func TestBitShuffleWideMatchesReference(t *testing.T) {
+	for _, typeSize := range []int{2048, 2049, 4096} {
+		for _, extra := range []int{0, 5} {
+			src := makeTestDataPure(24*typeSize + extra)
+			got := bitShuffle(src, typeSize)
+			if !bytes.Equal(got, referenceBitShuffle(src, typeSize)) {
+				t.Errorf("typeSize=%d extra=%d: layout differs from reference", typeSize, extra)
+				continue
+			}
+			if !bytes.Equal(bitUnshuffle(got, typeSize), src) {
+				t.Errorf("typeSize=%d extra=%d: round-trip failed", typeSize, extra)
+			}
+		}
+	}
+}
+
+// TestShuffleGenericMatchesNaive checks the tiled byte shuffle against the
+// element-by-element loop it replaces, around tile and group boundaries
+func TestShuffleGenericMatchesNaive(t *testing.T) {
+	defer func(off bool) { simdOff.Store(off) }(simdOff.Load())
+	DisableSIMD()
+	for typeSize := 2; typeSize <= 20; typeSize++ {
+		tile := max(shuffleTileBytes/typeSize&^7, 8)
+		for _, numElements := range []int{1, 7, 8, 9, 63, tile, tile + 1, 3*tile + 13} {
+			for _, extra := range []int{0, typeSize - 1} {
+				src := makeTestDataPure(numElements*typeSize + extra)
+				want := make([]byte, len(src))
+				for i := 0; i < numElements; i++ {
+					for j := 0; j < typeSize; j++ {
+						want[j*numElements+i] = src[i*typeSize+j]
+					}
+				}
+				copy(want[numElements*typeSize:], src[numElements*typeSize:])
+
+				got := shuffleBytes(src, typeSize)
+				if !bytes.Equal(got, want) {
+					t.Errorf("typeSize=%d elements=%d extra=%d: shuffle differs from the naive loop", typeSize, numElements, extra)
+					continue
+				}
+				if !bytes.Equal(unshuffleBytes(got, typeSize), src) {
+					t.Errorf("typeSize=%d elements=%d extra=%d: round-trip failed", typeSize, numElements, extra)
+				}
+			}
+		}
+	}
+}
+
+// TestBitShuffleGenericMatchesReference checks the portable bit transpose,
+// which SIMD kernels otherwise take over on the CPUs that have them
+func TestBitShuffleGenericMatchesReference(t *testing.T) {
+	defer func(off bool) { simdOff.Store(off) }(simdOff.Load())
+	DisableSIMD()
+	for _, typeSize := range []int{1, 2, 4, 8, 12} {
+		for _, numElements := range []int{8, 64, 512, 520, 4096 + 72} {
+			src := makeTestDataPure(numElements * typeSize)
+			got := bitShuffle(src, typeSize)
+			if !bytes.Equal(got, referenceBitShuffle(src, typeSize)) {
+				t.Errorf("typeSize=%d elements=%d: layout differs from reference", typeSize, numElements)
+				continue
+			}
+			if !bytes.Equal(bitUnshuffle(got, typeSize), src) {
+				t.Errorf("typeSize=%d elements=%d: round-trip failed", typeSize, numElements)
+			}
+		}
+	}
+}
+
+func TestTransposeBytes8x8(t *testing.T) {
+	var w, want [8]uint64
+	for i := range w {
+		for j := 0; j < 8; j++ {
+			w[i] |= uint64(i*8+j) << (8 * j)
+			want[i] |= uint64(j*8+i) << (8 * j)
+		}
+	}
+	orig := w
+	transposeBytes8x8(&w)
+	if w != want {
+		t.Errorf("got %x, want %x", w, want)
+	}
+	transposeBytes8x8(&w)
+	if w != orig {
+		t.Error("transposing twice did not restore the matrix")
+	}
+}
+
+func TestBitShuffleKnownLayout(t *testing.T) {
+	// Eight uint16 elements where only element 0 has bit 0 of its low byte set
+	// and only element 7 has bit 7 of its high byte set.
+	src := make([]byte, 16)
+	src[0] = 0x01
+	src[15] = 0x80
+
+	got := bitShuffle(src, 2)
+
+	// Rows are ordered (byte 0, bit 0..7), (byte 1, bit 0..7), one byte each
+	expected := make([]byte, 16)
+	expected[0] = 0x01  // byte 0, bit 0: element 0
+	expected[15] = 0x80 // byte 1, bit 7: element 7
+	if !bytes.Equal(got, expected) {
+		t.Errorf("got %x, want %x", got, expected)
+	}
+}
+
+func TestBitShuffleNonMultipleOf8Copies(t *testing.T) {
+	// c-blosc leaves blocks whose element count isn't a multiple of 8 unchanged
+	src := makeTestData(7 * 4)
+	if got := bitShuffle(src, 4); !bytes.Equal(got, src) {
+		t.Error("expected data with 7 elements to be copied unchanged")
+	}
+}
+
 func TestShuffleNoOp(t *testing.T) {
 	data := makeTestData(100)
 	original := make([]byte, len(data))
@@ -0,0 +1,111 @@
+//go:build wasm && go1.27 && !blosc_nosimd128
+
+package blosc
+
+// useAVX2 is always false on WebAssembly.
+var useAVX2 = false
+
+// useAVX512 is always false on WebAssembly.
+var useAVX512 = false
+
+// useSSE2 is always false on WebAssembly.
+var useSSE2 = false
+
+// useNEON is always false on WebAssembly.
+var useNEON = false
+
+// useSIMD128 indicates whether the WebAssembly SIMD128 kernels are built in.
+// Every current WebAssembly engine supports SIMD128, so they always are
+// unless the blosc_nosimd128 tag leaves them out.
+var useSIMD128 = true
+
+// useRVV is always false on WebAssembly.
+var useRVV = false
+
+// initSIMD is a no-op on WebAssembly, which cannot probe for SIMD128: an
+// engine without it rejects the whole module.
+func initSIMD() {}
+
+// shuffleBytesSIMD128 shuffles bytes using WebAssembly SIMD128 instructions.
+// For typeSize=4, processes 16 bytes at a time (4 elements).
+// Falls back by returning false if data is too small for SIMD processing.
+//
+//go:noescape
+func shuffleBytesSIMD128(dst, src []byte, typeSize int) bool
+
+// unshuffleBytesSIMD128 unshuffles bytes using WebAssembly SIMD128
+// instructions. For typeSize=4, processes 16 bytes at a time (4 elements).
+// Falls back by returning false if data is too small for SIMD processing.
+//
+//go:noescape
+func unshuffleBytesSIMD128(dst, src []byte, typeSize int) bool
+
+// shuffleBytesAVX2 is not available on WebAssembly.
+func shuffleBytesAVX2(dst, src []byte, typeSize int) bool {
+	return false
+}
+
+// unshuffleBytesAVX2 is not available on WebAssembly.
+func unshuffleBytesAVX2(dst, src []byte, typeSize int) bool {
+	return false
+}
+
+// shuffleBytesNEON is not available on WebAssembly.
+func shuffleBytesNEON(dst, src []byte, typeSize int) bool {
+	return false
+}
+
+// unshuffleBytesNEON is not available on WebAssembly.
+func unshuffleBytesNEON(dst, src []byte, typeSize int) bool {
+	return false
+}
+
+// shuffleBytesSSE2 is not available on WebAssembly.
+func shuffleBytesSSE2(dst, src []byte, typeSize int) bool {
+	return false
+}
+
+// unshuffleBytesSSE2 is not available on WebAssembly.
+func unshuffleBytesSSE2(dst, src []byte, typeSize int) bool {
+	return false
+}
+
+// shuffleBytesAVX512 is not available on WebAssembly.
+func shuffleBytesAVX512(dst, src []byte, typeSize int) bool {
+	return false
+}
+
+// unshuffleBytesAVX512 is not available on WebAssembly.
+func unshuffleBytesAVX512(dst, src []byte, typeSize int) bool {
+	return false
+}
+
+// bitTransposeAVX512 is not available on WebAssembly.
+func bitTransposeAVX512(dst []byte, rowStride int, src []byte) bool {
+	return false
+}
+
+// bitUntransposeAVX512 is not available on WebAssembly.
+func bitUntransposeAVX512(dst, src []byte, rowStride int) bool {
+	return false
+}
+
+// bitTransposeAVX2 is not available on WebAssembly.
+func bitTransposeAVX2(dst []byte, rowStride int, src []byte) bool {
+	return false
+}
+
+// bitUntransposeAVX2 is not available on WebAssembly.
+func bitUntransposeAVX2(dst, src []byte, rowStride int) bool {
+	return false
+}
+
+// shuffleBytesRVV is not available on WebAssembly.
+func shuffleBytesRVV(dst, src []byte, typeSize int) bool {
+	return false
+}
+
+// unshuffleBytesRVV is not available on WebAssembly.
+func unshuffleBytesRVV(dst, src []byte, typeSize int) bool {
+	return false
+}
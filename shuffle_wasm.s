@@ -0,0 +1,236 @@
+//go:build wasm && go1.27 && !blosc_nosimd128
+
+#include "textflag.h"
+
+// Swizzle mask for typeSize=4, which transposes the 4x4 byte matrix of four
+// elements and is its own inverse:
+// Input:  [a0 a1 a2 a3 | b0 b1 b2 b3 | c0 c1 c2 c3 | d0 d1 d2 d3]
+// Output: [a0 b0 c0 d0 | a1 b1 c1 d1 | a2 b2 c2 d2 | a3 b3 c3 d3]
+// Low 8 bytes:  [00 04 08 0c 01 05 09 0d]
+// High 8 bytes: [02 06 0a 0e 03 07 0b 0f]
+//
+// Lane instructions repeat their lane index: the assembler reads a single
+// operand as a source, but encodes the lane from the destination.
+
+// func shuffleBytesSIMD128(dst, src []byte, typeSize int) bool
+//
+// Registers:
+//   R0: dst cursor in stream 0
+//   R1: src cursor
+//   R2: number of elements, the distance between streams
+//   R3: 2*R2
+//   R4: 3*R2
+//   R5: end of the last whole vector of src
+//   V1: swizzle mask
+TEXT ·shuffleBytesSIMD128(SB), NOSPLIT, $0-57
+	MOVD dst_base+0(FP), R0
+	MOVD src_base+24(FP), R1
+	MOVD src_len+32(FP), R2
+	MOVD typeSize+48(FP), R3
+
+	Block
+		// Fall back unless typeSize is 4 and src holds a whole vector
+		Get R3
+		I64Const $4
+		I64Ne
+		Get R2
+		I64Const $16
+		I64LtU
+		I32Or
+		If
+			Get SP
+			I32Const $0
+			I32Store8 ret+56(FP)
+			Br $1
+		End
+
+		Get R2
+		I64Const $2
+		I64ShrU
+		Set R2
+		Get R2
+		I64Const $1
+		I64Shl
+		Set R3
+		Get R3
+		Get R2
+		I64Add
+		Set R4
+		Get R2
+		I64Const $-4
+		I64And
+		I64Const $2
+		I64Shl
+		Get R1
+		I64Add
+		Set R5
+
+		V128Const $0x0d0905010c080400, $0x0f0b07030e0a0602
+		Set V1
+
+		Loop
+			Get R1
+			I32WrapI64
+			V128Load $0
+			Get V1
+			I8x16Swizzle
+			Set V0
+
+			Get R0
+			I32WrapI64
+			Get V0
+			I32x4ExtractLane $0, $0
+			I32Store $0
+
+			Get R0
+			Get R2
+			I64Add
+			I32WrapI64
+			Get V0
+			I32x4ExtractLane $1, $1
+			I32Store $0
+
+			Get R0
+			Get R3
+			I64Add
+			I32WrapI64
+			Get V0
+			I32x4ExtractLane $2, $2
+			I32Store $0
+
+			Get R0
+			Get R4
+			I64Add
+			I32WrapI64
+			Get V0
+			I32x4ExtractLane $3, $3
+			I32Store $0
+
+			Get R0
+			I64Const $4
+			I64Add
+			Set R0
+			Get R1
+			I64Const $16
+			I64Add
+			Tee R1
+			Get R5
+			I64LtU
+			BrIf $0
+		End
+
+		Get SP
+		I32Const $1
+		I32Store8 ret+56(FP)
+	End
+	RET
+
+// func unshuffleBytesSIMD128(dst, src []byte, typeSize int) bool
+//
+// Registers:
+//   R0: dst cursor
+//   R1: src cursor in stream 0
+//   R2: number of elements, the distance between streams
+//   R3: 2*R2
+//   R4: 3*R2
+//   R5: end of the last whole vector of dst
+//   V1: swizzle mask
+TEXT ·unshuffleBytesSIMD128(SB), NOSPLIT, $0-57
+	MOVD dst_base+0(FP), R0
+	MOVD src_base+24(FP), R1
+	MOVD src_len+32(FP), R2
+	MOVD typeSize+48(FP), R3
+
+	Block
+		// Fall back unless typeSize is 4 and src holds a whole vector
+		Get R3
+		I64Const $4
+		I64Ne
+		Get R2
+		I64Const $16
+		I64LtU
+		I32Or
+		If
+			Get SP
+			I32Const $0
+			I32Store8 ret+56(FP)
+			Br $1
+		End
+
+		Get R2
+		I64Const $2
+		I64ShrU
+		Set R2
+		Get R2
+		I64Const $1
+		I64Shl
+		Set R3
+		Get R3
+		Get R2
+		I64Add
+		Set R4
+		Get R2
+		I64Const $-4
+		I64And
+		I64Const $2
+		I64Shl
+		Get R0
+		I64Add
+		Set R5
+
+		V128Const $0x0d0905010c080400, $0x0f0b07030e0a0602
+		Set V1
+
+		Loop
+			// Gather 4 bytes from each stream, then transpose them
+			Get R0
+			I32WrapI64
+
+			Get R1
+			I32WrapI64
+			I32Load $0
+			I32x4Splat
+
+			Get R1
+			Get R2
+			I64Add
+			I32WrapI64
+			I32Load $0
+			I32x4ReplaceLane $1, $1
+
+			Get R1
+			Get R3
+			I64Add
+			I32WrapI64
+			I32Load $0
+			I32x4ReplaceLane $2, $2
+
+			Get R1
+			Get R4
+			I64Add
+			I32WrapI64
+			I32Load $0
+			I32x4ReplaceLane $3, $3
+
+			Get V1
+			I8x16Swizzle
+			V128Store $0
+
+			Get R1
+			I64Const $4
+			I64Add
+			Set R1
+			Get R0
+			I64Const $16
+			I64Add
+			Tee R0
+			Get R5
+			I64LtU
+			BrIf $0
+		End
+
+		Get SP
+		I32Const $1
+		I32Store8 ret+56(FP)
+	End
+	RET
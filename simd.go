@@ -0,0 +1,99 @@
+package blosc
+
+import (
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// simdOff turns the SIMD shuffle kernels off, for DisableSIMD and the
+// bloscsimd GODEBUG setting
+var simdOff atomic.Bool
+
+func init() {
+	if !godebugSIMD(os.Getenv("GODEBUG")) {
+		simdOff.Store(true)
+	}
+}
+
+// godebugSIMD reports whether a GODEBUG value leaves SIMD on: the last
+// bloscsimd setting in it, 0 meaning off, wins
+func godebugSIMD(godebug string) bool {
+	on := true
+	for _, setting := range strings.Split(godebug, ",") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(setting), "bloscsimd="); ok {
+			on = value != "0"
+		}
+	}
+	return on
+}
+
+// SIMDFeatures reports the SIMD kernels the shuffle filters use.
+type SIMDFeatures struct {
+	AVX512  bool // AVX-512BW byte shuffle and bit transpose (x86-64)
+	AVX2    bool // AVX2 byte shuffle (x86-64)
+	SSE2    bool // SSE2 byte shuffle (x86-64)
+	NEON    bool // NEON byte shuffle (ARM64)
+	SIMD128 bool // SIMD128 byte shuffle (WebAssembly)
+	RVV     bool // Vector extension byte shuffle (RISC-V)
+
+	// Disabled is set when DisableSIMD or GODEBUG=bloscsimd=0 turned the
+	// kernels above off.
+	Disabled bool
+}
+
+// String lists the active kernels, such as "avx512,avx2,sse2", or returns
+// "none".
+func (f SIMDFeatures) String() string {
+	var names []string
+	for _, k := range []struct {
+		on   bool
+		name string
+	}{
+		{f.AVX512, "avx512"},
+		{f.AVX2, "avx2"},
+		{f.SSE2, "sse2"},
+		{f.NEON, "neon"},
+		{f.SIMD128, "simd128"},
+		{f.RVV, "rvv"},
+	} {
+		if k.on {
+			names = append(names, k.name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ",")
+}
+
+// SIMDInfo reports the SIMD kernels that shuffling currently uses: those the
+// CPU and build support, or none while SIMD is disabled.
+func SIMDInfo() SIMDFeatures {
+	if simdOff.Load() {
+		return SIMDFeatures{Disabled: true}
+	}
+	return SIMDFeatures{
+		AVX512:  useAVX512,
+		AVX2:    useAVX2,
+		SSE2:    useSSE2,
+		NEON:    useNEON,
+		SIMD128: useSIMD128,
+		RVV:     useRVV,
+	}
+}
+
+// DisableSIMD makes shuffling use the generic Go code only, for comparing it
+// with the SIMD kernels or working around a suspected kernel bug. Setting
+// GODEBUG=bloscsimd=0 in the environment disables SIMD from the start.
+// Output is the same either way. It is safe to call at any time, also while
+// other goroutines compress.
+func DisableSIMD() {
+	simdOff.Store(true)
+}
+
+// EnableSIMD undoes DisableSIMD and GODEBUG=bloscsimd=0, going back to the
+// kernels the CPU supports.
+func EnableSIMD() {
+	simdOff.Store(false)
+}
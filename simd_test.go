@@ -0,0 +1,64 @@
+package blosc
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDisableSIMD(t *testing.T) {
+	defer func(off bool) { simdOff.Store(off) }(simdOff.Load())
+	EnableSIMD()
+	enabled := SIMDInfo()
+	if enabled.Disabled {
+		t.Fatal("SIMDInfo reports SIMD disabled after EnableSIMD")
+	}
+	t.Logf("SIMD kernels: %s", enabled)
+
+	data := makeTestData(100003)
+	type output struct{ shuffled, bitShuffled []byte }
+	run := func() map[int]output {
+		out := map[int]output{}
+		for _, typeSize := range []int{2, 4, 8} {
+			out[typeSize] = output{shuffleBytes(data, typeSize), bitShuffle(data, typeSize)}
+		}
+		return out
+	}
+	vector := run()
+
+	DisableSIMD()
+	if info := SIMDInfo(); info != (SIMDFeatures{Disabled: true}) || info.String() != "none" {
+		t.Errorf("SIMDInfo after DisableSIMD: %+v (%s)", info, info)
+	}
+	for typeSize, scalar := range run() {
+		if !bytes.Equal(scalar.shuffled, vector[typeSize].shuffled) || !bytes.Equal(scalar.bitShuffled, vector[typeSize].bitShuffled) {
+			t.Errorf("typeSize %d: generic output differs from SIMD output", typeSize)
+		}
+		if !bytes.Equal(unshuffleBytes(scalar.shuffled, typeSize), data) || !bytes.Equal(bitUnshuffle(scalar.bitShuffled, typeSize), data) {
+			t.Errorf("typeSize %d: generic round trip failed", typeSize)
+		}
+	}
+
+	EnableSIMD()
+	if SIMDInfo() != enabled {
+		t.Errorf("SIMDInfo after EnableSIMD: %+v, expected %+v", SIMDInfo(), enabled)
+	}
+}
+
+func TestGodebugSIMD(t *testing.T) {
+	tests := []struct {
+		godebug string
+		want    bool
+	}{
+		{"", true},
+		{"bloscsimd=0", false},
+		{"bloscsimd=1", true},
+		{"gctrace=1,bloscsimd=0", false},
+		{"bloscsimd=0, bloscsimd=1", true},
+		{"xbloscsimd=0", true},
+	}
+	for _, tt := range tests {
+		if got := godebugSIMD(tt.godebug); got != tt.want {
+			t.Errorf("godebugSIMD(%q) = %v, expected %v", tt.godebug, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package blosc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Special identifies a Blosc2 special-value chunk. Such a chunk has no
+// blocks: the extended header alone says what it decompresses to, so an
+// all-zero buffer of any size takes 32 bytes.
+type Special uint8
+
+const (
+	SpecialNone   Special = 0 // Ordinary chunk
+	SpecialZero   Special = 1 // Every byte is zero
+	SpecialNaN    Special = 2 // Every element is a float32 or float64 NaN
+	SpecialValue  Special = 3 // Every element equals the value after the header
+	SpecialUninit Special = 4 // Content is undefined; decompressed as zeros
+)
+
+// NaN bit patterns of special NaN chunks, as c-blosc2 writes them
+const (
+	specialNaN32 = 0x7FC00000
+	specialNaN64 = 0x7FF8000000000000
+)
+
+// String returns the special value kind name.
+func (s Special) String() string {
+	switch s {
+	case SpecialNone:
+		return "none"
+	case SpecialZero:
+		return "zero"
+	case SpecialNaN:
+		return "nan"
+	case SpecialValue:
+		return "value"
+	case SpecialUninit:
+		return "uninit"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(s))
+	}
+}
+
+// Special returns the special value kind of the chunk, SpecialNone for
+// ordinary chunks.
+func (h *Header) Special() Special {
+	if !h.IsExtended() {
+		return SpecialNone
+	}
+	return Special(h.Blosc2Flags >> blosc2SpecialShift & blosc2SpecialMask)
+}
+
+// NewZeroChunk returns a chunk of n zero bytes, in elements of typeSize
+// bytes, that takes only a header.
+func NewZeroChunk(n, typeSize int) ([]byte, error) {
+	return specialChunk(SpecialZero, n, typeSize, nil)
+}
+
+// NewNaNChunk returns a chunk of n bytes of NaNs, in float32 or float64
+// elements as typeSize is 4 or 8, that takes only a header.
+func NewNaNChunk(n, typeSize int) ([]byte, error) {
+	if typeSize != 4 && typeSize != 8 {
+		return nil, fmt.Errorf("%w: NaN elements of %d bytes", ErrInvalidHeader, typeSize)
+	}
+	return specialChunk(SpecialNaN, n, typeSize, nil)
+}
+
+// NewValueChunk returns a chunk of n bytes holding value repeated, that
+// takes a header and one copy of value. TypeSize is len(value).
+func NewValueChunk(n int, value []byte) ([]byte, error) {
+	return specialChunk(SpecialValue, n, len(value), value)
+}
+
+// specialChunk builds a special-value chunk in the c-blosc2 layout: an
+// extended header, followed by the value for SpecialValue
+func specialChunk(kind Special, n, typeSize int, value []byte) ([]byte, error) {
+	if n <= 0 || typeSize < 1 || typeSize > math.MaxUint8 || n%typeSize != 0 {
+		return nil, fmt.Errorf("%w: %d bytes in elements of %d", ErrInvalidData, n, typeSize)
+	}
+	if err := checkBufferSize(int64(n)); err != nil {
+		return nil, err
+	}
+	header := Header{
+		Version:     Blosc2FormatVersion,
+		VersionLZ:   codecFormatVersion,
+		Flags:       flagExtended | formatBloscLZ<<flagCodecShift,
+		TypeSize:    uint8(typeSize),
+		NBytesOrig:  uint32(n),
+		BlockSize:   uint32(computeBlockSize(Options{TypeSize: typeSize, Level: 5}, n)),
+		NBytesComp:  uint32(ExtendedHeaderSize + len(value)),
+		Blosc2Flags: byte(kind) << blosc2SpecialShift,
+	}
+	return append(header.Bytes(), value...), nil
+}
+
+// detectSpecial returns the special-value chunk data can be stored as, if
+// every element of it is the same
+func detectSpecial(data []byte, opts Options) ([]byte, bool) {
+	typeSize := opts.TypeSize
+	if len(data) == 0 || len(data)%typeSize != 0 || typeSize > math.MaxUint8 {
+		return nil, false
+	}
+	// Every element equals the first when data equals itself shifted by one
+	if !bytes.Equal(data[typeSize:], data[:len(data)-typeSize]) {
+		return nil, false
+	}
+	return specialChunkFor(len(data), data[:typeSize])
+}
+
+// specialChunkFor returns the most compact special chunk of n bytes of value
+// repeated, if it is smaller than storing the bytes uncompressed
+func specialChunkFor(n int, value []byte) ([]byte, bool) {
+	typeSize := len(value)
+	if ExtendedHeaderSize+typeSize >= HeaderSize+n {
+		return nil, false
+	}
+	var chunk []byte
+	var err error
+	switch {
+	case bytes.Count(value, []byte{0}) == typeSize:
+		chunk, err = specialChunk(SpecialZero, n, typeSize, nil)
+	case typeSize == 4 && binary.LittleEndian.Uint32(value) == specialNaN32,
+		typeSize == 8 && binary.LittleEndian.Uint64(value) == specialNaN64:
+		chunk, err = specialChunk(SpecialNaN, n, typeSize, nil)
+	default:
+		chunk, err = specialChunk(SpecialValue, n, typeSize, value)
+	}
+	return chunk, err == nil
+}
+
+// specialValue returns the element a special chunk repeats
+func specialValue(header *Header, chunk []byte) ([]byte, error) {
+	typeSize := int(header.TypeSize)
+	if typeSize == 0 || int(header.NBytesOrig)%typeSize != 0 {
+		return nil, headerError(offsetTypeSize, fmt.Errorf("%w: %d bytes in elements of %d", ErrInvalidHeader, header.NBytesOrig, typeSize))
+	}
+	switch kind := header.Special(); kind {
+	case SpecialZero, SpecialUninit:
+		return make([]byte, typeSize), nil
+	case SpecialNaN:
+		switch typeSize {
+		case 4:
+			return binary.LittleEndian.AppendUint32(nil, specialNaN32), nil
+		case 8:
+			return binary.LittleEndian.AppendUint64(nil, specialNaN64), nil
+		}
+		return nil, headerError(offsetTypeSize, fmt.Errorf("%w: NaN elements of %d bytes", ErrInvalidHeader, typeSize))
+	case SpecialValue:
+		end := ExtendedHeaderSize + typeSize
+		if len(chunk) < end {
+			return nil, headerError(ExtendedHeaderSize, fmt.Errorf("%w: value of %d bytes truncated", ErrInvalidData, typeSize))
+		}
+		return chunk[ExtendedHeaderSize:end], nil
+	default:
+		return nil, headerError(offsetBlosc2Flags, fmt.Errorf("%w: special value kind %d", ErrInvalidHeader, kind))
+	}
+}
+
+// decodeSpecial decodes a special-value chunk like decodeBlocks: into dst,
+// or if dst is nil into a buffer of up to one block passed to emit
+func decodeSpecial(header *Header, chunk []byte, dst []byte, emit func([]byte) error) error {
+	value, err := specialValue(header, chunk)
+	if err != nil {
+		return err
+	}
+	if dst != nil {
+		fillPattern(dst, value)
+		return nil
+	}
+
+	// Emit whole elements, one block's worth at a time
+	nbytes := int(header.NBytesOrig)
+	step := max(int(header.BlockSize), len(value))
+	step -= step % len(value)
+	buf := make([]byte, min(step, nbytes))
+	fillPattern(buf, value)
+	for done := 0; done < nbytes; done += len(buf) {
+		if err := emit(buf[:min(len(buf), nbytes-done)]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fillPattern fills b with repeats of pattern
+func fillPattern(b, pattern []byte) {
+	if len(b) == 0 {
+		return
+	}
+	n := copy(b, pattern)
+	for n < len(b) {
+		n += copy(b[n:], b[:n])
+	}
+}
@@ -0,0 +1,206 @@
+package blosc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+)
+
+// repeatBytes returns n bytes of value repeated
+func repeatBytes(value []byte, n int) []byte {
+	b := make([]byte, n)
+	fillPattern(b, value)
+	return b
+}
+
+func TestSpecialChunks(t *testing.T) {
+	// The quiet NaNs of C's nan(""), not Go's math.NaN
+	nan32 := binary.LittleEndian.AppendUint32(nil, specialNaN32)
+	nan64 := binary.LittleEndian.AppendUint64(nil, specialNaN64)
+	pi := binary.LittleEndian.AppendUint64(nil, math.Float64bits(math.Pi))
+	tests := []struct {
+		name  string
+		new   func() ([]byte, error)
+		kind  Special
+		size  int
+		value []byte
+	}{
+		{"zero", func() ([]byte, error) { return NewZeroChunk(1<<20, 4) }, SpecialZero, 32, []byte{0, 0, 0, 0}},
+		{"nan32", func() ([]byte, error) { return NewNaNChunk(4000, 4) }, SpecialNaN, 32, nan32},
+		{"nan64", func() ([]byte, error) { return NewNaNChunk(8000, 8) }, SpecialNaN, 32, nan64},
+		{"value", func() ([]byte, error) { return NewValueChunk(800000, pi) }, SpecialValue, 40, pi},
+	}
+	for _, tt := range tests {
+		chunk, err := tt.new()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		header, err := ParseHeader(chunk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(chunk) != tt.size || header.Special() != tt.kind {
+			t.Errorf("%s: %d bytes, kind %d", tt.name, len(chunk), header.Special())
+		}
+		if err := Validate(chunk); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+		want := repeatBytes(tt.value, int(header.NBytesOrig))
+
+		got, err := Decompress(chunk)
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("%s: Decompress: %v", tt.name, err)
+		}
+		got, err = DecompressAppend([]byte("x"), chunk)
+		if err != nil || !bytes.Equal(got[1:], want) {
+			t.Errorf("%s: DecompressAppend: %v", tt.name, err)
+		}
+		var w maxWriteRecorder
+		if _, err := DecompressTo(&w, chunk); err != nil || !bytes.Equal(w.Bytes(), want) {
+			t.Errorf("%s: DecompressTo: %v", tt.name, err)
+		}
+		if w.largest > max(int(header.BlockSize), len(tt.value)) {
+			t.Errorf("%s: DecompressTo wrote %d bytes at once", tt.name, w.largest)
+		}
+	}
+
+	for name, f := range map[string]func() ([]byte, error){
+		"partial element": func() ([]byte, error) { return NewZeroChunk(10, 4) },
+		"empty":           func() ([]byte, error) { return NewZeroChunk(0, 1) },
+		"nan typesize":    func() ([]byte, error) { return NewNaNChunk(10, 2) },
+		"no value":        func() ([]byte, error) { return NewValueChunk(10, nil) },
+	} {
+		if _, err := f(); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+// TestSpecialCBlosc2 decodes special chunks laid out as c-blosc2's
+// blosc2_chunk_zeros and blosc2_chunk_repeatval write them.
+func TestSpecialCBlosc2(t *testing.T) {
+	zeros := []byte{
+		5, 1, 0x05, 4, // version, versionlz, flags, typesize
+		0x00, 0x10, 0x00, 0x00, // nbytes 4096
+		0x00, 0x10, 0x00, 0x00, // blocksize 4096
+		32, 0, 0, 0, // cbytes
+		0, 0, 0, 0, 0, 1, 0, 0, // filters
+		0, 0, 0, 0, 0, 0, 0, 0x10, // filters meta, blosc2 flags
+	}
+	got, err := Decompress(zeros)
+	if err != nil || !bytes.Equal(got, make([]byte, 4096)) {
+		t.Errorf("zeros: %v", err)
+	}
+
+	value := append(bytes.Clone(zeros), 1, 2)
+	value[3], value[12], value[31] = 2, 34, 0x30
+	got, err = Decompress(value)
+	if err != nil || !bytes.Equal(got, repeatBytes([]byte{1, 2}, 4096)) {
+		t.Errorf("value: %v", err)
+	}
+
+	uninit := bytes.Clone(zeros)
+	uninit[31] = 0x40
+	if got, err := Decompress(uninit); err != nil || len(got) != 4096 {
+		t.Errorf("uninit: %v", err)
+	}
+
+	for name, corrupt := range map[string]func(c []byte) []byte{
+		"unknown kind":    func(c []byte) []byte { c[31] = 0x50; return c },
+		"partial element": func(c []byte) []byte { c[4] = 1; return c },
+		"nan typesize":    func(c []byte) []byte { c[3], c[31] = 2, 0x20; return c },
+		"value truncated": func(c []byte) []byte { c[31] = 0x30; return c },
+	} {
+		chunk := corrupt(bytes.Clone(zeros))
+		if _, err := Decompress(chunk); !errors.Is(err, ErrInvalidHeader) && !errors.Is(err, ErrInvalidData) {
+			t.Errorf("%s: Decompress got %v", name, err)
+		}
+		if err := Validate(chunk); err == nil {
+			t.Errorf("%s: Validate accepted the chunk", name)
+		}
+	}
+}
+
+func TestSpecialValuesOption(t *testing.T) {
+	oddNaN := binary.LittleEndian.AppendUint32(nil, 0x7FC00001)
+	canonicalNaN := binary.LittleEndian.AppendUint32(nil, specialNaN32)
+	tests := []struct {
+		name string
+		data []byte
+		kind Special
+	}{
+		{"zeros", make([]byte, 100000), SpecialZero},
+		{"nan", repeatBytes(canonicalNaN, 100000), SpecialNaN},
+		{"nan payload", repeatBytes(oddNaN, 100000), SpecialValue},
+		{"constant", repeatBytes([]byte{1, 2, 3, 4}, 100000), SpecialValue},
+		{"small", make([]byte, 40), SpecialZero},
+		{"tiny", make([]byte, 16), SpecialNone},
+		{"varying", makeFloatData(25000), SpecialNone},
+		{"partial element", make([]byte, 100001), SpecialNone},
+		{"late change", append(make([]byte, 99999), 1), SpecialNone},
+	}
+	for _, tt := range tests {
+		opts := Options{Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: 4, SpecialValues: true}
+		chunk, err := CompressWithOptions(tt.data, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		header, _ := ParseHeader(chunk)
+		if header.Special() != tt.kind {
+			t.Errorf("%s: kind %d, want %d", tt.name, header.Special(), tt.kind)
+		}
+		got, err := Decompress(chunk)
+		if err != nil || !bytes.Equal(got, tt.data) {
+			t.Errorf("%s: round trip: %v", tt.name, err)
+		}
+
+		// CompressFrom makes the same chunk, whatever the block size
+		for _, blockSize := range []int{0, 1000, 4096} {
+			opts.BlockSize = blockSize
+			want, _ := CompressWithOptions(tt.data, opts)
+			streamed, err := CompressFrom(bytes.NewReader(tt.data), int64(len(tt.data)), opts)
+			if err != nil || !bytes.Equal(streamed, want) {
+				t.Errorf("%s: CompressFrom with blocks of %d differs: %v", tt.name, blockSize, err)
+			}
+		}
+	}
+
+	// The option is off by default and ignored for legacy chunks
+	for _, opts := range []Options{
+		{Codec: LZ4, Level: 5, TypeSize: 4},
+		{Codec: LZ4, Level: 5, TypeSize: 4, SpecialValues: true, LegacyFormat: true},
+	} {
+		chunk, _ := CompressWithOptions(make([]byte, 100000), opts)
+		if header, _ := ParseHeader(chunk); header.Special() != SpecialNone || header.IsExtended() {
+			t.Errorf("%+v: wrote a special chunk", opts)
+		}
+	}
+
+	// SChunks store all-zero buffers in a header
+	sc := NewSChunk(Options{Codec: ZSTD, Level: 5, TypeSize: 8, SpecialValues: true})
+	for i := 0; i < 10; i++ {
+		if _, err := sc.AppendBuffer(make([]byte, 1<<20)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if sc.CBytes() != 10*ExtendedHeaderSize {
+		t.Errorf("10 MB of zeros took %d bytes", sc.CBytes())
+	}
+}
+
+func TestSpecialStrings(t *testing.T) {
+	for kind, name := range map[Special]string{
+		SpecialNone:   "none",
+		SpecialZero:   "zero",
+		SpecialNaN:    "nan",
+		SpecialValue:  "value",
+		SpecialUninit: "uninit",
+		9:             "unknown(9)",
+	} {
+		if kind.String() != name {
+			t.Errorf("special %d: got %q, want %q", kind, kind.String(), name)
+		}
+	}
+}
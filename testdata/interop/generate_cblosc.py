@@ -0,0 +1,66 @@
+#!/usr/bin/env python3
+"""Write c-blosc fixtures for the go-blosc interop tests.
+
+Requires python-blosc 1.x. See README.md in this directory.
+"""
+
+import os
+import struct
+import sys
+
+import blosc
+
+CODECS = ["blosclz", "lz4", "lz4hc", "snappy", "zlib", "zstd"]
+SHUFFLES = [
+    ("noshuffle", blosc.NOSHUFFLE),
+    ("shuffle", blosc.SHUFFLE),
+    ("bitshuffle", blosc.BITSHUFFLE),
+]
+TYPESIZES = [1, 2, 4, 8]
+
+
+def payload():
+    """Mirror of InteropPayload in interop.go."""
+    n = 1024
+    out = bytearray(4 * n + 3)
+    x = 1
+    for i in range(n):
+        x = (x * 1103515245 + 12345) & 0xFFFFFFFF
+        struct.pack_into("<I", out, 4 * i, i * 4 + ((x >> 16) & 3))
+    out[4 * n:] = b"\x01\x02\x03"
+    return bytes(out)
+
+
+def main():
+    outdir = os.path.join(os.path.dirname(os.path.abspath(__file__)), "c-blosc")
+    os.makedirs(outdir, exist_ok=True)
+    data = payload()
+    available = set(blosc.compressor_list())
+
+    variants = [("", None, None), ("-bs1024", 1024, None), ("-nosplit", None, "NEVER")]
+    for cname in CODECS:
+        if cname not in available:
+            print("skipping %s: not built into python-blosc" % cname, file=sys.stderr)
+            continue
+        for sname, shuffle in SHUFFLES:
+            for typesize in TYPESIZES:
+                for suffix, blocksize, splitmode in variants:
+                    blosc.set_blocksize(blocksize or 0)
+                    if splitmode:
+                        os.environ["BLOSC_SPLITMODE"] = splitmode
+                    try:
+                        chunk = blosc.compress(
+                            data, typesize=typesize, clevel=5, shuffle=shuffle, cname=cname
+                        )
+                    finally:
+                        os.environ.pop("BLOSC_SPLITMODE", None)
+                        blosc.set_blocksize(0)
+                    name = "%s-%s-ts%d%s.blosc" % (cname, sname, typesize, suffix)
+                    with open(os.path.join(outdir, name), "wb") as f:
+                        f.write(chunk)
+
+    print("c-blosc %s, python-blosc %s" % (blosc.blosclib_version, blosc.__version__))
+
+
+if __name__ == "__main__":
+    main()
@@ -0,0 +1,282 @@
+// Package testsupport generates Blosc chunks that exercise the edges of the
+// format, for projects that fuzz or regression-test their own handling of
+// go-blosc chunks: every codec, shuffle and header layout, split and
+// unsplit blocks, stored and special-value chunks, and truncated or
+// corrupted chunks that must fail to decompress.
+//
+// The chunks are generated with this version of go-blosc, so a corpus
+// written with WriteCorpus also pins down the format it writes. Codecs left
+// out of the build, as with the blosc_nozstd build tag, are left out of
+// the cases.
+package testsupport
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	blosc "github.com/mrjoshuak/go-blosc"
+)
+
+// Case is a generated chunk and what decompressing it gives
+type Case struct {
+	Name  string // Describes the chunk, such as "zstd/bitshuffle/ts4/bs512/split/checksums"
+	Chunk []byte
+
+	// Valid reports whether the chunk decompresses, to Data. Chunks that
+	// are not valid must fail to decompress, with any error.
+	Valid bool
+	Data  []byte
+}
+
+// codecs are the built-in codecs the cases use
+var codecs = []blosc.Codec{blosc.BloscLZ, blosc.LZ4, blosc.LZ4HC, blosc.Snappy, blosc.ZLIB, blosc.ZSTD}
+
+// layouts are the ways a chunk can be laid out, each applied to the options
+// the cases are compressed with
+var layouts = []struct {
+	name  string
+	apply func(*blosc.Options)
+}{
+	{"blosc1", func(*blosc.Options) {}},
+	{"legacy", func(o *blosc.Options) { o.LegacyFormat = true }},
+	{"checksums", func(o *blosc.Options) { o.BlockChecksums = true }},
+	{"stored", func(o *blosc.Options) { o.Level = 0 }},
+	{"filters", func(o *blosc.Options) {
+		o.Filters[0] = blosc.FilterStage{Filter: blosc.FilterByteDelta}
+		switch o.Shuffle {
+		case blosc.Shuffle1:
+			o.Filters[1] = blosc.FilterStage{Filter: blosc.FilterShuffle}
+		case blosc.BitShuffle:
+			o.Filters[1] = blosc.FilterStage{Filter: blosc.FilterBitShuffle}
+		}
+	}},
+}
+
+// Chunks returns chunks of data compressed with every built-in codec,
+// shuffle mode and header layout: Blosc1, go-blosc 1.0.x legacy, extended
+// headers with block checksums or a filter pipeline, and stored without
+// compression. Each comes in element sizes of 1, 4 and 8 bytes, with the
+// default block size (bs0 in case names) and with 512-byte blocks, so that
+// both split and unsplit blocks occur, as the case names tell. Empty data
+// fails.
+func Chunks(data []byte) ([]Case, error) {
+	if len(data) == 0 {
+		return nil, errors.New("testsupport: no data to compress")
+	}
+	var cases []Case
+	for _, codec := range codecs {
+		for _, shuffle := range []blosc.Shuffle{blosc.NoShuffle, blosc.Shuffle1, blosc.BitShuffle} {
+			for _, typeSize := range []int{1, 4, 8} {
+				for _, blockSize := range []int{0, 512} {
+					for _, layout := range layouts {
+						opts := blosc.Options{Codec: codec, Level: 5, Shuffle: shuffle, TypeSize: typeSize, BlockSize: blockSize}
+						layout.apply(&opts)
+						chunk, err := blosc.CompressWithOptions(data, opts)
+						if errors.Is(err, blosc.ErrInvalidCodec) {
+							continue // Built without the codec
+						}
+						if err != nil {
+							return nil, fmt.Errorf("testsupport: compressing with %s: %w", opts, err)
+						}
+						name := fmt.Sprintf("%s/%s/ts%d/bs%d/%s/%s", codec, shuffle, typeSize, blockSize, splitName(chunk), layout.name)
+						cases = append(cases, Case{Name: name, Chunk: chunk, Valid: true, Data: data})
+					}
+				}
+			}
+		}
+	}
+
+	// Data that does not compress is stored as is
+	chunk, err := blosc.CompressWithOptions(data, blosc.Options{Codec: blosc.LZ4, Level: 0, TypeSize: 1})
+	if err != nil {
+		return nil, fmt.Errorf("testsupport: storing data: %w", err)
+	}
+	cases = append(cases, Case{Name: "memcpy", Chunk: chunk, Valid: true, Data: data})
+	return cases, nil
+}
+
+// splitName tells whether the blocks of chunk are split into one stream per
+// byte of an element
+func splitName(chunk []byte) string {
+	if h, err := blosc.ParseHeader(chunk); err == nil && flagSet(h, "dontsplit") {
+		return "dontsplit"
+	}
+	return "split"
+}
+
+// flagSet reports whether header h sets the flag HeaderFlags names name
+func flagSet(h *blosc.Header, name string) bool {
+	header := h.Bytes()
+	for _, f := range blosc.HeaderFlags() {
+		if f.Name == name && f.Offset < len(header) {
+			return header[f.Offset]&f.Mask != 0
+		}
+	}
+	return false
+}
+
+// Special returns the special-value chunks of n bytes in elements of
+// typeSize bytes: zeros, a repeated value and, for 4- and 8-byte elements,
+// NaNs. These chunks hold no compressed data at all.
+func Special(n, typeSize int) ([]Case, error) {
+	zeros, err := blosc.NewZeroChunk(n, typeSize)
+	if err != nil {
+		return nil, fmt.Errorf("testsupport: %w", err)
+	}
+	cases := []Case{{Name: fmt.Sprintf("special/zero/ts%d", typeSize), Chunk: zeros, Valid: true, Data: make([]byte, n)}}
+
+	value := make([]byte, typeSize)
+	for i := range value {
+		value[i] = byte(0xA5 + i)
+	}
+	chunk, err := blosc.NewValueChunk(n, value)
+	if err != nil {
+		return nil, fmt.Errorf("testsupport: %w", err)
+	}
+	data := make([]byte, 0, n)
+	for len(data) < n {
+		data = append(data, value...)
+	}
+	cases = append(cases, Case{Name: fmt.Sprintf("special/value/ts%d", typeSize), Chunk: chunk, Valid: true, Data: data})
+
+	if typeSize == 4 || typeSize == 8 {
+		chunk, err := blosc.NewNaNChunk(n, typeSize)
+		if err != nil {
+			return nil, fmt.Errorf("testsupport: %w", err)
+		}
+		// The quiet NaNs c-blosc2 decodes these chunks to
+		nan := make([]byte, n)
+		for i := 0; i < n; i += typeSize {
+			if typeSize == 4 {
+				binary.LittleEndian.PutUint32(nan[i:], 0x7FC00000)
+			} else {
+				binary.LittleEndian.PutUint64(nan[i:], 0x7FF8000000000000)
+			}
+		}
+		cases = append(cases, Case{Name: fmt.Sprintf("special/nan/ts%d", typeSize), Chunk: chunk, Valid: true, Data: nan})
+	}
+	return cases, nil
+}
+
+// Corrupt returns chunks derived from the valid case c that must fail to
+// decompress: c cut short inside the header, by one byte and by half, with
+// each header flag go-blosc does not support set, and, for chunks with
+// block checksums, with a byte of the last block flipped.
+func Corrupt(c Case) []Case {
+	chunk := c.Chunk
+	var cases []Case
+	add := func(name string, chunk []byte) {
+		cases = append(cases, Case{Name: c.Name + "/" + name, Chunk: chunk})
+	}
+
+	h, err := blosc.ParseHeader(chunk)
+	if err != nil {
+		return nil
+	}
+	add("truncated-header", clone(chunk[:h.Size()-1]))
+	add("truncated-1", clone(chunk[:len(chunk)-1]))
+	if len(chunk)/2 >= blosc.HeaderSize {
+		add("truncated-half", clone(chunk[:len(chunk)/2]))
+	}
+
+	if !h.IsLegacy() {
+		for _, f := range blosc.HeaderFlags() {
+			if f.Supported || (f.Offset >= blosc.HeaderSize && !h.IsExtended()) {
+				continue
+			}
+			flagged := clone(chunk)
+			flagged[f.Offset] |= f.Mask
+			add("flag-"+f.Name+"-"+strconv.Itoa(f.Offset), flagged)
+		}
+	}
+
+	if h.HasBlockChecksums() && !h.IsMemcpy() && len(chunk) > h.Size() {
+		flipped := clone(chunk)
+		flipped[len(flipped)-1] ^= 0x40
+		add("flipped", flipped)
+	}
+	return cases
+}
+
+func clone(b []byte) []byte {
+	return append([]byte(nil), b...)
+}
+
+// All returns the cases of Chunks for data that compresses well, their
+// names starting "ramp/", and for data that does not, starting "noise/",
+// those of Special, and those of Corrupt for the byte-shuffled Blosc1 and
+// checksummed chunks of each codec.
+func All() ([]Case, error) {
+	var cases []Case
+	for _, set := range []struct {
+		name string
+		data []byte
+	}{{"ramp", ramp(6000)}, {"noise", noise(3000)}} {
+		chunks, err := Chunks(set.data)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range chunks {
+			c.Name = set.name + "/" + c.Name
+			cases = append(cases, c)
+		}
+	}
+	for _, typeSize := range []int{1, 4, 8} {
+		special, err := Special(4096, typeSize)
+		if err != nil {
+			return nil, err
+		}
+		cases = append(cases, special...)
+	}
+	for _, c := range cases {
+		if strings.HasPrefix(c.Name, "ramp/") && (strings.HasSuffix(c.Name, "/shuffle/ts4/bs0/split/blosc1") || strings.HasSuffix(c.Name, "/shuffle/ts4/bs0/split/checksums")) {
+			cases = append(cases, Corrupt(c)...)
+		}
+	}
+	return cases, nil
+}
+
+// ramp returns n bytes of slowly rising little-endian uint32s, which
+// compress well with a shuffle
+func ramp(n int) []byte {
+	b := make([]byte, n)
+	for i := 0; i+4 <= n; i += 4 {
+		binary.LittleEndian.PutUint32(b[i:], uint32(i/4)*3)
+	}
+	return b
+}
+
+// noise returns n bytes that do not compress, the same on every call
+func noise(n int) []byte {
+	b := make([]byte, n)
+	x := uint32(2463534242)
+	for i := range b {
+		x ^= x << 13
+		x ^= x >> 17
+		x ^= x << 5
+		b[i] = byte(x)
+	}
+	return b
+}
+
+// WriteCorpus writes the chunks of cases to dir as a seed corpus for a Go
+// fuzz target taking one []byte, such as dir testdata/fuzz/FuzzDecompress
+// for FuzzDecompress. Each file is named after its case.
+func WriteCorpus(dir string, cases []Case) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, c := range cases {
+		name := strings.NewReplacer("/", "-", "\\", "-").Replace(c.Name)
+		content := "go test fuzz v1\n[]byte(" + strconv.Quote(string(c.Chunk)) + ")\n"
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
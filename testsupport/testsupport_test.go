@@ -0,0 +1,76 @@
+package testsupport
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	blosc "github.com/mrjoshuak/go-blosc"
+)
+
+func TestAll(t *testing.T) {
+	cases, err := All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[string]bool)
+	kinds := make(map[string]int)
+	for _, c := range cases {
+		if names[c.Name] {
+			t.Errorf("two cases named %s", c.Name)
+		}
+		names[c.Name] = true
+		for _, kind := range []string{"/split/", "/dontsplit/", "/legacy", "/checksums", "/filters", "/stored", "memcpy", "special/", "/truncated-", "/flag-", "/flipped"} {
+			if strings.Contains(c.Name, kind) {
+				kinds[kind]++
+			}
+		}
+
+		got, err := blosc.Decompress(c.Chunk)
+		if !c.Valid {
+			if err == nil {
+				t.Errorf("%s: decompressed", c.Name)
+			}
+			continue
+		}
+		if err != nil || !bytes.Equal(got, c.Data) {
+			t.Errorf("%s: %v", c.Name, err)
+		}
+	}
+	for _, kind := range []string{"/split/", "/dontsplit/", "/legacy", "/checksums", "/filters", "/stored", "memcpy", "special/", "/truncated-", "/flag-", "/flipped"} {
+		if kinds[kind] == 0 {
+			t.Errorf("no %q cases", kind)
+		}
+	}
+	t.Logf("%d cases", len(cases))
+}
+
+func TestChunksEmpty(t *testing.T) {
+	if _, err := Chunks(nil); err == nil {
+		t.Error("no error for empty data")
+	}
+}
+
+func TestWriteCorpus(t *testing.T) {
+	cases, err := Special(64, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(t.TempDir(), "testdata", "fuzz", "FuzzDecompress")
+	if err := WriteCorpus(dir, cases); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "special-zero-ts8"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(content), "go test fuzz v1\n[]byte(\"") {
+		t.Errorf("corpus file holds %q", content)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "special-nan-ts8")); errors.Is(err, os.ErrNotExist) {
+		t.Error("NaN case not written")
+	}
+}
@@ -0,0 +1,435 @@
+package blosc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ParseCodec returns the codec with the given name, matched without regard
+// to case: one of the names Codec.String returns for the built-in codecs, or
+// the Name of a codec added with RegisterCodec.
+func ParseCodec(name string) (Codec, error) {
+	for c := BloscLZ; c <= ZSTD; c++ {
+		if strings.EqualFold(name, c.String()) {
+			return c, nil
+		}
+	}
+	ids := ListCodecs()
+	slices.Sort(ids)
+	for _, id := range ids {
+		if strings.EqualFold(name, codecs[id].Name()) {
+			return id, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %q", ErrInvalidCodec, name)
+}
+
+// MarshalText returns the codec name, which ParseCodec accepts. Codecs
+// added with RegisterCodec are named by their Name.
+func (c Codec) MarshalText() ([]byte, error) {
+	if c <= ZSTD {
+		return []byte(c.String()), nil
+	}
+	if codec, ok := codecs[c]; ok {
+		return []byte(codec.Name()), nil
+	}
+	return nil, fmt.Errorf("%w: %d", ErrInvalidCodec, c)
+}
+
+// UnmarshalText sets c to the codec named by text, as ParseCodec does.
+func (c *Codec) UnmarshalText(text []byte) error {
+	codec, err := ParseCodec(string(text))
+	if err != nil {
+		return err
+	}
+	*c = codec
+	return nil
+}
+
+// ParseShuffle returns the shuffle mode with the given name, matched without
+// regard to case: "noshuffle", "shuffle", "bitshuffle" or "auto".
+func ParseShuffle(name string) (Shuffle, error) {
+	for s := NoShuffle; s <= AutoShuffle; s++ {
+		if strings.EqualFold(name, s.String()) {
+			return s, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %q", ErrInvalidShuffle, name)
+}
+
+// MarshalText returns the shuffle mode name, which ParseShuffle accepts.
+func (s Shuffle) MarshalText() ([]byte, error) {
+	if s > AutoShuffle {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidShuffle, s)
+	}
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText sets s to the shuffle mode named by text, as ParseShuffle
+// does.
+func (s *Shuffle) UnmarshalText(text []byte) error {
+	shuffle, err := ParseShuffle(string(text))
+	if err != nil {
+		return err
+	}
+	*s = shuffle
+	return nil
+}
+
+// MarshalText encodes the options as a comma-separated list of key=value
+// settings, such as "codec=zstd,level=9,shuffle=shuffle,typesize=8", with
+// "typesize=auto" for TypeSizeAuto. The codec, level, shuffle and typesize
+// keys are always written; the others only when set:
+//
+//	blocksize, threads      BlockSize and NumThreads
+//	legacy, special, skip   LegacyFormat, SpecialValues and SkipIncompressible
+//	checksums               BlockChecksums
+//	strict                  Strict
+//	deterministic           Deterministic
+//	filters                 Filters as "+"-separated slots of name:meta, such
+//	                        as "shuffle:0+bytedelta:4"
+//	error.absolute, error.relative
+//	                        ErrorBound
+//	lz4.acceleration, zstd.windowlog, zstd.level, zlib.huffmanonly
+//	                        CodecParams
+//
+// Options with a Prefilter or a ZSTD dictionary cannot be encoded. Other
+// Hooks are not encoded.
+func (o Options) MarshalText() ([]byte, error) {
+	if o.prefilter() != nil {
+		return nil, errors.New("blosc: cannot encode options with a Prefilter")
+	}
+	if o.CodecParams.ZSTD.Dict != nil {
+		return nil, errors.New("blosc: cannot encode options with a ZSTD dictionary")
+	}
+	codec, err := o.Codec.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	shuffle, err := o.Shuffle.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	typeSize := strconv.Itoa(o.TypeSize)
+	if o.TypeSize == TypeSizeAuto {
+		typeSize = "auto"
+	}
+	b := fmt.Appendf(nil, "codec=%s,level=%d,shuffle=%s,typesize=%s", codec, o.Level, shuffle, typeSize)
+	setting := func(key string, value any, set bool) {
+		if set {
+			b = fmt.Appendf(b, ",%s=%v", key, value)
+		}
+	}
+	setting("blocksize", o.BlockSize, o.BlockSize != 0)
+	setting("threads", o.NumThreads, o.NumThreads != 0)
+	setting("legacy", o.LegacyFormat, o.LegacyFormat)
+	setting("special", o.SpecialValues, o.SpecialValues)
+	setting("skip", o.SkipIncompressible, o.SkipIncompressible)
+	setting("checksums", o.BlockChecksums, o.BlockChecksums)
+	setting("strict", o.Strict, o.Strict)
+	setting("deterministic", o.Deterministic, o.Deterministic)
+	if o.Filters != ([MaxFilters]FilterStage{}) {
+		last := MaxFilters - 1
+		for o.Filters[last].Filter == FilterNone {
+			last--
+		}
+		slots := make([]string, last+1)
+		for i, stage := range o.Filters[:last+1] {
+			if _, ok := parseFilter(stage.Filter.String()); !ok {
+				return nil, fmt.Errorf("%w: %d", ErrInvalidFilter, stage.Filter)
+			}
+			slots[i] = fmt.Sprintf("%s:%d", stage.Filter, stage.Meta)
+		}
+		setting("filters", strings.Join(slots, "+"), true)
+	}
+	setting("error.absolute", o.ErrorBound.Absolute, o.ErrorBound.Absolute != 0)
+	setting("error.relative", o.ErrorBound.Relative, o.ErrorBound.Relative != 0)
+	p := o.CodecParams
+	setting("lz4.acceleration", p.LZ4.Acceleration, p.LZ4.Acceleration != 0)
+	setting("zstd.windowlog", p.ZSTD.WindowLog, p.ZSTD.WindowLog != 0)
+	setting("zstd.level", p.ZSTD.Level, p.ZSTD.Level != 0)
+	setting("zlib.huffmanonly", p.ZLIB.HuffmanOnly, p.ZLIB.HuffmanOnly)
+	return b, nil
+}
+
+// String describes the options for logs, as in "zstd level 7, bitshuffle
+// ts=8", followed by the settings MarshalText writes besides those, such as
+// "blocksize=65536" or "checksums".
+func (o Options) String() string {
+	typeSize := strconv.Itoa(o.TypeSize)
+	if o.TypeSize == TypeSizeAuto {
+		typeSize = "auto"
+	}
+	s := fmt.Sprintf("%s level %d, %s ts=%s", codecName(o.Codec), o.Level, o.Shuffle, typeSize)
+	prefilter, dict := o.prefilter() != nil, o.CodecParams.ZSTD.Dict != nil
+	o.Hooks, o.CodecParams.ZSTD.Dict = nil, nil
+	text, err := o.MarshalText()
+	if err != nil {
+		return s
+	}
+	// Skip the codec, level, shuffle and typesize settings
+	for _, setting := range strings.Split(string(text), ",")[4:] {
+		setting = strings.TrimSuffix(setting, "=true")
+		s += ", " + setting
+	}
+	if dict {
+		s += ", zstd.dict"
+	}
+	if prefilter {
+		s += ", prefilter"
+	}
+	return s
+}
+
+// MarshalJSON encodes the options as a JSON string of the form MarshalText
+// writes, which UnmarshalText reads back. Unlike MarshalText, it leaves out
+// a Prefilter and a ZSTD dictionary instead of failing, so that options can
+// always be logged.
+func (o Options) MarshalJSON() ([]byte, error) {
+	o.Hooks, o.CodecParams.ZSTD.Dict = nil, nil
+	text, err := o.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(string(text))
+}
+
+// UnmarshalText sets o from settings in the form MarshalText writes. Keys
+// are matched without regard to case and may come in any order; settings
+// that are left out keep their DefaultOptions values, so "codec=zstd" alone
+// gives ZSTD at level 5 with byte shuffle.
+func (o *Options) UnmarshalText(text []byte) error {
+	opts := DefaultOptions()
+	for _, setting := range strings.Split(string(text), ",") {
+		setting = strings.TrimSpace(setting)
+		if setting == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(setting, "=")
+		if !ok {
+			return fmt.Errorf("blosc: option %q has no value", setting)
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+		var err error
+		switch key {
+		case "codec":
+			opts.Codec, err = ParseCodec(value)
+		case "level":
+			opts.Level, err = strconv.Atoi(value)
+		case "shuffle":
+			opts.Shuffle, err = ParseShuffle(value)
+		case "typesize":
+			if strings.EqualFold(value, "auto") {
+				opts.TypeSize = TypeSizeAuto
+			} else {
+				opts.TypeSize, err = strconv.Atoi(value)
+			}
+		case "blocksize":
+			opts.BlockSize, err = strconv.Atoi(value)
+		case "threads":
+			opts.NumThreads, err = strconv.Atoi(value)
+		case "legacy":
+			opts.LegacyFormat, err = strconv.ParseBool(value)
+		case "special":
+			opts.SpecialValues, err = strconv.ParseBool(value)
+		case "skip":
+			opts.SkipIncompressible, err = strconv.ParseBool(value)
+		case "checksums":
+			opts.BlockChecksums, err = strconv.ParseBool(value)
+		case "strict":
+			opts.Strict, err = strconv.ParseBool(value)
+		case "deterministic":
+			opts.Deterministic, err = strconv.ParseBool(value)
+		case "filters":
+			opts.Filters, err = parseFilters(value)
+		case "error.absolute":
+			opts.ErrorBound.Absolute, err = strconv.ParseFloat(value, 64)
+		case "error.relative":
+			opts.ErrorBound.Relative, err = strconv.ParseFloat(value, 64)
+		case "lz4.acceleration":
+			opts.CodecParams.LZ4.Acceleration, err = strconv.Atoi(value)
+		case "zstd.windowlog":
+			opts.CodecParams.ZSTD.WindowLog, err = strconv.Atoi(value)
+		case "zstd.level":
+			opts.CodecParams.ZSTD.Level, err = strconv.Atoi(value)
+		case "zlib.huffmanonly":
+			opts.CodecParams.ZLIB.HuffmanOnly, err = strconv.ParseBool(value)
+		default:
+			return fmt.Errorf("blosc: unknown option %q", key)
+		}
+		if err != nil {
+			return fmt.Errorf("blosc: option %s: %w", key, err)
+		}
+	}
+	*o = opts
+	return nil
+}
+
+// parseFilters decodes a filter pipeline in the form Options.MarshalText
+// writes. A slot without ":meta" has Meta 0.
+func parseFilters(text string) ([MaxFilters]FilterStage, error) {
+	var filters [MaxFilters]FilterStage
+	slots := strings.Split(text, "+")
+	if len(slots) > MaxFilters {
+		return filters, fmt.Errorf("%w: %d filters, at most %d fit", ErrInvalidFilter, len(slots), MaxFilters)
+	}
+	for i, slot := range slots {
+		name, meta, hasMeta := strings.Cut(strings.TrimSpace(slot), ":")
+		filter, ok := parseFilter(name)
+		if !ok {
+			return filters, fmt.Errorf("%w: %q", ErrInvalidFilter, name)
+		}
+		filters[i].Filter = filter
+		if hasMeta {
+			m, err := strconv.ParseUint(meta, 10, 8)
+			if err != nil {
+				return filters, fmt.Errorf("%w: meta of %s: %w", ErrInvalidFilter, name, err)
+			}
+			filters[i].Meta = uint8(m)
+		}
+	}
+	return filters, nil
+}
+
+// parseFilter returns the filter whose String is name
+func parseFilter(name string) (Filter, bool) {
+	for _, f := range []Filter{FilterNone, FilterShuffle, FilterBitShuffle, FilterDelta, FilterTruncPrec, FilterNDCell, FilterByteDelta, FilterZigZag, FilterBitPack, FilterFloatQuantize, FilterTranspose} {
+		if strings.EqualFold(name, f.String()) {
+			return f, true
+		}
+	}
+	return 0, false
+}
+
+// codecName returns the name of c, as MarshalText does where it can
+func codecName(c Codec) string {
+	if name, err := c.MarshalText(); err == nil {
+		return string(name)
+	}
+	return c.String()
+}
+
+// String describes the chunk for logs, as in "zstd, bitshuffle ts=8,
+// 1.2 MB -> 300 KB", followed by its filter pipeline if it has an extended
+// header and notes such as "memcpy" or "checksums".
+func (h *Header) String() string {
+	s := fmt.Sprintf("%s, %s ts=%d, %s -> %s", codecName(h.Codec()), h.ShuffleMode(), h.TypeSize,
+		formatBytes(int64(h.NBytesOrig)), formatBytes(int64(h.NBytesComp)))
+	if h.IsExtended() {
+		var stages []string
+		for _, f := range h.Filters {
+			switch {
+			case f.Filter == FilterNone:
+			case f.Meta != 0:
+				stages = append(stages, fmt.Sprintf("%s(%d)", f.Filter, f.Meta))
+			default:
+				stages = append(stages, f.Filter.String())
+			}
+		}
+		if stages != nil {
+			s += ", filters " + strings.Join(stages, "+")
+		}
+	}
+	if h.legacy {
+		s += ", legacy"
+	}
+	if h.IsMemcpy() {
+		s += ", memcpy"
+	}
+	if kind := h.Special(); kind != SpecialNone {
+		s += ", special=" + kind.String()
+	}
+	if h.HasDict() {
+		s += ", dict"
+	}
+	if h.HasBlockChecksums() {
+		s += ", checksums"
+	}
+	return s
+}
+
+// headerJSON is the JSON form of a Header: its fields, the Filters and
+// Blosc2Flags of extended headers only, and the codec and shuffle by name
+type headerJSON struct {
+	Version     uint8
+	VersionLZ   uint8
+	Flags       uint8
+	TypeSize    uint8
+	NBytesOrig  uint32
+	BlockSize   uint32
+	NBytesComp  uint32
+	Filters     *[MaxFilters]FilterStage `json:",omitempty"`
+	UDCodec     uint8                    `json:",omitempty"`
+	CodecMeta   uint8                    `json:",omitempty"`
+	Blosc2Flags uint8                    `json:",omitempty"`
+	Codec       string
+	Shuffle     string
+	Legacy      bool   `json:",omitempty"`
+	Memcpy      bool   `json:",omitempty"`
+	Special     string `json:",omitempty"`
+}
+
+func (h *Header) jsonForm() headerJSON {
+	j := headerJSON{
+		Version:    h.Version,
+		VersionLZ:  h.VersionLZ,
+		Flags:      h.Flags,
+		TypeSize:   h.TypeSize,
+		NBytesOrig: h.NBytesOrig,
+		BlockSize:  h.BlockSize,
+		NBytesComp: h.NBytesComp,
+		Codec:      codecName(h.Codec()),
+		Shuffle:    h.ShuffleMode().String(),
+		Legacy:     h.legacy,
+		Memcpy:     h.IsMemcpy(),
+	}
+	if h.IsExtended() {
+		j.Filters, j.Blosc2Flags = &h.Filters, h.Blosc2Flags
+		j.UDCodec, j.CodecMeta = h.UDCodec, h.CodecMeta
+	}
+	if kind := h.Special(); kind != SpecialNone {
+		j.Special = kind.String()
+	}
+	return j
+}
+
+// MarshalJSON encodes the header fields, adding the codec and shuffle mode
+// by name and whether the chunk is legacy, memcpy or special.
+func (h *Header) MarshalJSON() ([]byte, error) {
+	return json.Marshal(h.jsonForm())
+}
+
+// MarshalJSON encodes the header as Header.MarshalJSON does, followed by
+// the fields Info adds. CodecName and ShuffleName are left out, as the
+// header's Codec and Shuffle hold them.
+func (i *Info) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		headerJSON
+		Ratio        float64
+		Blocks       int
+		Checksums    bool
+		DecodeMemory int64
+	}{i.Header.jsonForm(), i.Ratio, i.Blocks, i.Checksums, i.DecodeMemory})
+}
+
+// formatBytes formats n bytes for people, in B, KB, MB or GB of 1024
+func formatBytes(n int64) string {
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	v, unit := float64(n)/1024, "KB"
+	for _, u := range []string{"MB", "GB"} {
+		if v < 1024 {
+			break
+		}
+		v, unit = v/1024, u
+	}
+	if v < 10 {
+		return fmt.Sprintf("%.1f %s", v, unit)
+	}
+	return fmt.Sprintf("%.0f %s", v, unit)
+}
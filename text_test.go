@@ -0,0 +1,211 @@
+package blosc
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestParseCodec(t *testing.T) {
+	for c := BloscLZ; c <= ZSTD; c++ {
+		for _, name := range []string{c.String(), strings.ToUpper(c.String())} {
+			if got, err := ParseCodec(name); err != nil || got != c {
+				t.Errorf("ParseCodec(%q) = %v, %v", name, got, err)
+			}
+		}
+	}
+	if _, err := ParseCodec("brotli"); !errors.Is(err, ErrInvalidCodec) {
+		t.Errorf("expected ErrInvalidCodec, got %v", err)
+	}
+
+	// Registered codecs are found by name
+	const custom Codec = 100
+	RegisterCodec(custom, namedCodec{"mycodec"})
+	defer delete(codecs, custom)
+	if got, err := ParseCodec("MyCodec"); err != nil || got != custom {
+		t.Errorf("ParseCodec(MyCodec) = %v, %v", got, err)
+	}
+	if text, err := custom.MarshalText(); err != nil || string(text) != "mycodec" {
+		t.Errorf("MarshalText = %q, %v", text, err)
+	}
+	if _, err := Codec(101).MarshalText(); !errors.Is(err, ErrInvalidCodec) {
+		t.Errorf("expected ErrInvalidCodec, got %v", err)
+	}
+}
+
+// namedCodec is an LZ4 codec registered under another name
+type namedCodec struct{ name string }
+
+func (c namedCodec) Compress(data []byte, level int) ([]byte, error) {
+	return codecs[LZ4].Compress(data, level)
+}
+
+func (c namedCodec) Decompress(data []byte, expectedSize int) ([]byte, error) {
+	return codecs[LZ4].Decompress(data, expectedSize)
+}
+
+func (c namedCodec) Name() string { return c.name }
+
+func TestParseShuffle(t *testing.T) {
+	for s := NoShuffle; s <= BitShuffle; s++ {
+		if got, err := ParseShuffle(strings.ToUpper(s.String())); err != nil || got != s {
+			t.Errorf("ParseShuffle(%q) = %v, %v", s, got, err)
+		}
+	}
+	if _, err := ParseShuffle("byteshuffle"); !errors.Is(err, ErrInvalidShuffle) {
+		t.Errorf("expected ErrInvalidShuffle, got %v", err)
+	}
+	if _, err := Shuffle(7).MarshalText(); !errors.Is(err, ErrInvalidShuffle) {
+		t.Errorf("expected ErrInvalidShuffle, got %v", err)
+	}
+}
+
+func TestOptionsText(t *testing.T) {
+	float, _ := Profile(ProfileFloatData)
+	for _, opts := range []Options{
+		{},
+		DefaultOptions(),
+		float,
+		{Codec: ZLIB, Level: 9, Shuffle: BitShuffle, TypeSize: 8, BlockSize: 1 << 16, NumThreads: 4, LegacyFormat: true, SkipIncompressible: true, Strict: true,
+			CodecParams: CodecParams{LZ4: LZ4Params{Acceleration: 8}, ZSTD: ZSTDParams{WindowLog: 20, Level: -3}, ZLIB: ZLIBParams{HuffmanOnly: true}}},
+		{Codec: ZSTD, Level: 1, TypeSize: 4, BlockChecksums: true, Filters: [MaxFilters]FilterStage{2: {Filter: FilterNDCell, Meta: 4}, 3: {Filter: FilterShuffle}}},
+		{Codec: ZSTD, Level: 5, TypeSize: 8, Filters: [MaxFilters]FilterStage{{Filter: FilterFloatQuantize}, {Filter: FilterShuffle}}, ErrorBound: ErrorBound{Absolute: 1e-6, Relative: 0.25}},
+	} {
+		text, err := opts.MarshalText()
+		if err != nil {
+			t.Fatalf("%+v: %v", opts, err)
+		}
+		var got Options
+		if err := got.UnmarshalText(text); err != nil {
+			t.Fatalf("%s: %v", text, err)
+		}
+		if !reflect.DeepEqual(got, opts) {
+			t.Errorf("%s: got %+v, want %+v", text, got, opts)
+		}
+	}
+
+	// Settings that are left out keep their defaults
+	var opts Options
+	if err := opts.UnmarshalText([]byte(" Codec = ZSTD, level=9 ,")); err != nil {
+		t.Fatal(err)
+	}
+	want := DefaultOptions()
+	want.Codec, want.Level = ZSTD, 9
+	if !reflect.DeepEqual(opts, want) {
+		t.Errorf("got %+v, want %+v", opts, want)
+	}
+
+	// Options and their fields work as JSON strings
+	var config struct {
+		Options Options
+		Codec   Codec
+	}
+	if err := json.Unmarshal([]byte(`{"Options": "codec=lz4hc,shuffle=bitshuffle", "Codec": "snappy"}`), &config); err != nil {
+		t.Fatal(err)
+	}
+	if config.Options.Codec != LZ4HC || config.Options.Shuffle != BitShuffle || config.Codec != Snappy {
+		t.Errorf("JSON config decoded as %+v", config)
+	}
+	if b, err := json.Marshal(config); err != nil || string(b) != `{"Options":"codec=lz4hc,level=5,shuffle=bitshuffle,typesize=4","Codec":"snappy"}` {
+		t.Errorf("JSON config encoded as %s, %v", b, err)
+	}
+
+	for _, bad := range []string{"codec", "colour=red", "level=high", "codec=brotli", "filters=shuffle+fancy", "filters=bytedelta:300", "filters=a+b+c+d+e+f+g"} {
+		if err := opts.UnmarshalText([]byte(bad)); err == nil {
+			t.Errorf("%q: no error", bad)
+		}
+	}
+	if _, err := (Options{Hooks: &Hooks{Prefilter: func([]byte, int) {}}}).MarshalText(); err == nil {
+		t.Error("options with a Prefilter encoded")
+	}
+	if _, err := (Options{Filters: [MaxFilters]FilterStage{{Filter: 99}}}).MarshalText(); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("expected ErrInvalidFilter, got %v", err)
+	}
+}
+
+func TestOptionsString(t *testing.T) {
+	for _, tt := range []struct {
+		opts Options
+		want string
+	}{
+		{Options{Codec: ZSTD, Level: 7, Shuffle: BitShuffle, TypeSize: 8}, "zstd level 7, bitshuffle ts=8"},
+		{Options{Codec: LZ4, Level: 5, TypeSize: TypeSizeAuto, BlockSize: 1 << 16, BlockChecksums: true}, "lz4 level 5, noshuffle ts=auto, blocksize=65536, checksums"},
+		{Options{Codec: ZLIB, Level: 1, Shuffle: Shuffle1, TypeSize: 4, Hooks: &Hooks{Prefilter: func([]byte, int) {}}}, "zlib level 1, shuffle ts=4, prefilter"},
+		{Options{Codec: 99, Level: 1, TypeSize: 4}, "unknown(99) level 1, noshuffle ts=4"},
+	} {
+		if got := tt.opts.String(); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+
+	// Options with a Prefilter can be logged as JSON
+	b, err := json.Marshal(Options{Codec: LZ4, Level: 5, TypeSize: 4, Hooks: &Hooks{Prefilter: func([]byte, int) {}}})
+	if err != nil || string(b) != `"codec=lz4,level=5,shuffle=noshuffle,typesize=4"` {
+		t.Errorf("JSON options encoded as %s, %v", b, err)
+	}
+}
+
+func TestHeaderString(t *testing.T) {
+	data := makeFloatData(300000)
+	for _, tt := range []struct {
+		opts Options
+		want string
+	}{
+		{Options{Codec: ZSTD, Level: 7, Shuffle: BitShuffle, TypeSize: 8}, `^zstd, bitshuffle ts=8, 1\.1 MB -> \d+ KB$`},
+		{Options{Codec: LZ4, Level: 0, TypeSize: 4}, `^lz4, noshuffle ts=4, 1\.1 MB -> 1\.1 MB, memcpy$`},
+		{Options{Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: 4, LegacyFormat: true}, `^lz4, shuffle ts=4, 1\.1 MB -> \d+ KB, legacy$`},
+		{Options{Codec: ZSTD, Level: 5, TypeSize: 4, BlockChecksums: true, Filters: [MaxFilters]FilterStage{{Filter: FilterShuffle}, {Filter: FilterByteDelta, Meta: 4}}},
+			`^zstd, shuffle ts=4, 1\.1 MB -> \d+ KB, filters shuffle\+bytedelta\(4\), checksums$`},
+	} {
+		chunk, err := CompressWithOptions(data, tt.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h, _ := ParseHeader(chunk)
+		if got := h.String(); !regexp.MustCompile(tt.want).MatchString(got) {
+			t.Errorf("got %q, want %s", got, tt.want)
+		}
+	}
+
+	chunk, err := CompressWithOptions(data, Options{Codec: ZSTD, Level: 5, TypeSize: 4, BlockChecksums: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, _ := ParseHeader(chunk)
+	var fields map[string]any
+	b, err := json.Marshal(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if fields["Codec"] != "zstd" || fields["NBytesOrig"] != float64(len(data)) || fields["Blosc2Flags"] != float64(blosc2FlagSums) || fields["Filters"] == nil {
+		t.Errorf("header encoded as %s", b)
+	}
+
+	// Info adds its fields to those of the header
+	info, _ := GetInfo(chunk)
+	b, err = json.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields = nil
+	if err := json.Unmarshal(b, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if fields["Codec"] != "zstd" || fields["Checksums"] != true || fields["Blocks"] != float64(info.Blocks) || fields["DecodeMemory"] == nil {
+		t.Errorf("info encoded as %s", b)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, want := range map[int64]string{0: "0 B", 1023: "1023 B", 1024: "1.0 KB", 300 << 10: "300 KB", 1258291: "1.2 MB", 5 << 30: "5.0 GB", 4 << 40: "4096 GB"} {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
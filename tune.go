@@ -0,0 +1,316 @@
+package blosc
+
+import (
+	"slices"
+	"time"
+)
+
+// TuneObjective selects what Tune optimizes for.
+type TuneObjective int
+
+const (
+	// TuneBalanced minimizes the time to compress, decompress and move the
+	// compressed data over a link of about 200 MB/s, such as a disk or network.
+	TuneBalanced TuneObjective = iota
+
+	// TuneSpeed is TuneBalanced for an in-memory link of about 4 GB/s, which
+	// favors the fastest codecs even at a lower ratio.
+	TuneSpeed
+
+	// TuneRatio minimizes the compressed size, whatever the speed.
+	TuneRatio
+)
+
+// String returns the objective name.
+func (o TuneObjective) String() string {
+	switch o {
+	case TuneBalanced:
+		return "balanced"
+	case TuneSpeed:
+		return "speed"
+	case TuneRatio:
+		return "ratio"
+	default:
+		return "unknown"
+	}
+}
+
+// Link bandwidths, in bytes per second, that the speed objectives assume
+const (
+	tuneBalancedBandwidth = 200 << 20
+	tuneSpeedBandwidth    = 4 << 30
+)
+
+// Sampling limits: Tune compresses up to tuneSamples slices of
+// tuneSampleSize bytes spread evenly over the input
+const (
+	tuneSampleSize = 64 << 10
+	tuneSamples    = 4
+	tuneLevel      = 5 // Level used while comparing codecs and shuffles
+)
+
+// TuneTrial records one candidate Tune evaluated on the sample.
+type TuneTrial struct {
+	Options        Options
+	CompressedSize int           // Compressed size of the sample, headers included
+	Ratio          float64       // Sample size divided by CompressedSize
+	CompressTime   time.Duration // Time to compress the sample
+	DecompressTime time.Duration // Time to decompress the sample
+}
+
+// TuneReport describes how Tune reached its choice.
+type TuneReport struct {
+	Objective  TuneObjective
+	SampleSize int           // Bytes of input each candidate compressed
+	Trials     []TuneTrial   // Candidates in the order they were evaluated
+	Best       int           // Index of the chosen candidate in Trials
+	Elapsed    time.Duration // Total time spent tuning
+	Truncated  bool          // The budget ran out before every candidate was tried
+}
+
+// tuneShuffle is a shuffle mode paired with the element size it assumes
+type tuneShuffle struct {
+	shuffle  Shuffle
+	typeSize int
+}
+
+// tuneShuffles lists the shuffle candidates, most commonly useful first.
+// Tune does not know the element type, so it tries the common sizes.
+var tuneShuffles = []tuneShuffle{
+	{Shuffle1, 4},
+	{NoShuffle, 1},
+	{BitShuffle, 4},
+	{Shuffle1, 8},
+	{Shuffle1, 2},
+	{BitShuffle, 8},
+	{BitShuffle, 2},
+}
+
+// Tune picks compression options for data by sampling it, in the spirit of
+// Blosc2's btune. It uses the TuneBalanced objective; see TuneFor.
+func Tune(data []byte, budget time.Duration) (Options, TuneReport, error) {
+	return TuneFor(data, budget, TuneBalanced)
+}
+
+// TuneFor picks compression options for data under the given objective.
+//
+// It compresses a sample of up to 256 KB taken evenly across data, first with
+// every registered codec and shuffle mode at level 5, then at every level of
+// the best combination. Tuning stops once budget has elapsed, after at least
+// one trial; a budget of 0 or less tries every candidate. Timings are
+// measured, so results can vary between runs on noisy machines.
+func TuneFor(data []byte, budget time.Duration, objective TuneObjective) (Options, TuneReport, error) {
+	return tune(data, budget, objective, tuneShuffles)
+}
+
+// RunTrial compresses data with opts in chunks of chunkSize bytes, handled
+// as by NewWriterSize, then decompresses them, and reports the compressed
+// size and times as Tune does for each candidate. It measures all of data
+// rather than a sample, for benchmarks of a codec, level and shuffle on real
+// data; Ratio counts the chunk headers.
+func RunTrial(data []byte, chunkSize int, opts Options) (TuneTrial, error) {
+	if len(data) == 0 {
+		return TuneTrial{}, ErrInvalidData
+	}
+	size := chunkSizeFor(chunkSize, opts.TypeSize)
+	chunks := make([][]byte, 0, (len(data)+size-1)/size)
+	for len(data) > 0 {
+		n := min(size, len(data))
+		chunks = append(chunks, data[:n])
+		data = data[n:]
+	}
+	return tuneTrial(chunks, opts)
+}
+
+// autoSampleSize is how much of the start of a chunk AutoShuffle and
+// TypeSizeAuto compress with each candidate
+const autoSampleSize = 32 << 10
+
+// autoTypeSizes are the element sizes TypeSizeAuto tries, in order
+var autoTypeSizes = []int{1, 2, 4, 8}
+
+// pickTypeSize returns the element size, for TypeSizeAuto, that compresses
+// the first autoSampleSize bytes of data smallest with opts. Sizes are tried
+// in autoTypeSizes order and the first of equal sizes wins. Without a filter
+// pipeline the sample is byte shuffled, unless opts asks for bit shuffle,
+// since the element size matters little unshuffled. Store mode is compared
+// at level 1, and the sample is taken before any Prefilter.
+func pickTypeSize(data []byte, opts Options) int {
+	sample := data[:min(len(data), autoSampleSize)]
+	opts.Level = max(opts.Level, 1)
+	opts.Hooks = nil
+	if opts.Shuffle != BitShuffle {
+		opts.Shuffle = Shuffle1
+	}
+	best, bestSize := 1, -1
+	for _, typeSize := range autoTypeSizes {
+		opts.TypeSize = typeSize
+		chunk, err := compressGo(sample, opts, nil)
+		if err == nil && (bestSize < 0 || len(chunk) < bestSize) {
+			best, bestSize = typeSize, len(chunk)
+		}
+	}
+	return best
+}
+
+// pickShuffle returns the shuffle mode, for AutoShuffle, that compresses the
+// first autoSampleSize bytes of data smallest with opts. Modes are tried
+// from the cheapest, NoShuffle, to BitShuffle, and the first of equal sizes
+// wins. Store mode is compared at level 1, and the sample is taken before
+// any Prefilter. With a filter pipeline the mode is ignored anyway.
+func pickShuffle(data []byte, opts Options) Shuffle {
+	if opts.Filters != ([MaxFilters]FilterStage{}) {
+		return NoShuffle
+	}
+	sample := data[:min(len(data), autoSampleSize)]
+	opts.Level = max(opts.Level, 1)
+	opts.Hooks = nil
+	best, bestSize := NoShuffle, -1
+	for _, mode := range []Shuffle{NoShuffle, Shuffle1, BitShuffle} {
+		if mode == Shuffle1 && opts.TypeSize == 1 {
+			continue // The same as NoShuffle
+		}
+		opts.Shuffle = mode
+		chunk, err := compressGo(sample, opts, nil)
+		if err == nil && (bestSize < 0 || len(chunk) < bestSize) {
+			best, bestSize = mode, len(chunk)
+		}
+	}
+	return best
+}
+
+// tuneShufflesFor returns the shuffle candidates for a known element size
+func tuneShufflesFor(typeSize int) []tuneShuffle {
+	return []tuneShuffle{{Shuffle1, typeSize}, {NoShuffle, typeSize}, {BitShuffle, typeSize}}
+}
+
+// tune implements TuneFor over the given shuffle candidates
+func tune(data []byte, budget time.Duration, objective TuneObjective, shuffles []tuneShuffle) (Options, TuneReport, error) {
+	if len(data) == 0 {
+		return Options{}, TuneReport{}, ErrInvalidData
+	}
+
+	start := time.Now()
+	samples := tuneSample(data)
+	report := TuneReport{Objective: objective}
+	for _, s := range samples {
+		report.SampleSize += len(s)
+	}
+
+	ids := ListCodecs()
+	slices.Sort(ids)
+
+	// try evaluates opts and reports false once the budget is spent
+	try := func(opts Options) bool {
+		if budget > 0 && len(report.Trials) > 0 && time.Since(start) >= budget {
+			report.Truncated = true
+			return false
+		}
+		trial, err := tuneTrial(samples, opts)
+		if err != nil {
+			return true
+		}
+		report.Trials = append(report.Trials, trial)
+		if len(report.Trials) == 1 || tuneBetter(trial, report.Trials[report.Best], objective) {
+			report.Best = len(report.Trials) - 1
+		}
+		return true
+	}
+
+	// Phase 1: codec and shuffle at a fixed level
+	done := false
+	for _, s := range shuffles {
+		for _, id := range ids {
+			if !try(Options{Codec: id, Level: tuneLevel, Shuffle: s.shuffle, TypeSize: s.typeSize}) {
+				done = true
+				break
+			}
+		}
+		if done {
+			break
+		}
+	}
+
+	// Phase 2: every level of the winning combination
+	if !done && len(report.Trials) > 0 {
+		best := report.Trials[report.Best].Options
+		caps, err := CodecInfo(best.Codec)
+		if err == nil && caps.LevelsMatter {
+			for level := caps.MinLevel; level <= caps.MaxLevel; level++ {
+				if level == best.Level {
+					continue
+				}
+				opts := best
+				opts.Level = level
+				if !try(opts) {
+					break
+				}
+			}
+		}
+	}
+
+	report.Elapsed = time.Since(start)
+	if len(report.Trials) == 0 {
+		return Options{}, report, ErrCompressionFailed
+	}
+	return report.Trials[report.Best].Options, report, nil
+}
+
+// tuneSample returns up to tuneSamples slices of data spread evenly over it,
+// aligned to 8 bytes so that every candidate element size lines up
+func tuneSample(data []byte) [][]byte {
+	if len(data) <= tuneSampleSize*tuneSamples {
+		return [][]byte{data}
+	}
+	samples := make([][]byte, tuneSamples)
+	stride := (len(data) - tuneSampleSize) / (tuneSamples - 1)
+	for i := range samples {
+		off := i * stride &^ 7
+		samples[i] = data[off : off+tuneSampleSize]
+	}
+	return samples
+}
+
+// tuneTrial compresses and decompresses every sample with opts
+func tuneTrial(samples [][]byte, opts Options) (TuneTrial, error) {
+	trial := TuneTrial{Options: opts}
+	total := 0
+	for _, s := range samples {
+		t0 := time.Now()
+		compressed, err := CompressWithOptions(s, opts)
+		if err != nil {
+			return TuneTrial{}, err
+		}
+		t1 := time.Now()
+		if _, err := Decompress(compressed); err != nil {
+			return TuneTrial{}, err
+		}
+		trial.CompressTime += t1.Sub(t0)
+		trial.DecompressTime += time.Since(t1)
+		trial.CompressedSize += len(compressed)
+		total += len(s)
+	}
+	trial.Ratio = float64(total) / float64(trial.CompressedSize)
+	return trial, nil
+}
+
+// tuneBetter reports whether trial a beats trial b under objective
+func tuneBetter(a, b TuneTrial, objective TuneObjective) bool {
+	switch objective {
+	case TuneRatio:
+		if a.CompressedSize != b.CompressedSize {
+			return a.CompressedSize < b.CompressedSize
+		}
+		return a.CompressTime+a.DecompressTime < b.CompressTime+b.DecompressTime
+	case TuneSpeed:
+		return tuneCost(a, tuneSpeedBandwidth) < tuneCost(b, tuneSpeedBandwidth)
+	default:
+		return tuneCost(a, tuneBalancedBandwidth) < tuneCost(b, tuneBalancedBandwidth)
+	}
+}
+
+// tuneCost returns the seconds to compress, transfer and decompress the
+// sample over a link of the given bandwidth
+func tuneCost(t TuneTrial, bandwidth float64) float64 {
+	return (t.CompressTime + t.DecompressTime).Seconds() + float64(t.CompressedSize)/bandwidth
+}
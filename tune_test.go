@@ -0,0 +1,261 @@
+package blosc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// makeFloatData returns a slowly varying float32 series, the kind of typed
+// data shuffle is meant for
+func makeFloatData(n int) []byte {
+	data := make([]byte, 4*n)
+	for i := 0; i < n; i++ {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(float32(math.Sin(float64(i)/500))))
+	}
+	return data
+}
+
+func TestTuneRatio(t *testing.T) {
+	data := makeFloatData(200000)
+
+	opts, report, err := TuneFor(data, 0, TuneRatio)
+	if err != nil {
+		t.Fatalf("tune failed: %v", err)
+	}
+	if report.Truncated {
+		t.Error("unlimited budget reported as truncated")
+	}
+	if report.SampleSize != tuneSampleSize*tuneSamples {
+		t.Errorf("SampleSize = %d, want %d", report.SampleSize, tuneSampleSize*tuneSamples)
+	}
+	if !reflect.DeepEqual(opts, report.Trials[report.Best].Options) {
+		t.Errorf("returned %+v, best trial has %+v", opts, report.Trials[report.Best].Options)
+	}
+	for _, trial := range report.Trials {
+		if trial.CompressedSize < report.Trials[report.Best].CompressedSize {
+			t.Errorf("%+v compressed to %d bytes, smaller than the chosen %d", trial.Options, trial.CompressedSize, report.Trials[report.Best].CompressedSize)
+		}
+	}
+	if opts.Shuffle == NoShuffle {
+		t.Errorf("expected a shuffle mode for float data, got %+v", opts)
+	}
+
+	compressed, err := CompressWithOptions(data, opts)
+	if err != nil {
+		t.Fatalf("compress failed: %v", err)
+	}
+	decompressed, err := Decompress(compressed)
+	if err != nil {
+		t.Fatalf("decompress failed: %v", err)
+	}
+	if !bytes.Equal(decompressed, data) {
+		t.Error("round trip with tuned options failed")
+	}
+}
+
+func TestTuneObjectives(t *testing.T) {
+	data := makeFloatData(10000)
+
+	for _, objective := range []TuneObjective{TuneBalanced, TuneSpeed, TuneRatio} {
+		t.Run(objective.String(), func(t *testing.T) {
+			opts, report, err := TuneFor(data, 0, objective)
+			if err != nil {
+				t.Fatalf("tune failed: %v", err)
+			}
+			if report.Objective != objective {
+				t.Errorf("Objective = %s", report.Objective)
+			}
+			if report.SampleSize != len(data) {
+				t.Errorf("small input should be sampled whole, got %d of %d bytes", report.SampleSize, len(data))
+			}
+			// Every codec and shuffle candidate, plus the level sweep
+			if len(report.Trials) < len(tuneShuffles)*len(ListCodecs()) {
+				t.Errorf("only %d trials", len(report.Trials))
+			}
+			if _, err := CompressWithOptions(data, opts); err != nil {
+				t.Errorf("tuned options do not compress: %v", err)
+			}
+		})
+	}
+}
+
+func TestTuneBudget(t *testing.T) {
+	_, report, err := Tune(makeFloatData(10000), time.Nanosecond)
+	if err != nil {
+		t.Fatalf("tune failed: %v", err)
+	}
+	if len(report.Trials) != 1 || !report.Truncated {
+		t.Errorf("expected one trial and a truncated report, got %d trials, truncated=%v", len(report.Trials), report.Truncated)
+	}
+}
+
+func TestTuneEmpty(t *testing.T) {
+	if _, _, err := Tune(nil, 0); !errors.Is(err, ErrInvalidData) {
+		t.Errorf("expected ErrInvalidData, got %v", err)
+	}
+}
+
+func TestRunTrial(t *testing.T) {
+	data := makeFloatData(100000)
+	opts := Options{Codec: ZSTD, Level: 5, Shuffle: Shuffle1, TypeSize: 4}
+	trial, err := RunTrial(data, 64<<10, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Seven chunks, each compressed on its own
+	var want int
+	for off := 0; off < len(data); off += 64 << 10 {
+		chunk, err := CompressWithOptions(data[off:min(off+64<<10, len(data))], opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want += len(chunk)
+	}
+	if trial.CompressedSize != want || trial.Ratio != float64(len(data))/float64(want) || trial.Options.Codec != ZSTD {
+		t.Errorf("got %d bytes, ratio %.2f; want %d bytes", trial.CompressedSize, trial.Ratio, want)
+	}
+	if trial.CompressTime <= 0 || trial.DecompressTime <= 0 {
+		t.Errorf("times %v and %v", trial.CompressTime, trial.DecompressTime)
+	}
+
+	if _, err := RunTrial(nil, 0, opts); !errors.Is(err, ErrInvalidData) {
+		t.Errorf("expected ErrInvalidData, got %v", err)
+	}
+}
+
+func TestAutoShuffle(t *testing.T) {
+	text := bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog; "), 2000)
+	tests := []struct {
+		name     string
+		data     []byte
+		typeSize int
+		avoid    Shuffle // A mode that should lose
+	}{
+		{"floats", makeFloatData(1 << 16), 4, NoShuffle},
+		{"text", text, 1, BitShuffle},
+	}
+	for _, tt := range tests {
+		opts := Options{Codec: LZ4, Level: 5, Shuffle: AutoShuffle, TypeSize: tt.typeSize}
+		chunk, err := CompressWithOptions(tt.data, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h, err := GetInfo(chunk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		picked := h.ShuffleMode()
+		if picked == tt.avoid {
+			t.Errorf("%s: picked %s", tt.name, picked)
+		}
+		if got, err := Decompress(chunk); err != nil || !bytes.Equal(got, tt.data) {
+			t.Errorf("%s: round trip failed (%v)", tt.name, err)
+		}
+
+		// The chunk is the one the picked mode gives, by every route
+		opts.Shuffle = picked
+		want, err := CompressWithOptions(tt.data, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts.Shuffle = AutoShuffle
+		streamed, err := CompressFrom(bytes.NewReader(tt.data), int64(len(tt.data)), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoded, err := NewEncoder(opts).EncodeAll(tt.data, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for route, got := range map[string][]byte{"CompressWithOptions": chunk, "CompressFrom": streamed, "Encoder": encoded} {
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s: %s differs from %s", tt.name, route, picked)
+			}
+		}
+	}
+
+	if s, err := ParseShuffle("Auto"); err != nil || s != AutoShuffle {
+		t.Errorf("ParseShuffle(Auto) = %v, %v", s, err)
+	}
+	if err := (Options{Codec: LZ4, Level: 5, Shuffle: AutoShuffle, TypeSize: 4}).Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+	if err := (Options{Codec: LZ4, Level: 5, Shuffle: AutoShuffle + 1, TypeSize: 4}).Validate(); !errors.Is(err, ErrInvalidShuffle) {
+		t.Errorf("Validate(%d): %v", AutoShuffle+1, err)
+	}
+}
+
+func TestTypeSizeAuto(t *testing.T) {
+	// int16 and float64 series, each best shuffled at its own size
+	int16s := make([]byte, 2<<16)
+	for i := 0; i < len(int16s)/2; i++ {
+		binary.LittleEndian.PutUint16(int16s[2*i:], uint16(1000+int(300*math.Sin(float64(i)/50))))
+	}
+	float64s := make([]byte, 8<<14)
+	for i := 0; i < len(float64s)/8; i++ {
+		binary.LittleEndian.PutUint64(float64s[8*i:], math.Float64bits(math.Sin(float64(i)/500)))
+	}
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"int16", int16s, 2},
+		{"float32", makeFloatData(1 << 15), 4},
+		{"float64", float64s, 8},
+	}
+	for _, tt := range tests {
+		opts := Options{Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: TypeSizeAuto}
+		chunk, err := CompressWithOptions(tt.data, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h, err := GetInfo(chunk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if int(h.TypeSize) != tt.want {
+			t.Errorf("%s: picked type size %d, want %d", tt.name, h.TypeSize, tt.want)
+		}
+		if got, err := Decompress(chunk); err != nil || !bytes.Equal(got, tt.data) {
+			t.Errorf("%s: round trip failed (%v)", tt.name, err)
+		}
+		if len(chunk) > MaxCompressedSize(len(tt.data), opts) {
+			t.Errorf("%s: %d bytes exceed MaxCompressedSize", tt.name, len(chunk))
+		}
+
+		streamed, err := CompressFrom(bytes.NewReader(tt.data), int64(len(tt.data)), opts)
+		if err != nil || !bytes.Equal(streamed, chunk) {
+			t.Errorf("%s: CompressFrom differs (%v)", tt.name, err)
+		}
+	}
+
+	// Along with AutoShuffle, and in the text form
+	opts := Options{Codec: ZSTD, Level: 3, Shuffle: AutoShuffle, TypeSize: TypeSizeAuto}
+	if err := opts.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+	chunk, err := CompressWithOptions(float64s, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h, _ := GetInfo(chunk); h.TypeSize != 8 || h.ShuffleMode() == NoShuffle {
+		t.Errorf("picked type size %d, %s", h.TypeSize, h.ShuffleMode())
+	}
+	text, err := opts.MarshalText()
+	if err != nil || !bytes.Contains(text, []byte("typesize=auto")) {
+		t.Errorf("MarshalText: %s, %v", text, err)
+	}
+	var back Options
+	if err := back.UnmarshalText(text); err != nil || back.TypeSize != TypeSizeAuto {
+		t.Errorf("UnmarshalText: %+v, %v", back, err)
+	}
+	if _, err := NewConfig(WithTypeSize(TypeSizeAuto)); err != nil {
+		t.Errorf("WithTypeSize(TypeSizeAuto): %v", err)
+	}
+}
@@ -0,0 +1,374 @@
+package blosc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"slices"
+)
+
+// ValidationError describes why Validate rejected a chunk. It wraps one of the
+// package's sentinel errors, so errors.Is(err, ErrInvalidHeader) and friends
+// keep working.
+type ValidationError struct {
+	Field  string // Part of the chunk at fault, e.g. "Flags" or "block table"
+	Reason string // What is wrong with it
+	Err    error  // ErrInvalidVersion, ErrInvalidHeader, ErrInvalidData, ErrInvalidCodec, ErrUnsupportedFeature or ErrChecksum
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%v: %s: %s", e.Err, e.Field, e.Reason)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+func invalid(err error, field, format string, args ...any) error {
+	return &ValidationError{Field: field, Reason: fmt.Sprintf(format, args...), Err: err}
+}
+
+// Validate checks that data holds exactly one well-formed chunk without
+// decompressing it. Beyond what Decompress requires, it rejects:
+//
+//   - a NBytesComp that differs from len(data)
+//   - a zero TypeSize or BlockSize, or a BlockSize larger than the data
+//   - byte and bit shuffle flags set together outside an extended header
+//   - unknown filters in an extended header
+//   - a special-value chunk of the wrong size or with partial elements
+//   - a codec that is unknown or not registered
+//   - a memcpy chunk whose size does not match its data
+//   - a block offset table or stream sizes that do not fit the chunk
+//   - streams too short to decode to their size at the codec's largest
+//     expansion ratio, so that Decompress never sizes its output from a
+//     header the payload cannot back
+//   - blocks that do not match their checksums, in chunks written with
+//     Options.BlockChecksums
+//
+// Validate does not run the codecs, so a chunk that passes can still fail to
+// decompress if its compressed streams are corrupt. Failures are reported as
+// *ValidationError.
+func Validate(data []byte) error {
+	if len(data) < HeaderSize {
+		return invalid(ErrInvalidHeader, "header", "need %d bytes, have %d", HeaderSize, len(data))
+	}
+	header, err := ParseHeader(data)
+	if err != nil {
+		return invalid(ErrInvalidVersion, "Version", "got %d, expected %d", data[0], FormatVersion)
+	}
+
+	if int64(header.NBytesComp) != int64(len(data)) {
+		return invalid(ErrInvalidData, "NBytesComp", "header says %d bytes, have %d", header.NBytesComp, len(data))
+	}
+	if header.TypeSize == 0 {
+		return invalid(ErrInvalidHeader, "TypeSize", "must not be zero")
+	}
+	if flags := header.UnsupportedFlags(); flags != nil {
+		field := "Flags"
+		if flags[0].Offset == offsetBlosc2Flags {
+			field = "Blosc2Flags"
+		}
+		return invalid(ErrUnsupportedFeature, field, "%s bit 0x%02x set", flags[0].Name, flags[0].Mask)
+	}
+	if !header.IsExtended() && header.Flags&flagExtended == flagExtended {
+		return invalid(ErrInvalidHeader, "Flags", "byte and bit shuffle both set")
+	}
+	if header.IsExtended() {
+		if _, err := newPipeline(header.Filters, false); err != nil {
+			return invalid(ErrInvalidFilter, "Filters", "%v", err)
+		}
+	}
+	if header.Special() != SpecialNone {
+		return validateSpecial(header)
+	}
+	if header.BlockSize == 0 {
+		return invalid(ErrInvalidHeader, "BlockSize", "must not be zero")
+	}
+	if header.BlockSize > header.NBytesOrig && header.NBytesOrig > 0 {
+		return invalid(ErrInvalidHeader, "BlockSize", "%d exceeds data size %d", header.BlockSize, header.NBytesOrig)
+	}
+
+	codec := header.Codec()
+	if codec == unknownCodec {
+		return invalid(ErrInvalidCodec, "Flags", "unknown compressor code %d", header.Flags>>flagCodecShift)
+	}
+
+	if header.IsMemcpy() {
+		if int64(header.NBytesComp) != int64(header.NBytesOrig)+int64(header.Size()) {
+			return invalid(ErrInvalidData, "NBytesComp", "memcpy chunk of %d bytes has size %d", header.NBytesOrig, header.NBytesComp)
+		}
+		return nil
+	}
+
+	if _, ok := codecs[codec]; !ok {
+		return invalid(ErrInvalidCodec, "codec", "%s is not registered", codec)
+	}
+	if header.HasDict() && codec != ZSTD {
+		return invalid(ErrUnsupportedFeature, "Blosc2Flags", "dictionary for %s, only ZSTD chunks have one", codec)
+	}
+	if header.legacy {
+		if header.BlockSize != header.NBytesOrig {
+			return invalid(ErrInvalidHeader, "BlockSize", "legacy chunks hold a single block")
+		}
+		if ratio := codecRatio(codec); int64(header.NBytesOrig) > ratio*int64(len(data)-HeaderSize) {
+			return invalid(ErrInvalidData, "NBytesOrig", "%d bytes of %s stream cannot decode to %d", len(data)-HeaderSize, codec, header.NBytesOrig)
+		}
+		return nil
+	}
+	return validateBlocks(header, data)
+}
+
+// codecRatio returns the largest expansion ratio of a registered codec's
+// streams, or math.MaxInt32 for codecs that do not bound it
+func codecRatio(codec Codec) int64 {
+	if c, ok := codecs[codec].(boundedDecompressor); ok {
+		return int64(c.maxRatio())
+	}
+	return math.MaxInt32
+}
+
+// Verify checks that data holds one intact chunk: it runs Validate and the
+// checks Decompress makes of sizes the chunk's streams cannot decode to, so
+// that nothing is sized from a forged header, then decodes every stream in
+// turn into one scratch buffer the size of the largest stream, so that
+// streams the codecs reject, or that decode to the wrong size, are found
+// without holding the decompressed output or even a whole block. The filters
+// are not undone. Special-value and memcpy chunks have no codec streams and
+// need only Validate; legacy go-blosc chunks are a single stream, decoded
+// without unshuffling it.
+//
+// Unless written with Options.BlockChecksums, whose checksums Validate
+// checks, chunks carry no checksums of their own, so damage that leaves
+// every stream decodable, such as flipped bits in a stored stream, goes
+// unnoticed; frames check the checksums of their header and trailer when
+// opened. Structural failures are reported as *ValidationError and decoding
+// failures as *BloscError.
+func Verify(data []byte) error {
+	if err := Validate(data); err != nil {
+		return err
+	}
+	header, typeSize, err := checkChunk(data, 0, -1)
+	if err != nil {
+		return err
+	}
+	switch {
+	case header.IsMemcpy() || header.Special() != SpecialNone:
+		return nil
+	case header.legacy:
+		return verifyLegacy(header, data)
+	default:
+		return verifyBlocks(header, data, typeSize)
+	}
+}
+
+// verifyLegacy decodes the single stream of a go-blosc 1.0.x chunk, whose
+// size checkChunk has bounded by the stream's length
+func verifyLegacy(header *Header, data []byte) error {
+	decompressor, ok := codecs[header.Codec()]
+	if !ok {
+		return headerError(offsetVersionLZ, fmt.Errorf("%w: %s", ErrInvalidCodec, header.Codec()))
+	}
+	buf := make([]byte, header.NBytesOrig)
+	n, err := codecDecompressInto(decompressor, buf, data[HeaderSize:header.NBytesComp])
+	if err != nil {
+		return blockError(StageCodec, 0, HeaderSize, fmt.Errorf("%w: %w", ErrDecompressionFailed, err))
+	}
+	if n != len(buf) {
+		return blockError(StageCodec, 0, HeaderSize, fmt.Errorf("%w: got %d, expected %d", ErrSizeMismatch, n, len(buf)))
+	}
+	return nil
+}
+
+// verifyBlocks decodes the streams of a spec chunk one at a time into a
+// buffer reused for all of them
+func verifyBlocks(header *Header, data []byte, typeSize int) error {
+	d, err := newBlockDecoder(header, data, typeSize, nil)
+	if err != nil {
+		return err
+	}
+	if err := d.checkTable(); err != nil {
+		return err
+	}
+	var buf []byte
+	for i := 0; i < d.nblocks; i++ {
+		n := d.blockLen(i)
+		streamSize := n / header.blockStreams(n)
+		buf = slices.Grow(buf[:0], streamSize)[:streamSize]
+		if _, err := d.decodeStreams(i, n, func(int) []byte { return buf }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyRoundTrip compresses data with opts, decompresses the chunk and
+// checks that it gives back data byte for byte, for pipelines writing data
+// that cannot be recreated that want an end-to-end check of the codecs,
+// filters and hardware before committing it. Compressing the same data with
+// the same options gives the same chunk, so CompressWithOptions returns the
+// chunk checked. Errors from compression and decompression are returned as
+// they are, and output that differs fails with ErrRoundTrip. Options with a
+// Prefilter fail with ErrInvalidOption, since their chunks decompress to
+// the filtered data.
+func VerifyRoundTrip(data []byte, opts Options) error {
+	return VerifyRoundTripSample(data, opts, len(data))
+}
+
+// VerifyRoundTripSample is VerifyRoundTrip comparing only about sampleSize
+// bytes of the output, in runs of at most a block spread evenly from the
+// start of data to the end, which GetItems decodes alone. The whole chunk
+// is still compressed and checked with Validate, which also checks any
+// block checksums. A sampleSize of 0 or at least len(data) compares all of
+// it.
+func VerifyRoundTripSample(data []byte, opts Options, sampleSize int) error {
+	if opts.prefilter() != nil {
+		return fmt.Errorf("%w: a Prefilter changes the data a chunk decompresses to", ErrInvalidOption)
+	}
+	chunk, err := CompressWithOptions(data, opts)
+	if err != nil {
+		return err
+	}
+	header, err := ParseHeader(chunk)
+	if err != nil {
+		return err
+	}
+	size := max(int(header.TypeSize), 1)
+	if sampleSize <= 0 || sampleSize >= len(data) || len(data) < 2*size {
+		got, err := Decompress(chunk)
+		if err != nil {
+			return err
+		}
+		return compareRoundTrip(data, got, 0)
+	}
+	if err := Validate(chunk); err != nil {
+		return err
+	}
+
+	// Runs of a block, or the sample if smaller, between the first and
+	// the last element
+	count := len(data) / size
+	run := min(max(min(sampleSize, int(header.BlockSize))/size, 1), count)
+	runs := (sampleSize + run*size - 1) / (run * size)
+	for i := 0; i < runs; i++ {
+		start := 0
+		if runs > 1 {
+			start = i * (count - run) / (runs - 1)
+		}
+		got, err := GetItems(chunk, start, run)
+		if err != nil {
+			return err
+		}
+		if err := compareRoundTrip(data[start*size:(start+run)*size], got, start*size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compareRoundTrip checks that got equals want, which starts at offset in
+// the data compressed
+func compareRoundTrip(want, got []byte, offset int) error {
+	if bytes.Equal(want, got) {
+		return nil
+	}
+	if len(got) != len(want) {
+		return fmt.Errorf("%w: %d bytes decompressed from %d at byte %d", ErrRoundTrip, len(got), len(want), offset)
+	}
+	i := 0
+	for want[i] == got[i] {
+		i++
+	}
+	return fmt.Errorf("%w: byte %d is 0x%02x, not 0x%02x", ErrRoundTrip, offset+i, got[i], want[i])
+}
+
+// validateSpecial checks a special-value chunk, which has no blocks
+func validateSpecial(header *Header) error {
+	kind := header.Special()
+	if kind > SpecialUninit {
+		return invalid(ErrInvalidHeader, "Blosc2Flags", "unknown special value kind %d", kind)
+	}
+	typeSize := int64(header.TypeSize)
+	if int64(header.NBytesOrig)%typeSize != 0 {
+		return invalid(ErrInvalidHeader, "TypeSize", "%d bytes are not whole elements of %d", header.NBytesOrig, typeSize)
+	}
+	if kind == SpecialNaN && typeSize != 4 && typeSize != 8 {
+		return invalid(ErrInvalidHeader, "TypeSize", "NaN elements of %d bytes", typeSize)
+	}
+	size := int64(ExtendedHeaderSize)
+	if kind == SpecialValue {
+		size += typeSize
+	}
+	if int64(header.NBytesComp) != size {
+		return invalid(ErrInvalidData, "NBytesComp", "special value chunk has size %d, expected %d", header.NBytesComp, size)
+	}
+	return nil
+}
+
+// validateBlocks walks the block offset table and stream sizes of a spec chunk.
+func validateBlocks(header *Header, chunk []byte) error {
+	nblocks, _ := header.numBlocks()
+	hsize := header.Size()
+	tableEnd := hsize + 4*nblocks
+	if tableEnd > len(chunk) {
+		return invalid(ErrInvalidData, "block table", "%d entries do not fit in %d bytes", nblocks, len(chunk))
+	}
+
+	var sums []byte
+	if header.HasBlockChecksums() {
+		if tableEnd+4*nblocks > len(chunk) {
+			return invalid(ErrInvalidData, "block checksums", "%d checksums do not fit in %d bytes", nblocks, len(chunk))
+		}
+		chunk, sums = chunk[:len(chunk)-4*nblocks], chunk[len(chunk)-4*nblocks:]
+	}
+
+	nbytes := int(header.NBytesOrig)
+	blockSize := int(header.BlockSize)
+	ratio := codecRatio(header.Codec())
+	end := tableEnd
+
+	for i := 0; i < nblocks; i++ {
+		start := int(binary.LittleEndian.Uint32(chunk[hsize+4*i:]))
+		if start != end {
+			return invalid(ErrInvalidData, "block table", "block %d starts at %d, expected %d", i, start, end)
+		}
+
+		bsize := min(blockSize, nbytes-i*blockSize)
+		nstreams := header.blockStreams(bsize)
+		streamSize := bsize / nstreams
+
+		for j := 0; j < nstreams; j++ {
+			if end+4 > len(chunk) {
+				return invalid(ErrInvalidData, "block stream", "block %d stream %d size truncated at %d", i, j, end)
+			}
+			raw := binary.LittleEndian.Uint32(chunk[end:])
+			end += 4
+			if run := int32(raw); header.IsExtended() && run <= 0 && run >= -255 {
+				continue // Run of a repeated byte, with no payload
+			}
+			if raw == 0 || uint64(raw) > uint64(streamSize) {
+				return invalid(ErrInvalidData, "block stream", "block %d stream %d has size %d, stream holds %d bytes", i, j, raw, streamSize)
+			}
+			size := int(raw)
+			if size < streamSize && int64(size)*ratio < int64(streamSize) {
+				return invalid(ErrInvalidData, "block stream", "block %d stream %d of %d bytes cannot decode to %d with %s", i, j, size, streamSize, header.Codec())
+			}
+			if size > len(chunk)-end {
+				return invalid(ErrInvalidData, "block stream", "block %d stream %d runs past the chunk", i, j)
+			}
+			end += size
+		}
+		if sums == nil {
+			continue
+		}
+		if crc, sum := crc32.Checksum(chunk[start:end], castagnoli), binary.LittleEndian.Uint32(sums[4*i:]); crc != sum {
+			return invalid(ErrChecksum, "block checksum", "block %d has checksum %08x, stored %08x", i, crc, sum)
+		}
+	}
+
+	if end != len(chunk) {
+		return invalid(ErrInvalidData, "NBytesComp", "%d trailing bytes after the last block", len(chunk)-end)
+	}
+	return nil
+}
@@ -0,0 +1,353 @@
+package blosc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateAcceptsCompressedChunks(t *testing.T) {
+	inputs := map[string][]byte{
+		"compressible": makeTestData(200000),
+		"small":        makeTestData(50),
+		"random":       randomBytes(5000),
+	}
+
+	for name, data := range inputs {
+		for _, codec := range []Codec{LZ4, LZ4HC, Snappy, ZLIB, ZSTD} {
+			for _, shuffle := range []Shuffle{NoShuffle, Shuffle1, BitShuffle} {
+				for _, legacy := range []bool{false, true} {
+					opts := Options{Codec: codec, Level: 5, Shuffle: shuffle, TypeSize: 4, BlockSize: 16384, LegacyFormat: legacy}
+					compressed, err := CompressWithOptions(data, opts)
+					if err != nil {
+						t.Fatalf("compress failed: %v", err)
+					}
+					if err := Validate(compressed); err != nil {
+						t.Errorf("%s/%s/%s/legacy=%v: %v", name, codec, shuffle, legacy, err)
+					}
+					if err := Verify(compressed); err != nil {
+						t.Errorf("%s/%s/%s/legacy=%v: Verify: %v", name, codec, shuffle, legacy, err)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestValidateAcceptsInteropFixtures(t *testing.T) {
+	dirs, _ := filepath.Glob(filepath.Join("testdata", "interop", "*"))
+	for _, dir := range dirs {
+		fixtures, err := LoadFixtures(dir)
+		if err != nil {
+			continue
+		}
+		for _, f := range fixtures {
+			if _, ok := GetCodec(f.Codec); !ok {
+				continue
+			}
+			if err := Validate(f.Chunk); err != nil {
+				t.Errorf("%s/%s: %v", dir, f.Name, err)
+			}
+		}
+	}
+}
+
+func TestValidateRejects(t *testing.T) {
+	data := makeTestData(20000)
+	good, err := CompressWithOptions(data, Options{Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: 4, BlockSize: 4096})
+	if err != nil {
+		t.Fatalf("compress failed: %v", err)
+	}
+	memcpy, err := Compress(randomBytes(1000), LZ4, 5, NoShuffle, 1)
+	if err != nil {
+		t.Fatalf("compress failed: %v", err)
+	}
+	legacy, err := CompressWithOptions(data, Options{Codec: LZ4, Level: 5, TypeSize: 4, LegacyFormat: true})
+	if err != nil {
+		t.Fatalf("compress failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		base    []byte
+		corrupt func(c []byte) []byte
+		field   string
+		want    error
+	}{
+		{"short", good, func(c []byte) []byte { return c[:10] }, "header", ErrInvalidHeader},
+		{"version", good, func(c []byte) []byte { c[0] = 9; return c }, "Version", ErrInvalidVersion},
+		{"truncated", good, func(c []byte) []byte { return c[:len(c)-1] }, "NBytesComp", ErrInvalidData},
+		{"trailing", good, func(c []byte) []byte { return append(c, 0) }, "NBytesComp", ErrInvalidData},
+		{"typesize zero", good, func(c []byte) []byte { c[3] = 0; return c }, "TypeSize", ErrInvalidHeader},
+		{"both shuffles", good, func(c []byte) []byte { c[2] |= flagBitShuffle; return c }, "Flags", ErrInvalidHeader},
+		{"reserved flag", good, func(c []byte) []byte { c[2] |= flagReserved; return c }, "Flags", ErrUnsupportedFeature},
+		{"unknown compressor", good, func(c []byte) []byte { c[2] |= 7 << flagCodecShift; return c }, "Flags", ErrInvalidCodec},
+		{"block size zero", good, func(c []byte) []byte { binary.LittleEndian.PutUint32(c[8:], 0); return c }, "BlockSize", ErrInvalidHeader},
+		{"block size too large", good, func(c []byte) []byte { binary.LittleEndian.PutUint32(c[8:], 40000); return c }, "BlockSize", ErrInvalidHeader},
+		{"block offset", good, func(c []byte) []byte { c[HeaderSize+4]++; return c }, "block table", ErrInvalidData},
+		{"stream size", good, func(c []byte) []byte {
+			start := binary.LittleEndian.Uint32(c[HeaderSize:])
+			binary.LittleEndian.PutUint32(c[start:], 1<<20)
+			return c
+		}, "block stream", ErrInvalidData},
+		{"stream expansion", good, func(c []byte) []byte {
+			start := binary.LittleEndian.Uint32(c[HeaderSize:])
+			binary.LittleEndian.PutUint32(c[start:], 1)
+			return c
+		}, "block stream", ErrInvalidData},
+		{"legacy expansion", legacy, func(c []byte) []byte {
+			binary.LittleEndian.PutUint32(c[4:], 1<<30)
+			binary.LittleEndian.PutUint32(c[8:], 1<<30)
+			return c
+		}, "NBytesOrig", ErrInvalidData},
+		{"memcpy size", memcpy, func(c []byte) []byte { binary.LittleEndian.PutUint32(c[4:], 1001); return c }, "NBytesComp", ErrInvalidData},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunk := tt.corrupt(append([]byte(nil), tt.base...))
+			err := Validate(chunk)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("expected *ValidationError, got %T", err)
+			}
+			if verr.Field != tt.field {
+				t.Errorf("Field = %q, want %q (%v)", verr.Field, tt.field, err)
+			}
+		})
+	}
+}
+
+func TestUnsupportedFlags(t *testing.T) {
+	data := makeTestData(20000)
+	extended, err := CompressWithOptions(data, Options{Codec: LZ4, Level: 5, TypeSize: 4, BlockChecksums: true})
+	if err != nil {
+		t.Fatalf("compress failed: %v", err)
+	}
+	if h, _ := ParseHeader(extended); !h.IsExtended() {
+		t.Fatalf("expected an extended header, got %+v", h)
+	}
+	memcpy, err := Compress(randomBytes(1000), LZ4, 5, NoShuffle, 1)
+	if err != nil {
+		t.Fatalf("compress failed: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		base   []byte
+		offset int
+		set    uint8
+		flag   string
+	}{
+		{"all flags", memcpy, offsetFlags, 0xFF, "reserved"},
+		{"reserved", extended, offsetFlags, flagReserved, "reserved"},
+		{"blosc2 reserved", extended, offsetBlosc2Flags, blosc2FlagReserved, "reserved"},
+		{"instrumented", extended, offsetBlosc2Flags, blosc2FlagInstr, "instrumented"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunk := append([]byte(nil), tt.base...)
+			chunk[tt.offset] |= tt.set
+			h, err := ParseHeader(chunk)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if flags := h.UnsupportedFlags(); len(flags) == 0 || flags[0].Name != tt.flag || flags[0].Offset != tt.offset {
+				t.Errorf("UnsupportedFlags = %+v, want %s at byte %d", flags, tt.flag, tt.offset)
+			}
+			if _, err := Decompress(chunk); !errors.Is(err, ErrUnsupportedFeature) {
+				t.Errorf("Decompress: expected ErrUnsupportedFeature, got %v", err)
+			}
+			if err := Validate(chunk); !errors.Is(err, ErrUnsupportedFeature) {
+				t.Errorf("Validate: expected ErrUnsupportedFeature, got %v", err)
+			}
+		})
+	}
+
+	if h, _ := ParseHeader(extended); h.UnsupportedFlags() != nil {
+		t.Errorf("supported chunk reports %+v", h.UnsupportedFlags())
+	}
+	// Every bit of both bytes is accounted for, once
+	for _, offset := range []int{offsetFlags, offsetBlosc2Flags} {
+		var bits uint8
+		for _, f := range HeaderFlags() {
+			if f.Offset != offset {
+				continue
+			}
+			if bits&f.Mask != 0 {
+				t.Errorf("%s overlaps another flag of byte %d", f.Name, offset)
+			}
+			bits |= f.Mask
+		}
+		if bits != 0xFF {
+			t.Errorf("byte %d flags cover 0x%02x, want 0xff", offset, bits)
+		}
+	}
+}
+
+func TestValidateUnregisteredCodec(t *testing.T) {
+	chunk, err := Compress(makeTestData(20000), BloscLZ, 5, Shuffle1, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bloscLZ := codecs[BloscLZ]
+	delete(codecs, BloscLZ)
+	defer func() { codecs[BloscLZ] = bloscLZ }()
+
+	err = Validate(chunk)
+	var verr *ValidationError
+	if !errors.Is(err, ErrInvalidCodec) || !errors.As(err, &verr) || verr.Field != "codec" {
+		t.Errorf("expected ErrInvalidCodec in field codec, got %v", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	data := makeTestData(100000)
+	chunk, err := CompressWithOptions(data, Options{Codec: ZSTD, Level: 3, TypeSize: 4, BlockSize: 16384})
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := CompressWithOptions(data, Options{Codec: ZSTD, Level: 3, TypeSize: 4, LegacyFormat: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	zeros, err := NewZeroChunk(1<<20, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range [][]byte{chunk, legacy, zeros} {
+		if err := Verify(c); err != nil {
+			t.Errorf("intact chunk: %v", err)
+		}
+	}
+
+	// Damaged codec streams pass Validate but not Verify
+	damaged := bytes.Clone(chunk)
+	damaged[binary.LittleEndian.Uint32(damaged[HeaderSize+4*2:])+4] ^= 0xff
+	damagedLegacy := bytes.Clone(legacy)
+	damagedLegacy[HeaderSize] ^= 0xff
+	for name, tt := range map[string]struct {
+		chunk []byte
+		block int
+	}{
+		"spec":   {damaged, 2},
+		"legacy": {damagedLegacy, 0},
+	} {
+		if err := Validate(tt.chunk); err != nil {
+			t.Fatalf("%s: Validate: %v", name, err)
+		}
+		var berr *BloscError
+		if err := Verify(tt.chunk); !errors.As(err, &berr) || berr.Block != tt.block {
+			t.Errorf("%s: expected a BloscError for block %d, got %v", name, tt.block, err)
+		}
+	}
+
+	var verr *ValidationError
+	if err := Verify(chunk[:len(chunk)-1]); !errors.As(err, &verr) {
+		t.Errorf("truncated chunk: expected a ValidationError, got %v", err)
+	}
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	x := uint64(0x9E3779B97F4A7C15)
+	for i := range b {
+		x ^= x << 13
+		x ^= x >> 7
+		x ^= x << 17
+		b[i] = byte(x)
+	}
+	return b
+}
+
+// flipCodec decompresses with codec and then flips a bit of the output, as
+// a failing codec or faulty memory might
+type flipCodec struct {
+	CodecInterface
+	at int
+}
+
+func (c flipCodec) Decompress(data []byte, expectedSize int) ([]byte, error) {
+	out, err := c.CodecInterface.Decompress(data, expectedSize)
+	if err == nil && len(out) > c.at {
+		out[c.at] ^= 1
+	}
+	return out, err
+}
+
+func TestVerifyAllocs(t *testing.T) {
+	// Streams are decoded one at a time into a single reused buffer
+	data := makeTestData(4 << 20)
+	const blockSize = 1 << 20
+	chunk, err := CompressWithOptions(data, Options{Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: 4, BlockSize: blockSize})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if allocated := allocBytes(func() { err = Verify(chunk) }); err != nil || allocated >= blockSize {
+		t.Errorf("Verify: %v, allocated %d bytes for blocks of %d", err, allocated, blockSize)
+	}
+
+	// A header claiming far more than the streams can hold is rejected
+	// before anything is sized from it
+	header := Header{
+		Version:    FormatVersion,
+		VersionLZ:  codecFormatVersion,
+		Flags:      flagDontSplit | formatLZ4<<flagCodecShift,
+		TypeSize:   1,
+		NBytesOrig: 1 << 30,
+		BlockSize:  1 << 30,
+	}
+	bomb := binary.LittleEndian.AppendUint32(header.Bytes(), HeaderSize+4)
+	bomb = binary.LittleEndian.AppendUint32(bomb, 4)
+	bomb = append(bomb, 1, 2, 3, 4)
+	binary.LittleEndian.PutUint32(bomb[12:16], uint32(len(bomb)))
+	if allocated := allocBytes(func() { err = Verify(bomb) }); !errors.Is(err, ErrInvalidData) || allocated > 1<<20 {
+		t.Errorf("%d-byte chunk claiming 1 GiB: %v, allocated %d bytes", len(bomb), err, allocated)
+	}
+}
+
+func TestVerifyRoundTrip(t *testing.T) {
+	data := makeTestData(100000)
+	for _, opts := range []Options{
+		{Codec: ZSTD, Level: 5, Shuffle: Shuffle1, TypeSize: 4},
+		{Codec: LZ4, Level: 9, Shuffle: BitShuffle, TypeSize: 8, BlockSize: 8192, BlockChecksums: true},
+		{Codec: Snappy, Level: 1, TypeSize: 4, LegacyFormat: true},
+		{Codec: ZLIB, Level: 0, Shuffle: Shuffle1, TypeSize: 4},
+	} {
+		for _, sample := range []int{0, 1, 1000, 30000, len(data)} {
+			if err := VerifyRoundTripSample(data, opts, sample); err != nil {
+				t.Errorf("%v sampling %d bytes: %v", opts.Codec, sample, err)
+			}
+		}
+	}
+	if err := VerifyRoundTrip(make([]byte, 1<<16), Options{Codec: LZ4, Level: 5, TypeSize: 8, SpecialValues: true}); err != nil {
+		t.Errorf("special-value chunk: %v", err)
+	}
+	if err := VerifyRoundTrip(randomBytes(50000), Options{Codec: LZ4, Level: 5, TypeSize: 4}); err != nil {
+		t.Errorf("memcpy chunk: %v", err)
+	}
+	if err := VerifyRoundTrip(nil, Options{Codec: LZ4, Level: 5}); !errors.Is(err, ErrInvalidData) {
+		t.Errorf("no data: %v", err)
+	}
+	prefilter := Options{Codec: LZ4, Level: 5, TypeSize: 4, Hooks: &Hooks{Prefilter: func([]byte, int) {}}}
+	if err := VerifyRoundTrip(data, prefilter); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Prefilter: %v", err)
+	}
+
+	lz4Codec := codecs[LZ4]
+	defer func() { codecs[LZ4] = lz4Codec }()
+	codecs[LZ4] = flipCodec{lz4Codec, 7}
+	opts := Options{Codec: LZ4, Level: 5, TypeSize: 4, BlockSize: 16384}
+	if err := VerifyRoundTrip(data, opts); !errors.Is(err, ErrRoundTrip) || !strings.Contains(err.Error(), "byte 7 ") {
+		t.Errorf("flipped bit: %v", err)
+	}
+	if err := VerifyRoundTripSample(data, opts, 4096); !errors.Is(err, ErrRoundTrip) {
+		t.Errorf("flipped bit, sampled: %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+// Package vdbblosc reads and writes the Blosc-compressed buffers of OpenVDB
+// files, so that Go VDB readers can decode leaf node data.
+//
+// OpenVDB stores each compressed buffer as a little-endian int64 size, then
+// that many bytes of a c-blosc chunk. A size of zero or less means the
+// buffer was stored as is, and its magnitude is the number of raw bytes that
+// follow. The chunk may use any c-blosc codec, including BloscLZ, c-blosc's
+// default, and split or unsplit blocks; every layout decodes.
+package vdbblosc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	blosc "github.com/mrjoshuak/go-blosc"
+)
+
+// ErrInvalidBuffer indicates a buffer whose size prefix or contents do not
+// match the size the reader expects.
+var ErrInvalidBuffer = errors.New("vdbblosc: invalid compressed buffer")
+
+// prefixSize is the size of the size prefix
+const prefixSize = 8
+
+// ReadBuffer reads one buffer that decompresses to n bytes from r, as
+// OpenVDB's bloscFromStream does. The caller knows n from the grid: the
+// number of values in the leaf buffer times their size.
+func ReadBuffer(r io.Reader, n int) ([]byte, error) {
+	var prefix [prefixSize]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return nil, noEOF(err)
+	}
+	size := int64(binary.LittleEndian.Uint64(prefix[:]))
+	if size <= 0 {
+		if -size != int64(n) {
+			return nil, fmt.Errorf("%w: %d raw bytes, expected %d", ErrInvalidBuffer, -size, n)
+		}
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, noEOF(err)
+		}
+		return buf, nil
+	}
+
+	// c-blosc chunks never exceed their data plus a header, as data that
+	// does not compress is copied
+	if size > int64(n)+blosc.HeaderSize {
+		return nil, fmt.Errorf("%w: %d byte chunk for %d bytes", ErrInvalidBuffer, size, n)
+	}
+	chunk := make([]byte, size)
+	if _, err := io.ReadFull(r, chunk); err != nil {
+		return nil, noEOF(err)
+	}
+	buf, err := blosc.DecompressLimited(chunk, n)
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) != n {
+		return nil, fmt.Errorf("%w: chunk holds %d bytes, expected %d", ErrInvalidBuffer, len(buf), n)
+	}
+	return buf, nil
+}
+
+// noEOF reports a buffer that ends early as io.ErrUnexpectedEOF
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// WriteBuffer writes data to w as OpenVDB's bloscToStream does: compressed
+// with LZ4 at level 9 and a byte shuffle of elements of typeSize bytes, in
+// a single block, or stored as is when that does not pay.
+func WriteBuffer(w io.Writer, data []byte, typeSize int) (int64, error) {
+	size := -int64(len(data))
+	body := data
+	if len(data) > 0 {
+		chunk, err := blosc.CompressWithOptions(data, blosc.Options{
+			Codec:     blosc.LZ4,
+			Level:     9,
+			Shuffle:   blosc.Shuffle1,
+			TypeSize:  typeSize,
+			BlockSize: len(data),
+		})
+		if err != nil {
+			return 0, err
+		}
+		if len(chunk) < len(data) {
+			size, body = int64(len(chunk)), chunk
+		}
+	}
+
+	var prefix [prefixSize]byte
+	binary.LittleEndian.PutUint64(prefix[:], uint64(size))
+	n, err := w.Write(prefix[:])
+	if err != nil {
+		return int64(n), err
+	}
+	m, err := w.Write(body)
+	return int64(n + m), err
+}
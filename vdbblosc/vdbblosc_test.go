@@ -0,0 +1,108 @@
+package vdbblosc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"testing"
+
+	blosc "github.com/mrjoshuak/go-blosc"
+)
+
+// leafValues returns the float32 values of an 8x8x8 leaf node
+func leafValues() []byte {
+	buf := make([]byte, 4*512)
+	for i := 0; i < 512; i++ {
+		v := float32(math.Sin(float64(i) / 40))
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(v))
+	}
+	return buf
+}
+
+// prefixed returns body after an OpenVDB size prefix
+func prefixed(size int64, body []byte) []byte {
+	out := binary.LittleEndian.AppendUint64(nil, uint64(size))
+	return append(out, body...)
+}
+
+func TestRoundTrip(t *testing.T) {
+	leaf := leafValues()
+	var stream bytes.Buffer
+	for _, buf := range [][]byte{leaf, {1, 2, 3}, nil, leaf[:1000]} {
+		n, err := WriteBuffer(&stream, buf, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n < prefixSize+1 && len(buf) > 0 {
+			t.Errorf("wrote %d bytes", n)
+		}
+	}
+	if stream.Len() >= len(leaf)+1000 {
+		t.Errorf("stream of %d bytes did not compress", stream.Len())
+	}
+
+	r := bytes.NewReader(stream.Bytes())
+	for _, want := range [][]byte{leaf, {1, 2, 3}, {}, leaf[:1000]} {
+		got, err := ReadBuffer(r, len(want))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("buffer of %d bytes differs", len(want))
+		}
+	}
+	if r.Len() != 0 {
+		t.Errorf("%d bytes left unread", r.Len())
+	}
+}
+
+// TestReadCBloscDefaults reads buffers compressed as c-blosc does by
+// default, with BloscLZ and split blocks, and with each other codec.
+func TestReadCBloscDefaults(t *testing.T) {
+	leaf := leafValues()
+	for _, codec := range []blosc.Codec{blosc.BloscLZ, blosc.LZ4, blosc.ZLIB, blosc.ZSTD} {
+		if _, ok := blosc.GetCodec(codec); !ok {
+			continue // Left out by a build tag
+		}
+		chunk, err := blosc.CompressWithOptions(leaf, blosc.Options{Codec: codec, Level: 9, Shuffle: blosc.Shuffle1, TypeSize: 4})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ReadBuffer(bytes.NewReader(prefixed(int64(len(chunk)), chunk)), len(leaf))
+		if err != nil {
+			t.Fatalf("%s: %v", codec, err)
+		}
+		if !bytes.Equal(got, leaf) {
+			t.Errorf("%s: buffer differs", codec)
+		}
+	}
+}
+
+func TestReadBufferErrors(t *testing.T) {
+	leaf := leafValues()
+	chunk, err := blosc.Compress(leaf, blosc.BloscLZ, 9, blosc.Shuffle1, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		data []byte
+		n    int
+		want error
+	}{
+		{"raw size", prefixed(-3, []byte{1, 2, 3}), 4, ErrInvalidBuffer},
+		{"chunk size", prefixed(int64(len(chunk)), chunk), 100, ErrInvalidBuffer},
+		{"oversized chunk", prefixed(1<<40, nil), len(leaf), ErrInvalidBuffer},
+		{"short prefix", []byte{1, 2}, 4, io.ErrUnexpectedEOF},
+		{"no prefix", nil, 4, io.ErrUnexpectedEOF},
+		{"truncated raw", prefixed(-4, []byte{1, 2}), 4, io.ErrUnexpectedEOF},
+		{"truncated chunk", prefixed(int64(len(chunk)), chunk[:10]), len(leaf), io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		if _, err := ReadBuffer(bytes.NewReader(tt.data), tt.n); !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
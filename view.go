@@ -0,0 +1,57 @@
+package blosc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// viewElem lists the element types decompressed data can be viewed as
+type viewElem interface {
+	float32 | float64 | int32
+}
+
+// nativeLittleEndian reports whether the host stores numbers as Blosc data
+// from little-endian writers does
+var nativeLittleEndian = binary.NativeEndian.Uint16([]byte{1, 0}) == 1
+
+// AsFloat32s returns b, such as the output of Decompress, as little-endian
+// float32 values. Built with the blosc_unsafe tag, the result shares memory
+// with b when the host is little-endian and b is suitably aligned, so
+// writing to one changes the other; otherwise the values are copied. Use
+// ZeroCopyViews to tell the builds apart.
+func AsFloat32s(b []byte) ([]float32, error) {
+	return view(b, 4, func(p []byte) float32 {
+		return math.Float32frombits(binary.LittleEndian.Uint32(p))
+	})
+}
+
+// AsFloat64s returns b as little-endian float64 values, as AsFloat32s does.
+func AsFloat64s(b []byte) ([]float64, error) {
+	return view(b, 8, func(p []byte) float64 {
+		return math.Float64frombits(binary.LittleEndian.Uint64(p))
+	})
+}
+
+// AsInt32s returns b as little-endian int32 values, as AsFloat32s does.
+func AsInt32s(b []byte) ([]int32, error) {
+	return view(b, 4, func(p []byte) int32 {
+		return int32(binary.LittleEndian.Uint32(p))
+	})
+}
+
+// view returns b as elements of size bytes, aliasing b where the build
+// allows and decoding each element with decode otherwise
+func view[T viewElem](b []byte, size int, decode func([]byte) T) ([]T, error) {
+	if len(b)%size != 0 {
+		return nil, fmt.Errorf("%w: %d bytes is not a whole number of %d-byte elements", ErrSizeMismatch, len(b), size)
+	}
+	if v, ok := zeroCopy[T](b, size); ok {
+		return v, nil
+	}
+	v := make([]T, len(b)/size)
+	for i := range v {
+		v[i] = decode(b[i*size:])
+	}
+	return v, nil
+}
@@ -0,0 +1,12 @@
+//go:build !blosc_unsafe
+
+package blosc
+
+// ZeroCopyViews reports whether AsFloat32s and the other views may alias
+// their input. It is set by building with the blosc_unsafe tag.
+const ZeroCopyViews = false
+
+// zeroCopy never aliases b without the blosc_unsafe tag
+func zeroCopy[T viewElem](b []byte, size int) ([]T, bool) {
+	return nil, false
+}
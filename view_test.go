@@ -0,0 +1,62 @@
+package blosc
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+	"unsafe"
+)
+
+func TestViews(t *testing.T) {
+	data := makeFloatData(4096)
+	chunk, err := Compress(data, LZ4, 5, Shuffle1, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := Decompress(chunk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f32, err := AsFloat32s(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i32, _ := AsInt32s(out)
+	f64, _ := AsFloat64s(out)
+	if len(f32) != len(out)/4 || len(i32) != len(out)/4 || len(f64) != len(out)/8 {
+		t.Fatalf("lengths %d, %d, %d for %d bytes", len(f32), len(i32), len(f64), len(out))
+	}
+	for i := range f32 {
+		bits := binary.LittleEndian.Uint32(out[4*i:])
+		if math.Float32bits(f32[i]) != bits || uint32(i32[i]) != bits {
+			t.Fatalf("element %d: %v, %d, want bits %#x", i, f32[i], i32[i], bits)
+		}
+	}
+	for i := range f64 {
+		if math.Float64bits(f64[i]) != binary.LittleEndian.Uint64(out[8*i:]) {
+			t.Fatalf("float64 element %d: %v", i, f64[i])
+		}
+	}
+
+	// Decompressed buffers are aligned, so views alias them when allowed
+	shared := unsafe.Pointer(unsafe.SliceData(f32)) == unsafe.Pointer(unsafe.SliceData(out))
+	if shared != (ZeroCopyViews && nativeLittleEndian) {
+		t.Errorf("view shares memory: %v, ZeroCopyViews %v", shared, ZeroCopyViews)
+	}
+
+	// Misaligned input is copied and still decodes
+	odd := make([]byte, 1+8)
+	binary.LittleEndian.PutUint64(odd[1:], math.Float64bits(1.5))
+	if v, err := AsFloat64s(odd[1:]); err != nil || v[0] != 1.5 {
+		t.Errorf("misaligned: %v, %v", v, err)
+	}
+
+	if v, err := AsInt32s(nil); err != nil || len(v) != 0 {
+		t.Errorf("empty: %v, %v", v, err)
+	}
+	if _, err := AsFloat64s(out[:12]); !errors.Is(err, ErrSizeMismatch) {
+		t.Errorf("partial element: got %v", err)
+	}
+}
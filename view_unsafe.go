@@ -0,0 +1,22 @@
+//go:build blosc_unsafe
+
+package blosc
+
+import "unsafe"
+
+// ZeroCopyViews reports whether AsFloat32s and the other views may alias
+// their input. It is set by building with the blosc_unsafe tag.
+const ZeroCopyViews = true
+
+// zeroCopy returns b reinterpreted as elements of size bytes, if the host
+// byte order and the alignment of b allow it
+func zeroCopy[T viewElem](b []byte, size int) ([]T, bool) {
+	if !nativeLittleEndian || len(b) == 0 {
+		return nil, false
+	}
+	p := unsafe.Pointer(unsafe.SliceData(b))
+	if uintptr(p)%unsafe.Alignof(*new(T)) != 0 {
+		return nil, false
+	}
+	return unsafe.Slice((*T)(p), len(b)/size), true
+}
@@ -0,0 +1,124 @@
+package blosc
+
+import "io"
+
+// DefaultChunkSize is the chunk size NewWriter uses.
+const DefaultChunkSize = 1 << 20
+
+// Writer compresses a byte stream into back-to-back Blosc chunks. Each chunk
+// holds ChunkSize bytes of input, except the last one written by Flush or
+// Close. Since every chunk is self-describing, the output can be decoded one
+// chunk at a time by advancing NBytesComp bytes, as MultiChunkReader does.
+type Writer struct {
+	w    io.Writer
+	enc  chunkEncoder
+	buf  []byte
+	size int
+	err  error
+}
+
+// NewWriter returns a Writer that compresses chunks of DefaultChunkSize bytes
+// with opts.
+func NewWriter(w io.Writer, opts Options) *Writer {
+	return NewWriterSize(w, opts, DefaultChunkSize)
+}
+
+// NewWriterSize returns a Writer that compresses chunks of size bytes with
+// opts. The size is limited to MaxBufferSize and rounded down to a multiple
+// of opts.TypeSize, and sizes of 0 or less select DefaultChunkSize.
+func NewWriterSize(w io.Writer, opts Options, size int) *Writer {
+	return &Writer{w: w, enc: chunkEncoder{opts: opts}, size: chunkSizeFor(size, opts.TypeSize)}
+}
+
+// chunkSizeFor returns the chunk size to split input into when asked for
+// size, as NewWriterSize describes
+func chunkSizeFor(size, typeSize int) int {
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	size = min(size, MaxBufferSize)
+	if typeSize > 1 {
+		size = max(size-size%typeSize, typeSize)
+	}
+	return size
+}
+
+// SetAdaptive sets the policy used to re-tune compression of later chunks.
+func (w *Writer) SetAdaptive(policy AdaptivePolicy) {
+	w.enc.policy = policy
+	w.enc.count = 0
+}
+
+// Write buffers p, compressing and writing out every full chunk.
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n := 0
+	for len(p) > 0 {
+		// Compress straight from p when no partial chunk is pending
+		if len(w.buf) == 0 && len(p) >= w.size {
+			if err := w.writeChunk(p[:w.size]); err != nil {
+				return n, err
+			}
+			n += w.size
+			p = p[w.size:]
+			continue
+		}
+		if w.buf == nil {
+			w.buf = make([]byte, 0, w.size)
+		}
+		m := min(len(p), w.size-len(w.buf))
+		w.buf = append(w.buf, p[:m]...)
+		n += m
+		p = p[m:]
+		if len(w.buf) == w.size {
+			if err := w.Flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// Flush compresses and writes any buffered data as a chunk, which may be
+// shorter than the chunk size.
+func (w *Writer) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	if len(w.buf) == 0 {
+		return nil
+	}
+	err := w.writeChunk(w.buf)
+	w.buf = w.buf[:0]
+	return err
+}
+
+// CopyCompress compresses src into dst until src is exhausted, writing
+// chunks of DefaultChunkSize bytes as a Writer with opts would, and returns
+// the number of bytes read from src.
+func CopyCompress(dst io.Writer, src io.Reader, opts Options) (int64, error) {
+	w := NewWriter(dst, opts)
+	n, err := io.Copy(w, src)
+	if err != nil {
+		return n, err
+	}
+	return n, w.Close()
+}
+
+// Close flushes buffered data. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	return w.Flush()
+}
+
+func (w *Writer) writeChunk(data []byte) error {
+	chunk, err := w.enc.compress(data)
+	if err == nil {
+		_, err = w.w.Write(chunk)
+	}
+	if err != nil {
+		w.err = err
+	}
+	return err
+}
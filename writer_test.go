@@ -0,0 +1,122 @@
+package blosc
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+)
+
+// readChunks decompresses back-to-back chunks
+func readChunks(t *testing.T, stream []byte) ([]byte, int) {
+	t.Helper()
+	var out []byte
+	n := 0
+	for len(stream) > 0 {
+		header, err := ParseHeader(stream)
+		if err != nil {
+			t.Fatalf("chunk %d: %v", n, err)
+		}
+		data, err := Decompress(stream[:header.NBytesComp])
+		if err != nil {
+			t.Fatalf("chunk %d: %v", n, err)
+		}
+		out = append(out, data...)
+		stream = stream[header.NBytesComp:]
+		n++
+	}
+	return out, n
+}
+
+func TestWriter(t *testing.T) {
+	data := makeFloatData(50000)
+	var buf bytes.Buffer
+	w := NewWriterSize(&buf, Options{Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: 4}, 30001)
+
+	// Odd-sized writes exercise both the buffered and the direct path
+	for p, step := data, 1; len(p) > 0; step = step*3 + 1 {
+		m := min(step, len(p))
+		n, err := w.Write(p[:m])
+		if err != nil || n != m {
+			t.Fatalf("write returned %d, %v", n, err)
+		}
+		p = p[m:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	got, n := readChunks(t, buf.Bytes())
+	if !bytes.Equal(got, data) {
+		t.Error("stream does not round trip")
+	}
+	// 30001 rounds down to 30000, a multiple of the element size
+	if want := (len(data) + 29999) / 30000; n != want {
+		t.Errorf("wrote %d chunks, want %d", n, want)
+	}
+}
+
+func TestWriterSizeLimit(t *testing.T) {
+	w := NewWriterSize(nil, Options{TypeSize: 8}, math.MaxInt)
+	if w.size > MaxBufferSize || w.size%8 != 0 || w.size < MaxBufferSize-7 {
+		t.Errorf("chunk size %d for a limit of %d", w.size, MaxBufferSize)
+	}
+}
+
+func TestWriterAdaptive(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriterSize(&buf, Options{Codec: Snappy, Level: 5, Shuffle: NoShuffle, TypeSize: 4}, 64<<10)
+	w.SetAdaptive(AdaptivePolicy{Interval: 3, Objective: TuneRatio})
+
+	data := append(makeFloatData(100000), makeTestData(200000)...)
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	got, _ := readChunks(t, buf.Bytes())
+	if !bytes.Equal(got, data) {
+		t.Error("mixed-codec stream does not round trip")
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestWriterStickyError(t *testing.T) {
+	w := NewWriterSize(failingWriter{}, DefaultOptions(), 1024)
+	if _, err := w.Write(make([]byte, 4096)); err == nil {
+		t.Fatal("expected the underlying write error")
+	}
+	if _, err := w.Write([]byte{1}); err == nil {
+		t.Error("expected the error to persist")
+	}
+	if err := w.Close(); err == nil {
+		t.Error("expected Close to report the error")
+	}
+}
+
+func TestCopyCompress(t *testing.T) {
+	data := makeFloatData(600000) // 2.4 MB, three chunks
+	opts := Options{Codec: LZ4, Level: 5, Shuffle: Shuffle1, TypeSize: 4}
+
+	var out bytes.Buffer
+	n, err := CopyCompress(&out, bytes.NewReader(data), opts)
+	if err != nil {
+		t.Fatalf("CopyCompress failed: %v", err)
+	}
+	if n != int64(len(data)) {
+		t.Errorf("read %d bytes, want %d", n, len(data))
+	}
+	got, chunks := readChunks(t, out.Bytes())
+	if !bytes.Equal(got, data) || chunks != 3 {
+		t.Errorf("got %d chunks that do not round trip", chunks)
+	}
+
+	if _, err := CopyCompress(failingWriter{}, bytes.NewReader(data), opts); err == nil {
+		t.Error("expected the underlying write error")
+	}
+}
@@ -0,0 +1,100 @@
+package blosc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// zstd frame magic numbers. Skippable frames take the 16 magic numbers
+// 0x184D2A50 to 0x184D2A5F, the low 4 bits telling kinds of frame apart.
+const (
+	zstdFrameMagic     = 0xFD2FB528
+	zstdSkippableMagic = 0x184D2A50
+)
+
+// zstdSkippableFrames calls fn with the ID and content of each skippable
+// frame in stream, a sequence of zstd frames, walking the zstd frames by
+// their block headers. The zstd decoder skips such frames, so this finds
+// what decompression leaves out.
+func zstdSkippableFrames(stream []byte, fn func(id int, content []byte)) error {
+	for p := 0; p < len(stream); {
+		if len(stream)-p < 8 {
+			return fmt.Errorf("zstd frame at %d truncated", p)
+		}
+		magic := binary.LittleEndian.Uint32(stream[p:])
+		if magic&^0xF == zstdSkippableMagic {
+			size := int64(binary.LittleEndian.Uint32(stream[p+4:]))
+			if size > int64(len(stream)-p-8) {
+				return fmt.Errorf("zstd skippable frame at %d truncated", p)
+			}
+			fn(int(magic&0xF), stream[p+8:p+8+int(size)])
+			p += 8 + int(size)
+			continue
+		}
+		if magic != zstdFrameMagic {
+			return fmt.Errorf("zstd frame at %d has magic 0x%08x", p, magic)
+		}
+		n, err := zstdFrameSize(stream[p:])
+		if err != nil {
+			return fmt.Errorf("zstd frame at %d: %w", p, err)
+		}
+		p += n
+	}
+	return nil
+}
+
+// zstdFrameSize returns the size of the zstd frame that frame starts with
+func zstdFrameSize(frame []byte) (int, error) {
+	truncated := errors.New("truncated")
+	if len(frame) < 5 {
+		return 0, truncated
+	}
+	fhd := frame[4]
+	singleSegment := fhd&0x20 != 0
+	p := 5
+	if !singleSegment {
+		p++ // Window descriptor
+	}
+	// Dictionary ID and frame content size
+	p += [4]int{0, 1, 2, 4}[fhd&0x3]
+	switch fhd >> 6 {
+	case 0:
+		if singleSegment {
+			p++
+		}
+	case 1:
+		p += 2
+	case 2:
+		p += 4
+	case 3:
+		p += 8
+	}
+
+	for last := false; !last; {
+		if len(frame)-p < 3 {
+			return 0, truncated
+		}
+		h := uint32(frame[p]) | uint32(frame[p+1])<<8 | uint32(frame[p+2])<<16
+		p += 3
+		last = h&1 != 0
+		size := int(h >> 3)
+		switch (h >> 1) & 0x3 {
+		case 1:
+			size = 1 // An RLE block holds the byte it repeats
+		case 3:
+			return 0, errors.New("reserved block type")
+		}
+		if size > len(frame)-p {
+			return 0, truncated
+		}
+		p += size
+	}
+	if fhd&0x4 != 0 {
+		p += 4 // Content checksum
+	}
+	if p > len(frame) {
+		return 0, truncated
+	}
+	return p, nil
+}
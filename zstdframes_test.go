@@ -0,0 +1,112 @@
+package blosc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// skippableFrame returns a zstd skippable frame of content
+func skippableFrame(id int, content string) []byte {
+	b := binary.LittleEndian.AppendUint32(nil, zstdSkippableMagic|uint32(id))
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(content)))
+	return append(b, content...)
+}
+
+// replaceStream returns a Blosc1 chunk of one block and one stream with the
+// stream rewritten by edit
+func replaceStream(t *testing.T, chunk []byte, edit func(stream []byte) []byte) []byte {
+	t.Helper()
+	h, err := ParseHeader(chunk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := h.numBlocks(); n != 1 || h.IsExtended() || h.IsMemcpy() {
+		t.Fatalf("chunk %s is not a Blosc1 chunk of one block", h)
+	}
+	size := int(binary.LittleEndian.Uint32(chunk[HeaderSize+4:]))
+	stream := edit(chunk[HeaderSize+8 : HeaderSize+8+size : HeaderSize+8+size])
+	out := append([]byte(nil), chunk[:HeaderSize+4]...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(stream)))
+	out = append(out, stream...)
+	binary.LittleEndian.PutUint32(out[offsetNBytesComp:], uint32(len(out)))
+	return out
+}
+
+func TestZSTDSkippableFrames(t *testing.T) {
+	text := makeRecords(0, 50)
+	crc, _ := zstd.NewWriter(nil, zstd.WithEncoderCRC(true))
+	plain, _ := zstd.NewWriter(nil, zstd.WithEncoderCRC(false), zstd.WithEncoderLevel(zstd.SpeedFastest))
+	var streamed bytes.Buffer
+	w, _ := zstd.NewWriter(&streamed)
+	w.Write(text)
+	w.Close()
+
+	var stream []byte
+	for _, part := range [][]byte{
+		skippableFrame(3, "meta"),
+		crc.EncodeAll(text, nil),
+		plain.EncodeAll(make([]byte, 5000), nil),  // RLE block
+		plain.EncodeAll(randomBytes(3000), nil),   // Raw block
+		plain.EncodeAll(randomBytes(300000), nil), // Several blocks
+		streamed.Bytes(),                          // Window descriptor, no content size
+		skippableFrame(15, ""),
+	} {
+		stream = append(stream, part...)
+	}
+	var found []string
+	record := func(id int, content []byte) { found = append(found, fmt.Sprintf("%d:%s", id, content)) }
+	if err := zstdSkippableFrames(stream, record); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(found) != "[3:meta 15:]" {
+		t.Errorf("found skippable frames %q", found)
+	}
+	for _, cut := range []int{1, 5, len(stream) - 20} {
+		if err := zstdSkippableFrames(stream[:len(stream)-cut], func(int, []byte) {}); err == nil {
+			t.Errorf("stream cut by %d bytes: no error", cut)
+		}
+	}
+	if err := zstdSkippableFrames([]byte("not a zstd frame"), func(int, []byte) {}); err == nil {
+		t.Error("bad magic: no error")
+	}
+
+	// Chunks with skippable frames around their streams decompress, and
+	// report the frames when asked
+	chunk, err := CompressWithOptions(text, Options{Codec: ZSTD, Level: 5, Shuffle: NoShuffle, TypeSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	only := replaceStream(t, chunk, func([]byte) []byte { return skippableFrame(0, "nothing else") })
+	chunk = replaceStream(t, chunk, func(s []byte) []byte {
+		return append(append(skippableFrame(1, "producer=x"), s...), skippableFrame(2, "end")...)
+	})
+	if got, err := Decompress(chunk); err != nil || !bytes.Equal(got, text) {
+		t.Fatalf("Decompress: %v", err)
+	}
+	if err := Verify(chunk); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+	found = nil
+	got, err := DecompressWithOptions(chunk, DecompressOptions{SkippableFrames: func(block, id int, content []byte) {
+		if block != 0 {
+			t.Errorf("frame %d reported in block %d", id, block)
+		}
+		record(id, content)
+	}})
+	if err != nil || !bytes.Equal(got, text) {
+		t.Fatalf("DecompressWithOptions: %v", err)
+	}
+	if fmt.Sprint(found) != "[1:producer=x 2:end]" {
+		t.Errorf("found skippable frames %q", found)
+	}
+
+	// A stream that is only a skippable frame decodes to nothing
+	if _, err := Decompress(only); !errors.Is(err, ErrSizeMismatch) {
+		t.Errorf("expected ErrSizeMismatch, got %v", err)
+	}
+}